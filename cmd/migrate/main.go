@@ -0,0 +1,123 @@
+// Command migrate applies, rolls back and inspects the PostgreSQL schema
+// migrations embedded from internal/migrations.
+//
+//	migrate up [VERSION]   apply pending migrations, optionally stopping at VERSION
+//	migrate down [STEPS]   roll back the last STEPS migrations (default 1)
+//	migrate status         list migrations and whether they are applied
+//	migrate verify         fail if an applied migration was edited or is unknown
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/URLshorter/url-shortener/configs"
+	"github.com/URLshorter/url-shortener/internal/migrations"
+	"github.com/URLshorter/url-shortener/internal/storage"
+)
+
+const usage = `usage: migrate <command> [arg]
+
+commands:
+  up [VERSION]   apply pending migrations, optionally stopping at VERSION
+  down [STEPS]   roll back the last STEPS migrations (default 1)
+  status         list migrations and whether they are applied
+  verify         fail if an applied migration was edited or is unknown`
+
+func main() {
+	log.SetFlags(0)
+
+	if len(os.Args) < 2 || len(os.Args) > 3 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	command, arg := os.Args[1], ""
+	if len(os.Args) == 3 {
+		arg = os.Args[2]
+	}
+
+	config, err := configs.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	db, err := storage.NewPostgresStorage(config)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	runner, err := migrations.NewRunner(db.DB())
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+
+	ctx := context.Background()
+	switch command {
+	case "up":
+		target := parseArg(arg, 0)
+		applied, err := runner.Up(ctx, target)
+		for _, m := range applied {
+			log.Printf("applied %04d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		if len(applied) == 0 {
+			log.Println("schema is up to date")
+		}
+	case "down":
+		steps := parseArg(arg, 1)
+		rolledBack, err := runner.Down(ctx, int(steps))
+		for _, m := range rolledBack {
+			log.Printf("rolled back %04d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("Rollback failed: %v", err)
+		}
+	case "status":
+		statuses, err := runner.Status(ctx)
+		if err != nil {
+			log.Fatalf("Failed to read migration status: %v", err)
+		}
+		printStatus(statuses)
+	case "verify":
+		if err := runner.Verify(ctx); err != nil {
+			log.Fatalf("Verification failed: %v", err)
+		}
+		log.Println("applied migrations match this build")
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func parseArg(arg string, defaultValue int64) int64 {
+	if arg == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || value <= 0 {
+		log.Fatalf("Invalid argument %q: expected a positive number", arg)
+	}
+	return value
+}
+
+func printStatus(statuses []migrations.Status) {
+	for _, s := range statuses {
+		state := "pending"
+		switch {
+		case s.Unknown():
+			state = "UNKNOWN (not in this build)"
+		case s.Drifted():
+			state = "DRIFTED (edited after being applied)"
+		case s.Applied():
+			state = "applied " + s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Printf("%04d  %-32s %s\n", s.Version, s.Name, state)
+	}
+}
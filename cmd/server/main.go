@@ -2,6 +2,10 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
 	"log"
 	"net/http"
 	"os"
@@ -12,6 +16,7 @@ import (
 	"github.com/URLshorter/url-shortener/configs"
 	"github.com/URLshorter/url-shortener/internal/handlers"
 	"github.com/URLshorter/url-shortener/internal/middleware"
+	"github.com/URLshorter/url-shortener/internal/migrations"
 	"github.com/URLshorter/url-shortener/internal/routes"
 	"github.com/URLshorter/url-shortener/internal/services"
 	"github.com/URLshorter/url-shortener/internal/storage"
@@ -25,53 +30,185 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
-	// Initialize storage layers
-	db, err := storage.NewPostgresStorage(config)
-	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+	migrate := flag.Bool("migrate", config.MigrateOnStartup, "apply pending schema migrations before serving")
+	flag.Parse()
+
+	// Initialize storage layers. The SQLite and memory backends replace
+	// Redis with an in-process cache; Postgres-only features (advanced
+	// analytics, A/B tests, attribution, unique visitors) are unavailable
+	// with them.
+	var store storage.Store
+	var cache storage.Cache
+	var db *storage.PostgresStorage
+	var redis *storage.RedisStorage
+
+	switch config.StorageBackend {
+	case configs.StorageBackendSQLite:
+		sqlite, err := storage.NewSQLiteStorage(config)
+		if err != nil {
+			log.Fatalf("Failed to open SQLite database: %v", err)
+		}
+		defer sqlite.Close()
+
+		store = sqlite
+		cache = storage.NewLRUCache(config.URLCacheSize)
+	case configs.StorageBackendMemory:
+		log.Println("Using in-memory storage; data will be lost on restart")
+		store = storage.NewMemoryStorage()
+		cache = storage.NewMemoryCache()
+	case configs.StorageBackendPostgres:
+		db, err = storage.NewPostgresStorage(config)
+		if err != nil {
+			log.Fatalf("Failed to connect to database: %v", err)
+		}
+		defer db.Close()
+
+		if err := prepareSchema(db, *migrate); err != nil {
+			log.Fatalf("Failed to prepare database schema: %v", err)
+		}
+
+		redis, err = storage.NewRedisStorage(config)
+		if err != nil {
+			log.Fatalf("Failed to connect to Redis: %v", err)
+		}
+		defer redis.Close()
+
+		store, cache = db, redis
+	default:
+		log.Fatalf("Unknown storage backend %q", config.StorageBackend)
 	}
-	defer db.Close()
 
-	redis, err := storage.NewRedisStorage(config)
-	if err != nil {
-		log.Fatalf("Failed to connect to Redis: %v", err)
+	// Password-protected link cookies are signed with their own secret, which
+	// signing keys don't provide
+	if err := configureLinkPasswordSecret(config); err != nil {
+		log.Fatalf("Failed to configure link password secret: %v", err)
 	}
-	defer redis.Close()
 
 	// Initialize services
-	shortenerService := services.NewShortenerService(db, redis, config)
-	analyticsService := services.NewAnalyticsService(db)
+	shortenerService := services.NewShortenerService(store, cache, config)
+	analyticsService := services.NewAnalyticsService(store)
 	advancedAnalyticsService := services.NewAdvancedAnalyticsService(db)
 	// userAnalyticsService := services.NewUserAnalyticsService(db)  // Temporarily disabled
 	var userAnalyticsService *services.UserAnalyticsService // Placeholder
 
+	// Match country redirect rules against local GeoIP databases
+	if config.GeoIPCityDBPath != "" || config.GeoIPCountryDBPath != "" {
+		geoIPService := services.NewGeoIPService()
+		if err := geoIPService.Initialize(config.GeoIPCityDBPath, config.GeoIPCountryDBPath); err != nil {
+			log.Fatalf("Failed to load GeoIP database: %v", err)
+		}
+		defer geoIPService.Close()
+		shortenerService.SetGeoIPService(geoIPService)
+	} else {
+		log.Println("Warning: no GeoIP database configured; country redirect rules will not match")
+	}
+
 	// Initialize auth-related services
-	smsService := services.NewSMSService(db, config)
-	emailService := services.NewEmailService(db, config)
+	smsService := services.NewSMSService(store, config)
+	emailService := services.NewEmailService(store, config)
 	jwtService := services.NewJWTService(config.JWTSecret, config.JWTIssuer, time.Hour*24, time.Hour*24*7)
-	userService := services.NewUserService(db, redis, jwtService, smsService, emailService, nil)
-	authService := services.NewAuthService(userService, jwtService, smsService, emailService, db, redis, config)
+	jwtService.SetRevocationCache(cache)
+	if err := configureSigningKeys(jwtService, config); err != nil {
+		log.Fatalf("Failed to configure JWT signing keys: %v", err)
+	}
+	userService := services.NewUserService(store, cache, jwtService, smsService, emailService, nil)
+	authService := services.NewAuthService(userService, jwtService, smsService, emailService, store, cache, config)
+
+	// Authenticator app two-factor. Team owners can be required to use it
+	// where teams are stored.
+	twoFactorService := services.NewTwoFactorService(store, cache, config.TwoFactorIssuer)
+	if config.TwoFactorRequireTeamOwners && db != nil {
+		twoFactorService.SetTeamOwnerLookup(db.IsTeamOwner)
+	}
+	authService.SetTwoFactorService(twoFactorService)
+
 	rbacService := services.NewRBACService(db, redis)
-	conversionTrackingService := services.NewConversionTrackingService(db)
-	abTestingService := services.NewABTestingService(db, redis)
-	realtimeAnalyticsService := services.NewRealtimeAnalyticsService(db, redis, analyticsService)
-	attributionService := services.NewAttributionService(db, conversionTrackingService)
 	// cmsService := services.NewCMSService(db)  // Temporarily disabled
-	// apiKeyService := services.NewAPIKeyService(db)  // Temporarily disabled
-	
-	// Set real-time service on shortener for click broadcasting
-	shortenerService.SetRealtimeService(realtimeAnalyticsService)
-	
-	// Set attribution service on shortener for attribution tracking
-	shortenerService.SetAttributionService(attributionService)
+
+	// Conversion tracking, A/B tests, real-time stats, attribution and unique
+	// visitors query Postgres and Redis directly, so other backends go without
+	var conversionTrackingService *services.ConversionTrackingService
+	var abTestingService *services.ABTestingService
+	var realtimeAnalyticsService *services.RealtimeAnalyticsService
+	var attributionService *services.AttributionService
+	var uniqueVisitorService *services.UniqueVisitorService
+	if db != nil {
+		conversionTrackingService = services.NewConversionTrackingService(db)
+		abTestingService = services.NewABTestingService(db, redis)
+		realtimeAnalyticsService = services.NewRealtimeAnalyticsService(db, redis, analyticsService)
+		attributionService = services.NewAttributionService(db, conversionTrackingService)
+
+		// Set real-time service on shortener for click broadcasting
+		shortenerService.SetRealtimeService(realtimeAnalyticsService)
+
+		// Set attribution service on shortener for attribution tracking
+		shortenerService.SetAttributionService(attributionService)
+
+		// Count unique visitors with HyperLogLog sketches persisted to Postgres
+		uniqueVisitorService = services.NewUniqueVisitorService(db, redis)
+		uniqueVisitorService.Start()
+		shortenerService.SetUniqueVisitorService(uniqueVisitorService)
+		analyticsService.SetUniqueVisitorService(uniqueVisitorService)
+	}
+
+	// Screen destinations against the blocklist and reputation feeds, and
+	// re-check existing links in the background
+	var linkSafetyService *services.LinkSafetyService
+	if config.LinkSafetyEnabled {
+		linkSafetyService = services.NewLinkSafetyServiceFromConfig(store, cache, config)
+		linkSafetyService.Start()
+		shortenerService.SetLinkSafetyService(linkSafetyService)
+	}
+
+	// Import uploaded link files in the background, gated on the billing
+	// plan where subscriptions are stored
+	bulkImportService := services.NewBulkImportService(store, shortenerService, config)
+	if db != nil {
+		bulkImportService.SetPlanLookup(db.GetUserPlan)
+	}
+	bulkImportService.Start()
+
+	// Render QR codes; logos come from the media library and custom domains
+	// from the domains table, both of which live in Postgres
+	qrCodeService := services.NewQRCodeService(shortenerService, config)
+	if db != nil {
+		qrCodeService.SetLogoLookup(services.NewMediaService(db, "uploads", config.BaseURL).GetMediaFile)
+		qrCodeService.SetDomainLookup(db.HasVerifiedDomain)
+	}
 
 	// Initialize auth middleware
 	authMiddleware := middleware.NewAuthMiddleware(jwtService, userService, rbacService)
 
+	// API keys are stored in Postgres; other backends accept JWTs only.
+	// Usage is recorded by a fixed set of workers.
+	var apiKeyService *services.APIKeyService
+	var apiKeyUsage *services.APIKeyUsageRecorder
+	var apiKeys middleware.APIKeyValidator
+	if db != nil {
+		apiKeyService = services.NewAPIKeyService(db)
+		apiKeyService.SetPlanLookup(db.GetUserPlan)
+		apiKeyUsage = services.NewAPIKeyUsageRecorder(apiKeyService, services.DefaultAPIKeyUsageConfig())
+		apiKeys = apiKeyService
+	}
+	apiKeyAuth := middleware.NewAPIKeyAuth(authMiddleware, apiKeys, apiKeyUsage)
+
+	// Rate limit buckets live in the cache, so replicas sharing Redis share limits
+	rateLimiter := middleware.NewRateLimiter(cache)
+	if db != nil {
+		rateLimiter.SetPlanLookup(db.GetUserPlan)
+	}
+
 	// Initialize handlers
 	authHandlers := handlers.NewAuthHandlers(authService, smsService, emailService)
 	analyticsHandlers := handlers.NewAnalyticsHandlers(userAnalyticsService)
 	handler := handlers.NewHandler(shortenerService, analyticsService, advancedAnalyticsService, conversionTrackingService, abTestingService, realtimeAnalyticsService, attributionService, authHandlers, analyticsHandlers, db)
+	handler.BulkImportHandlers = handlers.NewBulkImportHandler(bulkImportService)
+	handler.QRCodeHandlers = handlers.NewQRCodeHandler(qrCodeService)
+	if apiKeyService != nil {
+		handler.APIKeyHandlers = handlers.NewAPIKeyHandler(apiKeyService)
+	}
+	handler.JWKSHandlers = handlers.NewJWKSHandler(jwtService)
+	handler.TwoFactorHandlers = handlers.NewTwoFactorHandler(twoFactorService, userService)
 
 	// Setup Gin router
 	if config.Environment == "production" {
@@ -79,9 +216,12 @@ func main() {
 	}
 
 	router := gin.Default()
+	if err := middleware.TrustProxies(router, config.TrustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
 
 	// Setup all routes
-	routes.SetupRoutes(router, handler, authMiddleware)
+	routes.SetupRoutes(router, handler, authMiddleware, apiKeyAuth, rateLimiter)
 
 	// Setup server
 	srv := &http.Server{
@@ -108,11 +248,130 @@ func main() {
 	defer cancel()
 
 	// Shutdown real-time analytics service
-	realtimeAnalyticsService.Shutdown()
+	if realtimeAnalyticsService != nil {
+		realtimeAnalyticsService.Shutdown()
+	}
 
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+		log.Printf("Server forced to shutdown: %v", err)
+	}
+
+	// Stop importing; unfinished jobs resume on the next start
+	if err := bulkImportService.Shutdown(ctx); err != nil {
+		log.Printf("Bulk import workers did not stop before timeout: %v", err)
+	}
+
+	// Record usage of requests served before shutdown
+	if apiKeyUsage != nil {
+		if err := apiKeyUsage.Shutdown(ctx); err != nil {
+			log.Printf("API key usage was not recorded before timeout: %v", err)
+		}
+	}
+
+	if linkSafetyService != nil {
+		if err := linkSafetyService.Shutdown(ctx); err != nil {
+			log.Printf("Link safety re-check did not stop before timeout: %v", err)
+		}
+	}
+
+	// Drain queued click events now that no new requests are being served
+	if err := shortenerService.Shutdown(ctx); err != nil {
+		log.Printf("Click ingestion did not drain before timeout: %v", err)
+	}
+	stats := shortenerService.ClickIngestionStats()
+	log.Printf("Click ingestion stopped: %d persisted, %d dropped, %d failed", stats.Persisted, stats.Dropped, stats.Failed)
+	effects := shortenerService.ClickEffectStats()
+	log.Printf("Click side effects stopped: %d processed, %d dropped", effects.Processed, effects.Dropped)
+
+	// Persist visitor sketches updated by the drained clicks
+	if uniqueVisitorService != nil {
+		uniqueVisitorService.Shutdown()
 	}
 
 	log.Println("Server exited")
-}
\ No newline at end of file
+}
+
+// configureSigningKeys signs tokens with the keys in JWT_SIGNING_KEYS. With
+// neither those nor JWT_SECRET set, development servers sign with a key
+// generated at startup, so tokens don't survive a restart; production
+// servers refuse to start.
+func configureSigningKeys(jwtService *services.JWTService, config *configs.Config) error {
+	if config.JWTSigningKeys != "" {
+		keys, err := services.LoadSigningKeys(config.JWTSigningKeys)
+		if err != nil {
+			return err
+		}
+		return jwtService.SetSigningKeys(keys)
+	}
+	if config.JWTSecret != "" {
+		return nil
+	}
+	if config.Environment == "production" {
+		return errors.New("set JWT_SIGNING_KEYS or JWT_SECRET")
+	}
+
+	log.Println("Warning: neither JWT_SIGNING_KEYS nor JWT_SECRET is set; signing tokens with a temporary key")
+	key, err := services.GenerateSigningKey("dev-" + time.Now().UTC().Format("20060102150405"))
+	if err != nil {
+		return err
+	}
+	return jwtService.SetSigningKeys([]*services.SigningKey{key})
+}
+
+// configureLinkPasswordSecret leaves LINK_PASSWORD_SECRET as configured. Without
+// it development servers sign link cookies with a secret generated at startup,
+// so visitors unlock links again after a restart; production servers refuse to
+// create or unlock password-protected links.
+func configureLinkPasswordSecret(config *configs.Config) error {
+	if config.LinkPasswordSecret != "" {
+		return nil
+	}
+	if config.Environment == "production" {
+		log.Println("Warning: LINK_PASSWORD_SECRET is not set; password-protected links are unavailable")
+		return nil
+	}
+
+	log.Println("Warning: LINK_PASSWORD_SECRET is not set; signing link cookies with a temporary secret")
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return err
+	}
+	config.LinkPasswordSecret = hex.EncodeToString(secret)
+	return nil
+}
+
+// prepareSchema applies pending migrations when asked to and otherwise only
+// warns about them, so a stale schema is noticed before queries start failing
+func prepareSchema(db *storage.PostgresStorage, migrate bool) error {
+	runner, err := migrations.NewRunner(db.DB())
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if migrate {
+		applied, err := runner.Up(ctx, 0)
+		for _, m := range applied {
+			log.Printf("Applied schema migration %04d_%s", m.Version, m.Name)
+		}
+		return err
+	}
+
+	statuses, err := runner.Status(ctx)
+	if err != nil {
+		return err
+	}
+	pending := 0
+	for _, s := range statuses {
+		switch {
+		case s.Drifted():
+			log.Printf("Warning: schema migration %04d_%s was edited after being applied", s.Version, s.Name)
+		case !s.Applied():
+			pending++
+		}
+	}
+	if pending > 0 {
+		log.Printf("Warning: %d schema migration(s) pending; run `migrate up` or start with -migrate", pending)
+	}
+	return nil
+}
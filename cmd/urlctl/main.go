@@ -0,0 +1,725 @@
+// Command urlctl is the operator tool for links and accounts. It opens the
+// storage backend configured for the server and prints JSON to stdout so it
+// can be scripted; errors are printed as {"error": "..."} to stderr with a
+// non-zero exit status. Flags go before positional arguments.
+//
+//	urlctl create -url URL [-code CODE] [-user USER] [-expires TIME] [-redirect-type TYPE]
+//	urlctl disable CODE
+//	urlctl enable CODE
+//	urlctl transfer -to USER CODE
+//	urlctl import [-user USER] FILE
+//	urlctl whois CODE
+//	urlctl purge-cache CODE
+//	urlctl reset-password -user USER [-password PASSWORD]
+//	urlctl assign-role -user USER -role ROLE [-expires TIME]
+//	urlctl require-2fa [-off] -user USER
+//	urlctl reset-2fa -user USER
+//	urlctl analytics [-days N] [-bots] CODE
+//	urlctl block -kind domain|url [-reason REASON] PATTERN
+//	urlctl unblock ID
+//	urlctl blocklist
+//	urlctl quarantine [-reason REASON] CODE
+//	urlctl release CODE
+//	urlctl recheck
+//
+// USER is a user ID or email address and TIME is in RFC 3339 format.
+//
+// disable, enable, transfer, purge-cache, reset-password, quarantine, release
+// and recheck reach the running server through the Redis cache, so they are
+// refused with SQLite, whose server caches in its own process.
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/URLshorter/url-shortener/configs"
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/URLshorter/url-shortener/internal/services"
+	"github.com/URLshorter/url-shortener/internal/storage"
+)
+
+const usage = `usage: urlctl <command> [flags] [args]
+
+commands:
+  create          -url URL [-code CODE] [-user USER] [-expires TIME] [-redirect-type TYPE]
+  disable         CODE
+  enable          CODE
+  transfer        -to USER CODE
+  import          [-user USER] FILE   (CSV with a header row; "-" reads stdin)
+  whois           CODE
+  purge-cache     CODE
+  reset-password  -user USER [-password PASSWORD]
+  assign-role     -user USER -role ROLE [-expires TIME]
+  require-2fa     [-off] -user USER   (the user must enrol at their next login)
+  reset-2fa       -user USER   (removes a lost authenticator and recovery codes)
+  analytics       [-days N] [-bots] CODE
+  block           -kind domain|url [-reason REASON] PATTERN   (* in url patterns matches anything)
+  unblock         ID
+  blocklist
+  quarantine      [-reason REASON] CODE
+  release         CODE
+  recheck         (screens one batch of links that are due a re-check)
+
+USER is a user ID or email address, TIME is RFC 3339.
+
+disable, enable, transfer, purge-cache, reset-password, quarantine, release and
+recheck need the Redis cache shared with the server, so they are refused with SQLite.`
+
+// errUsage marks invalid invocations, which exit with status 2
+var errUsage = errors.New("invalid arguments")
+
+// errNoSharedCache refuses commands the server would not notice
+var errNoSharedCache = errors.New("this command needs the Redis cache shared with the server; with SQLite the server caches links and sessions in its own process, which urlctl can't invalidate")
+
+// app holds the storage and services shared by the commands
+type app struct {
+	store     storage.Store
+	cache     storage.Cache
+	shortener *services.ShortenerService
+	users     *services.UserService
+	rbac      *services.RBACService
+	twoFactor *services.TwoFactorService
+	analytics *services.AnalyticsService
+	safety    *services.LinkSafetyService
+	close     func()
+
+	// sharedCache is set when the cache is the server's own, so dropping
+	// entries from it takes effect there
+	sharedCache bool
+}
+
+type command func(a *app, args []string) (interface{}, error)
+
+var commands = map[string]command{
+	"create":         createLink,
+	"disable":        needsSharedCache(func(a *app, args []string) (interface{}, error) { return setActive(a, args, false) }),
+	"enable":         needsSharedCache(func(a *app, args []string) (interface{}, error) { return setActive(a, args, true) }),
+	"transfer":       needsSharedCache(transferLink),
+	"import":         importLinks,
+	"whois":          whois,
+	"purge-cache":    needsSharedCache(purgeCache),
+	"reset-password": needsSharedCache(resetPassword),
+	"assign-role":    assignRole,
+	"require-2fa":    requireTwoFactor,
+	"reset-2fa":      resetTwoFactor,
+	"analytics":      printAnalytics,
+	"block":          blockDestination,
+	"unblock":        unblockDestination,
+	"blocklist":      listBlocklist,
+	"quarantine":     needsSharedCache(quarantineLink),
+	"release":        needsSharedCache(releaseLink),
+	"recheck":        needsSharedCache(recheckLinks),
+}
+
+// needsSharedCache guards a command whose change only reaches the server by
+// dropping cached links or sessions. Without a shared cache it would succeed
+// here while the server kept serving the old state, so it is refused.
+func needsSharedCache(run command) command {
+	return func(a *app, args []string) (interface{}, error) {
+		if !a.sharedCache {
+			return nil, errNoSharedCache
+		}
+		return run(a, args)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	run, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	config, err := configs.LoadConfig()
+	if err != nil {
+		fail(fmt.Errorf("failed to load config: %w", err))
+	}
+
+	a, err := newApp(config)
+	if err != nil {
+		fail(err)
+	}
+
+	result, err := run(a, os.Args[2:])
+	a.close()
+	if result != nil {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(result)
+	}
+	if err != nil {
+		fail(err)
+	}
+}
+
+// fail prints err as JSON and exits
+func fail(err error) {
+	json.NewEncoder(os.Stderr).Encode(map[string]string{"error": err.Error()})
+	if errors.Is(err, errUsage) {
+		os.Exit(2)
+	}
+	os.Exit(1)
+}
+
+// newApp opens the configured backend the same way the server does
+func newApp(config *configs.Config) (*app, error) {
+	// Clicks are never recorded here, so there is nothing to consume
+	config.ClickStreamEnabled = false
+
+	a := &app{}
+	var db *storage.PostgresStorage
+	var redis *storage.RedisStorage
+
+	switch config.StorageBackend {
+	case configs.StorageBackendSQLite:
+		sqlite, err := storage.NewSQLiteStorage(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+		}
+		a.store, a.cache = sqlite, storage.NewLRUCache(config.URLCacheSize)
+	case configs.StorageBackendPostgres:
+		var err error
+		db, err = storage.NewPostgresStorage(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to database: %w", err)
+		}
+		redis, err = storage.NewRedisStorage(config)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+		}
+		a.store, a.cache = db, redis
+		a.sharedCache = true
+	default:
+		// The memory backend lives inside the server process
+		return nil, fmt.Errorf("urlctl needs a persistent storage backend, not %q", config.StorageBackend)
+	}
+
+	a.shortener = services.NewShortenerService(a.store, a.cache, config)
+	// Tokens are never issued here; the JWT service only bumps token
+	// generations in the shared cache so reset-password logs the user out.
+	jwtService := services.NewJWTService(config.JWTSecret, config.JWTIssuer, time.Hour*24, time.Hour*24*7)
+	jwtService.SetRevocationCache(a.cache)
+	a.users = services.NewUserService(a.store, a.cache, jwtService, nil, nil, nil)
+	a.rbac = services.NewRBACService(db, redis)
+	a.twoFactor = services.NewTwoFactorService(a.store, a.cache, config.TwoFactorIssuer)
+	a.analytics = services.NewAnalyticsService(a.store)
+	a.analytics.SetCache(a.cache)
+	a.safety = services.NewLinkSafetyServiceFromConfig(a.store, a.cache, config)
+	if config.LinkSafetyEnabled {
+		a.shortener.SetLinkSafetyService(a.safety)
+	}
+	if db != nil {
+		if config.TwoFactorRequireTeamOwners {
+			a.twoFactor.SetTeamOwnerLookup(db.IsTeamOwner)
+		}
+		// Only reads sketches; the server persists them
+		a.analytics.SetUniqueVisitorService(services.NewUniqueVisitorService(db, redis))
+	}
+
+	a.close = func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		a.shortener.Shutdown(ctx)
+		if redis != nil {
+			redis.Close()
+		}
+		a.store.Close()
+	}
+	return a, nil
+}
+
+// parseFlags parses args and checks the number of positional arguments
+func parseFlags(fs *flag.FlagSet, args []string, positional int) ([]string, error) {
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", errUsage, fs.Name(), err)
+	}
+	if fs.NArg() != positional {
+		return nil, fmt.Errorf("%w: %s expects %d argument(s), got %d", errUsage, fs.Name(), positional, fs.NArg())
+	}
+	return fs.Args(), nil
+}
+
+func parseTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid time %q, expected RFC 3339", errUsage, value)
+	}
+	return &t, nil
+}
+
+// findUser resolves a user ID or email address
+func (a *app) findUser(ref string) (*models.User, error) {
+	if ref == "" {
+		return nil, fmt.Errorf("%w: a user is required", errUsage)
+	}
+
+	var user *models.User
+	var err error
+	if id, parseErr := strconv.ParseInt(ref, 10, 64); parseErr == nil {
+		user, err = a.users.GetUserByID(id)
+	} else {
+		user, err = a.users.GetUserByEmail(ref)
+	}
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("user %q not found", ref)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// findUserID resolves an optional user reference
+func (a *app) findUserID(ref string) (*int64, error) {
+	if ref == "" {
+		return nil, nil
+	}
+	user, err := a.findUser(ref)
+	if err != nil {
+		return nil, err
+	}
+	return &user.ID, nil
+}
+
+type linkStatus struct {
+	ShortCode string `json:"short_code"`
+	IsActive  bool   `json:"is_active"`
+}
+
+func createLink(a *app, args []string) (interface{}, error) {
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	url := fs.String("url", "", "destination URL")
+	code := fs.String("code", "", "custom short code")
+	userRef := fs.String("user", "", "owner, as a user ID or email")
+	expires := fs.String("expires", "", "expiry time")
+	redirectType := fs.String("redirect-type", "", "301, 302, 307, 308 or meta_refresh")
+	if _, err := parseFlags(fs, args, 0); err != nil {
+		return nil, err
+	}
+	if *url == "" {
+		return nil, fmt.Errorf("%w: create needs -url", errUsage)
+	}
+
+	expiresAt, err := parseTime(*expires)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := a.findUserID(*userRef)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := a.shortener.ShortenURL(&models.ShortenRequest{
+		URL:          *url,
+		CustomCode:   *code,
+		ExpiresAt:    expiresAt,
+		RedirectType: *redirectType,
+	}, "", userID)
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func setActive(a *app, args []string, active bool) (interface{}, error) {
+	name := "disable"
+	if active {
+		name = "enable"
+	}
+	positional, err := parseFlags(flag.NewFlagSet(name, flag.ContinueOnError), args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.shortener.SetURLActive(positional[0], active); err != nil {
+		return nil, err
+	}
+	return linkStatus{ShortCode: positional[0], IsActive: active}, nil
+}
+
+func transferLink(a *app, args []string) (interface{}, error) {
+	fs := flag.NewFlagSet("transfer", flag.ContinueOnError)
+	to := fs.String("to", "", "new owner, as a user ID or email")
+	positional, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := a.findUser(*to)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.shortener.TransferURL(positional[0], user.ID); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"short_code": positional[0], "user_id": user.ID, "email": user.Email}, nil
+}
+
+type importResult struct {
+	Line      int    `json:"line"`
+	URL       string `json:"url"`
+	ShortCode string `json:"short_code,omitempty"`
+	ShortURL  string `json:"short_url,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type importSummary struct {
+	Created int            `json:"created"`
+	Failed  int            `json:"failed"`
+	Results []importResult `json:"results"`
+}
+
+// importLinks creates a link per CSV row. The header row names the columns:
+// url is required, custom_code, expires_at and redirect_type are optional.
+// A failing row does not stop the import; the exit status is non-zero if any
+// row failed.
+func importLinks(a *app, args []string) (interface{}, error) {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	userRef := fs.String("user", "", "owner of the imported links, as a user ID or email")
+	positional, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, err := a.findUserID(*userRef)
+	if err != nil {
+		return nil, err
+	}
+
+	var input io.Reader = os.Stdin
+	if positional[0] != "-" {
+		file, err := os.Open(positional[0])
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		input = file
+	}
+
+	reader := csv.NewReader(input)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["url"]; !ok {
+		return nil, fmt.Errorf("CSV header needs a url column")
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	summary := &importSummary{Results: []importResult{}}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return summary, fmt.Errorf("failed to read CSV: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		result := importResult{Line: line, URL: field(record, "url")}
+		response, err := func() (*models.ShortenResponse, error) {
+			expiresAt, err := parseTime(field(record, "expires_at"))
+			if err != nil {
+				return nil, err
+			}
+			return a.shortener.ShortenURL(&models.ShortenRequest{
+				URL:          result.URL,
+				CustomCode:   field(record, "custom_code"),
+				ExpiresAt:    expiresAt,
+				RedirectType: field(record, "redirect_type"),
+			}, "", userID)
+		}()
+		if err != nil {
+			result.Error = err.Error()
+			summary.Failed++
+		} else {
+			result.ShortCode, result.ShortURL = response.ShortCode, response.ShortURL
+			summary.Created++
+		}
+		summary.Results = append(summary.Results, result)
+	}
+
+	if summary.Failed > 0 {
+		return summary, fmt.Errorf("%d of %d rows failed", summary.Failed, len(summary.Results))
+	}
+	return summary, nil
+}
+
+type whoisResult struct {
+	*models.URLMapping
+	PasswordProtected bool   `json:"password_protected,omitempty"`
+	OwnerEmail        string `json:"owner_email,omitempty"`
+}
+
+func whois(a *app, args []string) (interface{}, error) {
+	positional, err := parseFlags(flag.NewFlagSet("whois", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	mapping, err := a.shortener.LookupURL(positional[0])
+	if err != nil {
+		return nil, err
+	}
+	result := whoisResult{URLMapping: mapping, PasswordProtected: mapping.IsPasswordProtected()}
+	if mapping.UserID != nil {
+		if owner, err := a.users.GetUserByID(*mapping.UserID); err == nil {
+			result.OwnerEmail = owner.Email
+		}
+	}
+	return result, nil
+}
+
+func purgeCache(a *app, args []string) (interface{}, error) {
+	positional, err := parseFlags(flag.NewFlagSet("purge-cache", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.shortener.PurgeCache(positional[0]); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"short_code": positional[0], "purged": true}, nil
+}
+
+func resetPassword(a *app, args []string) (interface{}, error) {
+	fs := flag.NewFlagSet("reset-password", flag.ContinueOnError)
+	userRef := fs.String("user", "", "user ID or email")
+	password := fs.String("password", "", "new password; a random one is generated and printed when empty")
+	if _, err := parseFlags(fs, args, 0); err != nil {
+		return nil, err
+	}
+
+	user, err := a.findUser(*userRef)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]interface{}{"user_id": user.ID, "email": user.Email}
+	newPassword := *password
+	if newPassword == "" {
+		newPassword, err = generatePassword()
+		if err != nil {
+			return nil, err
+		}
+		result["password"] = newPassword
+	} else if len(newPassword) < 8 {
+		return nil, fmt.Errorf("%w: password must be at least 8 characters long", errUsage)
+	}
+
+	if err := a.users.SetPassword(user.ID, newPassword); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func generatePassword() (string, error) {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate password: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func assignRole(a *app, args []string) (interface{}, error) {
+	fs := flag.NewFlagSet("assign-role", flag.ContinueOnError)
+	userRef := fs.String("user", "", "user ID or email")
+	roleName := fs.String("role", "", "role name, e.g. admin")
+	expires := fs.String("expires", "", "when the assignment lapses")
+	if _, err := parseFlags(fs, args, 0); err != nil {
+		return nil, err
+	}
+	if *roleName == "" {
+		return nil, fmt.Errorf("%w: assign-role needs -role", errUsage)
+	}
+
+	expiresAt, err := parseTime(*expires)
+	if err != nil {
+		return nil, err
+	}
+	user, err := a.findUser(*userRef)
+	if err != nil {
+		return nil, err
+	}
+	role, err := a.rbac.GetRoleByName(*roleName)
+	if err != nil {
+		return nil, fmt.Errorf("role %q: %w", *roleName, err)
+	}
+
+	// Assigned by the system rather than a user account
+	if err := a.rbac.AssignRoleToUser(&models.AssignRoleRequest{UserID: user.ID, RoleID: role.ID, ExpiresAt: expiresAt}, 0); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"user_id": user.ID, "email": user.Email, "role": role.Name, "expires_at": expiresAt}, nil
+}
+
+func requireTwoFactor(a *app, args []string) (interface{}, error) {
+	fs := flag.NewFlagSet("require-2fa", flag.ContinueOnError)
+	userRef := fs.String("user", "", "user ID or email")
+	off := fs.Bool("off", false, "stop requiring two-factor")
+	if _, err := parseFlags(fs, args, 0); err != nil {
+		return nil, err
+	}
+
+	user, err := a.findUser(*userRef)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.twoFactor.SetRequired(user.ID, !*off); err != nil {
+		return nil, err
+	}
+
+	status, err := a.twoFactor.Status(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"user_id": user.ID, "email": user.Email, "two_factor": status}, nil
+}
+
+func resetTwoFactor(a *app, args []string) (interface{}, error) {
+	fs := flag.NewFlagSet("reset-2fa", flag.ContinueOnError)
+	userRef := fs.String("user", "", "user ID or email")
+	if _, err := parseFlags(fs, args, 0); err != nil {
+		return nil, err
+	}
+
+	user, err := a.findUser(*userRef)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.twoFactor.Reset(user.ID); err != nil {
+		return nil, err
+	}
+
+	status, err := a.twoFactor.Status(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"user_id": user.ID, "email": user.Email, "two_factor": status}, nil
+}
+
+func printAnalytics(a *app, args []string) (interface{}, error) {
+	fs := flag.NewFlagSet("analytics", flag.ContinueOnError)
+	days := fs.Int("days", 30, "number of days to report")
+	includeBots := fs.Bool("bots", false, "include bot traffic")
+	positional, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if *days < 1 {
+		return nil, fmt.Errorf("%w: -days must be at least 1", errUsage)
+	}
+
+	analytics, err := a.analytics.GetAnalytics(positional[0], *days, *includeBots)
+	if err != nil {
+		return nil, err
+	}
+	return analytics, nil
+}
+
+func blockDestination(a *app, args []string) (interface{}, error) {
+	fs := flag.NewFlagSet("block", flag.ContinueOnError)
+	kind := fs.String("kind", "", "domain, to block a host and its subdomains, or url, for a URL pattern")
+	reason := fs.String("reason", "", "shown to visitors of links this quarantines")
+	positional, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	// Added by the system rather than a user account
+	entry := &models.BlocklistEntry{Kind: *kind, Pattern: positional[0], Reason: *reason}
+	if err := a.safety.AddBlocklistEntry(entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func unblockDestination(a *app, args []string) (interface{}, error) {
+	positional, err := parseFlags(flag.NewFlagSet("unblock", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return nil, err
+	}
+	id, err := strconv.ParseInt(positional[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid blocklist entry ID %q", errUsage, positional[0])
+	}
+
+	if err := a.safety.RemoveBlocklistEntry(id); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"id": id, "removed": true}, nil
+}
+
+func listBlocklist(a *app, args []string) (interface{}, error) {
+	if _, err := parseFlags(flag.NewFlagSet("blocklist", flag.ContinueOnError), args, 0); err != nil {
+		return nil, err
+	}
+	return a.safety.ListBlocklist()
+}
+
+func quarantineLink(a *app, args []string) (interface{}, error) {
+	fs := flag.NewFlagSet("quarantine", flag.ContinueOnError)
+	reason := fs.String("reason", "", "shown to visitors on the warning page")
+	positional, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.safety.QuarantineURL(positional[0], *reason); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"short_code": positional[0], "quarantined": true}, nil
+}
+
+func releaseLink(a *app, args []string) (interface{}, error) {
+	positional, err := parseFlags(flag.NewFlagSet("release", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.safety.ReleaseURL(positional[0]); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"short_code": positional[0], "quarantined": false}, nil
+}
+
+func recheckLinks(a *app, args []string) (interface{}, error) {
+	if _, err := parseFlags(flag.NewFlagSet("recheck", flag.ContinueOnError), args, 0); err != nil {
+		return nil, err
+	}
+	return a.safety.RecheckLinks(context.Background())
+}
@@ -13,6 +13,14 @@ type Config struct {
 	ServerHost string
 	BaseURL    string
 	Environment string
+	TrustedProxies string // comma-separated proxy addresses or CIDRs whose X-Forwarded-For is honoured
+
+	// Storage backend: "postgres" (with Redis), "sqlite" or "memory"
+	StorageBackend string
+
+	// SQLite Configuration
+	SQLitePath   string
+	URLCacheSize int // keys held by the in-process LRU cache used instead of Redis
 
 	// Database Configuration
 	DBHost        string
@@ -21,6 +29,7 @@ type Config struct {
 	DBPassword    string
 	DBName        string
 	DBMaxConns    int
+	MigrateOnStartup bool // apply pending schema migrations when the server starts
 
 	// Redis Configuration
 	RedisHost     string
@@ -31,6 +40,36 @@ type Config struct {
 	// Snowflake Configuration
 	NodeID int64
 
+	// Click Ingestion Configuration
+	ClickQueueSize       int
+	ClickWorkers         int
+	ClickBatchSize       int
+	ClickFlushIntervalMs int
+	ClickStreamEnabled   bool
+
+	// Bulk Import Configuration
+	BulkImportWorkers   int
+	BulkImportBatchSize int
+	BulkImportMaxRows   int
+
+	// Link Metadata Configuration
+	MetadataFetchEnabled   bool
+	MetadataWorkers        int
+	MetadataFetchTimeoutMs int
+	MetadataMaxBodyBytes   int64
+	MetadataMaxRedirects   int
+
+	// Link Safety Configuration
+	LinkSafetyEnabled          bool
+	SafetyRecheckIntervalHours int
+	SafetyRecheckBatchSize     int
+	SafeBrowsingAPIKey         string
+	SafeBrowsingURL            string
+
+	// GeoIP Configuration (MaxMind databases used by country redirect rules)
+	GeoIPCityDBPath    string
+	GeoIPCountryDBPath string
+
 	// Logging
 	LogLevel  string
 	LogFormat string
@@ -41,10 +80,25 @@ type Config struct {
 	GoogleRedirectURL  string
 
 	// JWT Configuration
-	JWTSecret string
-	JWTIssuer string
+	JWTSecret      string // HMAC secret, used when no signing keys are configured
+	JWTIssuer      string
+	JWTSigningKeys string // comma-separated kid=path[@activation] private key files
+
+	// HMAC secret for the cookies that unlock password-protected links
+	LinkPasswordSecret string
+
+	// Two-Factor Authentication Configuration
+	TwoFactorIssuer            string // names the service in authenticator apps
+	TwoFactorRequireTeamOwners bool   // owners of active teams must use two-factor
 }
 
+// Supported storage backends
+const (
+	StorageBackendPostgres = "postgres"
+	StorageBackendSQLite   = "sqlite"
+	StorageBackendMemory   = "memory"
+)
+
 func LoadConfig() (*Config, error) {
 	// Load .env file if it exists
 	godotenv.Load()
@@ -54,6 +108,12 @@ func LoadConfig() (*Config, error) {
 		ServerHost:  getEnv("SERVER_HOST", "localhost"),
 		BaseURL:     getEnv("BASE_URL", "http://localhost:8080"),
 		Environment: getEnv("ENVIRONMENT", "development"),
+		TrustedProxies: getEnv("TRUSTED_PROXIES", ""),
+
+		StorageBackend: getEnv("STORAGE_BACKEND", StorageBackendPostgres),
+
+		SQLitePath:   getEnv("SQLITE_PATH", "data/urlshortener.db"),
+		URLCacheSize: getEnvAsInt("URL_CACHE_SIZE", 10000),
 
 		DBHost:     getEnv("DB_HOST", "localhost"),
 		DBPort:     getEnv("DB_PORT", "5432"),
@@ -61,6 +121,7 @@ func LoadConfig() (*Config, error) {
 		DBPassword: getEnv("DB_PASSWORD", "password"),
 		DBName:     getEnv("DB_NAME", "urlshortener_db"),
 		DBMaxConns: getEnvAsInt("DB_MAX_CONNECTIONS", 25),
+		MigrateOnStartup: getEnvAsBool("MIGRATE_ON_STARTUP", false),
 
 		RedisHost:     getEnv("REDIS_HOST", "localhost"),
 		RedisPort:     getEnv("REDIS_PORT", "6379"),
@@ -69,6 +130,31 @@ func LoadConfig() (*Config, error) {
 
 		NodeID: getEnvAsInt64("NODE_ID", 1),
 
+		ClickQueueSize:       getEnvAsInt("CLICK_QUEUE_SIZE", 10000),
+		ClickWorkers:         getEnvAsInt("CLICK_WORKERS", 4),
+		ClickBatchSize:       getEnvAsInt("CLICK_BATCH_SIZE", 500),
+		ClickFlushIntervalMs: getEnvAsInt("CLICK_FLUSH_INTERVAL_MS", 1000),
+		ClickStreamEnabled:   getEnvAsBool("CLICK_STREAM_ENABLED", true),
+
+		BulkImportWorkers:   getEnvAsInt("BULK_IMPORT_WORKERS", 2),
+		BulkImportBatchSize: getEnvAsInt("BULK_IMPORT_BATCH_SIZE", 200),
+		BulkImportMaxRows:   getEnvAsInt("BULK_IMPORT_MAX_ROWS", 50000),
+
+		MetadataFetchEnabled:   getEnvAsBool("METADATA_FETCH_ENABLED", true),
+		MetadataWorkers:        getEnvAsInt("METADATA_WORKERS", 2),
+		MetadataFetchTimeoutMs: getEnvAsInt("METADATA_FETCH_TIMEOUT_MS", 5000),
+		MetadataMaxBodyBytes:   getEnvAsInt64("METADATA_MAX_BODY_BYTES", 524288),
+		MetadataMaxRedirects:   getEnvAsInt("METADATA_MAX_REDIRECTS", 5),
+
+		LinkSafetyEnabled:          getEnvAsBool("LINK_SAFETY_ENABLED", true),
+		SafetyRecheckIntervalHours: getEnvAsInt("SAFETY_RECHECK_INTERVAL_HOURS", 24),
+		SafetyRecheckBatchSize:     getEnvAsInt("SAFETY_RECHECK_BATCH_SIZE", 500),
+		SafeBrowsingAPIKey:         getEnv("SAFE_BROWSING_API_KEY", ""),
+		SafeBrowsingURL:            getEnv("SAFE_BROWSING_URL", ""),
+
+		GeoIPCityDBPath:    getEnv("GEOIP_CITY_DB_PATH", ""),
+		GeoIPCountryDBPath: getEnv("GEOIP_COUNTRY_DB_PATH", ""),
+
 		LogLevel:  getEnv("LOG_LEVEL", "info"),
 		LogFormat: getEnv("LOG_FORMAT", "json"),
 
@@ -76,8 +162,14 @@ func LoadConfig() (*Config, error) {
 		GoogleClientSecret: getEnv("GOOGLE_CLIENT_SECRET", ""),
 		GoogleRedirectURL:  getEnv("GOOGLE_REDIRECT_URL", ""),
 
-		JWTSecret: getEnv("JWT_SECRET", "your-secret-key-change-this-in-production"),
-		JWTIssuer: getEnv("JWT_ISSUER", "urlshortener"),
+		JWTSecret:      getEnv("JWT_SECRET", ""),
+		JWTIssuer:      getEnv("JWT_ISSUER", "urlshortener"),
+		JWTSigningKeys: getEnv("JWT_SIGNING_KEYS", ""),
+
+		LinkPasswordSecret: getEnv("LINK_PASSWORD_SECRET", ""),
+
+		TwoFactorIssuer:            getEnv("TWO_FACTOR_ISSUER", "URL Shortener"),
+		TwoFactorRequireTeamOwners: getEnvAsBool("TWO_FACTOR_REQUIRE_TEAM_OWNERS", false),
 	}
 
 	return config, nil
@@ -98,6 +190,14 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvAsBool(key string, defaultValue bool) bool {
+	valueStr := getEnv(key, "")
+	if value, err := strconv.ParseBool(valueStr); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
 func getEnvAsInt64(key string, defaultValue int64) int64 {
 	valueStr := getEnv(key, "")
 	if value, err := strconv.ParseInt(valueStr, 10, 64); err == nil {
@@ -158,8 +158,8 @@ setup_shared_database() {
         echo "CREATE DATABASE urlshortener_db OWNER urlshortener;"
         echo "GRANT ALL PRIVILEGES ON DATABASE urlshortener_db TO urlshortener;"
         echo ""
-        echo "Then run the schema initialization:"
-        echo "docker exec -i <odoo_postgres_container> psql -U urlshortener -d urlshortener_db < $PROJECT_DIR/configs/database.sql"
+        echo "The schema is created by the app container on startup (MIGRATE_ON_STARTUP=true),"
+        echo "or manually with: docker exec <app_container> ./migrate up"
         echo ""
         read -p "Press Enter after setting up the database..."
     fi
@@ -57,22 +57,18 @@ export default function APIKeyManager() {
   const [formData, setFormData] = useState<CreateAPIKeyRequest>({
     name: '',
     description: '',
-    permissions: ['url.create', 'url.read', 'url.update', 'url.delete', 'analytics.read'],
+    permissions: ['urls:read', 'urls:write', 'analytics:read'],
     rate_limit: 1000,
     expires_at: '',
   });
   const [selectedStats, setSelectedStats] = useState<APIKeyStats | null>(null);
 
   const availablePermissions = [
-    { id: 'url.create', name: 'Create URLs', description: 'Create short URLs' },
-    { id: 'url.read', name: 'Read URLs', description: 'View URL information' },
-    { id: 'url.update', name: 'Update URLs', description: 'Modify existing URLs' },
-    { id: 'url.delete', name: 'Delete URLs', description: 'Remove URLs' },
-    { id: 'analytics.read', name: 'Read Analytics', description: 'View click analytics' },
-    { id: 'domain.create', name: 'Create Custom Domains', description: 'Add custom domains' },
-    { id: 'domain.read', name: 'Read Custom Domains', description: 'View custom domains' },
-    { id: 'domain.update', name: 'Update Custom Domains', description: 'Modify custom domains' },
-    { id: 'domain.delete', name: 'Delete Custom Domains', description: 'Remove custom domains' },
+    { id: 'urls:read', name: 'Read URLs', description: 'List and search your short URLs' },
+    { id: 'urls:write', name: 'Write URLs', description: 'Create, update and delete short URLs' },
+    { id: 'analytics:read', name: 'Read Analytics', description: 'View click analytics' },
+    { id: 'domains:read', name: 'Read Custom Domains', description: 'View custom domains' },
+    { id: 'domains:write', name: 'Write Custom Domains', description: 'Add, modify and remove custom domains' },
   ];
 
   useEffect(() => {
@@ -130,7 +126,7 @@ export default function APIKeyManager() {
         setFormData({
           name: '',
           description: '',
-          permissions: ['url.create', 'url.read', 'url.update', 'url.delete', 'analytics.read'],
+          permissions: ['urls:read', 'urls:write', 'analytics:read'],
           rate_limit: 1000,
           expires_at: '',
         });
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/URLshorter/url-shortener/internal/middleware"
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/URLshorter/url-shortener/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHandler handles API key management endpoints
+type APIKeyHandler struct {
+	apiKeyService *services.APIKeyService
+}
+
+// NewAPIKeyHandler creates a new API key handler
+func NewAPIKeyHandler(apiKeyService *services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+	}
+}
+
+// CreateAPIKey creates a new API key. The plain key is only returned here.
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "Authentication required",
+		})
+		return
+	}
+
+	var req models.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Name is required",
+		})
+		return
+	}
+
+	response, err := h.apiKeyService.CreateAPIKey(userID, &req)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// ListAPIKeys lists API keys for the authenticated user
+func (h *APIKeyHandler) ListAPIKeys(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "Authentication required",
+		})
+		return
+	}
+
+	query := &models.ListAPIKeysQuery{
+		SortBy:    c.DefaultQuery("sort_by", "created_at"),
+		SortOrder: c.DefaultQuery("sort_order", "desc"),
+		Limit:     50,
+	}
+
+	if active, err := strconv.ParseBool(c.Query("active")); err == nil {
+		query.Active = &active
+	}
+	if expired, err := strconv.ParseBool(c.Query("expired")); err == nil {
+		query.Expired = &expired
+	}
+	if search := c.Query("search"); search != "" {
+		query.Search = &search
+	}
+	if limit, err := strconv.Atoi(c.Query("limit")); err == nil && limit > 0 && limit <= 100 {
+		query.Limit = limit
+	}
+	if offset, err := strconv.Atoi(c.Query("offset")); err == nil && offset >= 0 {
+		query.Offset = offset
+	}
+
+	result, err := h.apiKeyService.ListAPIKeys(userID, query)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetAPIKey gets a specific API key
+func (h *APIKeyHandler) GetAPIKey(c *gin.Context) {
+	userID, id, ok := h.keyRequest(c)
+	if !ok {
+		return
+	}
+
+	apiKey, err := h.apiKeyService.GetAPIKey(id, userID)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"api_key": apiKey})
+}
+
+// RevokeAPIKey deactivates an API key; requests made with it are rejected
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	userID, id, ok := h.keyRequest(c)
+	if !ok {
+		return
+	}
+
+	if err := h.apiKeyService.RevokeAPIKey(id, userID); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked successfully"})
+}
+
+// DeleteAPIKey permanently deletes an API key along with its usage
+func (h *APIKeyHandler) DeleteAPIKey(c *gin.Context) {
+	userID, id, ok := h.keyRequest(c)
+	if !ok {
+		return
+	}
+
+	if err := h.apiKeyService.DeleteAPIKey(id, userID); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key deleted successfully"})
+}
+
+// GetAPIKeyStats gets usage statistics for an API key
+func (h *APIKeyHandler) GetAPIKeyStats(c *gin.Context) {
+	userID, id, ok := h.keyRequest(c)
+	if !ok {
+		return
+	}
+
+	stats, err := h.apiKeyService.GetAPIKeyStats(id, userID)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"stats": stats})
+}
+
+// keyRequest reads the caller and the key ID from the path, responding
+// itself when either is missing
+func (h *APIKeyHandler) keyRequest(c *gin.Context) (int64, int64, bool) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "Authentication required",
+		})
+		return 0, 0, false
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid API key ID",
+		})
+		return 0, 0, false
+	}
+
+	return userID, id, true
+}
+
+// respondError maps API key service errors to responses
+func (h *APIKeyHandler) respondError(c *gin.Context, err error) {
+	switch err {
+	case services.ErrAPIKeyNotFound:
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: err.Error(),
+		})
+	case services.ErrAPIAccessNotInPlan:
+		// Same shape as the billing feature gate
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":            "Feature not available in your current plan",
+			"feature":          "api_access",
+			"upgrade_required": true,
+		})
+	case services.ErrInvalidAPIKeyScope:
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_scope",
+			Message: err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: err.Error(),
+		})
+	}
+}
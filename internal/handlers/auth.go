@@ -72,25 +72,22 @@ func (h *AuthHandlers) Login(c *gin.Context) {
 		return
 	}
 
-	middleware.LogInfo(c, "Login attempt")
-	c.JSON(http.StatusOK, gin.H{
-		"access_token":  "dummy-access-token",
-		"refresh_token": "dummy-refresh-token",
-		"expires_in":    3600,
-		"user": gin.H{
-			"id":             1,
-			"name":          "Test User",
-			"email":         req.Email,
-			"phone":         "",
-			"phone_verified": false,
-			"email_verified": true,
-			"provider":      "local",
-			"avatar_url":    "",
-			"account_type":  "user",
-			"is_active":     true,
-			"created_at":    "2025-01-01T00:00:00Z",
-		},
-	})
+	response, err := h.authService.Login(&req, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		switch err {
+		case services.ErrInvalidCredentials:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+		case services.ErrAccountNotActive:
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account has been suspended"})
+		default:
+			middleware.LogError(c, err, "Login failed")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Login failed"})
+		}
+		return
+	}
+
+	middleware.LogInfo(c, "User logged in")
+	c.JSON(http.StatusOK, response)
 }
 
 // RefreshToken handles token refresh
@@ -107,11 +104,92 @@ func (h *AuthHandlers) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	middleware.LogInfo(c, "Token refresh attempt")
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Token refresh successful",
-		"token":   "new-dummy-token", // Temporary placeholder
-	})
+	response, err := h.authService.RefreshTokens(&req, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		if err == services.ErrRefreshTokenReused {
+			middleware.LogInfo(c, "Refresh token reused, session revoked")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token was already used; please log in again"})
+			return
+		}
+		if err == services.ErrTwoFactorSetupRequired {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Two-factor authentication must be set up; please log in again"})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	}
+
+	middleware.LogInfo(c, "Token refreshed")
+	c.JSON(http.StatusOK, response)
+}
+
+// CompleteTwoFactorLogin handles the second step of a login challenged for
+// an authenticator or recovery code
+func (h *AuthHandlers) CompleteTwoFactorLogin(c *gin.Context) {
+	var req models.TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	// Validate request
+	if err := h.validator.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.authService.CompleteTwoFactorLogin(&req, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		switch err {
+		case services.ErrInvalidTwoFactorCode:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code"})
+		case services.ErrTwoFactorChallengeInvalid, services.ErrTwoFactorNotEnrolling:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case services.ErrTwoFactorLocked:
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		case services.ErrAccountNotActive:
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account has been suspended"})
+		default:
+			middleware.LogError(c, err, "Two-factor login failed")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Login failed"})
+		}
+		return
+	}
+
+	middleware.LogInfo(c, "User logged in with two-factor")
+	c.JSON(http.StatusOK, response)
+}
+
+// BeginTwoFactorLoginSetup starts authenticator enrolment for a user who
+// must set up two-factor before their login can complete
+func (h *AuthHandlers) BeginTwoFactorLoginSetup(c *gin.Context) {
+	var req models.TwoFactorSetupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	// Validate request
+	if err := h.validator.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	enrollment, err := h.authService.BeginTwoFactorLoginSetup(&req)
+	if err != nil {
+		switch err {
+		case services.ErrTwoFactorChallengeInvalid:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case services.ErrTwoFactorAlreadyEnabled:
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			middleware.LogError(c, err, "Two-factor setup failed")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Two-factor setup failed"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
 }
 
 // SendOTP handles OTP sending
@@ -236,21 +314,47 @@ func (h *AuthHandlers) ChangePassword(c *gin.Context) {
 	})
 }
 
-// Logout handles user logout (invalidate session)
+// Logout handles user logout, revoking the caller's token and session
 func (h *AuthHandlers) Logout(c *gin.Context) {
-	_, exists := middleware.GetUserID(c)
-	if !exists {
+	value, exists := c.Get("claims")
+	claims, ok := value.(*services.Claims)
+	if !exists || !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
-	// TODO: Implement session invalidation
+	if err := h.authService.Logout(claims, c.ClientIP(), c.Request.UserAgent()); err != nil {
+		middleware.LogError(c, err, "Logout failed")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Logout failed"})
+		return
+	}
+
 	middleware.LogInfo(c, "User logged out")
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Logged out successfully",
 	})
 }
 
+// LogoutAll logs the user out of every device by revoking all their tokens
+func (h *AuthHandlers) LogoutAll(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := h.authService.LogoutAllDevices(userID, c.ClientIP(), c.Request.UserAgent()); err != nil {
+		middleware.LogError(c, err, "Logout from all devices failed")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Logout failed"})
+		return
+	}
+
+	middleware.LogInfo(c, "User logged out of all devices")
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Logged out of all devices",
+	})
+}
+
 // ResendOTP handles OTP resending with user ID from request
 func (h *AuthHandlers) ResendOTP(c *gin.Context) {
 	var req models.ResendOTPRequest
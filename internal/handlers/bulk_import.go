@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/URLshorter/url-shortener/internal/middleware"
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/URLshorter/url-shortener/internal/services"
+	"github.com/URLshorter/url-shortener/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportFileSize caps uploaded import files
+const maxImportFileSize = 32 << 20
+
+// BulkImportHandler handles bulk link import and export endpoints
+type BulkImportHandler struct {
+	bulkImportService *services.BulkImportService
+}
+
+// NewBulkImportHandler creates a new bulk import handler
+func NewBulkImportHandler(bulkImportService *services.BulkImportService) *BulkImportHandler {
+	return &BulkImportHandler{
+		bulkImportService: bulkImportService,
+	}
+}
+
+// CreateImport accepts a CSV or NDJSON file, either as the "file" field of a
+// multipart form or as the raw request body, and queues it for import
+func (h *BulkImportHandler) CreateImport(c *gin.Context) {
+	userID, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	// Check the plan before reading what may be a large upload
+	if plan, err := h.bulkImportService.CheckAccess(userID); err != nil {
+		h.respondImportError(c, plan, err)
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportFileSize)
+
+	var data []byte
+	var filename string
+	var err error
+	if file, header, formErr := c.Request.FormFile("file"); formErr == nil {
+		defer file.Close()
+		filename = header.Filename
+		data, err = io.ReadAll(file)
+	} else if formErr == http.ErrNotMultipart {
+		data, err = io.ReadAll(c.Request.Body)
+	} else {
+		err = formErr
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: fmt.Sprintf("could not read import file (at most %d MB): %v", maxImportFileSize>>20, err),
+		})
+		return
+	}
+
+	format := importFormat(c, filename)
+	job, created, err := h.bulkImportService.Submit(userID, format, data)
+	if err != nil {
+		h.respondImportError(c, "", err)
+		return
+	}
+
+	statusCode := http.StatusAccepted
+	if !created {
+		statusCode = http.StatusOK
+	}
+	c.JSON(statusCode, job)
+}
+
+// GetImport reports an import job's progress
+func (h *BulkImportHandler) GetImport(c *gin.Context) {
+	userID, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	jobID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid import ID",
+		})
+		return
+	}
+
+	job, err := h.bulkImportService.GetJob(userID, jobID)
+	if err != nil {
+		h.respondImportError(c, "", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// GetImportRows lists the per-row results of an import job
+func (h *BulkImportHandler) GetImportRows(c *gin.Context) {
+	userID, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	jobID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid import ID",
+		})
+		return
+	}
+
+	after, _ := strconv.Atoi(c.DefaultQuery("after", "0"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit < 1 || limit > 1000 {
+		limit = 100
+	}
+
+	rows, err := h.bulkImportService.ListRows(userID, jobID, c.Query("status"), after, limit)
+	if err != nil {
+		h.respondImportError(c, "", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rows)
+}
+
+// ExportURLs streams all of the user's links as CSV or NDJSON
+func (h *BulkImportHandler) ExportURLs(c *gin.Context) {
+	userID, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	format := c.DefaultQuery("format", models.BulkFormatCSV)
+	contentType := "text/csv; charset=utf-8"
+	switch format {
+	case models.BulkFormatCSV:
+	case models.BulkFormatNDJSON:
+		contentType = "application/x-ndjson"
+	default:
+		h.respondImportError(c, "", services.ErrInvalidImportFormat)
+		return
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="links.%s"`, format))
+	c.Status(http.StatusOK)
+
+	// Headers are already sent, so a failure can only cut the stream short
+	if err := h.bulkImportService.Export(userID, format, c.Writer); err != nil {
+		c.Error(err)
+	}
+}
+
+// respondImportError maps bulk import errors to responses
+func (h *BulkImportHandler) respondImportError(c *gin.Context, plan string, err error) {
+	if err == services.ErrBulkImportNotInPlan {
+		// Same shape as the billing feature gate
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":            "Feature not available in your current plan",
+			"feature":          "bulk_import",
+			"current_plan":     plan,
+			"upgrade_required": true,
+		})
+		return
+	}
+
+	if err == storage.ErrImportJobNotFound {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "import_not_found",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if _, ok := err.(*services.ServiceError); ok {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_import",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		Error:   "internal_error",
+		Message: err.Error(),
+	})
+}
+
+// importFormat picks the upload's format from the format query parameter,
+// falling back to its file extension and then its content type
+func importFormat(c *gin.Context, filename string) string {
+	if format := c.Query("format"); format != "" {
+		return strings.ToLower(format)
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return models.BulkFormatCSV
+	case ".ndjson", ".jsonl":
+		return models.BulkFormatNDJSON
+	}
+
+	switch c.ContentType() {
+	case "application/x-ndjson", "application/jsonl", "application/json":
+		return models.BulkFormatNDJSON
+	}
+	return models.BulkFormatCSV
+}
+
+// requireUser returns the authenticated caller, responding 401 if there is none
+func (h *BulkImportHandler) requireUser(c *gin.Context) (int64, bool) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "Authentication required",
+		})
+		return 0, false
+	}
+	return userID, true
+}
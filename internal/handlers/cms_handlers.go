@@ -10,14 +10,12 @@ import (
 )
 
 type CMSHandlers struct {
-	cmsService    *services.CMSService
-	apiKeyService *services.APIKeyService
+	cmsService *services.CMSService
 }
 
-func NewCMSHandlers(cmsService *services.CMSService, apiKeyService *services.APIKeyService) *CMSHandlers {
+func NewCMSHandlers(cmsService *services.CMSService) *CMSHandlers {
 	return &CMSHandlers{
-		cmsService:    cmsService,
-		apiKeyService: apiKeyService,
+		cmsService: cmsService,
 	}
 }
 
@@ -253,198 +251,6 @@ func (h *CMSHandlers) GetPageAnalytics(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"analytics": analytics})
 }
 
-// API Key Handlers
-
-// CreateAPIKey creates a new API key
-func (h *CMSHandlers) CreateAPIKey(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	var req models.CreateAPIKeyRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
-		return
-	}
-
-	// Validate required fields
-	if req.Name == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required"})
-		return
-	}
-
-	response, err := h.apiKeyService.CreateAPIKey(userID.(int64), &req)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key", "details": err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusCreated, response)
-}
-
-// ListAPIKeys lists API keys for the authenticated user
-func (h *CMSHandlers) ListAPIKeys(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	query := &models.ListAPIKeysQuery{
-		SortBy:    "created_at",
-		SortOrder: "desc",
-		Limit:     50,
-		Offset:    0,
-	}
-
-	// Parse query parameters
-	if active := c.Query("active"); active != "" {
-		if active == "true" {
-			query.Active = &[]bool{true}[0]
-		} else if active == "false" {
-			query.Active = &[]bool{false}[0]
-		}
-	}
-
-	if expired := c.Query("expired"); expired != "" {
-		if expired == "true" {
-			query.Expired = &[]bool{true}[0]
-		} else if expired == "false" {
-			query.Expired = &[]bool{false}[0]
-		}
-	}
-
-	if search := c.Query("search"); search != "" {
-		query.Search = &search
-	}
-
-	if sortBy := c.Query("sort_by"); sortBy != "" {
-		query.SortBy = sortBy
-	}
-
-	if sortOrder := c.Query("sort_order"); sortOrder != "" {
-		query.SortOrder = sortOrder
-	}
-
-	if limitStr := c.Query("limit"); limitStr != "" {
-		if limit, err := strconv.Atoi(limitStr); err == nil && limit > 0 && limit <= 100 {
-			query.Limit = limit
-		}
-	}
-
-	if offsetStr := c.Query("offset"); offsetStr != "" {
-		if offset, err := strconv.Atoi(offsetStr); err == nil && offset >= 0 {
-			query.Offset = offset
-		}
-	}
-
-	result, err := h.apiKeyService.ListAPIKeys(userID.(int64), query)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list API keys", "details": err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusOK, result)
-}
-
-// GetAPIKey gets a specific API key
-func (h *CMSHandlers) GetAPIKey(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	idStr := c.Param("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
-		return
-	}
-
-	apiKey, err := h.apiKeyService.GetAPIKey(id, userID.(int64))
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"api_key": apiKey})
-}
-
-// RevokeAPIKey revokes an API key
-func (h *CMSHandlers) RevokeAPIKey(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	idStr := c.Param("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
-		return
-	}
-
-	err = h.apiKeyService.RevokeAPIKey(id, userID.(int64))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key", "details": err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "API key revoked successfully"})
-}
-
-// DeleteAPIKey permanently deletes an API key
-func (h *CMSHandlers) DeleteAPIKey(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	idStr := c.Param("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
-		return
-	}
-
-	err = h.apiKeyService.DeleteAPIKey(id, userID.(int64))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete API key", "details": err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "API key deleted successfully"})
-}
-
-// GetAPIKeyStats gets usage statistics for an API key
-func (h *CMSHandlers) GetAPIKeyStats(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	idStr := c.Param("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
-		return
-	}
-
-	stats, err := h.apiKeyService.GetAPIKeyStats(id, userID.(int64))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get API key stats", "details": err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"stats": stats})
-}
-
 // PublicPageHandler serves public static pages
 func (h *CMSHandlers) PublicPageHandler(c *gin.Context) {
 	slug := c.Param("slug")
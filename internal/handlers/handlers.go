@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/URLshorter/url-shortener/internal/middleware"
 	"github.com/URLshorter/url-shortener/internal/models"
@@ -28,11 +29,16 @@ type Handler struct {
 	BillingHandlers        *BillingHandler
 	// AdvancedAnalyticsHandlers *AdvancedAnalyticsHandler  // Temporarily disabled
 	AttributionHandlers    *AttributionHandler
+	BulkImportHandlers     *BulkImportHandler
+	QRCodeHandlers         *QRCodeHandler
+	APIKeyHandlers         *APIKeyHandler
+	JWKSHandlers           *JWKSHandler
+	TwoFactorHandlers      *TwoFactorHandler
 }
 
 // NewHandler creates a new handler instance
 func NewHandler(shortenerService *services.ShortenerService, analyticsService *services.AnalyticsService, advancedAnalyticsService *services.AdvancedAnalyticsService, conversionService *services.ConversionTrackingService, abTestService *services.ABTestingService, realtimeService *services.RealtimeAnalyticsService, attributionService *services.AttributionService, authHandlers *AuthHandlers, analyticsHandlers *AnalyticsHandlers, db *storage.PostgresStorage) *Handler {
-	return &Handler{
+	handler := &Handler{
 		shortenerService:       shortenerService,
 		analyticsService:       analyticsService,
 		advancedAnalyticsService: advancedAnalyticsService,
@@ -42,13 +48,25 @@ func NewHandler(shortenerService *services.ShortenerService, analyticsService *s
 		attributionService:     attributionService,
 		AuthHandlers:           authHandlers,
 		AnalyticsHandlers:      analyticsHandlers,
-		ConversionHandlers:     NewConversionTrackingHandler(conversionService),
-		ABTestHandlers:         NewABTestingHandler(abTestService),
-		RealtimeHandlers:       NewRealtimeAnalyticsHandler(realtimeService),
 		BillingHandlers:        NewBillingHandler(nil), // Will need to fix this properly
 		// AdvancedAnalyticsHandlers: NewAdvancedAnalyticsHandler(advancedAnalyticsService), // Temporarily disabled
-		AttributionHandlers:    NewAttributionHandler(attributionService),
 	}
+
+	// Services that need Postgres are nil on other storage backends, and
+	// their routes are left unmounted
+	if conversionService != nil {
+		handler.ConversionHandlers = NewConversionTrackingHandler(conversionService)
+	}
+	if abTestService != nil {
+		handler.ABTestHandlers = NewABTestingHandler(abTestService)
+	}
+	if realtimeService != nil {
+		handler.RealtimeHandlers = NewRealtimeAnalyticsHandler(realtimeService)
+	}
+	if attributionService != nil {
+		handler.AttributionHandlers = NewAttributionHandler(attributionService)
+	}
+	return handler
 }
 
 // HealthCheck handles health check requests
@@ -57,6 +75,9 @@ func (h *Handler) HealthCheck(c *gin.Context) {
 		"status":    "healthy",
 		"service":   "url-shortener",
 		"timestamp": gin.H{},
+		"click_ingestion": h.shortenerService.ClickIngestionStats(),
+		"click_stream":    h.shortenerService.ClickStreamStats(),
+		"click_effects":   h.shortenerService.ClickEffectStats(),
 	})
 }
 
@@ -99,6 +120,30 @@ func (h *Handler) ShortenURL(c *gin.Context) {
 		case services.ErrReservedCustomCode, services.ErrCustomCodeAlreadyExists:
 			statusCode = http.StatusConflict
 			errorType = "custom_code_unavailable"
+		case services.ErrInvalidRedirectType:
+			statusCode = http.StatusBadRequest
+			errorType = "invalid_redirect_type"
+		case services.ErrInvalidRedirectRule, services.ErrTooManyRedirectRules:
+			statusCode = http.StatusBadRequest
+			errorType = "invalid_redirect_rules"
+		case services.ErrInvalidLinkPassword:
+			statusCode = http.StatusBadRequest
+			errorType = "invalid_password"
+		case services.ErrLinkPasswordUnavailable:
+			statusCode = http.StatusServiceUnavailable
+			errorType = "password_protection_unavailable"
+		case services.ErrInvalidSchedule, services.ErrInvalidMaxClicks:
+			statusCode = http.StatusBadRequest
+			errorType = "invalid_link_limits"
+		case services.ErrInvalidTag, services.ErrTooManyTags:
+			statusCode = http.StatusBadRequest
+			errorType = "invalid_tags"
+		case services.ErrSocialPreviewTooLong, services.ErrInvalidSocialPreviewImage, services.ErrInvalidTwitterCard:
+			statusCode = http.StatusBadRequest
+			errorType = "invalid_social_preview"
+		case services.ErrUnsafeDestination:
+			statusCode = http.StatusBadRequest
+			errorType = "unsafe_destination"
 		}
 
 		c.JSON(statusCode, models.ErrorResponse{
@@ -132,6 +177,8 @@ func (h *Handler) RedirectURL(c *gin.Context) {
 		if err == storage.ErrURLExpired {
 			statusCode = http.StatusGone
 			errorType = "url_expired"
+		} else if err == storage.ErrURLNotYetActive {
+			errorType = "url_not_active"
 		}
 
 		c.JSON(statusCode, models.ErrorResponse{
@@ -141,20 +188,173 @@ func (h *Handler) RedirectURL(c *gin.Context) {
 		return
 	}
 
-	// Record the click for analytics
+	// Quarantined links warn visitors instead of sending them on. No click is
+	// recorded, and preview bots get the warning too rather than a card.
+	if mapping.IsQuarantined() {
+		c.Header("Cache-Control", "private, no-store")
+		renderHTMLPage(c, http.StatusForbidden, quarantineWarningTemplate, gin.H{
+			"Reason":      mapping.QuarantineReason,
+			"Destination": mapping.OriginalURL,
+		})
+		return
+	}
+
 	clientIP := getClientIP(c)
 	userAgent := c.GetHeader("User-Agent")
 	referrer := c.GetHeader("Referer")
-	
-	go func() {
-		if err := h.shortenerService.RecordClick(shortCode, clientIP, userAgent, referrer); err != nil {
-			// Log error but don't fail the redirect
-			// In production, you'd use proper logging
+
+	// Link preview bots get the link's custom card instead of the destination.
+	// The card links back to the short URL, so it never reveals the destination
+	// of a password-protected link.
+	if card := h.shortenerService.PreviewCard(mapping, userAgent); card != nil {
+		h.shortenerService.RecordClick(mapping, clientIP, userAgent, referrer)
+		c.Header("Cache-Control", "public, max-age=300")
+		c.Header("Vary", "User-Agent")
+		renderHTMLPage(c, http.StatusOK, socialPreviewTemplate, card)
+		return
+	}
+
+	// Password-protected links show a challenge until the visitor holds a valid access cookie
+	if mapping.IsPasswordProtected() {
+		token, _ := c.Cookie(linkAccessCookieName(shortCode))
+		if !h.shortenerService.HasLinkAccess(mapping, token) {
+			c.Header("Cache-Control", "private, no-store")
+			renderHTMLPage(c, http.StatusOK, passwordChallengeTemplate, gin.H{"Action": linkPath(c, shortCode)})
+			return
+		}
+	}
+
+	// Click-limited links reserve their click before redirecting
+	if err := h.shortenerService.AdmitClick(mapping, userAgent); err != nil {
+		if err != services.ErrClickLimitReached {
+			c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{
+				Error:   "service_unavailable",
+				Message: "Unable to verify the link's click limit",
+			})
+			return
+		}
+
+		c.Header("Cache-Control", "private, no-store")
+		if mapping.FallbackURL != "" {
+			c.Redirect(http.StatusFound, mapping.FallbackURL)
+			return
 		}
-	}()
+		renderHTMLPage(c, http.StatusGone, linkExhaustedTemplate, nil)
+		return
+	}
+
+	// Record the click for analytics. Queuing never blocks the redirect; clicks
+	// dropped under backpressure are counted in the ingestion stats reported by
+	// the health check
+	if c.Query(models.ClickSourceParam) == models.ClickSourceQR {
+		h.shortenerService.RecordScan(mapping, clientIP, userAgent, referrer)
+	} else {
+		h.shortenerService.RecordClick(mapping, clientIP, userAgent, referrer)
+	}
+
+	// Pick the destination from the link's conditional rules
+	destination := h.shortenerService.ResolveDestination(mapping, &services.RedirectContext{
+		ClientIP:       clientIP,
+		UserAgent:      userAgent,
+		AcceptLanguage: c.GetHeader("Accept-Language"),
+		Time:           time.Now(),
+	})
+
+	// Perform the redirect using the link's configured redirect type
+	h.performRedirect(c, mapping, destination)
+}
+
+// UnlockURL handles password submissions for protected links
+func (h *Handler) UnlockURL(c *gin.Context) {
+	shortCode := c.Param("shortCode")
+
+	mapping, err := h.shortenerService.GetOriginalURL(shortCode)
+	if err != nil {
+		statusCode := http.StatusNotFound
+		errorType := "not_found"
+
+		if err == storage.ErrURLExpired {
+			statusCode = http.StatusGone
+			errorType = "url_expired"
+		} else if err == storage.ErrURLNotYetActive {
+			errorType = "url_not_active"
+		}
+
+		c.JSON(statusCode, models.ErrorResponse{
+			Error:   errorType,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if !mapping.IsPasswordProtected() {
+		c.Redirect(http.StatusSeeOther, linkPath(c, shortCode))
+		return
+	}
+
+	// Lockout is keyed on the client IP, which only honours X-Forwarded-For
+	// from TRUSTED_PROXIES, so visitors can't rotate it for unlimited guesses
+	token, expiresAt, err := h.shortenerService.UnlockLink(mapping, c.PostForm("password"), c.ClientIP())
+	if err != nil {
+		statusCode := http.StatusUnauthorized
+		if err == services.ErrLinkPasswordLocked {
+			statusCode = http.StatusTooManyRequests
+		} else if err == services.ErrLinkPasswordUnavailable {
+			statusCode = http.StatusServiceUnavailable
+		} else if err != services.ErrIncorrectLinkPassword {
+			statusCode = http.StatusInternalServerError
+		}
+
+		c.Header("Cache-Control", "private, no-store")
+		renderHTMLPage(c, statusCode, passwordChallengeTemplate, gin.H{
+			"Action": linkPath(c, shortCode),
+			"Error":  err.Error(),
+		})
+		return
+	}
+
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(linkAccessCookieName(shortCode), token, int(time.Until(expiresAt).Seconds()), "/"+shortCode, "", secure, true)
+
+	// Send the visitor back through the normal redirect so the click is recorded
+	c.Redirect(http.StatusSeeOther, linkPath(c, shortCode))
+}
+
+// linkPath returns the path of a short link, keeping the QR scan tag so
+// scans of protected links are still counted as scans once unlocked
+func linkPath(c *gin.Context, shortCode string) string {
+	if c.Query(models.ClickSourceParam) == models.ClickSourceQR {
+		return "/" + shortCode + "?" + models.ClickSourceParam + "=" + models.ClickSourceQR
+	}
+	return "/" + shortCode
+}
+
+// linkAccessCookieName returns the cookie holding a visitor's access token for a protected link
+func linkAccessCookieName(shortCode string) string {
+	return "link_access_" + shortCode
+}
+
+// performRedirect sends the client to the destination honouring the mapping's redirect type
+func (h *Handler) performRedirect(c *gin.Context, mapping *models.URLMapping, destination string) {
+	statusCode := models.RedirectStatusCode(mapping.RedirectType)
+
+	// Temporary redirects must not be cached, otherwise repeat clicks never reach us
+	if statusCode != http.StatusMovedPermanently && statusCode != http.StatusPermanentRedirect {
+		c.Header("Cache-Control", "private, no-cache, no-store, must-revalidate")
+	}
 
-	// Perform the redirect
-	c.Redirect(http.StatusMovedPermanently, mapping.OriginalURL)
+	// Responses vary per visitor when conditional rules or a preview card are in play
+	if len(mapping.RedirectRules) > 0 || mapping.SocialPreview != nil {
+		c.Header("Vary", "Accept-Language, User-Agent")
+	}
+
+	if mapping.RedirectType == models.RedirectTypeMetaRefresh {
+		renderHTMLPage(c, statusCode, metaRefreshTemplate, gin.H{"URL": destination})
+		return
+	}
+
+	c.Redirect(statusCode, destination)
 }
 
 // GetAnalytics handles analytics requests
@@ -181,7 +381,7 @@ func (h *Handler) GetAnalytics(c *gin.Context) {
 	}
 
 	// Get analytics data
-	analytics, err := h.analyticsService.GetAnalytics(shortCode, days)
+	analytics, err := h.analyticsService.GetAnalytics(shortCode, days, includeBotsParam(c))
 	if err != nil {
 		statusCode := http.StatusInternalServerError
 		errorType := "internal_error"
@@ -258,10 +458,17 @@ func (h *Handler) GetClickTrends(c *gin.Context) {
 		return
 	}
 
-	trends, err := h.analyticsService.GetClickTrends(shortCode, period)
+	trends, err := h.analyticsService.GetClickTrends(shortCode, period, includeBotsParam(c))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "invalid_period",
+		if err == services.ErrInvalidTrendPeriod {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_period",
+				Message: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
 			Message: err.Error(),
 		})
 		return
@@ -378,6 +585,42 @@ func (h *Handler) GetUserURLs(c *gin.Context) {
 	})
 }
 
+// SearchUserURLs handles searches over the user's URLs
+func (h *Handler) SearchUserURLs(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		// Temporary: Use default user ID 1 for development
+		userID = 1
+	}
+
+	var request models.URLSearchRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	results, err := h.shortenerService.SearchUserURLs(userID, &request)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		errorType := "internal_error"
+		if _, ok := err.(*services.ServiceError); ok {
+			statusCode = http.StatusBadRequest
+			errorType = "invalid_search"
+		}
+
+		c.JSON(statusCode, models.ErrorResponse{
+			Error:   errorType,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
 // DeleteURL handles URL deletion requests
 func (h *Handler) DeleteURL(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
@@ -449,6 +692,17 @@ func (h *Handler) UpdateURL(c *gin.Context) {
 			statusCode = http.StatusNotFound
 		} else if err == storage.ErrUnauthorized {
 			statusCode = http.StatusForbidden
+		} else if err == services.ErrInvalidRedirectType || err == services.ErrInvalidURL ||
+			err == services.ErrInvalidURLScheme || err == services.ErrSelfReferentialURL ||
+			err == services.ErrInvalidRedirectRule || err == services.ErrTooManyRedirectRules ||
+			err == services.ErrInvalidLinkPassword || err == services.ErrInvalidSchedule ||
+			err == services.ErrInvalidMaxClicks || err == services.ErrInvalidTag ||
+			err == services.ErrTooManyTags || err == services.ErrSocialPreviewTooLong ||
+			err == services.ErrInvalidSocialPreviewImage || err == services.ErrInvalidTwitterCard ||
+			err == services.ErrUnsafeDestination {
+			statusCode = http.StatusBadRequest
+		} else if err == services.ErrLinkPasswordUnavailable {
+			statusCode = http.StatusServiceUnavailable
 		}
 
 		c.JSON(statusCode, models.ErrorResponse{
@@ -461,6 +715,45 @@ func (h *Handler) UpdateURL(c *gin.Context) {
 	c.JSON(http.StatusOK, updatedURL)
 }
 
+// GetURLDestinationHistory handles requests for a URL's prior destinations
+func (h *Handler) GetURLDestinationHistory(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		// Temporary: Use default user ID 1 for development
+		userID = 1
+	}
+
+	shortCode := c.Param("shortCode")
+	if shortCode == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Short code is required",
+		})
+		return
+	}
+
+	history, err := h.shortenerService.GetUserURLDestinationHistory(userID, shortCode)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if err == storage.ErrURLNotFound {
+			statusCode = http.StatusNotFound
+		} else if err == storage.ErrUnauthorized {
+			statusCode = http.StatusForbidden
+		}
+
+		c.JSON(statusCode, models.ErrorResponse{
+			Error:   "history_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"short_code":   shortCode,
+		"destinations": history,
+	})
+}
+
 // GetUserDashboardStats handles user dashboard statistics
 func (h *Handler) GetUserDashboardStats(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
@@ -469,7 +762,7 @@ func (h *Handler) GetUserDashboardStats(c *gin.Context) {
 		userID = 1
 	}
 
-	stats, err := h.analyticsService.GetUserDashboardStats(userID)
+	stats, err := h.analyticsService.GetUserDashboardStats(userID, includeBotsParam(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "internal_error",
@@ -481,6 +774,13 @@ func (h *Handler) GetUserDashboardStats(c *gin.Context) {
 	c.JSON(http.StatusOK, stats)
 }
 
+// includeBotsParam reports whether the include_bots query flag asks for
+// bot, preview and scanner clicks to be counted
+func includeBotsParam(c *gin.Context) bool {
+	includeBots, _ := strconv.ParseBool(c.Query("include_bots"))
+	return includeBots
+}
+
 // getClientIP extracts the real client IP from the request
 func getClientIP(c *gin.Context) string {
 	// Check X-Forwarded-For header first (for load balancers/proxies)
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/URLshorter/url-shortener/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// JWKSHandler publishes the public keys tokens are signed with
+type JWKSHandler struct {
+	jwtService *services.JWTService
+}
+
+// NewJWKSHandler creates a new JWKS handler
+func NewJWKSHandler(jwtService *services.JWTService) *JWKSHandler {
+	return &JWKSHandler{
+		jwtService: jwtService,
+	}
+}
+
+// GetJWKS serves the JSON Web Key Set other services verify tokens with.
+// Keys are published before they start signing, so verifiers caching the
+// set for its max-age never see a token signed with an unknown key.
+func (h *JWKSHandler) GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.jwtService.JWKS())
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/URLshorter/url-shortener/internal/middleware"
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/URLshorter/url-shortener/internal/services"
+	"github.com/URLshorter/url-shortener/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// QRCodeHandler handles QR code endpoints
+type QRCodeHandler struct {
+	qrCodeService *services.QRCodeService
+}
+
+// NewQRCodeHandler creates a new QR code handler
+func NewQRCodeHandler(qrCodeService *services.QRCodeService) *QRCodeHandler {
+	return &QRCodeHandler{
+		qrCodeService: qrCodeService,
+	}
+}
+
+// GetQRCode renders a QR code for one of the user's links as PNG or SVG
+func (h *QRCodeHandler) GetQRCode(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		// Temporary: Use default user ID 1 for development
+		userID = 1
+	}
+
+	var req models.QRCodeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	data, contentType, err := h.qrCodeService.Render(userID, c.Param("shortCode"), &req)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		errorType := "qr_code_failed"
+		if _, ok := err.(*services.ServiceError); ok {
+			statusCode = http.StatusBadRequest
+			errorType = "invalid_qr_options"
+		} else if err == storage.ErrURLNotFound {
+			statusCode = http.StatusNotFound
+		} else if err == storage.ErrUnauthorized {
+			statusCode = http.StatusForbidden
+		}
+
+		c.JSON(statusCode, models.ErrorResponse{
+			Error:   errorType,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	// The image only changes with the options in the URL
+	c.Header("Cache-Control", "private, max-age=3600")
+	c.Data(http.StatusOK, contentType, data)
+}
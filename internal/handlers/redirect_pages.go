@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// metaRefreshTemplate renders the interstitial page used by the meta_refresh redirect type
+var metaRefreshTemplate = template.Must(template.New("meta_refresh").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex, nofollow">
+<meta http-equiv="refresh" content="0; url={{.URL}}">
+<title>Redirecting...</title>
+</head>
+<body>
+<p>Redirecting to <a href="{{.URL}}">{{.URL}}</a>...</p>
+<script>window.location.replace({{.URL}});</script>
+</body>
+</html>`))
+
+// renderHTMLPage executes a page template and writes it with the given status code
+func renderHTMLPage(c *gin.Context, statusCode int, tmpl *template.Template, data interface{}) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		c.String(http.StatusInternalServerError, "failed to render page")
+		return
+	}
+	c.Data(statusCode, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// passwordChallengeTemplate renders the password form shown for protected links
+var passwordChallengeTemplate = template.Must(template.New("password_challenge").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex, nofollow">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Password required</title>
+<style>
+body{font-family:-apple-system,BlinkMacSystemFont,"Segoe UI",Roboto,sans-serif;background:#f5f5f5;display:flex;align-items:center;justify-content:center;min-height:100vh;margin:0}
+form{background:#fff;padding:2rem;border-radius:8px;box-shadow:0 2px 8px rgba(0,0,0,.1);width:100%;max-width:320px}
+input{width:100%;padding:.6rem;margin:.75rem 0;box-sizing:border-box;border:1px solid #ccc;border-radius:4px}
+button{width:100%;padding:.6rem;border:0;border-radius:4px;background:#2563eb;color:#fff;cursor:pointer}
+.error{color:#b91c1c;font-size:.9rem}
+</style>
+</head>
+<body>
+<form method="POST" action="{{.Action}}">
+<h1>Password required</h1>
+<p>This link is protected. Enter the password to continue.</p>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<input type="password" name="password" autocomplete="current-password" autofocus required>
+<button type="submit">Continue</button>
+</form>
+</body>
+</html>`))
+
+// linkExhaustedTemplate renders the 410 page for click-limited links without a fallback URL
+var linkExhaustedTemplate = template.Must(template.New("link_exhausted").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex, nofollow">
+<title>Link no longer available</title>
+</head>
+<body>
+<h1>Link no longer available</h1>
+<p>This link has reached its maximum number of uses.</p>
+</body>
+</html>`))
+
+// socialPreviewTemplate renders a link's custom preview card for link preview bots
+var socialPreviewTemplate = template.Must(template.New("social_preview").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex, nofollow">
+<title>{{.Title}}</title>
+<meta property="og:type" content="website">
+<meta property="og:url" content="{{.URL}}">
+{{if .Title}}<meta property="og:title" content="{{.Title}}">
+<meta name="twitter:title" content="{{.Title}}">
+{{end}}{{if .Description}}<meta property="og:description" content="{{.Description}}">
+<meta name="description" content="{{.Description}}">
+<meta name="twitter:description" content="{{.Description}}">
+{{end}}{{if .Image}}<meta property="og:image" content="{{.Image}}">
+<meta name="twitter:image" content="{{.Image}}">
+{{end}}<meta name="twitter:card" content="{{.TwitterCard}}">
+</head>
+<body>
+<p><a href="{{.URL}}">{{if .Title}}{{.Title}}{{else}}{{.URL}}{{end}}</a></p>
+</body>
+</html>`))
+
+// quarantineWarningTemplate renders the warning shown instead of redirecting
+// for a quarantined link. The destination is shown as plain text, never as a
+// link, so the page can't be used to reach it with one click.
+var quarantineWarningTemplate = template.Must(template.New("quarantine_warning").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex, nofollow">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Warning: unsafe link</title>
+<style>
+body{font-family:-apple-system,BlinkMacSystemFont,"Segoe UI",Roboto,sans-serif;background:#fef2f2;display:flex;align-items:center;justify-content:center;min-height:100vh;margin:0}
+main{background:#fff;padding:2rem;border-radius:8px;box-shadow:0 2px 8px rgba(0,0,0,.1);max-width:480px}
+h1{color:#b91c1c}
+code{word-break:break-all}
+</style>
+</head>
+<body>
+<main>
+<h1>This link has been disabled</h1>
+<p>The page this link points to has been reported as unsafe and may try to steal your information or install harmful software.</p>
+{{if .Reason}}<p>Reason: {{.Reason}}</p>{{end}}
+<p>Destination: <code>{{.Destination}}</code></p>
+</main>
+</body>
+</html>`))
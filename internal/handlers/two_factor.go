@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/URLshorter/url-shortener/internal/middleware"
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/URLshorter/url-shortener/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// TwoFactorHandler handles a signed-in user's two-factor settings
+type TwoFactorHandler struct {
+	twoFactorService *services.TwoFactorService
+	userService      *services.UserService
+	validator        *validator.Validate
+}
+
+// NewTwoFactorHandler creates a new two-factor handler
+func NewTwoFactorHandler(twoFactorService *services.TwoFactorService, userService *services.UserService) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		twoFactorService: twoFactorService,
+		userService:      userService,
+		validator:        validator.New(),
+	}
+}
+
+// GetStatus returns whether two-factor is enabled or required and how many
+// recovery codes are left
+func (h *TwoFactorHandler) GetStatus(c *gin.Context) {
+	userID, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	status, err := h.twoFactorService.Status(userID)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// Enroll starts enrolment, returning the secret for the authenticator app
+func (h *TwoFactorHandler) Enroll(c *gin.Context) {
+	userID, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.userService.GetUserByID(userID)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	enrollment, err := h.twoFactorService.BeginEnrollment(user)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// Confirm enables two-factor with a code from the authenticator app and
+// returns the recovery codes, which are only shown this once
+func (h *TwoFactorHandler) Confirm(c *gin.Context) {
+	userID, req, ok := h.codeRequest(c)
+	if !ok {
+		return
+	}
+
+	codes, err := h.twoFactorService.ConfirmEnrollment(userID, req.Code)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	middleware.LogInfo(c, "Two-factor enabled")
+	c.JSON(http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// Disable turns two-factor off given a current code
+func (h *TwoFactorHandler) Disable(c *gin.Context) {
+	userID, req, ok := h.codeRequest(c)
+	if !ok {
+		return
+	}
+
+	if err := h.twoFactorService.Disable(userID, req.Code); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	middleware.LogInfo(c, "Two-factor disabled")
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes given a current code
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	userID, req, ok := h.codeRequest(c)
+	if !ok {
+		return
+	}
+
+	codes, err := h.twoFactorService.RegenerateRecoveryCodes(userID, req.Code)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// requireUser reads the caller, responding itself when there is none
+func (h *TwoFactorHandler) requireUser(c *gin.Context) (int64, bool) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "Authentication required",
+		})
+		return 0, false
+	}
+	return userID, true
+}
+
+// codeRequest reads the caller and the code in the body, responding itself
+// when either is missing
+func (h *TwoFactorHandler) codeRequest(c *gin.Context) (int64, *models.TwoFactorCodeRequest, bool) {
+	userID, ok := h.requireUser(c)
+	if !ok {
+		return 0, nil, false
+	}
+
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return 0, nil, false
+	}
+	if err := h.validator.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Code is required",
+		})
+		return 0, nil, false
+	}
+
+	return userID, &req, true
+}
+
+// respondError maps two-factor service errors to responses
+func (h *TwoFactorHandler) respondError(c *gin.Context, err error) {
+	switch err {
+	case services.ErrInvalidTwoFactorCode:
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "invalid_code",
+			Message: err.Error(),
+		})
+	case services.ErrTwoFactorAlreadyEnabled:
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "already_enabled",
+			Message: err.Error(),
+		})
+	case services.ErrTwoFactorNotEnabled, services.ErrTwoFactorNotEnrolling:
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "not_enabled",
+			Message: err.Error(),
+		})
+	case services.ErrTwoFactorRequired:
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "two_factor_required",
+			Message: err.Error(),
+		})
+	default:
+		middleware.LogError(c, err, "Two-factor request failed")
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Two-factor request failed",
+		})
+	}
+}
@@ -1,155 +1,199 @@
 package middleware
 
 import (
+	"fmt"
+	"log"
 	"net/http"
 	"strings"
 	"time"
 
-	"github.com/gin-gonic/gin"
 	"github.com/URLshorter/url-shortener/internal/models"
 	"github.com/URLshorter/url-shortener/internal/services"
+	"github.com/gin-gonic/gin"
 )
 
-// APIKeyAuthMiddleware validates API key authentication
-func APIKeyAuthMiddleware(apiKeyService *services.APIKeyService) gin.HandlerFunc {
+// apiKeyPrefix starts every API key, telling keys apart from JWTs
+const apiKeyPrefix = "us_"
+
+// APIKeyValidator looks up the API key a request presents
+type APIKeyValidator interface {
+	ValidateAPIKey(key string) (*models.APIKey, error)
+}
+
+// APIKeyAuth authenticates requests with an API key as an alternative to a
+// JWT. Keys are sent like tokens, as "Authorization: Bearer us_...", and
+// only reach routes guarded by RequireScope with a scope the key was granted.
+type APIKeyAuth struct {
+	auth  *AuthMiddleware
+	keys  APIKeyValidator
+	usage *services.APIKeyUsageRecorder
+}
+
+// NewAPIKeyAuth creates API key authentication falling back to auth for
+// JWTs. Without keys only JWTs are accepted; without usage, requests made
+// with keys are not recorded.
+func NewAPIKeyAuth(auth *AuthMiddleware, keys APIKeyValidator, usage *services.APIKeyUsageRecorder) *APIKeyAuth {
+	return &APIKeyAuth{
+		auth:  auth,
+		keys:  keys,
+		usage: usage,
+	}
+}
+
+// RequireAPIKey only accepts requests made with a valid API key
+func (a *APIKeyAuth) RequireAPIKey() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Check for API key in Authorization header
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "API key required"})
+		key, ok := a.extractAPIKey(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+				Error:   "unauthorized",
+				Message: "API key required",
+			})
 			c.Abort()
 			return
 		}
 
-		// Extract API key from "Bearer <key>" format
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format. Use 'Bearer <api_key>'"})
-			c.Abort()
+		a.serveWithAPIKey(c, key)
+	}
+}
+
+// RequireAuth accepts requests made with either a valid API key or a JWT
+func (a *APIKeyAuth) RequireAuth() gin.HandlerFunc {
+	requireJWT := a.auth.RequireAuth()
+	return func(c *gin.Context) {
+		if key, ok := a.extractAPIKey(c); ok {
+			a.serveWithAPIKey(c, key)
 			return
 		}
+		requireJWT(c)
+	}
+}
 
-		apiKey := parts[1]
-
-		// Validate API key
-		keyData, err := apiKeyService.ValidateAPIKey(apiKey)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired API key"})
-			c.Abort()
+// OptionalAuth sets the caller from an API key or JWT when one is present.
+// An invalid API key is rejected rather than treated as anonymous, so a
+// misconfigured integration notices.
+func (a *APIKeyAuth) OptionalAuth() gin.HandlerFunc {
+	optionalJWT := a.auth.OptionalAuth()
+	return func(c *gin.Context) {
+		if key, ok := a.extractAPIKey(c); ok {
+			a.serveWithAPIKey(c, key)
 			return
 		}
-
-		// Update API key usage
-		ipAddress := c.ClientIP()
-		go func() {
-			_ = apiKeyService.UpdateAPIKeyUsage(keyData.ID, ipAddress)
-		}()
-
-		// Set user and API key info in context
-		c.Set("user_id", keyData.UserID)
-		c.Set("api_key_id", keyData.ID)
-		c.Set("api_key_permissions", keyData.Permissions)
-		c.Set("auth_type", "api_key")
-
-		c.Next()
-
-		// Record API key usage after request
-		go func() {
-			statusCode := c.Writer.Status()
-			endpoint := c.Request.URL.Path
-			method := c.Request.Method
-			userAgent := c.GetHeader("User-Agent")
-			
-			// Calculate response time (simplified - you might want to use a more accurate method)
-			responseTime := int(time.Since(time.Now()).Milliseconds())
-			
-			_ = apiKeyService.RecordAPIKeyUsage(
-				keyData.ID,
-				endpoint,
-				method,
-				statusCode,
-				responseTime,
-				int(c.Request.ContentLength),
-				c.Writer.Size(),
-				ipAddress,
-				userAgent,
-			)
-		}()
+		optionalJWT(c)
 	}
 }
 
-// APIKeyPermissionMiddleware checks if the API key has the required permission
-func APIKeyPermissionMiddleware(apiKeyService *services.APIKeyService, permission string) gin.HandlerFunc {
+// RequireScope rejects requests made with an API key lacking scope. Requests
+// authenticated by JWT, or not at all, are left to the handler.
+func RequireScope(scope string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Check if this is API key authentication
-		authType, exists := c.Get("auth_type")
-		if !exists || authType != "api_key" {
+		if c.GetString("auth_type") != "api_key" {
 			c.Next()
 			return
 		}
 
-		// Get API key permissions
-		permissions, exists := c.Get("api_key_permissions")
-		if !exists {
-			c.JSON(http.StatusForbidden, gin.H{"error": "No permissions found"})
+		permissions, _ := c.Get("api_key_permissions")
+		granted, _ := permissions.(models.APIKeyPermissions)
+		if !granted.Allows(scope) {
+			c.JSON(http.StatusForbidden, models.ErrorResponse{
+				Error:   "insufficient_scope",
+				Message: fmt.Sprintf("API key lacks the %s scope", scope),
+			})
 			c.Abort()
 			return
 		}
 
-		apiKeyPerms, ok := permissions.(models.APIKeyPermissions)
-		if !ok {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid permissions format"})
-			c.Abort()
-			return
-		}
+		c.Next()
+	}
+}
 
-		// Check permission
-		hasPermission := false
-		for _, perm := range apiKeyPerms {
-			if perm == "*" || perm == permission {
-				hasPermission = true
-				break
-			}
-		}
+// extractAPIKey returns the API key in the Authorization header, if the
+// header holds one rather than a JWT
+func (a *APIKeyAuth) extractAPIKey(c *gin.Context) (string, bool) {
+	if a.keys == nil {
+		return "", false
+	}
+
+	key := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return "", false
+	}
+	return key, true
+}
 
-		if !hasPermission {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions for this action"})
+// serveWithAPIKey authenticates the request with key, serves it and records
+// the usage
+func (a *APIKeyAuth) serveWithAPIKey(c *gin.Context, key string) {
+	keyData, err := a.keys.ValidateAPIKey(key)
+	if err != nil {
+		if err != services.ErrInvalidAPIKey && err != services.ErrAPIKeyExpired {
+			log.Printf("Failed to validate API key: %v", err)
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "internal_error",
+				Message: "Failed to validate API key",
+			})
 			c.Abort()
 			return
 		}
 
-		c.Next()
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "invalid_api_key",
+			Message: "Invalid or expired API key",
+		})
+		c.Abort()
+		return
 	}
-}
 
-// FlexibleAuthMiddleware allows both JWT and API key authentication
-func FlexibleAuthMiddleware(authMiddleware *AuthMiddleware, apiKeyService *services.APIKeyService) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// Try API key authentication first
-		authHeader := c.GetHeader("Authorization")
-		if authHeader != "" && strings.HasPrefix(authHeader, "Bearer us_") {
-			parts := strings.Split(authHeader, " ")
-			if len(parts) == 2 {
-				apiKey := parts[1]
-				keyData, err := apiKeyService.ValidateAPIKey(apiKey)
-				if err == nil {
-					// Valid API key
-					ipAddress := c.ClientIP()
-					go func() {
-						_ = apiKeyService.UpdateAPIKeyUsage(keyData.ID, ipAddress)
-					}()
-
-					c.Set("user_id", keyData.UserID)
-					c.Set("api_key_id", keyData.ID)
-					c.Set("api_key_permissions", keyData.Permissions)
-					c.Set("auth_type", "api_key")
-					c.Next()
-					return
-				}
-			}
-		}
+	// Keys stop working with their owner's account, as tokens do
+	user, err := a.auth.userService.GetUserByID(keyData.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "user_not_found",
+			Message: "User account not found",
+		})
+		c.Abort()
+		return
+	}
+	if !user.IsActive {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "account_suspended",
+			Message: "Account has been suspended",
+		})
+		c.Abort()
+		return
+	}
 
-		// Fall back to JWT authentication
-		authMiddleware.RequireAuth()(c)
+	c.Set("user", user)
+	c.Set("user_id", user.ID)
+	c.Set("api_key_id", keyData.ID)
+	c.Set("api_key_permissions", keyData.Permissions)
+	c.Set("api_key_rate_limit", keyData.RateLimit)
+	c.Set("auth_type", "api_key")
+
+	start := time.Now()
+	c.Next()
+
+	if a.usage == nil {
+		return
+	}
+
+	requestSize := int(c.Request.ContentLength)
+	if requestSize < 0 {
+		requestSize = 0
+	}
+	endpoint := c.FullPath()
+	if endpoint == "" {
+		endpoint = c.Request.URL.Path
 	}
-}
\ No newline at end of file
+	a.usage.Record(
+		keyData.ID,
+		endpoint,
+		c.Request.Method,
+		c.Writer.Status(),
+		int(time.Since(start).Milliseconds()),
+		requestSize,
+		c.Writer.Size(),
+		c.ClientIP(),
+		c.GetHeader("User-Agent"),
+	)
+}
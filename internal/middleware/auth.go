@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/URLshorter/url-shortener/internal/models"
@@ -58,6 +57,24 @@ func (a *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 			return
 		}
 
+		// Reject tokens revoked by logging out
+		if err := a.jwtService.CheckRevoked(claims); err != nil {
+			if err == services.ErrRevokedToken {
+				c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+					Error:   "token_revoked",
+					Message: "Token has been revoked",
+				})
+			} else {
+				LogError(c, err, "Failed to check token revocation")
+				c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{
+					Error:   "auth_unavailable",
+					Message: "Unable to verify token",
+				})
+			}
+			c.Abort()
+			return
+		}
+
 		// Get user from database to ensure they still exist and are active
 		user, err := a.userService.GetUserByID(claims.UserID)
 		if err != nil {
@@ -221,7 +238,7 @@ func (a *AuthMiddleware) OptionalAuth() gin.HandlerFunc {
 			return
 		}
 
-		if claims.TokenType != "access" {
+		if claims.TokenType != "access" || a.jwtService.CheckRevoked(claims) != nil {
 			c.Next()
 			return
 		}
@@ -242,44 +259,6 @@ func (a *AuthMiddleware) OptionalAuth() gin.HandlerFunc {
 	}
 }
 
-// RateLimitByUser applies rate limiting based on user account type
-func (a *AuthMiddleware) RateLimitByUser() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		user, exists := c.Get("user")
-		if !exists {
-			// Apply default rate limiting for anonymous users
-			c.Header("X-RateLimit-Limit", "100")
-			c.Header("X-RateLimit-Remaining", "99")
-			c.Header("X-RateLimit-Reset", fmt.Sprintf("%d", time.Now().Add(time.Hour).Unix()))
-			c.Next()
-			return
-		}
-
-		u, ok := user.(*models.User)
-		if !ok {
-			c.Next()
-			return
-		}
-
-		// Set rate limits based on account type
-		var limit int
-		switch u.AccountType {
-		case "premium":
-			limit = 5000 // 5000 requests per hour
-		case "enterprise":
-			limit = 20000 // 20000 requests per hour
-		default:
-			limit = 1000 // 1000 requests per hour for free users
-		}
-
-		c.Header("X-RateLimit-Limit", fmt.Sprintf("%d", limit))
-		c.Header("X-RateLimit-Remaining", fmt.Sprintf("%d", limit-1)) // Simplified for demo
-		c.Header("X-RateLimit-Reset", fmt.Sprintf("%d", time.Now().Add(time.Hour).Unix()))
-
-		c.Next()
-	}
-}
-
 // RequireValidSession ensures the session is still valid
 func (a *AuthMiddleware) RequireValidSession() gin.HandlerFunc {
 	return func(c *gin.Context) {
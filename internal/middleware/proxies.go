@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TrustProxies makes the router honour X-Forwarded-For only from the given
+// comma-separated proxy addresses or CIDR ranges. With none, ClientIP is
+// always the connection address, so clients can't pick their own IP to get
+// a fresh rate limit bucket or more password guesses.
+func TrustProxies(router *gin.Engine, proxies string) error {
+	var trusted []string
+	for _, proxy := range strings.Split(proxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			trusted = append(trusted, proxy)
+		}
+	}
+	return router.SetTrustedProxies(trusted)
+}
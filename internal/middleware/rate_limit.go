@@ -2,69 +2,112 @@ package middleware
 
 import (
 	"fmt"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/URLshorter/url-shortener/internal/services"
+	"github.com/URLshorter/url-shortener/internal/storage"
 	"github.com/gin-gonic/gin"
-	"golang.org/x/time/rate"
 )
 
 // RateLimitConfig defines rate limiting configuration
 type RateLimitConfig struct {
-	RequestsPerSecond float64
+	Name              string  // keeps each policy's buckets separate
+	RequestsPerSecond float64 // for anonymous callers, counted per IP
 	Burst             int
-	Duration          time.Duration
+
+	// Quotas lets authenticated callers use their hourly quota, from their
+	// API key or billing plan, instead of the per-IP limit
+	Quotas bool
 }
 
 // DefaultRateLimit provides default rate limiting
 var DefaultRateLimit = RateLimitConfig{
+	Name:              "default",
 	RequestsPerSecond: 10, // 10 requests per second
 	Burst:             20, // Allow burst of 20
-	Duration:          time.Hour,
+	Quotas:            true,
 }
 
 // AuthRateLimit for authentication endpoints
 var AuthRateLimit = RateLimitConfig{
+	Name:              "auth",
 	RequestsPerSecond: 5,  // 5 requests per second
 	Burst:             10, // Allow burst of 10
-	Duration:          time.Hour,
 }
 
 // OTPRateLimit for OTP endpoints (more restrictive)
 var OTPRateLimit = RateLimitConfig{
-	RequestsPerSecond: 1,  // 1 request per second
-	Burst:             3,  // Allow burst of 3
-	Duration:          time.Minute * 5,
+	Name:              "otp",
+	RequestsPerSecond: 1, // 1 request per second
+	Burst:             3, // Allow burst of 3
 }
 
-type visitor struct {
-	limiter  *rate.Limiter
-	lastSeen time.Time
+// planCacheTTL is how long a user's billing plan is cached between lookups
+const planCacheTTL = 5 * time.Minute
+
+// RateLimiter enforces token bucket rate limits kept in the cache, so every
+// replica sharing Redis counts against the same buckets. Anonymous callers
+// are limited per IP; with Quotas, callers authenticated by an API key get the
+// key's hourly RateLimit, capped by their plan, and signed-in users get their
+// plan's RequestsPerHour. Register it after the authentication middleware so
+// it can see who the caller is.
+//
+// The limiter fails open: if the cache can't be reached the request is let
+// through and the failure logged.
+type RateLimiter struct {
+	cache storage.Cache
+	plans services.PlanLookup
 }
 
-var visitors = make(map[string]*visitor)
+// NewRateLimiter creates a rate limiter keeping its buckets in cache
+func NewRateLimiter(cache storage.Cache) *RateLimiter {
+	return &RateLimiter{cache: cache}
+}
 
-// RateLimitMiddleware creates rate limiting middleware
-func RateLimitMiddleware(config RateLimitConfig) gin.HandlerFunc {
-	// Cleanup old visitors periodically
-	go cleanupVisitors()
+// SetPlanLookup resolves the billing plan of authenticated callers. Without a
+// lookup, signed-in users are on the plan of their account type and API keys
+// are not capped by a plan.
+func (l *RateLimiter) SetPlanLookup(plans services.PlanLookup) {
+	l.plans = plans
+}
 
+// rateLimitBucket is the bucket a request is counted in
+type rateLimitBucket struct {
+	key   string
+	rate  float64 // tokens per second
+	burst int
+}
+
+// Limit returns middleware applying config
+func (l *RateLimiter) Limit(config RateLimitConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ip := c.ClientIP()
-		
-		v, exists := visitors[ip]
-		if !exists {
-			limiter := rate.NewLimiter(rate.Limit(config.RequestsPerSecond), config.Burst)
-			visitors[ip] = &visitor{limiter, time.Now()}
-			v = visitors[ip]
+		bucket := l.bucketFor(c, config)
+
+		result, err := l.cache.TakeToken(bucket.key, bucket.rate, bucket.burst)
+		if err != nil {
+			log.Printf("Rate limiter unavailable, allowing request: %v", err)
+			c.Next()
+			return
 		}
 
-		v.lastSeen = time.Now()
+		c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(result.ResetAfter).Unix(), 10))
 
-		if !v.limiter.Allow() {
-			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error":   "Rate limit exceeded",
-				"message": fmt.Sprintf("Too many requests. Limit: %.0f requests per second", config.RequestsPerSecond),
+		if !result.Allowed {
+			retryAfter := int(math.Ceil(result.RetryAfter.Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.JSON(http.StatusTooManyRequests, models.ErrorResponse{
+				Error:   "rate_limit_exceeded",
+				Message: fmt.Sprintf("Too many requests, retry in %d seconds", retryAfter),
 			})
 			c.Abort()
 			return
@@ -74,14 +117,75 @@ func RateLimitMiddleware(config RateLimitConfig) gin.HandlerFunc {
 	}
 }
 
-// cleanupVisitors removes old visitors to prevent memory leaks
-func cleanupVisitors() {
-	for {
-		time.Sleep(time.Minute)
-		for ip, v := range visitors {
-			if time.Since(v.lastSeen) > time.Hour {
-				delete(visitors, ip)
+// bucketFor picks the bucket and limits for a request
+func (l *RateLimiter) bucketFor(c *gin.Context, config RateLimitConfig) rateLimitBucket {
+	prefix := "ratelimit:" + config.Name + ":"
+
+	if config.Quotas {
+		userID, hasUser := c.Get("user_id")
+		id, _ := userID.(int64)
+
+		if keyID, ok := c.Get("api_key_id"); ok {
+			// A key's own limit never exceeds what its owner's plan allows
+			quota := c.GetInt("api_key_rate_limit")
+			if hasUser {
+				if planQuota := l.planQuota(c, id); planQuota > 0 && (quota <= 0 || planQuota < quota) {
+					quota = planQuota
+				}
 			}
+			if quota > 0 {
+				return hourlyBucket(fmt.Sprintf("%sapikey:%v", prefix, keyID), quota)
+			}
+		} else if hasUser {
+			quota := l.planQuota(c, id)
+			if quota <= 0 {
+				quota = services.PredefinedPlans["free"].Limits.RequestsPerHour
+			}
+			return hourlyBucket(fmt.Sprintf("%suser:%d", prefix, id), quota)
+		}
+	}
+
+	return rateLimitBucket{
+		key:   prefix + "ip:" + c.ClientIP(),
+		rate:  config.RequestsPerSecond,
+		burst: config.Burst,
+	}
+}
+
+// hourlyBucket spreads an hourly quota evenly over the hour, allowing the
+// whole quota as a burst
+func hourlyBucket(key string, perHour int) rateLimitBucket {
+	return rateLimitBucket{key: key, rate: float64(perHour) / 3600, burst: perHour}
+}
+
+// planQuota returns the hourly request quota of a user's plan, or 0 when it
+// can't be determined
+func (l *RateLimiter) planQuota(c *gin.Context, userID int64) int {
+	planID := ""
+	if user, ok := c.Get("user"); ok {
+		if u, ok := user.(*models.User); ok {
+			planID = u.AccountType
 		}
 	}
-}
\ No newline at end of file
+
+	if l.plans != nil && userID != 0 {
+		cacheKey := fmt.Sprintf("ratelimit:plan:%d", userID)
+		if cached, err := l.cache.Get(cacheKey); err == nil {
+			planID = cached
+		} else if plan, err := l.plans(userID); err == nil {
+			planID = plan
+			l.cache.Set(cacheKey, plan, planCacheTTL)
+		} else {
+			log.Printf("Failed to look up plan of user %d: %v", userID, err)
+		}
+	}
+
+	plan, ok := services.PredefinedPlans[planID]
+	if !ok {
+		if planID == "" {
+			return 0
+		}
+		plan = services.PredefinedPlans["free"]
+	}
+	return plan.Limits.RequestsPerHour
+}
@@ -0,0 +1,271 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// advisoryLockID serialises runners across processes, e.g. several replicas
+// migrating at startup
+const advisoryLockID = 72_657_310_013
+
+var (
+	// ErrChecksumMismatch means an applied migration was edited afterwards
+	ErrChecksumMismatch = errors.New("applied migration does not match its file")
+	// ErrUnknownVersion means the database has a migration this binary lacks
+	ErrUnknownVersion = errors.New("database has a migration unknown to this build")
+	// ErrIrreversible means a migration to roll back has no down script
+	ErrIrreversible = errors.New("migration has no down script")
+)
+
+// Status describes one migration known to the binary or recorded in the database
+type Status struct {
+	Version         int64
+	Name            string
+	Checksum        string     // checksum of the embedded up script, empty if unknown
+	AppliedChecksum string     // checksum recorded when applied
+	AppliedAt       *time.Time // nil while pending
+}
+
+// Applied reports whether the migration has been applied
+func (s Status) Applied() bool {
+	return s.AppliedAt != nil
+}
+
+// Drifted reports whether the migration changed after it was applied
+func (s Status) Drifted() bool {
+	return s.Applied() && s.Checksum != "" && s.Checksum != s.AppliedChecksum
+}
+
+// Unknown reports whether the applied migration is missing from this build
+func (s Status) Unknown() bool {
+	return s.Applied() && s.Checksum == ""
+}
+
+// Runner applies migrations to a PostgreSQL database
+type Runner struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// NewRunner creates a runner for the migrations embedded in the binary
+func NewRunner(db *sql.DB) (*Runner, error) {
+	migrations, err := All()
+	if err != nil {
+		return nil, err
+	}
+	return NewRunnerWithMigrations(db, migrations), nil
+}
+
+// NewRunnerWithMigrations creates a runner for an explicit migration set,
+// which must be ordered by version
+func NewRunnerWithMigrations(db *sql.DB, migrations []Migration) *Runner {
+	return &Runner{db: db, migrations: migrations}
+}
+
+type appliedMigration struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// withLock runs fn on a single connection holding the migration lock, after
+// making sure the schema_migrations table exists
+func (r *Runner) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, advisoryLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, advisoryLockID)
+
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			checksum VARCHAR(64) NOT NULL,
+			applied_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	return fn(conn)
+}
+
+func (r *Runner) applied(ctx context.Context, conn *sql.Conn) (map[int64]appliedMigration, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]appliedMigration)
+	for rows.Next() {
+		var version int64
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.name, &a.checksum, &a.appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schema_migrations: %w", err)
+		}
+		applied[version] = a
+	}
+	return applied, rows.Err()
+}
+
+// verify fails if an applied migration was edited or is missing from this build
+func (r *Runner) verify(applied map[int64]appliedMigration) error {
+	known := make(map[int64]bool, len(r.migrations))
+	for _, m := range r.migrations {
+		known[m.Version] = true
+		if a, ok := applied[m.Version]; ok && a.checksum != m.Checksum {
+			return fmt.Errorf("%w: %d_%s", ErrChecksumMismatch, m.Version, m.Name)
+		}
+	}
+	for version, a := range applied {
+		if !known[version] {
+			return fmt.Errorf("%w: %d_%s", ErrUnknownVersion, version, a.name)
+		}
+	}
+	return nil
+}
+
+// Up applies pending migrations up to and including target, or all of them
+// when target is 0, and returns the migrations it applied. Each migration
+// runs in its own transaction.
+func (r *Runner) Up(ctx context.Context, target int64) ([]Migration, error) {
+	var done []Migration
+	err := r.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := r.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := r.verify(applied); err != nil {
+			return err
+		}
+
+		for _, m := range r.migrations {
+			if target > 0 && m.Version > target {
+				break
+			}
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			if err := r.run(ctx, conn, m, m.Up, `INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`,
+				m.Version, m.Name, m.Checksum); err != nil {
+				return err
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down rolls back the most recently applied steps migrations and returns them
+// in the order they were rolled back
+func (r *Runner) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := r.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := r.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := r.verify(applied); err != nil {
+			return err
+		}
+
+		for i := len(r.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			m := r.migrations[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			if m.Down == "" {
+				return fmt.Errorf("%w: %d_%s", ErrIrreversible, m.Version, m.Name)
+			}
+			if err := r.run(ctx, conn, m, m.Down, `DELETE FROM schema_migrations WHERE version = $1`, m.Version); err != nil {
+				return err
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// run executes a script and its bookkeeping statement in one transaction
+func (r *Runner) run(ctx context.Context, conn *sql.Conn, m Migration, script, record string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration %d_%s: %w", m.Version, m.Name, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("migration %d_%s failed: %w", m.Version, m.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return fmt.Errorf("failed to record migration %d_%s: %w", m.Version, m.Name, err)
+	}
+	return tx.Commit()
+}
+
+// Status lists every known migration along with any applied migrations
+// missing from this build, ordered by version
+func (r *Runner) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := r.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := r.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		statuses = r.status(applied)
+		return nil
+	})
+	return statuses, err
+}
+
+func (r *Runner) status(applied map[int64]appliedMigration) []Status {
+	statuses := make([]Status, 0, len(r.migrations))
+	seen := make(map[int64]bool, len(r.migrations))
+	for _, m := range r.migrations {
+		s := Status{Version: m.Version, Name: m.Name, Checksum: m.Checksum}
+		if a, ok := applied[m.Version]; ok {
+			appliedAt := a.appliedAt
+			s.AppliedAt = &appliedAt
+			s.AppliedChecksum = a.checksum
+		}
+		seen[m.Version] = true
+		statuses = append(statuses, s)
+	}
+
+	for version, a := range applied {
+		if seen[version] {
+			continue
+		}
+		appliedAt := a.appliedAt
+		statuses = append(statuses, Status{Version: version, Name: a.name, AppliedChecksum: a.checksum, AppliedAt: &appliedAt})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses
+}
+
+// Verify fails if an applied migration was edited or is missing from this
+// build. Pending migrations are not an error.
+func (r *Runner) Verify(ctx context.Context) error {
+	return r.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := r.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		return r.verify(applied)
+	})
+}
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS unique_visitor_sketches;
+DROP TABLE IF EXISTS url_destination_history;
+DROP TABLE IF EXISTS click_events;
+DROP TABLE IF EXISTS url_mappings;
@@ -0,0 +1,90 @@
+-- Core link storage: short links, click events, destination history and
+-- unique visitor sketches. Baseline migrations are written to be safe on
+-- databases created by the old startup schema or configs/*.sql files.
+
+CREATE TABLE IF NOT EXISTS url_mappings (
+    id BIGINT PRIMARY KEY,
+    short_code VARCHAR(10) UNIQUE NOT NULL,
+    original_url TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    expires_at TIMESTAMP WITH TIME ZONE,
+    click_count BIGINT DEFAULT 0,
+    is_active BOOLEAN DEFAULT TRUE,
+    created_by_ip INET
+);
+
+ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS redirect_type VARCHAR(20) DEFAULT '302';
+ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS destination_version INTEGER DEFAULT 1;
+ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS destination_updated_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS redirect_rules JSONB; -- ordered conditional redirect rules
+ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS password_hash VARCHAR(255); -- bcrypt hash for password-protected links
+ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS starts_at TIMESTAMPTZ; -- scheduled activation
+ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS max_clicks BIGINT; -- click cap, NULL means unlimited
+ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS fallback_url TEXT; -- destination once the click cap is reached
+ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS user_id BIGINT;
+ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS is_public BOOLEAN DEFAULT TRUE;
+ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS custom_alias VARCHAR(50);
+ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS title VARCHAR(200);
+ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS description TEXT;
+ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(); -- last metadata change
+
+CREATE INDEX IF NOT EXISTS idx_short_code ON url_mappings(short_code);
+CREATE INDEX IF NOT EXISTS idx_created_at ON url_mappings(created_at);
+CREATE INDEX IF NOT EXISTS idx_active ON url_mappings(is_active);
+CREATE INDEX IF NOT EXISTS idx_expires_at ON url_mappings(expires_at);
+CREATE INDEX IF NOT EXISTS idx_url_mappings_user_id ON url_mappings(user_id);
+CREATE INDEX IF NOT EXISTS idx_url_mappings_is_public ON url_mappings(is_public);
+
+CREATE TABLE IF NOT EXISTS click_events (
+    id BIGINT PRIMARY KEY,
+    short_code VARCHAR(10) NOT NULL,
+    clicked_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    ip_address INET,
+    user_agent TEXT,
+    referrer TEXT,
+    country_code CHAR(2)
+);
+
+ALTER TABLE click_events ADD COLUMN IF NOT EXISTS destination_version INTEGER;
+ALTER TABLE click_events ADD COLUMN IF NOT EXISTS traffic_class VARCHAR(10) DEFAULT 'human'; -- human, bot, preview or scanner
+
+CREATE INDEX IF NOT EXISTS idx_short_code_time ON click_events(short_code, clicked_at);
+CREATE INDEX IF NOT EXISTS idx_clicked_at ON click_events(clicked_at);
+CREATE INDEX IF NOT EXISTS idx_country_code ON click_events(country_code);
+CREATE INDEX IF NOT EXISTS idx_click_events_short_code_traffic ON click_events(short_code, traffic_class);
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.table_constraints
+        WHERE constraint_name = 'fk_click_events_short_code'
+    ) THEN
+        ALTER TABLE click_events ADD CONSTRAINT fk_click_events_short_code
+        FOREIGN KEY (short_code) REFERENCES url_mappings(short_code) ON DELETE CASCADE;
+    END IF;
+END $$;
+
+-- Append-only history of prior link destinations
+CREATE TABLE IF NOT EXISTS url_destination_history (
+    id BIGSERIAL PRIMARY KEY,
+    short_code VARCHAR(10) NOT NULL REFERENCES url_mappings(short_code) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    original_url TEXT NOT NULL,
+    active_from TIMESTAMP WITH TIME ZONE NOT NULL,
+    replaced_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    replaced_by BIGINT,
+    UNIQUE(short_code, version)
+);
+
+CREATE INDEX IF NOT EXISTS idx_destination_history_short_code ON url_destination_history(short_code);
+
+-- Persisted HyperLogLog unique visitor sketches (Redis is the primary copy)
+CREATE TABLE IF NOT EXISTS unique_visitor_sketches (
+    sketch_key VARCHAR(100) PRIMARY KEY,
+    short_code VARCHAR(50) NOT NULL,
+    day DATE, -- NULL for the link's lifetime sketch
+    sketch BYTEA NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_unique_visitor_sketches_short_code ON unique_visitor_sketches(short_code);
@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS audit_logs;
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS password_resets;
+DROP TABLE IF EXISTS phone_verifications;
+DROP TABLE IF EXISTS email_verifications;
+DROP TABLE IF EXISTS user_sessions;
+DROP TABLE IF EXISTS oauth_providers;
+DROP TABLE IF EXISTS user_preferences;
+ALTER TABLE url_mappings DROP CONSTRAINT IF EXISTS url_mappings_user_id_fkey;
+DROP TABLE IF EXISTS users;
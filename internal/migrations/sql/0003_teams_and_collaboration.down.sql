@@ -0,0 +1,27 @@
+DROP VIEW IF EXISTS urls;
+DROP TABLE IF EXISTS user_activity_logs;
+DROP TABLE IF EXISTS url_activities;
+DROP TABLE IF EXISTS url_notes;
+DROP TABLE IF EXISTS url_collection_items;
+DROP TABLE IF EXISTS url_collections;
+DROP TABLE IF EXISTS url_favorites;
+DROP TABLE IF EXISTS url_bookmarks;
+DROP TABLE IF EXISTS url_comments;
+DROP TABLE IF EXISTS url_shares;
+DROP TABLE IF EXISTS domain_analytics;
+DROP TABLE IF EXISTS custom_domains;
+ALTER TABLE url_mappings DROP COLUMN IF EXISTS team_id;
+ALTER TABLE url_mappings DROP COLUMN IF EXISTS tags;
+ALTER TABLE url_mappings DROP COLUMN IF EXISTS custom_domain;
+ALTER TABLE url_mappings DROP COLUMN IF EXISTS utm_source;
+ALTER TABLE url_mappings DROP COLUMN IF EXISTS utm_medium;
+ALTER TABLE url_mappings DROP COLUMN IF EXISTS utm_campaign;
+ALTER TABLE url_mappings DROP COLUMN IF EXISTS utm_term;
+ALTER TABLE url_mappings DROP COLUMN IF EXISTS utm_content;
+DROP TABLE IF EXISTS team_invitations;
+DROP TABLE IF EXISTS team_members;
+DROP TABLE IF EXISTS teams;
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS permissions;
+DROP TABLE IF EXISTS roles;
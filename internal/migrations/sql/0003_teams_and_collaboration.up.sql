@@ -1,139 +1,4 @@
--- Create database (this may already exist from Docker environment variables)
--- CREATE DATABASE urlshortener_db;
-
--- Create user (this may already exist from Docker environment variables)
--- CREATE USER urlshortener WITH PASSWORD 'password';
--- GRANT ALL PRIVILEGES ON DATABASE urlshortener_db TO urlshortener;
-
--- Connect to database
-\c urlshortener_db;
-
--- Create tables
-CREATE TABLE IF NOT EXISTS url_mappings (
-    id BIGINT PRIMARY KEY,
-    short_code VARCHAR(10) UNIQUE NOT NULL,
-    original_url TEXT NOT NULL,
-    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-    expires_at TIMESTAMP WITH TIME ZONE,
-    click_count BIGINT DEFAULT 0,
-    is_active BOOLEAN DEFAULT TRUE,
-    created_by_ip INET
-);
-
--- Create indexes for url_mappings
-CREATE INDEX IF NOT EXISTS idx_short_code ON url_mappings(short_code);
-CREATE INDEX IF NOT EXISTS idx_created_at ON url_mappings(created_at);
-CREATE INDEX IF NOT EXISTS idx_active ON url_mappings(is_active);
-CREATE INDEX IF NOT EXISTS idx_expires_at ON url_mappings(expires_at);
-
--- Create click events table
-CREATE TABLE IF NOT EXISTS click_events (
-    id BIGINT PRIMARY KEY,
-    short_code VARCHAR(10) NOT NULL,
-    clicked_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-    ip_address INET,
-    user_agent TEXT,
-    referrer TEXT,
-    country_code CHAR(2)
-);
-
--- Create indexes for click_events
-CREATE INDEX IF NOT EXISTS idx_short_code_time ON click_events(short_code, clicked_at);
-CREATE INDEX IF NOT EXISTS idx_clicked_at ON click_events(clicked_at);
-CREATE INDEX IF NOT EXISTS idx_country_code ON click_events(country_code);
-
--- Add foreign key constraint
-ALTER TABLE click_events 
-ADD CONSTRAINT fk_click_events_short_code 
-FOREIGN KEY (short_code) 
-REFERENCES url_mappings(short_code) 
-ON DELETE CASCADE;
-
--- User Management Tables
-
--- Users table
-CREATE TABLE IF NOT EXISTS users (
-    id BIGSERIAL PRIMARY KEY,
-    email VARCHAR(255) UNIQUE NOT NULL,
-    username VARCHAR(50) UNIQUE,
-    password_hash VARCHAR(255) NOT NULL,
-    first_name VARCHAR(100),
-    last_name VARCHAR(100),
-    phone VARCHAR(20),
-    avatar_url TEXT,
-    bio TEXT,
-    timezone VARCHAR(50) DEFAULT 'UTC',
-    language VARCHAR(10) DEFAULT 'en',
-    is_email_verified BOOLEAN DEFAULT FALSE,
-    is_phone_verified BOOLEAN DEFAULT FALSE,
-    is_active BOOLEAN DEFAULT TRUE,
-    email_verification_token VARCHAR(255),
-    email_verification_expires TIMESTAMP WITH TIME ZONE,
-    phone_verification_code VARCHAR(6),
-    phone_verification_expires TIMESTAMP WITH TIME ZONE,
-    password_reset_token VARCHAR(255),
-    password_reset_expires TIMESTAMP WITH TIME ZONE,
-    last_login_at TIMESTAMP WITH TIME ZONE,
-    last_login_ip INET,
-    failed_login_attempts INTEGER DEFAULT 0,
-    locked_until TIMESTAMP WITH TIME ZONE,
-    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
-);
-
--- User preferences table
-CREATE TABLE IF NOT EXISTS user_preferences (
-    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
-    theme VARCHAR(20) DEFAULT 'light',
-    notifications_email BOOLEAN DEFAULT TRUE,
-    notifications_push BOOLEAN DEFAULT TRUE,
-    notifications_sms BOOLEAN DEFAULT FALSE,
-    analytics_enabled BOOLEAN DEFAULT TRUE,
-    public_profile BOOLEAN DEFAULT FALSE,
-    custom_domain_limit INTEGER DEFAULT 0,
-    url_limit INTEGER DEFAULT 1000,
-    click_tracking BOOLEAN DEFAULT TRUE,
-    password_expires_at TIMESTAMP WITH TIME ZONE,
-    two_factor_enabled BOOLEAN DEFAULT FALSE,
-    two_factor_secret VARCHAR(32),
-    backup_codes JSONB,
-    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
-);
-
--- OAuth providers table
-CREATE TABLE IF NOT EXISTS oauth_providers (
-    id BIGSERIAL PRIMARY KEY,
-    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
-    provider VARCHAR(50) NOT NULL,
-    provider_user_id VARCHAR(255) NOT NULL,
-    provider_email VARCHAR(255),
-    access_token TEXT,
-    refresh_token TEXT,
-    expires_at TIMESTAMP WITH TIME ZONE,
-    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-    UNIQUE(provider, provider_user_id)
-);
-
--- User sessions table
-CREATE TABLE IF NOT EXISTS user_sessions (
-    id VARCHAR(128) PRIMARY KEY,
-    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
-    ip_address INET,
-    user_agent TEXT,
-    device_type VARCHAR(20),
-    browser VARCHAR(50),
-    platform VARCHAR(50),
-    location VARCHAR(100),
-    is_mobile BOOLEAN DEFAULT FALSE,
-    screen_resolution VARCHAR(20),
-    timezone VARCHAR(50),
-    started_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-    ended_at TIMESTAMP WITH TIME ZONE,
-    duration_minutes INTEGER,
-    is_active BOOLEAN DEFAULT TRUE
-);
+-- Roles and permissions, teams, custom domains and collaboration features.
 
 -- RBAC Tables
 
@@ -225,14 +90,8 @@ CREATE TABLE IF NOT EXISTS team_invitations (
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
 
--- Update URL mappings table to support user ownership
-ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS user_id BIGINT REFERENCES users(id);
 ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS team_id BIGINT REFERENCES teams(id);
-ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS title VARCHAR(255);
-ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS description TEXT;
 ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS tags JSONB DEFAULT '[]';
-ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS is_public BOOLEAN DEFAULT TRUE;
-ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS password_hash VARCHAR(255);
 ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS custom_domain VARCHAR(100);
 ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS utm_source VARCHAR(100);
 ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS utm_medium VARCHAR(100);
@@ -240,8 +99,7 @@ ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS utm_campaign VARCHAR(100);
 ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS utm_term VARCHAR(100);
 ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS utm_content VARCHAR(100);
 
--- Create an alias for the url_mappings table as 'urls' for consistency
-CREATE VIEW IF NOT EXISTS urls AS SELECT * FROM url_mappings;
+CREATE INDEX IF NOT EXISTS idx_url_mappings_team_id ON url_mappings(team_id);
 
 -- Custom Domains Tables
 
@@ -409,41 +267,6 @@ CREATE TABLE IF NOT EXISTS user_activity_logs (
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
 
--- Audit logs table
-CREATE TABLE IF NOT EXISTS audit_logs (
-    id BIGSERIAL PRIMARY KEY,
-    user_id BIGINT REFERENCES users(id),
-    action VARCHAR(100) NOT NULL,
-    resource_type VARCHAR(50),
-    resource_id VARCHAR(100),
-    old_values JSONB,
-    new_values JSONB,
-    metadata JSONB DEFAULT '{}',
-    ip_address INET,
-    user_agent TEXT,
-    success BOOLEAN DEFAULT TRUE,
-    error_message TEXT,
-    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
-);
-
--- Create indexes for performance
-
--- User table indexes
-CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
-CREATE INDEX IF NOT EXISTS idx_users_username ON users(username);
-CREATE INDEX IF NOT EXISTS idx_users_is_active ON users(is_active);
-CREATE INDEX IF NOT EXISTS idx_users_created_at ON users(created_at);
-
--- User sessions indexes
-CREATE INDEX IF NOT EXISTS idx_user_sessions_user_id ON user_sessions(user_id);
-CREATE INDEX IF NOT EXISTS idx_user_sessions_started_at ON user_sessions(started_at);
-CREATE INDEX IF NOT EXISTS idx_user_sessions_active ON user_sessions(is_active);
-
--- URL mappings indexes (additional to existing ones)
-CREATE INDEX IF NOT EXISTS idx_url_mappings_user_id ON url_mappings(user_id);
-CREATE INDEX IF NOT EXISTS idx_url_mappings_team_id ON url_mappings(team_id);
-CREATE INDEX IF NOT EXISTS idx_url_mappings_is_public ON url_mappings(is_public);
-
 -- Team indexes
 CREATE INDEX IF NOT EXISTS idx_teams_owner_id ON teams(owner_id);
 CREATE INDEX IF NOT EXISTS idx_teams_slug ON teams(slug);
@@ -469,9 +292,9 @@ CREATE INDEX IF NOT EXISTS idx_user_activity_logs_user_id ON user_activity_logs(
 CREATE INDEX IF NOT EXISTS idx_user_activity_logs_session_id ON user_activity_logs(session_id);
 CREATE INDEX IF NOT EXISTS idx_user_activity_logs_activity_type ON user_activity_logs(activity_type);
 CREATE INDEX IF NOT EXISTS idx_user_activity_logs_created_at ON user_activity_logs(created_at);
-CREATE INDEX IF NOT EXISTS idx_audit_logs_user_id ON audit_logs(user_id);
-CREATE INDEX IF NOT EXISTS idx_audit_logs_action ON audit_logs(action);
-CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs(created_at);
+
+-- Alias of url_mappings used by the user analytics queries
+CREATE OR REPLACE VIEW urls AS SELECT * FROM url_mappings;
 
 -- Insert default roles and permissions
 INSERT INTO roles (name, display_name, description, is_system) VALUES
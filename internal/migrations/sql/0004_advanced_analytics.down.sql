@@ -0,0 +1,25 @@
+DROP FUNCTION IF EXISTS cleanup_old_analytics_data(INTEGER);
+DROP TABLE IF EXISTS attribution_model_performance;
+DROP TABLE IF EXISTS touchpoint_attributions;
+DROP FUNCTION IF EXISTS refresh_daily_analytics_summary();
+DROP MATERIALIZED VIEW IF EXISTS daily_analytics_summary;
+DROP INDEX IF EXISTS idx_click_events_session_id;
+DROP INDEX IF EXISTS idx_click_events_utm_source;
+ALTER TABLE click_events DROP COLUMN IF EXISTS session_id;
+ALTER TABLE click_events DROP COLUMN IF EXISTS device_fingerprint;
+ALTER TABLE click_events DROP COLUMN IF EXISTS utm_source;
+ALTER TABLE click_events DROP COLUMN IF EXISTS utm_medium;
+ALTER TABLE click_events DROP COLUMN IF EXISTS utm_campaign;
+ALTER TABLE click_events DROP COLUMN IF EXISTS utm_term;
+ALTER TABLE click_events DROP COLUMN IF EXISTS utm_content;
+DROP TABLE IF EXISTS realtime_subscriptions;
+DROP TABLE IF EXISTS ab_test_results;
+DROP TABLE IF EXISTS ab_test_variants;
+DROP TABLE IF EXISTS ab_tests;
+DROP TABLE IF EXISTS attribution_touchpoints;
+DROP TABLE IF EXISTS conversions;
+DROP TABLE IF EXISTS conversion_goals;
+DROP TABLE IF EXISTS referrer_analytics;
+DROP TABLE IF EXISTS time_analytics;
+DROP TABLE IF EXISTS device_analytics;
+DROP TABLE IF EXISTS geographic_analytics;
@@ -1,129 +1,106 @@
--- Enhanced Analytics Schema for URL Shortener
--- This extends the existing database with advanced analytics tables
+-- Advanced analytics: aggregated geographic, device, time and referrer
+-- stats, conversion tracking, A/B tests and multi-touch attribution.
 
--- Geographic Analytics Table
+-- Enhanced geographic analytics table
 CREATE TABLE IF NOT EXISTS geographic_analytics (
     id BIGSERIAL PRIMARY KEY,
-    short_code VARCHAR(50) NOT NULL,
-    country_code VARCHAR(2) NOT NULL,
-    country_name VARCHAR(100) NOT NULL,
+    short_code VARCHAR(10) NOT NULL,
+    country_code CHAR(2),
+    country_name VARCHAR(100),
     region VARCHAR(100),
     city VARCHAR(100),
-    latitude DECIMAL(10, 8),
-    longitude DECIMAL(11, 8),
-    clicks BIGINT DEFAULT 0,
-    unique_ips BIGINT DEFAULT 0,
-    last_click TIMESTAMP WITH TIME ZONE,
+    latitude DECIMAL(10,8),
+    longitude DECIMAL(11,8),
+    clicks INTEGER DEFAULT 1,
+    unique_ips INTEGER DEFAULT 1,
+    last_click TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-    UNIQUE(short_code, country_code, region, city)
+    UNIQUE(short_code, country_code, region, city),
+    FOREIGN KEY (short_code) REFERENCES url_mappings(short_code) ON DELETE CASCADE
 );
+CREATE INDEX IF NOT EXISTS idx_geographic_short_code ON geographic_analytics(short_code);
+CREATE INDEX IF NOT EXISTS idx_geographic_country ON geographic_analytics(country_code);
+CREATE INDEX IF NOT EXISTS idx_geographic_location ON geographic_analytics(country_code, region, city);
 
--- Indexes for geographic analytics
-CREATE INDEX IF NOT EXISTS idx_geographic_analytics_short_code ON geographic_analytics(short_code);
-CREATE INDEX IF NOT EXISTS idx_geographic_analytics_country ON geographic_analytics(country_code);
-CREATE INDEX IF NOT EXISTS idx_geographic_analytics_last_click ON geographic_analytics(last_click);
-
--- Time-based Analytics Table
-CREATE TABLE IF NOT EXISTS time_analytics (
-    id BIGSERIAL PRIMARY KEY,
-    short_code VARCHAR(50) NOT NULL,
-    click_date DATE NOT NULL,
-    hour_of_day INTEGER NOT NULL CHECK (hour_of_day >= 0 AND hour_of_day <= 23),
-    day_of_week INTEGER NOT NULL CHECK (day_of_week >= 0 AND day_of_week <= 6),
-    day_of_month INTEGER NOT NULL CHECK (day_of_month >= 1 AND day_of_month <= 31),
-    month INTEGER NOT NULL CHECK (month >= 1 AND month <= 12),
-    year INTEGER NOT NULL,
-    clicks BIGINT DEFAULT 0,
-    unique_ips BIGINT DEFAULT 0,
-    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-    UNIQUE(short_code, click_date, hour_of_day)
-);
-
--- Indexes for time analytics
-CREATE INDEX IF NOT EXISTS idx_time_analytics_short_code ON time_analytics(short_code);
-CREATE INDEX IF NOT EXISTS idx_time_analytics_date ON time_analytics(click_date);
-CREATE INDEX IF NOT EXISTS idx_time_analytics_hour ON time_analytics(hour_of_day);
-
--- Device Analytics Table
+-- Enhanced device analytics table
 CREATE TABLE IF NOT EXISTS device_analytics (
     id BIGSERIAL PRIMARY KEY,
-    short_code VARCHAR(50) NOT NULL,
-    device_type VARCHAR(50) NOT NULL, -- mobile, tablet, desktop
-    device_brand VARCHAR(100),
-    device_model VARCHAR(200),
-    os_name VARCHAR(100),
+    short_code VARCHAR(10) NOT NULL,
+    device_type VARCHAR(50), -- mobile, desktop, tablet
+    device_brand VARCHAR(50), -- Apple, Samsung, etc.
+    device_model VARCHAR(100),
+    os_name VARCHAR(50),
     os_version VARCHAR(50),
-    browser_name VARCHAR(100),
+    browser_name VARCHAR(50),
     browser_version VARCHAR(50),
     screen_resolution VARCHAR(20),
-    user_agent_hash VARCHAR(64) NOT NULL, -- SHA-256 hash for uniqueness
-    clicks BIGINT DEFAULT 0,
-    unique_ips BIGINT DEFAULT 0,
-    last_click TIMESTAMP WITH TIME ZONE,
+    user_agent_hash VARCHAR(64), -- SHA-256 hash for grouping
+    clicks INTEGER DEFAULT 1,
+    unique_ips INTEGER DEFAULT 1,
+    last_click TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-    UNIQUE(short_code, user_agent_hash)
+    UNIQUE(short_code, user_agent_hash),
+    FOREIGN KEY (short_code) REFERENCES url_mappings(short_code) ON DELETE CASCADE
 );
+CREATE INDEX IF NOT EXISTS idx_device_short_code ON device_analytics(short_code);
+CREATE INDEX IF NOT EXISTS idx_device_type ON device_analytics(device_type);
+CREATE INDEX IF NOT EXISTS idx_device_brand ON device_analytics(device_brand, device_model);
+CREATE INDEX IF NOT EXISTS idx_device_os ON device_analytics(os_name, os_version);
+CREATE INDEX IF NOT EXISTS idx_device_browser ON device_analytics(browser_name, browser_version);
 
--- Indexes for device analytics
-CREATE INDEX IF NOT EXISTS idx_device_analytics_short_code ON device_analytics(short_code);
-CREATE INDEX IF NOT EXISTS idx_device_analytics_type ON device_analytics(device_type);
-CREATE INDEX IF NOT EXISTS idx_device_analytics_browser ON device_analytics(browser_name);
-CREATE INDEX IF NOT EXISTS idx_device_analytics_os ON device_analytics(os_name);
+-- Time-based analytics table
+CREATE TABLE IF NOT EXISTS time_analytics (
+    id BIGSERIAL PRIMARY KEY,
+    short_code VARCHAR(10) NOT NULL,
+    click_date DATE NOT NULL,
+    hour_of_day INTEGER CHECK (hour_of_day >= 0 AND hour_of_day <= 23),
+    day_of_week INTEGER CHECK (day_of_week >= 0 AND day_of_week <= 6), -- 0=Sunday
+    day_of_month INTEGER CHECK (day_of_month >= 1 AND day_of_month <= 31),
+    month INTEGER CHECK (month >= 1 AND month <= 12),
+    year INTEGER,
+    clicks INTEGER DEFAULT 1,
+    unique_ips INTEGER DEFAULT 1,
+    conversions INTEGER DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    FOREIGN KEY (short_code) REFERENCES url_mappings(short_code) ON DELETE CASCADE,
+    UNIQUE(short_code, click_date, hour_of_day)
+);
+CREATE INDEX IF NOT EXISTS idx_time_short_code ON time_analytics(short_code);
+CREATE INDEX IF NOT EXISTS idx_time_date ON time_analytics(click_date);
+CREATE INDEX IF NOT EXISTS idx_time_hour ON time_analytics(hour_of_day);
+CREATE INDEX IF NOT EXISTS idx_time_day_week ON time_analytics(day_of_week);
+CREATE INDEX IF NOT EXISTS idx_time_heatmap ON time_analytics(short_code, click_date, hour_of_day);
 
--- Enhanced Referrer Analytics Table
+-- Enhanced referrer analytics table
 CREATE TABLE IF NOT EXISTS referrer_analytics (
     id BIGSERIAL PRIMARY KEY,
-    short_code VARCHAR(50) NOT NULL,
+    short_code VARCHAR(10) NOT NULL,
     referrer_domain VARCHAR(255),
     referrer_url TEXT,
-    referrer_type VARCHAR(50) NOT NULL, -- direct, search, social, email, campaign, referral
-    campaign_source VARCHAR(255),
-    campaign_medium VARCHAR(255),
-    campaign_name VARCHAR(255),
-    campaign_term VARCHAR(255),
-    campaign_content VARCHAR(255),
-    clicks BIGINT DEFAULT 0,
-    unique_clicks BIGINT DEFAULT 0,
-    conversions BIGINT DEFAULT 0,
-    last_click TIMESTAMP WITH TIME ZONE,
+    referrer_type VARCHAR(50), -- social, search, direct, email, etc.
+    campaign_source VARCHAR(100), -- UTM tracking
+    campaign_medium VARCHAR(100),
+    campaign_name VARCHAR(100),
+    campaign_term VARCHAR(100),
+    campaign_content VARCHAR(100),
+    clicks INTEGER DEFAULT 1,
+    unique_clicks INTEGER DEFAULT 1,
+    conversions INTEGER DEFAULT 0,
+    last_click TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-    UNIQUE(short_code, referrer_domain, campaign_source, campaign_medium)
+    UNIQUE(short_code, referrer_domain, campaign_source, campaign_medium),
+    FOREIGN KEY (short_code) REFERENCES url_mappings(short_code) ON DELETE CASCADE
 );
+CREATE INDEX IF NOT EXISTS idx_referrer_short_code ON referrer_analytics(short_code);
+CREATE INDEX IF NOT EXISTS idx_referrer_domain ON referrer_analytics(referrer_domain);
+CREATE INDEX IF NOT EXISTS idx_referrer_type ON referrer_analytics(referrer_type);
+CREATE INDEX IF NOT EXISTS idx_referrer_campaign ON referrer_analytics(campaign_source, campaign_medium);
 
--- Indexes for referrer analytics
-CREATE INDEX IF NOT EXISTS idx_referrer_analytics_short_code ON referrer_analytics(short_code);
-CREATE INDEX IF NOT EXISTS idx_referrer_analytics_type ON referrer_analytics(referrer_type);
-CREATE INDEX IF NOT EXISTS idx_referrer_analytics_domain ON referrer_analytics(referrer_domain);
-CREATE INDEX IF NOT EXISTS idx_referrer_analytics_campaign ON referrer_analytics(campaign_source, campaign_medium);
-
--- Attribution Touchpoints Table
-CREATE TABLE IF NOT EXISTS attribution_touchpoints (
-    id BIGSERIAL PRIMARY KEY,
-    session_id VARCHAR(64) NOT NULL,
-    short_code VARCHAR(50) NOT NULL,
-    user_ip VARCHAR(45),
-    user_agent TEXT,
-    referrer TEXT,
-    campaign_source VARCHAR(255),
-    campaign_medium VARCHAR(255),
-    campaign_name VARCHAR(255),
-    touchpoint_order INTEGER NOT NULL,
-    touchpoint_time TIMESTAMP WITH TIME ZONE NOT NULL,
-    conversion_id VARCHAR(64),
-    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
-);
-
--- Indexes for attribution touchpoints
-CREATE INDEX IF NOT EXISTS idx_attribution_touchpoints_session ON attribution_touchpoints(session_id);
-CREATE INDEX IF NOT EXISTS idx_attribution_touchpoints_short_code ON attribution_touchpoints(short_code);
-CREATE INDEX IF NOT EXISTS idx_attribution_touchpoints_conversion ON attribution_touchpoints(conversion_id);
-CREATE INDEX IF NOT EXISTS idx_attribution_touchpoints_time ON attribution_touchpoints(touchpoint_time);
-
--- Conversion Goals Table (extend existing)
+-- Conversion tracking tables
 CREATE TABLE IF NOT EXISTS conversion_goals (
     id BIGSERIAL PRIMARY KEY,
     user_id BIGINT NOT NULL,
@@ -131,43 +108,76 @@ CREATE TABLE IF NOT EXISTS conversion_goals (
     goal_type VARCHAR(50) NOT NULL, -- url_visit, custom_event, form_submit, purchase
     target_url TEXT,
     custom_event_name VARCHAR(100),
-    goal_value DECIMAL(12, 2) DEFAULT 0,
+    goal_value DECIMAL(10,2) DEFAULT 0, -- monetary value if applicable
     attribution_window INTEGER DEFAULT 30, -- days
-    is_active BOOLEAN DEFAULT true,
+    is_active BOOLEAN DEFAULT TRUE,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
 );
-
--- Indexes for conversion goals
-CREATE INDEX IF NOT EXISTS idx_conversion_goals_user_id ON conversion_goals(user_id);
+CREATE INDEX IF NOT EXISTS idx_conversion_goals_user ON conversion_goals(user_id);
 CREATE INDEX IF NOT EXISTS idx_conversion_goals_active ON conversion_goals(is_active);
 
--- Conversions Table (extend existing)
+-- Conversions tracking table
 CREATE TABLE IF NOT EXISTS conversions (
     id BIGSERIAL PRIMARY KEY,
-    short_code VARCHAR(50) NOT NULL,
-    goal_id BIGINT NOT NULL REFERENCES conversion_goals(id),
-    conversion_id VARCHAR(64) NOT NULL UNIQUE,
+    short_code VARCHAR(10) NOT NULL,
+    goal_id BIGINT NOT NULL,
+    conversion_id VARCHAR(100) UNIQUE NOT NULL, -- unique identifier for deduplication
     conversion_type VARCHAR(50) NOT NULL,
-    conversion_value DECIMAL(12, 2) DEFAULT 0,
-    user_ip VARCHAR(45),
+    conversion_value DECIMAL(10,2) DEFAULT 0,
+    user_ip INET,
     user_agent TEXT,
     referrer TEXT,
-    session_id VARCHAR(64),
-    click_id BIGINT,
-    conversion_time TIMESTAMP WITH TIME ZONE NOT NULL,
-    attribution_model VARCHAR(50) DEFAULT 'last_click',
-    time_to_conversion INTEGER, -- minutes from first touch
-    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+    session_id VARCHAR(100), -- for session tracking
+    click_id BIGINT, -- link to original click event
+    conversion_time TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    attribution_model VARCHAR(50) DEFAULT 'last_click', -- first_click, last_click, linear
+    time_to_conversion INTEGER, -- minutes from click to conversion
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    FOREIGN KEY (short_code) REFERENCES url_mappings(short_code) ON DELETE CASCADE,
+    FOREIGN KEY (goal_id) REFERENCES conversion_goals(id) ON DELETE CASCADE
 );
-
--- Indexes for conversions
 CREATE INDEX IF NOT EXISTS idx_conversions_short_code ON conversions(short_code);
-CREATE INDEX IF NOT EXISTS idx_conversions_goal_id ON conversions(goal_id);
-CREATE INDEX IF NOT EXISTS idx_conversions_session ON conversions(session_id);
+CREATE INDEX IF NOT EXISTS idx_conversions_goal ON conversions(goal_id);
 CREATE INDEX IF NOT EXISTS idx_conversions_time ON conversions(conversion_time);
+CREATE INDEX IF NOT EXISTS idx_conversions_session ON conversions(session_id);
+
+-- Attribution tracking for better conversion analysis
+CREATE TABLE IF NOT EXISTS attribution_touchpoints (
+    id BIGSERIAL PRIMARY KEY,
+    session_id VARCHAR(100) NOT NULL,
+    short_code VARCHAR(10) NOT NULL,
+    user_ip INET,
+    user_agent TEXT,
+    referrer TEXT,
+    campaign_source VARCHAR(100),
+    campaign_medium VARCHAR(100),
+    campaign_name VARCHAR(100),
+    touchpoint_order INTEGER NOT NULL, -- 1st touch, 2nd touch, etc.
+    touchpoint_time TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    conversion_id VARCHAR(100), -- link to final conversion if exists
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    FOREIGN KEY (short_code) REFERENCES url_mappings(short_code) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_attribution_session ON attribution_touchpoints(session_id);
+CREATE INDEX IF NOT EXISTS idx_attribution_conversion ON attribution_touchpoints(conversion_id);
+CREATE INDEX IF NOT EXISTS idx_attribution_time ON attribution_touchpoints(touchpoint_time);
+
+-- The startup schema used to create a two-variant ab_tests layout that the
+-- A/B testing service never wrote to; replace it with the variant-based one.
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'ab_tests' AND column_name = 'short_code_a'
+    ) THEN
+        DROP TABLE IF EXISTS ab_test_results;
+        DROP TABLE ab_tests;
+    END IF;
+END $$;
 
--- A/B Testing Tables (extend existing)
+-- A/B testing tables
 CREATE TABLE IF NOT EXISTS ab_tests (
     id BIGSERIAL PRIMARY KEY,
     user_id BIGINT NOT NULL,
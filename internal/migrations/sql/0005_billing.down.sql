@@ -0,0 +1,15 @@
+DROP INDEX IF EXISTS idx_users_stripe_customer_id;
+ALTER TABLE users DROP COLUMN IF EXISTS stripe_customer_id;
+ALTER TABLE users DROP COLUMN IF EXISTS preferred_plan;
+DROP FUNCTION IF EXISTS refresh_billing_analytics();
+DROP MATERIALIZED VIEW IF EXISTS billing_analytics;
+DROP TRIGGER IF EXISTS trigger_create_default_subscription ON users;
+DROP FUNCTION IF EXISTS create_default_subscription();
+DROP FUNCTION IF EXISTS get_current_month_usage(BIGINT);
+DROP FUNCTION IF EXISTS update_usage_tracking(BIGINT, DATE, INTEGER, INTEGER, INTEGER, INTEGER, INTEGER);
+DROP TABLE IF EXISTS coupon_redemptions;
+DROP TABLE IF EXISTS coupons;
+DROP TABLE IF EXISTS payment_methods;
+DROP TABLE IF EXISTS usage_tracking;
+DROP TABLE IF EXISTS billing_events;
+DROP TABLE IF EXISTS subscriptions;
@@ -1,9 +1,9 @@
--- Billing and Subscription Management Schema
--- Add to the existing database schema
+-- Billing: subscriptions, billing events, usage tracking, payment methods
+-- and coupons.
 
 -- Subscriptions table
 CREATE TABLE IF NOT EXISTS subscriptions (
-    id BIGINT PRIMARY KEY DEFAULT generate_snowflake_id(),
+    id BIGSERIAL PRIMARY KEY,
     user_id BIGINT NOT NULL,
     plan_type VARCHAR(50) NOT NULL DEFAULT 'free',
     status VARCHAR(50) NOT NULL DEFAULT 'active',
@@ -29,7 +29,7 @@ CREATE INDEX IF NOT EXISTS idx_subscriptions_plan_type ON subscriptions(plan_typ
 
 -- Billing events table for tracking billing-related events
 CREATE TABLE IF NOT EXISTS billing_events (
-    id BIGINT PRIMARY KEY DEFAULT generate_snowflake_id(),
+    id BIGSERIAL PRIMARY KEY,
     user_id BIGINT NOT NULL,
     subscription_id BIGINT,
     event_type VARCHAR(100) NOT NULL, -- subscription_created, payment_succeeded, etc.
@@ -54,7 +54,7 @@ CREATE INDEX IF NOT EXISTS idx_billing_events_created_at ON billing_events(creat
 
 -- Usage tracking table for monitoring user usage patterns
 CREATE TABLE IF NOT EXISTS usage_tracking (
-    id BIGINT PRIMARY KEY DEFAULT generate_snowflake_id(),
+    id BIGSERIAL PRIMARY KEY,
     user_id BIGINT NOT NULL,
     tracking_period DATE NOT NULL, -- YYYY-MM-DD format for monthly tracking
     urls_created INTEGER DEFAULT 0,
@@ -75,7 +75,7 @@ CREATE INDEX IF NOT EXISTS idx_usage_tracking_user_period ON usage_tracking(user
 
 -- Payment methods table (for storing card details from Stripe)
 CREATE TABLE IF NOT EXISTS payment_methods (
-    id BIGINT PRIMARY KEY DEFAULT generate_snowflake_id(),
+    id BIGSERIAL PRIMARY KEY,
     user_id BIGINT NOT NULL,
     stripe_payment_method_id VARCHAR(255) NOT NULL,
     type VARCHAR(50) NOT NULL, -- card, bank_account, etc.
@@ -97,7 +97,7 @@ CREATE INDEX IF NOT EXISTS idx_payment_methods_stripe_pm_id ON payment_methods(s
 
 -- Coupons and discounts table
 CREATE TABLE IF NOT EXISTS coupons (
-    id BIGINT PRIMARY KEY DEFAULT generate_snowflake_id(),
+    id BIGSERIAL PRIMARY KEY,
     code VARCHAR(50) UNIQUE NOT NULL,
     name VARCHAR(100) NOT NULL,
     description TEXT,
@@ -121,7 +121,7 @@ CREATE INDEX IF NOT EXISTS idx_coupons_valid_until ON coupons(valid_until);
 
 -- Coupon redemptions table
 CREATE TABLE IF NOT EXISTS coupon_redemptions (
-    id BIGINT PRIMARY KEY DEFAULT generate_snowflake_id(),
+    id BIGSERIAL PRIMARY KEY,
     coupon_id BIGINT NOT NULL,
     user_id BIGINT NOT NULL,
     subscription_id BIGINT,
@@ -275,4 +275,4 @@ BEGIN
     ) THEN
         ALTER TABLE users ADD COLUMN preferred_plan VARCHAR(50) DEFAULT 'free';
     END IF;
-END $$;
\ No newline at end of file
+END $$;
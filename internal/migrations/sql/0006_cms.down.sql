@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS navigation_menus;
+DROP TABLE IF EXISTS page_templates;
+DROP TABLE IF EXISTS content_blocks;
+DROP TABLE IF EXISTS global_settings;
+DROP TABLE IF EXISTS api_key_usage;
+DROP TABLE IF EXISTS page_visits;
+DROP TABLE IF EXISTS page_revisions;
+DROP TABLE IF EXISTS static_pages;
+DROP TABLE IF EXISTS media_files;
+DELETE FROM permissions WHERE name IN ('cms.read', 'cms.create', 'cms.update', 'cms.delete', 'cms.publish', 'api_key.read', 'api_key.create', 'api_key.delete');
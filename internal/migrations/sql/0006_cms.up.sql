@@ -1,4 +1,5 @@
--- CMS Tables for static page management and API keys
+-- CMS: static pages, media, global settings, content blocks, templates,
+-- navigation menus and API key usage tracking.
 
 -- Static pages table for CMS
 CREATE TABLE IF NOT EXISTS static_pages (
@@ -32,24 +33,6 @@ CREATE TABLE IF NOT EXISTS static_pages (
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
 
--- API Keys table for programmatic access
-CREATE TABLE IF NOT EXISTS api_keys (
-    id BIGSERIAL PRIMARY KEY,
-    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
-    key_hash VARCHAR(255) UNIQUE NOT NULL,
-    key_prefix VARCHAR(20) NOT NULL,
-    name VARCHAR(100) NOT NULL,
-    description TEXT,
-    permissions JSONB DEFAULT '[]',
-    rate_limit INTEGER DEFAULT 1000,
-    last_used_at TIMESTAMP WITH TIME ZONE,
-    last_used_ip INET,
-    expires_at TIMESTAMP WITH TIME ZONE,
-    is_active BOOLEAN DEFAULT TRUE,
-    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
-);
-
 -- Page revisions for version control
 CREATE TABLE IF NOT EXISTS page_revisions (
     id BIGSERIAL PRIMARY KEY,
@@ -95,10 +78,6 @@ CREATE INDEX IF NOT EXISTS idx_static_pages_published ON static_pages(is_publish
 CREATE INDEX IF NOT EXISTS idx_static_pages_sort_order ON static_pages(sort_order);
 CREATE INDEX IF NOT EXISTS idx_static_pages_author_id ON static_pages(author_id);
 
-CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys(user_id);
-CREATE INDEX IF NOT EXISTS idx_api_keys_key_hash ON api_keys(key_hash);
-CREATE INDEX IF NOT EXISTS idx_api_keys_active ON api_keys(is_active);
-CREATE INDEX IF NOT EXISTS idx_api_keys_expires_at ON api_keys(expires_at);
 
 CREATE INDEX IF NOT EXISTS idx_page_revisions_page_id ON page_revisions(page_id);
 CREATE INDEX IF NOT EXISTS idx_page_visits_page_id ON page_visits(page_id);
@@ -203,8 +182,16 @@ CREATE INDEX IF NOT EXISTS idx_navigation_menus_location ON navigation_menus(loc
 CREATE INDEX IF NOT EXISTS idx_navigation_menus_is_active ON navigation_menus(is_active);
 
 -- Add foreign key for featured image
-ALTER TABLE static_pages ADD CONSTRAINT fk_static_pages_featured_image 
-    FOREIGN KEY (featured_image_id) REFERENCES media_files(id) ON DELETE SET NULL;
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.table_constraints
+        WHERE constraint_name = 'fk_static_pages_featured_image'
+    ) THEN
+        ALTER TABLE static_pages ADD CONSTRAINT fk_static_pages_featured_image
+        FOREIGN KEY (featured_image_id) REFERENCES media_files(id) ON DELETE SET NULL;
+    END IF;
+END $$;
 
 -- Insert default static pages
 INSERT INTO static_pages (slug, title, content, meta_description, is_published, sort_order, published_at) VALUES
@@ -224,7 +211,7 @@ INSERT INTO static_pages (slug, title, content, meta_description, is_published,
     
     ('status', 'Service Status', '<h1>Service Status</h1><p>Current status of Trunc services and infrastructure.</p><div class="status-dashboard"><div class="status-item operational"><h3>URL Shortening Service</h3><p class="status">Operational</p><p class="uptime">99.9% uptime (last 30 days)</p></div><div class="status-item operational"><h3>Analytics Dashboard</h3><p class="status">Operational</p><p class="uptime">99.8% uptime (last 30 days)</p></div><div class="status-item operational"><h3>API Service</h3><p class="status">Operational</p><p class="uptime">99.9% uptime (last 30 days)</p></div><div class="status-item operational"><h3>Database</h3><p class="status">Operational</p><p class="uptime">100% uptime (last 30 days)</p></div></div><h2>Recent Incidents</h2><p>No incidents reported in the last 30 days.</p><h2>Scheduled Maintenance</h2><p>No scheduled maintenance at this time.</p><p><em>Last updated: ' || TO_CHAR(NOW(), 'Month DD, YYYY at HH24:MI UTC') || '</em></p>', 'Check the current operational status of Trunc services including uptime statistics and incident reports.', true, 6, NOW()),
     
-    ('blog', 'Blog', '<h1>Trunc Blog</h1><p>Stay updated with the latest news, features, and tips from Trunc.</p><div class="blog-posts"><article class="blog-post"><h2><a href="/blog/welcome-to-trunc">Welcome to Trunc</a></h2><p class="post-meta">Published on ' || TO_CHAR(NOW() - INTERVAL ''7 days'', 'Month DD, YYYY') || '</p><p>We''re excited to launch Trunc, a powerful and reliable URL shortening service designed for modern web users and businesses...</p><a href="/blog/welcome-to-trunc" class="read-more">Read more</a></article><article class="blog-post"><h2><a href="/blog/introducing-analytics">Introducing Advanced Analytics</a></h2><p class="post-meta">Published on ' || TO_CHAR(NOW() - INTERVAL ''14 days'', 'Month DD, YYYY') || '</p><p>Track your link performance like never before with our new advanced analytics dashboard featuring geographic insights, device analytics, and more...</p><a href="/blog/introducing-analytics" class="read-more">Read more</a></article><article class="blog-post"><h2><a href="/blog/api-launch">API Now Available</a></h2><p class="post-meta">Published on ' || TO_CHAR(NOW() - INTERVAL ''21 days'', 'Month DD, YYYY') || '</p><p>Integrate Trunc into your applications with our comprehensive REST API. Generate API keys and start building today...</p><a href="/blog/api-launch" class="read-more">Read more</a></article></div><p><a href="/blog/archive">View all blog posts →</a></p>', 'Trunc blog - Latest news, feature announcements, and tips for getting the most out of our URL shortening service.', true, 7, NOW()),
+    ('blog', 'Blog', '<h1>Trunc Blog</h1><p>Stay updated with the latest news, features, and tips from Trunc.</p><div class="blog-posts"><article class="blog-post"><h2><a href="/blog/welcome-to-trunc">Welcome to Trunc</a></h2><p class="post-meta">Published on ' || TO_CHAR(NOW() - INTERVAL '7 days', 'Month DD, YYYY') || '</p><p>We''re excited to launch Trunc, a powerful and reliable URL shortening service designed for modern web users and businesses...</p><a href="/blog/welcome-to-trunc" class="read-more">Read more</a></article><article class="blog-post"><h2><a href="/blog/introducing-analytics">Introducing Advanced Analytics</a></h2><p class="post-meta">Published on ' || TO_CHAR(NOW() - INTERVAL '14 days', 'Month DD, YYYY') || '</p><p>Track your link performance like never before with our new advanced analytics dashboard featuring geographic insights, device analytics, and more...</p><a href="/blog/introducing-analytics" class="read-more">Read more</a></article><article class="blog-post"><h2><a href="/blog/api-launch">API Now Available</a></h2><p class="post-meta">Published on ' || TO_CHAR(NOW() - INTERVAL '21 days', 'Month DD, YYYY') || '</p><p>Integrate Trunc into your applications with our comprehensive REST API. Generate API keys and start building today...</p><a href="/blog/api-launch" class="read-more">Read more</a></article></div><p><a href="/blog/archive">View all blog posts →</a></p>', 'Trunc blog - Latest news, feature announcements, and tips for getting the most out of our URL shortening service.', true, 7, NOW()),
     
     ('community', 'Community', '<h1>Trunc Community</h1><p>Connect with other Trunc users, share tips, and get help from the community.</p><h2>Join Our Community</h2><div class="community-links"><div class="community-item"><h3>Discord Server</h3><p>Chat with other users and get real-time help.</p><p><a href="#" class="btn btn-primary">Join Discord</a></p></div><div class="community-item"><h3>GitHub</h3><p>Contribute to our open-source projects and report issues.</p><p><a href="#" class="btn btn-primary">View on GitHub</a></p></div><div class="community-item"><h3>Reddit Community</h3><p>Share tips, ask questions, and discuss URL shortening best practices.</p><p><a href="#" class="btn btn-primary">Join r/Trunc</a></p></div></div><h2>Community Guidelines</h2><ul><li>Be respectful and helpful to other community members</li><li>Stay on topic and keep discussions relevant to Trunc</li><li>No spam, self-promotion, or advertising</li><li>Search before posting to avoid duplicate discussions</li><li>Follow platform-specific rules and guidelines</li></ul><h2>Community Resources</h2><ul><li><a href="/help">Help Center</a> - Comprehensive documentation and guides</li><li><a href="/api-docs">API Documentation</a> - Technical reference for developers</li><li><a href="/blog">Blog</a> - Latest news and feature updates</li><li><a href="/contact">Contact Support</a> - Direct help from our team</li></ul><h2>Featured Community Contributions</h2><p>Check out some amazing projects and integrations created by our community members:</p><ul><li>Trunc WordPress Plugin</li><li>Browser Extension for Quick Shortening</li><li>Mobile App for iOS and Android</li><li>Zapier Integration</li></ul>', 'Join the Trunc community to connect with other users, share tips, contribute to projects, and get community support.', true, 8, NOW())
 ON CONFLICT (slug) DO NOTHING;
@@ -423,4 +410,4 @@ INSERT INTO navigation_menus (name, location, items, is_active, sort_order) VALU
         {"label": "Status", "url": "/status", "type": "internal"},
         {"label": "Blog", "url": "/blog", "type": "internal"}
     ]', true, 2)
-ON CONFLICT (name, location) DO NOTHING;
\ No newline at end of file
+ON CONFLICT (name, location) DO NOTHING;
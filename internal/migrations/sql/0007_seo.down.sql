@@ -0,0 +1,6 @@
+DELETE FROM global_settings WHERE category = 'seo';
+DROP TABLE IF EXISTS robots_txt;
+DROP TABLE IF EXISTS seo_analyses;
+DROP TABLE IF EXISTS url_redirects;
+DROP TABLE IF EXISTS page_seo;
+DROP TABLE IF EXISTS meta_tags;
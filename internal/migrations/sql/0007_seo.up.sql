@@ -1,4 +1,4 @@
--- SEO-related database tables
+-- SEO: meta tags, per-page SEO data, redirects, SEO analyses and robots.txt.
 
 -- Meta tags table
 CREATE TABLE IF NOT EXISTS meta_tags (
@@ -82,12 +82,15 @@ CREATE TABLE IF NOT EXISTS robots_txt (
     id BIGSERIAL PRIMARY KEY,
     content TEXT NOT NULL,
     is_active BOOLEAN DEFAULT TRUE,
-    created_by BIGINT NOT NULL REFERENCES users(id),
+    created_by BIGINT REFERENCES users(id), -- NULL for the seeded default
     created_at TIMESTAMP NOT NULL DEFAULT NOW(),
-    updated_by BIGINT NOT NULL REFERENCES users(id),
+    updated_by BIGINT REFERENCES users(id),
     updated_at TIMESTAMP NOT NULL DEFAULT NOW()
 );
 
+ALTER TABLE robots_txt ALTER COLUMN created_by DROP NOT NULL;
+ALTER TABLE robots_txt ALTER COLUMN updated_by DROP NOT NULL;
+
 -- Create indexes for better performance
 CREATE INDEX IF NOT EXISTS idx_meta_tags_page_id ON meta_tags(page_id);
 CREATE INDEX IF NOT EXISTS idx_meta_tags_name ON meta_tags(name);
@@ -100,17 +103,17 @@ CREATE INDEX IF NOT EXISTS idx_seo_analyses_created_by ON seo_analyses(created_b
 CREATE INDEX IF NOT EXISTS idx_seo_analyses_score ON seo_analyses(score);
 
 -- Insert default SEO settings into global_settings
-INSERT INTO global_settings (key, value, type, category, display_name, description, is_public, sort_order, created_by, created_at, updated_at)
+INSERT INTO global_settings (key, value, type, category, display_name, description, is_public, sort_order, created_at, updated_at)
 VALUES 
-    ('site_title', '3logiq', 'text', 'seo', 'Site Title', 'Default site title for SEO', true, 1, 1, NOW(), NOW()),
-    ('site_description', 'Professional URL shortener service', 'text', 'seo', 'Site Description', 'Default site description for SEO', true, 2, 1, NOW(), NOW()),
-    ('site_keywords', 'url shortener, link shortener, custom links', 'text', 'seo', 'Site Keywords', 'Default keywords for SEO', true, 3, 1, NOW(), NOW()),
-    ('google_analytics_id', '', 'text', 'seo', 'Google Analytics ID', 'Google Analytics tracking ID', false, 4, 1, NOW(), NOW()),
-    ('google_search_console_verification', '', 'text', 'seo', 'Google Search Console', 'Google Search Console verification code', false, 5, 1, NOW(), NOW()),
-    ('og_image', '', 'text', 'seo', 'Default OG Image', 'Default Open Graph image URL', true, 6, 1, NOW(), NOW()),
-    ('twitter_site', '@3logiq', 'text', 'seo', 'Twitter Site Handle', 'Twitter site handle for cards', true, 7, 1, NOW(), NOW()),
-    ('robots_txt', 'User-agent: *\nDisallow:\nSitemap: /sitemap.xml', 'textarea', 'seo', 'Robots.txt Content', 'Content for robots.txt file', true, 8, 1, NOW(), NOW()),
-    ('sitemap_xml', '', 'textarea', 'seo', 'Sitemap XML', 'Generated XML sitemap content', false, 9, 1, NOW(), NOW())
+    ('site_title', '3logiq', 'text', 'seo', 'Site Title', 'Default site title for SEO', true, 1, NOW(), NOW()),
+    ('site_description', 'Professional URL shortener service', 'text', 'seo', 'Site Description', 'Default site description for SEO', true, 2, NOW(), NOW()),
+    ('site_keywords', 'url shortener, link shortener, custom links', 'text', 'seo', 'Site Keywords', 'Default keywords for SEO', true, 3, NOW(), NOW()),
+    ('google_analytics_id', '', 'text', 'seo', 'Google Analytics ID', 'Google Analytics tracking ID', false, 4, NOW(), NOW()),
+    ('google_search_console_verification', '', 'text', 'seo', 'Google Search Console', 'Google Search Console verification code', false, 5, NOW(), NOW()),
+    ('og_image', '', 'text', 'seo', 'Default OG Image', 'Default Open Graph image URL', true, 6, NOW(), NOW()),
+    ('twitter_site', '@3logiq', 'text', 'seo', 'Twitter Site Handle', 'Twitter site handle for cards', true, 7, NOW(), NOW()),
+    ('robots_txt', 'User-agent: *\nDisallow:\nSitemap: /sitemap.xml', 'textarea', 'seo', 'Robots.txt Content', 'Content for robots.txt file', true, 8, NOW(), NOW()),
+    ('sitemap_xml', '', 'textarea', 'seo', 'Sitemap XML', 'Generated XML sitemap content', false, 9, NOW(), NOW())
 ON CONFLICT (key) DO NOTHING;
 
 -- Insert default robots.txt
@@ -118,8 +121,8 @@ INSERT INTO robots_txt (content, is_active, created_by, created_at, updated_by,
 VALUES (
     E'User-agent: *\nDisallow: /admin\nDisallow: /api\nAllow: /\n\nSitemap: /sitemap.xml',
     true,
-    1,
+    NULL,
     NOW(),
-    1,
+    NULL,
     NOW()
-) ON CONFLICT DO NOTHING;
\ No newline at end of file
+) ON CONFLICT DO NOTHING;
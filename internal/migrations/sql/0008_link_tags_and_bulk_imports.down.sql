@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS bulk_import_rows;
+DROP TABLE IF EXISTS bulk_import_jobs;
+DROP TABLE IF EXISTS url_tags;
@@ -0,0 +1,46 @@
+-- Free-form link tags and asynchronous bulk imports of links.
+
+CREATE TABLE url_tags (
+    short_code VARCHAR(10) NOT NULL REFERENCES url_mappings(short_code) ON DELETE CASCADE,
+    tag VARCHAR(50) NOT NULL,
+    PRIMARY KEY (short_code, tag)
+);
+
+CREATE INDEX idx_url_tags_tag ON url_tags(tag);
+
+-- One job per uploaded file; the checksum makes re-uploads idempotent
+CREATE TABLE bulk_import_jobs (
+    id BIGINT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    format VARCHAR(10) NOT NULL,
+    checksum VARCHAR(64) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, running, completed or failed
+    total_rows INTEGER NOT NULL DEFAULT 0,
+    processed_rows INTEGER NOT NULL DEFAULT 0,
+    created_rows INTEGER NOT NULL DEFAULT 0,
+    failed_rows INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    started_at TIMESTAMP WITH TIME ZONE,
+    finished_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (user_id, checksum)
+);
+
+CREATE INDEX idx_bulk_import_jobs_unfinished ON bulk_import_jobs(created_at) WHERE status IN ('pending', 'running');
+
+-- Parsed rows of a job and the outcome of each
+CREATE TABLE bulk_import_rows (
+    job_id BIGINT NOT NULL REFERENCES bulk_import_jobs(id) ON DELETE CASCADE,
+    line INTEGER NOT NULL,
+    url TEXT NOT NULL,
+    custom_code VARCHAR(50),
+    expires_at TIMESTAMP WITH TIME ZONE,
+    title VARCHAR(200),
+    tags JSONB,
+    status VARCHAR(10) NOT NULL DEFAULT 'pending', -- pending, created or failed
+    short_code VARCHAR(10),
+    error TEXT,
+    PRIMARY KEY (job_id, line)
+);
+
+CREATE INDEX idx_bulk_import_rows_status ON bulk_import_rows(job_id, status, line);
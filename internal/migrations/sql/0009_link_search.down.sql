@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_url_mappings_user_clicks;
+DROP INDEX IF EXISTS idx_url_mappings_user_created;
+DROP INDEX IF EXISTS idx_url_mappings_user_host;
+DROP INDEX IF EXISTS idx_url_mappings_search;
+
+ALTER TABLE url_mappings
+    DROP COLUMN IF EXISTS search_vector,
+    DROP COLUMN IF EXISTS destination_host;
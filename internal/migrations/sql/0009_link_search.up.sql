@@ -0,0 +1,18 @@
+-- Columns for searching a user's links: the destination's host, and a
+-- full-text document over the title, description and destination URL.
+-- Both are generated so every write path keeps them current.
+
+ALTER TABLE url_mappings
+    ADD COLUMN destination_host TEXT GENERATED ALWAYS AS (
+        lower(substring(original_url from '^[A-Za-z][A-Za-z0-9+.-]*://(?:[^/?#@]*@)?([^/?#:]+)'))
+    ) STORED,
+    ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', COALESCE(title, '')), 'A') ||
+        setweight(to_tsvector('english', COALESCE(description, '')), 'B') ||
+        setweight(to_tsvector('simple', original_url), 'C')
+    ) STORED;
+
+CREATE INDEX idx_url_mappings_search ON url_mappings USING GIN (search_vector);
+CREATE INDEX idx_url_mappings_user_host ON url_mappings(user_id, destination_host);
+CREATE INDEX idx_url_mappings_user_created ON url_mappings(user_id, created_at, id);
+CREATE INDEX idx_url_mappings_user_clicks ON url_mappings(user_id, click_count, id);
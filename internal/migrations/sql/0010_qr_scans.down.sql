@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_click_events_short_code_source;
+
+ALTER TABLE click_events DROP COLUMN IF EXISTS source;
@@ -0,0 +1,6 @@
+-- How a visitor reached a link, so QR code scans can be counted apart
+-- from clicks. NULL for plain clicks.
+
+ALTER TABLE click_events ADD COLUMN source VARCHAR(10);
+
+CREATE INDEX idx_click_events_short_code_source ON click_events(short_code, source) WHERE source IS NOT NULL;
@@ -0,0 +1,4 @@
+ALTER TABLE url_mappings DROP COLUMN IF EXISTS metadata_fetched_at;
+ALTER TABLE url_mappings DROP COLUMN IF EXISTS site_name;
+ALTER TABLE url_mappings DROP COLUMN IF EXISTS favicon_url;
+ALTER TABLE url_mappings DROP COLUMN IF EXISTS image_url;
@@ -0,0 +1,8 @@
+-- Preview metadata fetched from a link's destination after it is created
+-- or changed. Titles and descriptions go in the existing columns when the
+-- owner has not set them.
+
+ALTER TABLE url_mappings ADD COLUMN image_url TEXT;
+ALTER TABLE url_mappings ADD COLUMN favicon_url TEXT;
+ALTER TABLE url_mappings ADD COLUMN site_name VARCHAR(200);
+ALTER TABLE url_mappings ADD COLUMN metadata_fetched_at TIMESTAMP WITH TIME ZONE;
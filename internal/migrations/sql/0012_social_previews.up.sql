@@ -0,0 +1,4 @@
+-- Custom social preview card per link, served to link preview bots instead
+-- of redirecting them. NULL when the link has none.
+
+ALTER TABLE url_mappings ADD COLUMN social_preview JSONB;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_url_mappings_safety_checked_at;
+
+ALTER TABLE url_mappings DROP COLUMN IF EXISTS safety_checked_at;
+ALTER TABLE url_mappings DROP COLUMN IF EXISTS quarantine_reason;
+ALTER TABLE url_mappings DROP COLUMN IF EXISTS quarantined_at;
+
+DROP TABLE IF EXISTS destination_blocklist;
@@ -0,0 +1,20 @@
+-- Destination safety screening: an admin-managed blocklist, and a
+-- quarantine state for links whose destination turned out to be malicious.
+-- safety_checked_at orders the periodic re-check of existing links.
+
+CREATE TABLE destination_blocklist (
+    id BIGSERIAL PRIMARY KEY,
+    kind VARCHAR(10) NOT NULL CHECK (kind IN ('domain', 'url')),
+    pattern TEXT NOT NULL,
+    reason TEXT,
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (kind, pattern)
+);
+
+ALTER TABLE url_mappings ADD COLUMN quarantined_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE url_mappings ADD COLUMN quarantine_reason TEXT;
+ALTER TABLE url_mappings ADD COLUMN safety_checked_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_url_mappings_safety_checked_at ON url_mappings(safety_checked_at NULLS FIRST)
+    WHERE is_active = TRUE AND quarantined_at IS NULL;
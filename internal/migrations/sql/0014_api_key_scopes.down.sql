@@ -0,0 +1,15 @@
+ALTER TABLE api_keys ALTER COLUMN permissions SET DEFAULT '[]';
+
+UPDATE api_keys SET permissions = COALESCE((
+    SELECT jsonb_agg(DISTINCT legacy)
+    FROM jsonb_array_elements_text(permissions) AS p,
+    LATERAL unnest(CASE p
+        WHEN 'urls:read' THEN ARRAY['url.read']
+        WHEN 'urls:write' THEN ARRAY['url.create', 'url.update', 'url.delete']
+        WHEN 'analytics:read' THEN ARRAY['analytics.read']
+        WHEN 'domains:read' THEN ARRAY['domain.read']
+        WHEN 'domains:write' THEN ARRAY['domain.create', 'domain.update', 'domain.delete']
+        ELSE ARRAY[p]
+    END) AS legacy
+), '[]'::jsonb)
+WHERE jsonb_typeof(permissions) = 'array';
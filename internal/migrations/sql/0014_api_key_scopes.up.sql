@@ -0,0 +1,21 @@
+-- API key permissions become scopes named after the resources routes expose.
+-- Keys that could create, update or delete URLs get urls:write.
+
+UPDATE api_keys SET permissions = COALESCE((
+    SELECT jsonb_agg(DISTINCT CASE p
+        WHEN 'url.read' THEN 'urls:read'
+        WHEN 'url.create' THEN 'urls:write'
+        WHEN 'url.update' THEN 'urls:write'
+        WHEN 'url.delete' THEN 'urls:write'
+        WHEN 'analytics.read' THEN 'analytics:read'
+        WHEN 'domain.read' THEN 'domains:read'
+        WHEN 'domain.create' THEN 'domains:write'
+        WHEN 'domain.update' THEN 'domains:write'
+        WHEN 'domain.delete' THEN 'domains:write'
+        ELSE p
+    END)
+    FROM jsonb_array_elements_text(permissions) AS p
+), '[]'::jsonb)
+WHERE jsonb_typeof(permissions) = 'array';
+
+ALTER TABLE api_keys ALTER COLUMN permissions SET DEFAULT '["urls:read", "urls:write", "analytics:read"]';
@@ -0,0 +1,22 @@
+-- Authenticator app (TOTP) two-factor authentication. The secret is stored
+-- when enrolment starts and enabled_at set once a code confirms it;
+-- last_used_step stops accepted codes from being replayed. Recovery codes
+-- are single use and only their SHA-256 hashes are kept.
+
+CREATE TABLE user_two_factor (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret TEXT,
+    enabled_at TIMESTAMP WITH TIME ZONE,
+    required BOOLEAN NOT NULL DEFAULT FALSE,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE two_factor_recovery_codes (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, code_hash)
+);
@@ -0,0 +1,4 @@
+UPDATE unique_visitor_sketches SET sketch_key = CASE
+    WHEN day IS NULL THEN 'uv:' || short_code
+    ELSE 'uv:' || short_code || ':' || to_char(day, 'YYYY-MM-DD')
+END;
@@ -0,0 +1,8 @@
+-- Unique visitor sketches move from uv:<code> and uv:<code>:<day> to their
+-- own prefixes, so a link's sketch key can't collide with the dirty set or
+-- a day's salt. Sketches not yet persisted when this runs start over.
+
+UPDATE unique_visitor_sketches SET sketch_key = CASE
+    WHEN day IS NULL THEN 'uv:l:' || short_code
+    ELSE 'uv:d:' || short_code || ':' || to_char(day, 'YYYY-MM-DD')
+END;
@@ -151,9 +151,14 @@ type LoginRequest struct {
 // AuthResponse represents authentication response
 type AuthResponse struct {
 	User         *PublicUser `json:"user"`
-	AccessToken  string      `json:"access_token"`
-	RefreshToken string      `json:"refresh_token"`
-	ExpiresIn    int64       `json:"expires_in"` // seconds
+	AccessToken  string      `json:"access_token,omitempty"`
+	RefreshToken string      `json:"refresh_token,omitempty"`
+	ExpiresIn    int64       `json:"expires_in,omitempty"` // seconds
+
+	// Set instead of the tokens when a second factor is needed
+	TwoFactor *TwoFactorChallenge `json:"two_factor,omitempty"`
+	// Set when login completed two-factor enrolment; shown only once
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
 }
 
 // OTPRequest represents OTP verification request
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+)
+
+// Bulk import file formats
+const (
+	BulkFormatCSV    = "csv"
+	BulkFormatNDJSON = "ndjson"
+)
+
+// Bulk import job states
+const (
+	BulkImportPending   = "pending"
+	BulkImportRunning   = "running"
+	BulkImportCompleted = "completed"
+	BulkImportFailed    = "failed"
+)
+
+// Bulk import row states
+const (
+	BulkRowPending = "pending"
+	BulkRowCreated = "created"
+	BulkRowFailed  = "failed"
+)
+
+// BulkImportJob is an asynchronous import of links from an uploaded file.
+// Jobs are keyed by a checksum of the file so re-uploading it returns the
+// existing job instead of creating the links twice.
+type BulkImportJob struct {
+	ID            int64      `json:"id" db:"id"`
+	UserID        int64      `json:"user_id" db:"user_id"`
+	Format        string     `json:"format" db:"format"`
+	Checksum      string     `json:"checksum" db:"checksum"`
+	Status        string     `json:"status" db:"status"`
+	TotalRows     int        `json:"total_rows" db:"total_rows"`
+	ProcessedRows int        `json:"processed_rows" db:"processed_rows"`
+	CreatedRows   int        `json:"created_rows" db:"created_rows"`
+	FailedRows    int        `json:"failed_rows" db:"failed_rows"`
+	Error         string     `json:"error,omitempty" db:"error"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	StartedAt     *time.Time `json:"started_at,omitempty" db:"started_at"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty" db:"finished_at"`
+}
+
+// IsFinished reports whether the job will not process any more rows
+func (j *BulkImportJob) IsFinished() bool {
+	return j.Status == BulkImportCompleted || j.Status == BulkImportFailed
+}
+
+// BulkImportRow is one link of an import file along with its result. Rows
+// that fail validation while the file is parsed are stored already failed.
+type BulkImportRow struct {
+	JobID      int64      `json:"-" db:"job_id"`
+	Line       int        `json:"line" db:"line"` // line of the row in the uploaded file
+	URL        string     `json:"url" db:"url"`
+	CustomCode string     `json:"custom_code,omitempty" db:"custom_code"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	Title      string     `json:"title,omitempty" db:"title"`
+	Tags       Tags       `json:"tags,omitempty" db:"tags"`
+	Status     string     `json:"status" db:"status"`
+	ShortCode  string     `json:"short_code,omitempty" db:"short_code"`
+	Error      string     `json:"error,omitempty" db:"error"`
+}
+
+// BulkImportRowsResponse is a page of an import job's row results
+type BulkImportRowsResponse struct {
+	Rows      []*BulkImportRow `json:"rows"`
+	NextAfter *int             `json:"next_after,omitempty"` // pass as after to fetch the next page
+}
+
+// Tags is a set of free-form labels on a link
+type Tags []string
+
+// Value implements the driver.Valuer interface
+func (t Tags) Value() (driver.Value, error) {
+	if len(t) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(t)
+}
+
+// Scan implements the sql.Scanner interface
+func (t *Tags) Scan(value interface{}) error {
+	if value == nil {
+		*t = nil
+		return nil
+	}
+
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, t)
+	case string:
+		return json.Unmarshal([]byte(v), t)
+	default:
+		return nil
+	}
+}
@@ -134,9 +134,40 @@ type APIKey struct {
 	UpdatedAt   time.Time     `json:"updated_at" db:"updated_at"`
 }
 
+// API key scopes. A key may only call routes requiring one of its scopes;
+// "*" grants every scope.
+const (
+	ScopeURLsRead      = "urls:read"
+	ScopeURLsWrite     = "urls:write"
+	ScopeAnalyticsRead = "analytics:read"
+	ScopeDomainsRead   = "domains:read"
+	ScopeDomainsWrite  = "domains:write"
+	ScopeAll           = "*"
+)
+
+// APIKeyScopes lists the scopes a key can be granted
+var APIKeyScopes = []string{
+	ScopeURLsRead,
+	ScopeURLsWrite,
+	ScopeAnalyticsRead,
+	ScopeDomainsRead,
+	ScopeDomainsWrite,
+	ScopeAll,
+}
+
 // APIKeyPermissions represents the permissions for an API key
 type APIKeyPermissions []string
 
+// Allows reports whether the permissions grant scope
+func (p APIKeyPermissions) Allows(scope string) bool {
+	for _, granted := range p {
+		if granted == ScopeAll || granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
 // Value implements the driver.Valuer interface
 func (p APIKeyPermissions) Value() (driver.Value, error) {
 	if p == nil {
@@ -354,26 +385,20 @@ type ContentBlockRequest struct {
 // Default permissions for API keys
 var (
 	DefaultAPIKeyPermissions = APIKeyPermissions{
-		"url.create",
-		"url.read",
-		"url.update", 
-		"url.delete",
-		"analytics.read",
+		ScopeURLsRead,
+		ScopeURLsWrite,
+		ScopeAnalyticsRead,
 	}
 
 	PremiumAPIKeyPermissions = APIKeyPermissions{
-		"url.create",
-		"url.read", 
-		"url.update",
-		"url.delete",
-		"analytics.read",
-		"domain.read",
-		"domain.create",
-		"domain.update",
-		"domain.delete",
+		ScopeURLsRead,
+		ScopeURLsWrite,
+		ScopeAnalyticsRead,
+		ScopeDomainsRead,
+		ScopeDomainsWrite,
 	}
 
 	AdminAPIKeyPermissions = APIKeyPermissions{
-		"*", // All permissions
+		ScopeAll, // All permissions
 	}
 )
\ No newline at end of file
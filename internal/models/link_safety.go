@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Kinds of destination blocklist entries
+const (
+	BlocklistKindDomain = "domain" // a host and all of its subdomains
+	BlocklistKindURL    = "url"    // a URL pattern where * matches any run of characters
+)
+
+// BlocklistEntry is a destination admins have barred from short links
+type BlocklistEntry struct {
+	ID        int64     `json:"id" db:"id"`
+	Kind      string    `json:"kind" db:"kind"`
+	Pattern   string    `json:"pattern" db:"pattern"`
+	Reason    string    `json:"reason,omitempty" db:"reason"`
+	CreatedBy *int64    `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// SafetyVerdict is the outcome of screening a destination URL
+type SafetyVerdict struct {
+	Malicious bool   `json:"malicious"`
+	Source    string `json:"source,omitempty"` // "blocklist" or the name of the reputation feed
+	Reason    string `json:"reason,omitempty"`
+}
+
+// SafetyRecheckResult summarises one pass over existing links
+type SafetyRecheckResult struct {
+	Checked     int      `json:"checked"`
+	Quarantined []string `json:"quarantined,omitempty"` // short codes quarantined by this pass
+}
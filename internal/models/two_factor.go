@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+// TwoFactor holds a user's authenticator app (TOTP) settings
+type TwoFactor struct {
+	UserID       int64      `json:"user_id"`
+	Secret       string     `json:"-"` // base32; set when enrolment starts
+	EnabledAt    *time.Time `json:"enabled_at"`
+	Required     bool       `json:"required"` // set by an admin; the user can't turn it off
+	LastUsedStep int64      `json:"-"`        // time step of the last accepted code
+}
+
+// Enabled reports whether enrolment was confirmed
+func (t *TwoFactor) Enabled() bool {
+	return t.EnabledAt != nil
+}
+
+// TwoFactorStatus describes a user's two-factor settings
+type TwoFactorStatus struct {
+	Enabled                bool       `json:"enabled"`
+	Required               bool       `json:"required"`
+	EnabledAt              *time.Time `json:"enabled_at,omitempty"`
+	RecoveryCodesRemaining int        `json:"recovery_codes_remaining"`
+}
+
+// TwoFactorEnrollment is the secret to add to an authenticator app, as is
+// and as an otpauth:// URI for QR codes
+type TwoFactorEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// TwoFactorChallenge is returned by login instead of tokens when the user
+// must also present a code. When SetupRequired is set, the user has to
+// enrol first because an admin requires two-factor for the account.
+type TwoFactorChallenge struct {
+	Token         string `json:"token"`
+	SetupRequired bool   `json:"setup_required"`
+	ExpiresIn     int64  `json:"expires_in"` // seconds
+}
+
+// TwoFactorCodeRequest carries an authenticator code or a recovery code
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// TwoFactorLoginRequest completes a login challenged for a second factor
+type TwoFactorLoginRequest struct {
+	Token string `json:"token" validate:"required"`
+	Code  string `json:"code" validate:"required"`
+}
+
+// TwoFactorSetupRequest starts enrolment during a login challenge
+type TwoFactorSetupRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// RecoveryCodesResponse lists newly generated recovery codes, which are only
+// shown once
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
@@ -1,6 +1,9 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
 	"time"
 )
 
@@ -15,6 +18,168 @@ type URLMapping struct {
 	IsActive    bool      `json:"is_active" db:"is_active"`
 	CreatedByIP string    `json:"created_by_ip,omitempty" db:"created_by_ip"`
 	UserID      *int64    `json:"user_id,omitempty" db:"user_id"`
+	RedirectType string   `json:"redirect_type,omitempty" db:"redirect_type"`
+	DestinationVersion int `json:"destination_version,omitempty" db:"destination_version"`
+	RedirectRules RedirectRules `json:"redirect_rules,omitempty" db:"redirect_rules"`
+	PasswordHash string       `json:"-" db:"password_hash"` // bcrypt; caches store it separately so it never reaches JSON output
+	StartsAt    *time.Time `json:"starts_at,omitempty" db:"starts_at"`
+	MaxClicks   *int64     `json:"max_clicks,omitempty" db:"max_clicks"`     // link stops redirecting after this many clicks
+	FallbackURL string     `json:"fallback_url,omitempty" db:"fallback_url"` // where exhausted links send visitors; 410 when empty
+	SocialPreview *SocialPreview `json:"social_preview,omitempty" db:"social_preview"`
+	QuarantinedAt *time.Time `json:"quarantined_at,omitempty" db:"quarantined_at"` // set when the destination was found to be malicious
+	QuarantineReason string  `json:"quarantine_reason,omitempty" db:"quarantine_reason"`
+}
+
+// IsQuarantined reports whether visitors get a warning instead of being redirected
+func (m *URLMapping) IsQuarantined() bool {
+	return m.QuarantinedAt != nil
+}
+
+// Destinations lists every URL the link can send visitors to
+func (m *URLMapping) Destinations() []string {
+	destinations := []string{m.OriginalURL}
+	for _, rule := range m.RedirectRules {
+		destinations = append(destinations, rule.Destination)
+	}
+	if m.FallbackURL != "" {
+		destinations = append(destinations, m.FallbackURL)
+	}
+	return destinations
+}
+
+// IsClickLimited reports whether the link stops redirecting after a number of clicks
+func (m *URLMapping) IsClickLimited() bool {
+	return m.MaxClicks != nil
+}
+
+// IsPasswordProtected reports whether visitors must enter a password before being redirected
+func (m *URLMapping) IsPasswordProtected() bool {
+	return m.PasswordHash != ""
+}
+
+// Twitter card types a social preview can use
+const (
+	TwitterCardSummary           = "summary"
+	TwitterCardSummaryLargeImage = "summary_large_image"
+)
+
+// SocialPreview is the card link preview bots (Slack, LinkedIn, ...) show for
+// a short link instead of the destination's own. Fields follow PageSEO.
+type SocialPreview struct {
+	OGTitle       string `json:"og_title,omitempty"`
+	OGDescription string `json:"og_description,omitempty"`
+	OGImage       string `json:"og_image,omitempty"`
+	TwitterCard   string `json:"twitter_card,omitempty"` // summary or summary_large_image
+}
+
+// IsEmpty reports whether the preview sets nothing
+func (p *SocialPreview) IsEmpty() bool {
+	return p == nil || *p == SocialPreview{}
+}
+
+// Value implements the driver.Valuer interface; empty previews are stored as NULL
+func (p SocialPreview) Value() (driver.Value, error) {
+	if p.IsEmpty() {
+		return nil, nil
+	}
+	return json.Marshal(p)
+}
+
+// Scan implements the sql.Scanner interface
+func (p *SocialPreview) Scan(value interface{}) error {
+	*p = SocialPreview{}
+
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, p)
+	case string:
+		return json.Unmarshal([]byte(v), p)
+	default:
+		return nil
+	}
+}
+
+// RedirectRule routes a click to an alternative destination when all of its
+// conditions match. Empty conditions match any click.
+type RedirectRule struct {
+	Countries   []string `json:"countries,omitempty"`    // ISO 3166-1 alpha-2 codes
+	DeviceTypes []string `json:"device_types,omitempty"` // mobile, tablet, desktop
+	OSNames     []string `json:"os_names,omitempty"`     // iOS, Android, Windows, macOS, Linux
+	Languages   []string `json:"languages,omitempty"`    // language tags from Accept-Language, e.g. "en" or "pt-BR"
+	Weekdays    []int    `json:"weekdays,omitempty"`     // 0-6 (Sunday-Saturday)
+	StartTime   string   `json:"start_time,omitempty"`   // HH:MM, inclusive
+	EndTime     string   `json:"end_time,omitempty"`     // HH:MM, exclusive; may wrap past midnight
+	Timezone    string   `json:"timezone,omitempty"`     // IANA zone for weekday/time checks, defaults to UTC
+	Destination string   `json:"destination" binding:"required"`
+}
+
+// RedirectRules is an ordered rule set; the first matching rule wins and the
+// link's original URL is the fallback when none match
+type RedirectRules []RedirectRule
+
+// Value implements the driver.Valuer interface
+func (r RedirectRules) Value() (driver.Value, error) {
+	if len(r) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(r)
+}
+
+// Scan implements the sql.Scanner interface
+func (r *RedirectRules) Scan(value interface{}) error {
+	if value == nil {
+		*r = nil
+		return nil
+	}
+
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, r)
+	case string:
+		return json.Unmarshal([]byte(v), r)
+	default:
+		return nil
+	}
+}
+
+// Redirect types supported per short link
+const (
+	RedirectTypePermanent         = "301"
+	RedirectTypeFound             = "302"
+	RedirectTypeTemporary         = "307"
+	RedirectTypePermanentRedirect = "308"
+	RedirectTypeMetaRefresh       = "meta_refresh" // HTML interstitial with meta refresh + JS fallback
+
+	// DefaultRedirectType is used when a link has no explicit redirect type.
+	// 302 keeps browsers from caching the redirect so every click reaches us.
+	DefaultRedirectType = RedirectTypeFound
+)
+
+// IsValidRedirectType reports whether the given redirect type is supported
+func IsValidRedirectType(redirectType string) bool {
+	switch redirectType {
+	case RedirectTypePermanent, RedirectTypeFound, RedirectTypeTemporary,
+		RedirectTypePermanentRedirect, RedirectTypeMetaRefresh:
+		return true
+	}
+	return false
+}
+
+// RedirectStatusCode returns the HTTP status code for a redirect type.
+// The meta refresh mode is served as a 200 HTML page.
+func RedirectStatusCode(redirectType string) int {
+	switch redirectType {
+	case RedirectTypePermanent:
+		return http.StatusMovedPermanently
+	case RedirectTypeTemporary:
+		return http.StatusTemporaryRedirect
+	case RedirectTypePermanentRedirect:
+		return http.StatusPermanentRedirect
+	case RedirectTypeMetaRefresh:
+		return http.StatusOK
+	default:
+		return http.StatusFound
+	}
 }
 
 // ClickEvent represents a click tracking event
@@ -26,6 +191,46 @@ type ClickEvent struct {
 	UserAgent   string    `json:"user_agent,omitempty" db:"user_agent"`
 	Referrer    string    `json:"referrer,omitempty" db:"referrer"`
 	CountryCode string    `json:"country_code,omitempty" db:"country_code"`
+	DestinationVersion int `json:"destination_version,omitempty" db:"destination_version"`
+	TrafficClass string   `json:"traffic_class,omitempty" db:"traffic_class"`
+	Source      string    `json:"source,omitempty" db:"source"` // how the visitor reached the link, empty for a plain click
+}
+
+// Click sources, taken from the ClickSourceParam query parameter of the
+// redirect. QR codes encode the short URL tagged with ClickSourceQR so
+// scans can be counted apart from clicks.
+const (
+	ClickSourceParam = "src"
+	ClickSourceQR    = "qr"
+)
+
+// Traffic classes assigned to clicks at ingestion. Only human clicks count
+// towards analytics unless bots are explicitly included.
+const (
+	TrafficClassHuman   = "human"
+	TrafficClassBot     = "bot"     // crawlers, monitors, HTTP clients
+	TrafficClassPreview = "preview" // link unfurlers (Slack, Twitter, iMessage, ...)
+	TrafficClassScanner = "scanner" // security and email link scanners
+)
+
+// DestinationHistoryEntry represents a prior destination of a short link.
+// Entries are append-only: one is written each time the destination changes.
+type DestinationHistoryEntry struct {
+	ID          int64     `json:"id" db:"id"`
+	ShortCode   string    `json:"short_code" db:"short_code"`
+	Version     int       `json:"version" db:"version"`
+	OriginalURL string    `json:"original_url" db:"original_url"`
+	ActiveFrom  time.Time `json:"active_from" db:"active_from"`
+	ReplacedAt  time.Time `json:"replaced_at" db:"replaced_at"`
+	ReplacedBy  *int64    `json:"replaced_by,omitempty" db:"replaced_by"`
+}
+
+// DestinationStat represents clicks received while a destination version was live
+type DestinationStat struct {
+	Version     int    `json:"version"`
+	OriginalURL string `json:"original_url"`
+	Clicks      int64  `json:"clicks"`
+	IsCurrent   bool   `json:"is_current"`
 }
 
 // ShortenRequest represents the request payload for shortening URLs
@@ -33,6 +238,14 @@ type ShortenRequest struct {
 	URL       string     `json:"url" binding:"required,url"`
 	ExpiresAt *time.Time `json:"expires_at,omitempty"`
 	CustomCode string    `json:"custom_code,omitempty"`
+	RedirectType string  `json:"redirect_type,omitempty"` // 301, 302, 307, 308 or meta_refresh
+	RedirectRules RedirectRules `json:"redirect_rules,omitempty"`
+	Password     string  `json:"password,omitempty"` // optional password visitors must enter
+	StartsAt     *time.Time `json:"starts_at,omitempty"`
+	MaxClicks    *int64     `json:"max_clicks,omitempty"`
+	FallbackURL  string     `json:"fallback_url,omitempty"`
+	Tags         []string   `json:"tags,omitempty"`
+	SocialPreview *SocialPreview `json:"social_preview,omitempty"`
 }
 
 // ShortenResponse represents the response for URL shortening
@@ -42,6 +255,13 @@ type ShortenResponse struct {
 	OriginalURL string    `json:"original_url"`
 	CreatedAt   time.Time `json:"created_at"`
 	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	RedirectType string   `json:"redirect_type"`
+	PasswordProtected bool `json:"password_protected"`
+	StartsAt     *time.Time `json:"starts_at,omitempty"`
+	MaxClicks    *int64     `json:"max_clicks,omitempty"`
+	FallbackURL  string     `json:"fallback_url,omitempty"`
+	Tags         Tags       `json:"tags,omitempty"`
+	SocialPreview *SocialPreview `json:"social_preview,omitempty"`
 }
 
 // AnalyticsResponse represents analytics data for a short URL
@@ -49,6 +269,8 @@ type AnalyticsResponse struct {
 	ShortCode    string    `json:"short_code"`
 	OriginalURL  string    `json:"original_url"`
 	TotalClicks  int64     `json:"total_clicks"`
+	TotalScans   int64     `json:"total_scans"` // clicks that came from scanning the link's QR code
+	UniqueVisitors int64   `json:"unique_visitors"`
 	CreatedAt    time.Time `json:"created_at"`
 	LastClickAt  *time.Time `json:"last_click_at,omitempty"`
 	DailyClicks  []DailyClick `json:"daily_clicks,omitempty"`
@@ -59,6 +281,7 @@ type AnalyticsResponse struct {
 type DailyClick struct {
 	Date   string `json:"date"`
 	Clicks int64  `json:"clicks"`
+	UniqueVisitors int64 `json:"unique_visitors"`
 }
 
 // CountryStat represents click statistics per country
@@ -94,6 +317,33 @@ type UpdateURLRequest struct {
 	Description *string    `json:"description,omitempty"`
 	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
 	IsPublic    *bool      `json:"is_public,omitempty"`
+	RedirectType *string   `json:"redirect_type,omitempty"`
+	OriginalURL *string    `json:"original_url,omitempty"`
+	RedirectRules *RedirectRules `json:"redirect_rules,omitempty"` // empty list removes all rules
+	Password    *string    `json:"password,omitempty"` // empty string removes the password
+	StartsAt    *time.Time `json:"starts_at,omitempty"`    // null or the zero time removes the start
+	MaxClicks   *int64     `json:"max_clicks,omitempty"`   // 0 removes the click limit
+	FallbackURL *string    `json:"fallback_url,omitempty"` // empty string serves 410 once exhausted
+	Tags        *[]string  `json:"tags,omitempty"`         // replaces the link's tags; empty list removes them
+	SocialPreview *SocialPreview `json:"social_preview,omitempty"` // replaces the custom preview; {} removes it
+}
+
+// UnmarshalJSON reads an explicit "starts_at": null as the zero time, so it
+// removes the start rather than leaving it untouched like a missing field
+func (r *UpdateURLRequest) UnmarshalJSON(data []byte) error {
+	type plain UpdateURLRequest
+	if err := json.Unmarshal(data, (*plain)(r)); err != nil {
+		return err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if raw, ok := fields["starts_at"]; ok && string(raw) == "null" {
+		r.StartsAt = &time.Time{}
+	}
+	return nil
 }
 
 // UserURLResponse represents a URL in the user's URL list
@@ -109,6 +359,84 @@ type UserURLResponse struct {
 	IsPublic    bool       `json:"is_public"`
 	CreatedAt   time.Time  `json:"created_at"`
 	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	RedirectType string    `json:"redirect_type"`
+	DestinationVersion int `json:"destination_version"`
+	RedirectRules RedirectRules `json:"redirect_rules,omitempty"`
+	PasswordProtected bool `json:"password_protected"`
+	StartsAt    *time.Time `json:"starts_at,omitempty"`
+	MaxClicks   *int64     `json:"max_clicks,omitempty"`
+	FallbackURL string     `json:"fallback_url,omitempty"`
+	Tags        Tags       `json:"tags,omitempty"`
+	ImageURL    *string    `json:"image_url,omitempty"`   // preview image from the destination's OpenGraph or Twitter card
+	FaviconURL  *string    `json:"favicon_url,omitempty"`
+	SiteName    *string    `json:"site_name,omitempty"`
+	MetadataFetchedAt *time.Time `json:"metadata_fetched_at,omitempty"`
+	SocialPreview *SocialPreview `json:"social_preview,omitempty"`
+	QuarantinedAt *time.Time `json:"quarantined_at,omitempty"`
+	QuarantineReason string  `json:"quarantine_reason,omitempty"`
+}
+
+// LinkMetadata is what a link's destination page says about itself. Empty
+// fields were not found on the page.
+type LinkMetadata struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	ImageURL    string `json:"image_url,omitempty"`
+	FaviconURL  string `json:"favicon_url,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
+}
+
+// Sort keys for searching a user's links
+const (
+	URLSortCreatedAt  = "created_at"
+	URLSortClickCount = "click_count"
+	URLSortTitle      = "title"
+)
+
+// Link states a search can be limited to
+const (
+	URLStateActive  = "active"  // not expired
+	URLStateExpired = "expired" // past its expiry time
+)
+
+// URLSearchRequest holds the query parameters of a search over a user's links
+type URLSearchRequest struct {
+	Query       string     `form:"q"`
+	Tags        []string   `form:"tag"` // links must have every tag
+	Domain      string     `form:"domain"` // destination host or any of its subdomains
+	CreatedFrom string     `form:"created_from"` // date or RFC 3339 timestamp
+	CreatedTo   string     `form:"created_to"`   // inclusive when a date
+	State       string     `form:"state"`
+	MinClicks   *int64     `form:"min_clicks"`
+	MaxClicks   *int64     `form:"max_clicks"`
+	Sort        string     `form:"sort"`
+	Order       string     `form:"order"` // asc or desc
+	Cursor      string     `form:"cursor"`
+	Limit       int        `form:"limit"`
+}
+
+// URLSearchResponse is a page of search results
+type URLSearchResponse struct {
+	URLs       []*UserURLResponse `json:"urls"`
+	NextCursor string             `json:"next_cursor,omitempty"` // pass as cursor to fetch the next page
+}
+
+// QR code output formats
+const (
+	QRFormatPNG = "png"
+	QRFormatSVG = "svg"
+)
+
+// QRCodeRequest holds the rendering options of a link's QR code
+type QRCodeRequest struct {
+	Format     string `form:"format"` // png (default) or svg
+	Size       int    `form:"size"`   // width and height in pixels
+	Margin     *int   `form:"margin"` // quiet zone in modules, 4 by default
+	Level      string `form:"ecc"`    // error correction level: L, M, Q or H
+	Foreground string `form:"fg"`     // hex colour, e.g. #000000
+	Background string `form:"bg"`     // hex colour, e.g. #ffffff
+	LogoID     int64  `form:"logo"`   // media library image drawn in the centre
+	Domain     string `form:"domain"` // verified custom domain to build the short URL from
 }
 
 // UserDashboardStats represents user-specific dashboard statistics
@@ -118,6 +446,8 @@ type UserDashboardStats struct {
 	ActiveURLs   int64 `json:"active_urls"`
 	TodayClicks  int64 `json:"today_clicks"`
 	MonthClicks  int64 `json:"month_clicks"`
+	UniqueVisitors int64 `json:"unique_visitors"`
+	TodayUniqueVisitors int64 `json:"today_unique_visitors"`
 }
 
 // ClickTrend represents click trends over time
@@ -657,6 +987,7 @@ type URL struct {
 	ClickCount  int64      `json:"click_count" db:"click_count"`
 	IsActive    bool       `json:"is_active" db:"is_active"`
 	IsPublic    bool       `json:"is_public" db:"is_public"`
+	CustomAlias *string    `json:"custom_alias,omitempty" db:"custom_alias"`
 	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
 	ExpiresAt   *time.Time `json:"expires_at,omitempty" db:"expires_at"`
@@ -678,4 +1009,5 @@ type URLAnalytics struct {
 	OSStats          []OSStat           `json:"os_stats"`
 	HourlyPattern    []HourlyClick      `json:"hourly_pattern"`
 	WeeklyPattern    []WeekdayClick     `json:"weekly_pattern"`
+	DestinationStats []DestinationStat  `json:"destination_stats,omitempty"`
 }
\ No newline at end of file
@@ -8,6 +8,7 @@ import (
 
 	"github.com/URLshorter/url-shortener/internal/handlers"
 	"github.com/URLshorter/url-shortener/internal/middleware"
+	"github.com/URLshorter/url-shortener/internal/models"
 )
 
 // SetupRoutes configures all application routes
@@ -15,6 +16,8 @@ func SetupRoutes(
 	router *gin.Engine,
 	handler *handlers.Handler,
 	authMiddleware *middleware.AuthMiddleware,
+	apiKeyAuth *middleware.APIKeyAuth,
+	rateLimiter *middleware.RateLimiter,
 ) {
 	// Add global middleware
 	router.Use(middleware.CORSMiddleware())
@@ -23,15 +26,23 @@ func SetupRoutes(
 	
 	// Health check
 	router.GET("/health", handler.HealthCheck)
+
+	// Public keys for services verifying our tokens
+	if handler.JWKSHandlers != nil {
+		router.GET("/.well-known/jwks.json", handler.JWKSHandlers.GetJWKS)
+	}
 	
 	// Public routes (no authentication required)
-	setupPublicRoutes(router, handler, authMiddleware)
+	setupPublicRoutes(router, handler, apiKeyAuth, rateLimiter)
 
 	// Authentication routes
-	setupAuthRoutes(router, handler.AuthHandlers, authMiddleware)
+	setupAuthRoutes(router, handler.AuthHandlers, authMiddleware, rateLimiter)
+
+	// Two-factor settings
+	setupTwoFactorRoutes(router, handler, authMiddleware, rateLimiter)
 
 	// Protected routes (authentication required)
-	setupProtectedRoutes(router, handler, authMiddleware)
+	setupProtectedRoutes(router, handler, authMiddleware, apiKeyAuth, rateLimiter)
 	
 	// Admin routes (admin access required) - temporarily disabled
 	// setupAdminRoutes(router, handler, authService)
@@ -41,20 +52,27 @@ func SetupRoutes(
 }
 
 // setupPublicRoutes configures public routes
-func setupPublicRoutes(router *gin.Engine, handler *handlers.Handler, authMiddleware *middleware.AuthMiddleware) {
-	// URL shortening (with optional auth) - temporarily disabled auth middleware
+func setupPublicRoutes(router *gin.Engine, handler *handlers.Handler, apiKeyAuth *middleware.APIKeyAuth, rateLimiter *middleware.RateLimiter) {
+	// URL shortening (with optional JWT or API key auth)
 	router.POST("/api/v1/shorten",
-		// middleware.OptionalAuthMiddleware(authService),
-		middleware.RateLimitMiddleware(middleware.DefaultRateLimit),
+		apiKeyAuth.OptionalAuth(),
+		rateLimiter.Limit(middleware.DefaultRateLimit),
+		middleware.RequireScope(models.ScopeURLsWrite),
 		handler.ShortenURL,
 	)
 	
 	// URL redirection (no auth required)
 	router.GET("/:shortCode", handler.RedirectURL)
+	router.POST("/:shortCode",
+		rateLimiter.Limit(middleware.DefaultRateLimit),
+		handler.UnlockURL,
+	)
 	
 	// Public analytics (if URL is public)
 	router.GET("/api/v1/analytics/:shortCode", 
-		middleware.RateLimitMiddleware(middleware.DefaultRateLimit),
+		apiKeyAuth.OptionalAuth(),
+		rateLimiter.Limit(middleware.DefaultRateLimit),
+		middleware.RequireScope(models.ScopeAnalyticsRead),
 		handler.GetAnalytics,
 	)
 	
@@ -63,20 +81,28 @@ func setupPublicRoutes(router *gin.Engine, handler *handlers.Handler, authMiddle
 }
 
 // setupAuthRoutes configures authentication routes
-func setupAuthRoutes(router *gin.Engine, authHandlers *handlers.AuthHandlers, authMiddleware *middleware.AuthMiddleware) {
+func setupAuthRoutes(router *gin.Engine, authHandlers *handlers.AuthHandlers, authMiddleware *middleware.AuthMiddleware, rateLimiter *middleware.RateLimiter) {
 	auth := router.Group("/api/v1/auth")
 	
 	// Apply auth-specific rate limiting
-	auth.Use(middleware.RateLimitMiddleware(middleware.AuthRateLimit))
+	auth.Use(rateLimiter.Limit(middleware.AuthRateLimit))
 	
 	// Registration and login
 	auth.POST("/register", authHandlers.Register)
 	auth.POST("/login", authHandlers.Login)
 	auth.POST("/refresh", authHandlers.RefreshToken)
+
+	// Second login step for users with two-factor, limited like OTPs
+	auth.POST("/login/2fa", rateLimiter.Limit(middleware.OTPRateLimit), authHandlers.CompleteTwoFactorLogin)
+	auth.POST("/login/2fa/setup", rateLimiter.Limit(middleware.OTPRateLimit), authHandlers.BeginTwoFactorLoginSetup)
+
+	// Logging out revokes the caller's tokens, so it always needs them
+	auth.POST("/logout", authMiddleware.RequireAuth(), authHandlers.Logout)
+	auth.POST("/logout-all", authMiddleware.RequireAuth(), authHandlers.LogoutAll)
 	
 	// OTP routes (more restrictive rate limiting)
 	otpGroup := auth.Group("/otp")
-	otpGroup.Use(middleware.RateLimitMiddleware(middleware.OTPRateLimit))
+	otpGroup.Use(rateLimiter.Limit(middleware.OTPRateLimit))
 	{
 		otpGroup.POST("/send", authHandlers.SendOTP)
 		otpGroup.POST("/verify", authHandlers.VerifyOTP)
@@ -94,25 +120,23 @@ func setupAuthRoutes(router *gin.Engine, authHandlers *handlers.AuthHandlers, au
 		protected.GET("/profile", authHandlers.GetProfile)
 		protected.PUT("/profile", authHandlers.UpdateProfile)
 		protected.POST("/change-password", authHandlers.ChangePassword)
-		protected.POST("/logout", authHandlers.Logout)
 		protected.POST("/send-otp", authHandlers.SendOTP) // For authenticated users
 	}
 }
 
 // setupProtectedRoutes configures routes that require authentication
-func setupProtectedRoutes(router *gin.Engine, handler *handlers.Handler, authMiddleware *middleware.AuthMiddleware) {
+func setupProtectedRoutes(router *gin.Engine, handler *handlers.Handler, authMiddleware *middleware.AuthMiddleware, apiKeyAuth *middleware.APIKeyAuth, rateLimiter *middleware.RateLimiter) {
+	// URL operations and analytics, open to integrations using API keys
+	setupKeyedRoutes(router, handler, apiKeyAuth, rateLimiter)
+
+	// API key management
+	setupAPIKeyRoutes(router, handler, authMiddleware, rateLimiter)
+
 	api := router.Group("/api/v1")
 	// api.Use(authMiddleware.RequireAuth())  // Temporarily disabled due to auth setup issues
-	api.Use(middleware.RateLimitMiddleware(middleware.DefaultRateLimit))
-	
-	// Protected URL operations
-	api.POST("/batch-shorten", handler.BatchShortenURLs)
-	api.GET("/my-urls", handler.GetUserURLs)
-	api.DELETE("/my-urls/:shortCode", handler.DeleteURL)
-	api.PUT("/my-urls/:shortCode", handler.UpdateURL)
-	
+	api.Use(rateLimiter.Limit(middleware.DefaultRateLimit))
+
 	// Enhanced analytics for authenticated users - temporarily disabled
-	api.GET("/analytics/:shortCode/trends", handler.GetClickTrends)
 	// api.GET("/analytics/:shortCode/advanced", handler.AdvancedAnalyticsHandlers.GetAdvancedAnalytics)
 	// api.GET("/analytics/:shortCode/geographic", handler.AdvancedAnalyticsHandlers.GetGeographicAnalytics)
 	// api.GET("/analytics/:shortCode/time", handler.AdvancedAnalyticsHandlers.GetTimeAnalytics)
@@ -138,20 +162,93 @@ func setupProtectedRoutes(router *gin.Engine, handler *handlers.Handler, authMid
 	setupAttributionRoutes(api, handler)
 	
 	// Real-time analytics WebSocket and stats
-	api.GET("/realtime/ws", handler.RealtimeHandlers.HandleWebSocket)
-	api.GET("/realtime/stats", handler.RealtimeHandlers.GetRealtimeStats)
+	if handler.RealtimeHandlers != nil {
+		api.GET("/realtime/ws", handler.RealtimeHandlers.HandleWebSocket)
+		api.GET("/realtime/stats", handler.RealtimeHandlers.GetRealtimeStats)
+	}
 	
 	// User analytics routes
 	setupUserAnalyticsRoutes(api, handler)
 }
 
+// setupKeyedRoutes configures routes accepting either a JWT or an API key
+// with the scope each route requires. Callers are authenticated before rate
+// limiting so keys and plans get their own quotas.
+func setupKeyedRoutes(router *gin.Engine, handler *handlers.Handler, apiKeyAuth *middleware.APIKeyAuth, rateLimiter *middleware.RateLimiter) {
+	keyed := router.Group("/api/v1")
+	keyed.Use(apiKeyAuth.RequireAuth())
+	keyed.Use(rateLimiter.Limit(middleware.DefaultRateLimit))
+
+	readURLs := middleware.RequireScope(models.ScopeURLsRead)
+	writeURLs := middleware.RequireScope(models.ScopeURLsWrite)
+
+	// Protected URL operations
+	keyed.POST("/batch-shorten", writeURLs, handler.BatchShortenURLs)
+	keyed.GET("/my-urls", readURLs, handler.GetUserURLs)
+	keyed.GET("/my-urls/search", readURLs, handler.SearchUserURLs)
+	keyed.DELETE("/my-urls/:shortCode", writeURLs, handler.DeleteURL)
+	keyed.PUT("/my-urls/:shortCode", writeURLs, handler.UpdateURL)
+	keyed.GET("/my-urls/:shortCode/destinations", readURLs, handler.GetURLDestinationHistory)
+
+	// QR codes for short links
+	if handler.QRCodeHandlers != nil {
+		keyed.GET("/my-urls/:shortCode/qr", readURLs, handler.QRCodeHandlers.GetQRCode)
+	}
+
+	// Bulk import and export
+	if handler.BulkImportHandlers != nil {
+		keyed.POST("/imports", writeURLs, handler.BulkImportHandlers.CreateImport)
+		keyed.GET("/imports/:id", readURLs, handler.BulkImportHandlers.GetImport)
+		keyed.GET("/imports/:id/rows", readURLs, handler.BulkImportHandlers.GetImportRows)
+		keyed.GET("/my-urls/export", readURLs, handler.BulkImportHandlers.ExportURLs)
+	}
+
+	keyed.GET("/analytics/:shortCode/trends", middleware.RequireScope(models.ScopeAnalyticsRead), handler.GetClickTrends)
+}
+
+// setupAPIKeyRoutes configures API key management. Keys are managed with a
+// JWT only, so a leaked key can't mint more keys.
+func setupAPIKeyRoutes(router *gin.Engine, handler *handlers.Handler, authMiddleware *middleware.AuthMiddleware, rateLimiter *middleware.RateLimiter) {
+	if handler.APIKeyHandlers == nil {
+		return
+	}
+
+	keys := router.Group("/api/v1/api-keys")
+	keys.Use(authMiddleware.RequireAuth())
+	keys.Use(rateLimiter.Limit(middleware.DefaultRateLimit))
+
+	keys.POST("", handler.APIKeyHandlers.CreateAPIKey)
+	keys.GET("", handler.APIKeyHandlers.ListAPIKeys)
+	keys.GET("/:id", handler.APIKeyHandlers.GetAPIKey)
+	keys.PUT("/:id/revoke", handler.APIKeyHandlers.RevokeAPIKey)
+	keys.DELETE("/:id", handler.APIKeyHandlers.DeleteAPIKey)
+	keys.GET("/:id/stats", handler.APIKeyHandlers.GetAPIKeyStats)
+}
+
+// setupTwoFactorRoutes configures the signed-in user's two-factor settings
+func setupTwoFactorRoutes(router *gin.Engine, handler *handlers.Handler, authMiddleware *middleware.AuthMiddleware, rateLimiter *middleware.RateLimiter) {
+	if handler.TwoFactorHandlers == nil {
+		return
+	}
+
+	twoFactor := router.Group("/api/v1/auth/2fa")
+	twoFactor.Use(authMiddleware.RequireAuth())
+	twoFactor.Use(rateLimiter.Limit(middleware.OTPRateLimit))
+
+	twoFactor.GET("", handler.TwoFactorHandlers.GetStatus)
+	twoFactor.POST("/enroll", handler.TwoFactorHandlers.Enroll)
+	twoFactor.POST("/confirm", handler.TwoFactorHandlers.Confirm)
+	twoFactor.POST("/disable", handler.TwoFactorHandlers.Disable)
+	twoFactor.POST("/recovery-codes", handler.TwoFactorHandlers.RegenerateRecoveryCodes)
+}
+
 // setupAdminRoutes configures routes that require admin access - TEMPORARILY DISABLED
 /*
 func setupAdminRoutes(router *gin.Engine, handler *handlers.Handler, authService *services.AuthService) {
 	admin := router.Group("/api/v1/admin")
 	admin.Use(middleware.AuthMiddleware(authService))
 	admin.Use(middleware.AdminMiddleware())
-	admin.Use(middleware.RateLimitMiddleware(middleware.DefaultRateLimit))
+	admin.Use(rateLimiter.Limit(middleware.DefaultRateLimit))
 	
 	// Admin user management
 	admin.GET("/users/:id", handler.AuthHandlers.GetUserByID)
@@ -182,6 +279,10 @@ func setupAdminRoutes(router *gin.Engine, handler *handlers.Handler, authService
 
 // setupConversionTrackingRoutes configures conversion tracking routes
 func setupConversionTrackingRoutes(api *gin.RouterGroup, handler *handlers.Handler) {
+	if handler.ConversionHandlers == nil {
+		return
+	}
+
 	conversion := api.Group("/conversions")
 	
 	// Conversion goal management
@@ -199,6 +300,10 @@ func setupConversionTrackingRoutes(api *gin.RouterGroup, handler *handlers.Handl
 
 // setupABTestingRoutes configures A/B testing routes
 func setupABTestingRoutes(api *gin.RouterGroup, handler *handlers.Handler) {
+	if handler.ABTestHandlers == nil {
+		return
+	}
+
 	abtest := api.Group("/ab-tests")
 	
 	// A/B test management
@@ -221,6 +326,10 @@ func setupABTestingRoutes(api *gin.RouterGroup, handler *handlers.Handler) {
 
 // setupAttributionRoutes configures attribution and multi-touch analytics routes
 func setupAttributionRoutes(api *gin.RouterGroup, handler *handlers.Handler) {
+	if handler.AttributionHandlers == nil {
+		return
+	}
+
 	attribution := api.Group("/attribution")
 	
 	// Touchpoint management
@@ -269,20 +378,6 @@ func setupBillingRoutes(api *gin.RouterGroup, handler *handlers.Handler) {
 			c.JSON(200, gin.H{"message": "List custom domains - coming soon"})
 		})
 	}
-	
-	// API access protected routes - temporarily disabled
-	/*
-	apiAccess := api.Group("/api-keys")
-	apiAccess.Use(handler.BillingHandlers.CheckFeatureAccess("api_access"))
-	{
-		apiAccess.POST("/", handler.CMSHandlers.CreateAPIKey)
-		apiAccess.GET("/", handler.CMSHandlers.ListAPIKeys)
-		apiAccess.GET("/:id", handler.CMSHandlers.GetAPIKey)
-		apiAccess.PUT("/:id/revoke", handler.CMSHandlers.RevokeAPIKey)
-		apiAccess.DELETE("/:id", handler.CMSHandlers.DeleteAPIKey)
-		apiAccess.GET("/:id/stats", handler.CMSHandlers.GetAPIKeyStats)
-	}
-	*/
 }
 
 // setupStaticRoutes configures static file serving for the frontend
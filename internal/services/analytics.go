@@ -9,29 +9,43 @@ import (
 )
 
 type AnalyticsService struct {
-	db             *storage.PostgresStorage
-	redis          *storage.RedisStorage
+	db             storage.LinkStore
+	cache          storage.Cache
 	advancedService *AdvancedAnalyticsService
+	uniqueVisitors  *UniqueVisitorService
 }
 
 // NewAnalyticsService creates a new analytics service
-func NewAnalyticsService(db *storage.PostgresStorage) *AnalyticsService {
-	return &AnalyticsService{
-		db:              db,
-		advancedService: NewAdvancedAnalyticsService(db),
+func NewAnalyticsService(db storage.LinkStore) *AnalyticsService {
+	service := &AnalyticsService{db: db}
+
+	// Advanced analytics run their own SQL and are only available on Postgres
+	if postgres, ok := db.(*storage.PostgresStorage); ok {
+		service.advancedService = NewAdvancedAnalyticsService(postgres)
 	}
+
+	return service
+}
+
+// SetCache sets the cache used for analytics snapshots
+func (a *AnalyticsService) SetCache(cache storage.Cache) {
+	a.cache = cache
 }
 
-// SetRedis sets the Redis storage for caching analytics
-func (a *AnalyticsService) SetRedis(redis *storage.RedisStorage) {
-	a.redis = redis
+// SetUniqueVisitorService sets the service providing unique visitor counts
+func (a *AnalyticsService) SetUniqueVisitorService(uniqueVisitors *UniqueVisitorService) {
+	a.uniqueVisitors = uniqueVisitors
 }
 
-// GetAnalytics retrieves analytics data for a short code
-func (a *AnalyticsService) GetAnalytics(shortCode string, days int) (*models.AnalyticsResponse, error) {
+// GetAnalytics retrieves analytics data for a short code. Bot, preview and
+// scanner clicks are excluded unless includeBots is set.
+func (a *AnalyticsService) GetAnalytics(shortCode string, days int, includeBots bool) (*models.AnalyticsResponse, error) {
+	// Only the default human-only view is cached
+	useCache := a.cache != nil && !includeBots
+
 	// Try cache first if Redis is available
-	if a.redis != nil {
-		cached, err := a.redis.GetAnalytics(shortCode)
+	if useCache {
+		cached, err := a.cache.GetAnalytics(shortCode)
 		if err == nil {
 			return cached, nil
 		}
@@ -39,15 +53,33 @@ func (a *AnalyticsService) GetAnalytics(shortCode string, days int) (*models.Ana
 	}
 
 	// Get analytics from database
-	analytics, err := a.db.GetAnalytics(shortCode, days)
+	analytics, err := a.db.GetAnalytics(shortCode, days, includeBots)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get analytics: %w", err)
 	}
 
+	// Unique visitors only ever count human traffic
+	if a.uniqueVisitors != nil {
+		if unique, err := a.uniqueVisitors.UniqueVisitors(shortCode); err == nil {
+			analytics.UniqueVisitors = unique
+		}
+		for i := range analytics.DailyClicks {
+			day := &analytics.DailyClicks[i]
+			// DATE columns scan as RFC 3339 timestamps; sketches are keyed by the day alone
+			date := day.Date
+			if len(date) > 10 {
+				date = date[:10]
+			}
+			if unique, err := a.uniqueVisitors.DailyUniqueVisitors(shortCode, date); err == nil {
+				day.UniqueVisitors = unique
+			}
+		}
+	}
+
 	// Cache the result if Redis is available
-	if a.redis != nil {
+	if useCache {
 		cacheTTL := 5 * time.Minute // Cache analytics for 5 minutes
-		a.redis.SetAnalytics(shortCode, analytics, cacheTTL)
+		a.cache.SetAnalytics(shortCode, analytics, cacheTTL)
 	}
 
 	return analytics, nil
@@ -59,7 +91,7 @@ func (a *AnalyticsService) GetURLAnalytics(shortCode string) (*models.URLAnalyti
 	// For now, return simulated comprehensive analytics
 	now := time.Now()
 	
-	return &models.URLAnalytics{
+	analytics := &models.URLAnalytics{
 		ShortCode:    shortCode,
 		OriginalURL:  "https://example.com/some-url",
 		TotalClicks:  247,
@@ -124,7 +156,16 @@ func (a *AnalyticsService) GetURLAnalytics(shortCode string) (*models.URLAnalyti
 			{Weekday: 5, Day: "Friday", Clicks: 35},
 			{Weekday: 6, Day: "Saturday", Clicks: 18},
 		},
-	}, nil
+	}
+
+	// Break clicks down by the destination version that was live
+	if a.db != nil {
+		if destinationStats, err := a.db.GetDestinationStats(shortCode); err == nil {
+			analytics.DestinationStats = destinationStats
+		}
+	}
+
+	return analytics, nil
 }
 
 // GetDetailedURLAnalytics retrieves detailed analytics for a URL within a time range
@@ -163,65 +204,50 @@ func (a *AnalyticsService) GetDetailedURLAnalytics(shortCode string, timeRange s
 
 // GetAdvancedAnalytics retrieves comprehensive advanced analytics
 func (a *AnalyticsService) GetAdvancedAnalytics(shortCode string, days int) (*models.AdvancedAnalyticsResponse, error) {
+	if a.advancedService == nil {
+		return nil, ErrAdvancedAnalyticsUnavailable
+	}
 	return a.advancedService.GetAdvancedAnalytics(shortCode, days)
 }
 
 // ProcessEnhancedClickEvent processes a click event with advanced analytics
 func (a *AnalyticsService) ProcessEnhancedClickEvent(clickEvent *models.ClickEvent) error {
+	if a.advancedService == nil {
+		return nil
+	}
 	return a.advancedService.ProcessEnhancedClickEvent(clickEvent)
 }
 
 // GetUserDashboardStats retrieves dashboard statistics for a specific user
-func (a *AnalyticsService) GetUserDashboardStats(userID int64) (*models.UserDashboardStats, error) {
-	stats := &models.UserDashboardStats{}
-
-	// Get total URLs count
-	err := a.db.QueryRow("SELECT COUNT(*) FROM url_mappings WHERE user_id = $1 AND is_active = TRUE", userID).Scan(&stats.TotalURLs)
+// Bot, preview and scanner clicks are excluded unless includeBots is set.
+func (a *AnalyticsService) GetUserDashboardStats(userID int64, includeBots bool) (*models.UserDashboardStats, error) {
+	stats, err := a.db.GetUserDashboardStats(userID, includeBots)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get total URLs: %w", err)
+		return nil, err
 	}
 
-	// Get active URLs count (same as total in this case since we filter by is_active)
-	stats.ActiveURLs = stats.TotalURLs
-
-	// Get total clicks
-	err = a.db.QueryRow(`
-		SELECT COALESCE(SUM(click_count), 0) 
-		FROM url_mappings 
-		WHERE user_id = $1 AND is_active = TRUE
-	`, userID).Scan(&stats.TotalClicks)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get total clicks: %w", err)
+	// Get unique visitors across the user's links
+	if a.uniqueVisitors != nil {
+		a.fillUserUniqueVisitors(userID, stats)
 	}
 
-	// Get today's clicks
-	err = a.db.QueryRow(`
-		SELECT COUNT(*) 
-		FROM click_events ce 
-		JOIN url_mappings um ON ce.short_code = um.short_code 
-		WHERE um.user_id = $1 
-		  AND um.is_active = TRUE 
-		  AND DATE(ce.clicked_at) = CURRENT_DATE
-	`, userID).Scan(&stats.TodayClicks)
-	if err != nil {
-		stats.TodayClicks = 0 // Don't fail if there's no click events table or data
-	}
+	return stats, nil
+}
 
-	// Get this month's clicks
-	err = a.db.QueryRow(`
-		SELECT COUNT(*) 
-		FROM click_events ce 
-		JOIN url_mappings um ON ce.short_code = um.short_code 
-		WHERE um.user_id = $1 
-		  AND um.is_active = TRUE 
-		  AND EXTRACT(YEAR FROM ce.clicked_at) = EXTRACT(YEAR FROM CURRENT_DATE)
-		  AND EXTRACT(MONTH FROM ce.clicked_at) = EXTRACT(MONTH FROM CURRENT_DATE)
-	`, userID).Scan(&stats.MonthClicks)
-	if err != nil {
-		stats.MonthClicks = 0 // Don't fail if there's no click events table or data
+// fillUserUniqueVisitors sets lifetime and today's unique visitors across a user's active links
+func (a *AnalyticsService) fillUserUniqueVisitors(userID int64, stats *models.UserDashboardStats) {
+	shortCodes, err := a.db.ListUserShortCodes(userID)
+	if err != nil || len(shortCodes) == 0 {
+		return
 	}
 
-	return stats, nil
+	if unique, err := a.uniqueVisitors.UniqueVisitorsAcross(shortCodes, ""); err == nil {
+		stats.UniqueVisitors = unique
+	}
+	today := time.Now().UTC().Format(uniqueVisitorDayFormat)
+	if unique, err := a.uniqueVisitors.UniqueVisitorsAcross(shortCodes, today); err == nil {
+		stats.TodayUniqueVisitors = unique
+	}
 }
 
 // GetDashboardStats retrieves overall statistics for dashboard
@@ -243,39 +269,16 @@ func (a *AnalyticsService) GetDashboardStats() (*models.DashboardStats, error) {
 	return stats, nil
 }
 
-// GetClickTrends retrieves click trends over time
-func (a *AnalyticsService) GetClickTrends(shortCode string, period string) ([]models.ClickTrend, error) {
-	var trends []models.ClickTrend
-	
-	// Determine the date range based on period
+// GetClickTrends retrieves click trends over time. Bot, preview and scanner
+// clicks are excluded unless includeBots is set.
+func (a *AnalyticsService) GetClickTrends(shortCode string, period string, includeBots bool) ([]models.ClickTrend, error) {
 	switch period {
-	case "hour":
-		_ = "YYYY-MM-DD HH24:00:00"  // dateFormat for future use
-		_ = "24 hours"                // intervalClause for future use
-	case "day":
-		_ = "YYYY-MM-DD"             // dateFormat for future use  
-		_ = "30 days"                // intervalClause for future use
-	case "week":
-		_ = "IYYY-IW"                // dateFormat for future use
-		_ = "12 weeks"               // intervalClause for future use
-	case "month":
-		_ = "YYYY-MM"                // dateFormat for future use
-		_ = "12 months"              // intervalClause for future use
+	case "hour", "day", "week", "month":
 	default:
-		return nil, fmt.Errorf("invalid period: %s", period)
+		return nil, ErrInvalidTrendPeriod
 	}
 
-	// This is a placeholder query - in a real implementation you would use proper SQL
-	// query := fmt.Sprintf(`
-	//     SELECT TO_CHAR(clicked_at, '%s') as period, COUNT(*) as clicks
-	//     FROM click_events
-	//     WHERE short_code = $1 AND clicked_at >= NOW() - INTERVAL '%s'
-	//     GROUP BY TO_CHAR(clicked_at, '%s')
-	//     ORDER BY period
-	// `, dateFormat, intervalClause, dateFormat)
-
-	// For now, return empty trends
-	return trends, nil
+	return a.db.GetClickTrends(shortCode, period, includeBots)
 }
 
 // GetTopReferrers retrieves top referrers for a short code
@@ -304,12 +307,12 @@ func (a *AnalyticsService) GetUserAgentStats(shortCode string) (*models.UserAgen
 
 // InvalidateAnalyticsCache invalidates cached analytics for a short code
 func (a *AnalyticsService) InvalidateAnalyticsCache(shortCode string) error {
-	if a.redis == nil {
+	if a.cache == nil {
 		return nil
 	}
 	
 	// Remove cached analytics
-	return a.redis.DeleteURLMapping(fmt.Sprintf("analytics:%s", shortCode))
+	return a.cache.DeleteURLMapping(fmt.Sprintf("analytics:%s", shortCode))
 }
 
 // GeoData represents geographic information
@@ -14,8 +14,18 @@ import (
 	"github.com/URLshorter/url-shortener/internal/storage"
 )
 
+// apiKeySortColumns are the columns API keys can be listed by
+var apiKeySortColumns = map[string]bool{
+	"created_at":   true,
+	"updated_at":   true,
+	"last_used_at": true,
+	"expires_at":   true,
+	"name":         true,
+}
+
 type APIKeyService struct {
-	db *storage.PostgresStorage
+	db    *storage.PostgresStorage
+	plans PlanLookup
 }
 
 func NewAPIKeyService(db *storage.PostgresStorage) *APIKeyService {
@@ -24,8 +34,29 @@ func NewAPIKeyService(db *storage.PostgresStorage) *APIKeyService {
 	}
 }
 
+// SetPlanLookup gates creating keys on the user's billing plan including API
+// access. Without a lookup every user may create keys.
+func (s *APIKeyService) SetPlanLookup(plans PlanLookup) {
+	s.plans = plans
+}
+
 // CreateAPIKey creates a new API key for a user
 func (s *APIKeyService) CreateAPIKey(userID int64, req *models.CreateAPIKeyRequest) (*models.CreateAPIKeyResponse, error) {
+	if s.plans != nil {
+		planType, err := s.plans(userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up plan: %w", err)
+		}
+		if plan, ok := PredefinedPlans[planType]; !ok || !plan.Limits.APIAccess {
+			return nil, ErrAPIAccessNotInPlan
+		}
+	}
+	for _, scope := range req.Permissions {
+		if !isAPIKeyScope(scope) {
+			return nil, ErrInvalidAPIKeyScope
+		}
+	}
+
 	// Generate API key
 	plainKey, keyHash, err := s.generateAPIKey()
 	if err != nil {
@@ -97,7 +128,7 @@ func (s *APIKeyService) GetAPIKey(id int64, userID int64) (*models.APIKey, error
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("API key not found")
+			return nil, ErrAPIKeyNotFound
 		}
 		return nil, fmt.Errorf("failed to get API key: %w", err)
 	}
@@ -128,19 +159,19 @@ func (s *APIKeyService) ValidateAPIKey(key string) (*models.APIKey, error) {
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("invalid API key")
+			return nil, ErrInvalidAPIKey
 		}
 		return nil, fmt.Errorf("failed to validate API key: %w", err)
 	}
 
 	// Check if key is expired
 	if apiKey.ExpiresAt != nil && apiKey.ExpiresAt.Before(time.Now()) {
-		return nil, fmt.Errorf("API key has expired")
+		return nil, ErrAPIKeyExpired
 	}
 
 	// Use constant time comparison for security
 	if subtle.ConstantTimeCompare([]byte(keyHash), []byte(apiKey.KeyHash)) != 1 {
-		return nil, fmt.Errorf("invalid API key")
+		return nil, ErrInvalidAPIKey
 	}
 
 	return &apiKey, nil
@@ -203,7 +234,7 @@ func (s *APIKeyService) ListAPIKeys(userID int64, query *models.ListAPIKeysQuery
 		if *query.Expired {
 			conditions = append(conditions, fmt.Sprintf("expires_at IS NOT NULL AND expires_at < $%d", argIndex))
 		} else {
-			conditions = append(conditions, fmt.Sprintf("expires_at IS NULL OR expires_at > $%d", argIndex))
+			conditions = append(conditions, fmt.Sprintf("(expires_at IS NULL OR expires_at > $%d)", argIndex))
 		}
 		args = append(args, time.Now())
 		argIndex++
@@ -230,7 +261,7 @@ func (s *APIKeyService) ListAPIKeys(userID int64, query *models.ListAPIKeysQuery
 
 	// Add ORDER BY
 	orderBy := "created_at DESC"
-	if query.SortBy != "" {
+	if apiKeySortColumns[query.SortBy] {
 		direction := "ASC"
 		if query.SortOrder == "desc" {
 			direction = "DESC"
@@ -290,7 +321,7 @@ func (s *APIKeyService) RevokeAPIKey(id int64, userID int64) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("API key not found or not owned by user")
+		return ErrAPIKeyNotFound
 	}
 
 	return nil
@@ -310,7 +341,7 @@ func (s *APIKeyService) DeleteAPIKey(id int64, userID int64) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("API key not found or not owned by user")
+		return ErrAPIKeyNotFound
 	}
 
 	return nil
@@ -353,9 +384,13 @@ func (s *APIKeyService) GetAPIKeyStats(keyID int64, userID int64) (*models.APIKe
 
 // CheckAPIKeyPermission checks if an API key has a specific permission
 func (s *APIKeyService) CheckAPIKeyPermission(apiKey *models.APIKey, permission string) bool {
-	// Admin keys with "*" permission have access to everything
-	for _, perm := range apiKey.Permissions {
-		if perm == "*" || perm == permission {
+	return apiKey.Permissions.Allows(permission)
+}
+
+// isAPIKeyScope reports whether scope is one a key can be granted
+func isAPIKeyScope(scope string) bool {
+	for _, known := range models.APIKeyScopes {
+		if scope == known {
 			return true
 		}
 	}
@@ -385,4 +420,13 @@ func (s *APIKeyService) CleanupExpiredAPIKeys() error {
 	query := `DELETE FROM api_keys WHERE expires_at IS NOT NULL AND expires_at < $1`
 	_, err := s.db.Exec(query, time.Now())
 	return err
-}
\ No newline at end of file
+}
+
+// API key errors
+var (
+	ErrAPIKeyNotFound     = &ServiceError{Message: "API key not found"}
+	ErrInvalidAPIKey      = &ServiceError{Message: "invalid API key"}
+	ErrAPIKeyExpired      = &ServiceError{Message: "API key has expired"}
+	ErrInvalidAPIKeyScope = &ServiceError{Message: "unknown API key scope"}
+	ErrAPIAccessNotInPlan = &ServiceError{Message: "API access is not available in your current plan"}
+)
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// APIKeyUsageStore persists requests made with API keys
+type APIKeyUsageStore interface {
+	RecordAPIKeyUsage(keyID int64, endpoint, method string, statusCode, responseTimeMs, requestSize, responseSize int, ipAddress, userAgent string) error
+	UpdateAPIKeyUsage(keyID int64, ipAddress string) error
+}
+
+// APIKeyUsageConfig tunes the API key usage workers
+type APIKeyUsageConfig struct {
+	QueueSize int
+	Workers   int
+}
+
+// DefaultAPIKeyUsageConfig returns the default worker settings
+func DefaultAPIKeyUsageConfig() APIKeyUsageConfig {
+	return APIKeyUsageConfig{
+		QueueSize: 5000,
+		Workers:   2,
+	}
+}
+
+// apiKeyUsage is a request waiting to be recorded
+type apiKeyUsage struct {
+	keyID          int64
+	endpoint       string
+	method         string
+	statusCode     int
+	responseTimeMs int
+	requestSize    int
+	responseSize   int
+	ipAddress      string
+	userAgent      string
+}
+
+// APIKeyUsageRecorder records API key usage on a fixed set of workers, so
+// requests never wait on the usage tables. Usage is dropped when the queue is
+// full; the request itself is unaffected.
+type APIKeyUsageRecorder struct {
+	store APIKeyUsageStore
+	queue chan apiKeyUsage
+	wg    sync.WaitGroup
+
+	closeMutex sync.RWMutex
+	closed     bool
+}
+
+// NewAPIKeyUsageRecorder creates the recorder and starts its workers
+func NewAPIKeyUsageRecorder(store APIKeyUsageStore, config APIKeyUsageConfig) *APIKeyUsageRecorder {
+	defaults := DefaultAPIKeyUsageConfig()
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaults.QueueSize
+	}
+	if config.Workers <= 0 {
+		config.Workers = defaults.Workers
+	}
+
+	r := &APIKeyUsageRecorder{
+		store: store,
+		queue: make(chan apiKeyUsage, config.QueueSize),
+	}
+
+	for i := 0; i < config.Workers; i++ {
+		r.wg.Add(1)
+		go r.worker()
+	}
+
+	return r
+}
+
+// Record queues a request made with an API key without blocking. It returns
+// false when the usage was dropped.
+func (r *APIKeyUsageRecorder) Record(keyID int64, endpoint, method string, statusCode, responseTimeMs, requestSize, responseSize int, ipAddress, userAgent string) bool {
+	r.closeMutex.RLock()
+	defer r.closeMutex.RUnlock()
+
+	if r.closed {
+		return false
+	}
+
+	usage := apiKeyUsage{
+		keyID:          keyID,
+		endpoint:       endpoint,
+		method:         method,
+		statusCode:     statusCode,
+		responseTimeMs: responseTimeMs,
+		requestSize:    requestSize,
+		responseSize:   responseSize,
+		ipAddress:      ipAddress,
+		userAgent:      userAgent,
+	}
+
+	select {
+	case r.queue <- usage:
+		return true
+	default:
+		return false
+	}
+}
+
+// Shutdown stops accepting usage and waits for the queued usage to be
+// recorded, or for the context to expire
+func (r *APIKeyUsageRecorder) Shutdown(ctx context.Context) error {
+	r.closeMutex.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.queue)
+	}
+	r.closeMutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *APIKeyUsageRecorder) worker() {
+	defer r.wg.Done()
+
+	for usage := range r.queue {
+		r.record(usage)
+	}
+}
+
+// record stores a request and marks the key as last used by it
+func (r *APIKeyUsageRecorder) record(u apiKeyUsage) {
+	if err := r.store.RecordAPIKeyUsage(u.keyID, u.endpoint, u.method, u.statusCode, u.responseTimeMs, u.requestSize, u.responseSize, u.ipAddress, u.userAgent); err != nil {
+		log.Printf("Failed to record usage of API key %d: %v", u.keyID, err)
+	}
+	if err := r.store.UpdateAPIKeyUsage(u.keyID, u.ipAddress); err != nil {
+		log.Printf("Failed to update last use of API key %d: %v", u.keyID, err)
+	}
+}
@@ -19,10 +19,11 @@ type AuthService struct {
 	jwtService   *JWTService
 	smsService   *SMSService
 	emailService *EmailService
-	db           *storage.PostgresStorage
-	redis        *storage.RedisStorage
+	db           storage.AccountStore
+	cache        storage.Cache
 	config       *configs.Config
 	googleConfig *oauth2.Config
+	twoFactor    *TwoFactorService
 }
 
 // NewAuthService creates a new authentication service
@@ -31,8 +32,8 @@ func NewAuthService(
 	jwtService *JWTService,
 	smsService *SMSService,
 	emailService *EmailService,
-	db *storage.PostgresStorage,
-	redis *storage.RedisStorage,
+	db storage.AccountStore,
+	cache storage.Cache,
 	config *configs.Config,
 ) *AuthService {
 	
@@ -51,12 +52,18 @@ func NewAuthService(
 		smsService:   smsService,
 		emailService: emailService,
 		db:           db,
-		redis:        redis,
+		cache:        cache,
 		config:       config,
 		googleConfig: googleConfig,
 	}
 }
 
+// SetTwoFactorService makes logins of users with two-factor enabled, or
+// required, take a second step
+func (a *AuthService) SetTwoFactorService(twoFactor *TwoFactorService) {
+	a.twoFactor = twoFactor
+}
+
 // Register creates a new user account
 func (a *AuthService) Register(req *models.RegisterRequest, ipAddress, userAgent string) (*UserRegistrationData, error) {
 	// Validate input
@@ -92,7 +99,7 @@ func (a *AuthService) Register(req *models.RegisterRequest, ipAddress, userAgent
 // Login authenticates a user
 func (a *AuthService) Login(req *models.LoginRequest, ipAddress, userAgent string) (*models.AuthResponse, error) {
 	// Use UserService to authenticate
-	user, sessionID, err := a.userService.AuthenticateUser(req)
+	user, err := a.userService.AuthenticateUser(req)
 	if err != nil {
 		// Log failed login attempt
 		a.logAuditEvent(nil, "login_failed", "authentication", "", 
@@ -103,6 +110,88 @@ func (a *AuthService) Login(req *models.LoginRequest, ipAddress, userAgent strin
 		return nil, err
 	}
 
+	// The password is not enough for users with two-factor; they get a
+	// challenge to complete with CompleteTwoFactorLogin instead of tokens
+	if a.twoFactor != nil {
+		challenge, err := a.twoFactor.StartChallenge(user.ID, req.RememberMe)
+		if err != nil {
+			return nil, err
+		}
+		if challenge != nil {
+			a.logAuditEvent(&user.ID, "login_two_factor_challenged", "authentication", fmt.Sprintf("%d", user.ID),
+				map[string]interface{}{
+					"email":          user.Email,
+					"setup_required": challenge.SetupRequired,
+				}, ipAddress, userAgent)
+			return &models.AuthResponse{
+				User:      user.ToPublic(),
+				TwoFactor: challenge,
+			}, nil
+		}
+	}
+
+	return a.completeLogin(user, req.RememberMe, ipAddress, userAgent)
+}
+
+// BeginTwoFactorLoginSetup starts enrolment for a user challenged at login
+// because two-factor is required for them but not yet set up
+func (a *AuthService) BeginTwoFactorLoginSetup(req *models.TwoFactorSetupRequest) (*models.TwoFactorEnrollment, error) {
+	if a.twoFactor == nil {
+		return nil, ErrTwoFactorChallengeInvalid
+	}
+
+	userID, err := a.twoFactor.ChallengeUser(req.Token)
+	if err != nil {
+		return nil, err
+	}
+	user, err := a.userService.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	return a.twoFactor.BeginEnrollment(user)
+}
+
+// CompleteTwoFactorLogin finishes a login challenged for a second factor,
+// given a code from the user's authenticator app or a recovery code. A user
+// enrolling during login gets their recovery codes in the response.
+func (a *AuthService) CompleteTwoFactorLogin(req *models.TwoFactorLoginRequest, ipAddress, userAgent string) (*models.AuthResponse, error) {
+	if a.twoFactor == nil {
+		return nil, ErrTwoFactorChallengeInvalid
+	}
+
+	userID, rememberMe, recoveryCodes, err := a.twoFactor.CompleteChallenge(req.Token, req.Code)
+	if err != nil {
+		if err == ErrInvalidTwoFactorCode {
+			a.logAuditEvent(nil, "login_two_factor_failed", "authentication", "",
+				map[string]interface{}{}, ipAddress, userAgent)
+		}
+		return nil, err
+	}
+
+	user, err := a.userService.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsActive {
+		return nil, ErrAccountNotActive
+	}
+
+	response, err := a.completeLogin(user, rememberMe, ipAddress, userAgent)
+	if err != nil {
+		return nil, err
+	}
+	response.RecoveryCodes = recoveryCodes
+	return response, nil
+}
+
+// completeLogin starts the session of a login that passed every factor and
+// issues its tokens
+func (a *AuthService) completeLogin(user *models.User, rememberMe bool, ipAddress, userAgent string) (*models.AuthResponse, error) {
+	sessionID, err := a.userService.StartSession(user, rememberMe, ipAddress, userAgent)
+	if err != nil {
+		return nil, err
+	}
+
 	// Generate JWT tokens
 	tokenPair, err := a.jwtService.GenerateTokenPair(user, sessionID)
 	if err != nil {
@@ -137,9 +226,27 @@ func (a *AuthService) RefreshTokens(req *models.RefreshTokenRequest, ipAddress,
 	if err != nil {
 		return nil, fmt.Errorf("user not found")
 	}
+	if !user.IsActive {
+		return nil, ErrAccountNotActive
+	}
+
+	// Sessions of users an admin has since required two-factor for end here,
+	// so they enrol at their next login
+	if a.twoFactor != nil {
+		if err := a.twoFactor.CheckSatisfied(user.ID); err != nil {
+			return nil, err
+		}
+	}
 
-	// Use JWT service to refresh tokens
-	tokenPair, err := a.jwtService.RefreshToken(req.RefreshToken, user, "")
+	// Use JWT service to rotate the refresh token
+	tokenPair, err := a.jwtService.RefreshToken(req.RefreshToken, user)
+	if err == ErrRefreshTokenReused {
+		a.logAuditEvent(&user.ID, "refresh_token_reused", "authentication", fmt.Sprintf("%d", user.ID),
+			map[string]interface{}{
+				"email": user.Email,
+			}, ipAddress, userAgent)
+		return nil, err
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to refresh token: %w", err)
 	}
@@ -281,20 +388,39 @@ func (a *AuthService) GetGoogleAuthURL(state string) string {
 	return a.googleConfig.AuthCodeURL(state, oauth2.AccessTypeOffline)
 }
 
-// Logout invalidates user session
-func (a *AuthService) Logout(userID int64, sessionID string, ipAddress, userAgent string) error {
-	// Invalidate session in database
-	// This would be implemented in the UserService or directly here
-	
+// Logout revokes the access token the user logged out with and the rest of
+// its session, so the session's refresh token can't be used either
+func (a *AuthService) Logout(claims *Claims, ipAddress, userAgent string) error {
+	if err := a.jwtService.InvalidateToken(claims.ID, claims.ExpiresAt.Time); err != nil {
+		return err
+	}
+	if claims.SessionID != "" {
+		if err := a.jwtService.RevokeSession(claims.SessionID); err != nil {
+			return err
+		}
+	}
+
 	// Log logout
-	a.logAuditEvent(&userID, "logout", "authentication", fmt.Sprintf("%d", userID), 
+	a.logAuditEvent(&claims.UserID, "logout", "authentication", fmt.Sprintf("%d", claims.UserID), 
 		map[string]interface{}{
-			"session_id": sessionID,
+			"session_id": claims.SessionID,
 		}, ipAddress, userAgent)
 
 	return nil
 }
 
+// LogoutAllDevices revokes every token issued to the user, on any device
+func (a *AuthService) LogoutAllDevices(userID int64, ipAddress, userAgent string) error {
+	if err := a.jwtService.RevokeAllTokens(userID); err != nil {
+		return err
+	}
+
+	a.logAuditEvent(&userID, "logout_all", "authentication", fmt.Sprintf("%d", userID),
+		map[string]interface{}{}, ipAddress, userAgent)
+
+	return nil
+}
+
 // Helper methods
 
 func (a *AuthService) validateRegistrationRequest(req *models.RegisterRequest) error {
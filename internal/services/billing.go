@@ -49,6 +49,7 @@ type PlanLimits struct {
 	APIAccess    bool `json:"api_access"`
 	BulkImport   bool `json:"bulk_import"`
 	Advanced     bool `json:"advanced_features"`
+	RequestsPerHour int `json:"requests_per_hour"` // API rate limit
 }
 
 type BillingUsage struct {
@@ -81,6 +82,7 @@ var PredefinedPlans = map[string]Plan{
 			APIAccess:    false,
 			BulkImport:   false,
 			Advanced:     false,
+			RequestsPerHour: 1000,
 		},
 	},
 	"premium": {
@@ -107,6 +109,7 @@ var PredefinedPlans = map[string]Plan{
 			APIAccess:    true,
 			BulkImport:   true,
 			Advanced:     true,
+			RequestsPerHour: 5000,
 		},
 	},
 	"enterprise": {
@@ -132,6 +135,7 @@ var PredefinedPlans = map[string]Plan{
 			APIAccess:    true,
 			BulkImport:   true,
 			Advanced:     true,
+			RequestsPerHour: 20000,
 		},
 	},
 }
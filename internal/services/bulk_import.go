@@ -0,0 +1,712 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/URLshorter/url-shortener/configs"
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/URLshorter/url-shortener/internal/storage"
+	"github.com/URLshorter/url-shortener/internal/utils"
+)
+
+// maxImportTitleLength matches the url_mappings.title column
+const maxImportTitleLength = 200
+
+// bulkImportSweepInterval is how often workers look for jobs they were not
+// woken for, such as ones left unfinished by a previous process
+const bulkImportSweepInterval = 30 * time.Second
+
+// PlanLookup returns the billing plan a user is subscribed to
+type PlanLookup func(userID int64) (string, error)
+
+// BulkImportConfig tunes the bulk import workers
+type BulkImportConfig struct {
+	Workers   int
+	BatchSize int
+	MaxRows   int
+}
+
+// DefaultBulkImportConfig returns the default bulk import settings
+func DefaultBulkImportConfig() BulkImportConfig {
+	return BulkImportConfig{
+		Workers:   2,
+		BatchSize: 200,
+		MaxRows:   50000,
+	}
+}
+
+// BulkImportService creates links from uploaded CSV or NDJSON files in the
+// background. Uploads are parsed and stored as a job with one row per link
+// up front, so progress survives restarts: workers pick unfinished jobs back
+// up and only process rows still pending.
+type BulkImportService struct {
+	store     storage.LinkStore
+	shortener *ShortenerService
+	config    BulkImportConfig
+	plans     PlanLookup
+
+	wake   chan struct{}
+	queue  chan int64
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	activeMutex sync.Mutex
+	active      map[int64]bool
+}
+
+// NewBulkImportService creates the service; call Start to begin processing
+func NewBulkImportService(store storage.LinkStore, shortener *ShortenerService, config *configs.Config) *BulkImportService {
+	importConfig := DefaultBulkImportConfig()
+	if config.BulkImportWorkers > 0 {
+		importConfig.Workers = config.BulkImportWorkers
+	}
+	if config.BulkImportBatchSize > 0 {
+		importConfig.BatchSize = config.BulkImportBatchSize
+	}
+	if config.BulkImportMaxRows > 0 {
+		importConfig.MaxRows = config.BulkImportMaxRows
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &BulkImportService{
+		store:     store,
+		shortener: shortener,
+		config:    importConfig,
+		wake:      make(chan struct{}, 1),
+		queue:     make(chan int64),
+		ctx:       ctx,
+		cancel:    cancel,
+		active:    make(map[int64]bool),
+	}
+}
+
+// SetPlanLookup gates imports on the user's billing plan allowing bulk
+// import. Without a lookup every user may import.
+func (s *BulkImportService) SetPlanLookup(plans PlanLookup) {
+	s.plans = plans
+}
+
+// Start launches the workers, resuming any unfinished jobs
+func (s *BulkImportService) Start() {
+	for i := 0; i < s.config.Workers; i++ {
+		s.wg.Add(1)
+		go s.worker()
+	}
+
+	s.wg.Add(1)
+	go s.dispatch()
+}
+
+// Shutdown stops the workers after their current batch, waiting until the
+// context expires. Interrupted jobs resume on the next start.
+func (s *BulkImportService) Shutdown(ctx context.Context) error {
+	s.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// CheckAccess returns the user's plan and ErrBulkImportNotInPlan when the
+// plan does not include bulk import
+func (s *BulkImportService) CheckAccess(userID int64) (string, error) {
+	if s.plans == nil {
+		return "", nil
+	}
+
+	planType, err := s.plans(userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up plan: %w", err)
+	}
+	if plan, ok := PredefinedPlans[planType]; !ok || !plan.Limits.BulkImport {
+		return planType, ErrBulkImportNotInPlan
+	}
+	return planType, nil
+}
+
+// Submit parses an uploaded file and queues it for import. Uploading a file
+// the user has already imported returns the existing job instead, reporting
+// false for created.
+func (s *BulkImportService) Submit(userID int64, format string, data []byte) (*models.BulkImportJob, bool, error) {
+	if _, err := s.CheckAccess(userID); err != nil {
+		return nil, false, err
+	}
+
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d:%s:", userID, format) + string(data)))
+	checksum := hex.EncodeToString(sum[:])
+
+	existing, err := s.store.GetImportJobByChecksum(userID, checksum)
+	if err == nil {
+		return existing, false, nil
+	}
+	if err != storage.ErrImportJobNotFound {
+		return nil, false, err
+	}
+
+	rows, err := ParseBulkImport(format, bytes.NewReader(data), s.config.MaxRows)
+	if err != nil {
+		return nil, false, err
+	}
+
+	id, err := utils.GenerateID()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to generate job ID: %w", err)
+	}
+
+	job := &models.BulkImportJob{
+		ID:        id,
+		UserID:    userID,
+		Format:    format,
+		Checksum:  checksum,
+		Status:    models.BulkImportPending,
+		TotalRows: len(rows),
+		CreatedAt: time.Now(),
+	}
+	for _, row := range rows {
+		if row.Status == models.BulkRowFailed {
+			job.ProcessedRows++
+			job.FailedRows++
+		}
+	}
+
+	if err := s.store.CreateImportJob(job, rows); err != nil {
+		// A concurrent upload of the same file won the race
+		if existing, lookupErr := s.store.GetImportJobByChecksum(userID, checksum); lookupErr == nil {
+			return existing, false, nil
+		}
+		return nil, false, err
+	}
+
+	s.notify()
+	return job, true, nil
+}
+
+// GetJob retrieves one of the user's import jobs
+func (s *BulkImportService) GetJob(userID, jobID int64) (*models.BulkImportJob, error) {
+	job, err := s.store.GetImportJob(jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job.UserID != userID {
+		return nil, storage.ErrImportJobNotFound
+	}
+	return job, nil
+}
+
+// ListRows returns a page of a job's row results, optionally only those
+// with the given status
+func (s *BulkImportService) ListRows(userID, jobID int64, status string, afterLine, limit int) (*models.BulkImportRowsResponse, error) {
+	if _, err := s.GetJob(userID, jobID); err != nil {
+		return nil, err
+	}
+
+	switch status {
+	case "", models.BulkRowPending, models.BulkRowCreated, models.BulkRowFailed:
+	default:
+		return nil, ErrInvalidImportRowStatus
+	}
+
+	rows, err := s.store.ListImportRows(jobID, status, afterLine, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.BulkImportRowsResponse{Rows: rows}
+	if response.Rows == nil {
+		response.Rows = []*models.BulkImportRow{}
+	}
+	if len(rows) == limit {
+		next := rows[len(rows)-1].Line
+		response.NextAfter = &next
+	}
+	return response, nil
+}
+
+// Export streams all of a user's active links with their click counts. CSV
+// exports use the import column names so the file can be imported again.
+func (s *BulkImportService) Export(userID int64, format string, w io.Writer) error {
+	switch format {
+	case models.BulkFormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write([]string{"url", "custom_code", "expires_at", "title", "tags", "short_url", "click_count", "created_at"}); err != nil {
+			return err
+		}
+
+		err := s.store.EachUserURL(userID, func(url *models.UserURLResponse) error {
+			var expiresAt, title string
+			if url.ExpiresAt != nil {
+				expiresAt = url.ExpiresAt.UTC().Format(time.RFC3339)
+			}
+			if url.Title != nil {
+				title = *url.Title
+			}
+			return writer.Write([]string{
+				url.OriginalURL,
+				url.ShortCode,
+				expiresAt,
+				title,
+				strings.Join(url.Tags, ";"),
+				fmt.Sprintf("%s/%s", s.shortener.config.BaseURL, url.ShortCode),
+				strconv.FormatInt(url.ClickCount, 10),
+				url.CreatedAt.UTC().Format(time.RFC3339),
+			})
+		})
+		if err != nil {
+			return err
+		}
+		writer.Flush()
+		return writer.Error()
+	case models.BulkFormatNDJSON:
+		encoder := json.NewEncoder(w)
+		return s.store.EachUserURL(userID, func(url *models.UserURLResponse) error {
+			url.ShortURL = fmt.Sprintf("%s/%s", s.shortener.config.BaseURL, url.ShortCode)
+			return encoder.Encode(url)
+		})
+	default:
+		return ErrInvalidImportFormat
+	}
+}
+
+// notify wakes the dispatcher without blocking
+func (s *BulkImportService) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch hands unfinished jobs to the workers whenever a job is submitted
+// and periodically, so jobs interrupted by a restart or an error are retried
+func (s *BulkImportService) dispatch() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(bulkImportSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		jobs, err := s.store.ListUnfinishedImportJobs()
+		if err != nil {
+			log.Printf("Failed to list unfinished import jobs: %v", err)
+		}
+		for _, job := range jobs {
+			if !s.claim(job.ID) {
+				continue
+			}
+			select {
+			case s.queue <- job.ID:
+			case <-s.ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-s.wake:
+		case <-ticker.C:
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// claim marks a job as being processed, returning false if it already is
+func (s *BulkImportService) claim(jobID int64) bool {
+	s.activeMutex.Lock()
+	defer s.activeMutex.Unlock()
+
+	if s.active[jobID] {
+		return false
+	}
+	s.active[jobID] = true
+	return true
+}
+
+func (s *BulkImportService) release(jobID int64) {
+	s.activeMutex.Lock()
+	defer s.activeMutex.Unlock()
+	delete(s.active, jobID)
+}
+
+func (s *BulkImportService) worker() {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case jobID := <-s.queue:
+			if err := s.processJob(jobID); err != nil {
+				log.Printf("Import job %d interrupted: %v", jobID, err)
+			}
+			s.release(jobID)
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// processJob imports a job's pending rows batch by batch. Errors other than
+// a row being invalid leave the job running so a later sweep resumes it.
+func (s *BulkImportService) processJob(jobID int64) error {
+	job, err := s.store.GetImportJob(jobID)
+	if err != nil {
+		return err
+	}
+	if job.IsFinished() {
+		return nil
+	}
+
+	// Counters may lag the rows if the previous run stopped between saving
+	// a batch and saving the job, so recount before continuing
+	if err := s.recount(job); err != nil {
+		return err
+	}
+	if job.StartedAt == nil {
+		now := time.Now()
+		job.StartedAt = &now
+	}
+	job.Status = models.BulkImportRunning
+	if err := s.store.UpdateImportJob(job); err != nil {
+		return err
+	}
+
+	for {
+		if s.ctx.Err() != nil {
+			return s.ctx.Err()
+		}
+
+		rows, err := s.store.ListImportRows(job.ID, models.BulkRowPending, 0, s.config.BatchSize)
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			break
+		}
+
+		for i, row := range rows {
+			if err := s.importRow(job.UserID, row); err != nil {
+				// Keep the outcome of rows already imported so they aren't created twice
+				if saveErr := s.store.UpdateImportRows(rows[:i]); saveErr != nil {
+					log.Printf("Failed to save import rows of job %d: %v", job.ID, saveErr)
+				}
+				return err
+			}
+		}
+		if err := s.store.UpdateImportRows(rows); err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			job.ProcessedRows++
+			if row.Status == models.BulkRowCreated {
+				job.CreatedRows++
+			} else {
+				job.FailedRows++
+			}
+		}
+		if err := s.store.UpdateImportJob(job); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	job.Status = models.BulkImportCompleted
+	job.FinishedAt = &now
+	return s.store.UpdateImportJob(job)
+}
+
+// recount recomputes a job's counters from its stored rows
+func (s *BulkImportService) recount(job *models.BulkImportJob) error {
+	job.ProcessedRows, job.CreatedRows, job.FailedRows = 0, 0, 0
+
+	after := 0
+	for {
+		rows, err := s.store.ListImportRows(job.ID, "", after, 1000)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			switch row.Status {
+			case models.BulkRowCreated:
+				job.ProcessedRows++
+				job.CreatedRows++
+			case models.BulkRowFailed:
+				job.ProcessedRows++
+				job.FailedRows++
+			}
+		}
+		if len(rows) < 1000 {
+			return nil
+		}
+		after = rows[len(rows)-1].Line
+	}
+}
+
+// importRow creates the link for a row, recording the outcome on the row.
+// Only storage failures are returned; invalid rows are marked failed.
+func (s *BulkImportService) importRow(userID int64, row *models.BulkImportRow) error {
+	shortCode, err := s.createLink(userID, row)
+	if err != nil {
+		if serviceErr, ok := err.(*ServiceError); ok {
+			row.Status = models.BulkRowFailed
+			row.Error = serviceErr.Message
+			return nil
+		}
+		return err
+	}
+
+	if row.Title != "" {
+		title := row.Title
+		if err := s.store.UpdateURL(shortCode, userID, &storage.URLUpdate{Title: &title}); err != nil {
+			return err
+		}
+	}
+
+	row.Status = models.BulkRowCreated
+	row.ShortCode = shortCode
+	return nil
+}
+
+// createLink shortens a row's URL. A custom code the user already points at
+// the same URL counts as created, so rows interrupted mid-batch don't fail
+// when the job resumes.
+func (s *BulkImportService) createLink(userID int64, row *models.BulkImportRow) (string, error) {
+	if row.CustomCode != "" {
+		mapping, err := s.store.LookupURLMapping(row.CustomCode)
+		if err == nil && mapping.UserID != nil && *mapping.UserID == userID && mapping.OriginalURL == row.URL {
+			return mapping.ShortCode, nil
+		}
+		if err != nil && err != storage.ErrURLNotFound {
+			return "", err
+		}
+	}
+
+	response, err := s.shortener.ShortenURL(&models.ShortenRequest{
+		URL:        row.URL,
+		CustomCode: row.CustomCode,
+		ExpiresAt:  row.ExpiresAt,
+		Tags:       row.Tags,
+	}, "", &userID)
+	if err != nil {
+		return "", err
+	}
+	return response.ShortCode, nil
+}
+
+// bulkImportRecord is one link of an NDJSON import file
+type bulkImportRecord struct {
+	URL        string   `json:"url"`
+	CustomCode string   `json:"custom_code"`
+	ExpiresAt  string   `json:"expires_at"`
+	Title      string   `json:"title"`
+	Tags       []string `json:"tags"`
+}
+
+// ParseBulkImport reads the rows of a CSV or NDJSON import file. CSV files
+// need a header naming their columns: url (required), custom_code,
+// expires_at (RFC 3339), title and tags (separated by ; or |); unknown
+// columns are ignored. Rows with invalid values are returned already
+// failed; malformed files are rejected as a whole.
+func ParseBulkImport(format string, r io.Reader, maxRows int) ([]*models.BulkImportRow, error) {
+	var rows []*models.BulkImportRow
+	var err error
+	switch format {
+	case models.BulkFormatCSV:
+		rows, err = parseImportCSV(r, maxRows)
+	case models.BulkFormatNDJSON:
+		rows, err = parseImportNDJSON(r, maxRows)
+	default:
+		return nil, ErrInvalidImportFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrEmptyImport
+	}
+
+	// A custom code can only be claimed once per file
+	seen := make(map[string]int)
+	for _, row := range rows {
+		if row.CustomCode == "" || row.Status == models.BulkRowFailed {
+			continue
+		}
+		if line, ok := seen[row.CustomCode]; ok {
+			failImportRow(row, fmt.Sprintf("custom code already used on line %d", line))
+			continue
+		}
+		seen[row.CustomCode] = row.Line
+	}
+	return rows, nil
+}
+
+func parseImportCSV(r io.Reader, maxRows int) ([]*models.BulkImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, ErrEmptyImport
+	}
+	if err != nil {
+		return nil, invalidImportFile(err.Error())
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if _, dup := columns[name]; !dup {
+			columns[name] = i
+		}
+	}
+	if _, ok := columns["url"]; !ok {
+		return nil, invalidImportFile("header must include a url column")
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var rows []*models.BulkImportRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, invalidImportFile(err.Error())
+		}
+		if len(rows) >= maxRows {
+			return nil, importTooLarge(maxRows)
+		}
+
+		line, _ := reader.FieldPos(0)
+		var tags []string
+		if raw := field(record, "tags"); raw != "" {
+			tags = strings.FieldsFunc(raw, func(r rune) bool { return r == ';' || r == '|' })
+		}
+		rows = append(rows, newImportRow(line, field(record, "url"), field(record, "custom_code"),
+			field(record, "expires_at"), field(record, "title"), tags))
+	}
+	return rows, nil
+}
+
+func parseImportNDJSON(r io.Reader, maxRows int) ([]*models.BulkImportRow, error) {
+	var rows []*models.BulkImportRow
+	line := 0
+
+	// Read whole lines since bufio.Scanner caps their length
+	reader := bufio.NewReader(r)
+	for {
+		text, err := reader.ReadBytes('\n')
+		if err == io.EOF && len(text) == 0 {
+			break
+		}
+		if err != nil && err != io.EOF {
+			return nil, invalidImportFile(err.Error())
+		}
+		line++
+
+		text = bytes.TrimSpace(text)
+		if len(text) == 0 {
+			continue
+		}
+		if len(rows) >= maxRows {
+			return nil, importTooLarge(maxRows)
+		}
+
+		var record bulkImportRecord
+		if err := json.Unmarshal(text, &record); err != nil {
+			// A single bad line shouldn't sink the file, but it has no URL to report
+			row := &models.BulkImportRow{Line: line}
+			failImportRow(row, "invalid JSON object")
+			rows = append(rows, row)
+			continue
+		}
+		rows = append(rows, newImportRow(line, strings.TrimSpace(record.URL), strings.TrimSpace(record.CustomCode),
+			strings.TrimSpace(record.ExpiresAt), strings.TrimSpace(record.Title), record.Tags))
+	}
+	return rows, nil
+}
+
+// newImportRow validates a row's fields, marking the row failed at the first
+// invalid one
+func newImportRow(line int, url, customCode, expiresAt, title string, tags []string) *models.BulkImportRow {
+	row := &models.BulkImportRow{
+		Line:       line,
+		URL:        url,
+		CustomCode: customCode,
+		Title:      title,
+		Status:     models.BulkRowPending,
+	}
+
+	if url == "" {
+		failImportRow(row, "url is required")
+		return row
+	}
+	if expiresAt != "" {
+		expires, err := time.Parse(time.RFC3339, expiresAt)
+		if err != nil {
+			failImportRow(row, "expires_at must be an RFC 3339 timestamp")
+			return row
+		}
+		row.ExpiresAt = &expires
+	}
+	if len([]rune(title)) > maxImportTitleLength {
+		row.Title = string([]rune(title)[:maxImportTitleLength])
+		failImportRow(row, fmt.Sprintf("title must be at most %d characters", maxImportTitleLength))
+		return row
+	}
+
+	normalized, err := NormalizeTags(tags)
+	if err != nil {
+		failImportRow(row, err.Error())
+		return row
+	}
+	row.Tags = normalized
+	return row
+}
+
+func failImportRow(row *models.BulkImportRow, message string) {
+	row.Status = models.BulkRowFailed
+	row.Error = message
+}
+
+func invalidImportFile(reason string) error {
+	return &ServiceError{Message: "invalid import file: " + reason}
+}
+
+func importTooLarge(maxRows int) error {
+	return &ServiceError{Message: fmt.Sprintf("import files are limited to %d rows", maxRows)}
+}
+
+// Bulk import errors
+var (
+	ErrBulkImportNotInPlan    = &ServiceError{Message: "bulk import is not available in your current plan"}
+	ErrInvalidImportFormat    = &ServiceError{Message: "format must be csv or ndjson"}
+	ErrEmptyImport            = &ServiceError{Message: "import file has no rows"}
+	ErrInvalidImportRowStatus = &ServiceError{Message: "status must be one of pending, created or failed"}
+)
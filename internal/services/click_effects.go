@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/URLshorter/url-shortener/internal/models"
+)
+
+// ClickEffectConfig tunes the click side effect workers
+type ClickEffectConfig struct {
+	QueueSize int
+	Workers   int
+}
+
+// DefaultClickEffectConfig returns the default worker settings
+func DefaultClickEffectConfig() ClickEffectConfig {
+	return ClickEffectConfig{
+		QueueSize: 10000,
+		Workers:   4,
+	}
+}
+
+// ClickEffectStats is a snapshot of the side effect queue's counters
+type ClickEffectStats struct {
+	QueueDepth    int    `json:"queue_depth"`
+	QueueCapacity int    `json:"queue_capacity"`
+	Processed     uint64 `json:"processed"`
+	Dropped       uint64 `json:"dropped"`
+}
+
+// ClickEffectQueue runs the per-click side effects (analytics, unique
+// visitors, real-time updates, attribution) of persisted clicks on its own
+// workers, so slow side effects never hold up persisting the next batch.
+// Clicks are dropped and counted when the queue is full; they are already
+// stored, only their side effects are skipped.
+type ClickEffectQueue struct {
+	processor ClickProcessor
+	queue     chan *models.ClickEvent
+	wg        sync.WaitGroup
+
+	closeMutex sync.RWMutex
+	closed     bool
+
+	processed uint64
+	dropped   uint64
+}
+
+// NewClickEffectQueue creates the queue and starts its workers
+func NewClickEffectQueue(processor ClickProcessor, config ClickEffectConfig) *ClickEffectQueue {
+	defaults := DefaultClickEffectConfig()
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaults.QueueSize
+	}
+	if config.Workers <= 0 {
+		config.Workers = defaults.Workers
+	}
+
+	q := &ClickEffectQueue{
+		processor: processor,
+		queue:     make(chan *models.ClickEvent, config.QueueSize),
+	}
+
+	for i := 0; i < config.Workers; i++ {
+		q.wg.Add(1)
+		go q.worker()
+	}
+
+	return q
+}
+
+// Enqueue hands a persisted click to the workers without blocking. Its
+// signature matches ClickProcessor so it can be given to the click pipeline
+// and stream consumer in place of the processor itself.
+func (q *ClickEffectQueue) Enqueue(event *models.ClickEvent) {
+	q.closeMutex.RLock()
+	defer q.closeMutex.RUnlock()
+
+	if q.closed {
+		atomic.AddUint64(&q.dropped, 1)
+		return
+	}
+
+	select {
+	case q.queue <- event:
+	default:
+		atomic.AddUint64(&q.dropped, 1)
+	}
+}
+
+// Stats returns the current queue depth and counters
+func (q *ClickEffectQueue) Stats() ClickEffectStats {
+	return ClickEffectStats{
+		QueueDepth:    len(q.queue),
+		QueueCapacity: cap(q.queue),
+		Processed:     atomic.LoadUint64(&q.processed),
+		Dropped:       atomic.LoadUint64(&q.dropped),
+	}
+}
+
+// Shutdown stops accepting clicks and waits for the queued ones to be
+// processed, or for the context to expire
+func (q *ClickEffectQueue) Shutdown(ctx context.Context) error {
+	q.closeMutex.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.queue)
+	}
+	q.closeMutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (q *ClickEffectQueue) worker() {
+	defer q.wg.Done()
+
+	for event := range q.queue {
+		q.processor(event)
+		atomic.AddUint64(&q.processed, 1)
+	}
+}
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/URLshorter/url-shortener/internal/models"
+)
+
+// ClickStore persists batches of click events. SaveClickEvents must skip
+// events that are already stored and return the IDs it inserted.
+type ClickStore interface {
+	SaveClickEvents(events []*models.ClickEvent) ([]int64, error)
+	IncrementClickCounts(counts map[string]int64) error
+}
+
+// ClickIngestionConfig tunes the click ingestion pipeline
+type ClickIngestionConfig struct {
+	QueueSize     int
+	Workers       int
+	BatchSize     int
+	FlushInterval time.Duration
+}
+
+// DefaultClickIngestionConfig returns the default pipeline settings
+func DefaultClickIngestionConfig() ClickIngestionConfig {
+	return ClickIngestionConfig{
+		QueueSize:     10000,
+		Workers:       4,
+		BatchSize:     500,
+		FlushInterval: time.Second,
+	}
+}
+
+// ClickIngestionStats is a snapshot of the pipeline's counters
+type ClickIngestionStats struct {
+	QueueDepth    int    `json:"queue_depth"`
+	QueueCapacity int    `json:"queue_capacity"`
+	Enqueued      uint64 `json:"enqueued"`
+	Dropped       uint64 `json:"dropped"`
+	Persisted     uint64 `json:"persisted"`
+	Failed        uint64 `json:"failed"`
+	Batches       uint64 `json:"batches"`
+}
+
+// ClickProcessor runs per-click side effects (analytics, attribution,
+// real-time updates) after a batch has been persisted. It is called from the
+// persisting worker, so it should hand slow work off, as ClickEffectQueue does.
+type ClickProcessor func(event *models.ClickEvent)
+
+// ClickIngestionPipeline buffers click events in a bounded queue and persists
+// them from a fixed worker pool. Each worker batches events into multi-row
+// inserts and coalesces click count increments per short code. When the queue
+// is full new events are dropped and counted rather than blocking redirects.
+type ClickIngestionPipeline struct {
+	store     ClickStore
+	processor ClickProcessor
+	config    ClickIngestionConfig
+	queue     chan *models.ClickEvent
+	wg        sync.WaitGroup
+
+	closeMutex sync.RWMutex
+	closed     bool
+
+	enqueued  uint64
+	dropped   uint64
+	persisted uint64
+	failed    uint64
+	batches   uint64
+}
+
+// NewClickIngestionPipeline creates the pipeline and starts its workers
+func NewClickIngestionPipeline(store ClickStore, processor ClickProcessor, config ClickIngestionConfig) *ClickIngestionPipeline {
+	defaults := DefaultClickIngestionConfig()
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaults.QueueSize
+	}
+	if config.Workers <= 0 {
+		config.Workers = defaults.Workers
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaults.FlushInterval
+	}
+
+	p := &ClickIngestionPipeline{
+		store:     store,
+		processor: processor,
+		config:    config,
+		queue:     make(chan *models.ClickEvent, config.QueueSize),
+	}
+
+	for i := 0; i < config.Workers; i++ {
+		p.wg.Add(1)
+		go p.worker()
+	}
+
+	return p
+}
+
+// Enqueue hands a click event to the pipeline without blocking. It returns
+// false when the event was dropped because the queue is full or shutting down.
+func (p *ClickIngestionPipeline) Enqueue(event *models.ClickEvent) bool {
+	p.closeMutex.RLock()
+	defer p.closeMutex.RUnlock()
+
+	if p.closed {
+		atomic.AddUint64(&p.dropped, 1)
+		return false
+	}
+
+	select {
+	case p.queue <- event:
+		atomic.AddUint64(&p.enqueued, 1)
+		return true
+	default:
+		atomic.AddUint64(&p.dropped, 1)
+		return false
+	}
+}
+
+// Stats returns the current queue depth and counters
+func (p *ClickIngestionPipeline) Stats() ClickIngestionStats {
+	return ClickIngestionStats{
+		QueueDepth:    len(p.queue),
+		QueueCapacity: cap(p.queue),
+		Enqueued:      atomic.LoadUint64(&p.enqueued),
+		Dropped:       atomic.LoadUint64(&p.dropped),
+		Persisted:     atomic.LoadUint64(&p.persisted),
+		Failed:        atomic.LoadUint64(&p.failed),
+		Batches:       atomic.LoadUint64(&p.batches),
+	}
+}
+
+// Shutdown stops accepting events and waits for the workers to flush
+// everything still queued, or for the context to expire
+func (p *ClickIngestionPipeline) Shutdown(ctx context.Context) error {
+	p.closeMutex.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.closeMutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *ClickIngestionPipeline) worker() {
+	defer p.wg.Done()
+
+	batch := make([]*models.ClickEvent, 0, p.config.BatchSize)
+	ticker := time.NewTicker(p.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event, ok := <-p.queue:
+			if !ok {
+				p.flush(batch)
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) >= p.config.BatchSize {
+				p.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				p.flush(batch)
+				batch = batch[:0]
+			}
+		}
+	}
+}
+
+// flush persists a batch and then runs the per-click processor for the
+// newly inserted events
+func (p *ClickIngestionPipeline) flush(batch []*models.ClickEvent) {
+	if len(batch) == 0 {
+		return
+	}
+	atomic.AddUint64(&p.batches, 1)
+
+	stored, inserted := persistClickBatch(p.store, batch)
+	atomic.AddUint64(&p.persisted, uint64(len(stored)))
+	atomic.AddUint64(&p.failed, uint64(len(batch)-len(stored)))
+
+	if p.processor != nil {
+		for _, event := range inserted {
+			p.processor(event)
+		}
+	}
+}
+
+// persistClickBatch saves a batch of click events and applies coalesced click
+// count increments for the rows that were newly inserted. If the batch insert
+// fails the events are retried one by one so a single bad row doesn't lose the
+// whole batch. It returns the events now safely stored (including ones stored
+// by an earlier delivery) and the subset inserted by this call.
+func persistClickBatch(store ClickStore, batch []*models.ClickEvent) (stored, inserted []*models.ClickEvent) {
+	insertedIDs, err := store.SaveClickEvents(batch)
+	if err == nil {
+		stored = batch
+	} else {
+		log.Printf("Click batch insert failed, retrying individually: %v", err)
+
+		insertedIDs = insertedIDs[:0]
+		stored = make([]*models.ClickEvent, 0, len(batch))
+		for _, event := range batch {
+			ids, err := store.SaveClickEvents([]*models.ClickEvent{event})
+			if err != nil {
+				log.Printf("Failed to save click event %d: %v", event.ID, err)
+				continue
+			}
+			stored = append(stored, event)
+			insertedIDs = append(insertedIDs, ids...)
+		}
+	}
+
+	isInserted := make(map[int64]bool, len(insertedIDs))
+	for _, id := range insertedIDs {
+		isInserted[id] = true
+	}
+
+	counts := make(map[string]int64)
+	for _, event := range stored {
+		if isInserted[event.ID] {
+			inserted = append(inserted, event)
+			counts[event.ShortCode]++
+		}
+	}
+	if err := store.IncrementClickCounts(counts); err != nil {
+		log.Printf("Failed to increment click counts: %v", err)
+	}
+
+	return stored, inserted
+}
@@ -0,0 +1,400 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/URLshorter/url-shortener/internal/storage"
+)
+
+const (
+	// ClickStream is the Redis Stream every recorded click is written to first
+	ClickStream = "clicks:stream"
+	// ClickDeadLetterStream holds clicks that could not be persisted after MaxDeliveries attempts
+	// while the store was taking other clicks
+	ClickDeadLetterStream = "clicks:stream:dead"
+	// ClickStreamGroup is the consumer group shared by all app replicas
+	ClickStreamGroup = "click-ingestion"
+)
+
+// ClickStreamBroker is the Redis Stream API the click consumer uses,
+// implemented by storage.RedisStorage
+type ClickStreamBroker interface {
+	StreamAdd(stream string, maxLen int64, values map[string]interface{}) (string, error)
+	StreamCreateGroup(stream, group string) error
+	StreamReadGroup(stream, group, consumer string, count int64, block time.Duration) ([]storage.StreamMessage, error)
+	StreamAck(stream, group string, ids ...string) error
+	StreamPending(stream, group string, minIdle time.Duration, count int64) ([]storage.PendingStreamMessage, error)
+	StreamClaim(stream, group, consumer string, minIdle time.Duration, ids ...string) ([]storage.StreamMessage, error)
+	StreamTrimAcknowledged(stream, group string) (int64, error)
+}
+
+// ClickStreamConfig tunes the Redis Stream click consumer
+type ClickStreamConfig struct {
+	Consumer      string        // unique per replica; defaults to hostname-pid
+	BatchSize     int64         // entries read per XREADGROUP
+	Block         time.Duration // how long a read waits for new entries
+	ClaimIdle     time.Duration // pending entries idle this long are retried
+	ClaimInterval time.Duration // how often pending entries are checked
+	MaxDeliveries int64         // deliveries before an entry is dead-lettered
+	MaxBackoff    time.Duration // longest pause between reads while the store is failing
+	TrimInterval  time.Duration // how often acknowledged entries are trimmed
+	MaxLen        int64         // approximate cap on the dead-letter stream
+}
+
+// DefaultClickStreamConfig returns the default consumer settings
+func DefaultClickStreamConfig() ClickStreamConfig {
+	hostname, _ := os.Hostname()
+	return ClickStreamConfig{
+		Consumer:      fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		BatchSize:     500,
+		Block:         2 * time.Second,
+		ClaimIdle:     30 * time.Second,
+		ClaimInterval: 15 * time.Second,
+		MaxDeliveries: 5,
+		MaxBackoff:    time.Minute,
+		TrimInterval:  time.Minute,
+		MaxLen:        1000000,
+	}
+}
+
+// ClickStreamStats is a snapshot of the stream consumer's counters
+type ClickStreamStats struct {
+	Published    uint64 `json:"published"`
+	Persisted    uint64 `json:"persisted"`
+	Retried      uint64 `json:"retried"`
+	DeadLettered uint64 `json:"dead_lettered"`
+}
+
+// ClickStreamConsumer makes click ingestion durable: clicks are appended to a
+// Redis Stream and a consumer group shared by all replicas persists them to
+// click_events, acknowledging entries only once they're stored. Entries left
+// pending by a failed write or a crashed replica are reclaimed after
+// ClaimIdle. An entry still failing after MaxDeliveries while other clicks
+// are being stored is moved to the dead-letter stream; while the store is
+// down nothing is dead-lettered and reads back off until it recovers. Only
+// acknowledged entries are trimmed from the stream.
+type ClickStreamConsumer struct {
+	broker    ClickStreamBroker
+	store     ClickStore
+	processor ClickProcessor
+	config    ClickStreamConfig
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// Only touched by the consumer goroutine
+	backoff       time.Duration // pause before the next read; 0 while the store is healthy
+	lastPersisted time.Time
+
+	published    uint64
+	persisted    uint64
+	retried      uint64
+	deadLettered uint64
+}
+
+// NewClickStreamConsumer creates a new click stream consumer
+func NewClickStreamConsumer(broker ClickStreamBroker, store ClickStore, processor ClickProcessor, config ClickStreamConfig) *ClickStreamConsumer {
+	defaults := DefaultClickStreamConfig()
+	if config.Consumer == "" {
+		config.Consumer = defaults.Consumer
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+	if config.Block <= 0 {
+		config.Block = defaults.Block
+	}
+	if config.ClaimIdle <= 0 {
+		config.ClaimIdle = defaults.ClaimIdle
+	}
+	if config.ClaimInterval <= 0 {
+		config.ClaimInterval = defaults.ClaimInterval
+	}
+	if config.MaxDeliveries <= 0 {
+		config.MaxDeliveries = defaults.MaxDeliveries
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = defaults.MaxBackoff
+	}
+	if config.TrimInterval <= 0 {
+		config.TrimInterval = defaults.TrimInterval
+	}
+	if config.MaxLen <= 0 {
+		config.MaxLen = defaults.MaxLen
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ClickStreamConsumer{
+		broker:    broker,
+		store:     store,
+		processor: processor,
+		config:    config,
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// Publish appends a click event to the stream. The stream isn't capped, so
+// clicks are kept however long the store is down.
+func (c *ClickStreamConsumer) Publish(event *models.ClickEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal click event: %w", err)
+	}
+
+	if _, err := c.broker.StreamAdd(ClickStream, 0, map[string]interface{}{"event": data}); err != nil {
+		return err
+	}
+	atomic.AddUint64(&c.published, 1)
+	return nil
+}
+
+// Start launches the consumer loop
+func (c *ClickStreamConsumer) Start() {
+	c.wg.Add(1)
+	go c.run()
+}
+
+// Shutdown stops reading new entries and waits for the in-flight batch to be
+// persisted. Anything still in the stream is picked up on the next start.
+func (c *ClickStreamConsumer) Shutdown(ctx context.Context) error {
+	c.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats returns the consumer's counters
+func (c *ClickStreamConsumer) Stats() ClickStreamStats {
+	return ClickStreamStats{
+		Published:    atomic.LoadUint64(&c.published),
+		Persisted:    atomic.LoadUint64(&c.persisted),
+		Retried:      atomic.LoadUint64(&c.retried),
+		DeadLettered: atomic.LoadUint64(&c.deadLettered),
+	}
+}
+
+func (c *ClickStreamConsumer) run() {
+	defer c.wg.Done()
+
+	groupReady := false
+	var lastClaim, lastTrim time.Time
+
+	for c.ctx.Err() == nil {
+		if !groupReady {
+			if err := c.broker.StreamCreateGroup(ClickStream, ClickStreamGroup); err != nil {
+				log.Printf("Click stream consumer: %v", err)
+				c.sleep(time.Second)
+				continue
+			}
+			groupReady = true
+		}
+
+		if time.Since(lastClaim) >= c.config.ClaimInterval {
+			c.retryPending()
+			lastClaim = time.Now()
+		}
+		if time.Since(lastTrim) >= c.config.TrimInterval {
+			c.trimAcknowledged()
+			lastTrim = time.Now()
+		}
+
+		// Don't pull more entries than needed to notice the store is back
+		if c.backoff > 0 {
+			c.sleep(c.backoff)
+			if c.ctx.Err() != nil {
+				return
+			}
+		}
+
+		messages, err := c.broker.StreamReadGroup(ClickStream, ClickStreamGroup, c.config.Consumer, c.config.BatchSize, c.config.Block)
+		if err != nil {
+			log.Printf("Click stream consumer: %v", err)
+			// The group disappears if the stream key was deleted
+			if strings.Contains(err.Error(), "NOGROUP") {
+				groupReady = false
+			}
+			c.sleep(time.Second)
+			continue
+		}
+
+		c.handle(messages)
+	}
+}
+
+// handle persists a batch of stream entries and acknowledges the ones
+// stored, returning the entries that failed to persist
+func (c *ClickStreamConsumer) handle(messages []storage.StreamMessage) []storage.StreamMessage {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	events := make([]*models.ClickEvent, 0, len(messages))
+	entries := make(map[int64]storage.StreamMessage, len(messages))
+	for _, msg := range messages {
+		event, err := decodeClickStreamMessage(msg)
+		if err != nil {
+			log.Printf("Click stream entry %s is malformed: %v", msg.ID, err)
+			c.deadLetter(msg, 0, err.Error())
+			continue
+		}
+		events = append(events, event)
+		entries[event.ID] = msg
+	}
+	if len(events) == 0 {
+		return nil
+	}
+
+	// Entries that fail to persist stay pending and are retried by retryPending
+	stored, inserted := persistClickBatch(c.store, events)
+	c.recordStoreHealth(len(stored) > 0)
+
+	ackIDs := make([]string, 0, len(stored))
+	for _, event := range stored {
+		ackIDs = append(ackIDs, entries[event.ID].ID)
+		delete(entries, event.ID)
+	}
+	if err := c.broker.StreamAck(ClickStream, ClickStreamGroup, ackIDs...); err != nil {
+		// Unacked entries are redelivered; the insert skips ones already stored
+		log.Printf("Click stream consumer: %v", err)
+	}
+	atomic.AddUint64(&c.persisted, uint64(len(inserted)))
+
+	if c.processor != nil {
+		for _, event := range inserted {
+			c.processor(event)
+		}
+	}
+
+	failed := make([]storage.StreamMessage, 0, len(entries))
+	for _, msg := range entries {
+		failed = append(failed, msg)
+	}
+	return failed
+}
+
+// recordStoreHealth backs off reads while every write fails, doubling the
+// pause up to MaxBackoff, and resumes them as soon as one succeeds
+func (c *ClickStreamConsumer) recordStoreHealth(persisted bool) {
+	if persisted {
+		c.backoff = 0
+		c.lastPersisted = time.Now()
+		return
+	}
+
+	if c.backoff == 0 {
+		log.Printf("Click stream consumer: click store is failing, backing off")
+	}
+	c.backoff = min(max(2*c.backoff, time.Second), c.config.MaxBackoff)
+}
+
+// retryPending reclaims entries left unacknowledged for ClaimIdle, whether
+// from failed writes or a replica that died mid-batch, and retries them. An
+// entry that has exhausted its deliveries is dead-lettered if it still fails
+// while the store is taking other clicks; during an outage it waits.
+func (c *ClickStreamConsumer) retryPending() {
+	pending, err := c.broker.StreamPending(ClickStream, ClickStreamGroup, c.config.ClaimIdle, c.config.BatchSize)
+	if err != nil {
+		log.Printf("Click stream consumer: %v", err)
+		return
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	claimedAt := time.Now()
+	deliveries := make(map[string]int64, len(pending))
+	lastDelivered := make(map[string]time.Time, len(pending))
+	ids := make([]string, len(pending))
+	for i, p := range pending {
+		deliveries[p.ID] = p.Deliveries
+		lastDelivered[p.ID] = claimedAt.Add(-p.Idle)
+		ids[i] = p.ID
+	}
+
+	messages, err := c.broker.StreamClaim(ClickStream, ClickStreamGroup, c.config.Consumer, c.config.ClaimIdle, ids...)
+	if err != nil {
+		log.Printf("Click stream consumer: %v", err)
+		return
+	}
+
+	atomic.AddUint64(&c.retried, uint64(len(messages)))
+	for _, msg := range c.handle(messages) {
+		// Other clicks stored since the entry last failed show the store
+		// is up and the entry itself can't be stored
+		if deliveries[msg.ID] >= c.config.MaxDeliveries && c.lastPersisted.After(lastDelivered[msg.ID]) {
+			c.deadLetter(msg, deliveries[msg.ID], "max deliveries exceeded")
+		}
+	}
+}
+
+// trimAcknowledged drops entries every replica has acknowledged, leaving
+// pending ones for retryPending
+func (c *ClickStreamConsumer) trimAcknowledged() {
+	if _, err := c.broker.StreamTrimAcknowledged(ClickStream, ClickStreamGroup); err != nil {
+		log.Printf("Click stream consumer: %v", err)
+	}
+}
+
+// deadLetter copies an entry to the dead-letter stream and acknowledges it
+func (c *ClickStreamConsumer) deadLetter(msg storage.StreamMessage, deliveries int64, reason string) {
+	values := map[string]interface{}{
+		"original_id": msg.ID,
+		"deliveries":  deliveries,
+		"reason":      reason,
+	}
+	if event, ok := msg.Values["event"]; ok {
+		values["event"] = event
+	}
+
+	if _, err := c.broker.StreamAdd(ClickDeadLetterStream, c.config.MaxLen, values); err != nil {
+		// Leave the entry pending so it's dead-lettered on a later pass
+		log.Printf("Click stream consumer: %v", err)
+		return
+	}
+	if err := c.broker.StreamAck(ClickStream, ClickStreamGroup, msg.ID); err != nil {
+		log.Printf("Click stream consumer: %v", err)
+	}
+	atomic.AddUint64(&c.deadLettered, 1)
+}
+
+func (c *ClickStreamConsumer) sleep(d time.Duration) {
+	select {
+	case <-c.ctx.Done():
+	case <-time.After(d):
+	}
+}
+
+// decodeClickStreamMessage decodes the click event carried by a stream entry
+func decodeClickStreamMessage(msg storage.StreamMessage) (*models.ClickEvent, error) {
+	raw, ok := msg.Values["event"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing event field")
+	}
+
+	var event models.ClickEvent
+	if err := json.Unmarshal([]byte(raw), &event); err != nil {
+		return nil, fmt.Errorf("invalid event payload: %w", err)
+	}
+	return &event, nil
+}
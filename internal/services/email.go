@@ -14,12 +14,12 @@ import (
 )
 
 type EmailService struct {
-	db     *storage.PostgresStorage
+	db     storage.UserRepository
 	config *configs.Config
 }
 
 // NewEmailService creates a new email service
-func NewEmailService(db *storage.PostgresStorage, config *configs.Config) *EmailService {
+func NewEmailService(db storage.UserRepository, config *configs.Config) *EmailService {
 	return &EmailService{
 		db:     db,
 		config: config,
@@ -185,84 +185,33 @@ func (e *EmailService) generateVerificationToken() (string, error) {
 
 // CreateEmailVerification creates an email verification record
 func (e *EmailService) CreateEmailVerification(verification *models.EmailVerification) error {
-	query := `
-		INSERT INTO email_verifications (id, user_id, token, expires_at, created_at)
-		VALUES ($1, $2, $3, $4, $5)`
-	
-	_, err := e.db.Exec(query, verification.ID, verification.UserID, verification.Token,
-		verification.ExpiresAt, verification.CreatedAt)
-	return err
+	return e.db.CreateEmailVerification(verification)
 }
 
 // GetEmailVerificationByToken gets email verification by token
 func (e *EmailService) GetEmailVerificationByToken(token string) (*models.EmailVerification, error) {
-	verification := &models.EmailVerification{}
-	query := `
-		SELECT id, user_id, token, expires_at, verified_at, created_at
-		FROM email_verifications
-		WHERE token = $1`
-	
-	err := e.db.QueryRow(query, token).Scan(
-		&verification.ID, &verification.UserID, &verification.Token,
-		&verification.ExpiresAt, &verification.VerifiedAt, &verification.CreatedAt,
-	)
-	
-	return verification, err
+	return e.db.GetEmailVerificationByToken(token)
 }
 
 // GetLatestEmailVerification gets the latest email verification for a user
 func (e *EmailService) GetLatestEmailVerification(userID int64) (*models.EmailVerification, error) {
-	verification := &models.EmailVerification{}
-	query := `
-		SELECT id, user_id, token, expires_at, verified_at, created_at
-		FROM email_verifications
-		WHERE user_id = $1
-		ORDER BY created_at DESC
-		LIMIT 1`
-	
-	err := e.db.QueryRow(query, userID).Scan(
-		&verification.ID, &verification.UserID, &verification.Token,
-		&verification.ExpiresAt, &verification.VerifiedAt, &verification.CreatedAt,
-	)
-	
-	return verification, err
+	return e.db.GetLatestEmailVerification(userID)
 }
 
 // MarkEmailVerified marks the email as verified
 func (e *EmailService) MarkEmailVerified(verificationID uuid.UUID, userID int64, verifiedAt time.Time) error {
-	tx, err := e.db.Begin()
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	// Mark verification as completed
-	_, err = tx.Exec("UPDATE email_verifications SET verified_at = $1 WHERE id = $2", verifiedAt, verificationID)
-	if err != nil {
+	if err := e.db.UpdateEmailVerification(verificationID.String(), verifiedAt); err != nil {
 		return err
 	}
-
-	// Update user email_verified status
-	_, err = tx.Exec("UPDATE users SET email_verified = true WHERE id = $1", userID)
-	if err != nil {
-		return err
-	}
-
-	return tx.Commit()
+	return e.db.UpdateUserEmailVerified(userID, true)
 }
 
 // InvalidateEmailVerifications invalidates all pending verifications for a user
 func (e *EmailService) InvalidateEmailVerifications(userID int64) error {
-	query := "UPDATE email_verifications SET verified_at = NOW() WHERE user_id = $1 AND verified_at IS NULL"
-	_, err := e.db.Exec(query, userID)
-	return err
+	return e.db.InvalidateEmailVerifications(userID)
 }
 
-// GetUserByID gets a user by ID (simplified version for email service)
+// GetUserByID gets a user by ID
 func (e *EmailService) GetUserByID(userID int64) (*models.User, error) {
-	user := &models.User{}
-	query := "SELECT id, email, email_verified FROM users WHERE id = $1"
-	
-	err := e.db.QueryRow(query, userID).Scan(&user.ID, &user.Email, &user.EmailVerified)
-	return user, err
-}
\ No newline at end of file
+	return e.db.GetUserByID(userID)
+}
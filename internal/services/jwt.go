@@ -5,16 +5,22 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/URLshorter/url-shortener/internal/storage"
 )
 
 var (
 	ErrInvalidToken = errors.New("invalid token")
 	ErrExpiredToken = errors.New("token has expired")
 	ErrInvalidClaims = errors.New("invalid token claims")
+	ErrRevokedToken = errors.New("token has been revoked")
+	ErrRefreshTokenReused = errors.New("refresh token was already used; session revoked")
+	ErrRevocationUnavailable = errors.New("token revocation is not configured")
+	ErrNoSigningKey = errors.New("no JWT signing key is configured")
 )
 
 type JWTService struct {
@@ -22,6 +28,8 @@ type JWTService struct {
 	issuer          string
 	accessTokenTTL  time.Duration
 	refreshTokenTTL time.Duration
+	revocations     storage.Cache
+	signingKeys     []*SigningKey // ordered by activation
 }
 
 type Claims struct {
@@ -31,6 +39,7 @@ type Claims struct {
 	AccountType string `json:"account_type"`
 	SessionID   string `json:"session_id"`
 	TokenType   string `json:"token_type"` // "access" or "refresh"
+	Generation  int64  `json:"gen,omitempty"` // user's token generation when issued
 	jwt.RegisteredClaims
 }
 
@@ -50,16 +59,52 @@ func NewJWTService(secretKey, issuer string, accessTTL, refreshTTL time.Duration
 	}
 }
 
-// GenerateTokenPair creates both access and refresh tokens for a user
+// SetRevocationCache keeps revoked tokens, revoked sessions and token
+// generations in cache, which must be shared by every replica (Redis in
+// production). Without it tokens stay valid until they expire.
+func (j *JWTService) SetRevocationCache(cache storage.Cache) {
+	j.revocations = cache
+}
+
+// SetSigningKeys signs tokens with asymmetric keys instead of the HMAC
+// secret. The most recently activated key signs; keys it replaced keep
+// verifying tokens until the longest-lived token they signed has expired,
+// and keys scheduled for later are published ahead of use. While the secret
+// is set, HMAC tokens issued before the switch are still accepted.
+func (j *JWTService) SetSigningKeys(keys []*SigningKey) error {
+	sorted, err := sortSigningKeys(keys)
+	if err != nil {
+		return err
+	}
+	if len(sorted) == 0 || sorted[0].ActiveFrom.After(time.Now()) {
+		return errors.New("at least one signing key must already be active")
+	}
+
+	j.signingKeys = sorted
+	return nil
+}
+
+// GenerateTokenPair creates both access and refresh tokens for a user. The
+// tokens of a pair share the session ID, which a refresh carries over to
+// the next pair; an empty session ID starts a new session.
 func (j *JWTService) GenerateTokenPair(user *models.User, sessionID string) (*TokenPair, error) {
+	if sessionID == "" {
+		sessionID = j.generateTokenID()
+	}
+
+	generation, err := j.tokenGeneration(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	// Generate access token
-	accessToken, accessExpiresAt, err := j.generateToken(user, sessionID, "access", j.accessTokenTTL)
+	accessToken, accessExpiresAt, err := j.generateToken(user, sessionID, "access", generation, j.accessTokenTTL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate access token: %w", err)
 	}
 
 	// Generate refresh token
-	refreshToken, _, err := j.generateToken(user, sessionID, "refresh", j.refreshTokenTTL)
+	refreshToken, _, err := j.generateToken(user, sessionID, "refresh", generation, j.refreshTokenTTL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
 	}
@@ -73,7 +118,7 @@ func (j *JWTService) GenerateTokenPair(user *models.User, sessionID string) (*To
 }
 
 // generateToken creates a JWT token with the specified type and TTL
-func (j *JWTService) generateToken(user *models.User, sessionID, tokenType string, ttl time.Duration) (string, time.Time, error) {
+func (j *JWTService) generateToken(user *models.User, sessionID, tokenType string, generation int64, ttl time.Duration) (string, time.Time, error) {
 	now := time.Now()
 	expiresAt := now.Add(ttl)
 
@@ -84,6 +129,7 @@ func (j *JWTService) generateToken(user *models.User, sessionID, tokenType strin
 		AccountType: user.AccountType,
 		SessionID:   sessionID,
 		TokenType:   tokenType,
+		Generation:  generation,
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer:    j.issuer,
 			Subject:   fmt.Sprintf("%d", user.ID),
@@ -95,8 +141,7 @@ func (j *JWTService) generateToken(user *models.User, sessionID, tokenType strin
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(j.secretKey))
+	tokenString, err := j.signToken(claims)
 	if err != nil {
 		return "", time.Time{}, err
 	}
@@ -106,13 +151,7 @@ func (j *JWTService) generateToken(user *models.User, sessionID, tokenType strin
 
 // ValidateToken validates and parses a JWT token
 func (j *JWTService) ValidateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		// Verify signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(j.secretKey), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, j.verificationKey)
 
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
@@ -129,8 +168,89 @@ func (j *JWTService) ValidateToken(tokenString string) (*Claims, error) {
 	return claims, nil
 }
 
-// RefreshToken creates a new access token using a valid refresh token
-func (j *JWTService) RefreshToken(refreshToken string, user *models.User, sessionID string) (*TokenPair, error) {
+// signToken signs claims with the current signing key, or the HMAC secret
+// when no signing keys are configured
+func (j *JWTService) signToken(claims *Claims) (string, error) {
+	key := j.currentSigningKey(time.Now())
+	if key == nil {
+		if j.secretKey == "" {
+			return "", ErrNoSigningKey
+		}
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(j.secretKey))
+	}
+
+	token := jwt.NewWithClaims(key.signingMethod(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.privateKey)
+}
+
+// verificationKey returns the key a token's signature is checked against:
+// the published key named by its "kid" header, or the HMAC secret
+func (j *JWTService) verificationKey(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		if j.secretKey == "" {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(j.secretKey), nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	for _, key := range j.publishedKeys(time.Now()) {
+		if key.ID != kid {
+			continue
+		}
+		if token.Method.Alg() != key.Algorithm {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return key.privateKey.Public(), nil
+	}
+	return nil, fmt.Errorf("unknown signing key: %q", kid)
+}
+
+// currentSigningKey returns the most recently activated signing key, or nil
+// when tokens are signed with the HMAC secret
+func (j *JWTService) currentSigningKey(now time.Time) *SigningKey {
+	var current *SigningKey
+	for _, key := range j.signingKeys {
+		if key.ActiveFrom.After(now) {
+			break
+		}
+		current = key
+	}
+	return current
+}
+
+// publishedKeys returns the keys tokens may currently be verified with: the
+// signing key, keys scheduled to replace it, and keys it replaced less than
+// a refresh token lifetime ago
+func (j *JWTService) publishedKeys(now time.Time) []*SigningKey {
+	var published []*SigningKey
+	for i, key := range j.signingKeys {
+		if i+1 < len(j.signingKeys) {
+			retiredAt := j.signingKeys[i+1].ActiveFrom
+			if !retiredAt.After(now) && now.Sub(retiredAt) >= j.refreshTokenTTL {
+				continue
+			}
+		}
+		published = append(published, key)
+	}
+	return published
+}
+
+// JWKS returns the public keys other services verify tokens with
+func (j *JWTService) JWKS() *JWKS {
+	jwks := &JWKS{Keys: []JWK{}}
+	for _, key := range j.publishedKeys(time.Now()) {
+		jwks.Keys = append(jwks.Keys, key.JWK())
+	}
+	return jwks
+}
+
+// RefreshToken exchanges a refresh token for a new token pair in the same
+// session. Each refresh token can be used once: presenting one again means
+// it was stolen, so the whole session is revoked and ErrRefreshTokenReused
+// returned.
+func (j *JWTService) RefreshToken(refreshToken string, user *models.User) (*TokenPair, error) {
 	claims, err := j.ValidateToken(refreshToken)
 	if err != nil {
 		return nil, err
@@ -141,13 +261,30 @@ func (j *JWTService) RefreshToken(refreshToken string, user *models.User, sessio
 		return nil, errors.New("invalid token type for refresh")
 	}
 
-	// Verify user and session match
-	if claims.UserID != user.ID || claims.SessionID != sessionID {
+	// Verify the token belongs to the user
+	if claims.UserID != user.ID {
 		return nil, errors.New("token validation failed")
 	}
 
+	if err := j.CheckRevoked(claims); err != nil {
+		return nil, err
+	}
+
+	if j.revocations != nil {
+		uses, err := j.revocations.IncrementWithTTL(usedRefreshTokenKey(claims.ID), remainingLifetime(claims))
+		if err != nil {
+			return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+		}
+		if uses > 1 {
+			if err := j.RevokeSession(claims.SessionID); err != nil {
+				return nil, err
+			}
+			return nil, ErrRefreshTokenReused
+		}
+	}
+
 	// Generate new token pair
-	return j.GenerateTokenPair(user, sessionID)
+	return j.GenerateTokenPair(user, claims.SessionID)
 }
 
 // GetTokenClaims extracts claims from a token without full validation (for expired tokens)
@@ -165,14 +302,127 @@ func (j *JWTService) GetTokenClaims(tokenString string) (*Claims, error) {
 	return claims, nil
 }
 
-// InvalidateToken adds token to blacklist (would need Redis implementation)
-func (j *JWTService) InvalidateToken(tokenID string) error {
-	// This would typically store the token ID in Redis with expiration
-	// For now, we'll implement basic invalidation
-	// In production, you'd want to store invalidated token IDs in Redis
+// InvalidateToken adds a token to the denylist until it expires
+func (j *JWTService) InvalidateToken(tokenID string, expiresAt time.Time) error {
+	if j.revocations == nil {
+		return ErrRevocationUnavailable
+	}
+
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	if err := j.revocations.Set(revokedTokenKey(tokenID), "1", ttl); err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return nil
+}
+
+// RevokeSession revokes every token issued to a session, including refresh
+// tokens not yet used
+func (j *JWTService) RevokeSession(sessionID string) error {
+	if j.revocations == nil {
+		return ErrRevocationUnavailable
+	}
+
+	if err := j.revocations.Set(revokedSessionKey(sessionID), "1", j.refreshTokenTTL); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
+
+// RevokeAllTokens revokes every token issued to a user so far, logging them
+// out of all devices, by bumping the user's token generation
+func (j *JWTService) RevokeAllTokens(userID int64) error {
+	if j.revocations == nil {
+		return ErrRevocationUnavailable
+	}
+
+	// The generation is a timestamp rather than a counter so it keeps
+	// increasing after the key expires; by then every token issued before
+	// it has expired too
+	generation := time.Now().UnixNano()
+	if err := j.revocations.Set(tokenGenerationKey(userID), generation, j.refreshTokenTTL); err != nil {
+		return fmt.Errorf("failed to revoke tokens: %w", err)
+	}
 	return nil
 }
 
+// CheckRevoked returns ErrRevokedToken if a validated token was revoked on
+// its own, with its session, or by logging its user out of all devices
+func (j *JWTService) CheckRevoked(claims *Claims) error {
+	if j.revocations == nil {
+		return nil
+	}
+
+	keys := []string{revokedTokenKey(claims.ID)}
+	if claims.SessionID != "" {
+		keys = append(keys, revokedSessionKey(claims.SessionID))
+	}
+	for _, key := range keys {
+		_, err := j.revocations.Get(key)
+		if err == nil {
+			return ErrRevokedToken
+		}
+		if err != storage.ErrCacheKeyNotFound {
+			return fmt.Errorf("failed to check token revocation: %w", err)
+		}
+	}
+
+	generation, err := j.tokenGeneration(claims.UserID)
+	if err != nil {
+		return err
+	}
+	if claims.Generation < generation {
+		return ErrRevokedToken
+	}
+	return nil
+}
+
+// tokenGeneration returns the user's current token generation; tokens from
+// earlier generations are revoked
+func (j *JWTService) tokenGeneration(userID int64) (int64, error) {
+	if j.revocations == nil {
+		return 0, nil
+	}
+
+	value, err := j.revocations.Get(tokenGenerationKey(userID))
+	if err == storage.ErrCacheKeyNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read token generation: %w", err)
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// remainingLifetime is how long a token stays valid
+func remainingLifetime(claims *Claims) time.Duration {
+	if claims.ExpiresAt == nil {
+		return time.Minute
+	}
+	if ttl := time.Until(claims.ExpiresAt.Time); ttl > 0 {
+		return ttl
+	}
+	return time.Second
+}
+
+func revokedTokenKey(tokenID string) string {
+	return "jwt:revoked:" + tokenID
+}
+
+func revokedSessionKey(sessionID string) string {
+	return "jwt:revoked-session:" + sessionID
+}
+
+func usedRefreshTokenKey(tokenID string) string {
+	return "jwt:refreshed:" + tokenID
+}
+
+func tokenGenerationKey(userID int64) string {
+	return fmt.Sprintf("jwt:generation:%d", userID)
+}
+
 // generateTokenID creates a unique ID for the token
 func (j *JWTService) generateTokenID() string {
 	bytes := make([]byte, 16)
@@ -265,8 +515,7 @@ func (j *JWTService) CreatePasswordResetToken(user *models.User, duration time.D
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(j.secretKey))
+	return j.signToken(claims)
 }
 
 // ValidatePasswordResetToken validates a password reset token
@@ -303,8 +552,7 @@ func (j *JWTService) CreateEmailVerificationToken(user *models.User, duration ti
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(j.secretKey))
+	return j.signToken(claims)
 }
 
 // ValidateEmailVerificationToken validates an email verification token
@@ -0,0 +1,210 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Signing algorithms supported for asymmetric keys
+const (
+	SigningAlgorithmRS256 = "RS256"
+	SigningAlgorithmEdDSA = "EdDSA"
+)
+
+// minRSAKeyBits is the smallest RSA key accepted for signing
+const minRSAKeyBits = 2048
+
+// SigningKey is a private key tokens are signed with, identified by the
+// "kid" header of the tokens it signs. A key takes over signing at
+// ActiveFrom; the key it replaces keeps verifying tokens, and stays in the
+// JWKS, until every token it signed has expired.
+type SigningKey struct {
+	ID         string
+	Algorithm  string
+	ActiveFrom time.Time
+	privateKey crypto.Signer
+}
+
+// NewSigningKey creates a signing key from an RSA or Ed25519 private key
+func NewSigningKey(id string, privateKey crypto.Signer, activeFrom time.Time) (*SigningKey, error) {
+	if id == "" {
+		return nil, fmt.Errorf("signing key ID is required")
+	}
+
+	key := &SigningKey{
+		ID:         id,
+		ActiveFrom: activeFrom,
+		privateKey: privateKey,
+	}
+	switch k := privateKey.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("signing key %s: RSA keys must be at least %d bits", id, minRSAKeyBits)
+		}
+		key.Algorithm = SigningAlgorithmRS256
+	case ed25519.PrivateKey:
+		key.Algorithm = SigningAlgorithmEdDSA
+	default:
+		return nil, fmt.Errorf("signing key %s: unsupported key type %T", id, privateKey)
+	}
+
+	return key, nil
+}
+
+// GenerateSigningKey creates a new Ed25519 signing key, active immediately
+func GenerateSigningKey(id string) (*SigningKey, error) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	return NewSigningKey(id, privateKey, time.Now())
+}
+
+// LoadSigningKeys loads the signing keys listed in spec, a comma-separated
+// list of "kid=path" entries naming PEM private key files. An entry may end
+// in "@" and an RFC 3339 time to schedule when the key takes over signing;
+// keys without one are active from the start.
+func LoadSigningKeys(spec string) ([]*SigningKey, error) {
+	var keys []*SigningKey
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, location, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("signing key %q: expected kid=path", entry)
+		}
+		id = strings.TrimSpace(id)
+
+		path, at, scheduled := strings.Cut(location, "@")
+		var activeFrom time.Time
+		if scheduled {
+			var err error
+			activeFrom, err = time.Parse(time.RFC3339, strings.TrimSpace(at))
+			if err != nil {
+				return nil, fmt.Errorf("signing key %s: invalid activation time: %w", id, err)
+			}
+		}
+
+		privateKey, err := readPrivateKey(strings.TrimSpace(path))
+		if err != nil {
+			return nil, fmt.Errorf("signing key %s: %w", id, err)
+		}
+		key, err := NewSigningKey(id, privateKey, activeFrom)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// readPrivateKey reads a PKCS #8 or PKCS #1 PEM private key file
+func readPrivateKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s holds no PEM data", path)
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := parsed.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("%s holds an unsupported key type %T", path, parsed)
+		}
+		return signer, nil
+	default:
+		return nil, fmt.Errorf("%s holds a %q block, not a private key", path, block.Type)
+	}
+}
+
+// signingMethod returns the JWT signing method for the key's algorithm
+func (k *SigningKey) signingMethod() jwt.SigningMethod {
+	if k.Algorithm == SigningAlgorithmEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+// JWK is the public half of a signing key in JSON Web Key form (RFC 7517)
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`   // RSA modulus
+	E         string `json:"e,omitempty"`   // RSA exponent
+	Curve     string `json:"crv,omitempty"` // OKP curve
+	X         string `json:"x,omitempty"`   // OKP public key
+}
+
+// JWKS is the set of public keys tokens may be verified with
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK returns the public key in JSON Web Key form
+func (k *SigningKey) JWK() JWK {
+	jwk := JWK{
+		KeyID:     k.ID,
+		Use:       "sig",
+		Algorithm: k.Algorithm,
+	}
+	switch public := k.privateKey.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	}
+	return jwk
+}
+
+// sortSigningKeys returns a copy of keys ordered by activation, checking
+// their IDs are unique
+func sortSigningKeys(keys []*SigningKey) ([]*SigningKey, error) {
+	sorted := make([]*SigningKey, len(keys))
+	copy(sorted, keys)
+	sort.SliceStable(sorted, func(i, k int) bool {
+		return sorted[i].ActiveFrom.Before(sorted[k].ActiveFrom)
+	})
+
+	seen := make(map[string]bool, len(sorted))
+	for _, key := range sorted {
+		if seen[key.ID] {
+			return nil, fmt.Errorf("duplicate signing key ID %q", key.ID)
+		}
+		seen[key.ID] = true
+	}
+	return sorted, nil
+}
@@ -0,0 +1,44 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/URLshorter/url-shortener/internal/models"
+)
+
+// Administrative link operations. Unlike the user-facing methods they skip
+// ownership checks, so they must only be reachable from operator tooling.
+
+// LookupURL returns a link whatever its state, including who created it
+func (s *ShortenerService) LookupURL(shortCode string) (*models.URLMapping, error) {
+	return s.db.LookupURLMapping(shortCode)
+}
+
+// SetURLActive enables or disables any link and drops its cached mapping so
+// the change takes effect immediately
+func (s *ShortenerService) SetURLActive(shortCode string, active bool) error {
+	if err := s.db.SetURLActive(shortCode, active); err != nil {
+		return err
+	}
+	return s.PurgeCache(shortCode)
+}
+
+// TransferURL hands a link over to another user
+func (s *ShortenerService) TransferURL(shortCode string, userID int64) error {
+	if err := s.db.TransferURL(shortCode, userID); err != nil {
+		return err
+	}
+	return s.PurgeCache(shortCode)
+}
+
+// PurgeCache removes a link's cached mapping and analytics snapshot. Click
+// counters are kept since they may hold clicks not yet persisted.
+func (s *ShortenerService) PurgeCache(shortCode string) error {
+	if s.cache == nil {
+		return nil
+	}
+	if err := s.cache.DeleteURLMapping(shortCode); err != nil {
+		return err
+	}
+	return s.cache.Delete(fmt.Sprintf("analytics:%s", shortCode))
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/URLshorter/url-shortener/internal/models"
+)
+
+// LinkMetadataStore persists metadata fetched from link destinations
+type LinkMetadataStore interface {
+	SaveLinkMetadata(shortCode string, destinationVersion int, metadata *models.LinkMetadata) error
+}
+
+// LinkMetadataConfig tunes the link metadata workers
+type LinkMetadataConfig struct {
+	QueueSize int
+	Workers   int
+}
+
+// DefaultLinkMetadataConfig returns the default worker settings
+func DefaultLinkMetadataConfig() LinkMetadataConfig {
+	return LinkMetadataConfig{
+		QueueSize: 1000,
+		Workers:   2,
+	}
+}
+
+// linkMetadataJob is a link destination waiting to be fetched
+type linkMetadataJob struct {
+	shortCode          string
+	url                string
+	destinationVersion int
+}
+
+// LinkMetadataService fetches link previews in the background so creating a
+// link never waits on its destination. Jobs are dropped when the queue is
+// full, as during a large bulk import; those links simply have no preview.
+type LinkMetadataService struct {
+	store   LinkMetadataStore
+	fetcher *MetadataFetcher
+	queue   chan linkMetadataJob
+	wg      sync.WaitGroup
+
+	closeMutex sync.RWMutex
+	closed     bool
+}
+
+// NewLinkMetadataService creates the service and starts its workers
+func NewLinkMetadataService(store LinkMetadataStore, fetcher *MetadataFetcher, config LinkMetadataConfig) *LinkMetadataService {
+	defaults := DefaultLinkMetadataConfig()
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaults.QueueSize
+	}
+	if config.Workers <= 0 {
+		config.Workers = defaults.Workers
+	}
+
+	s := &LinkMetadataService{
+		store:   store,
+		fetcher: fetcher,
+		queue:   make(chan linkMetadataJob, config.QueueSize),
+	}
+
+	for i := 0; i < config.Workers; i++ {
+		s.wg.Add(1)
+		go s.worker()
+	}
+
+	return s
+}
+
+// Enqueue schedules fetching metadata for a link's destination without
+// blocking. It returns false when the job was dropped.
+func (s *LinkMetadataService) Enqueue(shortCode, url string, destinationVersion int) bool {
+	s.closeMutex.RLock()
+	defer s.closeMutex.RUnlock()
+
+	if s.closed {
+		return false
+	}
+
+	select {
+	case s.queue <- linkMetadataJob{shortCode: shortCode, url: url, destinationVersion: destinationVersion}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Shutdown stops accepting jobs and waits for the queued ones to finish, or
+// for the context to expire
+func (s *LinkMetadataService) Shutdown(ctx context.Context) error {
+	s.closeMutex.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.closeMutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *LinkMetadataService) worker() {
+	defer s.wg.Done()
+
+	for job := range s.queue {
+		s.fetch(job)
+	}
+}
+
+// fetch downloads a destination's metadata and stores it on the link
+func (s *LinkMetadataService) fetch(job linkMetadataJob) {
+	metadata, err := s.fetcher.Fetch(context.Background(), job.url)
+	if err != nil {
+		log.Printf("Failed to fetch metadata for %s: %v", job.shortCode, err)
+		return
+	}
+
+	if err := s.store.SaveLinkMetadata(job.shortCode, job.destinationVersion, metadata); err != nil {
+		log.Printf("Failed to save metadata for %s: %v", job.shortCode, err)
+	}
+}
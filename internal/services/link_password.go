@@ -0,0 +1,145 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/URLshorter/url-shortener/internal/storage"
+)
+
+const (
+	MinLinkPasswordLength = 4
+	MaxLinkPasswordLength = 72 // bcrypt ignores anything past 72 bytes
+)
+
+// LinkPasswordService guards password-protected short links: it hashes link
+// passwords, verifies challenge submissions with per-visitor lockout and
+// issues the signed access tokens stored in the visitor's cookie
+type LinkPasswordService struct {
+	cache           storage.Cache
+	secret          []byte
+	bcryptCost      int
+	accessTTL       time.Duration
+	maxAttempts     int64
+	lockoutDuration time.Duration
+}
+
+// NewLinkPasswordService creates a new link password service. Without a cache
+// failed attempts are counted in process, so visitors are still locked out.
+func NewLinkPasswordService(cache storage.Cache, secret string) *LinkPasswordService {
+	if cache == nil {
+		cache = storage.NewMemoryCache()
+	}
+
+	return &LinkPasswordService{
+		cache:           cache,
+		secret:          []byte(secret),
+		bcryptCost:      12,
+		accessTTL:       time.Hour,
+		maxAttempts:     5,
+		lockoutDuration: 15 * time.Minute,
+	}
+}
+
+// HashPassword validates and hashes a link password. Links can't be protected
+// while no secret is configured, since they could never be unlocked.
+func (l *LinkPasswordService) HashPassword(password string) (string, error) {
+	if len(l.secret) == 0 {
+		return "", ErrLinkPasswordUnavailable
+	}
+	if len(password) < MinLinkPasswordLength || len(password) > MaxLinkPasswordLength {
+		return "", ErrInvalidLinkPassword
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), l.bcryptCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash link password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// Unlock checks a submitted password and returns a signed access token on success.
+// Failed attempts are counted per link and client IP; once the limit is hit
+// the visitor is locked out until the counter expires.
+func (l *LinkPasswordService) Unlock(mapping *models.URLMapping, password, clientIP string) (string, time.Time, error) {
+	// Tokens signed with an empty key could be forged by anyone
+	if len(l.secret) == 0 {
+		return "", time.Time{}, ErrLinkPasswordUnavailable
+	}
+
+	failuresKey := fmt.Sprintf("link_password_failures:%s:%s", mapping.ShortCode, clientIP)
+
+	// Guesses aren't checked while failures can't be counted
+	value, err := l.cache.Get(failuresKey)
+	if err == nil {
+		if failures, _ := strconv.ParseInt(value, 10, 64); failures >= l.maxAttempts {
+			return "", time.Time{}, ErrLinkPasswordLocked
+		}
+	} else if err != storage.ErrCacheKeyNotFound {
+		return "", time.Time{}, fmt.Errorf("failed to check password attempts: %w", err)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(mapping.PasswordHash), []byte(password)); err != nil {
+		failures, err := l.cache.IncrementWithTTL(failuresKey, l.lockoutDuration)
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("failed to count password attempt: %w", err)
+		}
+		if failures >= l.maxAttempts {
+			return "", time.Time{}, ErrLinkPasswordLocked
+		}
+		return "", time.Time{}, ErrIncorrectLinkPassword
+	}
+
+	l.cache.Delete(failuresKey)
+
+	expiresAt := time.Now().Add(l.accessTTL)
+	return l.signAccessToken(mapping, expiresAt), expiresAt, nil
+}
+
+// HasAccess reports whether an access token unlocks the given link
+func (l *LinkPasswordService) HasAccess(mapping *models.URLMapping, token string) bool {
+	if len(l.secret) == 0 {
+		return false
+	}
+
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 {
+		return false
+	}
+
+	expiry, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || time.Now().Unix() > expiry {
+		return false
+	}
+
+	expected := l.signAccessToken(mapping, time.Unix(expiry, 0))
+	return hmac.Equal([]byte(token), []byte(expected))
+}
+
+// AccessTTL returns how long an access token stays valid
+func (l *LinkPasswordService) AccessTTL() time.Duration {
+	return l.accessTTL
+}
+
+// signAccessToken builds "<expiry>.<mac>"; the MAC covers the password hash
+// so changing or removing the password invalidates issued cookies
+func (l *LinkPasswordService) signAccessToken(mapping *models.URLMapping, expiresAt time.Time) string {
+	expiry := strconv.FormatInt(expiresAt.Unix(), 10)
+
+	mac := hmac.New(sha256.New, l.secret)
+	mac.Write([]byte(mapping.ShortCode))
+	mac.Write([]byte{0})
+	mac.Write([]byte(mapping.PasswordHash))
+	mac.Write([]byte{0})
+	mac.Write([]byte(expiry))
+
+	return expiry + "." + hex.EncodeToString(mac.Sum(nil))
+}
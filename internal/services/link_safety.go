@@ -0,0 +1,445 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/URLshorter/url-shortener/configs"
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/URLshorter/url-shortener/internal/storage"
+)
+
+// DestinationChecker screens a URL against an external reputation feed such
+// as Google Safe Browsing. A nil verdict or one that is not Malicious means
+// the feed knows nothing bad about the URL.
+type DestinationChecker interface {
+	CheckURL(ctx context.Context, rawURL string) (*models.SafetyVerdict, error)
+}
+
+// LinkSafetyConfig tunes destination screening
+type LinkSafetyConfig struct {
+	RecheckInterval  time.Duration // how long a link's last screening stays valid
+	RecheckBatchSize int           // links screened per pass
+	PollInterval     time.Duration // how often the background loop looks for links due a re-check
+	CheckTimeout     time.Duration // per reputation feed lookup
+	BlocklistRefresh time.Duration // how long the blocklist is cached between reloads
+}
+
+// DefaultLinkSafetyConfig returns the default screening settings
+func DefaultLinkSafetyConfig() LinkSafetyConfig {
+	return LinkSafetyConfig{
+		RecheckInterval:  24 * time.Hour,
+		RecheckBatchSize: 500,
+		PollInterval:     time.Minute,
+		CheckTimeout:     5 * time.Second,
+		BlocklistRefresh: time.Minute,
+	}
+}
+
+// Longest blocklist pattern, in bytes
+const maxBlocklistPatternLength = 2048
+
+// compiledBlocklistEntry is a blocklist entry ready for matching
+type compiledBlocklistEntry struct {
+	entry *models.BlocklistEntry
+	glob  *regexp.Regexp // for URL patterns
+}
+
+// LinkSafetyService screens link destinations against the admin-managed
+// blocklist and any registered reputation feeds. Links created with a
+// destination that is already known to be malicious are rejected; existing
+// links are re-screened in the background and quarantined when one of their
+// destinations turns bad, so visitors see a warning instead of a redirect.
+//
+// Reputation feeds fail open: if a feed is unreachable the destination is
+// treated as safe and the failure is logged. The blocklist never fails open.
+type LinkSafetyService struct {
+	store    storage.SafetyRepository
+	cache    storage.Cache
+	config   LinkSafetyConfig
+	checkers []DestinationChecker
+
+	blocklistMutex    sync.RWMutex
+	blocklist         []compiledBlocklistEntry
+	blocklistLoadedAt time.Time
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewLinkSafetyService creates the service, filling unset settings from the
+// defaults. Call Start to re-screen existing links in the background.
+func NewLinkSafetyService(store storage.SafetyRepository, cache storage.Cache, config LinkSafetyConfig) *LinkSafetyService {
+	defaults := DefaultLinkSafetyConfig()
+	if config.RecheckInterval <= 0 {
+		config.RecheckInterval = defaults.RecheckInterval
+	}
+	if config.RecheckBatchSize <= 0 {
+		config.RecheckBatchSize = defaults.RecheckBatchSize
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaults.PollInterval
+	}
+	if config.CheckTimeout <= 0 {
+		config.CheckTimeout = defaults.CheckTimeout
+	}
+	if config.BlocklistRefresh <= 0 {
+		config.BlocklistRefresh = defaults.BlocklistRefresh
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &LinkSafetyService{
+		store:  store,
+		cache:  cache,
+		config: config,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// NewLinkSafetyServiceFromConfig creates the service from the application
+// settings, adding the Safe Browsing feed when an API key is configured
+func NewLinkSafetyServiceFromConfig(store storage.SafetyRepository, cache storage.Cache, config *configs.Config) *LinkSafetyService {
+	service := NewLinkSafetyService(store, cache, LinkSafetyConfig{
+		RecheckInterval:  time.Duration(config.SafetyRecheckIntervalHours) * time.Hour,
+		RecheckBatchSize: config.SafetyRecheckBatchSize,
+	})
+	if config.SafeBrowsingAPIKey != "" {
+		service.AddChecker(NewSafeBrowsingChecker(config.SafeBrowsingAPIKey, config.SafeBrowsingURL))
+	}
+	return service
+}
+
+// AddChecker registers a reputation feed. Feeds are consulted in the order
+// they were added, after the blocklist.
+func (s *LinkSafetyService) AddChecker(checker DestinationChecker) {
+	s.checkers = append(s.checkers, checker)
+}
+
+// CheckURL screens a single destination
+func (s *LinkSafetyService) CheckURL(ctx context.Context, rawURL string) (*models.SafetyVerdict, error) {
+	if verdict, err := s.checkBlocklist(rawURL); err != nil || verdict.Malicious {
+		return verdict, err
+	}
+
+	for _, checker := range s.checkers {
+		checkCtx, cancel := context.WithTimeout(ctx, s.config.CheckTimeout)
+		verdict, err := checker.CheckURL(checkCtx, rawURL)
+		cancel()
+		if err != nil {
+			log.Printf("Destination reputation check failed for %s: %v", rawURL, err)
+			continue
+		}
+		if verdict != nil && verdict.Malicious {
+			return verdict, nil
+		}
+	}
+
+	return &models.SafetyVerdict{}, nil
+}
+
+// CheckDestinations screens each destination and returns the first malicious
+// verdict, or a safe verdict when none is
+func (s *LinkSafetyService) CheckDestinations(ctx context.Context, destinations []string) (*models.SafetyVerdict, error) {
+	for _, destination := range destinations {
+		if destination == "" {
+			continue
+		}
+		verdict, err := s.CheckURL(ctx, destination)
+		if err != nil || verdict.Malicious {
+			return verdict, err
+		}
+	}
+	return &models.SafetyVerdict{}, nil
+}
+
+// checkBlocklist matches a destination against the blocklist
+func (s *LinkSafetyService) checkBlocklist(rawURL string) (*models.SafetyVerdict, error) {
+	blocklist, err := s.loadBlocklist()
+	if err != nil {
+		return nil, err
+	}
+
+	host := ""
+	if parsed, err := url.Parse(rawURL); err == nil {
+		host = strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+	}
+
+	for _, compiled := range blocklist {
+		entry := compiled.entry
+		matched := false
+		switch entry.Kind {
+		case models.BlocklistKindDomain:
+			matched = host != "" && (host == entry.Pattern || strings.HasSuffix(host, "."+entry.Pattern))
+		case models.BlocklistKindURL:
+			matched = compiled.glob.MatchString(rawURL)
+		}
+		if matched {
+			reason := entry.Reason
+			if reason == "" {
+				reason = fmt.Sprintf("destination matches blocked %s %s", entry.Kind, entry.Pattern)
+			}
+			return &models.SafetyVerdict{Malicious: true, Source: "blocklist", Reason: reason}, nil
+		}
+	}
+	return &models.SafetyVerdict{}, nil
+}
+
+// loadBlocklist returns the cached blocklist, reloading it once it is older
+// than BlocklistRefresh so entries added by other instances are picked up
+func (s *LinkSafetyService) loadBlocklist() ([]compiledBlocklistEntry, error) {
+	s.blocklistMutex.RLock()
+	blocklist, loadedAt := s.blocklist, s.blocklistLoadedAt
+	s.blocklistMutex.RUnlock()
+
+	if !loadedAt.IsZero() && time.Since(loadedAt) < s.config.BlocklistRefresh {
+		return blocklist, nil
+	}
+
+	entries, err := s.store.ListBlocklistEntries()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load blocklist: %w", err)
+	}
+	blocklist = make([]compiledBlocklistEntry, 0, len(entries))
+	for _, entry := range entries {
+		compiled := compiledBlocklistEntry{entry: entry}
+		if entry.Kind == models.BlocklistKindURL {
+			compiled.glob = compileURLGlob(entry.Pattern)
+		}
+		blocklist = append(blocklist, compiled)
+	}
+
+	s.blocklistMutex.Lock()
+	s.blocklist, s.blocklistLoadedAt = blocklist, time.Now()
+	s.blocklistMutex.Unlock()
+	return blocklist, nil
+}
+
+// invalidateBlocklist makes the next check reload the blocklist
+func (s *LinkSafetyService) invalidateBlocklist() {
+	s.blocklistMutex.Lock()
+	s.blocklistLoadedAt = time.Time{}
+	s.blocklistMutex.Unlock()
+}
+
+// compileURLGlob turns a URL pattern into a case-insensitive regexp matching
+// the whole URL, where * matches any run of characters
+func compileURLGlob(pattern string) *regexp.Regexp {
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regexp.MustCompile(`(?is)^` + strings.Join(parts, ".*") + `$`)
+}
+
+// normalizeBlocklistEntry checks an entry's kind and pattern. Domain patterns
+// are reduced to a lowercase host name, so "https://Evil.example/path" and
+// "*.evil.example" both block evil.example and its subdomains.
+func normalizeBlocklistEntry(entry *models.BlocklistEntry) error {
+	entry.Kind = strings.ToLower(strings.TrimSpace(entry.Kind))
+	entry.Pattern = strings.TrimSpace(entry.Pattern)
+	entry.Reason = strings.TrimSpace(entry.Reason)
+
+	switch entry.Kind {
+	case models.BlocklistKindDomain:
+		pattern := strings.ToLower(entry.Pattern)
+		if strings.Contains(pattern, "://") {
+			parsed, err := url.Parse(pattern)
+			if err != nil {
+				return ErrInvalidBlocklistEntry
+			}
+			pattern = parsed.Hostname()
+		}
+		pattern = strings.TrimSuffix(strings.TrimPrefix(pattern, "*."), ".")
+		if pattern == "" || strings.ContainsAny(pattern, "*/?#@: \t") {
+			return ErrInvalidBlocklistEntry
+		}
+		entry.Pattern = pattern
+	case models.BlocklistKindURL:
+		if entry.Pattern == "" || strings.Trim(entry.Pattern, "*") == "" {
+			return ErrInvalidBlocklistEntry
+		}
+	default:
+		return ErrInvalidBlocklistEntry
+	}
+
+	if len(entry.Pattern) > maxBlocklistPatternLength {
+		return ErrInvalidBlocklistEntry
+	}
+	return nil
+}
+
+// AddBlocklistEntry blocks a domain or URL pattern. Existing links are not
+// affected until their next re-check; run RecheckLinks to apply it sooner.
+func (s *LinkSafetyService) AddBlocklistEntry(entry *models.BlocklistEntry) error {
+	if err := normalizeBlocklistEntry(entry); err != nil {
+		return err
+	}
+	if err := s.store.AddBlocklistEntry(entry); err != nil {
+		return err
+	}
+	s.invalidateBlocklist()
+	return nil
+}
+
+// RemoveBlocklistEntry unblocks a pattern. Links it quarantined stay
+// quarantined until they are released.
+func (s *LinkSafetyService) RemoveBlocklistEntry(id int64) error {
+	if err := s.store.DeleteBlocklistEntry(id); err != nil {
+		return err
+	}
+	s.invalidateBlocklist()
+	return nil
+}
+
+// ListBlocklist returns every blocklist entry
+func (s *LinkSafetyService) ListBlocklist() ([]*models.BlocklistEntry, error) {
+	return s.store.ListBlocklistEntries()
+}
+
+// QuarantineURL makes a link show a warning page instead of redirecting
+func (s *LinkSafetyService) QuarantineURL(shortCode, reason string) error {
+	if err := s.store.QuarantineURL(shortCode, strings.TrimSpace(reason)); err != nil {
+		return err
+	}
+	s.purge(shortCode)
+	return nil
+}
+
+// ReleaseURL lifts a link's quarantine. The link counts as freshly screened,
+// so a feed that still flags it won't quarantine it again until the next
+// re-check interval.
+func (s *LinkSafetyService) ReleaseURL(shortCode string) error {
+	if err := s.store.ReleaseURL(shortCode); err != nil {
+		return err
+	}
+	s.purge(shortCode)
+	return nil
+}
+
+// purge drops a link's cached mapping so redirects see its new state
+func (s *LinkSafetyService) purge(shortCode string) {
+	if s.cache != nil {
+		s.cache.DeleteURLMapping(shortCode)
+	}
+}
+
+// RecheckLinks screens one batch of links whose last screening is older than
+// RecheckInterval, quarantining those with a malicious destination
+func (s *LinkSafetyService) RecheckLinks(ctx context.Context) (*models.SafetyRecheckResult, error) {
+	mappings, err := s.store.ListURLsForSafetyCheck(time.Now().Add(-s.config.RecheckInterval), s.config.RecheckBatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list links to re-check: %w", err)
+	}
+
+	result := &models.SafetyRecheckResult{}
+	for _, mapping := range mappings {
+		if ctx.Err() != nil {
+			break
+		}
+
+		verdict, err := s.CheckDestinations(ctx, mapping.Destinations())
+		if err != nil {
+			return result, err
+		}
+		result.Checked++
+
+		if verdict.Malicious {
+			reason := verdict.Reason
+			if reason == "" {
+				reason = "destination flagged by " + verdict.Source
+			}
+			if err := s.QuarantineURL(mapping.ShortCode, reason); err != nil {
+				log.Printf("Failed to quarantine %s: %v", mapping.ShortCode, err)
+				continue
+			}
+			log.Printf("Quarantined %s: %s", mapping.ShortCode, reason)
+			result.Quarantined = append(result.Quarantined, mapping.ShortCode)
+		}
+
+		if err := s.store.MarkURLSafetyChecked(mapping.ShortCode, time.Now()); err != nil {
+			log.Printf("Failed to mark %s as checked: %v", mapping.ShortCode, err)
+		}
+	}
+	return result, nil
+}
+
+// Start launches the background re-check loop
+func (s *LinkSafetyService) Start() {
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Shutdown stops the re-check loop and waits for the current batch to finish
+func (s *LinkSafetyService) Shutdown(ctx context.Context) error {
+	s.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *LinkSafetyService) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Keep going while full batches come back, so a backlog clears quickly
+		for s.ctx.Err() == nil {
+			result, err := s.RecheckLinks(s.ctx)
+			if err != nil {
+				log.Printf("Link safety re-check: %v", err)
+				break
+			}
+			if result.Checked < s.config.RecheckBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// screenDestinations rejects destinations on the blocklist or flagged by a
+// reputation feed. It is a no-op when no safety service is set.
+func (s *ShortenerService) screenDestinations(destinations ...string) error {
+	if s.safety == nil {
+		return nil
+	}
+	verdict, err := s.safety.CheckDestinations(context.Background(), destinations)
+	if err != nil {
+		return err
+	}
+	if verdict.Malicious {
+		return ErrUnsafeDestination
+	}
+	return nil
+}
+
+// Link safety errors
+var (
+	ErrUnsafeDestination     = &ServiceError{Message: "destination is blocked or known to be malicious"}
+	ErrInvalidBlocklistEntry = &ServiceError{Message: "blocklist entries need a kind of domain or url and a valid pattern"}
+)
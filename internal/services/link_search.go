@@ -0,0 +1,230 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/URLshorter/url-shortener/internal/storage"
+)
+
+// Limits on link tags
+const (
+	MaxTagsPerLink = 20
+	MaxTagLength   = 50
+)
+
+// Limits on link searches
+const (
+	defaultSearchLimit  = 20
+	maxSearchLimit      = 100
+	maxSearchTextLength = 200
+)
+
+// searchDomainPattern matches the hostnames a search can be limited to
+var searchDomainPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*$`)
+
+// searchCursor is the opaque cursor handed to clients. It records the sort
+// it was issued for so it can't be replayed against a different ordering.
+type searchCursor struct {
+	Sort       string                  `json:"s"`
+	Descending bool                    `json:"d"`
+	After      storage.URLSearchCursor `json:"a"`
+}
+
+// NormalizeTags lowercases and trims tags, dropping empty and repeated ones
+func NormalizeTags(tags []string) ([]string, error) {
+	var normalized []string
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if len([]rune(tag)) > MaxTagLength || strings.IndexFunc(tag, unicode.IsControl) >= 0 {
+			return nil, ErrInvalidTag
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) > MaxTagsPerLink {
+		return nil, ErrTooManyTags
+	}
+	return normalized, nil
+}
+
+// SearchUserURLs returns a page of a user's links matching the request,
+// along with a cursor for the next page if there is one
+func (s *ShortenerService) SearchUserURLs(userID int64, req *models.URLSearchRequest) (*models.URLSearchResponse, error) {
+	search, err := buildURLSearch(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fetch one extra link to learn whether another page follows
+	limit := search.Limit
+	search.Limit++
+	urls, err := s.db.SearchUserURLs(userID, search)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.URLSearchResponse{URLs: urls}
+	if len(urls) > limit {
+		response.URLs = urls[:limit]
+		last := response.URLs[limit-1]
+		cursor := searchCursor{
+			Sort:       search.Sort,
+			Descending: search.Descending,
+			After:      storage.URLSearchCursor{ID: last.ID},
+		}
+		switch search.Sort {
+		case models.URLSortClickCount:
+			cursor.After.ClickCount = last.ClickCount
+		case models.URLSortTitle:
+			if last.Title != nil {
+				cursor.After.Title = *last.Title
+			}
+		default:
+			cursor.After.CreatedAt = last.CreatedAt
+		}
+		encoded, err := json.Marshal(cursor)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode cursor: %w", err)
+		}
+		response.NextCursor = base64.RawURLEncoding.EncodeToString(encoded)
+	}
+
+	if response.URLs == nil {
+		response.URLs = []*models.UserURLResponse{}
+	}
+	for _, url := range response.URLs {
+		url.ShortURL = fmt.Sprintf("%s/%s", s.config.BaseURL, url.ShortCode)
+	}
+	return response, nil
+}
+
+// buildURLSearch validates a search request
+func buildURLSearch(req *models.URLSearchRequest) (*storage.URLSearch, error) {
+	search := &storage.URLSearch{
+		Text:       strings.TrimSpace(req.Query),
+		State:      req.State,
+		MinClicks:  req.MinClicks,
+		MaxClicks:  req.MaxClicks,
+		Sort:       req.Sort,
+		Descending: req.Order != "asc",
+		Limit:      req.Limit,
+	}
+
+	if len(search.Text) > maxSearchTextLength {
+		return nil, ErrInvalidSearchText
+	}
+
+	tags, err := NormalizeTags(req.Tags)
+	if err != nil {
+		return nil, err
+	}
+	search.Tags = tags
+
+	if domain := strings.ToLower(strings.TrimSpace(req.Domain)); domain != "" {
+		// Accept a pasted URL as well as a bare hostname
+		if parsed, err := url.Parse(domain); err == nil && parsed.Host != "" {
+			domain = parsed.Hostname()
+		}
+		if !searchDomainPattern.MatchString(domain) {
+			return nil, ErrInvalidSearchDomain
+		}
+		search.Domain = domain
+	}
+
+	if req.CreatedFrom != "" {
+		from, _, err := parseSearchDate(req.CreatedFrom)
+		if err != nil {
+			return nil, err
+		}
+		search.CreatedFrom = &from
+	}
+	if req.CreatedTo != "" {
+		to, isDate, err := parseSearchDate(req.CreatedTo)
+		if err != nil {
+			return nil, err
+		}
+		// A date includes the whole day
+		if isDate {
+			to = to.AddDate(0, 0, 1)
+		}
+		search.CreatedTo = &to
+	}
+
+	switch search.State {
+	case "", "all":
+		search.State = ""
+	case models.URLStateActive, models.URLStateExpired:
+	default:
+		return nil, ErrInvalidSearchState
+	}
+
+	if (search.MinClicks != nil && *search.MinClicks < 0) || (search.MaxClicks != nil && *search.MaxClicks < 0) ||
+		(search.MinClicks != nil && search.MaxClicks != nil && *search.MinClicks > *search.MaxClicks) {
+		return nil, ErrInvalidSearchClicks
+	}
+
+	switch search.Sort {
+	case "":
+		search.Sort = models.URLSortCreatedAt
+	case models.URLSortCreatedAt, models.URLSortClickCount, models.URLSortTitle:
+	default:
+		return nil, ErrInvalidSearchSort
+	}
+	if req.Order != "" && req.Order != "asc" && req.Order != "desc" {
+		return nil, ErrInvalidSearchSort
+	}
+
+	if search.Limit < 1 || search.Limit > maxSearchLimit {
+		search.Limit = defaultSearchLimit
+	}
+
+	if req.Cursor != "" {
+		var cursor searchCursor
+		decoded, err := base64.RawURLEncoding.DecodeString(req.Cursor)
+		if err != nil || json.Unmarshal(decoded, &cursor) != nil ||
+			cursor.Sort != search.Sort || cursor.Descending != search.Descending {
+			return nil, ErrInvalidSearchCursor
+		}
+		search.After = &cursor.After
+	}
+
+	return search, nil
+}
+
+// parseSearchDate parses a date or an RFC 3339 timestamp, reporting which
+// it was. Dates are taken as UTC midnight.
+func parseSearchDate(value string) (time.Time, bool, error) {
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		return date, true, nil
+	}
+	timestamp, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false, ErrInvalidSearchDate
+	}
+	return timestamp, false, nil
+}
+
+// Tag and search errors
+var (
+	ErrInvalidTag          = &ServiceError{Message: fmt.Sprintf("tags must be at most %d characters without control characters", MaxTagLength)}
+	ErrTooManyTags         = &ServiceError{Message: fmt.Sprintf("a link can have at most %d tags", MaxTagsPerLink)}
+	ErrInvalidSearchText   = &ServiceError{Message: fmt.Sprintf("search text must be at most %d characters", maxSearchTextLength)}
+	ErrInvalidSearchDomain = &ServiceError{Message: "domain must be a hostname such as example.com"}
+	ErrInvalidSearchDate   = &ServiceError{Message: "dates must be YYYY-MM-DD or RFC 3339 timestamps"}
+	ErrInvalidSearchState  = &ServiceError{Message: "state must be one of all, active or expired"}
+	ErrInvalidSearchClicks = &ServiceError{Message: "click bounds must be non-negative with min_clicks at most max_clicks"}
+	ErrInvalidSearchSort   = &ServiceError{Message: "sort must be one of created_at, click_count or title, and order asc or desc"}
+	ErrInvalidSearchCursor = &ServiceError{Message: "cursor is invalid or was issued for a different sort"}
+)
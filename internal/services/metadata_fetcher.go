@@ -0,0 +1,316 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/URLshorter/url-shortener/internal/models"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+// MetadataFetcherConfig limits what the metadata fetcher downloads
+type MetadataFetcherConfig struct {
+	Timeout      time.Duration // for the whole request, redirects included
+	MaxBodyBytes int64         // the page is parsed up to this many bytes
+	MaxRedirects int
+	UserAgent    string
+
+	// AllowPrivateNetworks lets the fetcher connect to loopback, private and
+	// link-local addresses. It exists for tests; leave it off in production.
+	AllowPrivateNetworks bool
+}
+
+// DefaultMetadataFetcherConfig returns the default fetcher limits
+func DefaultMetadataFetcherConfig() MetadataFetcherConfig {
+	return MetadataFetcherConfig{
+		Timeout:      5 * time.Second,
+		MaxBodyBytes: 512 * 1024,
+		MaxRedirects: 5,
+		UserAgent:    "URLShortenerBot/1.0 (link preview)",
+	}
+}
+
+// Longest values kept from a page, in characters
+const (
+	maxMetadataTitleLength       = 200
+	maxMetadataDescriptionLength = 1000
+	maxMetadataURLLength         = 2048
+)
+
+// nonPublicNetworks are reserved ranges not covered by the net.IP helpers
+var nonPublicNetworks = parseCIDRs(
+	"0.0.0.0/8",      // "this" network
+	"100.64.0.0/10",  // carrier-grade NAT
+	"192.0.0.0/24",   // IETF protocol assignments
+	"198.18.0.0/15",  // benchmarking
+	"240.0.0.0/4",    // reserved, including broadcast
+	"64:ff9b:1::/48", // local-use IPv4/IPv6 translation
+)
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks[i] = network
+	}
+	return networks
+}
+
+// MetadataFetcher downloads a link's destination and reads its title,
+// description, OpenGraph and Twitter card tags and favicon. Connections to
+// non-public addresses are refused after DNS resolution, so a hostname that
+// resolves to an internal service is blocked as well.
+type MetadataFetcher struct {
+	client *http.Client
+	config MetadataFetcherConfig
+}
+
+// NewMetadataFetcher creates a fetcher, filling unset limits from the defaults
+func NewMetadataFetcher(config MetadataFetcherConfig) *MetadataFetcher {
+	defaults := DefaultMetadataFetcherConfig()
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = defaults.MaxBodyBytes
+	}
+	if config.MaxRedirects <= 0 {
+		config.MaxRedirects = defaults.MaxRedirects
+	}
+	if config.UserAgent == "" {
+		config.UserAgent = defaults.UserAgent
+	}
+
+	dialer := &net.Dialer{Timeout: config.Timeout}
+	if !config.AllowPrivateNetworks {
+		dialer.Control = refuseNonPublicAddress
+	}
+
+	transport := &http.Transport{
+		// No proxy: the address check must see the destination, not the proxy
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   config.Timeout,
+		ResponseHeaderTimeout: config.Timeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       30 * time.Second,
+	}
+
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   config.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > config.MaxRedirects {
+				return ErrMetadataTooManyRedirects
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return ErrMetadataUnsupportedScheme
+			}
+			return nil
+		},
+	}
+
+	return &MetadataFetcher{client: client, config: config}
+}
+
+// refuseNonPublicAddress is a dialer control function that fails connections
+// to addresses outside the public internet
+func refuseNonPublicAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !isPublicIP(ip) {
+		return ErrMetadataBlockedAddress
+	}
+	return nil
+}
+
+// isPublicIP reports whether ip is a globally routable unicast address
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, network := range nonPublicNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Fetch downloads rawURL and extracts its metadata. URLs in the result are
+// absolute, resolved against the address the page was finally served from.
+func (f *MetadataFetcher) Fetch(ctx context.Context, rawURL string) (*models.LinkMetadata, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, ErrInvalidURL
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, ErrMetadataUnsupportedScheme
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsed.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("User-Agent", f.config.UserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.1")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("destination returned %s", resp.Status)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, ErrMetadataNotHTML
+	}
+
+	body, err := charset.NewReader(io.LimitReader(resp.Body, f.config.MaxBodyBytes), contentType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode page: %w", err)
+	}
+
+	return parseLinkMetadata(body, resp.Request.URL), nil
+}
+
+// parseLinkMetadata reads the head of an HTML page. OpenGraph values win over
+// Twitter card values, which win over the plain HTML title and description.
+func parseLinkMetadata(body io.Reader, base *url.URL) *models.LinkMetadata {
+	tokenizer := html.NewTokenizer(body)
+	meta := make(map[string]string)
+	var title strings.Builder
+	var inTitle, seenTitle bool
+	var icon string
+
+parse:
+	for {
+		tokenType := tokenizer.Next()
+		switch tokenType {
+		case html.ErrorToken:
+			// End of the page, or of the bytes we were willing to read
+			break parse
+		case html.TextToken:
+			if inTitle {
+				title.Write(tokenizer.Text())
+			}
+		case html.EndTagToken:
+			if name, _ := tokenizer.TagName(); string(name) == "title" {
+				inTitle = false
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttributes := tokenizer.TagName()
+			switch string(name) {
+			case "body":
+				break parse
+			case "title":
+				if tokenType == html.StartTagToken && !seenTitle {
+					inTitle, seenTitle = true, true
+				}
+			case "meta":
+				attributes := tagAttributes(tokenizer, hasAttributes)
+				key := attributes["property"]
+				if key == "" {
+					key = attributes["name"]
+				}
+				key = strings.ToLower(strings.TrimSpace(key))
+				if _, seen := meta[key]; key != "" && !seen {
+					meta[key] = attributes["content"]
+				}
+			case "link":
+				attributes := tagAttributes(tokenizer, hasAttributes)
+				for _, rel := range strings.Fields(strings.ToLower(attributes["rel"])) {
+					if rel == "icon" && icon == "" {
+						icon = attributes["href"]
+					}
+				}
+			}
+		}
+	}
+
+	metadata := &models.LinkMetadata{
+		Title:       firstText(maxMetadataTitleLength, meta["og:title"], meta["twitter:title"], title.String()),
+		Description: firstText(maxMetadataDescriptionLength, meta["og:description"], meta["twitter:description"], meta["description"]),
+		SiteName:    firstText(maxMetadataTitleLength, meta["og:site_name"]),
+		ImageURL: resolveMetadataURL(base, meta["og:image"], meta["og:image:url"], meta["og:image:secure_url"],
+			meta["twitter:image"], meta["twitter:image:src"]),
+		FaviconURL: resolveMetadataURL(base, icon, "/favicon.ico"),
+	}
+	return metadata
+}
+
+// tagAttributes returns the current tag's attributes keyed by lowercase name
+func tagAttributes(tokenizer *html.Tokenizer, hasAttributes bool) map[string]string {
+	attributes := make(map[string]string)
+	for hasAttributes {
+		var key, value []byte
+		key, value, hasAttributes = tokenizer.TagAttr()
+		attributes[strings.ToLower(string(key))] = string(value)
+	}
+	return attributes
+}
+
+// firstText returns the first non-blank value with its whitespace collapsed,
+// cut to maxLength characters
+func firstText(maxLength int, values ...string) string {
+	for _, value := range values {
+		value = strings.Join(strings.Fields(value), " ")
+		if value == "" {
+			continue
+		}
+		if utf8.RuneCountInString(value) > maxLength {
+			value = string([]rune(value)[:maxLength])
+		}
+		return value
+	}
+	return ""
+}
+
+// resolveMetadataURL returns the first value that resolves to an http or https
+// URL against base
+func resolveMetadataURL(base *url.URL, values ...string) string {
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		ref, err := url.Parse(value)
+		if err != nil {
+			continue
+		}
+		resolved := base.ResolveReference(ref)
+		if (resolved.Scheme == "http" || resolved.Scheme == "https") && len(resolved.String()) <= maxMetadataURLLength {
+			return resolved.String()
+		}
+	}
+	return ""
+}
+
+// Metadata fetch errors
+var (
+	ErrMetadataBlockedAddress    = &ServiceError{Message: "destination resolves to a non-public address"}
+	ErrMetadataTooManyRedirects  = &ServiceError{Message: "destination redirected too many times"}
+	ErrMetadataUnsupportedScheme = &ServiceError{Message: "destination must use http or https"}
+	ErrMetadataNotHTML           = &ServiceError{Message: "destination is not an HTML page"}
+)
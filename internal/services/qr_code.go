@@ -0,0 +1,418 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/URLshorter/url-shortener/configs"
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/URLshorter/url-shortener/internal/utils"
+)
+
+// Limits on QR code rendering
+const (
+	defaultQRSize   = 512
+	minQRSize       = 64
+	maxQRSize       = 2048
+	defaultQRMargin = 4
+	maxQRMargin     = 16
+
+	// A centre logo covers at most this share of the symbol's width, which
+	// level H error correction recovers comfortably
+	qrLogoWidthRatio = 0.2
+	// Logos larger than this are refused before being decoded
+	maxQRLogoBytes      = 10 << 20
+	maxQRLogoDimensions = 4096
+	// Foreground and background need WCAG-style contrast to scan reliably
+	minQRContrast = 3.0
+)
+
+// LogoLookup returns a media library file by ID if the user may use it
+type LogoLookup func(mediaID, userID int64) (*models.MediaFile, error)
+
+// DomainLookup reports whether a user owns a verified, active custom domain
+type DomainLookup func(userID int64, domain string) (bool, error)
+
+// QRCodeService renders QR codes for users' short links. The encoded URL
+// carries the scan tag so visits from the code are counted as scans.
+type QRCodeService struct {
+	shortener *ShortenerService
+	config    *configs.Config
+	logos     LogoLookup
+	domains   DomainLookup
+}
+
+// qrOptions are validated rendering options
+type qrOptions struct {
+	format     string
+	size       int
+	margin     int
+	level      utils.QRLevel
+	foreground color.NRGBA
+	background color.NRGBA
+	logoID     int64
+	domain     string
+}
+
+// NewQRCodeService creates a new QR code service. Logos and custom domains
+// are unavailable until their lookups are set.
+func NewQRCodeService(shortener *ShortenerService, config *configs.Config) *QRCodeService {
+	return &QRCodeService{
+		shortener: shortener,
+		config:    config,
+	}
+}
+
+// SetLogoLookup sets where centre logos are read from
+func (s *QRCodeService) SetLogoLookup(lookup LogoLookup) {
+	s.logos = lookup
+}
+
+// SetDomainLookup sets how custom domains are checked
+func (s *QRCodeService) SetDomainLookup(lookup DomainLookup) {
+	s.domains = lookup
+}
+
+// Render returns a QR code for one of a user's links and its content type
+func (s *QRCodeService) Render(userID int64, shortCode string, req *models.QRCodeRequest) ([]byte, string, error) {
+	opts, err := parseQROptions(req)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if _, err := s.shortener.GetURLByShortCode(userID, shortCode); err != nil {
+		return nil, "", err
+	}
+
+	baseURL := strings.TrimSuffix(s.config.BaseURL, "/")
+	if opts.domain != "" {
+		if s.domains == nil {
+			return nil, "", ErrQRDomainNotVerified
+		}
+		verified, err := s.domains(userID, opts.domain)
+		if err != nil {
+			return nil, "", err
+		}
+		if !verified {
+			return nil, "", ErrQRDomainNotVerified
+		}
+		baseURL = "https://" + opts.domain
+	}
+	target := fmt.Sprintf("%s/%s?%s=%s", baseURL, shortCode, models.ClickSourceParam, models.ClickSourceQR)
+
+	code, err := utils.EncodeQR(target, opts.level)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var logo []byte
+	if opts.logoID != 0 {
+		if logo, err = s.loadLogo(userID, opts.logoID); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if opts.format == models.QRFormatSVG {
+		return renderQRSVG(code, opts, logo), "image/svg+xml", nil
+	}
+	data, err := renderQRPNG(code, opts, logo)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, "image/png", nil
+}
+
+// loadLogo reads a logo image from the media library
+func (s *QRCodeService) loadLogo(userID, mediaID int64) ([]byte, error) {
+	if s.logos == nil {
+		return nil, ErrQRLogoUnavailable
+	}
+	media, err := s.logos(mediaID, userID)
+	if err != nil || media.FileType != "image" {
+		return nil, ErrQRLogoNotFound
+	}
+
+	file, err := os.Open(media.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open logo: %w", err)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxQRLogoBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read logo: %w", err)
+	}
+	if len(data) > maxQRLogoBytes {
+		return nil, ErrQRInvalidLogo
+	}
+
+	// Check the dimensions before decoding anything large
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || config.Width > maxQRLogoDimensions || config.Height > maxQRLogoDimensions {
+		return nil, ErrQRInvalidLogo
+	}
+	return data, nil
+}
+
+// parseQROptions validates a QR code request and fills in defaults
+func parseQROptions(req *models.QRCodeRequest) (*qrOptions, error) {
+	opts := &qrOptions{
+		format: strings.ToLower(req.Format),
+		size:   req.Size,
+		margin: defaultQRMargin,
+		logoID: req.LogoID,
+	}
+
+	switch opts.format {
+	case "":
+		opts.format = models.QRFormatPNG
+	case models.QRFormatPNG, models.QRFormatSVG:
+	default:
+		return nil, ErrInvalidQRFormat
+	}
+
+	if opts.size == 0 {
+		opts.size = defaultQRSize
+	}
+	if opts.size < minQRSize || opts.size > maxQRSize {
+		return nil, ErrInvalidQRSize
+	}
+
+	if req.Margin != nil {
+		if *req.Margin < 0 || *req.Margin > maxQRMargin {
+			return nil, ErrInvalidQRMargin
+		}
+		opts.margin = *req.Margin
+	}
+
+	// A logo hides part of the symbol, so it needs the higher levels
+	switch strings.ToUpper(req.Level) {
+	case "":
+		opts.level = utils.QRLevelMedium
+		if opts.logoID != 0 {
+			opts.level = utils.QRLevelHigh
+		}
+	case "L":
+		opts.level = utils.QRLevelLow
+	case "M":
+		opts.level = utils.QRLevelMedium
+	case "Q":
+		opts.level = utils.QRLevelQuartile
+	case "H":
+		opts.level = utils.QRLevelHigh
+	default:
+		return nil, ErrInvalidQRLevel
+	}
+	if opts.logoID != 0 && opts.level < utils.QRLevelQuartile {
+		return nil, ErrQRLogoNeedsHighLevel
+	}
+	if opts.logoID < 0 {
+		return nil, ErrQRLogoNotFound
+	}
+
+	var err error
+	if opts.foreground, err = parseHexColor(req.Foreground, color.NRGBA{A: 0xff}); err != nil {
+		return nil, err
+	}
+	if opts.background, err = parseHexColor(req.Background, color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}); err != nil {
+		return nil, err
+	}
+	if contrastRatio(opts.foreground, opts.background) < minQRContrast {
+		return nil, ErrQRLowContrast
+	}
+
+	if domain := strings.ToLower(strings.TrimSpace(req.Domain)); domain != "" {
+		if !searchDomainPattern.MatchString(domain) {
+			return nil, ErrQRDomainNotVerified
+		}
+		opts.domain = domain
+	}
+
+	return opts, nil
+}
+
+// parseHexColor parses an opaque #rgb or #rrggbb colour, the # being optional
+func parseHexColor(value string, fallback color.NRGBA) (color.NRGBA, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "#")
+	if value == "" {
+		return fallback, nil
+	}
+	if len(value) == 3 {
+		value = string([]byte{value[0], value[0], value[1], value[1], value[2], value[2]})
+	}
+	if len(value) != 6 {
+		return color.NRGBA{}, ErrInvalidQRColor
+	}
+	rgb, err := strconv.ParseUint(value, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, ErrInvalidQRColor
+	}
+	return color.NRGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb), A: 0xff}, nil
+}
+
+// contrastRatio returns how much lighter the background is than the
+// foreground, as a WCAG contrast ratio. Inverted codes score below 1.
+func contrastRatio(foreground, background color.NRGBA) float64 {
+	luminance := func(c color.NRGBA) float64 {
+		channel := func(v uint8) float64 {
+			s := float64(v) / 255
+			if s <= 0.03928 {
+				return s / 12.92
+			}
+			return math.Pow((s+0.055)/1.055, 2.4)
+		}
+		return 0.2126*channel(c.R) + 0.7152*channel(c.G) + 0.0722*channel(c.B)
+	}
+	return (luminance(background) + 0.05) / (luminance(foreground) + 0.05)
+}
+
+// qrLogoModules returns the side of the square left for a logo, in modules
+func qrLogoModules(code *utils.QRCode) int {
+	return int(float64(code.Size) * qrLogoWidthRatio)
+}
+
+// renderQRPNG draws the symbol centred in a square image of the requested size
+func renderQRPNG(code *utils.QRCode, opts *qrOptions, logo []byte) ([]byte, error) {
+	modules := code.Size + 2*opts.margin
+	scale := opts.size / modules
+	if scale < 1 {
+		return nil, ErrQRSizeTooSmall
+	}
+	offset := (opts.size-scale*modules)/2 + opts.margin*scale
+
+	img := image.NewNRGBA(image.Rect(0, 0, opts.size, opts.size))
+	draw.Draw(img, img.Bounds(), image.NewUniform(opts.background), image.Point{}, draw.Src)
+	foreground := image.NewUniform(opts.foreground)
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if code.Dark(x, y) {
+				module := image.Rect(offset+x*scale, offset+y*scale, offset+(x+1)*scale, offset+(y+1)*scale)
+				draw.Draw(img, module, foreground, image.Point{}, draw.Src)
+			}
+		}
+	}
+
+	if logo != nil {
+		src, _, err := image.Decode(bytes.NewReader(logo))
+		if err != nil {
+			return nil, ErrQRInvalidLogo
+		}
+
+		// Clear whole modules behind the logo and keep a module of padding around it
+		logoModules := qrLogoModules(code)
+		side := logoModules * scale
+		origin := offset + (code.Size-logoModules)/2*scale
+		area := image.Rect(origin, origin, origin+side, origin+side)
+		draw.Draw(img, area, image.NewUniform(opts.background), image.Point{}, draw.Src)
+
+		scaled := fitImage(src, side-2*scale)
+		at := image.Pt(origin+(side-scaled.Bounds().Dx())/2, origin+(side-scaled.Bounds().Dy())/2)
+		draw.Draw(img, scaled.Bounds().Add(at), scaled, image.Point{}, draw.Over)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode QR code: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// fitImage scales an image to fit a square of the given side, keeping its
+// aspect ratio, by sampling the nearest source pixel
+func fitImage(src image.Image, side int) *image.NRGBA {
+	bounds := src.Bounds()
+	if side < 1 || bounds.Empty() {
+		return image.NewNRGBA(image.Rect(0, 0, 0, 0))
+	}
+	width, height := side, side
+	if bounds.Dx() > bounds.Dy() {
+		height = int(math.Max(1, math.Round(float64(side*bounds.Dy())/float64(bounds.Dx()))))
+	} else {
+		width = int(math.Max(1, math.Round(float64(side*bounds.Dx())/float64(bounds.Dy()))))
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		sy := bounds.Min.Y + y*bounds.Dy()/height
+		for x := 0; x < width; x++ {
+			sx := bounds.Min.X + x*bounds.Dx()/width
+			dst.Set(x, y, src.At(sx, sy))
+		}
+	}
+	return dst
+}
+
+// renderQRSVG draws the symbol as a single path, one unit per module, with
+// the logo embedded as a data URI
+func renderQRSVG(code *utils.QRCode, opts *qrOptions, logo []byte) []byte {
+	modules := code.Size + 2*opts.margin
+	logoSide := 0
+	if logo != nil {
+		logoSide = qrLogoModules(code)
+	}
+	logoStart := opts.margin + (code.Size-logoSide)/2
+	underLogo := func(x, y int) bool {
+		return logoSide > 0 && x >= logoStart && x < logoStart+logoSide && y >= logoStart && y < logoStart+logoSide
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		opts.size, opts.size, modules, modules)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="%s"/>`, modules, modules, hexColor(opts.background))
+	fmt.Fprintf(&buf, `<path fill="%s" d="`, hexColor(opts.foreground))
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; {
+			// Draw each horizontal run of dark modules as one rectangle
+			run := 0
+			for x+run < code.Size && code.Dark(x+run, y) && !underLogo(opts.margin+x+run, opts.margin+y) {
+				run++
+			}
+			if run == 0 {
+				x++
+				continue
+			}
+			fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", opts.margin+x, opts.margin+y, run, run)
+			x += run
+		}
+	}
+	buf.WriteString(`"/>`)
+
+	if logo != nil {
+		_, format, _ := image.DecodeConfig(bytes.NewReader(logo))
+		fmt.Fprintf(&buf, `<image x="%d" y="%d" width="%d" height="%d" preserveAspectRatio="xMidYMid meet" href="data:image/%s;base64,%s"/>`,
+			logoStart+1, logoStart+1, logoSide-2, logoSide-2, format, base64.StdEncoding.EncodeToString(logo))
+	}
+	buf.WriteString("</svg>\n")
+	return buf.Bytes()
+}
+
+// hexColor formats a colour as #rrggbb
+func hexColor(c color.NRGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// QR code errors
+var (
+	ErrInvalidQRFormat      = &ServiceError{Message: "format must be png or svg"}
+	ErrInvalidQRSize        = &ServiceError{Message: fmt.Sprintf("size must be between %d and %d pixels", minQRSize, maxQRSize)}
+	ErrInvalidQRMargin      = &ServiceError{Message: fmt.Sprintf("margin must be between 0 and %d modules", maxQRMargin)}
+	ErrInvalidQRLevel       = &ServiceError{Message: "ecc must be one of L, M, Q or H"}
+	ErrInvalidQRColor       = &ServiceError{Message: "colours must be hex values such as #000000"}
+	ErrQRLowContrast        = &ServiceError{Message: "the foreground must be darker than the background with enough contrast to scan"}
+	ErrQRSizeTooSmall       = &ServiceError{Message: "size is too small to draw this link's QR code"}
+	ErrQRLogoNeedsHighLevel = &ServiceError{Message: "a logo needs error correction level Q or H"}
+	ErrQRLogoNotFound       = &ServiceError{Message: "logo not found in the media library"}
+	ErrQRLogoUnavailable    = &ServiceError{Message: "logos need the media library, which requires PostgreSQL"}
+	ErrQRInvalidLogo        = &ServiceError{Message: fmt.Sprintf("logo must be a PNG, JPEG or GIF image of at most %dx%d pixels", maxQRLogoDimensions, maxQRLogoDimensions)}
+	ErrQRDomainNotVerified  = &ServiceError{Message: "domain must be one of your verified custom domains"}
+)
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"database/sql"
 	"fmt"
 	"time"
 
@@ -65,8 +66,11 @@ func (r *RBACService) CreateRole(req *models.CreateRoleRequest, createdBy int64)
 
 // GetRoleByID retrieves a role by ID
 func (r *RBACService) GetRoleByID(roleID int64) (*models.Role, error) {
-	// Implementation would query database
-	// For now, return simulated data based on constants
+	if r.db != nil {
+		return r.queryRole(`WHERE id = $1`, roleID)
+	}
+
+	// Without Postgres, fall back to the built-in system roles
 	switch roleID {
 	case 1:
 		return &models.Role{
@@ -108,7 +112,10 @@ func (r *RBACService) GetRoleByID(roleID int64) (*models.Role, error) {
 
 // GetRoleByName retrieves a role by name
 func (r *RBACService) GetRoleByName(name string) (*models.Role, error) {
-	// Implementation would query database by name
+	if r.db != nil {
+		return r.queryRole(`WHERE name = $1`, name)
+	}
+
 	switch name {
 	case models.RoleSuperAdmin:
 		return r.GetRoleByID(1)
@@ -121,6 +128,26 @@ func (r *RBACService) GetRoleByName(name string) (*models.Role, error) {
 	}
 }
 
+// queryRole loads the role matching a WHERE clause from the roles table
+func (r *RBACService) queryRole(where string, args ...interface{}) (*models.Role, error) {
+	role := &models.Role{}
+	var description sql.NullString
+	err := r.db.QueryRow(`
+		SELECT id, name, display_name, description, is_system, is_active, created_at, updated_at
+		FROM roles `+where, args...).Scan(
+		&role.ID, &role.Name, &role.DisplayName, &description,
+		&role.IsSystem, &role.IsActive, &role.CreatedAt, &role.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("role not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role: %w", err)
+	}
+	role.Description = description.String
+	return role, nil
+}
+
 // GetRoleWithPermissions retrieves a role with its permissions
 func (r *RBACService) GetRoleWithPermissions(roleID int64) (*models.RoleWithPermissions, error) {
 	role, err := r.GetRoleByID(roleID)
@@ -314,17 +341,25 @@ func (r *RBACService) AssignRoleToUser(req *models.AssignRoleRequest, assignedBy
 		return fmt.Errorf("user already has role '%s'", role.Name)
 	}
 
-	userRole := &models.UserRole{
-		UserID:     req.UserID,
-		RoleID:     req.RoleID,
-		AssignedBy: &assignedBy,
-		ExpiresAt:  req.ExpiresAt,
-		CreatedAt:  time.Now(),
-		UpdatedAt:  time.Now(),
+	if r.db == nil {
+		return fmt.Errorf("role assignments require the Postgres storage backend")
 	}
 
-	// Implementation would save to database
-	userRole.ID = time.Now().Unix() // Temporary ID
+	// Assignments by the system itself (assignedBy 0) have no assigning user
+	var assigner sql.NullInt64
+	if assignedBy != 0 {
+		assigner = sql.NullInt64{Int64: assignedBy, Valid: true}
+	}
+
+	_, err = r.db.Exec(`
+		INSERT INTO user_roles (user_id, role_id, assigned_by, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, role_id) DO UPDATE SET
+			assigned_by = EXCLUDED.assigned_by, assigned_at = NOW(), expires_at = EXCLUDED.expires_at`,
+		req.UserID, req.RoleID, assigner, req.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to assign role: %w", err)
+	}
 
 	return nil
 }
@@ -340,16 +375,55 @@ func (r *RBACService) RemoveRoleFromUser(userID, roleID int64, removedBy int64)
 		return fmt.Errorf("user does not have this role")
 	}
 
-	// Implementation would remove from database
+	if r.db == nil {
+		return fmt.Errorf("role assignments require the Postgres storage backend")
+	}
+
+	if _, err := r.db.Exec(`DELETE FROM user_roles WHERE user_id = $1 AND role_id = $2`, userID, roleID); err != nil {
+		return fmt.Errorf("failed to remove role: %w", err)
+	}
 	return nil
 }
 
-// GetUserRoles retrieves all roles for a user
+// GetUserRoles retrieves all roles for a user. Users without any unexpired
+// assignment get the default user role.
 func (r *RBACService) GetUserRoles(userID int64) ([]*models.Role, error) {
-	// Implementation would query database
-	// For now, return default user role
-	userRole, _ := r.GetRoleByName(models.RoleUser)
-	return []*models.Role{userRole}, nil
+	var roles []*models.Role
+	if r.db != nil {
+		rows, err := r.db.Query(`
+			SELECT r.id, r.name, r.display_name, r.description, r.is_system, r.is_active, r.created_at, r.updated_at
+			FROM user_roles ur
+			JOIN roles r ON r.id = ur.role_id
+			WHERE ur.user_id = $1 AND r.is_active = TRUE AND (ur.expires_at IS NULL OR ur.expires_at > NOW())
+			ORDER BY r.id`, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user roles: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			role := &models.Role{}
+			var description sql.NullString
+			if err := rows.Scan(&role.ID, &role.Name, &role.DisplayName, &description,
+				&role.IsSystem, &role.IsActive, &role.CreatedAt, &role.UpdatedAt); err != nil {
+				return nil, fmt.Errorf("failed to scan user role: %w", err)
+			}
+			role.Description = description.String
+			roles = append(roles, role)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(roles) == 0 {
+		userRole, err := r.GetRoleByName(models.RoleUser)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, userRole)
+	}
+	return roles, nil
 }
 
 // GetUserPermissions retrieves all permissions for a user (through roles)
@@ -479,13 +553,19 @@ func (r *RBACService) AssignPermissionsToRole(roleID int64, permissionIDs []int6
 // GetRolePermissions retrieves all permissions for a role
 func (r *RBACService) GetRolePermissions(roleID int64) ([]*models.Permission, error) {
 	// Implementation would query role_permissions join
-	// For now, return basic permissions based on role
-	switch roleID {
-	case 1: // Super Admin
+	// For now, return basic permissions based on the role's name, since
+	// role IDs differ between the database and the built-in roles
+	role, err := r.GetRoleByID(roleID)
+	if err != nil {
+		return []*models.Permission{}, nil
+	}
+
+	switch role.Name {
+	case models.RoleSuperAdmin:
 		return r.getSystemPermissions(), nil
-	case 2: // Admin
+	case models.RoleAdmin:
 		return r.getAdminPermissions(), nil
-	case 3: // User
+	case models.RoleUser:
 		return r.getUserPermissions(), nil
 	default:
 		return []*models.Permission{}, nil
@@ -158,7 +158,7 @@ func (r *RealtimeAnalyticsService) unsubscribe(conn *websocket.Conn, shortCode s
 
 // sendInitialAnalytics sends the current analytics data when a client subscribes
 func (r *RealtimeAnalyticsService) sendInitialAnalytics(conn *websocket.Conn, shortCode string) {
-	analytics, err := r.analyticsService.GetAnalytics(shortCode, 30) // Last 30 days
+	analytics, err := r.analyticsService.GetAnalytics(shortCode, 30, false) // Last 30 days
 	if err != nil {
 		return
 	}
@@ -346,7 +346,7 @@ func (r *RealtimeAnalyticsService) sendPeriodicUpdates() {
 	
 	for _, shortCode := range shortCodes {
 		go func(sc string) {
-			analytics, err := r.analyticsService.GetAnalytics(sc, 1) // Last 24 hours for real-time
+			analytics, err := r.analyticsService.GetAnalytics(sc, 1, false) // Last 24 hours for real-time
 			if err != nil {
 				return
 			}
@@ -0,0 +1,284 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/URLshorter/url-shortener/internal/models"
+)
+
+// MaxRedirectRules is the maximum number of conditional rules per short link
+const MaxRedirectRules = 20
+
+// GeoIPLookup is implemented by the GeoIP services (GeoIPService, FreeGeoIPService)
+type GeoIPLookup interface {
+	LookupIP(ipAddress string) (*GeoIPResult, error)
+}
+
+// RedirectContext describes the click being routed by conditional redirect rules
+type RedirectContext struct {
+	ClientIP       string
+	UserAgent      string
+	AcceptLanguage string
+	Time           time.Time
+}
+
+// RedirectRuleEngine evaluates conditional redirect rules against a click
+type RedirectRuleEngine struct {
+	geoIP     GeoIPLookup
+	userAgent *UserAgentService
+	locations sync.Map // timezone name -> *time.Location
+}
+
+// NewRedirectRuleEngine creates a new redirect rule engine
+func NewRedirectRuleEngine(geoIP GeoIPLookup, userAgent *UserAgentService) *RedirectRuleEngine {
+	return &RedirectRuleEngine{
+		geoIP:     geoIP,
+		userAgent: userAgent,
+	}
+}
+
+// Resolve returns the destination of the first rule matching the click, or
+// the fallback URL when no rule matches
+func (e *RedirectRuleEngine) Resolve(rules models.RedirectRules, fallback string, ctx *RedirectContext) string {
+	if len(rules) == 0 || ctx == nil {
+		return fallback
+	}
+
+	eval := &ruleEvaluation{engine: e, ctx: ctx}
+	for i := range rules {
+		if eval.matches(&rules[i]) {
+			return rules[i].Destination
+		}
+	}
+
+	return fallback
+}
+
+// ruleEvaluation lazily derives click attributes so GeoIP and user agent
+// parsing only happen when a rule actually needs them
+type ruleEvaluation struct {
+	engine *RedirectRuleEngine
+	ctx    *RedirectContext
+
+	country         *string
+	device          *DeviceInfo
+	languages       []string
+	languagesParsed bool
+}
+
+func (r *ruleEvaluation) matches(rule *models.RedirectRule) bool {
+	if len(rule.Countries) > 0 && !containsFold(rule.Countries, r.countryCode()) {
+		return false
+	}
+	if len(rule.DeviceTypes) > 0 && !containsFold(rule.DeviceTypes, r.deviceInfo().DeviceType) {
+		return false
+	}
+	if len(rule.OSNames) > 0 && !containsFold(rule.OSNames, r.deviceInfo().OSName) {
+		return false
+	}
+	if len(rule.Languages) > 0 && !r.matchesLanguage(rule.Languages) {
+		return false
+	}
+	if len(rule.Weekdays) > 0 || rule.StartTime != "" || rule.EndTime != "" {
+		if !r.matchesSchedule(rule) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *ruleEvaluation) countryCode() string {
+	if r.country == nil {
+		code := ""
+		if r.engine.geoIP != nil {
+			if result, err := r.engine.geoIP.LookupIP(r.ctx.ClientIP); err == nil && result != nil {
+				code = result.CountryCode
+			}
+		}
+		r.country = &code
+	}
+	return *r.country
+}
+
+func (r *ruleEvaluation) deviceInfo() *DeviceInfo {
+	if r.device == nil {
+		r.device = r.engine.userAgent.ParseUserAgent(r.ctx.UserAgent)
+	}
+	return r.device
+}
+
+func (r *ruleEvaluation) matchesLanguage(ruleLanguages []string) bool {
+	if !r.languagesParsed {
+		r.languages = ParseAcceptLanguage(r.ctx.AcceptLanguage)
+		r.languagesParsed = true
+	}
+
+	for _, visitorLang := range r.languages {
+		for _, ruleLang := range ruleLanguages {
+			ruleLang = strings.ToLower(ruleLang)
+			if visitorLang == ruleLang || strings.HasPrefix(visitorLang, ruleLang+"-") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r *ruleEvaluation) matchesSchedule(rule *models.RedirectRule) bool {
+	loc := r.engine.location(rule.Timezone)
+	now := r.ctx.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	now = now.In(loc)
+
+	if len(rule.Weekdays) > 0 {
+		found := false
+		for _, day := range rule.Weekdays {
+			if day == int(now.Weekday()) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if rule.StartTime == "" && rule.EndTime == "" {
+		return true
+	}
+
+	start, _ := parseClock(rule.StartTime)
+	end, err := parseClock(rule.EndTime)
+	if rule.EndTime == "" || err != nil {
+		end = 24 * 60
+	}
+	minute := now.Hour()*60 + now.Minute()
+
+	if start <= end {
+		return minute >= start && minute < end
+	}
+	// Window wraps past midnight, e.g. 22:00-06:00
+	return minute >= start || minute < end
+}
+
+// location returns the cached time.Location for a rule timezone, defaulting to UTC
+func (e *RedirectRuleEngine) location(name string) *time.Location {
+	if name == "" {
+		return time.UTC
+	}
+	if loc, ok := e.locations.Load(name); ok {
+		return loc.(*time.Location)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	e.locations.Store(name, loc)
+	return loc
+}
+
+// ValidateRedirectRules checks rule conditions; destinations are validated by the caller
+func ValidateRedirectRules(rules models.RedirectRules) error {
+	if len(rules) > MaxRedirectRules {
+		return ErrTooManyRedirectRules
+	}
+
+	for _, rule := range rules {
+		if rule.Destination == "" {
+			return ErrInvalidRedirectRule
+		}
+		for _, day := range rule.Weekdays {
+			if day < 0 || day > 6 {
+				return ErrInvalidRedirectRule
+			}
+		}
+		if rule.StartTime != "" {
+			if _, err := parseClock(rule.StartTime); err != nil {
+				return ErrInvalidRedirectRule
+			}
+		}
+		if rule.EndTime != "" {
+			if _, err := parseClock(rule.EndTime); err != nil {
+				return ErrInvalidRedirectRule
+			}
+		}
+		if rule.Timezone != "" {
+			if _, err := time.LoadLocation(rule.Timezone); err != nil {
+				return ErrInvalidRedirectRule
+			}
+		}
+	}
+
+	return nil
+}
+
+// ParseAcceptLanguage returns the lower-cased language tags of an
+// Accept-Language header ordered by preference, skipping q=0 entries
+func ParseAcceptLanguage(header string) []string {
+	type weightedTag struct {
+		tag    string
+		weight float64
+	}
+
+	var tags []weightedTag
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		weight := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					weight = q
+				}
+			}
+		}
+		if weight <= 0 {
+			continue
+		}
+		tags = append(tags, weightedTag{tag: tag, weight: weight})
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].weight > tags[j].weight
+	})
+
+	languages := make([]string, len(tags))
+	for i, t := range tags {
+		languages[i] = t.tag
+	}
+	return languages
+}
+
+// parseClock parses an HH:MM string into minutes since midnight
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q: %w", value, err)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// containsFold reports whether values contains target, ignoring case
+func containsFold(values []string, target string) bool {
+	if target == "" {
+		return false
+	}
+	for _, v := range values {
+		if strings.EqualFold(v, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,8 +1,8 @@
 package services
 
 import (
+	"context"
 	"crypto/sha256"
-	"database/sql"
 	"encoding/hex"
 	"fmt"
 	"net"
@@ -17,28 +17,78 @@ import (
 )
 
 type ShortenerService struct {
-	db          *storage.PostgresStorage
-	redis       *storage.RedisStorage
+	db          storage.LinkStore
+	cache       storage.Cache
 	config      *configs.Config
 	analytics   *AnalyticsService
 	realtime    *RealtimeAnalyticsService
 	attribution *AttributionService
+	redirectRules *RedirectRuleEngine
+	userAgents    *UserAgentService
+	linkPasswords *LinkPasswordService
+	clicks        *ClickIngestionPipeline
+	clickStream   *ClickStreamConsumer
+	clickEffects  *ClickEffectQueue
+	uniqueVisitors *UniqueVisitorService
+	metadata       *LinkMetadataService
+	safety         *LinkSafetyService
 }
 
 // NewShortenerService creates a new shortener service
-func NewShortenerService(db *storage.PostgresStorage, redis *storage.RedisStorage, config *configs.Config) *ShortenerService {
+func NewShortenerService(db storage.LinkStore, cache storage.Cache, config *configs.Config) *ShortenerService {
 	// Initialize Snowflake ID generator
 	utils.InitializeSnowflake(config.NodeID)
 	
 	service := &ShortenerService{
 		db:     db,
-		redis:  redis,
+		cache:  cache,
 		config: config,
 	}
 	
 	// Initialize analytics service
 	service.analytics = NewAnalyticsService(db)
-	service.analytics.SetRedis(redis)
+	service.analytics.SetCache(cache)
+
+	// Initialize conditional redirect rule engine. Country rules are matched
+	// against a local GeoIP database so redirects never wait on a remote
+	// lookup; until one is loaded with SetGeoIPService no country matches
+	service.userAgents = NewUserAgentService()
+	service.redirectRules = NewRedirectRuleEngine(NewGeoIPService(), service.userAgents)
+
+	// Initialize password protection for links
+	service.linkPasswords = NewLinkPasswordService(cache, config.LinkPasswordSecret)
+
+	// Run per-click side effects apart from persistence so they never stall
+	// the batch workers
+	service.clickEffects = NewClickEffectQueue(service.processClick, ClickEffectConfig{
+		QueueSize: config.ClickQueueSize,
+		Workers:   config.ClickWorkers,
+	})
+
+	// Start the click ingestion pipeline
+	service.clicks = NewClickIngestionPipeline(db, service.clickEffects.Enqueue, ClickIngestionConfig{
+		QueueSize:     config.ClickQueueSize,
+		Workers:       config.ClickWorkers,
+		BatchSize:     config.ClickBatchSize,
+		FlushInterval: time.Duration(config.ClickFlushIntervalMs) * time.Millisecond,
+	})
+
+	// Buffer clicks durably in a Redis Stream; the in-process pipeline above
+	// is only used when the stream can't be written to. Streams need Redis.
+	if redis, ok := cache.(*storage.RedisStorage); ok && config.ClickStreamEnabled {
+		service.clickStream = NewClickStreamConsumer(redis, db, service.clickEffects.Enqueue, DefaultClickStreamConfig())
+		service.clickStream.Start()
+	}
+
+	// Fetch link previews from destinations in the background
+	if config.MetadataFetchEnabled {
+		fetcher := NewMetadataFetcher(MetadataFetcherConfig{
+			Timeout:      time.Duration(config.MetadataFetchTimeoutMs) * time.Millisecond,
+			MaxBodyBytes: config.MetadataMaxBodyBytes,
+			MaxRedirects: config.MetadataMaxRedirects,
+		})
+		service.metadata = NewLinkMetadataService(db, fetcher, LinkMetadataConfig{Workers: config.MetadataWorkers})
+	}
 	
 	return service
 }
@@ -48,11 +98,63 @@ func (s *ShortenerService) SetRealtimeService(realtime *RealtimeAnalyticsService
 	s.realtime = realtime
 }
 
+// SetUniqueVisitorService sets the service counting unique visitors per link
+func (s *ShortenerService) SetUniqueVisitorService(uniqueVisitors *UniqueVisitorService) {
+	s.uniqueVisitors = uniqueVisitors
+}
+
+// SetLinkSafetyService sets the service screening link destinations
+func (s *ShortenerService) SetLinkSafetyService(safety *LinkSafetyService) {
+	s.safety = safety
+}
+
 // SetAttributionService sets the attribution service
 func (s *ShortenerService) SetAttributionService(attribution *AttributionService) {
 	s.attribution = attribution
 }
 
+// ClickIngestionStats returns queue depth and drop counters of the click pipeline
+func (s *ShortenerService) ClickIngestionStats() ClickIngestionStats {
+	return s.clicks.Stats()
+}
+
+// ClickEffectStats returns queue depth and drop counters of the click side effects
+func (s *ShortenerService) ClickEffectStats() ClickEffectStats {
+	return s.clickEffects.Stats()
+}
+
+// ClickStreamStats returns the Redis Stream click consumer's counters
+func (s *ShortenerService) ClickStreamStats() ClickStreamStats {
+	if s.clickStream == nil {
+		return ClickStreamStats{}
+	}
+	return s.clickStream.Stats()
+}
+
+// Shutdown stops the click stream consumer and drains queued click events,
+// their side effects and metadata fetches, waiting until the context expires
+func (s *ShortenerService) Shutdown(ctx context.Context) error {
+	if s.metadata != nil {
+		if err := s.metadata.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
+	if s.clickStream != nil {
+		if err := s.clickStream.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
+	if err := s.clicks.Shutdown(ctx); err != nil {
+		return err
+	}
+	return s.clickEffects.Shutdown(ctx)
+}
+
+// SetGeoIPService sets the GeoIP service used to evaluate country redirect rules
+func (s *ShortenerService) SetGeoIPService(geoIP GeoIPLookup) {
+	s.redirectRules.geoIP = geoIP
+}
+
 // ShortenURL creates a new short URL
 func (s *ShortenerService) ShortenURL(request *models.ShortenRequest, clientIP string, userID *int64) (*models.ShortenResponse, error) {
 	// Validate URL
@@ -60,6 +162,50 @@ func (s *ShortenerService) ShortenURL(request *models.ShortenRequest, clientIP s
 		return nil, err
 	}
 
+	// Validate redirect type
+	redirectType := request.RedirectType
+	if redirectType == "" {
+		redirectType = models.DefaultRedirectType
+	}
+	if !models.IsValidRedirectType(redirectType) {
+		return nil, ErrInvalidRedirectType
+	}
+
+	// Validate conditional redirect rules
+	if err := s.validateRedirectRules(request.RedirectRules); err != nil {
+		return nil, err
+	}
+
+	// Validate activation schedule and click limit
+	if err := s.validateLinkLimits(request.StartsAt, request.ExpiresAt, request.MaxClicks, request.FallbackURL); err != nil {
+		return nil, err
+	}
+
+	// Validate the custom social preview
+	socialPreview := request.SocialPreview
+	if err := NormalizeSocialPreview(socialPreview); err != nil {
+		return nil, err
+	}
+	if socialPreview.IsEmpty() {
+		socialPreview = nil
+	}
+
+	// Validate tags
+	tags, err := NormalizeTags(request.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	// Hash the optional link password
+	var passwordHash string
+	if request.Password != "" {
+		hash, err := s.linkPasswords.HashPassword(request.Password)
+		if err != nil {
+			return nil, err
+		}
+		passwordHash = hash
+	}
+
 	// Generate unique ID
 	id, err := utils.GenerateID()
 	if err != nil {
@@ -115,12 +261,33 @@ func (s *ShortenerService) ShortenURL(request *models.ShortenRequest, clientIP s
 		IsActive:    true,
 		CreatedByIP: clientIP,
 		UserID:      userID,
+		RedirectType: redirectType,
+		DestinationVersion: 1,
+		RedirectRules: request.RedirectRules,
+		PasswordHash: passwordHash,
+		StartsAt:    request.StartsAt,
+		MaxClicks:   request.MaxClicks,
+		FallbackURL: request.FallbackURL,
+		SocialPreview: socialPreview,
+	}
+
+	// Refuse blocked and known malicious destinations
+	if err := s.screenDestinations(mapping.Destinations()...); err != nil {
+		return nil, err
 	}
 
 	// Save to database
 	if err := s.db.SaveURLMapping(mapping); err != nil {
 		return nil, fmt.Errorf("failed to save URL mapping: %w", err)
 	}
+	if len(tags) > 0 {
+		if err := s.db.SetURLTags(shortCode, tags); err != nil {
+			return nil, fmt.Errorf("failed to save tags: %w", err)
+		}
+	}
+	if s.metadata != nil {
+		s.metadata.Enqueue(shortCode, mapping.OriginalURL, mapping.DestinationVersion)
+	}
 
 	// Cache in Redis with appropriate TTL
 	cacheTTL := 24 * time.Hour // Default cache TTL
@@ -131,7 +298,7 @@ func (s *ShortenerService) ShortenURL(request *models.ShortenRequest, clientIP s
 		}
 	}
 	
-	s.redis.SetURLMapping(shortCode, mapping, cacheTTL)
+	s.cache.SetURLMapping(shortCode, mapping, cacheTTL)
 
 	// Create response
 	response := &models.ShortenResponse{
@@ -140,6 +307,13 @@ func (s *ShortenerService) ShortenURL(request *models.ShortenRequest, clientIP s
 		OriginalURL: request.URL,
 		CreatedAt:   mapping.CreatedAt,
 		ExpiresAt:   mapping.ExpiresAt,
+		RedirectType: mapping.RedirectType,
+		PasswordProtected: mapping.IsPasswordProtected(),
+		StartsAt:    mapping.StartsAt,
+		MaxClicks:   mapping.MaxClicks,
+		FallbackURL: mapping.FallbackURL,
+		Tags:        tags,
+		SocialPreview: mapping.SocialPreview,
 	}
 
 	return response, nil
@@ -148,7 +322,11 @@ func (s *ShortenerService) ShortenURL(request *models.ShortenRequest, clientIP s
 // GetOriginalURL retrieves the original URL for a short code
 func (s *ShortenerService) GetOriginalURL(shortCode string) (*models.URLMapping, error) {
 	// Try cache first
-	mapping, err := s.redis.GetURLMapping(shortCode)
+	mapping, err := s.cache.GetURLMapping(shortCode)
+	if err == storage.ErrURLNotYetActive || err == storage.ErrURLExpired {
+		// The cached link is known, just outside its schedule
+		return nil, err
+	}
 	if err != nil && err != storage.ErrCacheKeyNotFound {
 		// Log cache error but continue with database lookup
 		fmt.Printf("Cache lookup failed for %s: %v\n", shortCode, err)
@@ -169,73 +347,123 @@ func (s *ShortenerService) GetOriginalURL(shortCode string) (*models.URLMapping,
 				cacheTTL = timeUntilExpiry
 			}
 		}
-		s.redis.SetURLMapping(shortCode, mapping, cacheTTL)
+		s.cache.SetURLMapping(shortCode, mapping, cacheTTL)
 	}
 
 	return mapping, nil
 }
 
-// GetURLByShortCode retrieves a URL by its short code (for dashboard)
-func (s *ShortenerService) GetURLByShortCode(shortCode string) (*models.URL, error) {
-	// Implementation would query database
-	// For now, return simulated URL
-	now := time.Now()
-	userID := int64(1) // Simulated user ID
-	
-	return &models.URL{
-		ID:          1,
-		ShortCode:   shortCode,
-		OriginalURL: "https://example.com/original-url",
-		Title:       utils.StringPtr("Example Website"),
-		Description: utils.StringPtr("An example website for demonstration"),
-		CreatedBy:   &userID,
-		ClickCount:  25,
-		IsActive:    true,
-		IsPublic:    true,
-		CreatedAt:   now.Add(-7 * 24 * time.Hour),
-		UpdatedAt:   now.Add(-1 * time.Hour),
-		ExpiresAt:   nil,
-	}, nil
+// ResolveDestination picks the destination for a click, evaluating the
+// mapping's conditional redirect rules in order with the original URL as fallback
+func (s *ShortenerService) ResolveDestination(mapping *models.URLMapping, ctx *RedirectContext) string {
+	return s.redirectRules.Resolve(mapping.RedirectRules, mapping.OriginalURL, ctx)
 }
 
-// UpdateURL updates a URL's properties
-func (s *ShortenerService) UpdateURL(shortCode string, req *models.UpdateURLRequest) (*models.URL, error) {
-	// Get current URL
-	url, err := s.GetURLByShortCode(shortCode)
-	if err != nil {
-		return nil, err
+// AdmitClick reserves one click on a click-limited link. The cap is enforced
+// with an atomic Redis increment so concurrent redirects can't overshoot it;
+// ErrClickLimitReached is returned once the link is exhausted. Only human
+// visits count towards the cap: bots, link previews and scanners are let
+// through without reserving a click until the link is exhausted.
+func (s *ShortenerService) AdmitClick(mapping *models.URLMapping, userAgent string) error {
+	if !mapping.IsClickLimited() {
+		return nil
 	}
 
-	// Update fields if provided
-	if req.Title != nil {
-		url.Title = req.Title
+	// Resume from the persisted total if the cached counter has expired
+	count, err := s.cache.GetClickCount(mapping.ShortCode)
+	if err == storage.ErrCacheKeyNotFound {
+		count, err = s.db.CountHumanClicks(mapping.ShortCode)
+		if err != nil {
+			return fmt.Errorf("failed to load click count: %w", err)
+		}
+		if err := s.cache.SeedClickCount(mapping.ShortCode, count); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return fmt.Errorf("failed to load click count: %w", err)
 	}
-	if req.Description != nil {
-		url.Description = req.Description
+
+	if s.userAgents.ClassifyTraffic(userAgent) != models.TrafficClassHuman {
+		if count >= *mapping.MaxClicks {
+			return ErrClickLimitReached
+		}
+		return nil
 	}
-	if req.ExpiresAt != nil {
-		url.ExpiresAt = req.ExpiresAt
+
+	count, err = s.cache.IncrementClickCount(mapping.ShortCode)
+	if err != nil {
+		return fmt.Errorf("failed to reserve click: %w", err)
 	}
-	if req.IsPublic != nil {
-		url.IsPublic = *req.IsPublic
+
+	if count > *mapping.MaxClicks {
+		// Hand the slot back so rejected visits don't eat into a later raised cap
+		s.cache.DecrementClickCount(mapping.ShortCode)
+		return ErrClickLimitReached
 	}
 
-	url.UpdatedAt = time.Now()
+	return nil
+}
+
+// UnlockLink verifies a visitor's password for a protected link and returns
+// a signed access token with its expiry
+func (s *ShortenerService) UnlockLink(mapping *models.URLMapping, password, clientIP string) (string, time.Time, error) {
+	return s.linkPasswords.Unlock(mapping, password, clientIP)
+}
+
+// HasLinkAccess reports whether a visitor's access token unlocks a protected link
+func (s *ShortenerService) HasLinkAccess(mapping *models.URLMapping, token string) bool {
+	return s.linkPasswords.HasAccess(mapping, token)
+}
+
+// GetURLByShortCode retrieves a user's URL by its short code (for dashboard)
+func (s *ShortenerService) GetURLByShortCode(userID int64, shortCode string) (*models.URL, error) {
+	url, err := s.db.GetURL(shortCode)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check ownership
+	if url.CreatedBy == nil || *url.CreatedBy != userID {
+		return nil, storage.ErrUnauthorized
+	}
 
-	// Implementation would save to database
 	return url, nil
 }
 
-// DeleteURL deletes a URL (soft delete)
-func (s *ShortenerService) DeleteURL(shortCode string) error {
-	// Implementation would mark URL as inactive or delete from database
-	// For now, simulate success
-	return nil
+// UpdateURL updates a user's URL and returns the stored record
+func (s *ShortenerService) UpdateURL(userID int64, shortCode string, req *models.UpdateURLRequest) (*models.URL, error) {
+	if _, err := s.UpdateUserURL(userID, shortCode, req); err != nil {
+		return nil, err
+	}
+
+	return s.GetURLByShortCode(userID, shortCode)
+}
+
+// DeleteURL soft deletes a user's URL
+func (s *ShortenerService) DeleteURL(userID int64, shortCode string) error {
+	// Distinguish links owned by someone else from missing ones
+	if _, err := s.GetURLByShortCode(userID, shortCode); err != nil {
+		return err
+	}
+
+	return s.DeleteUserURL(userID, shortCode)
 }
 
 
 // RecordClick records a click event for analytics
-func (s *ShortenerService) RecordClick(shortCode, clientIP, userAgent, referrer string) error {
+func (s *ShortenerService) RecordClick(mapping *models.URLMapping, clientIP, userAgent, referrer string) error {
+	return s.recordClick(mapping, "", clientIP, userAgent, referrer)
+}
+
+// RecordScan records a visit from the link's QR code. Scans count as clicks
+// and are also reported separately in the link's analytics.
+func (s *ShortenerService) RecordScan(mapping *models.URLMapping, clientIP, userAgent, referrer string) error {
+	return s.recordClick(mapping, models.ClickSourceQR, clientIP, userAgent, referrer)
+}
+
+func (s *ShortenerService) recordClick(mapping *models.URLMapping, source, clientIP, userAgent, referrer string) error {
+	shortCode := mapping.ShortCode
+
 	// Generate ID for click event
 	id, err := utils.GenerateID()
 	if err != nil {
@@ -251,45 +479,60 @@ func (s *ShortenerService) RecordClick(shortCode, clientIP, userAgent, referrer
 		UserAgent: userAgent,
 		Referrer:  referrer,
 		CountryCode: s.getCountryFromIP(clientIP), // Simple implementation
+		DestinationVersion: mapping.DestinationVersion,
+		TrafficClass: s.userAgents.ClassifyTraffic(userAgent),
+		Source: source,
 	}
 
-	// Save to database (async operation for better performance)
-	go func() {
-		if err := s.db.SaveClickEvent(event); err != nil {
-			fmt.Printf("Failed to save click event: %v\n", err)
-		}
-	}()
+	// Increment cached click count for faster analytics; click-limited links
+	// count only the human clicks AdmitClick reserved
+	if !mapping.IsClickLimited() {
+		s.cache.IncrementClickCount(shortCode)
+	}
 
-	// Process enhanced analytics (async operation)
-	go func() {
-		if err := s.analytics.ProcessEnhancedClickEvent(event); err != nil {
-			fmt.Printf("Failed to process enhanced click analytics: %v\n", err)
+	// Write the click to the durable stream; the consumer group persists it
+	if s.clickStream != nil {
+		err := s.clickStream.Publish(event)
+		if err == nil {
+			return nil
 		}
-	}()
+		fmt.Printf("Click stream unavailable, using in-process queue: %v\n", err)
+	}
+
+	// Persistence, click counts and analytics happen in the ingestion pipeline
+	if !s.clicks.Enqueue(event) {
+		return ErrClickQueueFull
+	}
 
-	// Increment click count in database
-	go func() {
-		if err := s.db.IncrementClickCount(shortCode); err != nil {
-			fmt.Printf("Failed to increment click count in DB: %v\n", err)
+	return nil
+}
+
+// processClick runs the per-click side effects once the pipeline has persisted the event
+func (s *ShortenerService) processClick(event *models.ClickEvent) {
+	if err := s.analytics.ProcessEnhancedClickEvent(event); err != nil {
+		fmt.Printf("Failed to process enhanced click analytics: %v\n", err)
+	}
+
+	// Bots, link previews and scanners don't feed live dashboards or attribution
+	if event.TrafficClass != "" && event.TrafficClass != models.TrafficClassHuman {
+		return
+	}
+
+	if s.uniqueVisitors != nil {
+		if err := s.uniqueVisitors.Record(event); err != nil {
+			fmt.Printf("Failed to record unique visitor: %v\n", err)
 		}
-	}()
+	}
 
-	// Increment cached click count for faster analytics
-	s.redis.IncrementClickCount(shortCode)
-	
 	// Broadcast real-time click event if real-time service is available
 	if s.realtime != nil {
-		s.realtime.BroadcastClick(shortCode, clientIP, userAgent, referrer)
+		s.realtime.BroadcastClick(event.ShortCode, event.IPAddress, event.UserAgent, event.Referrer)
 	}
-	
+
 	// Record attribution touchpoint if attribution service is available
 	if s.attribution != nil {
-		go func() {
-			s.recordAttributionTouchpoint(shortCode, clientIP, userAgent, referrer)
-		}()
+		s.recordAttributionTouchpoint(event.ShortCode, event.IPAddress, event.UserAgent, event.Referrer)
 	}
-
-	return nil
 }
 
 // validateURL validates if the provided URL is valid
@@ -322,6 +565,35 @@ func (s *ShortenerService) validateURL(rawURL string) error {
 	return nil
 }
 
+// validateRedirectRules validates conditional redirect rules and their destinations
+func (s *ShortenerService) validateRedirectRules(rules models.RedirectRules) error {
+	if err := ValidateRedirectRules(rules); err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		if err := s.validateURL(rule.Destination); err != nil {
+			return ErrInvalidRedirectRule
+		}
+	}
+	return nil
+}
+
+// validateLinkLimits validates scheduled activation and click limit settings
+func (s *ShortenerService) validateLinkLimits(startsAt, expiresAt *time.Time, maxClicks *int64, fallbackURL string) error {
+	if startsAt != nil && expiresAt != nil && !startsAt.Before(*expiresAt) {
+		return ErrInvalidSchedule
+	}
+	if maxClicks != nil && *maxClicks < 1 {
+		return ErrInvalidMaxClicks
+	}
+	if fallbackURL != "" {
+		if err := s.validateURL(fallbackURL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // validateCustomCode validates custom short codes
 func (s *ShortenerService) validateCustomCode(code string) error {
 	if len(code) < 3 || len(code) > 10 {
@@ -369,6 +641,19 @@ var (
 	ErrInvalidCustomCodeCharacters  = &ServiceError{Message: "custom code can only contain letters, numbers"}
 	ErrReservedCustomCode          = &ServiceError{Message: "custom code is reserved"}
 	ErrCustomCodeAlreadyExists     = &ServiceError{Message: "custom code already exists"}
+	ErrInvalidRedirectType         = &ServiceError{Message: "redirect type must be one of 301, 302, 307, 308 or meta_refresh"}
+	ErrInvalidRedirectRule         = &ServiceError{Message: "redirect rules need a valid destination URL, weekdays 0-6, HH:MM times and a valid timezone"}
+	ErrTooManyRedirectRules        = &ServiceError{Message: "too many redirect rules"}
+	ErrInvalidLinkPassword         = &ServiceError{Message: "link password must be between 4 and 72 characters"}
+	ErrIncorrectLinkPassword       = &ServiceError{Message: "incorrect password"}
+	ErrLinkPasswordLocked          = &ServiceError{Message: "too many failed password attempts, try again later"}
+	ErrLinkPasswordUnavailable     = &ServiceError{Message: "password-protected links are not configured"}
+	ErrInvalidSchedule             = &ServiceError{Message: "starts_at must be before expires_at"}
+	ErrInvalidMaxClicks            = &ServiceError{Message: "max_clicks must be at least 1"}
+	ErrClickLimitReached           = &ServiceError{Message: "this link has reached its click limit"}
+	ErrClickQueueFull              = &ServiceError{Message: "click ingestion queue is full, click dropped"}
+	ErrInvalidTrendPeriod          = &ServiceError{Message: "period must be one of hour, day, week or month"}
+	ErrAdvancedAnalyticsUnavailable = &ServiceError{Message: "advanced analytics require the Postgres storage backend"}
 )
 
 type ServiceError struct {
@@ -381,62 +666,14 @@ func (e *ServiceError) Error() string {
 
 // GetUserURLs retrieves URLs for a specific user with pagination
 func (s *ShortenerService) GetUserURLs(userID int64, offset, limit int) ([]*models.UserURLResponse, int64, error) {
-	query := `
-		SELECT id, short_code, original_url, created_at, expires_at, click_count, is_active,
-		       COALESCE(user_id, 0), COALESCE(is_public, true), COALESCE(custom_alias, ''), 
-		       COALESCE(title, ''), COALESCE(description, '')
-		FROM url_mappings 
-		WHERE user_id = $1 AND is_active = TRUE
-		ORDER BY created_at DESC
-		LIMIT $2 OFFSET $3
-	`
-	
-	rows, err := s.db.Query(query, userID, limit, offset)
+	urls, total, err := s.db.ListUserURLs(userID, offset, limit)
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to query user URLs: %w", err)
+		return nil, 0, err
 	}
-	defer rows.Close()
-
-	var urls []*models.UserURLResponse
-	for rows.Next() {
-		var url models.UserURLResponse
-		var userIDDB int64
-		var isPublicDB bool
-		var customAlias, title, description string
-		var expiresAt sql.NullTime
-		
-		err := rows.Scan(
-			&url.ID, &url.ShortCode, &url.OriginalURL, &url.CreatedAt,
-			&expiresAt, &url.ClickCount, &url.IsActive,
-			&userIDDB, &isPublicDB, &customAlias, &title, &description,
-		)
-		if err != nil {
-			return nil, 0, fmt.Errorf("failed to scan URL row: %w", err)
-		}
 
-		// Set nullable fields
-		if expiresAt.Valid {
-			url.ExpiresAt = &expiresAt.Time
-		}
-		if title != "" {
-			url.Title = &title
-		}
-		if description != "" {
-			url.Description = &description
-		}
-		url.IsPublic = isPublicDB
-
-		// Build short URL
+	// Build short URLs
+	for _, url := range urls {
 		url.ShortURL = fmt.Sprintf("%s/%s", s.config.BaseURL, url.ShortCode)
-		
-		urls = append(urls, &url)
-	}
-
-	// Get total count
-	var total int64
-	err = s.db.QueryRow("SELECT COUNT(*) FROM url_mappings WHERE user_id = $1 AND is_active = TRUE", userID).Scan(&total)
-	if err != nil {
-		return nil, 0, fmt.Errorf("failed to count user URLs: %w", err)
 	}
 
 	return urls, total, nil
@@ -444,25 +681,13 @@ func (s *ShortenerService) GetUserURLs(userID int64, offset, limit int) ([]*mode
 
 // DeleteUserURL deletes a URL belonging to a specific user
 func (s *ShortenerService) DeleteUserURL(userID int64, shortCode string) error {
-	query := `UPDATE url_mappings SET is_active = FALSE WHERE short_code = $1 AND user_id = $2 AND is_active = TRUE`
-	
-	result, err := s.db.Exec(query, shortCode, userID)
-	if err != nil {
-		return fmt.Errorf("failed to delete URL: %w", err)
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to check affected rows: %w", err)
-	}
-
-	if rowsAffected == 0 {
-		return storage.ErrURLNotFound
+	if err := s.db.DeactivateURL(shortCode, userID); err != nil {
+		return err
 	}
 
 	// Remove from cache
-	if s.redis != nil {
-		s.redis.DeleteURLMapping(shortCode)
+	if s.cache != nil {
+		s.cache.DeleteURLMapping(shortCode)
 	}
 
 	return nil
@@ -471,132 +696,164 @@ func (s *ShortenerService) DeleteUserURL(userID int64, shortCode string) error {
 // UpdateUserURL updates a URL belonging to a specific user
 func (s *ShortenerService) UpdateUserURL(userID int64, shortCode string, req *models.UpdateURLRequest) (*models.UserURLResponse, error) {
 	// First check if the URL exists and belongs to the user
-	var existingURL models.UserURLResponse
-	var userIDDB sql.NullInt64
-	var isPublicDB sql.NullBool
-	var customAlias, title, description sql.NullString
-	var expiresAt sql.NullTime
-
-	checkQuery := `
-		SELECT id, short_code, original_url, created_at, expires_at, click_count, is_active,
-		       user_id, is_public, custom_alias, title, description
-		FROM url_mappings 
-		WHERE short_code = $1 AND is_active = TRUE
-	`
-	
-	err := s.db.QueryRow(checkQuery, shortCode).Scan(
-		&existingURL.ID, &existingURL.ShortCode, &existingURL.OriginalURL, &existingURL.CreatedAt,
-		&expiresAt, &existingURL.ClickCount, &existingURL.IsActive,
-		&userIDDB, &isPublicDB, &customAlias, &title, &description,
-	)
-	
-	if err == sql.ErrNoRows {
-		return nil, storage.ErrURLNotFound
+	if _, err := s.GetURLByShortCode(userID, shortCode); err != nil {
+		return nil, err
 	}
+	existingURL, err := s.db.GetUserURL(shortCode)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch URL: %w", err)
+		return nil, err
 	}
 
-	// Check ownership
-	if !userIDDB.Valid || userIDDB.Int64 != userID {
-		return nil, storage.ErrUnauthorized
+	update := &storage.URLUpdate{
+		Title:       req.Title,
+		Description: req.Description,
+		ExpiresAt:   req.ExpiresAt,
+		IsPublic:    req.IsPublic,
 	}
 
-	// Build update query dynamically
-	updates := []string{}
-	args := []interface{}{}
-	argIndex := 1
+	if req.RedirectType != nil {
+		if !models.IsValidRedirectType(*req.RedirectType) {
+			return nil, ErrInvalidRedirectType
+		}
+		update.RedirectType = req.RedirectType
+	}
 
-	if req.Title != nil {
-		updates = append(updates, fmt.Sprintf("title = $%d", argIndex))
-		args = append(args, *req.Title)
-		argIndex++
+	if req.RedirectRules != nil {
+		if err := s.validateRedirectRules(*req.RedirectRules); err != nil {
+			return nil, err
+		}
+		for _, rule := range *req.RedirectRules {
+			if err := s.screenDestinations(rule.Destination); err != nil {
+				return nil, err
+			}
+		}
+		update.RedirectRules = req.RedirectRules
 	}
 
-	if req.Description != nil {
-		updates = append(updates, fmt.Sprintf("description = $%d", argIndex))
-		args = append(args, *req.Description)
-		argIndex++
+	if req.SocialPreview != nil {
+		if err := NormalizeSocialPreview(req.SocialPreview); err != nil {
+			return nil, err
+		}
+		update.SocialPreview = req.SocialPreview
 	}
 
-	if req.ExpiresAt != nil {
-		updates = append(updates, fmt.Sprintf("expires_at = $%d", argIndex))
-		args = append(args, *req.ExpiresAt)
-		argIndex++
+	if req.StartsAt != nil || req.ExpiresAt != nil {
+		// Check the schedule as it will be stored, keeping the end not being
+		// changed; a zero start removes it
+		startsAt, expiresAt := req.StartsAt, req.ExpiresAt
+		if startsAt == nil {
+			startsAt = existingURL.StartsAt
+		} else if startsAt.IsZero() {
+			startsAt = nil
+		}
+		if expiresAt == nil {
+			expiresAt = existingURL.ExpiresAt
+		}
+		if err := s.validateLinkLimits(startsAt, expiresAt, nil, ""); err != nil {
+			return nil, err
+		}
+		update.StartsAt = req.StartsAt
 	}
 
-	if req.IsPublic != nil {
-		updates = append(updates, fmt.Sprintf("is_public = $%d", argIndex))
-		args = append(args, *req.IsPublic)
-		argIndex++
+	if req.MaxClicks != nil {
+		if *req.MaxClicks != 0 {
+			if err := s.validateLinkLimits(nil, nil, req.MaxClicks, ""); err != nil {
+				return nil, err
+			}
+		}
+		update.MaxClicks = req.MaxClicks
 	}
 
-	if len(updates) == 0 {
-		// No updates requested, return current data
-		if expiresAt.Valid {
-			existingURL.ExpiresAt = &expiresAt.Time
+	if req.FallbackURL != nil {
+		if err := s.validateLinkLimits(nil, nil, nil, *req.FallbackURL); err != nil {
+			return nil, err
 		}
-		if title.Valid {
-			existingURL.Title = &title.String
+		if err := s.screenDestinations(*req.FallbackURL); err != nil {
+			return nil, err
+		}
+		update.FallbackURL = req.FallbackURL
+	}
+
+	if req.Password != nil {
+		hash := ""
+		if *req.Password != "" {
+			hash, err = s.linkPasswords.HashPassword(*req.Password)
+			if err != nil {
+				return nil, err
+			}
 		}
-		if description.Valid {
-			existingURL.Description = &description.String
+		update.PasswordHash = &hash
+	}
+
+	var tags []string
+	if req.Tags != nil {
+		if tags, err = NormalizeTags(*req.Tags); err != nil {
+			return nil, err
 		}
-		if isPublicDB.Valid {
-			existingURL.IsPublic = isPublicDB.Bool
-		} else {
-			existingURL.IsPublic = true
+	}
+
+	// Destination changes are versioned separately so the prior URL is kept in history
+	destinationChanged := req.OriginalURL != nil && *req.OriginalURL != existingURL.OriginalURL
+	if destinationChanged {
+		if err := s.validateURL(*req.OriginalURL); err != nil {
+			return nil, err
 		}
+		if err := s.screenDestinations(*req.OriginalURL); err != nil {
+			return nil, err
+		}
+	}
+
+	if !destinationChanged && update.IsEmpty() && req.Tags == nil {
+		// No updates requested, return current data
 		existingURL.ShortURL = fmt.Sprintf("%s/%s", s.config.BaseURL, existingURL.ShortCode)
-		return &existingURL, nil
+		return existingURL, nil
 	}
 
-	// Add WHERE clause parameters
-	args = append(args, shortCode, userID)
-	
-	updateQuery := fmt.Sprintf(
-		"UPDATE url_mappings SET %s WHERE short_code = $%d AND user_id = $%d AND is_active = TRUE",
-		strings.Join(updates, ", "), argIndex, argIndex+1,
-	)
+	if destinationChanged {
+		// The other fields change in the same transaction, so a failure
+		// leaves the link exactly as it was
+		version, err := s.db.ChangeURLDestination(shortCode, userID, *req.OriginalURL, update)
+		if err == storage.ErrURLNotFound {
+			return nil, err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to change destination: %w", err)
+		}
+		if s.metadata != nil {
+			s.metadata.Enqueue(shortCode, *req.OriginalURL, version)
+		}
+	} else if err := s.db.UpdateURL(shortCode, userID, update); err != nil {
+		return nil, err
+	}
 
-	_, err = s.db.Exec(updateQuery, args...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to update URL: %w", err)
+	if req.Tags != nil {
+		if err := s.db.SetURLTags(shortCode, tags); err != nil {
+			return nil, fmt.Errorf("failed to save tags: %w", err)
+		}
 	}
 
 	// Remove from cache to force refresh
-	if s.redis != nil {
-		s.redis.DeleteURLMapping(shortCode)
+	if s.cache != nil {
+		s.cache.DeleteURLMapping(shortCode)
 	}
 
 	// Fetch updated record
-	err = s.db.QueryRow(checkQuery, shortCode).Scan(
-		&existingURL.ID, &existingURL.ShortCode, &existingURL.OriginalURL, &existingURL.CreatedAt,
-		&expiresAt, &existingURL.ClickCount, &existingURL.IsActive,
-		&userIDDB, &isPublicDB, &customAlias, &title, &description,
-	)
+	updatedURL, err := s.db.GetUserURL(shortCode)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch updated URL: %w", err)
 	}
+	updatedURL.ShortURL = fmt.Sprintf("%s/%s", s.config.BaseURL, updatedURL.ShortCode)
 
-	// Set nullable fields
-	if expiresAt.Valid {
-		existingURL.ExpiresAt = &expiresAt.Time
-	}
-	if title.Valid {
-		existingURL.Title = &title.String
-	}
-	if description.Valid {
-		existingURL.Description = &description.String
-	}
-	if isPublicDB.Valid {
-		existingURL.IsPublic = isPublicDB.Bool
-	} else {
-		existingURL.IsPublic = true
+	return updatedURL, nil
+}
+
+// GetUserURLDestinationHistory retrieves the prior destinations of a URL belonging to a specific user
+func (s *ShortenerService) GetUserURLDestinationHistory(userID int64, shortCode string) ([]models.DestinationHistoryEntry, error) {
+	if _, err := s.GetURLByShortCode(userID, shortCode); err != nil {
+		return nil, err
 	}
-	existingURL.ShortURL = fmt.Sprintf("%s/%s", s.config.BaseURL, existingURL.ShortCode)
 
-	return &existingURL, nil
+	return s.db.GetDestinationHistory(shortCode)
 }
 
 // recordAttributionTouchpoint records a touchpoint for attribution analysis
@@ -621,14 +878,11 @@ func (s *ShortenerService) recordAttributionTouchpoint(shortCode, clientIP, user
 		TouchpointTime: time.Now(),
 	}
 
-	// Record the touchpoint asynchronously
-	go func() {
-		if err := s.attribution.RecordTouchpoint(touchpoint); err != nil {
-			// Log error but don't fail the request
-			// In production, use proper logging
-			fmt.Printf("Failed to record attribution touchpoint: %v\n", err)
-		}
-	}()
+	if err := s.attribution.RecordTouchpoint(touchpoint); err != nil {
+		// Log error but don't fail the request
+		// In production, use proper logging
+		fmt.Printf("Failed to record attribution touchpoint: %v\n", err)
+	}
 }
 
 // generateSessionID creates a session ID from IP and user agent
@@ -9,13 +9,15 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/google/uuid"
+
 	"github.com/URLshorter/url-shortener/configs"
 	"github.com/URLshorter/url-shortener/internal/models"
 	"github.com/URLshorter/url-shortener/internal/storage"
 )
 
 type SMSService struct {
-	db     *storage.PostgresStorage
+	db     storage.UserRepository
 	config *configs.Config
 	client *http.Client
 }
@@ -35,7 +37,7 @@ type TextLKResponse struct {
 }
 
 // NewSMSService creates a new SMS service
-func NewSMSService(db *storage.PostgresStorage, config *configs.Config) *SMSService {
+func NewSMSService(db storage.UserRepository, config *configs.Config) *SMSService {
 	return &SMSService{
 		db:     db,
 		config: config,
@@ -55,6 +57,7 @@ func (s *SMSService) SendOTP(userID int64, phone string) (*models.PhoneVerificat
 
 	// Create phone verification record
 	verification := &models.PhoneVerification{
+		ID:        uuid.New(),
 		UserID:    userID,
 		Phone:     phone,
 		OTPCode:   otp,
@@ -107,7 +110,7 @@ func (s *SMSService) VerifyOTP(userID int64, otp string) error {
 	// Verify OTP
 	if verification.OTPCode != otp {
 		// Increment attempts
-		err = s.IncrementVerificationAttempts(verification.ID)
+		err = s.IncrementVerificationAttempts(verification)
 		if err != nil {
 			fmt.Printf("Failed to increment verification attempts: %v\n", err)
 		}
@@ -234,76 +237,33 @@ func (s *SMSService) getTextLKAPIKey() string {
 
 // CreatePhoneVerification creates a phone verification record
 func (s *SMSService) CreatePhoneVerification(verification *models.PhoneVerification) error {
-	query := `
-		INSERT INTO phone_verifications (user_id, phone, otp_code, attempts, expires_at, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id`
-	
-	return s.db.QueryRow(query, verification.UserID, verification.Phone, verification.OTPCode,
-		verification.Attempts, verification.ExpiresAt, verification.CreatedAt).Scan(&verification.ID)
+	return s.db.CreatePhoneVerification(verification)
 }
 
 // GetLatestPhoneVerification gets the latest phone verification for a user
 func (s *SMSService) GetLatestPhoneVerification(userID int64) (*models.PhoneVerification, error) {
-	verification := &models.PhoneVerification{}
-	query := `
-		SELECT id, user_id, phone, otp_code, attempts, expires_at, verified_at, created_at
-		FROM phone_verifications
-		WHERE user_id = $1 AND verified_at IS NULL
-		ORDER BY created_at DESC
-		LIMIT 1`
-	
-	err := s.db.QueryRow(query, userID).Scan(
-		&verification.ID, &verification.UserID, &verification.Phone, &verification.OTPCode,
-		&verification.Attempts, &verification.ExpiresAt, &verification.VerifiedAt,
-		&verification.CreatedAt,
-	)
-	
-	return verification, err
+	return s.db.GetPhoneVerificationByUserID(userID)
 }
 
-// IncrementVerificationAttempts increments the attempt count
-func (s *SMSService) IncrementVerificationAttempts(verificationID interface{}) error {
-	query := "UPDATE phone_verifications SET attempts = attempts + 1 WHERE id = $1"
-	_, err := s.db.Exec(query, verificationID)
-	return err
+// IncrementVerificationAttempts counts a failed attempt at a verification
+func (s *SMSService) IncrementVerificationAttempts(verification *models.PhoneVerification) error {
+	return s.db.UpdatePhoneVerificationAttempts(verification.UserID, verification.Attempts+1)
 }
 
 // MarkPhoneVerified marks the phone as verified
-func (s *SMSService) MarkPhoneVerified(verificationID interface{}, userID int64, verifiedAt time.Time) error {
-	tx, err := s.db.Begin()
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	// Mark verification as completed
-	_, err = tx.Exec("UPDATE phone_verifications SET verified_at = $1 WHERE id = $2", verifiedAt, verificationID)
-	if err != nil {
-		return err
-	}
-
-	// Update user phone_verified status
-	_, err = tx.Exec("UPDATE users SET phone_verified = true WHERE id = $1", userID)
-	if err != nil {
+func (s *SMSService) MarkPhoneVerified(verificationID uuid.UUID, userID int64, verifiedAt time.Time) error {
+	if err := s.db.UpdatePhoneVerification(verificationID.String(), verifiedAt); err != nil {
 		return err
 	}
-
-	return tx.Commit()
+	return s.db.UpdateUserPhoneVerified(userID, true)
 }
 
-// InvalidatePhoneVerifications invalidates all pending verifications for a user
+// InvalidatePhoneVerifications removes all pending verifications for a user
 func (s *SMSService) InvalidatePhoneVerifications(userID int64) error {
-	query := "UPDATE phone_verifications SET verified_at = NOW() WHERE user_id = $1 AND verified_at IS NULL"
-	_, err := s.db.Exec(query, userID)
-	return err
+	return s.db.DeletePhoneVerificationByUserID(userID)
 }
 
-// GetUserByID gets a user by ID (simplified version for SMS service)
+// GetUserByID gets a user by ID
 func (s *SMSService) GetUserByID(userID int64) (*models.User, error) {
-	user := &models.User{}
-	query := "SELECT id, email, phone, phone_verified FROM users WHERE id = $1"
-	
-	err := s.db.QueryRow(query, userID).Scan(&user.ID, &user.Email, &user.Phone, &user.PhoneVerified)
-	return user, err
-}
\ No newline at end of file
+	return s.db.GetUserByID(userID)
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/URLshorter/url-shortener/internal/models"
+)
+
+// Longest social preview values, in characters
+const (
+	maxSocialPreviewTitleLength       = 200
+	maxSocialPreviewDescriptionLength = 500
+	maxSocialPreviewImageLength       = 2048
+)
+
+// PreviewCard is the page served to link preview bots for a link with a
+// custom social preview
+type PreviewCard struct {
+	URL         string // the short link, so the card points back at it
+	Title       string
+	Description string
+	Image       string
+	TwitterCard string
+}
+
+// NormalizeSocialPreview trims a social preview and checks its fields. The
+// Twitter card type defaults to a large image card when an image is set.
+func NormalizeSocialPreview(preview *models.SocialPreview) error {
+	if preview == nil {
+		return nil
+	}
+
+	preview.OGTitle = strings.TrimSpace(preview.OGTitle)
+	preview.OGDescription = strings.TrimSpace(preview.OGDescription)
+	preview.OGImage = strings.TrimSpace(preview.OGImage)
+	preview.TwitterCard = strings.ToLower(strings.TrimSpace(preview.TwitterCard))
+
+	if utf8.RuneCountInString(preview.OGTitle) > maxSocialPreviewTitleLength ||
+		utf8.RuneCountInString(preview.OGDescription) > maxSocialPreviewDescriptionLength {
+		return ErrSocialPreviewTooLong
+	}
+
+	if preview.OGImage != "" {
+		image, err := url.Parse(preview.OGImage)
+		if err != nil || (image.Scheme != "http" && image.Scheme != "https") || image.Host == "" ||
+			len(preview.OGImage) > maxSocialPreviewImageLength {
+			return ErrInvalidSocialPreviewImage
+		}
+	}
+
+	switch preview.TwitterCard {
+	case "":
+		if preview.IsEmpty() {
+			return nil
+		}
+		preview.TwitterCard = models.TwitterCardSummary
+		if preview.OGImage != "" {
+			preview.TwitterCard = models.TwitterCardSummaryLargeImage
+		}
+	case models.TwitterCardSummary, models.TwitterCardSummaryLargeImage:
+	default:
+		return ErrInvalidTwitterCard
+	}
+
+	return nil
+}
+
+// IsPreviewBot reports whether a user agent belongs to a social network or
+// chat app fetching a link to show a preview of it
+func (s *ShortenerService) IsPreviewBot(userAgent string) bool {
+	bot := s.userAgents.DetectBot(userAgent)
+	return bot != nil && bot.Type == "social_media"
+}
+
+// PreviewCard returns the card to serve instead of a redirect, or nil when the
+// visitor is not a preview bot or the link has no custom preview
+func (s *ShortenerService) PreviewCard(mapping *models.URLMapping, userAgent string) *PreviewCard {
+	if mapping.SocialPreview.IsEmpty() || !s.IsPreviewBot(userAgent) {
+		return nil
+	}
+
+	preview := mapping.SocialPreview
+	return &PreviewCard{
+		URL:         fmt.Sprintf("%s/%s", s.config.BaseURL, mapping.ShortCode),
+		Title:       preview.OGTitle,
+		Description: preview.OGDescription,
+		Image:       preview.OGImage,
+		TwitterCard: preview.TwitterCard,
+	}
+}
+
+// Social preview errors
+var (
+	ErrSocialPreviewTooLong      = &ServiceError{Message: "social preview titles are limited to 200 characters and descriptions to 500"}
+	ErrInvalidSocialPreviewImage = &ServiceError{Message: "social preview image must be an http or https URL"}
+	ErrInvalidTwitterCard        = &ServiceError{Message: "twitter card must be summary or summary_large_image"}
+)
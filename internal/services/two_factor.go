@@ -0,0 +1,540 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/URLshorter/url-shortener/internal/storage"
+)
+
+// Authenticator app codes follow RFC 6238 with the parameters every app
+// supports: HMAC-SHA1, six digits and a 30 second period
+const (
+	totpPeriod = 30
+	totpDigits = 6
+	totpSkew   = 1 // periods accepted either side of the current one
+
+	totpSecretBytes = 20
+
+	recoveryCodeCount  = 10
+	recoveryCodeGroups = 4
+	recoveryCodeGroup  = 4
+
+	twoFactorChallengeTTL = 5 * time.Minute
+	twoFactorMaxAttempts  = 5
+
+	// Failures are also counted per user, since anyone with the password
+	// can start as many challenges as they like
+	twoFactorMaxUserFailures = 10
+	twoFactorLockoutDuration = 15 * time.Minute
+)
+
+// recoveryCodeAlphabet leaves out characters that are easily confused
+const recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TeamOwnerLookup reports whether a user owns an active team
+type TeamOwnerLookup func(userID int64) (bool, error)
+
+// TwoFactorService handles authenticator app (TOTP) two-factor
+// authentication: enrolment, verifying codes at login, and single-use
+// recovery codes for users who lose their device. Admins can require it
+// for individual users, and for every team owner with SetTeamOwnerLookup.
+type TwoFactorService struct {
+	store      storage.TwoFactorRepository
+	cache      storage.Cache
+	issuer     string
+	teamOwners TeamOwnerLookup
+}
+
+// NewTwoFactorService creates a two-factor service. Login challenges are
+// kept in cache, which must be shared by every replica (Redis in
+// production); issuer names the service in authenticator apps.
+func NewTwoFactorService(store storage.TwoFactorRepository, cache storage.Cache, issuer string) *TwoFactorService {
+	return &TwoFactorService{
+		store:  store,
+		cache:  cache,
+		issuer: issuer,
+	}
+}
+
+// SetTeamOwnerLookup requires two-factor for every user owning a team
+func (s *TwoFactorService) SetTeamOwnerLookup(lookup TeamOwnerLookup) {
+	s.teamOwners = lookup
+}
+
+// Status returns a user's two-factor settings
+func (s *TwoFactorService) Status(userID int64) (*models.TwoFactorStatus, error) {
+	twoFactor, err := s.store.GetTwoFactor(userID)
+	if err != nil {
+		return nil, err
+	}
+	required, err := s.isRequired(twoFactor)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &models.TwoFactorStatus{
+		Enabled:   twoFactor.Enabled(),
+		Required:  required,
+		EnabledAt: twoFactor.EnabledAt,
+	}
+	if status.Enabled {
+		status.RecoveryCodesRemaining, err = s.store.CountRecoveryCodes(userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
+}
+
+// SetRequired sets whether an admin requires two-factor for a user. A user
+// required to use it can't turn it off, and must enrol at their next login.
+func (s *TwoFactorService) SetRequired(userID int64, required bool) error {
+	return s.store.SetTwoFactorRequired(userID, required)
+}
+
+// BeginEnrollment generates a new secret for the user to add to their
+// authenticator app. Two-factor is enabled once ConfirmEnrollment receives
+// a code generated from it.
+func (s *TwoFactorService) BeginEnrollment(user *models.User) (*models.TwoFactorEnrollment, error) {
+	twoFactor, err := s.store.GetTwoFactor(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if twoFactor.Enabled() {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	raw := make([]byte, totpSecretBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate two-factor secret: %w", err)
+	}
+	secret := totpEncoding.EncodeToString(raw)
+
+	if err := s.store.SaveTwoFactorSecret(user.ID, secret); err != nil {
+		if err == storage.ErrTwoFactorEnabled {
+			return nil, ErrTwoFactorAlreadyEnabled
+		}
+		return nil, err
+	}
+
+	return &models.TwoFactorEnrollment{
+		Secret: secret,
+		URI:    s.otpauthURI(user.Email, secret),
+	}, nil
+}
+
+// ConfirmEnrollment enables two-factor once code shows the user's app holds
+// the secret, returning the user's recovery codes. They are only returned
+// here and stored hashed.
+func (s *TwoFactorService) ConfirmEnrollment(userID int64, code string) ([]string, error) {
+	twoFactor, err := s.store.GetTwoFactor(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.confirm(twoFactor, code)
+}
+
+// Verify checks a code from the user's authenticator app or one of their
+// recovery codes, which is used up. Each authenticator code is accepted
+// once.
+func (s *TwoFactorService) Verify(userID int64, code string) error {
+	twoFactor, err := s.store.GetTwoFactor(userID)
+	if err != nil {
+		return err
+	}
+	return s.verify(twoFactor, code)
+}
+
+// Disable turns two-factor off after checking a code, unless it is required
+// for the user
+func (s *TwoFactorService) Disable(userID int64, code string) error {
+	twoFactor, err := s.store.GetTwoFactor(userID)
+	if err != nil {
+		return err
+	}
+	required, err := s.isRequired(twoFactor)
+	if err != nil {
+		return err
+	}
+	if required {
+		return ErrTwoFactorRequired
+	}
+
+	if err := s.verify(twoFactor, code); err != nil {
+		return err
+	}
+	return s.store.DisableTwoFactor(userID)
+}
+
+// Reset removes a user's authenticator and recovery codes without asking
+// for a code, for admins helping a user who lost both. A user still
+// required to use two-factor enrols again at their next login.
+func (s *TwoFactorService) Reset(userID int64) error {
+	return s.store.DisableTwoFactor(userID)
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes after checking
+// a code, returning the new ones
+func (s *TwoFactorService) RegenerateRecoveryCodes(userID int64, code string) ([]string, error) {
+	twoFactor, err := s.store.GetTwoFactor(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verify(twoFactor, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// CheckSatisfied returns ErrTwoFactorSetupRequired if two-factor is
+// required for the user but not enabled
+func (s *TwoFactorService) CheckSatisfied(userID int64) error {
+	twoFactor, err := s.store.GetTwoFactor(userID)
+	if err != nil {
+		return err
+	}
+	if twoFactor.Enabled() {
+		return nil
+	}
+
+	required, err := s.isRequired(twoFactor)
+	if err != nil {
+		return err
+	}
+	if required {
+		return ErrTwoFactorSetupRequired
+	}
+	return nil
+}
+
+// StartChallenge begins the second step of a login when the user has
+// two-factor enabled or is required to enrol, returning nil when the
+// password is enough. The challenge remembers whether the login asked to be
+// remembered, for the session started once it is completed.
+func (s *TwoFactorService) StartChallenge(userID int64, rememberMe bool) (*models.TwoFactorChallenge, error) {
+	twoFactor, err := s.store.GetTwoFactor(userID)
+	if err != nil {
+		return nil, err
+	}
+	required, err := s.isRequired(twoFactor)
+	if err != nil {
+		return nil, err
+	}
+	if !twoFactor.Enabled() && !required {
+		return nil, nil
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate two-factor challenge: %w", err)
+	}
+	token := hex.EncodeToString(raw)
+
+	if err := s.cache.Set(twoFactorChallengeKey(token), fmt.Sprintf("%d:%t", userID, rememberMe), twoFactorChallengeTTL); err != nil {
+		return nil, fmt.Errorf("failed to store two-factor challenge: %w", err)
+	}
+
+	return &models.TwoFactorChallenge{
+		Token:         token,
+		SetupRequired: !twoFactor.Enabled(),
+		ExpiresIn:     int64(twoFactorChallengeTTL.Seconds()),
+	}, nil
+}
+
+// ChallengeUser returns the user a login challenge was issued to
+func (s *TwoFactorService) ChallengeUser(token string) (int64, error) {
+	userID, _, err := s.lookupChallenge(token)
+	return userID, err
+}
+
+// CompleteChallenge checks the code presented for a login challenge,
+// returning the user and whether the login asked to be remembered. A user
+// enrolling during login confirms the enrolment with the code and gets
+// their recovery codes back. A challenge allows a few attempts and is used
+// up by the first success; a user failing too many challenges is locked out
+// for a while.
+func (s *TwoFactorService) CompleteChallenge(token, code string) (int64, bool, []string, error) {
+	userID, rememberMe, err := s.lookupChallenge(token)
+	if err != nil {
+		return 0, false, nil, err
+	}
+
+	attempts, err := s.cache.IncrementWithTTL(twoFactorAttemptsKey(token), twoFactorChallengeTTL)
+	if err != nil {
+		return 0, false, nil, fmt.Errorf("failed to count two-factor attempts: %w", err)
+	}
+	if attempts > twoFactorMaxAttempts {
+		s.cache.Delete(twoFactorChallengeKey(token))
+		return 0, false, nil, ErrTwoFactorChallengeInvalid
+	}
+
+	failures, err := s.cache.Get(twoFactorFailuresKey(userID))
+	if err == nil {
+		if count, _ := strconv.ParseInt(failures, 10, 64); count >= twoFactorMaxUserFailures {
+			return 0, false, nil, ErrTwoFactorLocked
+		}
+	} else if err != storage.ErrCacheKeyNotFound {
+		return 0, false, nil, fmt.Errorf("failed to read two-factor failures: %w", err)
+	}
+
+	twoFactor, err := s.store.GetTwoFactor(userID)
+	if err != nil {
+		return 0, false, nil, err
+	}
+
+	var recoveryCodes []string
+	if twoFactor.Enabled() {
+		err = s.verify(twoFactor, code)
+	} else {
+		recoveryCodes, err = s.confirm(twoFactor, code)
+	}
+	if err == ErrInvalidTwoFactorCode {
+		if _, countErr := s.cache.IncrementWithTTL(twoFactorFailuresKey(userID), twoFactorLockoutDuration); countErr != nil {
+			return 0, false, nil, fmt.Errorf("failed to count two-factor failures: %w", countErr)
+		}
+	}
+	if err != nil {
+		return 0, false, nil, err
+	}
+
+	s.cache.Delete(twoFactorFailuresKey(userID))
+	if err := s.cache.Delete(twoFactorChallengeKey(token)); err != nil {
+		return 0, false, nil, fmt.Errorf("failed to clear two-factor challenge: %w", err)
+	}
+	return userID, rememberMe, recoveryCodes, nil
+}
+
+// lookupChallenge returns the user of a pending login challenge and whether
+// the login asked to be remembered
+func (s *TwoFactorService) lookupChallenge(token string) (int64, bool, error) {
+	if token == "" {
+		return 0, false, ErrTwoFactorChallengeInvalid
+	}
+
+	value, err := s.cache.Get(twoFactorChallengeKey(token))
+	if err == storage.ErrCacheKeyNotFound {
+		return 0, false, ErrTwoFactorChallengeInvalid
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read two-factor challenge: %w", err)
+	}
+
+	id, remember, _ := strings.Cut(value, ":")
+	userID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return 0, false, ErrTwoFactorChallengeInvalid
+	}
+	return userID, remember == "true", nil
+}
+
+// confirm enables two-factor for a pending enrolment
+func (s *TwoFactorService) confirm(twoFactor *models.TwoFactor, code string) ([]string, error) {
+	if twoFactor.Enabled() {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if twoFactor.Secret == "" {
+		return nil, ErrTwoFactorNotEnrolling
+	}
+
+	step, ok := matchTOTP(twoFactor.Secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.EnableTwoFactor(twoFactor.UserID, step, hashes); err != nil {
+		if err == storage.ErrTwoFactorNotPending {
+			return nil, ErrTwoFactorNotEnrolling
+		}
+		return nil, err
+	}
+	return codes, nil
+}
+
+// verify checks an authenticator code or uses up a recovery code
+func (s *TwoFactorService) verify(twoFactor *models.TwoFactor, code string) error {
+	if !twoFactor.Enabled() {
+		return ErrTwoFactorNotEnabled
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == totpDigits {
+		step, ok := matchTOTP(twoFactor.Secret, code, time.Now())
+		if !ok {
+			return ErrInvalidTwoFactorCode
+		}
+		// Each step's code is accepted once, so an observed code can't be
+		// replayed within its period
+		used, err := s.store.UseTwoFactorStep(twoFactor.UserID, step)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrInvalidTwoFactorCode
+		}
+		return nil
+	}
+
+	used, err := s.store.UseRecoveryCode(twoFactor.UserID, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// isRequired reports whether an admin requires two-factor for the user,
+// directly or by owning a team
+func (s *TwoFactorService) isRequired(twoFactor *models.TwoFactor) (bool, error) {
+	if twoFactor.Required {
+		return true, nil
+	}
+	if s.teamOwners == nil {
+		return false, nil
+	}
+	return s.teamOwners(twoFactor.UserID)
+}
+
+// otpauthURI builds the key URI authenticator apps read from QR codes
+func (s *TwoFactorService) otpauthURI(account, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", s.issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", strconv.Itoa(totpDigits))
+	query.Set("period", strconv.Itoa(totpPeriod))
+
+	label := url.PathEscape(s.issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// TOTPCode returns the authenticator code for a secret at a point in time
+func TOTPCode(secret string, at time.Time) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", fmt.Errorf("invalid two-factor secret: %w", err)
+	}
+	return totpCode(key, at.Unix()/totpPeriod), nil
+}
+
+// matchTOTP returns the time step code was generated for, allowing for
+// clock drift of totpSkew periods
+func matchTOTP(secret, code string, now time.Time) (int64, bool) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return 0, false
+	}
+
+	code = strings.TrimSpace(code)
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// totpCode computes the HOTP value of a time step (RFC 4226)
+func totpCode(key []byte, step int64) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// generateRecoveryCodes returns new recovery codes, formatted like
+// "abcd-efgh-jkmn-pqrs", and their hashes
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		raw := make([]byte, recoveryCodeGroups*recoveryCodeGroup)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, fmt.Errorf("failed to generate recovery codes: %w", err)
+		}
+
+		var code strings.Builder
+		for j, b := range raw {
+			if j > 0 && j%recoveryCodeGroup == 0 {
+				code.WriteByte('-')
+			}
+			code.WriteByte(recoveryCodeAlphabet[int(b)%len(recoveryCodeAlphabet)])
+		}
+		codes[i] = code.String()
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode hashes a recovery code ignoring case, spaces and dashes.
+// Codes carry enough randomness that a fast hash is safe, which lets them
+// be looked up by hash.
+func hashRecoveryCode(code string) string {
+	normalized := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+func twoFactorChallengeKey(token string) string {
+	return "2fa:challenge:" + token
+}
+
+func twoFactorAttemptsKey(token string) string {
+	return "2fa:attempts:" + token
+}
+
+func twoFactorFailuresKey(userID int64) string {
+	return "2fa:failures:" + strconv.FormatInt(userID, 10)
+}
+
+// Two-factor errors
+var (
+	ErrTwoFactorAlreadyEnabled   = &ServiceError{Message: "two-factor authentication is already enabled"}
+	ErrTwoFactorNotEnabled       = &ServiceError{Message: "two-factor authentication is not enabled"}
+	ErrTwoFactorNotEnrolling     = &ServiceError{Message: "start two-factor enrolment first"}
+	ErrInvalidTwoFactorCode      = &ServiceError{Message: "invalid two-factor code"}
+	ErrTwoFactorRequired         = &ServiceError{Message: "two-factor authentication is required for this account"}
+	ErrTwoFactorSetupRequired    = &ServiceError{Message: "two-factor authentication must be set up for this account"}
+	ErrTwoFactorChallengeInvalid = &ServiceError{Message: "two-factor login expired or is invalid; log in again"}
+	ErrTwoFactorLocked           = &ServiceError{Message: "too many failed two-factor attempts, try again later"}
+)
@@ -0,0 +1,306 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/URLshorter/url-shortener/internal/storage"
+)
+
+// Sketches live under their own prefixes so no short code can name the
+// dirty set, a day's salt or a scratch key
+const (
+	uniqueVisitorDayFormat      = "2006-01-02"
+	uniqueVisitorDirtySet       = "uv:dirty"
+	uniqueVisitorLifetimePrefix = "uv:l:"
+	uniqueVisitorDailyPrefix    = "uv:d:"
+)
+
+// UniqueVisitorService counts unique visitors per link and per day with Redis
+// HyperLogLog sketches. Visitors are identified by a fingerprint hashing the
+// IP and user agent with a salt that rotates daily and is never persisted, so
+// fingerprints can't be reversed or linked across days. A returning visitor
+// therefore counts once per day in the lifetime sketch. Sketches touched since
+// the last run are periodically copied to Postgres and restored from there
+// when Redis has evicted them.
+type UniqueVisitorService struct {
+	db    *storage.PostgresStorage
+	redis *storage.RedisStorage
+
+	persistInterval time.Duration
+	dailyTTL        time.Duration
+
+	saltMutex sync.Mutex
+	saltDay   string
+	salt      string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewUniqueVisitorService creates a new unique visitor service
+func NewUniqueVisitorService(db *storage.PostgresStorage, redis *storage.RedisStorage) *UniqueVisitorService {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &UniqueVisitorService{
+		db:              db,
+		redis:           redis,
+		persistInterval: 5 * time.Minute,
+		dailyTTL:        90 * 24 * time.Hour,
+		ctx:             ctx,
+		cancel:          cancel,
+	}
+}
+
+// Start launches the periodic sketch persistence loop
+func (u *UniqueVisitorService) Start() {
+	u.wg.Add(1)
+	go func() {
+		defer u.wg.Done()
+
+		ticker := time.NewTicker(u.persistInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-u.ctx.Done():
+				return
+			case <-ticker.C:
+				u.PersistSketches()
+			}
+		}
+	}()
+}
+
+// Shutdown stops the persistence loop and persists outstanding sketches
+func (u *UniqueVisitorService) Shutdown() {
+	u.cancel()
+	u.wg.Wait()
+	u.PersistSketches()
+}
+
+// Record adds a human click's visitor fingerprint to the link's lifetime and daily sketches
+func (u *UniqueVisitorService) Record(event *models.ClickEvent) error {
+	if event.TrafficClass != "" && event.TrafficClass != models.TrafficClassHuman {
+		return nil
+	}
+
+	day := event.ClickedAt.UTC().Format(uniqueVisitorDayFormat)
+	fingerprint, err := u.fingerprint(event.IPAddress, event.UserAgent, day)
+	if err != nil {
+		return err
+	}
+
+	lifetimeKey := lifetimeSketchKey(event.ShortCode)
+	dailyKey := dailySketchKey(event.ShortCode, day)
+
+	for _, sketch := range []struct {
+		key string
+		ttl time.Duration
+	}{{lifetimeKey, 0}, {dailyKey, u.dailyTTL}} {
+		if err := u.ensureLoaded(sketch.key); err != nil {
+			return err
+		}
+		if err := u.redis.PFAdd(sketch.key, sketch.ttl, fingerprint); err != nil {
+			return err
+		}
+	}
+
+	return u.redis.SAdd(uniqueVisitorDirtySet, lifetimeKey, dailyKey)
+}
+
+// UniqueVisitors returns the approximate number of unique visitors of a link
+func (u *UniqueVisitorService) UniqueVisitors(shortCode string) (int64, error) {
+	return u.count([]string{lifetimeSketchKey(shortCode)})
+}
+
+// DailyUniqueVisitors returns unique visitors of a link for a day (YYYY-MM-DD)
+func (u *UniqueVisitorService) DailyUniqueVisitors(shortCode, day string) (int64, error) {
+	return u.count([]string{dailySketchKey(shortCode, day)})
+}
+
+// UniqueVisitorsAcross returns unique visitors across several links, optionally for a single day
+func (u *UniqueVisitorService) UniqueVisitorsAcross(shortCodes []string, day string) (int64, error) {
+	keys := make([]string, len(shortCodes))
+	for i, shortCode := range shortCodes {
+		if day == "" {
+			keys[i] = lifetimeSketchKey(shortCode)
+		} else {
+			keys[i] = dailySketchKey(shortCode, day)
+		}
+	}
+	return u.count(keys)
+}
+
+// PersistSketches copies sketches updated since the last run to Postgres
+func (u *UniqueVisitorService) PersistSketches() {
+	for {
+		keys, err := u.redis.SPopN(uniqueVisitorDirtySet, 500)
+		if err != nil {
+			log.Printf("Failed to read dirty visitor sketches: %v", err)
+			return
+		}
+		if len(keys) == 0 {
+			return
+		}
+
+		for _, key := range keys {
+			if err := u.persistSketch(key); err != nil {
+				log.Printf("Failed to persist visitor sketch %s: %v", key, err)
+				// Retry on the next run
+				u.redis.SAdd(uniqueVisitorDirtySet, key)
+			}
+		}
+	}
+}
+
+func (u *UniqueVisitorService) persistSketch(key string) error {
+	shortCode, day, ok := parseSketchKey(key)
+	if !ok {
+		// Left in the set by a version with other sketch keys
+		return nil
+	}
+
+	sketch, err := u.redis.GetBytes(key)
+	if err == storage.ErrCacheKeyNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var dayPtr *time.Time
+	if day != "" {
+		parsed, err := time.Parse(uniqueVisitorDayFormat, day)
+		if err != nil {
+			return fmt.Errorf("invalid sketch key %s: %w", key, err)
+		}
+		dayPtr = &parsed
+	}
+
+	return u.db.SaveVisitorSketch(key, shortCode, dayPtr, sketch)
+}
+
+// count returns the cardinality of the union of sketches, restoring evicted ones first.
+// Large unions are merged into a short-lived scratch key in chunks.
+func (u *UniqueVisitorService) count(keys []string) (int64, error) {
+	for _, key := range keys {
+		if err := u.ensureLoaded(key); err != nil {
+			return 0, err
+		}
+	}
+
+	const maxKeysPerCount = 100
+	if len(keys) <= maxKeysPerCount {
+		return u.redis.PFCount(keys...)
+	}
+
+	scratch := fmt.Sprintf("uv:scratch:%d", time.Now().UnixNano())
+	defer u.redis.Delete(scratch)
+	for start := 0; start < len(keys); start += maxKeysPerCount {
+		end := start + maxKeysPerCount
+		if end > len(keys) {
+			end = len(keys)
+		}
+		if err := u.redis.PFMerge(scratch, time.Minute, keys[start:end]...); err != nil {
+			return 0, err
+		}
+	}
+	return u.redis.PFCount(scratch)
+}
+
+// ensureLoaded restores a sketch from Postgres if Redis no longer holds it
+func (u *UniqueVisitorService) ensureLoaded(key string) error {
+	exists, err := u.redis.Exists(key)
+	if err != nil || exists {
+		return err
+	}
+
+	sketch, err := u.db.GetVisitorSketch(key)
+	if err != nil || sketch == nil {
+		return err
+	}
+
+	var ttl time.Duration
+	if _, day, _ := parseSketchKey(key); day != "" {
+		ttl = u.dailyTTL
+	}
+	// SETNX so a sketch another replica already started isn't clobbered
+	_, err = u.redis.SetNX(key, sketch, ttl)
+	return err
+}
+
+// fingerprint hashes a visitor's IP and user agent with the day's salt
+func (u *UniqueVisitorService) fingerprint(ip, userAgent, day string) (string, error) {
+	salt, err := u.dailySalt(day)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	h.Write([]byte(salt))
+	h.Write([]byte{0})
+	h.Write([]byte(ip))
+	h.Write([]byte{0})
+	h.Write([]byte(userAgent))
+	return hex.EncodeToString(h.Sum(nil)[:16]), nil
+}
+
+// dailySalt returns the random salt shared by all replicas for a day. It lives
+// only in Redis and expires shortly after the day ends.
+func (u *UniqueVisitorService) dailySalt(day string) (string, error) {
+	u.saltMutex.Lock()
+	defer u.saltMutex.Unlock()
+
+	if u.saltDay == day {
+		return u.salt, nil
+	}
+
+	key := "uv:salt:" + day
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate visitor salt: %w", err)
+	}
+	if _, err := u.redis.SetNX(key, hex.EncodeToString(buf), 48*time.Hour); err != nil {
+		return "", err
+	}
+
+	salt, err := u.redis.Get(key)
+	if err != nil {
+		return "", err
+	}
+
+	u.saltDay = day
+	u.salt = salt
+	return salt, nil
+}
+
+func lifetimeSketchKey(shortCode string) string {
+	return uniqueVisitorLifetimePrefix + shortCode
+}
+
+func dailySketchKey(shortCode, day string) string {
+	return uniqueVisitorDailyPrefix + shortCode + ":" + day
+}
+
+// parseSketchKey splits a sketch key into its short code and day (empty for
+// lifetime sketches), reporting false for keys that aren't sketch keys
+func parseSketchKey(key string) (shortCode, day string, ok bool) {
+	if shortCode, ok := strings.CutPrefix(key, uniqueVisitorLifetimePrefix); ok {
+		return shortCode, "", true
+	}
+	if rest, ok := strings.CutPrefix(key, uniqueVisitorDailyPrefix); ok {
+		if i := strings.LastIndex(rest, ":"); i > 0 {
+			return rest[:i], rest[i+1:], true
+		}
+	}
+	return "", "", false
+}
@@ -30,8 +30,8 @@ var (
 )
 
 type UserService struct {
-	db          *storage.PostgresStorage
-	redis       *storage.RedisStorage
+	db          storage.AccountStore
+	cache       storage.Cache
 	jwtService  *JWTService
 	smsService  *SMSService
 	emailService *EmailService
@@ -53,7 +53,7 @@ type UserRegistrationData struct {
 	TempPassword    string // For OAuth users
 }
 
-func NewUserService(db *storage.PostgresStorage, redis *storage.RedisStorage, 
+func NewUserService(db storage.AccountStore, cache storage.Cache, 
 	jwtService *JWTService, smsService *SMSService, emailService *EmailService, config *Config) *UserService {
 	
 	if config == nil {
@@ -68,7 +68,7 @@ func NewUserService(db *storage.PostgresStorage, redis *storage.RedisStorage,
 
 	return &UserService{
 		db:           db,
-		redis:        redis,
+		cache:        cache,
 		jwtService:   jwtService,
 		smsService:   smsService,
 		emailService: emailService,
@@ -158,34 +158,40 @@ func (u *UserService) CreateUser(req *models.RegisterRequest) (*UserRegistration
 	}, nil
 }
 
-// AuthenticateUser authenticates user with email and password
-func (u *UserService) AuthenticateUser(req *models.LoginRequest) (*models.User, string, error) {
+// AuthenticateUser checks a user's email and password. The user isn't logged
+// in until StartSession, which waits for any second factor.
+func (u *UserService) AuthenticateUser(req *models.LoginRequest) (*models.User, error) {
 	// Get user by email
 	user, err := u.GetUserByEmail(req.Email)
 	if err != nil {
-		return nil, "", ErrInvalidCredentials
+		return nil, ErrInvalidCredentials
 	}
 
 	// Check if account is active
 	if !user.IsActive {
-		return nil, "", ErrAccountNotActive
+		return nil, ErrAccountNotActive
 	}
 
 	// Check if user has password (for OAuth-only users)
 	if user.PasswordHash == nil {
-		return nil, "", errors.New("please use social login for this account")
+		return nil, errors.New("please use social login for this account")
 	}
 
 	// Verify password
 	err = bcrypt.CompareHashAndPassword([]byte(*user.PasswordHash), []byte(req.Password))
 	if err != nil {
-		return nil, "", ErrInvalidCredentials
+		return nil, ErrInvalidCredentials
 	}
 
-	// Create session
-	sessionID, err := u.CreateSession(user, req.RememberMe, "", "")
+	return user, nil
+}
+
+// StartSession logs in an authenticated user, creating their session and
+// recording the login time
+func (u *UserService) StartSession(user *models.User, rememberMe bool, ipAddress, userAgent string) (string, error) {
+	sessionID, err := u.CreateSession(user, rememberMe, ipAddress, userAgent)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to create session: %w", err)
+		return "", fmt.Errorf("failed to create session: %w", err)
 	}
 
 	// Update last login time
@@ -196,7 +202,7 @@ func (u *UserService) AuthenticateUser(req *models.LoginRequest) (*models.User,
 		fmt.Printf("Failed to update last login: %v\n", err)
 	}
 
-	return user, sessionID, nil
+	return sessionID, nil
 }
 
 // GetUserByID retrieves user by ID
@@ -470,7 +476,7 @@ func (u *UserService) ResetPassword(token, newPassword string) error {
 		return err
 	}
 
-	return nil
+	return u.revokeTokens(passwordReset.UserID)
 }
 
 // ChangePassword changes user password (requires current password)
@@ -497,7 +503,43 @@ func (u *UserService) ChangePassword(userID int64, currentPassword, newPassword
 	}
 
 	// Update password
-	return u.db.UpdateUserPassword(userID, string(hashedPassword))
+	if err := u.db.UpdateUserPassword(userID, string(hashedPassword)); err != nil {
+		return err
+	}
+
+	return u.revokeTokens(userID)
+}
+
+// SetPassword replaces a user's password without the current one, for
+// administrative resets
+func (u *UserService) SetPassword(userID int64, newPassword string) error {
+	if _, err := u.GetUserByID(userID); err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), u.config.BCryptCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	if err := u.db.UpdateUserPassword(userID, string(hashedPassword)); err != nil {
+		return err
+	}
+
+	return u.revokeTokens(userID)
+}
+
+// revokeTokens logs a user out of every device after their password changes
+// or their account is deactivated, so tokens issued before stop working
+func (u *UserService) revokeTokens(userID int64) error {
+	if u.jwtService == nil {
+		return nil
+	}
+
+	if err := u.jwtService.RevokeAllTokens(userID); err != nil {
+		return fmt.Errorf("failed to revoke existing tokens: %w", err)
+	}
+	return nil
 }
 
 // UpdateProfile updates user profile information
@@ -548,7 +590,11 @@ func (u *UserService) UpdateProfile(userID int64, req *models.UpdateProfileReque
 
 // DeactivateUser deactivates a user account
 func (u *UserService) DeactivateUser(userID int64) error {
-	return u.db.UpdateUserStatus(userID, false)
+	if err := u.db.UpdateUserStatus(userID, false); err != nil {
+		return err
+	}
+
+	return u.revokeTokens(userID)
 }
 
 // ActivateUser activates a user account
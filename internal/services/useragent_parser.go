@@ -7,6 +7,8 @@ import (
 	"strings"
 
 	"github.com/mssola/user_agent"
+
+	"github.com/URLshorter/url-shortener/internal/models"
 )
 
 // UserAgentService handles user agent string parsing
@@ -99,12 +101,8 @@ func (u *UserAgentService) DetectBot(userAgentString string) *BotInfo {
 		return nil
 	}
 
-	ua := user_agent.New(userAgentString)
-	if !ua.Bot() {
-		return nil
-	}
-
-	// Common bot patterns
+	// Common bot patterns, checked first because the parser misses some of
+	// them (Slackbot, for one)
 	botPatterns := map[string]*BotInfo{
 		"Googlebot":        {"Googlebot", "search_engine", "Google's web crawler"},
 		"Bingbot":          {"Bingbot", "search_engine", "Microsoft Bing's web crawler"},
@@ -118,6 +116,8 @@ func (u *UserAgentService) DetectBot(userAgentString string) *BotInfo {
 		"WhatsApp":         {"WhatsApp Bot", "social_media", "WhatsApp's link preview crawler"},
 		"TelegramBot":      {"TelegramBot", "social_media", "Telegram's link preview crawler"},
 		"DiscordBot":       {"DiscordBot", "social_media", "Discord's link preview crawler"},
+		"Slackbot":         {"Slackbot", "social_media", "Slack's link preview crawler"},
+		"Pinterestbot":     {"Pinterestbot", "social_media", "Pinterest's link preview crawler"},
 		"Applebot":         {"Applebot", "search_engine", "Apple's web crawler for Siri and Spotlight"},
 		"AhrefsBot":        {"AhrefsBot", "seo", "Ahrefs SEO tool crawler"},
 		"MJ12bot":          {"MJ12bot", "seo", "Majestic SEO crawler"},
@@ -140,6 +140,10 @@ func (u *UserAgentService) DetectBot(userAgentString string) *BotInfo {
 		}
 	}
 
+	if !user_agent.New(userAgentString).Bot() {
+		return nil
+	}
+
 	// Generic bot detection
 	return &BotInfo{
 		Name:        "Unknown Bot",
@@ -148,6 +152,67 @@ func (u *UserAgentService) DetectBot(userAgentString string) *BotInfo {
 	}
 }
 
+// previewUserAgents identify link unfurlers that fetch a URL to render a preview
+var previewUserAgents = []string{
+	"slackbot-linkexpanding", "slack-imgproxy", "slackbot", "twitterbot", "facebookexternalhit",
+	"facebot", "linkedinbot", "whatsapp", "telegrambot", "discordbot", "skypeuripreview",
+	"pinterestbot", "redditbot", "embedly", "iframely", "vkshare", "bingpreview",
+	"google-pagerenderer", "mattermost-bot",
+}
+
+// scannerUserAgents identify security products and email gateways that
+// follow links to check them before the recipient does
+var scannerUserAgents = []string{
+	"urlscan", "virustotal", "safebrowsing", "google-safety", "proofpoint", "mimecast",
+	"barracuda", "forcepoint", "trendmicro", "symantec", "bitdefender", "kaspersky",
+	"sophos", "fortiguard", "paloalto", "checkpoint", "zscaler", "netcraft", "phishtank",
+	"microsoft office protocol discovery", "safelinks",
+}
+
+// genericBotMarkers catch automated clients not recognised by the parser
+var genericBotMarkers = []string{
+	"bot", "crawler", "spider", "headlesschrome", "phantomjs", "curl/", "wget/",
+	"python-requests", "python-urllib", "go-http-client", "java/", "okhttp", "axios/",
+	"node-fetch", "libwww-perl", "httpclient",
+}
+
+// ClassifyTraffic classifies a click's user agent as human, bot, preview or scanner
+func (u *UserAgentService) ClassifyTraffic(userAgentString string) string {
+	if strings.TrimSpace(userAgentString) == "" {
+		return models.TrafficClassBot
+	}
+
+	userAgentLower := strings.ToLower(userAgentString)
+
+	// Scanners often embed browser tokens, so check them before anything else
+	for _, pattern := range scannerUserAgents {
+		if strings.Contains(userAgentLower, pattern) {
+			return models.TrafficClassScanner
+		}
+	}
+
+	for _, pattern := range previewUserAgents {
+		if strings.Contains(userAgentLower, pattern) {
+			return models.TrafficClassPreview
+		}
+	}
+
+	if botInfo := u.DetectBot(userAgentString); botInfo != nil {
+		if botInfo.Type == "social_media" {
+			return models.TrafficClassPreview
+		}
+		return models.TrafficClassBot
+	}
+
+	for _, marker := range genericBotMarkers {
+		if strings.Contains(userAgentLower, marker) {
+			return models.TrafficClassBot
+		}
+	}
+
+	return models.TrafficClassHuman
+}
+
 // Helper methods
 
 func (u *UserAgentService) getDeviceType(ua *user_agent.UserAgent, userAgentString string) string {
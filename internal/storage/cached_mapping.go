@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"encoding/json"
+
+	"github.com/URLshorter/url-shortener/internal/models"
+)
+
+// cachedURLMapping is a URL mapping as caches store it. Redirects need the
+// password hash, which the mapping's own JSON leaves out so it never reaches
+// API responses.
+type cachedURLMapping struct {
+	*models.URLMapping
+	PasswordHash string `json:"password_hash,omitempty"`
+}
+
+// marshalURLMapping serializes a mapping for a cache
+func marshalURLMapping(mapping *models.URLMapping) ([]byte, error) {
+	return json.Marshal(cachedURLMapping{URLMapping: mapping, PasswordHash: mapping.PasswordHash})
+}
+
+// unmarshalURLMapping restores a mapping serialized by marshalURLMapping
+func unmarshalURLMapping(data []byte) (*models.URLMapping, error) {
+	cached := cachedURLMapping{URLMapping: &models.URLMapping{}}
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return nil, err
+	}
+	cached.URLMapping.PasswordHash = cached.PasswordHash
+	return cached.URLMapping, nil
+}
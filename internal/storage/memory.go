@@ -0,0 +1,1581 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/URLshorter/url-shortener/internal/models"
+)
+
+// MemoryStorage is an in-process Store for tests and single-binary dev mode.
+// It mirrors the Postgres backend's semantics, including ErrURLNotFound for
+// missing links and sql.ErrNoRows for missing account records, but keeps
+// nothing across restarts.
+type MemoryStorage struct {
+	mu sync.RWMutex
+
+	links      map[string]*memoryLink
+	history    map[string][]models.DestinationHistoryEntry
+	historySeq int64
+	clicks     []models.ClickEvent
+	clickIDs   map[int64]struct{}
+
+	importJobs map[int64]*models.BulkImportJob
+	importRows map[int64][]*models.BulkImportRow
+
+	blocklist    []*models.BlocklistEntry
+	blocklistSeq int64
+
+	users              map[int64]*models.User
+	preferences        map[int64]*models.UserPreferences
+	sessions           map[string]*models.UserSession
+	emailVerifications map[string]*models.EmailVerification
+	phoneVerifications map[string]*models.PhoneVerification
+	passwordResets     map[string]*models.PasswordReset
+	twoFactor          map[int64]*models.TwoFactor
+	recoveryCodes      map[int64]map[string]bool // code hash -> used
+}
+
+// memoryLink is a url_mappings row: the mapping plus its dashboard columns
+type memoryLink struct {
+	mapping              models.URLMapping
+	title                *string
+	description          *string
+	customAlias          *string
+	isPublic             bool
+	tags                 []string
+	metadata             *models.LinkMetadata
+	metadataFetchedAt    time.Time
+	safetyCheckedAt      time.Time
+	updatedAt            time.Time
+	destinationUpdatedAt time.Time
+}
+
+// NewMemoryStorage creates an empty in-memory store
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		links:              make(map[string]*memoryLink),
+		history:            make(map[string][]models.DestinationHistoryEntry),
+		clickIDs:           make(map[int64]struct{}),
+		importJobs:         make(map[int64]*models.BulkImportJob),
+		importRows:         make(map[int64][]*models.BulkImportRow),
+		users:              make(map[int64]*models.User),
+		preferences:        make(map[int64]*models.UserPreferences),
+		sessions:           make(map[string]*models.UserSession),
+		emailVerifications: make(map[string]*models.EmailVerification),
+		phoneVerifications: make(map[string]*models.PhoneVerification),
+		passwordResets:     make(map[string]*models.PasswordReset),
+		twoFactor:          make(map[int64]*models.TwoFactor),
+		recoveryCodes:      make(map[int64]map[string]bool),
+	}
+}
+
+// Close releases nothing; it exists to satisfy Store
+func (m *MemoryStorage) Close() error {
+	return nil
+}
+
+// activeLink returns an active link, optionally restricted to an owner. Callers hold mu.
+func (m *MemoryStorage) activeLink(shortCode string, userID *int64) *memoryLink {
+	link, ok := m.links[shortCode]
+	if !ok || !link.mapping.IsActive {
+		return nil
+	}
+	if userID != nil && (link.mapping.UserID == nil || *link.mapping.UserID != *userID) {
+		return nil
+	}
+	return link
+}
+
+// userURL converts a link to its dashboard listing shape
+func (l *memoryLink) userURL() *models.UserURLResponse {
+	url := &models.UserURLResponse{
+		ID:                 l.mapping.ID,
+		ShortCode:          l.mapping.ShortCode,
+		OriginalURL:        l.mapping.OriginalURL,
+		ClickCount:         l.mapping.ClickCount,
+		IsActive:           l.mapping.IsActive,
+		IsPublic:           l.isPublic,
+		CreatedAt:          l.mapping.CreatedAt,
+		ExpiresAt:          l.mapping.ExpiresAt,
+		RedirectType:       l.mapping.RedirectType,
+		DestinationVersion: l.mapping.DestinationVersion,
+		RedirectRules:      l.mapping.RedirectRules,
+		PasswordProtected:  l.mapping.IsPasswordProtected(),
+		StartsAt:           l.mapping.StartsAt,
+		MaxClicks:          l.mapping.MaxClicks,
+		FallbackURL:        l.mapping.FallbackURL,
+		SocialPreview:      l.mapping.SocialPreview,
+		QuarantinedAt:      l.mapping.QuarantinedAt,
+		QuarantineReason:   l.mapping.QuarantineReason,
+	}
+	if l.title != nil && *l.title != "" {
+		url.Title = l.title
+	}
+	if l.description != nil && *l.description != "" {
+		url.Description = l.description
+	}
+	if len(l.tags) > 0 {
+		url.Tags = append(models.Tags(nil), l.tags...)
+	}
+	if l.metadata != nil {
+		url.ImageURL = nonEmpty(l.metadata.ImageURL)
+		url.FaviconURL = nonEmpty(l.metadata.FaviconURL)
+		url.SiteName = nonEmpty(l.metadata.SiteName)
+		fetchedAt := l.metadataFetchedAt
+		url.MetadataFetchedAt = &fetchedAt
+	}
+	return url
+}
+
+// nonEmpty returns a pointer to s, or nil when s is empty
+func nonEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// SaveURLMapping stores a new URL mapping
+func (m *MemoryStorage) SaveURLMapping(mapping *models.URLMapping) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.links[mapping.ShortCode]; exists {
+		return fmt.Errorf("failed to save URL mapping: short code %q already exists", mapping.ShortCode)
+	}
+
+	stored := *mapping
+	if stored.RedirectType == "" {
+		stored.RedirectType = models.DefaultRedirectType
+	}
+	stored.ClickCount = 0
+	stored.IsActive = true
+	stored.DestinationVersion = 1
+	if stored.SocialPreview != nil {
+		preview := *stored.SocialPreview
+		stored.SocialPreview = &preview
+	}
+
+	m.links[mapping.ShortCode] = &memoryLink{
+		mapping:              stored,
+		isPublic:             true,
+		updatedAt:            stored.CreatedAt,
+		destinationUpdatedAt: stored.CreatedAt,
+	}
+	return nil
+}
+
+// GetURLMappingByShortCode retrieves an active URL mapping that can currently be resolved
+func (m *MemoryStorage) GetURLMappingByShortCode(shortCode string) (*models.URLMapping, error) {
+	m.mu.RLock()
+	link := m.activeLink(shortCode, nil)
+	var mapping models.URLMapping
+	if link != nil {
+		mapping = link.mapping
+	}
+	m.mu.RUnlock()
+
+	if link == nil {
+		return nil, ErrURLNotFound
+	}
+	if mapping.ExpiresAt != nil && mapping.ExpiresAt.Before(time.Now()) {
+		return nil, ErrURLExpired
+	}
+	if mapping.StartsAt != nil && mapping.StartsAt.After(time.Now()) {
+		return nil, ErrURLNotYetActive
+	}
+	return &mapping, nil
+}
+
+// LookupURLMapping retrieves a URL mapping whatever its state, including
+// deleted, expired and scheduled links
+func (m *MemoryStorage) LookupURLMapping(shortCode string) (*models.URLMapping, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	link, ok := m.links[shortCode]
+	if !ok {
+		return nil, ErrURLNotFound
+	}
+	mapping := link.mapping
+	return &mapping, nil
+}
+
+// ShortCodeExists reports whether a short code has ever been used, including deleted links
+func (m *MemoryStorage) ShortCodeExists(shortCode string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, exists := m.links[shortCode]
+	return exists, nil
+}
+
+// GetClickCount returns the persisted click count for a URL mapping
+func (m *MemoryStorage) GetClickCount(shortCode string) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	link, ok := m.links[shortCode]
+	if !ok {
+		return 0, ErrURLNotFound
+	}
+	return link.mapping.ClickCount, nil
+}
+
+// CountHumanClicks counts a link's recorded human clicks
+func (m *MemoryStorage) CountHumanClicks(shortCode string) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return int64(len(m.linkClicks(shortCode, false))), nil
+}
+
+// GetURL retrieves an active link with its dashboard metadata
+func (m *MemoryStorage) GetURL(shortCode string) (*models.URL, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	link := m.activeLink(shortCode, nil)
+	if link == nil {
+		return nil, ErrURLNotFound
+	}
+	return &models.URL{
+		ID:          link.mapping.ID,
+		ShortCode:   link.mapping.ShortCode,
+		OriginalURL: link.mapping.OriginalURL,
+		Title:       link.title,
+		Description: link.description,
+		CreatedBy:   link.mapping.UserID,
+		ClickCount:  link.mapping.ClickCount,
+		IsActive:    link.mapping.IsActive,
+		IsPublic:    link.isPublic,
+		CustomAlias: link.customAlias,
+		CreatedAt:   link.mapping.CreatedAt,
+		UpdatedAt:   link.updatedAt,
+		ExpiresAt:   link.mapping.ExpiresAt,
+	}, nil
+}
+
+// GetUserURL retrieves an active link as listed on a user's dashboard
+func (m *MemoryStorage) GetUserURL(shortCode string) (*models.UserURLResponse, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	link := m.activeLink(shortCode, nil)
+	if link == nil {
+		return nil, ErrURLNotFound
+	}
+	return link.userURL(), nil
+}
+
+// userLinks returns a user's active links, newest first. Callers hold mu.
+func (m *MemoryStorage) userLinks(userID int64) []*memoryLink {
+	var links []*memoryLink
+	for _, link := range m.links {
+		if m.activeLink(link.mapping.ShortCode, &userID) != nil {
+			links = append(links, link)
+		}
+	}
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].mapping.CreatedAt.After(links[j].mapping.CreatedAt)
+	})
+	return links
+}
+
+// ListUserURLs retrieves a page of a user's active links, newest first, with the total count
+func (m *MemoryStorage) ListUserURLs(userID int64, offset, limit int) ([]*models.UserURLResponse, int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	links := m.userLinks(userID)
+	var urls []*models.UserURLResponse
+	for i := offset; i < len(links) && i < offset+limit; i++ {
+		urls = append(urls, links[i].userURL())
+	}
+	return urls, int64(len(links)), nil
+}
+
+// ListUserShortCodes retrieves the short codes of a user's active links
+func (m *MemoryStorage) ListUserShortCodes(userID int64) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var shortCodes []string
+	for _, link := range m.userLinks(userID) {
+		shortCodes = append(shortCodes, link.mapping.ShortCode)
+	}
+	return shortCodes, nil
+}
+
+// UpdateURL applies the non-nil fields of update to a link owned by userID
+func (m *MemoryStorage) UpdateURL(shortCode string, userID int64, update *URLUpdate) error {
+	if update.IsEmpty() {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	link := m.activeLink(shortCode, &userID)
+	if link == nil {
+		return ErrURLNotFound
+	}
+
+	applyURLUpdate(link, update)
+	return nil
+}
+
+// applyURLUpdate applies a non-empty update to link; the caller holds the lock
+func applyURLUpdate(link *memoryLink, update *URLUpdate) {
+	if update.Title != nil {
+		title := *update.Title
+		link.title = &title
+	}
+	if update.Description != nil {
+		description := *update.Description
+		link.description = &description
+	}
+	if update.ExpiresAt != nil {
+		expiresAt := *update.ExpiresAt
+		link.mapping.ExpiresAt = &expiresAt
+	}
+	if update.IsPublic != nil {
+		link.isPublic = *update.IsPublic
+	}
+	if update.RedirectType != nil {
+		link.mapping.RedirectType = *update.RedirectType
+	}
+	if update.RedirectRules != nil {
+		link.mapping.RedirectRules = *update.RedirectRules
+	}
+	if update.SocialPreview != nil {
+		link.mapping.SocialPreview = nil
+		if !update.SocialPreview.IsEmpty() {
+			preview := *update.SocialPreview
+			link.mapping.SocialPreview = &preview
+		}
+	}
+	if update.StartsAt != nil {
+		if update.StartsAt.IsZero() {
+			link.mapping.StartsAt = nil
+		} else {
+			startsAt := *update.StartsAt
+			link.mapping.StartsAt = &startsAt
+		}
+	}
+	if update.MaxClicks != nil {
+		if *update.MaxClicks == 0 {
+			link.mapping.MaxClicks = nil
+		} else {
+			maxClicks := *update.MaxClicks
+			link.mapping.MaxClicks = &maxClicks
+		}
+	}
+	if update.FallbackURL != nil {
+		link.mapping.FallbackURL = *update.FallbackURL
+	}
+	if update.PasswordHash != nil {
+		link.mapping.PasswordHash = *update.PasswordHash
+	}
+	link.updatedAt = time.Now()
+}
+
+// DeactivateURL soft deletes a link owned by userID
+func (m *MemoryStorage) DeactivateURL(shortCode string, userID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	link := m.activeLink(shortCode, &userID)
+	if link == nil {
+		return ErrURLNotFound
+	}
+	link.mapping.IsActive = false
+	link.updatedAt = time.Now()
+	return nil
+}
+
+// SetURLActive enables or disables a link regardless of its owner
+func (m *MemoryStorage) SetURLActive(shortCode string, active bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	link, ok := m.links[shortCode]
+	if !ok {
+		return ErrURLNotFound
+	}
+	link.mapping.IsActive = active
+	link.updatedAt = time.Now()
+	return nil
+}
+
+// TransferURL makes userID the owner of a link
+func (m *MemoryStorage) TransferURL(shortCode string, userID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	link, ok := m.links[shortCode]
+	if !ok {
+		return ErrURLNotFound
+	}
+	link.mapping.UserID = &userID
+	link.updatedAt = time.Now()
+	return nil
+}
+
+// SetURLTags replaces a link's tags
+func (m *MemoryStorage) SetURLTags(shortCode string, tags []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	link, ok := m.links[shortCode]
+	if !ok {
+		return ErrURLNotFound
+	}
+	link.tags = append([]string(nil), tags...)
+	sort.Strings(link.tags)
+	return nil
+}
+
+// SaveLinkMetadata stores metadata fetched from a link's destination unless
+// the destination has changed since destinationVersion
+func (m *MemoryStorage) SaveLinkMetadata(shortCode string, destinationVersion int, metadata *models.LinkMetadata) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	link, ok := m.links[shortCode]
+	if !ok || link.mapping.DestinationVersion != destinationVersion {
+		return nil
+	}
+	if (link.title == nil || *link.title == "") && metadata.Title != "" {
+		title := metadata.Title
+		link.title = &title
+	}
+	if (link.description == nil || *link.description == "") && metadata.Description != "" {
+		description := metadata.Description
+		link.description = &description
+	}
+	stored := *metadata
+	link.metadata = &stored
+	link.metadataFetchedAt = time.Now()
+	return nil
+}
+
+// EachUserURL calls fn for each of a user's active links with its tags, oldest first
+func (m *MemoryStorage) EachUserURL(userID int64, fn func(*models.UserURLResponse) error) error {
+	m.mu.RLock()
+	links := m.userLinks(userID)
+	urls := make([]*models.UserURLResponse, len(links))
+	for i, link := range links {
+		urls[len(links)-1-i] = link.userURL()
+	}
+	m.mu.RUnlock()
+
+	for _, url := range urls {
+		if err := fn(url); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SearchUserURLs returns a page of a user's active links matching search.
+// Each word of the text must appear in the title, description or destination.
+func (m *MemoryStorage) SearchUserURLs(userID int64, search *URLSearch) ([]*models.UserURLResponse, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	terms := searchTerms(search.Text)
+	var matches []*models.UserURLResponse
+	for _, link := range m.userLinks(userID) {
+		url := link.userURL()
+		if !memorySearchMatches(search, url, link.tags, terms, now) {
+			continue
+		}
+		if search.After != nil && !memorySearchAfter(search, url) {
+			continue
+		}
+		matches = append(matches, url)
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if search.Descending {
+			return memorySearchLess(search, matches[j], matches[i])
+		}
+		return memorySearchLess(search, matches[i], matches[j])
+	})
+	if len(matches) > search.Limit {
+		matches = matches[:search.Limit]
+	}
+	return matches, nil
+}
+
+// memorySearchMatches reports whether a link passes the search filters
+func memorySearchMatches(search *URLSearch, url *models.UserURLResponse, tags, terms []string, now time.Time) bool {
+	if len(terms) > 0 {
+		document := strings.ToLower(url.OriginalURL)
+		if url.Title != nil {
+			document += " " + strings.ToLower(*url.Title)
+		}
+		if url.Description != nil {
+			document += " " + strings.ToLower(*url.Description)
+		}
+		for _, term := range terms {
+			if !strings.Contains(document, term) {
+				return false
+			}
+		}
+	}
+	for _, tag := range search.Tags {
+		i := sort.SearchStrings(tags, tag)
+		if i == len(tags) || tags[i] != tag {
+			return false
+		}
+	}
+	if search.Domain != "" && !matchesDomain(destinationHost(url.OriginalURL), search.Domain) {
+		return false
+	}
+	if search.CreatedFrom != nil && url.CreatedAt.Before(*search.CreatedFrom) {
+		return false
+	}
+	if search.CreatedTo != nil && !url.CreatedAt.Before(*search.CreatedTo) {
+		return false
+	}
+	expired := url.ExpiresAt != nil && !url.ExpiresAt.After(now)
+	if (search.State == models.URLStateActive && expired) || (search.State == models.URLStateExpired && !expired) {
+		return false
+	}
+	if search.MinClicks != nil && url.ClickCount < *search.MinClicks {
+		return false
+	}
+	if search.MaxClicks != nil && url.ClickCount > *search.MaxClicks {
+		return false
+	}
+	return true
+}
+
+// memorySearchLess orders links ascending by the sort field and then ID
+func memorySearchLess(search *URLSearch, a, b *models.UserURLResponse) bool {
+	switch search.Sort {
+	case models.URLSortClickCount:
+		if a.ClickCount != b.ClickCount {
+			return a.ClickCount < b.ClickCount
+		}
+	case models.URLSortTitle:
+		var titleA, titleB string
+		if a.Title != nil {
+			titleA = *a.Title
+		}
+		if b.Title != nil {
+			titleB = *b.Title
+		}
+		if titleA != titleB {
+			return titleA < titleB
+		}
+	default:
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+	}
+	return a.ID < b.ID
+}
+
+// memorySearchAfter reports whether a link sorts after the search cursor
+func memorySearchAfter(search *URLSearch, url *models.UserURLResponse) bool {
+	cursor := &models.UserURLResponse{ID: search.After.ID, CreatedAt: search.After.CreatedAt, ClickCount: search.After.ClickCount}
+	if search.After.Title != "" {
+		cursor.Title = &search.After.Title
+	}
+	if search.Descending {
+		return memorySearchLess(search, url, cursor)
+	}
+	return memorySearchLess(search, cursor, url)
+}
+
+// ChangeURLDestination points a user's short code at a new destination and
+// applies update, recording the previous destination in its history. Returns
+// the new version.
+func (m *MemoryStorage) ChangeURLDestination(shortCode string, userID int64, newURL string, update *URLUpdate) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	link := m.activeLink(shortCode, &userID)
+	if link == nil {
+		return 0, ErrURLNotFound
+	}
+
+	if !update.IsEmpty() {
+		applyURLUpdate(link, update)
+	}
+
+	// Nothing to record if the destination is unchanged
+	version := link.mapping.DestinationVersion
+	if link.mapping.OriginalURL == newURL {
+		return version, nil
+	}
+
+	now := time.Now()
+	m.historySeq++
+	replacedBy := userID
+	m.history[shortCode] = append(m.history[shortCode], models.DestinationHistoryEntry{
+		ID:          m.historySeq,
+		ShortCode:   shortCode,
+		Version:     version,
+		OriginalURL: link.mapping.OriginalURL,
+		ActiveFrom:  link.destinationUpdatedAt,
+		ReplacedAt:  now,
+		ReplacedBy:  &replacedBy,
+	})
+
+	link.mapping.OriginalURL = newURL
+	link.mapping.DestinationVersion = version + 1
+	link.destinationUpdatedAt = now
+	return version + 1, nil
+}
+
+// GetDestinationHistory retrieves prior destinations of a short code, newest first
+func (m *MemoryStorage) GetDestinationHistory(shortCode string) ([]models.DestinationHistoryEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entries := m.history[shortCode]
+	history := make([]models.DestinationHistoryEntry, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		history = append(history, entries[i])
+	}
+	return history, nil
+}
+
+// SaveClickEvents stores click events, skipping IDs that are already stored,
+// and returns the IDs of the newly stored events
+func (m *MemoryStorage) SaveClickEvents(events []*models.ClickEvent) ([]int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	inserted := make([]int64, 0, len(events))
+	for _, event := range events {
+		if _, exists := m.clickIDs[event.ID]; exists {
+			continue
+		}
+		stored := *event
+		stored.TrafficClass = trafficClass(event)
+		if stored.DestinationVersion <= 0 {
+			stored.DestinationVersion = 1
+		}
+		m.clicks = append(m.clicks, stored)
+		m.clickIDs[event.ID] = struct{}{}
+		inserted = append(inserted, event.ID)
+	}
+	return inserted, nil
+}
+
+// IncrementClickCounts applies coalesced click count increments
+func (m *MemoryStorage) IncrementClickCounts(counts map[string]int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for shortCode, n := range counts {
+		if link, ok := m.links[shortCode]; ok {
+			link.mapping.ClickCount += n
+		}
+	}
+	return nil
+}
+
+// linkClicks returns a link's click events, restricted to human traffic
+// unless includeBots is set. Callers hold mu.
+func (m *MemoryStorage) linkClicks(shortCode string, includeBots bool) []models.ClickEvent {
+	var clicks []models.ClickEvent
+	for _, click := range m.clicks {
+		if click.ShortCode != shortCode {
+			continue
+		}
+		if !includeBots && click.TrafficClass != models.TrafficClassHuman {
+			continue
+		}
+		clicks = append(clicks, click)
+	}
+	return clicks
+}
+
+// GetAnalytics retrieves analytics data for a short code.
+// Non-human clicks are excluded unless includeBots is set.
+func (m *MemoryStorage) GetAnalytics(shortCode string, days int, includeBots bool) (*models.AnalyticsResponse, error) {
+	mapping, err := m.GetURLMappingByShortCode(shortCode)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	clicks := m.linkClicks(shortCode, includeBots)
+	analytics := &models.AnalyticsResponse{
+		ShortCode:   mapping.ShortCode,
+		OriginalURL: mapping.OriginalURL,
+		TotalClicks: mapping.ClickCount,
+		CreatedAt:   mapping.CreatedAt,
+	}
+	if !includeBots {
+		analytics.TotalClicks = int64(len(clicks))
+	}
+
+	since := time.Now().AddDate(0, 0, -days)
+	daily := make(map[string]int64)
+	countries := make(map[string]int64)
+	for _, click := range clicks {
+		if click.Source == models.ClickSourceQR {
+			analytics.TotalScans++
+		}
+		if analytics.LastClickAt == nil || click.ClickedAt.After(*analytics.LastClickAt) {
+			clickedAt := click.ClickedAt
+			analytics.LastClickAt = &clickedAt
+		}
+		if !click.ClickedAt.Before(since) {
+			daily[click.ClickedAt.Format("2006-01-02")]++
+		}
+		if click.CountryCode != "" {
+			countries[click.CountryCode]++
+		}
+	}
+
+	for date, n := range daily {
+		analytics.DailyClicks = append(analytics.DailyClicks, models.DailyClick{Date: date, Clicks: n})
+	}
+	sort.Slice(analytics.DailyClicks, func(i, j int) bool {
+		return analytics.DailyClicks[i].Date > analytics.DailyClicks[j].Date
+	})
+
+	for code, n := range countries {
+		analytics.CountryStats = append(analytics.CountryStats, models.CountryStat{CountryCode: code, Clicks: n})
+	}
+	sort.Slice(analytics.CountryStats, func(i, j int) bool {
+		a, b := analytics.CountryStats[i], analytics.CountryStats[j]
+		if a.Clicks != b.Clicks {
+			return a.Clicks > b.Clicks
+		}
+		return a.CountryCode < b.CountryCode
+	})
+	if len(analytics.CountryStats) > 10 {
+		analytics.CountryStats = analytics.CountryStats[:10]
+	}
+
+	return analytics, nil
+}
+
+// GetDestinationStats breaks down clicks on a short code by the destination
+// version that was live when each click happened
+func (m *MemoryStorage) GetDestinationStats(shortCode string) ([]models.DestinationStat, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	link, ok := m.links[shortCode]
+	if !ok {
+		return nil, nil
+	}
+
+	clicksByVersion := make(map[int]int64)
+	for _, click := range m.clicks {
+		if click.ShortCode == shortCode {
+			clicksByVersion[click.DestinationVersion]++
+		}
+	}
+
+	var stats []models.DestinationStat
+	for _, entry := range m.history[shortCode] {
+		stats = append(stats, models.DestinationStat{
+			Version:     entry.Version,
+			OriginalURL: entry.OriginalURL,
+			Clicks:      clicksByVersion[entry.Version],
+		})
+	}
+	stats = append(stats, models.DestinationStat{
+		Version:     link.mapping.DestinationVersion,
+		OriginalURL: link.mapping.OriginalURL,
+		Clicks:      clicksByVersion[link.mapping.DestinationVersion],
+		IsCurrent:   true,
+	})
+	return stats, nil
+}
+
+// GetClickTrends groups a link's recent clicks by hour, day, week or month
+func (m *MemoryStorage) GetClickTrends(shortCode, period string, includeBots bool) ([]models.ClickTrend, error) {
+	now := time.Now()
+	var since time.Time
+	var bucket func(t time.Time) string
+
+	switch period {
+	case "hour":
+		since = now.Add(-24 * time.Hour)
+		bucket = func(t time.Time) string { return t.Format("2006-01-02 15:00:00") }
+	case "day":
+		since = now.AddDate(0, 0, -30)
+		bucket = func(t time.Time) string { return t.Format("2006-01-02") }
+	case "week":
+		since = now.AddDate(0, 0, -12*7)
+		bucket = func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%04d-%02d", year, week)
+		}
+	case "month":
+		since = now.AddDate(0, -12, 0)
+		bucket = func(t time.Time) string { return t.Format("2006-01") }
+	default:
+		return nil, fmt.Errorf("unsupported trend period %q", period)
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	counts := make(map[string]int64)
+	for _, click := range m.linkClicks(shortCode, includeBots) {
+		if !click.ClickedAt.Before(since) {
+			counts[bucket(click.ClickedAt)]++
+		}
+	}
+
+	trends := []models.ClickTrend{}
+	for p, n := range counts {
+		trends = append(trends, models.ClickTrend{Period: p, Clicks: n})
+	}
+	sort.Slice(trends, func(i, j int) bool { return trends[i].Period < trends[j].Period })
+	return trends, nil
+}
+
+// GetUserDashboardStats retrieves link and click totals across a user's active links
+func (m *MemoryStorage) GetUserDashboardStats(userID int64, includeBots bool) (*models.UserDashboardStats, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	links := m.userLinks(userID)
+	stats := &models.UserDashboardStats{
+		TotalURLs:  int64(len(links)),
+		ActiveURLs: int64(len(links)),
+	}
+
+	now := time.Now()
+	today := now.Format("2006-01-02")
+	month := now.Format("2006-01")
+	for _, link := range links {
+		clicks := m.linkClicks(link.mapping.ShortCode, includeBots)
+		// click_count includes every click, so human-only totals come from the events
+		if includeBots {
+			stats.TotalClicks += link.mapping.ClickCount
+		} else {
+			stats.TotalClicks += int64(len(clicks))
+		}
+		for _, click := range clicks {
+			if click.ClickedAt.Format("2006-01-02") == today {
+				stats.TodayClicks++
+			}
+			if click.ClickedAt.Format("2006-01") == month {
+				stats.MonthClicks++
+			}
+		}
+	}
+
+	return stats, nil
+}
+
+// CreateImportJob saves a job together with its rows
+func (m *MemoryStorage) CreateImportJob(job *models.BulkImportJob, rows []*models.BulkImportRow) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.importJobs[job.ID]; exists {
+		return fmt.Errorf("failed to save import job: job %d already exists", job.ID)
+	}
+	for _, existing := range m.importJobs {
+		if existing.UserID == job.UserID && existing.Checksum == job.Checksum {
+			return fmt.Errorf("failed to save import job: file already imported as job %d", existing.ID)
+		}
+	}
+
+	stored := *job
+	m.importJobs[job.ID] = &stored
+	m.importRows[job.ID] = make([]*models.BulkImportRow, 0, len(rows))
+	for _, row := range rows {
+		storedRow := *row
+		storedRow.JobID = job.ID
+		m.importRows[job.ID] = append(m.importRows[job.ID], &storedRow)
+	}
+	sort.Slice(m.importRows[job.ID], func(i, j int) bool {
+		return m.importRows[job.ID][i].Line < m.importRows[job.ID][j].Line
+	})
+	return nil
+}
+
+// GetImportJob retrieves an import job by ID
+func (m *MemoryStorage) GetImportJob(id int64) (*models.BulkImportJob, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	job, ok := m.importJobs[id]
+	if !ok {
+		return nil, ErrImportJobNotFound
+	}
+	copied := *job
+	return &copied, nil
+}
+
+// GetImportJobByChecksum retrieves a user's import job for a file
+func (m *MemoryStorage) GetImportJobByChecksum(userID int64, checksum string) (*models.BulkImportJob, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, job := range m.importJobs {
+		if job.UserID == userID && job.Checksum == checksum {
+			copied := *job
+			return &copied, nil
+		}
+	}
+	return nil, ErrImportJobNotFound
+}
+
+// ListUnfinishedImportJobs retrieves pending and running jobs, oldest first
+func (m *MemoryStorage) ListUnfinishedImportJobs() ([]*models.BulkImportJob, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var jobs []*models.BulkImportJob
+	for _, job := range m.importJobs {
+		if !job.IsFinished() {
+			copied := *job
+			jobs = append(jobs, &copied)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+	return jobs, nil
+}
+
+// UpdateImportJob saves a job's status and progress
+func (m *MemoryStorage) UpdateImportJob(job *models.BulkImportJob) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.importJobs[job.ID]; !ok {
+		return ErrImportJobNotFound
+	}
+	stored := *job
+	m.importJobs[job.ID] = &stored
+	return nil
+}
+
+// ListImportRows returns up to limit rows of a job after the given line
+func (m *MemoryStorage) ListImportRows(jobID int64, status string, afterLine, limit int) ([]*models.BulkImportRow, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var rows []*models.BulkImportRow
+	for _, row := range m.importRows[jobID] {
+		if len(rows) >= limit {
+			break
+		}
+		if row.Line <= afterLine || (status != "" && row.Status != status) {
+			continue
+		}
+		copied := *row
+		rows = append(rows, &copied)
+	}
+	return rows, nil
+}
+
+// UpdateImportRows records the outcome of processed rows
+func (m *MemoryStorage) UpdateImportRows(rows []*models.BulkImportRow) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, update := range rows {
+		for _, row := range m.importRows[update.JobID] {
+			if row.Line == update.Line {
+				row.Status, row.ShortCode, row.Error = update.Status, update.ShortCode, update.Error
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// AddBlocklistEntry stores a blocked domain or URL pattern
+func (m *MemoryStorage) AddBlocklistEntry(entry *models.BlocklistEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.blocklist {
+		if existing.Kind == entry.Kind && existing.Pattern == entry.Pattern {
+			return ErrBlocklistEntryExists
+		}
+	}
+
+	m.blocklistSeq++
+	entry.ID = m.blocklistSeq
+	entry.CreatedAt = time.Now()
+	stored := *entry
+	m.blocklist = append(m.blocklist, &stored)
+	return nil
+}
+
+// DeleteBlocklistEntry removes a blocklist entry
+func (m *MemoryStorage) DeleteBlocklistEntry(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, entry := range m.blocklist {
+		if entry.ID == id {
+			m.blocklist = append(m.blocklist[:i], m.blocklist[i+1:]...)
+			return nil
+		}
+	}
+	return ErrBlocklistEntryNotFound
+}
+
+// ListBlocklistEntries returns the whole blocklist, oldest first
+func (m *MemoryStorage) ListBlocklistEntries() ([]*models.BlocklistEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entries := make([]*models.BlocklistEntry, 0, len(m.blocklist))
+	for _, entry := range m.blocklist {
+		copied := *entry
+		entries = append(entries, &copied)
+	}
+	return entries, nil
+}
+
+// QuarantineURL marks a link as quarantined. A link that is already
+// quarantined keeps its original time and reason.
+func (m *MemoryStorage) QuarantineURL(shortCode, reason string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	link, ok := m.links[shortCode]
+	if !ok {
+		return ErrURLNotFound
+	}
+	if link.mapping.QuarantinedAt == nil {
+		now := time.Now()
+		link.mapping.QuarantinedAt = &now
+		link.mapping.QuarantineReason = reason
+	}
+	return nil
+}
+
+// ReleaseURL lifts a link's quarantine
+func (m *MemoryStorage) ReleaseURL(shortCode string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	link, ok := m.links[shortCode]
+	if !ok {
+		return ErrURLNotFound
+	}
+	link.mapping.QuarantinedAt = nil
+	link.mapping.QuarantineReason = ""
+	link.safetyCheckedAt = time.Now()
+	return nil
+}
+
+// ListURLsForSafetyCheck returns active, unquarantined links due for screening
+func (m *MemoryStorage) ListURLsForSafetyCheck(checkedBefore time.Time, limit int) ([]*models.URLMapping, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var due []*memoryLink
+	for _, link := range m.links {
+		if link.mapping.IsActive && link.mapping.QuarantinedAt == nil && link.safetyCheckedAt.Before(checkedBefore) {
+			due = append(due, link)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].safetyCheckedAt.Equal(due[j].safetyCheckedAt) {
+			return due[i].safetyCheckedAt.Before(due[j].safetyCheckedAt)
+		}
+		return due[i].mapping.ID < due[j].mapping.ID
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	mappings := make([]*models.URLMapping, 0, len(due))
+	for _, link := range due {
+		mapping := link.mapping
+		mappings = append(mappings, &mapping)
+	}
+	return mappings, nil
+}
+
+// MarkURLSafetyChecked records when a link was last screened
+func (m *MemoryStorage) MarkURLSafetyChecked(shortCode string, checkedAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if link, ok := m.links[shortCode]; ok {
+		link.safetyCheckedAt = checkedAt
+	}
+	return nil
+}
+
+// CreateUser stores a new user, rejecting duplicate IDs and emails
+func (m *MemoryStorage) CreateUser(user *models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.users[user.ID]; exists {
+		return fmt.Errorf("user %d already exists", user.ID)
+	}
+	for _, existing := range m.users {
+		if existing.Email == user.Email {
+			return fmt.Errorf("user with email %s already exists", user.Email)
+		}
+	}
+	stored := *user
+	m.users[user.ID] = &stored
+	return nil
+}
+
+// findUser returns a copy of the first user matching match, or sql.ErrNoRows
+func (m *MemoryStorage) findUser(match func(*models.User) bool) (*models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, user := range m.users {
+		if match(user) {
+			found := *user
+			return &found, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// GetUserByID retrieves a user by ID
+func (m *MemoryStorage) GetUserByID(id int64) (*models.User, error) {
+	return m.findUser(func(u *models.User) bool { return u.ID == id })
+}
+
+// GetUserByEmail retrieves a user by email
+func (m *MemoryStorage) GetUserByEmail(email string) (*models.User, error) {
+	return m.findUser(func(u *models.User) bool { return u.Email == email })
+}
+
+// GetUserByPhone retrieves a user by phone
+func (m *MemoryStorage) GetUserByPhone(phone string) (*models.User, error) {
+	return m.findUser(func(u *models.User) bool { return u.Phone != nil && *u.Phone == phone })
+}
+
+// updateUser applies change to a stored user and bumps updated_at; missing users are ignored
+func (m *MemoryStorage) updateUser(userID int64, change func(*models.User)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if user, ok := m.users[userID]; ok {
+		change(user)
+		user.UpdatedAt = time.Now()
+	}
+	return nil
+}
+
+// UpdateUser updates user information
+func (m *MemoryStorage) UpdateUser(user *models.User) error {
+	return m.updateUser(user.ID, func(u *models.User) {
+		u.Name = user.Name
+		u.Email = user.Email
+		u.Phone = user.Phone
+		u.Provider = user.Provider
+		u.ProviderID = user.ProviderID
+		u.AvatarURL = user.AvatarURL
+		u.AccountType = user.AccountType
+	})
+}
+
+// UpdateUserLastLogin updates user's last login time
+func (m *MemoryStorage) UpdateUserLastLogin(userID int64, loginTime time.Time) error {
+	return m.updateUser(userID, func(u *models.User) { u.LastLoginAt = &loginTime })
+}
+
+// UpdateUserPassword updates user password
+func (m *MemoryStorage) UpdateUserPassword(userID int64, passwordHash string) error {
+	return m.updateUser(userID, func(u *models.User) { u.PasswordHash = &passwordHash })
+}
+
+// UpdateUserStatus updates user status (active/inactive)
+func (m *MemoryStorage) UpdateUserStatus(userID int64, isActive bool) error {
+	return m.updateUser(userID, func(u *models.User) { u.IsActive = isActive })
+}
+
+// UpdateUserEmailVerified updates user email verification status
+func (m *MemoryStorage) UpdateUserEmailVerified(userID int64, verified bool) error {
+	return m.updateUser(userID, func(u *models.User) { u.EmailVerified = verified })
+}
+
+// UpdateUserPhoneVerified updates user phone verification status
+func (m *MemoryStorage) UpdateUserPhoneVerified(userID int64, verified bool) error {
+	return m.updateUser(userID, func(u *models.User) { u.PhoneVerified = verified })
+}
+
+// CreateUserPreferences creates user preferences
+func (m *MemoryStorage) CreateUserPreferences(prefs *models.UserPreferences) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.preferences[prefs.UserID]; exists {
+		return fmt.Errorf("preferences for user %d already exist", prefs.UserID)
+	}
+	stored := *prefs
+	m.preferences[prefs.UserID] = &stored
+	return nil
+}
+
+// GetUserPreferences retrieves user preferences
+func (m *MemoryStorage) GetUserPreferences(userID int64) (*models.UserPreferences, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	prefs, ok := m.preferences[userID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	found := *prefs
+	return &found, nil
+}
+
+// UpdateUserPreferences updates user preferences
+func (m *MemoryStorage) UpdateUserPreferences(prefs *models.UserPreferences) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.preferences[prefs.UserID]; ok {
+		stored := *prefs
+		m.preferences[prefs.UserID] = &stored
+	}
+	return nil
+}
+
+// GetTwoFactor returns a user's two-factor settings
+func (m *MemoryStorage) GetTwoFactor(userID int64) (*models.TwoFactor, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if twoFactor, ok := m.twoFactor[userID]; ok {
+		found := *twoFactor
+		return &found, nil
+	}
+	return &models.TwoFactor{UserID: userID}, nil
+}
+
+// SaveTwoFactorSecret stores a secret awaiting confirmation
+func (m *MemoryStorage) SaveTwoFactorSecret(userID int64, secret string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	twoFactor := m.twoFactorLocked(userID)
+	if twoFactor.Enabled() {
+		return ErrTwoFactorEnabled
+	}
+	twoFactor.Secret = secret
+	twoFactor.LastUsedStep = 0
+	return nil
+}
+
+// EnableTwoFactor confirms the stored secret and replaces the recovery codes
+func (m *MemoryStorage) EnableTwoFactor(userID int64, step int64, recoveryCodeHashes []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	twoFactor := m.twoFactorLocked(userID)
+	if twoFactor.Secret == "" || twoFactor.Enabled() {
+		return ErrTwoFactorNotPending
+	}
+	now := time.Now()
+	twoFactor.EnabledAt = &now
+	twoFactor.LastUsedStep = step
+	m.replaceRecoveryCodesLocked(userID, recoveryCodeHashes)
+	return nil
+}
+
+// DisableTwoFactor removes the secret and recovery codes
+func (m *MemoryStorage) DisableTwoFactor(userID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if twoFactor, ok := m.twoFactor[userID]; ok {
+		twoFactor.Secret = ""
+		twoFactor.EnabledAt = nil
+		twoFactor.LastUsedStep = 0
+	}
+	delete(m.recoveryCodes, userID)
+	return nil
+}
+
+// SetTwoFactorRequired sets whether a user must use two-factor
+func (m *MemoryStorage) SetTwoFactorRequired(userID int64, required bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.twoFactorLocked(userID).Required = required
+	return nil
+}
+
+// UseTwoFactorStep records the time step of an accepted code
+func (m *MemoryStorage) UseTwoFactorStep(userID int64, step int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	twoFactor, ok := m.twoFactor[userID]
+	if !ok || twoFactor.LastUsedStep >= step {
+		return false, nil
+	}
+	twoFactor.LastUsedStep = step
+	return true, nil
+}
+
+// ReplaceRecoveryCodes swaps a user's recovery codes for new ones
+func (m *MemoryStorage) ReplaceRecoveryCodes(userID int64, codeHashes []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.replaceRecoveryCodesLocked(userID, codeHashes)
+	return nil
+}
+
+// UseRecoveryCode marks an unused recovery code as used
+func (m *MemoryStorage) UseRecoveryCode(userID int64, codeHash string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	used, ok := m.recoveryCodes[userID][codeHash]
+	if !ok || used {
+		return false, nil
+	}
+	m.recoveryCodes[userID][codeHash] = true
+	return true, nil
+}
+
+// CountRecoveryCodes returns how many unused recovery codes a user has left
+func (m *MemoryStorage) CountRecoveryCodes(userID int64) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	count := 0
+	for _, used := range m.recoveryCodes[userID] {
+		if !used {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// twoFactorLocked returns a user's stored settings, creating them if needed.
+// The caller must hold the write lock.
+func (m *MemoryStorage) twoFactorLocked(userID int64) *models.TwoFactor {
+	twoFactor, ok := m.twoFactor[userID]
+	if !ok {
+		twoFactor = &models.TwoFactor{UserID: userID}
+		m.twoFactor[userID] = twoFactor
+	}
+	return twoFactor
+}
+
+// replaceRecoveryCodesLocked swaps a user's recovery codes. The caller must
+// hold the write lock.
+func (m *MemoryStorage) replaceRecoveryCodesLocked(userID int64, codeHashes []string) {
+	codes := make(map[string]bool, len(codeHashes))
+	for _, hash := range codeHashes {
+		codes[hash] = false
+	}
+	m.recoveryCodes[userID] = codes
+}
+
+// CreateSession creates a new user session
+func (m *MemoryStorage) CreateSession(session *models.UserSession) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := *session
+	m.sessions[session.ID.String()] = &stored
+	return nil
+}
+
+// GetSessionByID retrieves a session by ID
+func (m *MemoryStorage) GetSessionByID(id string) (*models.UserSession, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	session, ok := m.sessions[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	found := *session
+	return &found, nil
+}
+
+// CreateEmailVerification creates an email verification record
+func (m *MemoryStorage) CreateEmailVerification(verification *models.EmailVerification) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := *verification
+	m.emailVerifications[verification.ID.String()] = &stored
+	return nil
+}
+
+// GetEmailVerificationByToken retrieves email verification by token
+func (m *MemoryStorage) GetEmailVerificationByToken(token string) (*models.EmailVerification, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, verification := range m.emailVerifications {
+		if verification.Token == token {
+			found := *verification
+			return &found, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// GetLatestEmailVerification retrieves the most recent email verification of a user
+func (m *MemoryStorage) GetLatestEmailVerification(userID int64) (*models.EmailVerification, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var latest *models.EmailVerification
+	for _, verification := range m.emailVerifications {
+		if verification.UserID == userID && (latest == nil || verification.CreatedAt.After(latest.CreatedAt)) {
+			latest = verification
+		}
+	}
+	if latest == nil {
+		return nil, sql.ErrNoRows
+	}
+	found := *latest
+	return &found, nil
+}
+
+// UpdateEmailVerification updates email verification record
+func (m *MemoryStorage) UpdateEmailVerification(id string, verifiedAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if verification, ok := m.emailVerifications[id]; ok {
+		verification.VerifiedAt = &verifiedAt
+	}
+	return nil
+}
+
+// InvalidateEmailVerifications closes all pending email verifications of a user
+func (m *MemoryStorage) InvalidateEmailVerifications(userID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for _, verification := range m.emailVerifications {
+		if verification.UserID == userID && verification.VerifiedAt == nil {
+			verifiedAt := now
+			verification.VerifiedAt = &verifiedAt
+		}
+	}
+	return nil
+}
+
+// CreatePhoneVerification creates a phone verification record
+func (m *MemoryStorage) CreatePhoneVerification(verification *models.PhoneVerification) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := *verification
+	m.phoneVerifications[verification.ID.String()] = &stored
+	return nil
+}
+
+// GetPhoneVerificationByUserID retrieves the latest phone verification of a user
+func (m *MemoryStorage) GetPhoneVerificationByUserID(userID int64) (*models.PhoneVerification, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var latest *models.PhoneVerification
+	for _, verification := range m.phoneVerifications {
+		if verification.UserID == userID && (latest == nil || verification.CreatedAt.After(latest.CreatedAt)) {
+			latest = verification
+		}
+	}
+	if latest == nil {
+		return nil, sql.ErrNoRows
+	}
+	found := *latest
+	return &found, nil
+}
+
+// UpdatePhoneVerificationAttempts updates phone verification attempts
+func (m *MemoryStorage) UpdatePhoneVerificationAttempts(userID int64, attempts int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, verification := range m.phoneVerifications {
+		if verification.UserID == userID {
+			verification.Attempts = attempts
+		}
+	}
+	return nil
+}
+
+// UpdatePhoneVerification updates phone verification record
+func (m *MemoryStorage) UpdatePhoneVerification(id string, verifiedAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if verification, ok := m.phoneVerifications[id]; ok {
+		verification.VerifiedAt = &verifiedAt
+	}
+	return nil
+}
+
+// DeletePhoneVerificationByUserID deletes phone verification by user ID
+func (m *MemoryStorage) DeletePhoneVerificationByUserID(userID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, verification := range m.phoneVerifications {
+		if verification.UserID == userID {
+			delete(m.phoneVerifications, id)
+		}
+	}
+	return nil
+}
+
+// CreatePasswordReset creates a password reset record
+func (m *MemoryStorage) CreatePasswordReset(reset *models.PasswordReset) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := *reset
+	m.passwordResets[reset.ID.String()] = &stored
+	return nil
+}
+
+// GetPasswordResetByToken retrieves password reset by token
+func (m *MemoryStorage) GetPasswordResetByToken(token string) (*models.PasswordReset, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, reset := range m.passwordResets {
+		if reset.Token == token {
+			found := *reset
+			return &found, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// UpdatePasswordReset updates password reset record
+func (m *MemoryStorage) UpdatePasswordReset(id string, usedAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if reset, ok := m.passwordResets[id]; ok {
+		reset.UsedAt = &usedAt
+	}
+	return nil
+}
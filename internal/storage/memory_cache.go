@@ -0,0 +1,323 @@
+package storage
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/URLshorter/url-shortener/internal/models"
+)
+
+// MemoryCache is an in-process Cache with the same key layout and expiry
+// semantics as RedisStorage. Bounded caches evict the least recently used
+// key once they hold capacity keys.
+type MemoryCache struct {
+	mu       sync.Mutex
+	capacity int // 0 means unbounded
+	entries  map[string]*list.Element
+	order    *list.List // most recently used at the front
+}
+
+type memoryCacheEntry struct {
+	key       string
+	value     string
+	expiresAt time.Time // zero means no expiry
+}
+
+func (e memoryCacheEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// NewMemoryCache creates an unbounded in-memory cache
+func NewMemoryCache() *MemoryCache {
+	return NewLRUCache(0)
+}
+
+// NewLRUCache creates an in-memory cache holding at most capacity keys
+func NewLRUCache(capacity int) *MemoryCache {
+	return &MemoryCache{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Len returns the number of keys held, including expired keys not yet evicted
+func (m *MemoryCache) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.entries)
+}
+
+// get returns a live entry and marks it recently used, evicting it if it
+// has expired. Callers hold mu.
+func (m *MemoryCache) get(key string) (*memoryCacheEntry, bool) {
+	elem, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*memoryCacheEntry)
+	if entry.expired(time.Now()) {
+		m.remove(elem)
+		return nil, false
+	}
+	m.order.MoveToFront(elem)
+	return entry, true
+}
+
+// set stores a value, where a ttl of zero keeps it until deleted. Callers hold mu.
+func (m *MemoryCache) set(key, value string, ttl time.Duration) {
+	entry := &memoryCacheEntry{key: key, value: value}
+	if ttl > 0 {
+		entry.expiresAt = time.Now().Add(ttl)
+	}
+
+	if elem, ok := m.entries[key]; ok {
+		elem.Value = entry
+		m.order.MoveToFront(elem)
+		return
+	}
+	m.entries[key] = m.order.PushFront(entry)
+
+	if m.capacity > 0 && m.order.Len() > m.capacity {
+		m.remove(m.order.Back())
+	}
+}
+
+// remove drops an entry. Callers hold mu.
+func (m *MemoryCache) remove(elem *list.Element) {
+	m.order.Remove(elem)
+	delete(m.entries, elem.Value.(*memoryCacheEntry).key)
+}
+
+// incrBy adds delta to an integer counter, keeping its expiry. Callers hold mu.
+func (m *MemoryCache) incrBy(key string, delta int64) (int64, error) {
+	entry, ok := m.get(key)
+	if !ok {
+		m.set(key, strconv.FormatInt(delta, 10), 0)
+		return delta, nil
+	}
+
+	count, err := strconv.ParseInt(entry.value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("value of %s is not an integer", key)
+	}
+	count += delta
+	entry.value = strconv.FormatInt(count, 10)
+	return count, nil
+}
+
+func (m *MemoryCache) setJSON(key string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.set(key, string(data), ttl)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MemoryCache) getJSON(key string, value interface{}) error {
+	data, err := m.Get(key)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(data), value)
+}
+
+// SetURLMapping caches a URL mapping with TTL
+func (m *MemoryCache) SetURLMapping(shortCode string, mapping *models.URLMapping, ttl time.Duration) error {
+	data, err := marshalURLMapping(mapping)
+	if err != nil {
+		return fmt.Errorf("failed to marshal URL mapping: %w", err)
+	}
+	m.mu.Lock()
+	m.set(fmt.Sprintf("url:%s", shortCode), string(data), ttl)
+	m.mu.Unlock()
+	return nil
+}
+
+// GetURLMapping retrieves a cached URL mapping
+func (m *MemoryCache) GetURLMapping(shortCode string) (*models.URLMapping, error) {
+	key := fmt.Sprintf("url:%s", shortCode)
+	data, err := m.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	mapping, err := unmarshalURLMapping([]byte(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal URL mapping: %w", err)
+	}
+
+	if mapping.ExpiresAt != nil && mapping.ExpiresAt.Before(time.Now()) {
+		m.Delete(key)
+		return nil, ErrURLExpired
+	}
+	if mapping.StartsAt != nil && mapping.StartsAt.After(time.Now()) {
+		return nil, ErrURLNotYetActive
+	}
+
+	return mapping, nil
+}
+
+// DeleteURLMapping removes a cached URL mapping
+func (m *MemoryCache) DeleteURLMapping(shortCode string) error {
+	return m.Delete(fmt.Sprintf("url:%s", shortCode))
+}
+
+// IncrementClickCount increments the cached click count, expiring it after 24 hours
+func (m *MemoryCache) IncrementClickCount(shortCode string) (int64, error) {
+	key := fmt.Sprintf("clicks:%s", shortCode)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	count, err := m.incrBy(key, 1)
+	if err != nil {
+		return 0, fmt.Errorf("failed to increment click count in cache: %w", err)
+	}
+	m.set(key, strconv.FormatInt(count, 10), 24*time.Hour)
+	return count, nil
+}
+
+// DecrementClickCount reverts an increment of the cached click count
+func (m *MemoryCache) DecrementClickCount(shortCode string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.incrBy(fmt.Sprintf("clicks:%s", shortCode), -1); err != nil {
+		return fmt.Errorf("failed to decrement click count in cache: %w", err)
+	}
+	return nil
+}
+
+// SeedClickCount initialises the cached click counter if it doesn't exist yet
+func (m *MemoryCache) SeedClickCount(shortCode string, count int64) error {
+	key := fmt.Sprintf("clicks:%s", shortCode)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.get(key); !ok {
+		m.set(key, strconv.FormatInt(count, 10), 24*time.Hour)
+	}
+	return nil
+}
+
+// GetClickCount gets the cached click count
+func (m *MemoryCache) GetClickCount(shortCode string) (int64, error) {
+	value, err := m.Get(fmt.Sprintf("clicks:%s", shortCode))
+	if err != nil {
+		return 0, err
+	}
+	count, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get click count from cache: %w", err)
+	}
+	return count, nil
+}
+
+// SetAnalytics caches analytics data
+func (m *MemoryCache) SetAnalytics(shortCode string, analytics *models.AnalyticsResponse, ttl time.Duration) error {
+	if err := m.setJSON(fmt.Sprintf("analytics:%s", shortCode), analytics, ttl); err != nil {
+		return fmt.Errorf("failed to marshal analytics: %w", err)
+	}
+	return nil
+}
+
+// GetAnalytics retrieves cached analytics data
+func (m *MemoryCache) GetAnalytics(shortCode string) (*models.AnalyticsResponse, error) {
+	var analytics models.AnalyticsResponse
+	if err := m.getJSON(fmt.Sprintf("analytics:%s", shortCode), &analytics); err != nil {
+		if err == ErrCacheKeyNotFound {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to unmarshal analytics: %w", err)
+	}
+	return &analytics, nil
+}
+
+// Get retrieves a value by key
+func (m *MemoryCache) Get(key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.get(key)
+	if !ok {
+		return "", ErrCacheKeyNotFound
+	}
+	return entry.value, nil
+}
+
+// Set stores a value with TTL, formatting it the way Redis would
+func (m *MemoryCache) Set(key string, value interface{}, ttl time.Duration) error {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		s = fmt.Sprint(v)
+	}
+
+	m.mu.Lock()
+	m.set(key, s, ttl)
+	m.mu.Unlock()
+	return nil
+}
+
+// IncrementWithTTL increments a counter and starts its TTL on the first increment
+func (m *MemoryCache) IncrementWithTTL(key string, ttl time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	count, err := m.incrBy(key, 1)
+	if err != nil {
+		return 0, fmt.Errorf("failed to increment key: %w", err)
+	}
+	if count == 1 {
+		m.set(key, "1", ttl)
+	}
+	return count, nil
+}
+
+// TakeToken takes a token from the bucket at key, which holds burst tokens
+// and refills at rate tokens per second. The bucket is kept as a cache entry,
+// so bounded caches may evict idle buckets, which then start out full.
+func (m *MemoryCache) TakeToken(key string, rate float64, burst int) (*TokenBucket, error) {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tokens := float64(burst)
+	if entry, ok := m.get(key); ok {
+		var stored float64
+		var storedAt int64
+		if _, err := fmt.Sscanf(entry.value, "%g %d", &stored, &storedAt); err == nil {
+			tokens = refillTokens(stored, now.Sub(time.Unix(0, storedAt)).Seconds(), rate, burst)
+		}
+	}
+
+	allowed := tokens >= 1
+	if allowed {
+		tokens--
+	}
+	m.set(key, fmt.Sprintf("%g %d", tokens, now.UnixNano()), tokenBucketTTL(rate, burst))
+	return newTokenBucket(allowed, tokens, rate, burst), nil
+}
+
+// Delete removes a key
+func (m *MemoryCache) Delete(key string) error {
+	m.mu.Lock()
+	if elem, ok := m.entries[key]; ok {
+		m.remove(elem)
+	}
+	m.mu.Unlock()
+	return nil
+}
+
+// IsHealthy always reports true for the in-process cache
+func (m *MemoryCache) IsHealthy() bool {
+	return true
+}
@@ -3,11 +3,12 @@ package storage
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/URLshorter/url-shortener/configs"
 	"github.com/URLshorter/url-shortener/internal/models"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type PostgresStorage struct {
@@ -34,14 +35,9 @@ func NewPostgresStorage(config *configs.Config) (*PostgresStorage, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	storage := &PostgresStorage{db: db}
-	
-	// Initialize tables if they don't exist
-	if err := storage.initTables(); err != nil {
-		return nil, fmt.Errorf("failed to initialize tables: %w", err)
-	}
-
-	return storage, nil
+	// The schema is owned by internal/migrations; run cmd/migrate or start
+	// the server with -migrate before first use
+	return &PostgresStorage{db: db}, nil
 }
 
 // Close closes the database connection
@@ -71,279 +67,25 @@ func (p *PostgresStorage) Begin() (*sql.Tx, error) {
 	return p.db.Begin()
 }
 
-// initTables creates the necessary tables if they don't exist
-func (p *PostgresStorage) initTables() error {
-	queries := []string{
-		`CREATE TABLE IF NOT EXISTS url_mappings (
-			id BIGINT PRIMARY KEY,
-			short_code VARCHAR(10) UNIQUE NOT NULL,
-			original_url TEXT NOT NULL,
-			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-			expires_at TIMESTAMP WITH TIME ZONE,
-			click_count BIGINT DEFAULT 0,
-			is_active BOOLEAN DEFAULT TRUE,
-			created_by_ip INET
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_short_code ON url_mappings(short_code)`,
-		`CREATE INDEX IF NOT EXISTS idx_created_at ON url_mappings(created_at)`,
-		`CREATE INDEX IF NOT EXISTS idx_active ON url_mappings(is_active)`,
-		`CREATE TABLE IF NOT EXISTS click_events (
-			id BIGINT PRIMARY KEY,
-			short_code VARCHAR(10) NOT NULL,
-			clicked_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-			ip_address INET,
-			user_agent TEXT,
-			referrer TEXT,
-			country_code CHAR(2)
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_short_code_time ON click_events(short_code, clicked_at)`,
-	}
-
-	for _, query := range queries {
-		if _, err := p.db.Exec(query); err != nil {
-			return fmt.Errorf("failed to execute query %s: %w", query, err)
-		}
-	}
-
-	// Initialize enhanced analytics tables
-	if err := p.initAdvancedAnalyticsTables(); err != nil {
-		return fmt.Errorf("failed to initialize advanced analytics tables: %w", err)
-	}
-
-	return nil
-}
-
-// initAdvancedAnalyticsTables creates enhanced analytics tables
-func (p *PostgresStorage) initAdvancedAnalyticsTables() error {
-	queries := []string{
-		// Enhanced geographic analytics table
-		`CREATE TABLE IF NOT EXISTS geographic_analytics (
-			id BIGSERIAL PRIMARY KEY,
-			short_code VARCHAR(10) NOT NULL,
-			country_code CHAR(2),
-			country_name VARCHAR(100),
-			region VARCHAR(100),
-			city VARCHAR(100),
-			latitude DECIMAL(10,8),
-			longitude DECIMAL(11,8),
-			clicks INTEGER DEFAULT 1,
-			unique_ips INTEGER DEFAULT 1,
-			last_click TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-			UNIQUE(short_code, country_code, region, city),
-			FOREIGN KEY (short_code) REFERENCES url_mappings(short_code) ON DELETE CASCADE
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_geographic_short_code ON geographic_analytics(short_code)`,
-		`CREATE INDEX IF NOT EXISTS idx_geographic_country ON geographic_analytics(country_code)`,
-		`CREATE INDEX IF NOT EXISTS idx_geographic_location ON geographic_analytics(country_code, region, city)`,
-		
-		// Enhanced device analytics table
-		`CREATE TABLE IF NOT EXISTS device_analytics (
-			id BIGSERIAL PRIMARY KEY,
-			short_code VARCHAR(10) NOT NULL,
-			device_type VARCHAR(50), -- mobile, desktop, tablet
-			device_brand VARCHAR(50), -- Apple, Samsung, etc.
-			device_model VARCHAR(100),
-			os_name VARCHAR(50),
-			os_version VARCHAR(50),
-			browser_name VARCHAR(50),
-			browser_version VARCHAR(50),
-			screen_resolution VARCHAR(20),
-			user_agent_hash VARCHAR(64), -- SHA-256 hash for grouping
-			clicks INTEGER DEFAULT 1,
-			unique_ips INTEGER DEFAULT 1,
-			last_click TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-			UNIQUE(short_code, user_agent_hash),
-			FOREIGN KEY (short_code) REFERENCES url_mappings(short_code) ON DELETE CASCADE
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_device_short_code ON device_analytics(short_code)`,
-		`CREATE INDEX IF NOT EXISTS idx_device_type ON device_analytics(device_type)`,
-		`CREATE INDEX IF NOT EXISTS idx_device_brand ON device_analytics(device_brand, device_model)`,
-		`CREATE INDEX IF NOT EXISTS idx_device_os ON device_analytics(os_name, os_version)`,
-		`CREATE INDEX IF NOT EXISTS idx_device_browser ON device_analytics(browser_name, browser_version)`,
-		
-		// Time-based analytics table
-		`CREATE TABLE IF NOT EXISTS time_analytics (
-			id BIGSERIAL PRIMARY KEY,
-			short_code VARCHAR(10) NOT NULL,
-			click_date DATE NOT NULL,
-			hour_of_day INTEGER CHECK (hour_of_day >= 0 AND hour_of_day <= 23),
-			day_of_week INTEGER CHECK (day_of_week >= 0 AND day_of_week <= 6), -- 0=Sunday
-			day_of_month INTEGER CHECK (day_of_month >= 1 AND day_of_month <= 31),
-			month INTEGER CHECK (month >= 1 AND month <= 12),
-			year INTEGER,
-			clicks INTEGER DEFAULT 1,
-			unique_ips INTEGER DEFAULT 1,
-			conversions INTEGER DEFAULT 0,
-			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-			FOREIGN KEY (short_code) REFERENCES url_mappings(short_code) ON DELETE CASCADE,
-			UNIQUE(short_code, click_date, hour_of_day)
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_time_short_code ON time_analytics(short_code)`,
-		`CREATE INDEX IF NOT EXISTS idx_time_date ON time_analytics(click_date)`,
-		`CREATE INDEX IF NOT EXISTS idx_time_hour ON time_analytics(hour_of_day)`,
-		`CREATE INDEX IF NOT EXISTS idx_time_day_week ON time_analytics(day_of_week)`,
-		`CREATE INDEX IF NOT EXISTS idx_time_heatmap ON time_analytics(short_code, click_date, hour_of_day)`,
-		
-		// Enhanced referrer analytics table
-		`CREATE TABLE IF NOT EXISTS referrer_analytics (
-			id BIGSERIAL PRIMARY KEY,
-			short_code VARCHAR(10) NOT NULL,
-			referrer_domain VARCHAR(255),
-			referrer_url TEXT,
-			referrer_type VARCHAR(50), -- social, search, direct, email, etc.
-			campaign_source VARCHAR(100), -- UTM tracking
-			campaign_medium VARCHAR(100),
-			campaign_name VARCHAR(100),
-			campaign_term VARCHAR(100),
-			campaign_content VARCHAR(100),
-			clicks INTEGER DEFAULT 1,
-			unique_clicks INTEGER DEFAULT 1,
-			conversions INTEGER DEFAULT 0,
-			last_click TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-			UNIQUE(short_code, referrer_domain, campaign_source, campaign_medium),
-			FOREIGN KEY (short_code) REFERENCES url_mappings(short_code) ON DELETE CASCADE
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_referrer_short_code ON referrer_analytics(short_code)`,
-		`CREATE INDEX IF NOT EXISTS idx_referrer_domain ON referrer_analytics(referrer_domain)`,
-		`CREATE INDEX IF NOT EXISTS idx_referrer_type ON referrer_analytics(referrer_type)`,
-		`CREATE INDEX IF NOT EXISTS idx_referrer_campaign ON referrer_analytics(campaign_source, campaign_medium)`,
-		
-		// Conversion tracking tables
-		`CREATE TABLE IF NOT EXISTS conversion_goals (
-			id BIGSERIAL PRIMARY KEY,
-			user_id BIGINT NOT NULL,
-			goal_name VARCHAR(100) NOT NULL,
-			goal_type VARCHAR(50) NOT NULL, -- url_visit, custom_event, form_submit, purchase
-			target_url TEXT,
-			custom_event_name VARCHAR(100),
-			goal_value DECIMAL(10,2) DEFAULT 0, -- monetary value if applicable
-			attribution_window INTEGER DEFAULT 30, -- days
-			is_active BOOLEAN DEFAULT TRUE,
-			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_conversion_goals_user ON conversion_goals(user_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_conversion_goals_active ON conversion_goals(is_active)`,
-		
-		// Conversions tracking table
-		`CREATE TABLE IF NOT EXISTS conversions (
-			id BIGSERIAL PRIMARY KEY,
-			short_code VARCHAR(10) NOT NULL,
-			goal_id BIGINT NOT NULL,
-			conversion_id VARCHAR(100) UNIQUE NOT NULL, -- unique identifier for deduplication
-			conversion_type VARCHAR(50) NOT NULL,
-			conversion_value DECIMAL(10,2) DEFAULT 0,
-			user_ip INET,
-			user_agent TEXT,
-			referrer TEXT,
-			session_id VARCHAR(100), -- for session tracking
-			click_id BIGINT, -- link to original click event
-			conversion_time TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-			attribution_model VARCHAR(50) DEFAULT 'last_click', -- first_click, last_click, linear
-			time_to_conversion INTEGER, -- minutes from click to conversion
-			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-			FOREIGN KEY (short_code) REFERENCES url_mappings(short_code) ON DELETE CASCADE,
-			FOREIGN KEY (goal_id) REFERENCES conversion_goals(id) ON DELETE CASCADE
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_conversions_short_code ON conversions(short_code)`,
-		`CREATE INDEX IF NOT EXISTS idx_conversions_goal ON conversions(goal_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_conversions_time ON conversions(conversion_time)`,
-		`CREATE INDEX IF NOT EXISTS idx_conversions_session ON conversions(session_id)`,
-		
-		// A/B testing tables
-		`CREATE TABLE IF NOT EXISTS ab_tests (
-			id BIGSERIAL PRIMARY KEY,
-			user_id BIGINT NOT NULL,
-			test_name VARCHAR(100) NOT NULL,
-			description TEXT,
-			short_code_a VARCHAR(10) NOT NULL, -- Control variant
-			short_code_b VARCHAR(10) NOT NULL, -- Test variant
-			traffic_split INTEGER DEFAULT 50, -- percentage to variant B (0-100)
-			start_date TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-			end_date TIMESTAMP WITH TIME ZONE,
-			status VARCHAR(20) DEFAULT 'active', -- draft, active, paused, completed
-			winner VARCHAR(1), -- 'A', 'B', or NULL if inconclusive
-			confidence_level DECIMAL(5,2), -- statistical confidence percentage
-			min_sample_size INTEGER DEFAULT 100,
-			conversion_goal_id BIGINT,
-			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
-			FOREIGN KEY (short_code_a) REFERENCES url_mappings(short_code) ON DELETE CASCADE,
-			FOREIGN KEY (short_code_b) REFERENCES url_mappings(short_code) ON DELETE CASCADE,
-			FOREIGN KEY (conversion_goal_id) REFERENCES conversion_goals(id) ON DELETE SET NULL
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_ab_tests_user ON ab_tests(user_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_ab_tests_status ON ab_tests(status)`,
-		`CREATE INDEX IF NOT EXISTS idx_ab_tests_dates ON ab_tests(start_date, end_date)`,
-		
-		// A/B test results tracking
-		`CREATE TABLE IF NOT EXISTS ab_test_results (
-			id BIGSERIAL PRIMARY KEY,
-			test_id BIGINT NOT NULL,
-			variant VARCHAR(1) NOT NULL, -- 'A' or 'B'
-			date DATE DEFAULT CURRENT_DATE,
-			clicks INTEGER DEFAULT 0,
-			conversions INTEGER DEFAULT 0,
-			conversion_rate DECIMAL(6,4) DEFAULT 0, -- stored as percentage (0-100.00)
-			revenue DECIMAL(10,2) DEFAULT 0,
-			unique_visitors INTEGER DEFAULT 0,
-			bounce_rate DECIMAL(5,2) DEFAULT 0,
-			avg_time_on_site INTEGER DEFAULT 0, -- seconds
-			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-			FOREIGN KEY (test_id) REFERENCES ab_tests(id) ON DELETE CASCADE,
-			UNIQUE(test_id, variant, date)
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_ab_test_results_test ON ab_test_results(test_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_ab_test_results_date ON ab_test_results(date)`,
-		
-		// Attribution tracking for better conversion analysis
-		`CREATE TABLE IF NOT EXISTS attribution_touchpoints (
-			id BIGSERIAL PRIMARY KEY,
-			session_id VARCHAR(100) NOT NULL,
-			short_code VARCHAR(10) NOT NULL,
-			user_ip INET,
-			user_agent TEXT,
-			referrer TEXT,
-			campaign_source VARCHAR(100),
-			campaign_medium VARCHAR(100),
-			campaign_name VARCHAR(100),
-			touchpoint_order INTEGER NOT NULL, -- 1st touch, 2nd touch, etc.
-			touchpoint_time TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-			conversion_id VARCHAR(100), -- link to final conversion if exists
-			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-			FOREIGN KEY (short_code) REFERENCES url_mappings(short_code) ON DELETE CASCADE
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_attribution_session ON attribution_touchpoints(session_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_attribution_conversion ON attribution_touchpoints(conversion_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_attribution_time ON attribution_touchpoints(touchpoint_time)`,
-	}
-
-	for _, query := range queries {
-		if _, err := p.db.Exec(query); err != nil {
-			return fmt.Errorf("failed to execute query %s: %w", query, err)
-		}
-	}
-
-	return nil
+// DB returns the underlying connection pool, e.g. for the migration runner
+func (p *PostgresStorage) DB() *sql.DB {
+	return p.db
 }
 
 // SaveURLMapping saves a URL mapping to the database
 func (p *PostgresStorage) SaveURLMapping(mapping *models.URLMapping) error {
 	query := `
-		INSERT INTO url_mappings (id, short_code, original_url, created_at, expires_at, created_by_ip, user_id)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO url_mappings (id, short_code, original_url, created_at, expires_at, created_by_ip, user_id, redirect_type, redirect_rules, password_hash,
+		                          starts_at, max_clicks, fallback_url, social_preview)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, '')::inet, $7, $8, $9, NULLIF($10, ''), $11, $12, NULLIF($13, ''), $14)
 	`
+	redirectType := mapping.RedirectType
+	if redirectType == "" {
+		redirectType = models.DefaultRedirectType
+	}
 	_, err := p.db.Exec(query, mapping.ID, mapping.ShortCode, mapping.OriginalURL,
-		mapping.CreatedAt, mapping.ExpiresAt, mapping.CreatedByIP, mapping.UserID)
+		mapping.CreatedAt, mapping.ExpiresAt, mapping.CreatedByIP, mapping.UserID, redirectType, mapping.RedirectRules, mapping.PasswordHash,
+		mapping.StartsAt, mapping.MaxClicks, mapping.FallbackURL, mapping.SocialPreview)
 	
 	if err != nil {
 		return fmt.Errorf("failed to save URL mapping: %w", err)
@@ -353,21 +95,53 @@ func (p *PostgresStorage) SaveURLMapping(mapping *models.URLMapping) error {
 
 // GetURLMappingByShortCode retrieves a URL mapping by its short code
 func (p *PostgresStorage) GetURLMappingByShortCode(shortCode string) (*models.URLMapping, error) {
+	mapping, err := p.getURLMapping(shortCode, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if URL has expired
+	if mapping.ExpiresAt != nil && mapping.ExpiresAt.Before(time.Now()) {
+		return nil, ErrURLExpired
+	}
+
+	// Check if URL has been activated yet
+	if mapping.StartsAt != nil && mapping.StartsAt.After(time.Now()) {
+		return nil, ErrURLNotYetActive
+	}
+
+	return mapping, nil
+}
+
+// LookupURLMapping retrieves a URL mapping whatever its state, including
+// deleted, expired and scheduled links
+func (p *PostgresStorage) LookupURLMapping(shortCode string) (*models.URLMapping, error) {
+	return p.getURLMapping(shortCode, false)
+}
+
+func (p *PostgresStorage) getURLMapping(shortCode string, activeOnly bool) (*models.URLMapping, error) {
 	query := `
-		SELECT id, short_code, original_url, created_at, expires_at, click_count, is_active, created_by_ip, user_id
+		SELECT id, short_code, original_url, created_at, expires_at, click_count, is_active, created_by_ip, user_id,
+		       COALESCE(redirect_type, '302'), COALESCE(destination_version, 1), redirect_rules,
+		       COALESCE(password_hash, ''), starts_at, max_clicks, COALESCE(fallback_url, ''), social_preview,
+		       quarantined_at, COALESCE(quarantine_reason, '')
 		FROM url_mappings
-		WHERE short_code = $1 AND is_active = TRUE
+		WHERE short_code = $1 AND (is_active = TRUE OR NOT $2)
 	`
 	
 	mapping := &models.URLMapping{}
-	var expiresAt sql.NullTime
+	var expiresAt, startsAt, quarantinedAt sql.NullTime
 	var createdByIP sql.NullString
-	var userID sql.NullInt64
+	var userID, maxClicks sql.NullInt64
+	var socialPreview models.SocialPreview
 	
-	err := p.db.QueryRow(query, shortCode).Scan(
+	err := p.db.QueryRow(query, shortCode, activeOnly).Scan(
 		&mapping.ID, &mapping.ShortCode, &mapping.OriginalURL,
 		&mapping.CreatedAt, &expiresAt, &mapping.ClickCount,
-		&mapping.IsActive, &createdByIP, &userID,
+		&mapping.IsActive, &createdByIP, &userID, &mapping.RedirectType,
+		&mapping.DestinationVersion, &mapping.RedirectRules, &mapping.PasswordHash,
+		&startsAt, &maxClicks, &mapping.FallbackURL, &socialPreview,
+		&quarantinedAt, &mapping.QuarantineReason,
 	)
 	
 	if err != nil {
@@ -380,21 +154,51 @@ func (p *PostgresStorage) GetURLMappingByShortCode(shortCode string) (*models.UR
 	if expiresAt.Valid {
 		mapping.ExpiresAt = &expiresAt.Time
 	}
+	if !socialPreview.IsEmpty() {
+		mapping.SocialPreview = &socialPreview
+	}
+	if quarantinedAt.Valid {
+		mapping.QuarantinedAt = &quarantinedAt.Time
+	}
 	if createdByIP.Valid {
 		mapping.CreatedByIP = createdByIP.String
 	}
 	if userID.Valid {
 		mapping.UserID = &userID.Int64
 	}
-
-	// Check if URL has expired
-	if mapping.ExpiresAt != nil && mapping.ExpiresAt.Before(time.Now()) {
-		return nil, ErrURLExpired
+	if startsAt.Valid {
+		mapping.StartsAt = &startsAt.Time
+	}
+	if maxClicks.Valid {
+		mapping.MaxClicks = &maxClicks.Int64
 	}
 
 	return mapping, nil
 }
 
+// GetClickCount returns the persisted click count for a URL mapping
+func (p *PostgresStorage) GetClickCount(shortCode string) (int64, error) {
+	var count int64
+	err := p.db.QueryRow(`SELECT click_count FROM url_mappings WHERE short_code = $1`, shortCode).Scan(&count)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrURLNotFound
+		}
+		return 0, fmt.Errorf("failed to get click count: %w", err)
+	}
+	return count, nil
+}
+
+// CountHumanClicks counts a link's recorded human clicks
+func (p *PostgresStorage) CountHumanClicks(shortCode string) (int64, error) {
+	var count int64
+	query := `SELECT COUNT(*) FROM click_events WHERE short_code = $1 AND ` + HumanTrafficFilter(false)
+	if err := p.db.QueryRow(query, shortCode).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count clicks: %w", err)
+	}
+	return count, nil
+}
+
 // IncrementClickCount increments the click count for a URL mapping
 func (p *PostgresStorage) IncrementClickCount(shortCode string) error {
 	query := `UPDATE url_mappings SET click_count = click_count + 1 WHERE short_code = $1`
@@ -408,11 +212,15 @@ func (p *PostgresStorage) IncrementClickCount(shortCode string) error {
 // SaveClickEvent saves a click event to the database
 func (p *PostgresStorage) SaveClickEvent(event *models.ClickEvent) error {
 	query := `
-		INSERT INTO click_events (id, short_code, clicked_at, ip_address, user_agent, referrer, country_code)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO click_events (id, short_code, clicked_at, ip_address, user_agent, referrer, country_code, destination_version, traffic_class, source)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 	`
+	var destinationVersion sql.NullInt64
+	if event.DestinationVersion > 0 {
+		destinationVersion = sql.NullInt64{Int64: int64(event.DestinationVersion), Valid: true}
+	}
 	_, err := p.db.Exec(query, event.ID, event.ShortCode, event.ClickedAt,
-		event.IPAddress, event.UserAgent, event.Referrer, event.CountryCode)
+		event.IPAddress, event.UserAgent, event.Referrer, event.CountryCode, destinationVersion, trafficClass(event), nullString(event.Source))
 	
 	if err != nil {
 		return fmt.Errorf("failed to save click event: %w", err)
@@ -420,8 +228,241 @@ func (p *PostgresStorage) SaveClickEvent(event *models.ClickEvent) error {
 	return nil
 }
 
+// clickEventColumns is the number of columns written per click event row
+const clickEventColumns = 10
+
+// SaveClickEvents batch-inserts click events with multi-row INSERTs, chunked
+// to stay under Postgres' bind parameter limit. Events whose ID is already
+// stored are skipped, so redelivered events are safe to save again; the IDs
+// of the newly inserted rows are returned.
+func (p *PostgresStorage) SaveClickEvents(events []*models.ClickEvent) ([]int64, error) {
+	const maxRowsPerStatement = 65535 / clickEventColumns
+
+	inserted := make([]int64, 0, len(events))
+	for start := 0; start < len(events); start += maxRowsPerStatement {
+		end := start + maxRowsPerStatement
+		if end > len(events) {
+			end = len(events)
+		}
+		chunk := events[start:end]
+
+		placeholders := make([]string, 0, len(chunk))
+		args := make([]interface{}, 0, len(chunk)*clickEventColumns)
+		for i, event := range chunk {
+			base := i * clickEventColumns
+			placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)",
+				base+1, base+2, base+3, base+4, base+5, base+6, base+7, base+8, base+9, base+10))
+
+			var destinationVersion sql.NullInt64
+			if event.DestinationVersion > 0 {
+				destinationVersion = sql.NullInt64{Int64: int64(event.DestinationVersion), Valid: true}
+			}
+			args = append(args, event.ID, event.ShortCode, event.ClickedAt,
+				event.IPAddress, event.UserAgent, event.Referrer, event.CountryCode, destinationVersion, trafficClass(event), nullString(event.Source))
+		}
+
+		query := `INSERT INTO click_events (id, short_code, clicked_at, ip_address, user_agent, referrer, country_code, destination_version, traffic_class, source)
+			VALUES ` + strings.Join(placeholders, ", ") + `
+			ON CONFLICT (id) DO NOTHING
+			RETURNING id`
+		rows, err := p.db.Query(query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to save click events: %w", err)
+		}
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan inserted click event: %w", err)
+			}
+			inserted = append(inserted, id)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to save click events: %w", err)
+		}
+	}
+
+	return inserted, nil
+}
+
+// trafficClass returns the event's traffic class, treating unclassified events as human
+func trafficClass(event *models.ClickEvent) string {
+	if event.TrafficClass == "" {
+		return models.TrafficClassHuman
+	}
+	return event.TrafficClass
+}
+
+// HumanTrafficFilter returns the SQL condition restricting click_events to
+// human traffic, or an always-true condition when bots are included
+func HumanTrafficFilter(includeBots bool) string {
+	if includeBots {
+		return "TRUE"
+	}
+	return "COALESCE(traffic_class, 'human') = 'human'"
+}
+
+// IncrementClickCounts applies coalesced click count increments in a single statement
+func (p *PostgresStorage) IncrementClickCounts(counts map[string]int64) error {
+	if len(counts) == 0 {
+		return nil
+	}
+
+	shortCodes := make([]string, 0, len(counts))
+	increments := make([]int64, 0, len(counts))
+	for shortCode, n := range counts {
+		shortCodes = append(shortCodes, shortCode)
+		increments = append(increments, n)
+	}
+
+	query := `
+		UPDATE url_mappings u
+		SET click_count = u.click_count + c.n
+		FROM unnest($1::text[], $2::bigint[]) AS c(short_code, n)
+		WHERE u.short_code = c.short_code
+	`
+	if _, err := p.db.Exec(query, pq.Array(shortCodes), pq.Array(increments)); err != nil {
+		return fmt.Errorf("failed to increment click counts: %w", err)
+	}
+	return nil
+}
+
+// ChangeURLDestination points a user's short code at a new destination and
+// applies update. The previous destination is appended to
+// url_destination_history in the same transaction and the destination version
+// is bumped. Returns the new version.
+func (p *PostgresStorage) ChangeURLDestination(shortCode string, userID int64, newURL string, update *URLUpdate) (int, error) {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var currentURL string
+	var version int
+	var activeFrom time.Time
+	err = tx.QueryRow(`
+		SELECT original_url, COALESCE(destination_version, 1), COALESCE(destination_updated_at, created_at)
+		FROM url_mappings
+		WHERE short_code = $1 AND user_id = $2 AND is_active = TRUE
+		FOR UPDATE
+	`, shortCode, userID).Scan(&currentURL, &version, &activeFrom)
+	if err == sql.ErrNoRows {
+		return 0, ErrURLNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to lock URL mapping: %w", err)
+	}
+
+	if !update.IsEmpty() {
+		if err := updateURL(tx, shortCode, userID, update); err != nil {
+			return 0, err
+		}
+	}
+
+	// Nothing to record if the destination is unchanged
+	if currentURL != newURL {
+		now := time.Now()
+		_, err = tx.Exec(`
+			INSERT INTO url_destination_history (short_code, version, original_url, active_from, replaced_at, replaced_by)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, shortCode, version, currentURL, activeFrom, now, userID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to record destination history: %w", err)
+		}
+
+		version++
+		_, err = tx.Exec(`
+			UPDATE url_mappings
+			SET original_url = $1, destination_version = $2, destination_updated_at = $3
+			WHERE short_code = $4
+		`, newURL, version, now, shortCode)
+		if err != nil {
+			return 0, fmt.Errorf("failed to update destination: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit destination change: %w", err)
+	}
+
+	return version, nil
+}
+
+// GetDestinationHistory retrieves prior destinations of a short code, newest first
+func (p *PostgresStorage) GetDestinationHistory(shortCode string) ([]models.DestinationHistoryEntry, error) {
+	rows, err := p.db.Query(`
+		SELECT id, short_code, version, original_url, active_from, replaced_at, replaced_by
+		FROM url_destination_history
+		WHERE short_code = $1
+		ORDER BY version DESC
+	`, shortCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query destination history: %w", err)
+	}
+	defer rows.Close()
+
+	history := []models.DestinationHistoryEntry{}
+	for rows.Next() {
+		var entry models.DestinationHistoryEntry
+		var replacedBy sql.NullInt64
+		if err := rows.Scan(&entry.ID, &entry.ShortCode, &entry.Version, &entry.OriginalURL,
+			&entry.ActiveFrom, &entry.ReplacedAt, &replacedBy); err != nil {
+			return nil, fmt.Errorf("failed to scan destination history: %w", err)
+		}
+		if replacedBy.Valid {
+			entry.ReplacedBy = &replacedBy.Int64
+		}
+		history = append(history, entry)
+	}
+
+	return history, rows.Err()
+}
+
+// GetDestinationStats breaks down clicks on a short code by the destination
+// version that was live when each click happened. Clicks recorded before
+// destination versioning existed are attributed to version 1.
+func (p *PostgresStorage) GetDestinationStats(shortCode string) ([]models.DestinationStat, error) {
+	query := `
+		WITH versions AS (
+			SELECT version, original_url, FALSE AS is_current
+			FROM url_destination_history
+			WHERE short_code = $1
+			UNION ALL
+			SELECT COALESCE(destination_version, 1), original_url, TRUE
+			FROM url_mappings
+			WHERE short_code = $1
+		)
+		SELECT v.version, v.original_url, v.is_current, COUNT(ce.id)
+		FROM versions v
+		LEFT JOIN click_events ce
+			ON ce.short_code = $1 AND COALESCE(ce.destination_version, 1) = v.version
+		GROUP BY v.version, v.original_url, v.is_current
+		ORDER BY v.version
+	`
+	rows, err := p.db.Query(query, shortCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query destination stats: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []models.DestinationStat
+	for rows.Next() {
+		var stat models.DestinationStat
+		if err := rows.Scan(&stat.Version, &stat.OriginalURL, &stat.IsCurrent, &stat.Clicks); err != nil {
+			return nil, fmt.Errorf("failed to scan destination stats: %w", err)
+		}
+		stats = append(stats, stat)
+	}
+
+	return stats, rows.Err()
+}
+
 // GetAnalytics retrieves analytics data for a short code
-func (p *PostgresStorage) GetAnalytics(shortCode string, days int) (*models.AnalyticsResponse, error) {
+// Non-human clicks (bots, link previews, scanners) are excluded unless includeBots is set.
+func (p *PostgresStorage) GetAnalytics(shortCode string, days int, includeBots bool) (*models.AnalyticsResponse, error) {
 	// Get basic URL info
 	mapping, err := p.GetURLMappingByShortCode(shortCode)
 	if err != nil {
@@ -435,10 +476,31 @@ func (p *PostgresStorage) GetAnalytics(shortCode string, days int) (*models.Anal
 		CreatedAt:   mapping.CreatedAt,
 	}
 
+	trafficFilter := HumanTrafficFilter(includeBots)
+
+	// click_count includes every click, so count human clicks from the events
+	if !includeBots {
+		err = p.db.QueryRow(
+			`SELECT COUNT(*) FROM click_events WHERE short_code = $1 AND `+trafficFilter,
+			shortCode,
+		).Scan(&analytics.TotalClicks)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count clicks: %w", err)
+		}
+	}
+
+	err = p.db.QueryRow(
+		`SELECT COUNT(*) FROM click_events WHERE short_code = $1 AND source = $2 AND `+trafficFilter,
+		shortCode, models.ClickSourceQR,
+	).Scan(&analytics.TotalScans)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count scans: %w", err)
+	}
+
 	// Get last click time
 	var lastClickAt sql.NullTime
 	err = p.db.QueryRow(
-		`SELECT MAX(clicked_at) FROM click_events WHERE short_code = $1`,
+		`SELECT MAX(clicked_at) FROM click_events WHERE short_code = $1 AND `+trafficFilter,
 		shortCode,
 	).Scan(&lastClickAt)
 	if err == nil && lastClickAt.Valid {
@@ -449,11 +511,11 @@ func (p *PostgresStorage) GetAnalytics(shortCode string, days int) (*models.Anal
 	dailyClicksQuery := `
 		SELECT DATE(clicked_at) as date, COUNT(*) as clicks
 		FROM click_events
-		WHERE short_code = $1 AND clicked_at >= NOW() - INTERVAL '%d days'
+		WHERE short_code = $1 AND clicked_at >= NOW() - INTERVAL '%d days' AND %s
 		GROUP BY DATE(clicked_at)
 		ORDER BY date DESC
 	`
-	rows, err := p.db.Query(fmt.Sprintf(dailyClicksQuery, days), shortCode)
+	rows, err := p.db.Query(fmt.Sprintf(dailyClicksQuery, days, trafficFilter), shortCode)
 	if err == nil {
 		defer rows.Close()
 		for rows.Next() {
@@ -468,7 +530,7 @@ func (p *PostgresStorage) GetAnalytics(shortCode string, days int) (*models.Anal
 	countryStatsQuery := `
 		SELECT country_code, COUNT(*) as clicks
 		FROM click_events
-		WHERE short_code = $1 AND country_code IS NOT NULL
+		WHERE short_code = $1 AND country_code IS NOT NULL AND ` + trafficFilter + `
 		GROUP BY country_code
 		ORDER BY clicks DESC
 		LIMIT 10
@@ -487,6 +549,32 @@ func (p *PostgresStorage) GetAnalytics(shortCode string, days int) (*models.Anal
 	return analytics, nil
 }
 
+// SaveVisitorSketch upserts a serialized HyperLogLog sketch. day is nil for a link's lifetime sketch.
+func (p *PostgresStorage) SaveVisitorSketch(key, shortCode string, day *time.Time, sketch []byte) error {
+	query := `
+		INSERT INTO unique_visitor_sketches (sketch_key, short_code, day, sketch, updated_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (sketch_key) DO UPDATE SET sketch = EXCLUDED.sketch, updated_at = NOW()
+	`
+	if _, err := p.db.Exec(query, key, shortCode, day, sketch); err != nil {
+		return fmt.Errorf("failed to save visitor sketch: %w", err)
+	}
+	return nil
+}
+
+// GetVisitorSketch loads a persisted HyperLogLog sketch, returning nil when none is stored
+func (p *PostgresStorage) GetVisitorSketch(key string) ([]byte, error) {
+	var sketch []byte
+	err := p.db.QueryRow(`SELECT sketch FROM unique_visitor_sketches WHERE sketch_key = $1`, key).Scan(&sketch)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get visitor sketch: %w", err)
+	}
+	return sketch, nil
+}
+
 // ShortCodeExists checks if a short code already exists
 func (p *PostgresStorage) ShortCodeExists(shortCode string) (bool, error) {
 	var exists bool
@@ -650,6 +738,22 @@ func (p *PostgresStorage) GetEmailVerificationByToken(token string) (*models.Ema
 	return verification, nil
 }
 
+// GetLatestEmailVerification retrieves the most recent email verification of a user
+func (p *PostgresStorage) GetLatestEmailVerification(userID int64) (*models.EmailVerification, error) {
+	verification := &models.EmailVerification{}
+	query := `
+		SELECT id, user_id, token, expires_at, verified_at, created_at
+		FROM email_verifications WHERE user_id = $1 ORDER BY created_at DESC LIMIT 1
+	`
+	err := p.QueryRow(query, userID).Scan(
+		&verification.ID, &verification.UserID, &verification.Token,
+		&verification.ExpiresAt, &verification.VerifiedAt, &verification.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return verification, nil
+}
+
 // UpdateEmailVerification updates email verification record
 func (p *PostgresStorage) UpdateEmailVerification(id string, verifiedAt time.Time) error {
 	query := `UPDATE email_verifications SET verified_at = $1 WHERE id = $2`
@@ -657,6 +761,13 @@ func (p *PostgresStorage) UpdateEmailVerification(id string, verifiedAt time.Tim
 	return err
 }
 
+// InvalidateEmailVerifications closes all pending email verifications of a user
+func (p *PostgresStorage) InvalidateEmailVerifications(userID int64) error {
+	query := `UPDATE email_verifications SET verified_at = NOW() WHERE user_id = $1 AND verified_at IS NULL`
+	_, err := p.Exec(query, userID)
+	return err
+}
+
 // UpdateUserEmailVerified updates user email verification status
 func (p *PostgresStorage) UpdateUserEmailVerified(userID int64, verified bool) error {
 	query := `UPDATE users SET email_verified = $1, updated_at = $2 WHERE id = $3`
@@ -820,7 +931,13 @@ func (p *PostgresStorage) UpdateUserPreferences(prefs *models.UserPreferences) e
 var (
 	ErrURLNotFound   = &StorageError{Message: "URL not found"}
 	ErrURLExpired    = &StorageError{Message: "URL has expired"}
+	ErrURLNotYetActive = &StorageError{Message: "URL is not active yet"}
 	ErrUnauthorized  = &StorageError{Message: "Unauthorized access"}
+	ErrImportJobNotFound = &StorageError{Message: "import job not found"}
+	ErrBlocklistEntryNotFound = &StorageError{Message: "blocklist entry not found"}
+	ErrBlocklistEntryExists   = &StorageError{Message: "pattern is already blocked"}
+	ErrTwoFactorEnabled       = &StorageError{Message: "two-factor authentication is already enabled"}
+	ErrTwoFactorNotPending    = &StorageError{Message: "no two-factor enrolment to confirm"}
 )
 
 type StorageError struct {
@@ -0,0 +1,268 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/lib/pq"
+)
+
+// SetURLTags replaces a link's tags
+func (p *PostgresStorage) SetURLTags(shortCode string, tags []string) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM url_tags WHERE short_code = $1`, shortCode); err != nil {
+		return fmt.Errorf("failed to clear tags: %w", err)
+	}
+	if len(tags) > 0 {
+		_, err := tx.Exec(`INSERT INTO url_tags (short_code, tag) SELECT $1, unnest($2::text[]) ON CONFLICT DO NOTHING`,
+			shortCode, pq.Array(tags))
+		if err != nil {
+			return fmt.Errorf("failed to save tags: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// EachUserURL calls fn for each of a user's active links with its tags, oldest first
+func (p *PostgresStorage) EachUserURL(userID int64, fn func(*models.UserURLResponse) error) error {
+	query := `
+		SELECT ` + userURLColumns + `, ` + urlTagsColumn + `
+		FROM url_mappings
+		WHERE user_id = $1 AND is_active = TRUE
+		ORDER BY created_at, id
+	`
+
+	rows, err := p.db.Query(query, userID)
+	if err != nil {
+		return fmt.Errorf("failed to query user URLs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		url, err := scanTaggedUserURL(rows)
+		if err != nil {
+			return fmt.Errorf("failed to scan URL row: %w", err)
+		}
+		if err := fn(url); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// importJobColumns are the bulk_import_jobs columns scanned by scanImportJob
+const importJobColumns = `
+	id, user_id, format, checksum, status, total_rows, processed_rows, created_rows,
+	failed_rows, COALESCE(error, ''), created_at, started_at, finished_at`
+
+func scanImportJob(row rowScanner) (*models.BulkImportJob, error) {
+	job := &models.BulkImportJob{}
+	var startedAt, finishedAt sql.NullTime
+	err := row.Scan(
+		&job.ID, &job.UserID, &job.Format, &job.Checksum, &job.Status, &job.TotalRows,
+		&job.ProcessedRows, &job.CreatedRows, &job.FailedRows, &job.Error, &job.CreatedAt,
+		&startedAt, &finishedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrImportJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	return job, nil
+}
+
+// CreateImportJob saves a job together with its rows, copying the rows in
+// one round trip since files can hold tens of thousands of them
+func (p *PostgresStorage) CreateImportJob(job *models.BulkImportJob, rows []*models.BulkImportRow) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO bulk_import_jobs (id, user_id, format, checksum, status, total_rows, processed_rows,
+		                              created_rows, failed_rows, error, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''), $11)`,
+		job.ID, job.UserID, job.Format, job.Checksum, job.Status, job.TotalRows, job.ProcessedRows,
+		job.CreatedRows, job.FailedRows, job.Error, job.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save import job: %w", err)
+	}
+
+	stmt, err := tx.Prepare(pq.CopyIn("bulk_import_rows",
+		"job_id", "line", "url", "custom_code", "expires_at", "title", "tags", "status", "short_code", "error"))
+	if err != nil {
+		return fmt.Errorf("failed to prepare import rows: %w", err)
+	}
+	for _, row := range rows {
+		// COPY sends []byte as bytea, so tags go as JSON text
+		var tags interface{}
+		if value, err := row.Tags.Value(); err != nil {
+			return fmt.Errorf("failed to encode tags: %w", err)
+		} else if value != nil {
+			tags = string(value.([]byte))
+		}
+
+		_, err := stmt.Exec(job.ID, row.Line, row.URL, nullString(row.CustomCode), row.ExpiresAt,
+			nullString(row.Title), tags, row.Status, nullString(row.ShortCode), nullString(row.Error))
+		if err != nil {
+			stmt.Close()
+			return fmt.Errorf("failed to save import row %d: %w", row.Line, err)
+		}
+	}
+	if _, err := stmt.Exec(); err != nil {
+		stmt.Close()
+		return fmt.Errorf("failed to save import rows: %w", err)
+	}
+	if err := stmt.Close(); err != nil {
+		return fmt.Errorf("failed to save import rows: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// GetImportJob retrieves an import job by ID
+func (p *PostgresStorage) GetImportJob(id int64) (*models.BulkImportJob, error) {
+	job, err := scanImportJob(p.db.QueryRow(`SELECT `+importJobColumns+` FROM bulk_import_jobs WHERE id = $1`, id))
+	if err != nil && err != ErrImportJobNotFound {
+		return nil, fmt.Errorf("failed to get import job: %w", err)
+	}
+	return job, err
+}
+
+// GetImportJobByChecksum retrieves a user's import job for a file
+func (p *PostgresStorage) GetImportJobByChecksum(userID int64, checksum string) (*models.BulkImportJob, error) {
+	job, err := scanImportJob(p.db.QueryRow(`SELECT `+importJobColumns+` FROM bulk_import_jobs WHERE user_id = $1 AND checksum = $2`,
+		userID, checksum))
+	if err != nil && err != ErrImportJobNotFound {
+		return nil, fmt.Errorf("failed to get import job: %w", err)
+	}
+	return job, err
+}
+
+// ListUnfinishedImportJobs retrieves pending and running jobs, oldest first
+func (p *PostgresStorage) ListUnfinishedImportJobs() ([]*models.BulkImportJob, error) {
+	rows, err := p.db.Query(`SELECT ` + importJobColumns + ` FROM bulk_import_jobs WHERE status IN ('pending', 'running') ORDER BY created_at`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query import jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []*models.BulkImportJob
+	for rows.Next() {
+		job, err := scanImportJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan import job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// UpdateImportJob saves a job's status and progress
+func (p *PostgresStorage) UpdateImportJob(job *models.BulkImportJob) error {
+	_, err := p.db.Exec(`
+		UPDATE bulk_import_jobs
+		SET status = $2, processed_rows = $3, created_rows = $4, failed_rows = $5,
+		    error = NULLIF($6, ''), started_at = $7, finished_at = $8
+		WHERE id = $1`,
+		job.ID, job.Status, job.ProcessedRows, job.CreatedRows, job.FailedRows, job.Error, job.StartedAt, job.FinishedAt)
+	if err != nil {
+		return fmt.Errorf("failed to update import job: %w", err)
+	}
+	return nil
+}
+
+// ListImportRows returns up to limit rows of a job after the given line
+func (p *PostgresStorage) ListImportRows(jobID int64, status string, afterLine, limit int) ([]*models.BulkImportRow, error) {
+	rows, err := p.db.Query(`
+		SELECT job_id, line, url, COALESCE(custom_code, ''), expires_at, COALESCE(title, ''), tags,
+		       status, COALESCE(short_code, ''), COALESCE(error, '')
+		FROM bulk_import_rows
+		WHERE job_id = $1 AND ($2 = '' OR status = $2) AND line > $3
+		ORDER BY line
+		LIMIT $4`, jobID, status, afterLine, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query import rows: %w", err)
+	}
+	defer rows.Close()
+
+	var result []*models.BulkImportRow
+	for rows.Next() {
+		row := &models.BulkImportRow{}
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&row.JobID, &row.Line, &row.URL, &row.CustomCode, &expiresAt, &row.Title, &row.Tags,
+			&row.Status, &row.ShortCode, &row.Error); err != nil {
+			return nil, fmt.Errorf("failed to scan import row: %w", err)
+		}
+		if expiresAt.Valid {
+			row.ExpiresAt = &expiresAt.Time
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// UpdateImportRows records the outcome of processed rows in one statement
+func (p *PostgresStorage) UpdateImportRows(rows []*models.BulkImportRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	lines := make([]int64, len(rows))
+	statuses := make([]string, len(rows))
+	shortCodes := make([]string, len(rows))
+	errs := make([]string, len(rows))
+	for i, row := range rows {
+		lines[i], statuses[i], shortCodes[i], errs[i] = int64(row.Line), row.Status, row.ShortCode, row.Error
+	}
+
+	_, err := p.db.Exec(`
+		UPDATE bulk_import_rows r
+		SET status = u.status, short_code = NULLIF(u.short_code, ''), error = NULLIF(u.error, '')
+		FROM unnest($2::int[], $3::text[], $4::text[], $5::text[]) AS u(line, status, short_code, error)
+		WHERE r.job_id = $1 AND r.line = u.line`,
+		rows[0].JobID, pq.Array(lines), pq.Array(statuses), pq.Array(shortCodes), pq.Array(errs))
+	if err != nil {
+		return fmt.Errorf("failed to update import rows: %w", err)
+	}
+	return nil
+}
+
+// GetUserPlan returns the plan of a user's latest active or trialing
+// subscription, defaulting to the free plan
+func (p *PostgresStorage) GetUserPlan(userID int64) (string, error) {
+	var planType string
+	err := p.db.QueryRow(`
+		SELECT plan_type FROM subscriptions
+		WHERE user_id = $1 AND status IN ('active', 'trialing')
+		ORDER BY created_at DESC
+		LIMIT 1`, userID).Scan(&planType)
+	if err == sql.ErrNoRows {
+		return "free", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get subscription plan: %w", err)
+	}
+	return planType, nil
+}
+
+// nullString maps empty strings to NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
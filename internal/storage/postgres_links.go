@@ -0,0 +1,507 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/URLshorter/url-shortener/internal/models"
+)
+
+// userURLColumns are the url_mappings columns scanned by scanUserURL
+const userURLColumns = `
+	id, short_code, original_url, created_at, expires_at, click_count, is_active,
+	is_public, title, description, COALESCE(redirect_type, '302'),
+	COALESCE(destination_version, 1), redirect_rules, password_hash IS NOT NULL,
+	starts_at, max_clicks, COALESCE(fallback_url, ''), image_url, favicon_url,
+	site_name, metadata_fetched_at, social_preview, quarantined_at,
+	COALESCE(quarantine_reason, '')`
+
+// urlTagsColumn aggregates a link's tags into a JSON array, NULL when it has none
+const urlTagsColumn = `(SELECT json_agg(tag ORDER BY tag) FROM url_tags t WHERE t.short_code = url_mappings.short_code)`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanUserURL scans userURLColumns followed by any extra columns into extra
+func scanUserURL(row rowScanner, extra ...interface{}) (*models.UserURLResponse, error) {
+	url := &models.UserURLResponse{}
+	var isPublic sql.NullBool
+	var title, description, imageURL, faviconURL, siteName sql.NullString
+	var expiresAt, startsAt, metadataFetchedAt, quarantinedAt sql.NullTime
+	var maxClicks sql.NullInt64
+	var socialPreview models.SocialPreview
+
+	dest := []interface{}{
+		&url.ID, &url.ShortCode, &url.OriginalURL, &url.CreatedAt,
+		&expiresAt, &url.ClickCount, &url.IsActive,
+		&isPublic, &title, &description, &url.RedirectType,
+		&url.DestinationVersion, &url.RedirectRules, &url.PasswordProtected,
+		&startsAt, &maxClicks, &url.FallbackURL, &imageURL, &faviconURL,
+		&siteName, &metadataFetchedAt, &socialPreview, &quarantinedAt,
+		&url.QuarantineReason,
+	}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set nullable fields
+	if expiresAt.Valid {
+		url.ExpiresAt = &expiresAt.Time
+	}
+	if startsAt.Valid {
+		url.StartsAt = &startsAt.Time
+	}
+	if maxClicks.Valid {
+		url.MaxClicks = &maxClicks.Int64
+	}
+	if title.Valid && title.String != "" {
+		url.Title = &title.String
+	}
+	if description.Valid && description.String != "" {
+		url.Description = &description.String
+	}
+	if imageURL.Valid {
+		url.ImageURL = &imageURL.String
+	}
+	if faviconURL.Valid {
+		url.FaviconURL = &faviconURL.String
+	}
+	if siteName.Valid {
+		url.SiteName = &siteName.String
+	}
+	if metadataFetchedAt.Valid {
+		url.MetadataFetchedAt = &metadataFetchedAt.Time
+	}
+	if !socialPreview.IsEmpty() {
+		url.SocialPreview = &socialPreview
+	}
+	if quarantinedAt.Valid {
+		url.QuarantinedAt = &quarantinedAt.Time
+	}
+	url.IsPublic = !isPublic.Valid || isPublic.Bool
+
+	return url, nil
+}
+
+// scanTaggedUserURL scans userURLColumns followed by a column of tags
+func scanTaggedUserURL(row rowScanner) (*models.UserURLResponse, error) {
+	var tags models.Tags
+	url, err := scanUserURL(row, &tags)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) > 0 {
+		url.Tags = tags
+	}
+	return url, nil
+}
+
+// GetURL retrieves an active link with its dashboard metadata
+func (p *PostgresStorage) GetURL(shortCode string) (*models.URL, error) {
+	query := `
+		SELECT id, short_code, original_url, title, description, user_id, click_count, is_active,
+		       COALESCE(is_public, TRUE), custom_alias, created_at, COALESCE(updated_at, created_at), expires_at
+		FROM url_mappings
+		WHERE short_code = $1 AND is_active = TRUE
+	`
+
+	url := &models.URL{}
+	var title, description, customAlias sql.NullString
+	var createdBy sql.NullInt64
+	var expiresAt sql.NullTime
+
+	err := p.db.QueryRow(query, shortCode).Scan(
+		&url.ID, &url.ShortCode, &url.OriginalURL, &title, &description, &createdBy,
+		&url.ClickCount, &url.IsActive, &url.IsPublic, &customAlias, &url.CreatedAt,
+		&url.UpdatedAt, &expiresAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrURLNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch URL: %w", err)
+	}
+
+	if createdBy.Valid {
+		url.CreatedBy = &createdBy.Int64
+	}
+	if title.Valid {
+		url.Title = &title.String
+	}
+	if description.Valid {
+		url.Description = &description.String
+	}
+	if customAlias.Valid {
+		url.CustomAlias = &customAlias.String
+	}
+	if expiresAt.Valid {
+		url.ExpiresAt = &expiresAt.Time
+	}
+
+	return url, nil
+}
+
+// GetUserURL retrieves an active link as listed on a user's dashboard
+func (p *PostgresStorage) GetUserURL(shortCode string) (*models.UserURLResponse, error) {
+	query := `SELECT ` + userURLColumns + `, ` + urlTagsColumn + ` FROM url_mappings WHERE short_code = $1 AND is_active = TRUE`
+
+	url, err := scanTaggedUserURL(p.db.QueryRow(query, shortCode))
+	if err == sql.ErrNoRows {
+		return nil, ErrURLNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch URL: %w", err)
+	}
+	return url, nil
+}
+
+// ListUserURLs retrieves a page of a user's active links, newest first, with the total count
+func (p *PostgresStorage) ListUserURLs(userID int64, offset, limit int) ([]*models.UserURLResponse, int64, error) {
+	query := `
+		SELECT ` + userURLColumns + `, ` + urlTagsColumn + `
+		FROM url_mappings
+		WHERE user_id = $1 AND is_active = TRUE
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := p.db.Query(query, userID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query user URLs: %w", err)
+	}
+	defer rows.Close()
+
+	var urls []*models.UserURLResponse
+	for rows.Next() {
+		url, err := scanTaggedUserURL(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan URL row: %w", err)
+		}
+		urls = append(urls, url)
+	}
+
+	var total int64
+	err = p.db.QueryRow("SELECT COUNT(*) FROM url_mappings WHERE user_id = $1 AND is_active = TRUE", userID).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count user URLs: %w", err)
+	}
+
+	return urls, total, nil
+}
+
+// ListUserShortCodes retrieves the short codes of a user's active links
+func (p *PostgresStorage) ListUserShortCodes(userID int64) ([]string, error) {
+	rows, err := p.db.Query("SELECT short_code FROM url_mappings WHERE user_id = $1 AND is_active = TRUE", userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user short codes: %w", err)
+	}
+	defer rows.Close()
+
+	var shortCodes []string
+	for rows.Next() {
+		var shortCode string
+		if err := rows.Scan(&shortCode); err != nil {
+			return nil, fmt.Errorf("failed to scan short code: %w", err)
+		}
+		shortCodes = append(shortCodes, shortCode)
+	}
+	return shortCodes, rows.Err()
+}
+
+// UpdateURL applies the non-nil fields of update to a link owned by userID
+func (p *PostgresStorage) UpdateURL(shortCode string, userID int64, update *URLUpdate) error {
+	if update.IsEmpty() {
+		return nil
+	}
+
+	tx, err := p.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := updateURL(tx, shortCode, userID, update); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit URL update: %w", err)
+	}
+	return nil
+}
+
+// updateURL applies a non-empty update within tx
+func updateURL(tx *sql.Tx, shortCode string, userID int64, update *URLUpdate) error {
+	// Build update query dynamically
+	updates := []string{}
+	args := []interface{}{}
+	set := func(column string, value interface{}) {
+		args = append(args, value)
+		updates = append(updates, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	if update.Title != nil {
+		set("title", *update.Title)
+	}
+	if update.Description != nil {
+		set("description", *update.Description)
+	}
+	if update.ExpiresAt != nil {
+		set("expires_at", *update.ExpiresAt)
+	}
+	if update.IsPublic != nil {
+		set("is_public", *update.IsPublic)
+	}
+	if update.RedirectType != nil {
+		set("redirect_type", *update.RedirectType)
+	}
+	if update.RedirectRules != nil {
+		set("redirect_rules", *update.RedirectRules)
+	}
+	if update.SocialPreview != nil {
+		set("social_preview", *update.SocialPreview)
+	}
+	if update.StartsAt != nil {
+		if update.StartsAt.IsZero() {
+			updates = append(updates, "starts_at = NULL")
+		} else {
+			set("starts_at", *update.StartsAt)
+		}
+	}
+	if update.MaxClicks != nil {
+		if *update.MaxClicks == 0 {
+			updates = append(updates, "max_clicks = NULL")
+		} else {
+			set("max_clicks", *update.MaxClicks)
+		}
+	}
+	if update.FallbackURL != nil {
+		args = append(args, *update.FallbackURL)
+		updates = append(updates, fmt.Sprintf("fallback_url = NULLIF($%d, '')", len(args)))
+	}
+	if update.PasswordHash != nil {
+		args = append(args, *update.PasswordHash)
+		updates = append(updates, fmt.Sprintf("password_hash = NULLIF($%d, '')", len(args)))
+	}
+	updates = append(updates, "updated_at = NOW()")
+
+	args = append(args, shortCode, userID)
+	query := fmt.Sprintf(
+		"UPDATE url_mappings SET %s WHERE short_code = $%d AND user_id = $%d AND is_active = TRUE",
+		strings.Join(updates, ", "), len(args)-1, len(args),
+	)
+
+	result, err := tx.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update URL: %w", err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		return ErrURLNotFound
+	}
+	return nil
+}
+
+// SaveLinkMetadata stores metadata fetched from a link's destination unless
+// the destination has changed since destinationVersion
+func (p *PostgresStorage) SaveLinkMetadata(shortCode string, destinationVersion int, metadata *models.LinkMetadata) error {
+	query := `
+		UPDATE url_mappings
+		SET title = COALESCE(NULLIF(title, ''), NULLIF($3, '')),
+		    description = COALESCE(NULLIF(description, ''), NULLIF($4, '')),
+		    image_url = NULLIF($5, ''),
+		    favicon_url = NULLIF($6, ''),
+		    site_name = NULLIF($7, ''),
+		    metadata_fetched_at = NOW()
+		WHERE short_code = $1 AND COALESCE(destination_version, 1) = $2
+	`
+
+	_, err := p.db.Exec(query, shortCode, destinationVersion, metadata.Title, metadata.Description,
+		metadata.ImageURL, metadata.FaviconURL, metadata.SiteName)
+	if err != nil {
+		return fmt.Errorf("failed to save link metadata: %w", err)
+	}
+	return nil
+}
+
+// DeactivateURL soft deletes a link owned by userID
+func (p *PostgresStorage) DeactivateURL(shortCode string, userID int64) error {
+	query := `UPDATE url_mappings SET is_active = FALSE, updated_at = NOW() WHERE short_code = $1 AND user_id = $2 AND is_active = TRUE`
+
+	result, err := p.db.Exec(query, shortCode, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete URL: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check affected rows: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrURLNotFound
+	}
+	return nil
+}
+
+// SetURLActive enables or disables a link regardless of its owner
+func (p *PostgresStorage) SetURLActive(shortCode string, active bool) error {
+	result, err := p.db.Exec(`UPDATE url_mappings SET is_active = $2, updated_at = NOW() WHERE short_code = $1`, shortCode, active)
+	if err != nil {
+		return fmt.Errorf("failed to update URL status: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check affected rows: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrURLNotFound
+	}
+	return nil
+}
+
+// TransferURL makes userID the owner of a link
+func (p *PostgresStorage) TransferURL(shortCode string, userID int64) error {
+	result, err := p.db.Exec(`UPDATE url_mappings SET user_id = $2, updated_at = NOW() WHERE short_code = $1`, shortCode, userID)
+	if err != nil {
+		return fmt.Errorf("failed to transfer URL: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check affected rows: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrURLNotFound
+	}
+	return nil
+}
+
+// GetClickTrends groups a link's recent clicks by hour, day, week or month
+func (p *PostgresStorage) GetClickTrends(shortCode, period string, includeBots bool) ([]models.ClickTrend, error) {
+	var dateFormat, intervalClause string
+
+	// Determine the date range based on period
+	switch period {
+	case "hour":
+		dateFormat = "YYYY-MM-DD HH24:00:00"
+		intervalClause = "24 hours"
+	case "day":
+		dateFormat = "YYYY-MM-DD"
+		intervalClause = "30 days"
+	case "week":
+		dateFormat = "IYYY-IW"
+		intervalClause = "12 weeks"
+	case "month":
+		dateFormat = "YYYY-MM"
+		intervalClause = "12 months"
+	default:
+		return nil, fmt.Errorf("unsupported trend period %q", period)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT TO_CHAR(clicked_at, '%s') as period, COUNT(*) as clicks
+		FROM click_events
+		WHERE short_code = $1 AND clicked_at >= NOW() - INTERVAL '%s' AND %s
+		GROUP BY 1
+		ORDER BY 1
+	`, dateFormat, intervalClause, HumanTrafficFilter(includeBots))
+
+	rows, err := p.db.Query(query, shortCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query click trends: %w", err)
+	}
+	defer rows.Close()
+
+	trends := []models.ClickTrend{}
+	for rows.Next() {
+		var trend models.ClickTrend
+		if err := rows.Scan(&trend.Period, &trend.Clicks); err != nil {
+			return nil, fmt.Errorf("failed to scan click trend: %w", err)
+		}
+		trends = append(trends, trend)
+	}
+
+	return trends, rows.Err()
+}
+
+// GetUserDashboardStats retrieves link and click totals across a user's active links
+func (p *PostgresStorage) GetUserDashboardStats(userID int64, includeBots bool) (*models.UserDashboardStats, error) {
+	stats := &models.UserDashboardStats{}
+
+	// Get total URLs count
+	err := p.db.QueryRow("SELECT COUNT(*) FROM url_mappings WHERE user_id = $1 AND is_active = TRUE", userID).Scan(&stats.TotalURLs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get total URLs: %w", err)
+	}
+
+	// Get active URLs count (same as total in this case since we filter by is_active)
+	stats.ActiveURLs = stats.TotalURLs
+
+	trafficFilter := HumanTrafficFilter(includeBots)
+
+	// Get total clicks; click_count includes every click, so human-only
+	// totals are counted from the click events
+	if includeBots {
+		err = p.db.QueryRow(`
+			SELECT COALESCE(SUM(click_count), 0)
+			FROM url_mappings
+			WHERE user_id = $1 AND is_active = TRUE
+		`, userID).Scan(&stats.TotalClicks)
+	} else {
+		err = p.db.QueryRow(`
+			SELECT COUNT(*)
+			FROM click_events ce
+			JOIN url_mappings um ON ce.short_code = um.short_code
+			WHERE um.user_id = $1
+			  AND um.is_active = TRUE
+			  AND `+trafficFilter, userID).Scan(&stats.TotalClicks)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get total clicks: %w", err)
+	}
+
+	// Get today's clicks
+	err = p.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM click_events ce
+		JOIN url_mappings um ON ce.short_code = um.short_code
+		WHERE um.user_id = $1
+		  AND um.is_active = TRUE
+		  AND DATE(ce.clicked_at) = CURRENT_DATE
+		  AND `+trafficFilter, userID).Scan(&stats.TodayClicks)
+	if err != nil {
+		stats.TodayClicks = 0 // Don't fail if there's no click events table or data
+	}
+
+	// Get this month's clicks
+	err = p.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM click_events ce
+		JOIN url_mappings um ON ce.short_code = um.short_code
+		WHERE um.user_id = $1
+		  AND um.is_active = TRUE
+		  AND EXTRACT(YEAR FROM ce.clicked_at) = EXTRACT(YEAR FROM CURRENT_DATE)
+		  AND EXTRACT(MONTH FROM ce.clicked_at) = EXTRACT(MONTH FROM CURRENT_DATE)
+		  AND `+trafficFilter, userID).Scan(&stats.MonthClicks)
+	if err != nil {
+		stats.MonthClicks = 0 // Don't fail if there's no click events table or data
+	}
+
+	return stats, nil
+}
+
+// HasVerifiedDomain reports whether a user owns a verified, active custom domain
+func (p *PostgresStorage) HasVerifiedDomain(userID int64, domain string) (bool, error) {
+	var exists bool
+	err := p.db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM custom_domains
+			WHERE user_id = $1 AND domain = $2 AND is_verified = TRUE AND is_active = TRUE
+		)`, userID, domain).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check custom domain: %w", err)
+	}
+	return exists, nil
+}
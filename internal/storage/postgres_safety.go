@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/URLshorter/url-shortener/internal/models"
+)
+
+// AddBlocklistEntry stores a blocked domain or URL pattern
+func (p *PostgresStorage) AddBlocklistEntry(entry *models.BlocklistEntry) error {
+	query := `
+		INSERT INTO destination_blocklist (kind, pattern, reason, created_by)
+		VALUES ($1, $2, NULLIF($3, ''), $4)
+		ON CONFLICT (kind, pattern) DO NOTHING
+		RETURNING id, created_at
+	`
+
+	err := p.db.QueryRow(query, entry.Kind, entry.Pattern, entry.Reason, entry.CreatedBy).Scan(&entry.ID, &entry.CreatedAt)
+	if err == sql.ErrNoRows {
+		return ErrBlocklistEntryExists
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save blocklist entry: %w", err)
+	}
+	return nil
+}
+
+// DeleteBlocklistEntry removes a blocklist entry
+func (p *PostgresStorage) DeleteBlocklistEntry(id int64) error {
+	result, err := p.db.Exec(`DELETE FROM destination_blocklist WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete blocklist entry: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrBlocklistEntryNotFound
+	}
+	return nil
+}
+
+// ListBlocklistEntries returns the whole blocklist, oldest first
+func (p *PostgresStorage) ListBlocklistEntries() ([]*models.BlocklistEntry, error) {
+	rows, err := p.db.Query(`
+		SELECT id, kind, pattern, COALESCE(reason, ''), created_by, created_at
+		FROM destination_blocklist
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query blocklist: %w", err)
+	}
+	defer rows.Close()
+
+	return scanBlocklistEntries(rows)
+}
+
+// scanBlocklistEntries scans the rows of a blocklist query
+func scanBlocklistEntries(rows *sql.Rows) ([]*models.BlocklistEntry, error) {
+	entries := []*models.BlocklistEntry{}
+	for rows.Next() {
+		entry := &models.BlocklistEntry{}
+		var createdBy sql.NullInt64
+		if err := rows.Scan(&entry.ID, &entry.Kind, &entry.Pattern, &entry.Reason, &createdBy, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan blocklist entry: %w", err)
+		}
+		if createdBy.Valid {
+			entry.CreatedBy = &createdBy.Int64
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// QuarantineURL marks a link as quarantined. A link that is already
+// quarantined keeps its original time and reason.
+func (p *PostgresStorage) QuarantineURL(shortCode, reason string) error {
+	result, err := p.db.Exec(`
+		UPDATE url_mappings
+		SET quarantined_at = COALESCE(quarantined_at, NOW()),
+		    quarantine_reason = CASE WHEN quarantined_at IS NULL THEN $2 ELSE quarantine_reason END
+		WHERE short_code = $1
+	`, shortCode, reason)
+	if err != nil {
+		return fmt.Errorf("failed to quarantine URL: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrURLNotFound
+	}
+	return nil
+}
+
+// ReleaseURL lifts a link's quarantine
+func (p *PostgresStorage) ReleaseURL(shortCode string) error {
+	result, err := p.db.Exec(`
+		UPDATE url_mappings
+		SET quarantined_at = NULL, quarantine_reason = NULL, safety_checked_at = NOW()
+		WHERE short_code = $1
+	`, shortCode)
+	if err != nil {
+		return fmt.Errorf("failed to release URL: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrURLNotFound
+	}
+	return nil
+}
+
+// ListURLsForSafetyCheck returns active, unquarantined links due for screening
+func (p *PostgresStorage) ListURLsForSafetyCheck(checkedBefore time.Time, limit int) ([]*models.URLMapping, error) {
+	rows, err := p.db.Query(`
+		SELECT id, short_code, original_url, user_id, COALESCE(destination_version, 1),
+		       redirect_rules, COALESCE(fallback_url, '')
+		FROM url_mappings
+		WHERE is_active = TRUE AND quarantined_at IS NULL
+		  AND (safety_checked_at IS NULL OR safety_checked_at < $1)
+		ORDER BY safety_checked_at NULLS FIRST, id
+		LIMIT $2
+	`, checkedBefore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query URLs for safety check: %w", err)
+	}
+	defer rows.Close()
+
+	return scanSafetyCheckMappings(rows)
+}
+
+// scanSafetyCheckMappings scans the columns needed to screen a link's destinations
+func scanSafetyCheckMappings(rows *sql.Rows) ([]*models.URLMapping, error) {
+	mappings := []*models.URLMapping{}
+	for rows.Next() {
+		mapping := &models.URLMapping{IsActive: true}
+		var userID sql.NullInt64
+		err := rows.Scan(&mapping.ID, &mapping.ShortCode, &mapping.OriginalURL, &userID,
+			&mapping.DestinationVersion, &mapping.RedirectRules, &mapping.FallbackURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan URL row: %w", err)
+		}
+		if userID.Valid {
+			mapping.UserID = &userID.Int64
+		}
+		mappings = append(mappings, mapping)
+	}
+	return mappings, rows.Err()
+}
+
+// MarkURLSafetyChecked records when a link was last screened
+func (p *PostgresStorage) MarkURLSafetyChecked(shortCode string, checkedAt time.Time) error {
+	_, err := p.db.Exec(`UPDATE url_mappings SET safety_checked_at = $2 WHERE short_code = $1`, shortCode, checkedAt)
+	if err != nil {
+		return fmt.Errorf("failed to mark URL checked: %w", err)
+	}
+	return nil
+}
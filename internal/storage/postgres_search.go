@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/lib/pq"
+)
+
+// SearchUserURLs returns a page of a user's active links matching search.
+// Text is matched with the search_vector full-text index, and domains with
+// the generated destination_host column.
+func (p *PostgresStorage) SearchUserURLs(userID int64, search *URLSearch) ([]*models.UserURLResponse, error) {
+	args := []interface{}{userID}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	conditions := []string{"user_id = $1", "is_active = TRUE"}
+
+	if search.Text != "" {
+		// The simple configuration also matches words the English one drops as stop words
+		text := arg(search.Text)
+		conditions = append(conditions, fmt.Sprintf(
+			"search_vector @@ (websearch_to_tsquery('english', %s) || websearch_to_tsquery('simple', %s))", text, text))
+	}
+	if len(search.Tags) > 0 {
+		conditions = append(conditions, fmt.Sprintf(
+			"short_code IN (SELECT short_code FROM url_tags WHERE tag = ANY(%s) GROUP BY short_code HAVING COUNT(*) = %s)",
+			arg(pq.Array(search.Tags)), arg(len(search.Tags))))
+	}
+	if search.Domain != "" {
+		conditions = append(conditions, fmt.Sprintf(`(destination_host = %s OR destination_host LIKE %s ESCAPE '\')`,
+			arg(search.Domain), arg(subdomainPattern(search.Domain))))
+	}
+	if search.CreatedFrom != nil {
+		conditions = append(conditions, "created_at >= "+arg(*search.CreatedFrom))
+	}
+	if search.CreatedTo != nil {
+		conditions = append(conditions, "created_at < "+arg(*search.CreatedTo))
+	}
+	switch search.State {
+	case models.URLStateActive:
+		conditions = append(conditions, "(expires_at IS NULL OR expires_at > NOW())")
+	case models.URLStateExpired:
+		conditions = append(conditions, "expires_at <= NOW()")
+	}
+	if search.MinClicks != nil {
+		conditions = append(conditions, "click_count >= "+arg(*search.MinClicks))
+	}
+	if search.MaxClicks != nil {
+		conditions = append(conditions, "click_count <= "+arg(*search.MaxClicks))
+	}
+
+	sortColumn := "created_at"
+	switch search.Sort {
+	case models.URLSortClickCount:
+		sortColumn = "click_count"
+	case models.URLSortTitle:
+		sortColumn = "COALESCE(title, '')"
+	}
+	direction, after := search.searchDirection()
+	if search.After != nil {
+		conditions = append(conditions, fmt.Sprintf("(%s, id) %s (%s, %s)",
+			sortColumn, after, arg(search.cursorValue()), arg(search.After.ID)))
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s, %s
+		FROM url_mappings
+		WHERE %s
+		ORDER BY %s %s, id %s
+		LIMIT %s`,
+		userURLColumns, urlTagsColumn, strings.Join(conditions, " AND "),
+		sortColumn, direction, direction, arg(search.Limit))
+
+	rows, err := p.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search user URLs: %w", err)
+	}
+	defer rows.Close()
+
+	var urls []*models.UserURLResponse
+	for rows.Next() {
+		url, err := scanTaggedUserURL(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan URL row: %w", err)
+		}
+		urls = append(urls, url)
+	}
+	return urls, rows.Err()
+}
@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/URLshorter/url-shortener/internal/models"
+)
+
+// GetTwoFactor returns a user's two-factor settings
+func (p *PostgresStorage) GetTwoFactor(userID int64) (*models.TwoFactor, error) {
+	twoFactor := &models.TwoFactor{UserID: userID}
+	var enabledAt sql.NullTime
+	err := p.db.QueryRow(`
+		SELECT COALESCE(secret, ''), enabled_at, required, last_used_step
+		FROM user_two_factor
+		WHERE user_id = $1
+	`, userID).Scan(&twoFactor.Secret, &enabledAt, &twoFactor.Required, &twoFactor.LastUsedStep)
+	if err == sql.ErrNoRows {
+		return twoFactor, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get two-factor settings: %w", err)
+	}
+	if enabledAt.Valid {
+		twoFactor.EnabledAt = &enabledAt.Time
+	}
+	return twoFactor, nil
+}
+
+// SaveTwoFactorSecret stores a secret awaiting confirmation
+func (p *PostgresStorage) SaveTwoFactorSecret(userID int64, secret string) error {
+	result, err := p.db.Exec(`
+		INSERT INTO user_two_factor (user_id, secret)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, last_used_step = 0, updated_at = NOW()
+		WHERE user_two_factor.enabled_at IS NULL
+	`, userID, secret)
+	if err != nil {
+		return fmt.Errorf("failed to save two-factor secret: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrTwoFactorEnabled
+	}
+	return nil
+}
+
+// EnableTwoFactor confirms the stored secret and replaces the recovery codes
+func (p *PostgresStorage) EnableTwoFactor(userID int64, step int64, recoveryCodeHashes []string) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE user_two_factor
+		SET enabled_at = NOW(), last_used_step = $2, updated_at = NOW()
+		WHERE user_id = $1 AND secret IS NOT NULL AND enabled_at IS NULL
+	`, userID, step)
+	if err != nil {
+		return fmt.Errorf("failed to enable two-factor: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrTwoFactorNotPending
+	}
+
+	if err := replaceRecoveryCodes(tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DisableTwoFactor removes the secret and recovery codes
+func (p *PostgresStorage) DisableTwoFactor(userID int64) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		UPDATE user_two_factor
+		SET secret = NULL, enabled_at = NULL, last_used_step = 0, updated_at = NOW()
+		WHERE user_id = $1
+	`, userID); err != nil {
+		return fmt.Errorf("failed to disable two-factor: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM two_factor_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	return tx.Commit()
+}
+
+// SetTwoFactorRequired sets whether a user must use two-factor
+func (p *PostgresStorage) SetTwoFactorRequired(userID int64, required bool) error {
+	_, err := p.db.Exec(`
+		INSERT INTO user_two_factor (user_id, required)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET required = EXCLUDED.required, updated_at = NOW()
+	`, userID, required)
+	if err != nil {
+		return fmt.Errorf("failed to set two-factor requirement: %w", err)
+	}
+	return nil
+}
+
+// UseTwoFactorStep records the time step of an accepted code
+func (p *PostgresStorage) UseTwoFactorStep(userID int64, step int64) (bool, error) {
+	result, err := p.db.Exec(`
+		UPDATE user_two_factor
+		SET last_used_step = $2
+		WHERE user_id = $1 AND last_used_step < $2
+	`, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("failed to record two-factor code: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+// ReplaceRecoveryCodes swaps a user's recovery codes for new ones
+func (p *PostgresStorage) ReplaceRecoveryCodes(userID int64, codeHashes []string) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(tx, userID, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseRecoveryCode marks an unused recovery code as used
+func (p *PostgresStorage) UseRecoveryCode(userID int64, codeHash string) (bool, error) {
+	result, err := p.db.Exec(`
+		UPDATE two_factor_recovery_codes
+		SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`, userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+// CountRecoveryCodes returns how many unused recovery codes a user has left
+func (p *PostgresStorage) CountRecoveryCodes(userID int64) (int, error) {
+	var count int
+	err := p.db.QueryRow(`
+		SELECT COUNT(*) FROM two_factor_recovery_codes
+		WHERE user_id = $1 AND used_at IS NULL
+	`, userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+	return count, nil
+}
+
+// IsTeamOwner reports whether a user owns an active team
+func (p *PostgresStorage) IsTeamOwner(userID int64) (bool, error) {
+	var owner bool
+	err := p.db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM teams WHERE owner_id = $1 AND is_active = TRUE
+		)`, userID).Scan(&owner)
+	if err != nil {
+		return false, fmt.Errorf("failed to check team ownership: %w", err)
+	}
+	return owner, nil
+}
+
+// replaceRecoveryCodes deletes a user's recovery codes and inserts new ones
+func replaceRecoveryCodes(tx *sql.Tx, userID int64, codeHashes []string) error {
+	if _, err := tx.Exec(`DELETE FROM two_factor_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	for _, hash := range codeHashes {
+		if _, err := tx.Exec(`INSERT INTO two_factor_recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hash); err != nil {
+			return fmt.Errorf("failed to save recovery code: %w", err)
+		}
+	}
+	return nil
+}
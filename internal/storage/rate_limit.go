@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"math"
+	"time"
+)
+
+// TokenBucket is the state of a rate limit bucket after taking a token from it
+type TokenBucket struct {
+	Allowed    bool
+	Limit      int           // bucket capacity
+	Remaining  int           // whole tokens left
+	RetryAfter time.Duration // until the next token, when not allowed
+	ResetAfter time.Duration // until the bucket is full again
+}
+
+// newTokenBucket describes a bucket holding tokens after a take. Buckets hold
+// burst tokens and refill at rate tokens per second.
+func newTokenBucket(allowed bool, tokens, rate float64, burst int) *TokenBucket {
+	bucket := &TokenBucket{
+		Allowed:    allowed,
+		Limit:      burst,
+		Remaining:  int(math.Floor(tokens)),
+		ResetAfter: time.Duration((float64(burst) - tokens) / rate * float64(time.Second)),
+	}
+	if !allowed {
+		bucket.RetryAfter = time.Duration((1 - tokens) / rate * float64(time.Second))
+	}
+	return bucket
+}
+
+// refillTokens returns the tokens a bucket holds after elapsed seconds
+func refillTokens(tokens, elapsed, rate float64, burst int) float64 {
+	if elapsed > 0 {
+		tokens += elapsed * rate
+	}
+	return math.Min(tokens, float64(burst))
+}
+
+// tokenBucketTTL is how long an idle bucket is kept; by then it is full again
+func tokenBucketTTL(rate float64, burst int) time.Duration {
+	return time.Duration(float64(burst)/rate*float64(time.Second)) + time.Second
+}
@@ -44,7 +44,7 @@ func (r *RedisStorage) Close() error {
 
 // SetURLMapping caches a URL mapping in Redis with TTL
 func (r *RedisStorage) SetURLMapping(shortCode string, mapping *models.URLMapping, ttl time.Duration) error {
-	data, err := json.Marshal(mapping)
+	data, err := marshalURLMapping(mapping)
 	if err != nil {
 		return fmt.Errorf("failed to marshal URL mapping: %w", err)
 	}
@@ -69,8 +69,8 @@ func (r *RedisStorage) GetURLMapping(shortCode string) (*models.URLMapping, erro
 		return nil, fmt.Errorf("failed to get URL mapping from cache: %w", err)
 	}
 
-	var mapping models.URLMapping
-	if err := json.Unmarshal([]byte(data), &mapping); err != nil {
+	mapping, err := unmarshalURLMapping([]byte(data))
+	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal URL mapping: %w", err)
 	}
 
@@ -81,7 +81,12 @@ func (r *RedisStorage) GetURLMapping(shortCode string) (*models.URLMapping, erro
 		return nil, ErrURLExpired
 	}
 
-	return &mapping, nil
+	// Scheduled links stay cached but don't resolve before their start time
+	if mapping.StartsAt != nil && mapping.StartsAt.After(time.Now()) {
+		return nil, ErrURLNotYetActive
+	}
+
+	return mapping, nil
 }
 
 // DeleteURLMapping removes a URL mapping from Redis cache
@@ -108,6 +113,25 @@ func (r *RedisStorage) IncrementClickCount(shortCode string) (int64, error) {
 	return count, nil
 }
 
+// DecrementClickCount reverts an increment of the cached click count
+func (r *RedisStorage) DecrementClickCount(shortCode string) error {
+	key := fmt.Sprintf("clicks:%s", shortCode)
+	if err := r.client.Decr(r.ctx, key).Err(); err != nil {
+		return fmt.Errorf("failed to decrement click count in cache: %w", err)
+	}
+	return nil
+}
+
+// SeedClickCount initialises the cached click counter if it doesn't exist yet,
+// so counting resumes from the persisted total after the key expires
+func (r *RedisStorage) SeedClickCount(shortCode string, count int64) error {
+	key := fmt.Sprintf("clicks:%s", shortCode)
+	if err := r.client.SetNX(r.ctx, key, count, 24*time.Hour).Err(); err != nil {
+		return fmt.Errorf("failed to seed click count in cache: %w", err)
+	}
+	return nil
+}
+
 // GetClickCount gets the cached click count
 func (r *RedisStorage) GetClickCount(shortCode string) (int64, error) {
 	key := fmt.Sprintf("clicks:%s", shortCode)
@@ -222,6 +246,27 @@ func (r *RedisStorage) Set(key string, value interface{}, ttl time.Duration) err
 	return nil
 }
 
+// IncrementWithTTL increments a counter and starts its TTL on the first increment
+func (r *RedisStorage) IncrementWithTTL(key string, ttl time.Duration) (int64, error) {
+	count, err := r.client.Incr(r.ctx, key).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to increment key: %w", err)
+	}
+	if count == 1 {
+		r.client.Expire(r.ctx, key, ttl)
+	}
+	return count, nil
+}
+
+// Delete removes a key from Redis
+func (r *RedisStorage) Delete(key string) error {
+	err := r.client.Del(r.ctx, key).Err()
+	if err != nil {
+		return fmt.Errorf("failed to delete key from cache: %w", err)
+	}
+	return nil
+}
+
 // Custom error for cache key not found
 var ErrCacheKeyNotFound = &CacheError{Message: "cache key not found"}
 
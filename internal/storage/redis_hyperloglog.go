@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// PFAdd adds elements to a HyperLogLog sketch and refreshes its TTL when ttl > 0
+func (r *RedisStorage) PFAdd(key string, ttl time.Duration, elements ...interface{}) error {
+	pipe := r.client.TxPipeline()
+	pipe.PFAdd(r.ctx, key, elements...)
+	if ttl > 0 {
+		pipe.Expire(r.ctx, key, ttl)
+	}
+	if _, err := pipe.Exec(r.ctx); err != nil {
+		return fmt.Errorf("failed to add to sketch %s: %w", key, err)
+	}
+	return nil
+}
+
+// PFCount returns the approximate cardinality of the union of the given sketches
+func (r *RedisStorage) PFCount(keys ...string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	count, err := r.client.PFCount(r.ctx, keys...).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count sketch: %w", err)
+	}
+	return count, nil
+}
+
+// PFMerge merges source sketches into dest, keeping dest's existing contents
+func (r *RedisStorage) PFMerge(dest string, ttl time.Duration, keys ...string) error {
+	pipe := r.client.TxPipeline()
+	pipe.PFMerge(r.ctx, dest, keys...)
+	if ttl > 0 {
+		pipe.Expire(r.ctx, dest, ttl)
+	}
+	if _, err := pipe.Exec(r.ctx); err != nil {
+		return fmt.Errorf("failed to merge sketches into %s: %w", dest, err)
+	}
+	return nil
+}
+
+// Exists reports whether a key exists
+func (r *RedisStorage) Exists(key string) (bool, error) {
+	n, err := r.client.Exists(r.ctx, key).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check key: %w", err)
+	}
+	return n > 0, nil
+}
+
+// SetNX stores a value only if the key doesn't exist yet, reporting whether it was set
+func (r *RedisStorage) SetNX(key string, value interface{}, ttl time.Duration) (bool, error) {
+	ok, err := r.client.SetNX(r.ctx, key, value, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to set key in cache: %w", err)
+	}
+	return ok, nil
+}
+
+// GetBytes retrieves a raw value, such as a serialized sketch, by key
+func (r *RedisStorage) GetBytes(key string) ([]byte, error) {
+	val, err := r.client.Get(r.ctx, key).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, ErrCacheKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to get key from cache: %w", err)
+	}
+	return val, nil
+}
+
+// SAdd adds members to a set
+func (r *RedisStorage) SAdd(key string, members ...interface{}) error {
+	if err := r.client.SAdd(r.ctx, key, members...).Err(); err != nil {
+		return fmt.Errorf("failed to add to set %s: %w", key, err)
+	}
+	return nil
+}
+
+// SPopN removes and returns up to count random members of a set
+func (r *RedisStorage) SPopN(key string, count int64) ([]string, error) {
+	members, err := r.client.SPopN(r.ctx, key, count).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to pop from set %s: %w", key, err)
+	}
+	return members, nil
+}
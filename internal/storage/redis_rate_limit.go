@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// takeTokenScript refills a token bucket stored as a hash and takes a token
+// from it in one step, so replicas sharing Redis never over-admit. It uses the
+// Redis clock, which every replica agrees on.
+var takeTokenScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local ttl = tonumber(ARGV[3])
+
+local now = redis.call('TIME')
+now = tonumber(now[1]) + tonumber(now[2]) / 1000000
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('PEXPIRE', KEYS[1], ttl)
+return {allowed, tostring(tokens)}
+`)
+
+// TakeToken takes a token from the bucket at key, which holds burst tokens
+// and refills at rate tokens per second
+func (r *RedisStorage) TakeToken(key string, rate float64, burst int) (*TokenBucket, error) {
+	ttl := tokenBucketTTL(rate, burst).Milliseconds()
+	result, err := takeTokenScript.Run(r.ctx, r.client, []string{key}, rate, burst, ttl).Slice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to take rate limit token: %w", err)
+	}
+	if len(result) != 2 {
+		return nil, fmt.Errorf("unexpected rate limit script result %v", result)
+	}
+
+	allowed, _ := result[0].(int64)
+	tokenString, _ := result[1].(string)
+	tokens, err := strconv.ParseFloat(tokenString, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected rate limit token count %q", tokenString)
+	}
+	return newTokenBucket(allowed == 1, tokens, rate, burst), nil
+}
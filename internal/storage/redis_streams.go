@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// StreamMessage is an entry read from a Redis Stream
+type StreamMessage struct {
+	ID     string
+	Values map[string]interface{}
+}
+
+// PendingStreamMessage describes an entry delivered to a consumer group but not yet acknowledged
+type PendingStreamMessage struct {
+	ID         string
+	Consumer   string
+	Idle       time.Duration
+	Deliveries int64
+}
+
+// StreamAdd appends an entry to a stream, trimming it to roughly maxLen entries when maxLen > 0
+func (r *RedisStorage) StreamAdd(stream string, maxLen int64, values map[string]interface{}) (string, error) {
+	args := &redis.XAddArgs{
+		Stream: stream,
+		Values: values,
+	}
+	if maxLen > 0 {
+		args.MaxLen = maxLen
+		args.Approx = true
+	}
+
+	id, err := r.client.XAdd(r.ctx, args).Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to add to stream %s: %w", stream, err)
+	}
+	return id, nil
+}
+
+// StreamCreateGroup creates a consumer group reading from the start of the
+// stream, creating the stream if needed. An existing group is not an error.
+func (r *RedisStorage) StreamCreateGroup(stream, group string) error {
+	err := r.client.XGroupCreateMkStream(r.ctx, stream, group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create consumer group %s: %w", group, err)
+	}
+	return nil
+}
+
+// StreamReadGroup reads up to count new entries for a consumer, blocking up to block
+func (r *RedisStorage) StreamReadGroup(stream, group, consumer string, count int64, block time.Duration) ([]StreamMessage, error) {
+	streams, err := r.client.XReadGroup(r.ctx, &redis.XReadGroupArgs{
+		Group:    group,
+		Consumer: consumer,
+		Streams:  []string{stream, ">"},
+		Count:    count,
+		Block:    block,
+	}).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read from stream %s: %w", stream, err)
+	}
+
+	var messages []StreamMessage
+	for _, s := range streams {
+		messages = append(messages, toStreamMessages(s.Messages)...)
+	}
+	return messages, nil
+}
+
+// StreamAck acknowledges processed entries for a consumer group
+func (r *RedisStorage) StreamAck(stream, group string, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := r.client.XAck(r.ctx, stream, group, ids...).Err(); err != nil {
+		return fmt.Errorf("failed to ack stream entries: %w", err)
+	}
+	return nil
+}
+
+// StreamPending lists up to count unacknowledged entries idle for at least minIdle
+func (r *RedisStorage) StreamPending(stream, group string, minIdle time.Duration, count int64) ([]PendingStreamMessage, error) {
+	pending, err := r.client.XPendingExt(r.ctx, &redis.XPendingExtArgs{
+		Stream: stream,
+		Group:  group,
+		Idle:   minIdle,
+		Start:  "-",
+		End:    "+",
+		Count:  count,
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending stream entries: %w", err)
+	}
+
+	messages := make([]PendingStreamMessage, len(pending))
+	for i, p := range pending {
+		messages[i] = PendingStreamMessage{
+			ID:         p.ID,
+			Consumer:   p.Consumer,
+			Idle:       p.Idle,
+			Deliveries: p.RetryCount,
+		}
+	}
+	return messages, nil
+}
+
+// StreamClaim transfers idle pending entries to a consumer and returns them.
+// Entries that were trimmed from the stream are not returned.
+func (r *RedisStorage) StreamClaim(stream, group, consumer string, minIdle time.Duration, ids ...string) ([]StreamMessage, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	messages, err := r.client.XClaim(r.ctx, &redis.XClaimArgs{
+		Stream:   stream,
+		Group:    group,
+		Consumer: consumer,
+		MinIdle:  minIdle,
+		Messages: ids,
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim stream entries: %w", err)
+	}
+	return toStreamMessages(messages), nil
+}
+
+// StreamTrimAcknowledged removes the entries group has acknowledged,
+// returning how many were removed. Pending and undelivered entries are kept.
+func (r *RedisStorage) StreamTrimAcknowledged(stream, group string) (int64, error) {
+	groups, err := r.client.XInfoGroups(r.ctx, stream).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read consumer groups of %s: %w", stream, err)
+	}
+	minID := ""
+	for _, g := range groups {
+		if g.Name == group {
+			minID = g.LastDeliveredID
+		}
+	}
+	if minID == "" || minID == "0-0" {
+		return 0, nil
+	}
+
+	// Entries are delivered in order, so everything before the oldest pending
+	// entry, or before the last delivered one if none are pending, is acknowledged
+	pending, err := r.client.XPending(r.ctx, stream, group).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read pending entries of %s: %w", stream, err)
+	}
+	if pending.Count > 0 {
+		minID = pending.Lower
+	}
+
+	n, err := r.client.XTrimMinID(r.ctx, stream, minID).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to trim stream %s: %w", stream, err)
+	}
+	return n, nil
+}
+
+// StreamLen returns the number of entries in a stream
+func (r *RedisStorage) StreamLen(stream string) (int64, error) {
+	n, err := r.client.XLen(r.ctx, stream).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get stream length: %w", err)
+	}
+	return n, nil
+}
+
+func toStreamMessages(messages []redis.XMessage) []StreamMessage {
+	result := make([]StreamMessage, len(messages))
+	for i, m := range messages {
+		result[i] = StreamMessage{ID: m.ID, Values: m.Values}
+	}
+	return result
+}
@@ -0,0 +1,277 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/URLshorter/url-shortener/internal/models"
+)
+
+// URLRepository stores short link mappings and their dashboard metadata
+type URLRepository interface {
+	SaveURLMapping(mapping *models.URLMapping) error
+	GetURLMappingByShortCode(shortCode string) (*models.URLMapping, error)
+	// LookupURLMapping returns a link whatever its state, for administrative use
+	LookupURLMapping(shortCode string) (*models.URLMapping, error)
+	ShortCodeExists(shortCode string) (bool, error)
+	GetClickCount(shortCode string) (int64, error)
+	// CountHumanClicks counts a link's recorded human clicks, the ones its
+	// click limit applies to
+	CountHumanClicks(shortCode string) (int64, error)
+
+	// GetURL returns an active link with its dashboard metadata
+	GetURL(shortCode string) (*models.URL, error)
+	// GetUserURL returns an active link in the shape listed on a user's dashboard
+	GetUserURL(shortCode string) (*models.UserURLResponse, error)
+	ListUserURLs(userID int64, offset, limit int) ([]*models.UserURLResponse, int64, error)
+	ListUserShortCodes(userID int64) ([]string, error)
+	// UpdateURL applies the non-nil fields of update to a link owned by userID
+	UpdateURL(shortCode string, userID int64, update *URLUpdate) error
+	// DeactivateURL soft deletes a link owned by userID, returning ErrURLNotFound if there is none
+	DeactivateURL(shortCode string, userID int64) error
+	// SetURLActive enables or disables a link regardless of its owner
+	SetURLActive(shortCode string, active bool) error
+	// TransferURL makes userID the owner of a link
+	TransferURL(shortCode string, userID int64) error
+
+	// ChangeURLDestination points a link owned by userID at newURL, keeping the
+	// previous destination in its history, and applies update in the same
+	// transaction. Returns the new destination version.
+	ChangeURLDestination(shortCode string, userID int64, newURL string, update *URLUpdate) (int, error)
+	GetDestinationHistory(shortCode string) ([]models.DestinationHistoryEntry, error)
+
+	// SetURLTags replaces a link's tags
+	SetURLTags(shortCode string, tags []string) error
+	// EachUserURL calls fn for each of a user's active links with its tags,
+	// oldest first, without loading them all at once. It stops at the first
+	// error fn returns.
+	EachUserURL(userID int64, fn func(*models.UserURLResponse) error) error
+	// SearchUserURLs returns up to search.Limit of a user's active links
+	// matching search, continuing after search.After
+	SearchUserURLs(userID int64, search *URLSearch) ([]*models.UserURLResponse, error)
+	// SaveLinkMetadata stores metadata fetched from a link's destination. It
+	// fills the title and description only where they are empty and does
+	// nothing if the destination has changed since destinationVersion.
+	SaveLinkMetadata(shortCode string, destinationVersion int, metadata *models.LinkMetadata) error
+}
+
+// URLUpdate lists link fields to change; nil fields are left untouched
+type URLUpdate struct {
+	Title         *string
+	Description   *string
+	ExpiresAt     *time.Time
+	IsPublic      *bool
+	RedirectType  *string
+	RedirectRules *models.RedirectRules
+	StartsAt      *time.Time            // the zero time removes the start
+	MaxClicks     *int64                // 0 removes the click limit
+	FallbackURL   *string               // empty removes the fallback
+	PasswordHash  *string               // empty removes the password
+	SocialPreview *models.SocialPreview // empty removes the custom preview
+}
+
+// IsEmpty reports whether the update changes nothing
+func (u *URLUpdate) IsEmpty() bool {
+	return *u == URLUpdate{}
+}
+
+// URLSearch filters and orders a user's links; zero fields don't filter.
+// Results are ordered by Sort and then by ID so pages can be continued from
+// the last link returned.
+type URLSearch struct {
+	Text        string   // words to match in the title, description and destination
+	Tags        []string // links must have every tag
+	Domain      string   // destination host or any of its subdomains, lowercase
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time // exclusive
+	State       string     // "", models.URLStateActive or models.URLStateExpired
+	MinClicks   *int64
+	MaxClicks   *int64
+	Sort        string // models.URLSortCreatedAt, URLSortClickCount or URLSortTitle
+	Descending  bool
+	After       *URLSearchCursor
+	Limit       int
+}
+
+// URLSearchCursor is the position of the last link of a page: its ID and
+// the value of the field results are sorted by
+type URLSearchCursor struct {
+	ID         int64     `json:"id"`
+	CreatedAt  time.Time `json:"created_at,omitempty"`
+	ClickCount int64     `json:"click_count,omitempty"`
+	Title      string    `json:"title,omitempty"`
+}
+
+// ClickRepository stores click events and answers analytics queries over them.
+// Unless includeBots is set, queries only count human traffic.
+type ClickRepository interface {
+	SaveClickEvents(events []*models.ClickEvent) ([]int64, error)
+	IncrementClickCounts(counts map[string]int64) error
+
+	GetAnalytics(shortCode string, days int, includeBots bool) (*models.AnalyticsResponse, error)
+	GetDestinationStats(shortCode string) ([]models.DestinationStat, error)
+	// GetClickTrends groups recent clicks by hour, day, week or month
+	GetClickTrends(shortCode, period string, includeBots bool) ([]models.ClickTrend, error)
+	GetUserDashboardStats(userID int64, includeBots bool) (*models.UserDashboardStats, error)
+}
+
+// BulkImportRepository stores bulk import jobs and the parsed rows of their
+// files, so imports resume after a restart and report a result per row
+type BulkImportRepository interface {
+	// CreateImportJob saves a job together with its rows
+	CreateImportJob(job *models.BulkImportJob, rows []*models.BulkImportRow) error
+	GetImportJob(id int64) (*models.BulkImportJob, error)
+	// GetImportJobByChecksum returns a user's job for a file, or ErrImportJobNotFound
+	GetImportJobByChecksum(userID int64, checksum string) (*models.BulkImportJob, error)
+	ListUnfinishedImportJobs() ([]*models.BulkImportJob, error)
+	UpdateImportJob(job *models.BulkImportJob) error
+
+	// ListImportRows returns up to limit rows after the given line, ordered by
+	// line and optionally restricted to a status
+	ListImportRows(jobID int64, status string, afterLine, limit int) ([]*models.BulkImportRow, error)
+	// UpdateImportRows records the outcome of processed rows
+	UpdateImportRows(rows []*models.BulkImportRow) error
+}
+
+// SafetyRepository stores the destination blocklist and the quarantine state
+// of links whose destination was found to be malicious
+type SafetyRepository interface {
+	// AddBlocklistEntry stores an entry and sets its ID and creation time. It
+	// returns ErrBlocklistEntryExists if the pattern is already blocked.
+	AddBlocklistEntry(entry *models.BlocklistEntry) error
+	// DeleteBlocklistEntry returns ErrBlocklistEntryNotFound if there is no such entry
+	DeleteBlocklistEntry(id int64) error
+	ListBlocklistEntries() ([]*models.BlocklistEntry, error)
+
+	// QuarantineURL makes a link show a warning instead of redirecting;
+	// ReleaseURL lifts it. Both return ErrURLNotFound for unknown links.
+	QuarantineURL(shortCode, reason string) error
+	ReleaseURL(shortCode string) error
+	// ListURLsForSafetyCheck returns up to limit active links that are not
+	// quarantined and were last screened before checkedBefore, or never,
+	// least recently screened first
+	ListURLsForSafetyCheck(checkedBefore time.Time, limit int) ([]*models.URLMapping, error)
+	// MarkURLSafetyChecked records when a link's destinations were screened
+	MarkURLSafetyChecked(shortCode string, checkedAt time.Time) error
+}
+
+// UserRepository stores accounts, preferences and their verification tokens
+type UserRepository interface {
+	CreateUser(user *models.User) error
+	GetUserByID(id int64) (*models.User, error)
+	GetUserByEmail(email string) (*models.User, error)
+	GetUserByPhone(phone string) (*models.User, error)
+	UpdateUser(user *models.User) error
+	UpdateUserLastLogin(userID int64, loginTime time.Time) error
+	UpdateUserPassword(userID int64, passwordHash string) error
+	UpdateUserStatus(userID int64, isActive bool) error
+	UpdateUserEmailVerified(userID int64, verified bool) error
+	UpdateUserPhoneVerified(userID int64, verified bool) error
+
+	CreateUserPreferences(prefs *models.UserPreferences) error
+	GetUserPreferences(userID int64) (*models.UserPreferences, error)
+	UpdateUserPreferences(prefs *models.UserPreferences) error
+
+	CreateEmailVerification(verification *models.EmailVerification) error
+	GetEmailVerificationByToken(token string) (*models.EmailVerification, error)
+	GetLatestEmailVerification(userID int64) (*models.EmailVerification, error)
+	UpdateEmailVerification(id string, verifiedAt time.Time) error
+	InvalidateEmailVerifications(userID int64) error // closes a user's pending verifications
+
+	CreatePhoneVerification(verification *models.PhoneVerification) error
+	GetPhoneVerificationByUserID(userID int64) (*models.PhoneVerification, error)
+	UpdatePhoneVerificationAttempts(userID int64, attempts int) error
+	UpdatePhoneVerification(id string, verifiedAt time.Time) error
+	DeletePhoneVerificationByUserID(userID int64) error
+
+	CreatePasswordReset(reset *models.PasswordReset) error
+	GetPasswordResetByToken(token string) (*models.PasswordReset, error)
+	UpdatePasswordReset(id string, usedAt time.Time) error
+}
+
+// TwoFactorRepository stores authenticator app secrets and the hashes of
+// single-use recovery codes
+type TwoFactorRepository interface {
+	// GetTwoFactor returns a user's two-factor settings; users who never set
+	// it up get settings with nothing enabled
+	GetTwoFactor(userID int64) (*models.TwoFactor, error)
+	// SaveTwoFactorSecret stores a secret awaiting confirmation, replacing any
+	// earlier unconfirmed one
+	SaveTwoFactorSecret(userID int64, secret string) error
+	// EnableTwoFactor confirms the stored secret, recording step as the last
+	// used, and replaces the recovery codes
+	EnableTwoFactor(userID int64, step int64, recoveryCodeHashes []string) error
+	// DisableTwoFactor removes the secret and recovery codes but keeps
+	// whether two-factor is required
+	DisableTwoFactor(userID int64) error
+	SetTwoFactorRequired(userID int64, required bool) error
+	// UseTwoFactorStep records the time step of an accepted code. It returns
+	// false if that step or a later one was already used, so a code can't be
+	// replayed.
+	UseTwoFactorStep(userID int64, step int64) (bool, error)
+
+	ReplaceRecoveryCodes(userID int64, codeHashes []string) error
+	// UseRecoveryCode marks an unused recovery code as used, returning false
+	// if the user has no such unused code
+	UseRecoveryCode(userID int64, codeHash string) (bool, error)
+	CountRecoveryCodes(userID int64) (int, error)
+}
+
+// SessionRepository stores login sessions
+type SessionRepository interface {
+	CreateSession(session *models.UserSession) error
+	GetSessionByID(id string) (*models.UserSession, error)
+}
+
+// Cache is the fast key-value layer in front of the repositories: cached
+// mappings, click counters, analytics snapshots and short-lived counters.
+// Lookups of missing keys return ErrCacheKeyNotFound.
+type Cache interface {
+	SetURLMapping(shortCode string, mapping *models.URLMapping, ttl time.Duration) error
+	GetURLMapping(shortCode string) (*models.URLMapping, error)
+	DeleteURLMapping(shortCode string) error
+
+	IncrementClickCount(shortCode string) (int64, error)
+	DecrementClickCount(shortCode string) error
+	SeedClickCount(shortCode string, count int64) error
+	GetClickCount(shortCode string) (int64, error)
+
+	SetAnalytics(shortCode string, analytics *models.AnalyticsResponse, ttl time.Duration) error
+	GetAnalytics(shortCode string) (*models.AnalyticsResponse, error)
+
+	Get(key string) (string, error)
+	Set(key string, value interface{}, ttl time.Duration) error
+	IncrementWithTTL(key string, ttl time.Duration) (int64, error)
+	TakeToken(key string, rate float64, burst int) (*TokenBucket, error)
+	Delete(key string) error
+	IsHealthy() bool
+}
+
+// LinkStore bundles the repositories behind links and their analytics
+type LinkStore interface {
+	URLRepository
+	ClickRepository
+	BulkImportRepository
+	SafetyRepository
+}
+
+// AccountStore bundles the repositories behind user accounts
+type AccountStore interface {
+	UserRepository
+	TwoFactorRepository
+	SessionRepository
+}
+
+// Store bundles the repositories a storage backend provides
+type Store interface {
+	LinkStore
+	AccountStore
+	Close() error
+}
+
+var (
+	_ Store = (*PostgresStorage)(nil)
+	_ Store = (*SQLiteStorage)(nil)
+	_ Store = (*MemoryStorage)(nil)
+	_ Cache = (*RedisStorage)(nil)
+	_ Cache = (*MemoryCache)(nil)
+)
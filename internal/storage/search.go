@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/URLshorter/url-shortener/internal/models"
+)
+
+// Helpers shared by the backends that can't search with Postgres full-text
+// indexes and generated columns.
+
+// destinationHost returns the lowercase host of a destination URL, or an
+// empty string if it has none
+func destinationHost(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Hostname())
+}
+
+// matchesDomain reports whether host is domain or one of its subdomains
+func matchesDomain(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// searchTerms splits search text into lowercase words, all of which must
+// appear in a matching link
+func searchTerms(text string) []string {
+	return strings.Fields(strings.ToLower(text))
+}
+
+// likeEscaper escapes LIKE wildcards for a LIKE ... ESCAPE '\' clause
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// likePattern matches values containing term in a LIKE ... ESCAPE '\' clause
+func likePattern(term string) string {
+	return "%" + likeEscaper.Replace(term) + "%"
+}
+
+// subdomainPattern matches subdomains of domain in a LIKE ... ESCAPE '\' clause
+func subdomainPattern(domain string) string {
+	return "%." + likeEscaper.Replace(domain)
+}
+
+// cursorValue returns the cursor's value of the field results are sorted by
+func (s *URLSearch) cursorValue() interface{} {
+	switch s.Sort {
+	case models.URLSortClickCount:
+		return s.After.ClickCount
+	case models.URLSortTitle:
+		return s.After.Title
+	default:
+		return s.After.CreatedAt
+	}
+}
+
+// searchDirection returns the SQL sort direction and the comparison that
+// selects links after the cursor
+func (s *URLSearch) searchDirection() (string, string) {
+	if s.Descending {
+		return "DESC", "<"
+	}
+	return "ASC", ">"
+}
@@ -0,0 +1,1388 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/URLshorter/url-shortener/configs"
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/mattn/go-sqlite3"
+)
+
+// sqliteDriverName is the go-sqlite3 driver extended with the functions
+// queries need: url_host(url) returns a destination's lowercase host
+const sqliteDriverName = "sqlite3_urlshortener"
+
+func init() {
+	sql.Register(sqliteDriverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("url_host", destinationHost, true)
+		},
+	})
+}
+
+// SQLiteStorage is a Store backed by a single SQLite file, for single-node
+// installs that don't run Postgres. Timestamps of click events are stored in
+// UTC so that range queries can compare them as text. Timestamp expressions
+// lose their column type in SQLite, so queries select timestamp columns bare.
+type SQLiteStorage struct {
+	db *sql.DB
+}
+
+// NewSQLiteStorage opens (creating if needed) the SQLite database at config.SQLitePath
+func NewSQLiteStorage(config *configs.Config) (*SQLiteStorage, error) {
+	if dir := filepath.Dir(config.SQLitePath); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create database directory: %w", err)
+		}
+	}
+
+	// WAL lets redirects read while clicks are written; immediate transactions
+	// take the write lock up front instead of failing to upgrade it later
+	dsn := fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=5000&_foreign_keys=on&_txlock=immediate", config.SQLitePath)
+	db, err := sql.Open(sqliteDriverName, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	storage := &SQLiteStorage{db: db}
+
+	// Initialize tables if they don't exist
+	if err := storage.initTables(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize tables: %w", err)
+	}
+
+	return storage, nil
+}
+
+// Close closes the database connection
+func (s *SQLiteStorage) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLiteStorage) initTables() error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS url_mappings (
+			id INTEGER PRIMARY KEY,
+			short_code TEXT UNIQUE NOT NULL,
+			original_url TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			expires_at TIMESTAMP,
+			click_count INTEGER NOT NULL DEFAULT 0,
+			is_active BOOLEAN NOT NULL DEFAULT 1,
+			created_by_ip TEXT,
+			user_id INTEGER,
+			is_public BOOLEAN DEFAULT 1,
+			title TEXT,
+			description TEXT,
+			custom_alias TEXT,
+			updated_at TIMESTAMP,
+			redirect_type TEXT NOT NULL DEFAULT '302',
+			destination_version INTEGER NOT NULL DEFAULT 1,
+			destination_updated_at TIMESTAMP,
+			redirect_rules TEXT,
+			password_hash TEXT,
+			starts_at TIMESTAMP,
+			max_clicks INTEGER,
+			fallback_url TEXT,
+			image_url TEXT,
+			favicon_url TEXT,
+			site_name TEXT,
+			metadata_fetched_at TIMESTAMP,
+			social_preview TEXT,
+			quarantined_at TIMESTAMP,
+			quarantine_reason TEXT,
+			safety_checked_at TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_url_mappings_user ON url_mappings(user_id, is_active)`,
+		`CREATE TABLE IF NOT EXISTS click_events (
+			id INTEGER PRIMARY KEY,
+			short_code TEXT NOT NULL,
+			clicked_at TIMESTAMP NOT NULL,
+			ip_address TEXT,
+			user_agent TEXT,
+			referrer TEXT,
+			country_code TEXT,
+			destination_version INTEGER,
+			traffic_class TEXT NOT NULL DEFAULT 'human',
+			source TEXT
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_click_events_short_code_time ON click_events(short_code, clicked_at)`,
+		`CREATE TABLE IF NOT EXISTS url_destination_history (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			short_code TEXT NOT NULL,
+			version INTEGER NOT NULL,
+			original_url TEXT NOT NULL,
+			active_from TIMESTAMP NOT NULL,
+			replaced_at TIMESTAMP NOT NULL,
+			replaced_by INTEGER,
+			UNIQUE (short_code, version)
+		)`,
+		`CREATE TABLE IF NOT EXISTS url_tags (
+			short_code TEXT NOT NULL REFERENCES url_mappings(short_code) ON DELETE CASCADE,
+			tag TEXT NOT NULL,
+			PRIMARY KEY (short_code, tag)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_url_tags_tag ON url_tags(tag)`,
+		`CREATE TABLE IF NOT EXISTS bulk_import_jobs (
+			id INTEGER PRIMARY KEY,
+			user_id INTEGER NOT NULL,
+			format TEXT NOT NULL,
+			checksum TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			total_rows INTEGER NOT NULL DEFAULT 0,
+			processed_rows INTEGER NOT NULL DEFAULT 0,
+			created_rows INTEGER NOT NULL DEFAULT 0,
+			failed_rows INTEGER NOT NULL DEFAULT 0,
+			error TEXT,
+			created_at TIMESTAMP NOT NULL,
+			started_at TIMESTAMP,
+			finished_at TIMESTAMP,
+			UNIQUE (user_id, checksum)
+		)`,
+		`CREATE TABLE IF NOT EXISTS bulk_import_rows (
+			job_id INTEGER NOT NULL REFERENCES bulk_import_jobs(id) ON DELETE CASCADE,
+			line INTEGER NOT NULL,
+			url TEXT NOT NULL,
+			custom_code TEXT,
+			expires_at TIMESTAMP,
+			title TEXT,
+			tags TEXT,
+			status TEXT NOT NULL DEFAULT 'pending',
+			short_code TEXT,
+			error TEXT,
+			PRIMARY KEY (job_id, line)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_bulk_import_rows_status ON bulk_import_rows(job_id, status, line)`,
+		`CREATE TABLE IF NOT EXISTS destination_blocklist (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			kind TEXT NOT NULL,
+			pattern TEXT NOT NULL,
+			reason TEXT,
+			created_by INTEGER,
+			created_at TIMESTAMP NOT NULL,
+			UNIQUE (kind, pattern)
+		)`,
+		`CREATE TABLE IF NOT EXISTS users (
+			id INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			email TEXT UNIQUE NOT NULL,
+			phone TEXT UNIQUE,
+			password_hash TEXT,
+			email_verified BOOLEAN NOT NULL DEFAULT 0,
+			phone_verified BOOLEAN NOT NULL DEFAULT 0,
+			provider TEXT NOT NULL DEFAULT 'email',
+			provider_id TEXT,
+			avatar_url TEXT,
+			account_type TEXT NOT NULL DEFAULT 'free',
+			is_active BOOLEAN NOT NULL DEFAULT 1,
+			is_admin BOOLEAN NOT NULL DEFAULT 0,
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL,
+			last_login_at TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS user_preferences (
+			user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			default_expiration INTEGER,
+			analytics_public BOOLEAN NOT NULL DEFAULT 0,
+			email_notifications BOOLEAN NOT NULL DEFAULT 1,
+			marketing_emails BOOLEAN NOT NULL DEFAULT 0,
+			timezone TEXT NOT NULL DEFAULT 'UTC',
+			theme TEXT NOT NULL DEFAULT 'light'
+		)`,
+		`CREATE TABLE IF NOT EXISTS user_two_factor (
+			user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			secret TEXT,
+			enabled_at TIMESTAMP,
+			required BOOLEAN NOT NULL DEFAULT 0,
+			last_used_step INTEGER NOT NULL DEFAULT 0,
+			updated_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS two_factor_recovery_codes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			code_hash TEXT NOT NULL,
+			used_at TIMESTAMP,
+			created_at TIMESTAMP NOT NULL,
+			UNIQUE (user_id, code_hash)
+		)`,
+		`CREATE TABLE IF NOT EXISTS user_sessions (
+			id TEXT PRIMARY KEY,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			device_info TEXT,
+			ip_address TEXT,
+			user_agent TEXT,
+			refresh_token_hash TEXT,
+			expires_at TIMESTAMP NOT NULL,
+			created_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS email_verifications (
+			id TEXT PRIMARY KEY,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			token TEXT UNIQUE NOT NULL,
+			expires_at TIMESTAMP NOT NULL,
+			verified_at TIMESTAMP,
+			created_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS phone_verifications (
+			id TEXT PRIMARY KEY,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			phone TEXT NOT NULL,
+			otp_code TEXT NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			expires_at TIMESTAMP NOT NULL,
+			verified_at TIMESTAMP,
+			created_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS password_resets (
+			id TEXT PRIMARY KEY,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			token TEXT UNIQUE NOT NULL,
+			expires_at TIMESTAMP NOT NULL,
+			used_at TIMESTAMP,
+			created_at TIMESTAMP NOT NULL
+		)`,
+	}
+
+	for _, query := range queries {
+		if _, err := s.db.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute query: %s, error: %w", query, err)
+		}
+	}
+
+	// Columns added after a table was first created
+	columns := []struct{ table, column, definition string }{
+		{"click_events", "source", "TEXT"},
+		{"url_mappings", "image_url", "TEXT"},
+		{"url_mappings", "favicon_url", "TEXT"},
+		{"url_mappings", "site_name", "TEXT"},
+		{"url_mappings", "metadata_fetched_at", "TIMESTAMP"},
+		{"url_mappings", "social_preview", "TEXT"},
+		{"url_mappings", "quarantined_at", "TIMESTAMP"},
+		{"url_mappings", "quarantine_reason", "TEXT"},
+		{"url_mappings", "safety_checked_at", "TIMESTAMP"},
+	}
+	for _, c := range columns {
+		if err := s.addMissingColumn(c.table, c.column, c.definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addMissingColumn adds a column to a table created by an earlier version
+func (s *SQLiteStorage) addMissingColumn(table, column, definition string) error {
+	rows, err := s.db.Query(fmt.Sprintf("SELECT name FROM pragma_table_info('%s')", table))
+	if err != nil {
+		return fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return fmt.Errorf("failed to read columns of %s: %w", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
+	rows.Close()
+
+	if _, err := s.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
+	return nil
+}
+
+// SaveURLMapping saves a URL mapping to the database
+func (s *SQLiteStorage) SaveURLMapping(mapping *models.URLMapping) error {
+	query := `
+		INSERT INTO url_mappings (id, short_code, original_url, created_at, expires_at, created_by_ip, user_id, redirect_type, redirect_rules, password_hash,
+		                          starts_at, max_clicks, fallback_url, updated_at, destination_updated_at, social_preview)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''), ?, ?, NULLIF(?, ''), ?, ?, ?)
+	`
+	redirectType := mapping.RedirectType
+	if redirectType == "" {
+		redirectType = models.DefaultRedirectType
+	}
+	_, err := s.db.Exec(query, mapping.ID, mapping.ShortCode, mapping.OriginalURL,
+		mapping.CreatedAt, mapping.ExpiresAt, mapping.CreatedByIP, mapping.UserID, redirectType, mapping.RedirectRules, mapping.PasswordHash,
+		mapping.StartsAt, mapping.MaxClicks, mapping.FallbackURL, mapping.CreatedAt, mapping.CreatedAt, mapping.SocialPreview)
+	if err != nil {
+		return fmt.Errorf("failed to save URL mapping: %w", err)
+	}
+	return nil
+}
+
+// GetURLMappingByShortCode retrieves an active URL mapping that can currently be resolved
+func (s *SQLiteStorage) GetURLMappingByShortCode(shortCode string) (*models.URLMapping, error) {
+	mapping, err := s.getURLMapping(shortCode, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if mapping.ExpiresAt != nil && mapping.ExpiresAt.Before(time.Now()) {
+		return nil, ErrURLExpired
+	}
+	if mapping.StartsAt != nil && mapping.StartsAt.After(time.Now()) {
+		return nil, ErrURLNotYetActive
+	}
+
+	return mapping, nil
+}
+
+// LookupURLMapping retrieves a URL mapping whatever its state, including
+// deleted, expired and scheduled links
+func (s *SQLiteStorage) LookupURLMapping(shortCode string) (*models.URLMapping, error) {
+	return s.getURLMapping(shortCode, false)
+}
+
+func (s *SQLiteStorage) getURLMapping(shortCode string, activeOnly bool) (*models.URLMapping, error) {
+	query := `
+		SELECT id, short_code, original_url, created_at, expires_at, click_count, is_active, created_by_ip, user_id,
+		       redirect_type, destination_version, redirect_rules,
+		       COALESCE(password_hash, ''), starts_at, max_clicks, COALESCE(fallback_url, ''), social_preview,
+		       quarantined_at, COALESCE(quarantine_reason, '')
+		FROM url_mappings
+		WHERE short_code = ? AND (is_active = 1 OR NOT ?)
+	`
+
+	mapping := &models.URLMapping{}
+	var expiresAt, startsAt, quarantinedAt sql.NullTime
+	var createdByIP sql.NullString
+	var userID, maxClicks sql.NullInt64
+	var socialPreview models.SocialPreview
+
+	err := s.db.QueryRow(query, shortCode, activeOnly).Scan(
+		&mapping.ID, &mapping.ShortCode, &mapping.OriginalURL,
+		&mapping.CreatedAt, &expiresAt, &mapping.ClickCount,
+		&mapping.IsActive, &createdByIP, &userID, &mapping.RedirectType,
+		&mapping.DestinationVersion, &mapping.RedirectRules, &mapping.PasswordHash,
+		&startsAt, &maxClicks, &mapping.FallbackURL, &socialPreview,
+		&quarantinedAt, &mapping.QuarantineReason,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrURLNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get URL mapping: %w", err)
+	}
+
+	if expiresAt.Valid {
+		mapping.ExpiresAt = &expiresAt.Time
+	}
+	if !socialPreview.IsEmpty() {
+		mapping.SocialPreview = &socialPreview
+	}
+	if quarantinedAt.Valid {
+		mapping.QuarantinedAt = &quarantinedAt.Time
+	}
+	if createdByIP.Valid {
+		mapping.CreatedByIP = createdByIP.String
+	}
+	if userID.Valid {
+		mapping.UserID = &userID.Int64
+	}
+	if startsAt.Valid {
+		mapping.StartsAt = &startsAt.Time
+	}
+	if maxClicks.Valid {
+		mapping.MaxClicks = &maxClicks.Int64
+	}
+
+	return mapping, nil
+}
+
+// ShortCodeExists checks if a short code already exists, including deleted links
+func (s *SQLiteStorage) ShortCodeExists(shortCode string) (bool, error) {
+	var exists bool
+	err := s.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM url_mappings WHERE short_code = ?)`, shortCode).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if short code exists: %w", err)
+	}
+	return exists, nil
+}
+
+// GetClickCount returns the persisted click count for a URL mapping
+func (s *SQLiteStorage) GetClickCount(shortCode string) (int64, error) {
+	var count int64
+	err := s.db.QueryRow(`SELECT click_count FROM url_mappings WHERE short_code = ?`, shortCode).Scan(&count)
+	if err == sql.ErrNoRows {
+		return 0, ErrURLNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get click count: %w", err)
+	}
+	return count, nil
+}
+
+// CountHumanClicks counts a link's recorded human clicks
+func (s *SQLiteStorage) CountHumanClicks(shortCode string) (int64, error) {
+	var count int64
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM click_events WHERE short_code = ? AND traffic_class = 'human'`, shortCode).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count clicks: %w", err)
+	}
+	return count, nil
+}
+
+// GetURL retrieves an active link with its dashboard metadata
+func (s *SQLiteStorage) GetURL(shortCode string) (*models.URL, error) {
+	query := `
+		SELECT id, short_code, original_url, title, description, user_id, click_count, is_active,
+		       COALESCE(is_public, 1), custom_alias, created_at, updated_at, expires_at
+		FROM url_mappings
+		WHERE short_code = ? AND is_active = 1
+	`
+
+	url := &models.URL{}
+	var title, description, customAlias sql.NullString
+	var createdBy sql.NullInt64
+	var expiresAt sql.NullTime
+
+	err := s.db.QueryRow(query, shortCode).Scan(
+		&url.ID, &url.ShortCode, &url.OriginalURL, &title, &description, &createdBy,
+		&url.ClickCount, &url.IsActive, &url.IsPublic, &customAlias, &url.CreatedAt,
+		&url.UpdatedAt, &expiresAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrURLNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch URL: %w", err)
+	}
+
+	if createdBy.Valid {
+		url.CreatedBy = &createdBy.Int64
+	}
+	if title.Valid {
+		url.Title = &title.String
+	}
+	if description.Valid {
+		url.Description = &description.String
+	}
+	if customAlias.Valid {
+		url.CustomAlias = &customAlias.String
+	}
+	if expiresAt.Valid {
+		url.ExpiresAt = &expiresAt.Time
+	}
+
+	return url, nil
+}
+
+// sqliteURLTagsColumn aggregates a link's tags into a JSON array
+const sqliteURLTagsColumn = `(SELECT json_group_array(tag ORDER BY tag) FROM url_tags t WHERE t.short_code = url_mappings.short_code)`
+
+// GetUserURL retrieves an active link as listed on a user's dashboard
+func (s *SQLiteStorage) GetUserURL(shortCode string) (*models.UserURLResponse, error) {
+	query := `SELECT ` + userURLColumns + `, ` + sqliteURLTagsColumn + ` FROM url_mappings WHERE short_code = ? AND is_active = 1`
+
+	url, err := scanTaggedUserURL(s.db.QueryRow(query, shortCode))
+	if err == sql.ErrNoRows {
+		return nil, ErrURLNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch URL: %w", err)
+	}
+	return url, nil
+}
+
+// ListUserURLs retrieves a page of a user's active links, newest first, with the total count
+func (s *SQLiteStorage) ListUserURLs(userID int64, offset, limit int) ([]*models.UserURLResponse, int64, error) {
+	var total int64
+	err := s.db.QueryRow("SELECT COUNT(*) FROM url_mappings WHERE user_id = ? AND is_active = 1", userID).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count user URLs: %w", err)
+	}
+
+	query := `
+		SELECT ` + userURLColumns + `, ` + sqliteURLTagsColumn + `
+		FROM url_mappings
+		WHERE user_id = ? AND is_active = 1
+		ORDER BY created_at DESC
+		LIMIT ? OFFSET ?
+	`
+	rows, err := s.db.Query(query, userID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query user URLs: %w", err)
+	}
+	defer rows.Close()
+
+	var urls []*models.UserURLResponse
+	for rows.Next() {
+		url, err := scanTaggedUserURL(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan URL row: %w", err)
+		}
+		urls = append(urls, url)
+	}
+
+	return urls, total, rows.Err()
+}
+
+// ListUserShortCodes retrieves the short codes of a user's active links
+func (s *SQLiteStorage) ListUserShortCodes(userID int64) ([]string, error) {
+	rows, err := s.db.Query("SELECT short_code FROM url_mappings WHERE user_id = ? AND is_active = 1", userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user short codes: %w", err)
+	}
+	defer rows.Close()
+
+	var shortCodes []string
+	for rows.Next() {
+		var shortCode string
+		if err := rows.Scan(&shortCode); err != nil {
+			return nil, fmt.Errorf("failed to scan short code: %w", err)
+		}
+		shortCodes = append(shortCodes, shortCode)
+	}
+	return shortCodes, rows.Err()
+}
+
+// UpdateURL applies the non-nil fields of update to a link owned by userID
+func (s *SQLiteStorage) UpdateURL(shortCode string, userID int64, update *URLUpdate) error {
+	if update.IsEmpty() {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.updateURL(tx, shortCode, userID, update); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit URL update: %w", err)
+	}
+	return nil
+}
+
+// updateURL applies a non-empty update within tx
+func (s *SQLiteStorage) updateURL(tx *sql.Tx, shortCode string, userID int64, update *URLUpdate) error {
+	// Build update query dynamically
+	updates := []string{}
+	args := []interface{}{}
+	set := func(assignment string, value interface{}) {
+		updates = append(updates, assignment)
+		args = append(args, value)
+	}
+
+	if update.Title != nil {
+		set("title = ?", *update.Title)
+	}
+	if update.Description != nil {
+		set("description = ?", *update.Description)
+	}
+	if update.ExpiresAt != nil {
+		set("expires_at = ?", *update.ExpiresAt)
+	}
+	if update.IsPublic != nil {
+		set("is_public = ?", *update.IsPublic)
+	}
+	if update.RedirectType != nil {
+		set("redirect_type = ?", *update.RedirectType)
+	}
+	if update.RedirectRules != nil {
+		set("redirect_rules = ?", *update.RedirectRules)
+	}
+	if update.SocialPreview != nil {
+		set("social_preview = ?", *update.SocialPreview)
+	}
+	if update.StartsAt != nil {
+		if update.StartsAt.IsZero() {
+			updates = append(updates, "starts_at = NULL")
+		} else {
+			set("starts_at = ?", *update.StartsAt)
+		}
+	}
+	if update.MaxClicks != nil {
+		set("max_clicks = NULLIF(?, 0)", *update.MaxClicks)
+	}
+	if update.FallbackURL != nil {
+		set("fallback_url = NULLIF(?, '')", *update.FallbackURL)
+	}
+	if update.PasswordHash != nil {
+		set("password_hash = NULLIF(?, '')", *update.PasswordHash)
+	}
+	set("updated_at = ?", time.Now())
+
+	query := "UPDATE url_mappings SET "
+	for i, assignment := range updates {
+		if i > 0 {
+			query += ", "
+		}
+		query += assignment
+	}
+	query += " WHERE short_code = ? AND user_id = ? AND is_active = 1"
+	args = append(args, shortCode, userID)
+
+	result, err := tx.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update URL: %w", err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		return ErrURLNotFound
+	}
+	return nil
+}
+
+// SaveLinkMetadata stores metadata fetched from a link's destination unless
+// the destination has changed since destinationVersion
+func (s *SQLiteStorage) SaveLinkMetadata(shortCode string, destinationVersion int, metadata *models.LinkMetadata) error {
+	query := `
+		UPDATE url_mappings
+		SET title = COALESCE(NULLIF(title, ''), NULLIF(?, '')),
+		    description = COALESCE(NULLIF(description, ''), NULLIF(?, '')),
+		    image_url = NULLIF(?, ''),
+		    favicon_url = NULLIF(?, ''),
+		    site_name = NULLIF(?, ''),
+		    metadata_fetched_at = ?
+		WHERE short_code = ? AND destination_version = ?
+	`
+
+	_, err := s.db.Exec(query, metadata.Title, metadata.Description, metadata.ImageURL,
+		metadata.FaviconURL, metadata.SiteName, time.Now(), shortCode, destinationVersion)
+	if err != nil {
+		return fmt.Errorf("failed to save link metadata: %w", err)
+	}
+	return nil
+}
+
+// DeactivateURL soft deletes a link owned by userID
+func (s *SQLiteStorage) DeactivateURL(shortCode string, userID int64) error {
+	query := `UPDATE url_mappings SET is_active = 0, updated_at = ? WHERE short_code = ? AND user_id = ? AND is_active = 1`
+
+	result, err := s.db.Exec(query, time.Now(), shortCode, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete URL: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check affected rows: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrURLNotFound
+	}
+	return nil
+}
+
+// SetURLActive enables or disables a link regardless of its owner
+func (s *SQLiteStorage) SetURLActive(shortCode string, active bool) error {
+	result, err := s.db.Exec(`UPDATE url_mappings SET is_active = ?, updated_at = ? WHERE short_code = ?`, active, time.Now(), shortCode)
+	if err != nil {
+		return fmt.Errorf("failed to update URL status: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check affected rows: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrURLNotFound
+	}
+	return nil
+}
+
+// TransferURL makes userID the owner of a link
+func (s *SQLiteStorage) TransferURL(shortCode string, userID int64) error {
+	result, err := s.db.Exec(`UPDATE url_mappings SET user_id = ?, updated_at = ? WHERE short_code = ?`, userID, time.Now(), shortCode)
+	if err != nil {
+		return fmt.Errorf("failed to transfer URL: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check affected rows: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrURLNotFound
+	}
+	return nil
+}
+
+// ChangeURLDestination points a user's short code at a new destination and
+// applies update. The previous destination is appended to
+// url_destination_history in the same transaction and the destination version
+// is bumped. Returns the new version.
+func (s *SQLiteStorage) ChangeURLDestination(shortCode string, userID int64, newURL string, update *URLUpdate) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var currentURL string
+	var version int
+	var activeFrom time.Time
+	err = tx.QueryRow(`
+		SELECT original_url, destination_version, destination_updated_at
+		FROM url_mappings
+		WHERE short_code = ? AND user_id = ? AND is_active = 1
+	`, shortCode, userID).Scan(&currentURL, &version, &activeFrom)
+	if err == sql.ErrNoRows {
+		return 0, ErrURLNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to load URL mapping: %w", err)
+	}
+
+	if !update.IsEmpty() {
+		if err := s.updateURL(tx, shortCode, userID, update); err != nil {
+			return 0, err
+		}
+	}
+
+	// Nothing to record if the destination is unchanged
+	if currentURL != newURL {
+		now := time.Now()
+		_, err = tx.Exec(`
+			INSERT INTO url_destination_history (short_code, version, original_url, active_from, replaced_at, replaced_by)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, shortCode, version, currentURL, activeFrom, now, userID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to record destination history: %w", err)
+		}
+
+		version++
+		_, err = tx.Exec(`
+			UPDATE url_mappings
+			SET original_url = ?, destination_version = ?, destination_updated_at = ?
+			WHERE short_code = ?
+		`, newURL, version, now, shortCode)
+		if err != nil {
+			return 0, fmt.Errorf("failed to update destination: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit destination change: %w", err)
+	}
+
+	return version, nil
+}
+
+// GetDestinationHistory retrieves prior destinations of a short code, newest first
+func (s *SQLiteStorage) GetDestinationHistory(shortCode string) ([]models.DestinationHistoryEntry, error) {
+	rows, err := s.db.Query(`
+		SELECT id, short_code, version, original_url, active_from, replaced_at, replaced_by
+		FROM url_destination_history
+		WHERE short_code = ?
+		ORDER BY version DESC
+	`, shortCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query destination history: %w", err)
+	}
+	defer rows.Close()
+
+	history := []models.DestinationHistoryEntry{}
+	for rows.Next() {
+		var entry models.DestinationHistoryEntry
+		var replacedBy sql.NullInt64
+		if err := rows.Scan(&entry.ID, &entry.ShortCode, &entry.Version, &entry.OriginalURL,
+			&entry.ActiveFrom, &entry.ReplacedAt, &replacedBy); err != nil {
+			return nil, fmt.Errorf("failed to scan destination history: %w", err)
+		}
+		if replacedBy.Valid {
+			entry.ReplacedBy = &replacedBy.Int64
+		}
+		history = append(history, entry)
+	}
+
+	return history, rows.Err()
+}
+
+// SaveClickEvents stores click events in a single transaction. Events whose
+// ID is already stored are skipped; the IDs of the newly inserted rows are returned.
+func (s *SQLiteStorage) SaveClickEvents(events []*models.ClickEvent) ([]int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT OR IGNORE INTO click_events (id, short_code, clicked_at, ip_address, user_agent, referrer, country_code, destination_version, traffic_class, source)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''))
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare click event insert: %w", err)
+	}
+	defer stmt.Close()
+
+	inserted := make([]int64, 0, len(events))
+	for _, event := range events {
+		var destinationVersion sql.NullInt64
+		if event.DestinationVersion > 0 {
+			destinationVersion = sql.NullInt64{Int64: int64(event.DestinationVersion), Valid: true}
+		}
+		result, err := stmt.Exec(event.ID, event.ShortCode, event.ClickedAt.UTC(),
+			event.IPAddress, event.UserAgent, event.Referrer, event.CountryCode, destinationVersion, trafficClass(event), event.Source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to save click events: %w", err)
+		}
+		if n, err := result.RowsAffected(); err == nil && n > 0 {
+			inserted = append(inserted, event.ID)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to save click events: %w", err)
+	}
+	return inserted, nil
+}
+
+// IncrementClickCounts applies coalesced click count increments in a single transaction
+func (s *SQLiteStorage) IncrementClickCounts(counts map[string]int64) error {
+	if len(counts) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for shortCode, n := range counts {
+		if _, err := tx.Exec(`UPDATE url_mappings SET click_count = click_count + ? WHERE short_code = ?`, n, shortCode); err != nil {
+			return fmt.Errorf("failed to increment click counts: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to increment click counts: %w", err)
+	}
+	return nil
+}
+
+// GetAnalytics retrieves analytics data for a short code.
+// Non-human clicks are excluded unless includeBots is set.
+func (s *SQLiteStorage) GetAnalytics(shortCode string, days int, includeBots bool) (*models.AnalyticsResponse, error) {
+	mapping, err := s.GetURLMappingByShortCode(shortCode)
+	if err != nil {
+		return nil, err
+	}
+
+	analytics := &models.AnalyticsResponse{
+		ShortCode:   mapping.ShortCode,
+		OriginalURL: mapping.OriginalURL,
+		TotalClicks: mapping.ClickCount,
+		CreatedAt:   mapping.CreatedAt,
+	}
+
+	trafficFilter := HumanTrafficFilter(includeBots)
+
+	// click_count includes every click, so count human clicks from the events
+	if !includeBots {
+		err = s.db.QueryRow(
+			`SELECT COUNT(*) FROM click_events WHERE short_code = ? AND `+trafficFilter,
+			shortCode,
+		).Scan(&analytics.TotalClicks)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count clicks: %w", err)
+		}
+	}
+
+	err = s.db.QueryRow(
+		`SELECT COUNT(*) FROM click_events WHERE short_code = ? AND source = ? AND `+trafficFilter,
+		shortCode, models.ClickSourceQR,
+	).Scan(&analytics.TotalScans)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count scans: %w", err)
+	}
+
+	// Get last click time; MAX() loses the column type, so order instead
+	var lastClickAt time.Time
+	err = s.db.QueryRow(
+		`SELECT clicked_at FROM click_events WHERE short_code = ? AND `+trafficFilter+` ORDER BY clicked_at DESC LIMIT 1`,
+		shortCode,
+	).Scan(&lastClickAt)
+	if err == nil {
+		analytics.LastClickAt = &lastClickAt
+	}
+
+	// Get daily clicks for the specified number of days
+	since := time.Now().UTC().AddDate(0, 0, -days)
+	rows, err := s.db.Query(`
+		SELECT date(clicked_at) AS day, COUNT(*) AS clicks
+		FROM click_events
+		WHERE short_code = ? AND clicked_at >= ? AND `+trafficFilter+`
+		GROUP BY day
+		ORDER BY day DESC
+	`, shortCode, since)
+	if err == nil {
+		for rows.Next() {
+			var dailyClick models.DailyClick
+			if err := rows.Scan(&dailyClick.Date, &dailyClick.Clicks); err == nil {
+				analytics.DailyClicks = append(analytics.DailyClicks, dailyClick)
+			}
+		}
+		rows.Close()
+	}
+
+	// Get country stats
+	rows, err = s.db.Query(`
+		SELECT country_code, COUNT(*) AS clicks
+		FROM click_events
+		WHERE short_code = ? AND country_code IS NOT NULL AND country_code != '' AND `+trafficFilter+`
+		GROUP BY country_code
+		ORDER BY clicks DESC, country_code
+		LIMIT 10
+	`, shortCode)
+	if err == nil {
+		for rows.Next() {
+			var countryStat models.CountryStat
+			if err := rows.Scan(&countryStat.CountryCode, &countryStat.Clicks); err == nil {
+				analytics.CountryStats = append(analytics.CountryStats, countryStat)
+			}
+		}
+		rows.Close()
+	}
+
+	return analytics, nil
+}
+
+// GetDestinationStats breaks down clicks on a short code by the destination
+// version that was live when each click happened. Clicks recorded without a
+// destination version are attributed to version 1.
+func (s *SQLiteStorage) GetDestinationStats(shortCode string) ([]models.DestinationStat, error) {
+	query := `
+		WITH versions AS (
+			SELECT version, original_url, 0 AS is_current
+			FROM url_destination_history
+			WHERE short_code = ?1
+			UNION ALL
+			SELECT destination_version, original_url, 1
+			FROM url_mappings
+			WHERE short_code = ?1
+		)
+		SELECT v.version, v.original_url, v.is_current, COUNT(ce.id)
+		FROM versions v
+		LEFT JOIN click_events ce
+			ON ce.short_code = ?1 AND COALESCE(ce.destination_version, 1) = v.version
+		GROUP BY v.version, v.original_url, v.is_current
+		ORDER BY v.version
+	`
+	rows, err := s.db.Query(query, shortCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query destination stats: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []models.DestinationStat
+	for rows.Next() {
+		var stat models.DestinationStat
+		if err := rows.Scan(&stat.Version, &stat.OriginalURL, &stat.IsCurrent, &stat.Clicks); err != nil {
+			return nil, fmt.Errorf("failed to scan destination stats: %w", err)
+		}
+		stats = append(stats, stat)
+	}
+
+	return stats, rows.Err()
+}
+
+// GetClickTrends groups a link's recent clicks by hour, day, week or month.
+// Weeks are numbered from Monday with strftime's %W rather than ISO weeks.
+func (s *SQLiteStorage) GetClickTrends(shortCode, period string, includeBots bool) ([]models.ClickTrend, error) {
+	var dateFormat string
+	var since time.Time
+
+	now := time.Now().UTC()
+	switch period {
+	case "hour":
+		dateFormat = "%Y-%m-%d %H:00:00"
+		since = now.Add(-24 * time.Hour)
+	case "day":
+		dateFormat = "%Y-%m-%d"
+		since = now.AddDate(0, 0, -30)
+	case "week":
+		dateFormat = "%Y-%W"
+		since = now.AddDate(0, 0, -12*7)
+	case "month":
+		dateFormat = "%Y-%m"
+		since = now.AddDate(0, -12, 0)
+	default:
+		return nil, fmt.Errorf("unsupported trend period %q", period)
+	}
+
+	query := `
+		SELECT strftime(?, clicked_at) AS period, COUNT(*) AS clicks
+		FROM click_events
+		WHERE short_code = ? AND clicked_at >= ? AND ` + HumanTrafficFilter(includeBots) + `
+		GROUP BY 1
+		ORDER BY 1
+	`
+	rows, err := s.db.Query(query, dateFormat, shortCode, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query click trends: %w", err)
+	}
+	defer rows.Close()
+
+	trends := []models.ClickTrend{}
+	for rows.Next() {
+		var trend models.ClickTrend
+		if err := rows.Scan(&trend.Period, &trend.Clicks); err != nil {
+			return nil, fmt.Errorf("failed to scan click trend: %w", err)
+		}
+		trends = append(trends, trend)
+	}
+
+	return trends, rows.Err()
+}
+
+// GetUserDashboardStats retrieves link and click totals across a user's active links.
+// Today and this month are UTC calendar periods.
+func (s *SQLiteStorage) GetUserDashboardStats(userID int64, includeBots bool) (*models.UserDashboardStats, error) {
+	stats := &models.UserDashboardStats{}
+
+	err := s.db.QueryRow("SELECT COUNT(*) FROM url_mappings WHERE user_id = ? AND is_active = 1", userID).Scan(&stats.TotalURLs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get total URLs: %w", err)
+	}
+	stats.ActiveURLs = stats.TotalURLs
+
+	trafficFilter := HumanTrafficFilter(includeBots)
+	userClicks := `
+		SELECT COUNT(*)
+		FROM click_events ce
+		JOIN url_mappings um ON ce.short_code = um.short_code
+		WHERE um.user_id = ? AND um.is_active = 1 AND ` + trafficFilter
+
+	// click_count includes every click, so human-only totals are counted from the click events
+	if includeBots {
+		err = s.db.QueryRow(`
+			SELECT COALESCE(SUM(click_count), 0)
+			FROM url_mappings
+			WHERE user_id = ? AND is_active = 1
+		`, userID).Scan(&stats.TotalClicks)
+	} else {
+		err = s.db.QueryRow(userClicks, userID).Scan(&stats.TotalClicks)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get total clicks: %w", err)
+	}
+
+	err = s.db.QueryRow(userClicks+` AND date(ce.clicked_at) = date('now')`, userID).Scan(&stats.TodayClicks)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get today's clicks: %w", err)
+	}
+
+	err = s.db.QueryRow(userClicks+` AND strftime('%Y-%m', ce.clicked_at) = strftime('%Y-%m', 'now')`, userID).Scan(&stats.MonthClicks)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get this month's clicks: %w", err)
+	}
+
+	return stats, nil
+}
+
+// userColumns are the users columns scanned by scanUser
+const userColumns = `
+	id, name, email, phone, password_hash, email_verified, phone_verified,
+	provider, provider_id, avatar_url, account_type, is_active, is_admin,
+	created_at, updated_at, last_login_at`
+
+func scanUser(row rowScanner) (*models.User, error) {
+	user := &models.User{}
+	err := row.Scan(
+		&user.ID, &user.Name, &user.Email, &user.Phone, &user.PasswordHash,
+		&user.EmailVerified, &user.PhoneVerified, &user.Provider, &user.ProviderID,
+		&user.AvatarURL, &user.AccountType, &user.IsActive, &user.IsAdmin,
+		&user.CreatedAt, &user.UpdatedAt, &user.LastLoginAt)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// CreateUser creates a new user
+func (s *SQLiteStorage) CreateUser(user *models.User) error {
+	query := `
+		INSERT INTO users (id, name, email, phone, password_hash, email_verified, phone_verified,
+		                   provider, provider_id, avatar_url, account_type, is_active, is_admin, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err := s.db.Exec(query, user.ID, user.Name, user.Email, user.Phone, user.PasswordHash,
+		user.EmailVerified, user.PhoneVerified, user.Provider, user.ProviderID, user.AvatarURL,
+		user.AccountType, user.IsActive, user.IsAdmin, user.CreatedAt, user.UpdatedAt)
+	return err
+}
+
+// GetUserByID retrieves a user by ID
+func (s *SQLiteStorage) GetUserByID(id int64) (*models.User, error) {
+	return scanUser(s.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE id = ?`, id))
+}
+
+// GetUserByEmail retrieves a user by email
+func (s *SQLiteStorage) GetUserByEmail(email string) (*models.User, error) {
+	return scanUser(s.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE email = ?`, email))
+}
+
+// GetUserByPhone retrieves a user by phone
+func (s *SQLiteStorage) GetUserByPhone(phone string) (*models.User, error) {
+	return scanUser(s.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE phone = ?`, phone))
+}
+
+// UpdateUser updates user information
+func (s *SQLiteStorage) UpdateUser(user *models.User) error {
+	query := `
+		UPDATE users SET name = ?, email = ?, phone = ?, provider = ?,
+		                 provider_id = ?, avatar_url = ?, account_type = ?,
+		                 updated_at = ?
+		WHERE id = ?
+	`
+	_, err := s.db.Exec(query, user.Name, user.Email, user.Phone, user.Provider,
+		user.ProviderID, user.AvatarURL, user.AccountType, time.Now(), user.ID)
+	return err
+}
+
+// UpdateUserLastLogin updates user's last login time
+func (s *SQLiteStorage) UpdateUserLastLogin(userID int64, loginTime time.Time) error {
+	_, err := s.db.Exec(`UPDATE users SET last_login_at = ?, updated_at = ? WHERE id = ?`, loginTime, time.Now(), userID)
+	return err
+}
+
+// UpdateUserPassword updates user password
+func (s *SQLiteStorage) UpdateUserPassword(userID int64, passwordHash string) error {
+	_, err := s.db.Exec(`UPDATE users SET password_hash = ?, updated_at = ? WHERE id = ?`, passwordHash, time.Now(), userID)
+	return err
+}
+
+// UpdateUserStatus updates user status (active/inactive)
+func (s *SQLiteStorage) UpdateUserStatus(userID int64, isActive bool) error {
+	_, err := s.db.Exec(`UPDATE users SET is_active = ?, updated_at = ? WHERE id = ?`, isActive, time.Now(), userID)
+	return err
+}
+
+// UpdateUserEmailVerified updates user email verification status
+func (s *SQLiteStorage) UpdateUserEmailVerified(userID int64, verified bool) error {
+	_, err := s.db.Exec(`UPDATE users SET email_verified = ?, updated_at = ? WHERE id = ?`, verified, time.Now(), userID)
+	return err
+}
+
+// UpdateUserPhoneVerified updates user phone verification status
+func (s *SQLiteStorage) UpdateUserPhoneVerified(userID int64, verified bool) error {
+	_, err := s.db.Exec(`UPDATE users SET phone_verified = ?, updated_at = ? WHERE id = ?`, verified, time.Now(), userID)
+	return err
+}
+
+// CreateUserPreferences creates user preferences
+func (s *SQLiteStorage) CreateUserPreferences(prefs *models.UserPreferences) error {
+	query := `
+		INSERT INTO user_preferences (user_id, default_expiration, analytics_public,
+		                              email_notifications, marketing_emails, timezone, theme)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err := s.db.Exec(query, prefs.UserID, prefs.DefaultExpiration, prefs.AnalyticsPublic,
+		prefs.EmailNotifications, prefs.MarketingEmails, prefs.Timezone, prefs.Theme)
+	return err
+}
+
+// GetUserPreferences retrieves user preferences
+func (s *SQLiteStorage) GetUserPreferences(userID int64) (*models.UserPreferences, error) {
+	prefs := &models.UserPreferences{}
+	query := `
+		SELECT user_id, default_expiration, analytics_public, email_notifications,
+		       marketing_emails, timezone, theme
+		FROM user_preferences WHERE user_id = ?
+	`
+	err := s.db.QueryRow(query, userID).Scan(
+		&prefs.UserID, &prefs.DefaultExpiration, &prefs.AnalyticsPublic,
+		&prefs.EmailNotifications, &prefs.MarketingEmails, &prefs.Timezone, &prefs.Theme)
+	if err != nil {
+		return nil, err
+	}
+	return prefs, nil
+}
+
+// UpdateUserPreferences updates user preferences
+func (s *SQLiteStorage) UpdateUserPreferences(prefs *models.UserPreferences) error {
+	query := `
+		UPDATE user_preferences
+		SET default_expiration = ?, analytics_public = ?, email_notifications = ?,
+		    marketing_emails = ?, timezone = ?, theme = ?
+		WHERE user_id = ?
+	`
+	_, err := s.db.Exec(query, prefs.DefaultExpiration, prefs.AnalyticsPublic,
+		prefs.EmailNotifications, prefs.MarketingEmails, prefs.Timezone, prefs.Theme, prefs.UserID)
+	return err
+}
+
+// CreateSession creates a new user session
+func (s *SQLiteStorage) CreateSession(session *models.UserSession) error {
+	query := `
+		INSERT INTO user_sessions (id, user_id, device_info, ip_address, user_agent,
+		                           refresh_token_hash, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err := s.db.Exec(query, session.ID.String(), session.UserID, session.DeviceInfo, session.IPAddress,
+		session.UserAgent, session.RefreshTokenHash, session.ExpiresAt, session.CreatedAt)
+	return err
+}
+
+// GetSessionByID retrieves a session by ID
+func (s *SQLiteStorage) GetSessionByID(id string) (*models.UserSession, error) {
+	session := &models.UserSession{}
+	query := `
+		SELECT id, user_id, device_info, ip_address, user_agent,
+		       refresh_token_hash, expires_at, created_at
+		FROM user_sessions WHERE id = ?
+	`
+	err := s.db.QueryRow(query, id).Scan(
+		&session.ID, &session.UserID, &session.DeviceInfo, &session.IPAddress,
+		&session.UserAgent, &session.RefreshTokenHash, &session.ExpiresAt, &session.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// CreateEmailVerification creates an email verification record
+func (s *SQLiteStorage) CreateEmailVerification(verification *models.EmailVerification) error {
+	query := `
+		INSERT INTO email_verifications (id, user_id, token, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`
+	_, err := s.db.Exec(query, verification.ID.String(), verification.UserID, verification.Token,
+		verification.ExpiresAt, verification.CreatedAt)
+	return err
+}
+
+// GetEmailVerificationByToken retrieves email verification by token
+func (s *SQLiteStorage) GetEmailVerificationByToken(token string) (*models.EmailVerification, error) {
+	verification := &models.EmailVerification{}
+	query := `
+		SELECT id, user_id, token, expires_at, verified_at, created_at
+		FROM email_verifications WHERE token = ?
+	`
+	err := s.db.QueryRow(query, token).Scan(
+		&verification.ID, &verification.UserID, &verification.Token,
+		&verification.ExpiresAt, &verification.VerifiedAt, &verification.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return verification, nil
+}
+
+// GetLatestEmailVerification retrieves the most recent email verification of a user
+func (s *SQLiteStorage) GetLatestEmailVerification(userID int64) (*models.EmailVerification, error) {
+	verification := &models.EmailVerification{}
+	query := `
+		SELECT id, user_id, token, expires_at, verified_at, created_at
+		FROM email_verifications WHERE user_id = ? ORDER BY created_at DESC LIMIT 1
+	`
+	err := s.db.QueryRow(query, userID).Scan(
+		&verification.ID, &verification.UserID, &verification.Token,
+		&verification.ExpiresAt, &verification.VerifiedAt, &verification.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return verification, nil
+}
+
+// UpdateEmailVerification updates email verification record
+func (s *SQLiteStorage) UpdateEmailVerification(id string, verifiedAt time.Time) error {
+	_, err := s.db.Exec(`UPDATE email_verifications SET verified_at = ? WHERE id = ?`, verifiedAt, id)
+	return err
+}
+
+// InvalidateEmailVerifications closes all pending email verifications of a user
+func (s *SQLiteStorage) InvalidateEmailVerifications(userID int64) error {
+	_, err := s.db.Exec(`UPDATE email_verifications SET verified_at = ? WHERE user_id = ? AND verified_at IS NULL`, time.Now(), userID)
+	return err
+}
+
+// CreatePhoneVerification creates a phone verification record
+func (s *SQLiteStorage) CreatePhoneVerification(verification *models.PhoneVerification) error {
+	query := `
+		INSERT INTO phone_verifications (id, user_id, phone, otp_code, attempts, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err := s.db.Exec(query, verification.ID.String(), verification.UserID, verification.Phone,
+		verification.OTPCode, verification.Attempts, verification.ExpiresAt, verification.CreatedAt)
+	return err
+}
+
+// GetPhoneVerificationByUserID retrieves the latest phone verification of a user
+func (s *SQLiteStorage) GetPhoneVerificationByUserID(userID int64) (*models.PhoneVerification, error) {
+	verification := &models.PhoneVerification{}
+	query := `
+		SELECT id, user_id, phone, otp_code, attempts, expires_at, verified_at, created_at
+		FROM phone_verifications WHERE user_id = ? ORDER BY created_at DESC LIMIT 1
+	`
+	err := s.db.QueryRow(query, userID).Scan(
+		&verification.ID, &verification.UserID, &verification.Phone,
+		&verification.OTPCode, &verification.Attempts, &verification.ExpiresAt, &verification.VerifiedAt, &verification.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return verification, nil
+}
+
+// UpdatePhoneVerificationAttempts updates phone verification attempts
+func (s *SQLiteStorage) UpdatePhoneVerificationAttempts(userID int64, attempts int) error {
+	_, err := s.db.Exec(`UPDATE phone_verifications SET attempts = ? WHERE user_id = ?`, attempts, userID)
+	return err
+}
+
+// UpdatePhoneVerification updates phone verification record
+func (s *SQLiteStorage) UpdatePhoneVerification(id string, verifiedAt time.Time) error {
+	_, err := s.db.Exec(`UPDATE phone_verifications SET verified_at = ? WHERE id = ?`, verifiedAt, id)
+	return err
+}
+
+// DeletePhoneVerificationByUserID deletes phone verification by user ID
+func (s *SQLiteStorage) DeletePhoneVerificationByUserID(userID int64) error {
+	_, err := s.db.Exec(`DELETE FROM phone_verifications WHERE user_id = ?`, userID)
+	return err
+}
+
+// CreatePasswordReset creates a password reset record
+func (s *SQLiteStorage) CreatePasswordReset(reset *models.PasswordReset) error {
+	query := `
+		INSERT INTO password_resets (id, user_id, token, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`
+	_, err := s.db.Exec(query, reset.ID.String(), reset.UserID, reset.Token,
+		reset.ExpiresAt, reset.CreatedAt)
+	return err
+}
+
+// GetPasswordResetByToken retrieves password reset by token
+func (s *SQLiteStorage) GetPasswordResetByToken(token string) (*models.PasswordReset, error) {
+	reset := &models.PasswordReset{}
+	query := `
+		SELECT id, user_id, token, expires_at, used_at, created_at
+		FROM password_resets WHERE token = ?
+	`
+	err := s.db.QueryRow(query, token).Scan(
+		&reset.ID, &reset.UserID, &reset.Token,
+		&reset.ExpiresAt, &reset.UsedAt, &reset.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return reset, nil
+}
+
+// UpdatePasswordReset updates password reset record
+func (s *SQLiteStorage) UpdatePasswordReset(id string, usedAt time.Time) error {
+	_, err := s.db.Exec(`UPDATE password_resets SET used_at = ? WHERE id = ?`, usedAt, id)
+	return err
+}
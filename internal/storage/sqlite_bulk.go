@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/URLshorter/url-shortener/internal/models"
+)
+
+// SetURLTags replaces a link's tags
+func (s *SQLiteStorage) SetURLTags(shortCode string, tags []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM url_tags WHERE short_code = ?`, shortCode); err != nil {
+		return fmt.Errorf("failed to clear tags: %w", err)
+	}
+	for _, tag := range tags {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO url_tags (short_code, tag) VALUES (?, ?)`, shortCode, tag); err != nil {
+			return fmt.Errorf("failed to save tags: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// EachUserURL calls fn for each of a user's active links with its tags, oldest first
+func (s *SQLiteStorage) EachUserURL(userID int64, fn func(*models.UserURLResponse) error) error {
+	query := `
+		SELECT ` + userURLColumns + `, ` + sqliteURLTagsColumn + `
+		FROM url_mappings
+		WHERE user_id = ? AND is_active = 1
+		ORDER BY created_at, id
+	`
+
+	rows, err := s.db.Query(query, userID)
+	if err != nil {
+		return fmt.Errorf("failed to query user URLs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		url, err := scanTaggedUserURL(rows)
+		if err != nil {
+			return fmt.Errorf("failed to scan URL row: %w", err)
+		}
+		if err := fn(url); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// CreateImportJob saves a job together with its rows
+func (s *SQLiteStorage) CreateImportJob(job *models.BulkImportJob, rows []*models.BulkImportRow) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO bulk_import_jobs (id, user_id, format, checksum, status, total_rows, processed_rows,
+		                              created_rows, failed_rows, error, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''), ?)`,
+		job.ID, job.UserID, job.Format, job.Checksum, job.Status, job.TotalRows, job.ProcessedRows,
+		job.CreatedRows, job.FailedRows, job.Error, job.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save import job: %w", err)
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO bulk_import_rows (job_id, line, url, custom_code, expires_at, title, tags, status, short_code, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare import rows: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, row := range rows {
+		_, err := stmt.Exec(job.ID, row.Line, row.URL, nullString(row.CustomCode), row.ExpiresAt,
+			nullString(row.Title), row.Tags, row.Status, nullString(row.ShortCode), nullString(row.Error))
+		if err != nil {
+			return fmt.Errorf("failed to save import row %d: %w", row.Line, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetImportJob retrieves an import job by ID
+func (s *SQLiteStorage) GetImportJob(id int64) (*models.BulkImportJob, error) {
+	job, err := scanImportJob(s.db.QueryRow(`SELECT `+importJobColumns+` FROM bulk_import_jobs WHERE id = ?`, id))
+	if err != nil && err != ErrImportJobNotFound {
+		return nil, fmt.Errorf("failed to get import job: %w", err)
+	}
+	return job, err
+}
+
+// GetImportJobByChecksum retrieves a user's import job for a file
+func (s *SQLiteStorage) GetImportJobByChecksum(userID int64, checksum string) (*models.BulkImportJob, error) {
+	job, err := scanImportJob(s.db.QueryRow(`SELECT `+importJobColumns+` FROM bulk_import_jobs WHERE user_id = ? AND checksum = ?`,
+		userID, checksum))
+	if err != nil && err != ErrImportJobNotFound {
+		return nil, fmt.Errorf("failed to get import job: %w", err)
+	}
+	return job, err
+}
+
+// ListUnfinishedImportJobs retrieves pending and running jobs, oldest first
+func (s *SQLiteStorage) ListUnfinishedImportJobs() ([]*models.BulkImportJob, error) {
+	rows, err := s.db.Query(`SELECT ` + importJobColumns + ` FROM bulk_import_jobs WHERE status IN ('pending', 'running') ORDER BY created_at`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query import jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []*models.BulkImportJob
+	for rows.Next() {
+		job, err := scanImportJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan import job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// UpdateImportJob saves a job's status and progress
+func (s *SQLiteStorage) UpdateImportJob(job *models.BulkImportJob) error {
+	_, err := s.db.Exec(`
+		UPDATE bulk_import_jobs
+		SET status = ?, processed_rows = ?, created_rows = ?, failed_rows = ?,
+		    error = NULLIF(?, ''), started_at = ?, finished_at = ?
+		WHERE id = ?`,
+		job.Status, job.ProcessedRows, job.CreatedRows, job.FailedRows, job.Error, job.StartedAt, job.FinishedAt, job.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update import job: %w", err)
+	}
+	return nil
+}
+
+// ListImportRows returns up to limit rows of a job after the given line
+func (s *SQLiteStorage) ListImportRows(jobID int64, status string, afterLine, limit int) ([]*models.BulkImportRow, error) {
+	rows, err := s.db.Query(`
+		SELECT job_id, line, url, COALESCE(custom_code, ''), expires_at, COALESCE(title, ''), tags,
+		       status, COALESCE(short_code, ''), COALESCE(error, '')
+		FROM bulk_import_rows
+		WHERE job_id = ? AND (? = '' OR status = ?) AND line > ?
+		ORDER BY line
+		LIMIT ?`, jobID, status, status, afterLine, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query import rows: %w", err)
+	}
+	defer rows.Close()
+
+	var result []*models.BulkImportRow
+	for rows.Next() {
+		row := &models.BulkImportRow{}
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&row.JobID, &row.Line, &row.URL, &row.CustomCode, &expiresAt, &row.Title, &row.Tags,
+			&row.Status, &row.ShortCode, &row.Error); err != nil {
+			return nil, fmt.Errorf("failed to scan import row: %w", err)
+		}
+		if expiresAt.Valid {
+			row.ExpiresAt = &expiresAt.Time
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// UpdateImportRows records the outcome of processed rows in one transaction
+func (s *SQLiteStorage) UpdateImportRows(rows []*models.BulkImportRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`UPDATE bulk_import_rows SET status = ?, short_code = ?, error = ? WHERE job_id = ? AND line = ?`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare import row update: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, row := range rows {
+		if _, err := stmt.Exec(row.Status, nullString(row.ShortCode), nullString(row.Error), row.JobID, row.Line); err != nil {
+			return fmt.Errorf("failed to update import row %d: %w", row.Line, err)
+		}
+	}
+	return tx.Commit()
+}
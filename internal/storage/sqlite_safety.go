@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/URLshorter/url-shortener/internal/models"
+)
+
+// AddBlocklistEntry stores a blocked domain or URL pattern
+func (s *SQLiteStorage) AddBlocklistEntry(entry *models.BlocklistEntry) error {
+	createdAt := time.Now()
+	result, err := s.db.Exec(`
+		INSERT OR IGNORE INTO destination_blocklist (kind, pattern, reason, created_by, created_at)
+		VALUES (?, ?, NULLIF(?, ''), ?, ?)
+	`, entry.Kind, entry.Pattern, entry.Reason, entry.CreatedBy, createdAt)
+	if err != nil {
+		return fmt.Errorf("failed to save blocklist entry: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrBlocklistEntryExists
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to read blocklist entry ID: %w", err)
+	}
+	entry.ID = id
+	entry.CreatedAt = createdAt
+	return nil
+}
+
+// DeleteBlocklistEntry removes a blocklist entry
+func (s *SQLiteStorage) DeleteBlocklistEntry(id int64) error {
+	result, err := s.db.Exec(`DELETE FROM destination_blocklist WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete blocklist entry: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrBlocklistEntryNotFound
+	}
+	return nil
+}
+
+// ListBlocklistEntries returns the whole blocklist, oldest first
+func (s *SQLiteStorage) ListBlocklistEntries() ([]*models.BlocklistEntry, error) {
+	rows, err := s.db.Query(`
+		SELECT id, kind, pattern, COALESCE(reason, ''), created_by, created_at
+		FROM destination_blocklist
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query blocklist: %w", err)
+	}
+	defer rows.Close()
+
+	return scanBlocklistEntries(rows)
+}
+
+// QuarantineURL marks a link as quarantined. A link that is already
+// quarantined keeps its original time and reason.
+func (s *SQLiteStorage) QuarantineURL(shortCode, reason string) error {
+	result, err := s.db.Exec(`
+		UPDATE url_mappings
+		SET quarantine_reason = CASE WHEN quarantined_at IS NULL THEN ? ELSE quarantine_reason END,
+		    quarantined_at = COALESCE(quarantined_at, ?)
+		WHERE short_code = ?
+	`, reason, time.Now(), shortCode)
+	if err != nil {
+		return fmt.Errorf("failed to quarantine URL: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrURLNotFound
+	}
+	return nil
+}
+
+// ReleaseURL lifts a link's quarantine
+func (s *SQLiteStorage) ReleaseURL(shortCode string) error {
+	result, err := s.db.Exec(`
+		UPDATE url_mappings
+		SET quarantined_at = NULL, quarantine_reason = NULL, safety_checked_at = ?
+		WHERE short_code = ?
+	`, time.Now(), shortCode)
+	if err != nil {
+		return fmt.Errorf("failed to release URL: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrURLNotFound
+	}
+	return nil
+}
+
+// ListURLsForSafetyCheck returns active, unquarantined links due for screening
+func (s *SQLiteStorage) ListURLsForSafetyCheck(checkedBefore time.Time, limit int) ([]*models.URLMapping, error) {
+	rows, err := s.db.Query(`
+		SELECT id, short_code, original_url, user_id, destination_version,
+		       redirect_rules, COALESCE(fallback_url, '')
+		FROM url_mappings
+		WHERE is_active = 1 AND quarantined_at IS NULL
+		  AND (safety_checked_at IS NULL OR safety_checked_at < ?)
+		ORDER BY safety_checked_at IS NOT NULL, safety_checked_at, id
+		LIMIT ?
+	`, checkedBefore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query URLs for safety check: %w", err)
+	}
+	defer rows.Close()
+
+	return scanSafetyCheckMappings(rows)
+}
+
+// MarkURLSafetyChecked records when a link was last screened
+func (s *SQLiteStorage) MarkURLSafetyChecked(shortCode string, checkedAt time.Time) error {
+	_, err := s.db.Exec(`UPDATE url_mappings SET safety_checked_at = ? WHERE short_code = ?`, checkedAt, shortCode)
+	if err != nil {
+		return fmt.Errorf("failed to mark URL checked: %w", err)
+	}
+	return nil
+}
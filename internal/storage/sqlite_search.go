@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/URLshorter/url-shortener/internal/models"
+)
+
+// SearchUserURLs returns a page of a user's active links matching search.
+// Without full-text indexes, each word of the text must appear somewhere in
+// the title, description or destination. Timestamps are compared through
+// julianday since they are stored as text with the writer's UTC offset.
+func (s *SQLiteStorage) SearchUserURLs(userID int64, search *URLSearch) ([]*models.UserURLResponse, error) {
+	args := []interface{}{userID}
+	conditions := []string{"user_id = ?", "is_active = 1"}
+
+	for _, term := range searchTerms(search.Text) {
+		conditions = append(conditions,
+			`(COALESCE(title, '') || ' ' || COALESCE(description, '') || ' ' || original_url) LIKE ? ESCAPE '\'`)
+		args = append(args, likePattern(term))
+	}
+	if len(search.Tags) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(search.Tags)), ", ")
+		conditions = append(conditions, fmt.Sprintf(
+			"short_code IN (SELECT short_code FROM url_tags WHERE tag IN (%s) GROUP BY short_code HAVING COUNT(*) = ?)", placeholders))
+		for _, tag := range search.Tags {
+			args = append(args, tag)
+		}
+		args = append(args, len(search.Tags))
+	}
+	if search.Domain != "" {
+		conditions = append(conditions, `(url_host(original_url) = ? OR url_host(original_url) LIKE ? ESCAPE '\')`)
+		args = append(args, search.Domain, subdomainPattern(search.Domain))
+	}
+	if search.CreatedFrom != nil {
+		conditions = append(conditions, "julianday(created_at) >= julianday(?)")
+		args = append(args, *search.CreatedFrom)
+	}
+	if search.CreatedTo != nil {
+		conditions = append(conditions, "julianday(created_at) < julianday(?)")
+		args = append(args, *search.CreatedTo)
+	}
+	switch search.State {
+	case models.URLStateActive:
+		conditions = append(conditions, "(expires_at IS NULL OR julianday(expires_at) > julianday('now'))")
+	case models.URLStateExpired:
+		conditions = append(conditions, "julianday(expires_at) <= julianday('now')")
+	}
+	if search.MinClicks != nil {
+		conditions = append(conditions, "click_count >= ?")
+		args = append(args, *search.MinClicks)
+	}
+	if search.MaxClicks != nil {
+		conditions = append(conditions, "click_count <= ?")
+		args = append(args, *search.MaxClicks)
+	}
+
+	sortColumn, cursorPlaceholder := "julianday(created_at)", "julianday(?)"
+	switch search.Sort {
+	case models.URLSortClickCount:
+		sortColumn, cursorPlaceholder = "click_count", "?"
+	case models.URLSortTitle:
+		sortColumn, cursorPlaceholder = "COALESCE(title, '')", "?"
+	}
+	direction, after := search.searchDirection()
+	if search.After != nil {
+		conditions = append(conditions, fmt.Sprintf("(%s, id) %s (%s, ?)", sortColumn, after, cursorPlaceholder))
+		args = append(args, search.cursorValue(), search.After.ID)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s, %s
+		FROM url_mappings
+		WHERE %s
+		ORDER BY %s %s, id %s
+		LIMIT ?`,
+		userURLColumns, sqliteURLTagsColumn, strings.Join(conditions, " AND "),
+		sortColumn, direction, direction)
+	args = append(args, search.Limit)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search user URLs: %w", err)
+	}
+	defer rows.Close()
+
+	var urls []*models.UserURLResponse
+	for rows.Next() {
+		url, err := scanTaggedUserURL(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan URL row: %w", err)
+		}
+		urls = append(urls, url)
+	}
+	return urls, rows.Err()
+}
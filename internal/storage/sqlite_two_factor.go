@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/URLshorter/url-shortener/internal/models"
+)
+
+// GetTwoFactor returns a user's two-factor settings
+func (s *SQLiteStorage) GetTwoFactor(userID int64) (*models.TwoFactor, error) {
+	twoFactor := &models.TwoFactor{UserID: userID}
+	var enabledAt sql.NullTime
+	err := s.db.QueryRow(`
+		SELECT COALESCE(secret, ''), enabled_at, required, last_used_step
+		FROM user_two_factor
+		WHERE user_id = ?
+	`, userID).Scan(&twoFactor.Secret, &enabledAt, &twoFactor.Required, &twoFactor.LastUsedStep)
+	if err == sql.ErrNoRows {
+		return twoFactor, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get two-factor settings: %w", err)
+	}
+	if enabledAt.Valid {
+		twoFactor.EnabledAt = &enabledAt.Time
+	}
+	return twoFactor, nil
+}
+
+// SaveTwoFactorSecret stores a secret awaiting confirmation
+func (s *SQLiteStorage) SaveTwoFactorSecret(userID int64, secret string) error {
+	now := time.Now()
+	result, err := s.db.Exec(`
+		INSERT INTO user_two_factor (user_id, secret, updated_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = excluded.secret, last_used_step = 0, updated_at = excluded.updated_at
+		WHERE user_two_factor.enabled_at IS NULL
+	`, userID, secret, now)
+	if err != nil {
+		return fmt.Errorf("failed to save two-factor secret: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrTwoFactorEnabled
+	}
+	return nil
+}
+
+// EnableTwoFactor confirms the stored secret and replaces the recovery codes
+func (s *SQLiteStorage) EnableTwoFactor(userID int64, step int64, recoveryCodeHashes []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result, err := tx.Exec(`
+		UPDATE user_two_factor
+		SET enabled_at = ?, last_used_step = ?, updated_at = ?
+		WHERE user_id = ? AND secret IS NOT NULL AND enabled_at IS NULL
+	`, now, step, now, userID)
+	if err != nil {
+		return fmt.Errorf("failed to enable two-factor: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrTwoFactorNotPending
+	}
+
+	if err := s.replaceRecoveryCodes(tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DisableTwoFactor removes the secret and recovery codes
+func (s *SQLiteStorage) DisableTwoFactor(userID int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		UPDATE user_two_factor
+		SET secret = NULL, enabled_at = NULL, last_used_step = 0, updated_at = ?
+		WHERE user_id = ?
+	`, time.Now(), userID); err != nil {
+		return fmt.Errorf("failed to disable two-factor: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM two_factor_recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	return tx.Commit()
+}
+
+// SetTwoFactorRequired sets whether a user must use two-factor
+func (s *SQLiteStorage) SetTwoFactorRequired(userID int64, required bool) error {
+	_, err := s.db.Exec(`
+		INSERT INTO user_two_factor (user_id, required, updated_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE
+		SET required = excluded.required, updated_at = excluded.updated_at
+	`, userID, required, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to set two-factor requirement: %w", err)
+	}
+	return nil
+}
+
+// UseTwoFactorStep records the time step of an accepted code
+func (s *SQLiteStorage) UseTwoFactorStep(userID int64, step int64) (bool, error) {
+	result, err := s.db.Exec(`
+		UPDATE user_two_factor
+		SET last_used_step = ?
+		WHERE user_id = ? AND last_used_step < ?
+	`, step, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("failed to record two-factor code: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+// ReplaceRecoveryCodes swaps a user's recovery codes for new ones
+func (s *SQLiteStorage) ReplaceRecoveryCodes(userID int64, codeHashes []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.replaceRecoveryCodes(tx, userID, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseRecoveryCode marks an unused recovery code as used
+func (s *SQLiteStorage) UseRecoveryCode(userID int64, codeHash string) (bool, error) {
+	result, err := s.db.Exec(`
+		UPDATE two_factor_recovery_codes
+		SET used_at = ?
+		WHERE user_id = ? AND code_hash = ? AND used_at IS NULL
+	`, time.Now(), userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+// CountRecoveryCodes returns how many unused recovery codes a user has left
+func (s *SQLiteStorage) CountRecoveryCodes(userID int64) (int, error) {
+	var count int
+	err := s.db.QueryRow(`
+		SELECT COUNT(*) FROM two_factor_recovery_codes
+		WHERE user_id = ? AND used_at IS NULL
+	`, userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+	return count, nil
+}
+
+// replaceRecoveryCodes deletes a user's recovery codes and inserts new ones
+func (s *SQLiteStorage) replaceRecoveryCodes(tx *sql.Tx, userID int64, codeHashes []string) error {
+	if _, err := tx.Exec(`DELETE FROM two_factor_recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	now := time.Now()
+	for _, hash := range codeHashes {
+		if _, err := tx.Exec(`
+			INSERT INTO two_factor_recovery_codes (user_id, code_hash, created_at)
+			VALUES (?, ?, ?)
+		`, userID, hash, now); err != nil {
+			return fmt.Errorf("failed to save recovery code: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,522 @@
+package utils
+
+// A QR code encoder (ISO/IEC 18004) for short URLs. Text is encoded in byte
+// mode at the smallest version that fits the requested error correction
+// level, and the mask with the lowest penalty score is chosen.
+
+// QRLevel is a QR code error correction level
+type QRLevel int
+
+// Error correction levels, recovering roughly 7%, 15%, 25% and 30% of the symbol
+const (
+	QRLevelLow QRLevel = iota
+	QRLevelMedium
+	QRLevelQuartile
+	QRLevelHigh
+)
+
+// qrFormatBits are the two bits identifying each level in the format information
+var qrFormatBits = [4]int{1, 0, 3, 2}
+
+// Error correction codewords per block and number of blocks, indexed by
+// level and version (index 0 is unused)
+var qrECCCodewordsPerBlock = [4][41]int{
+	{0, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{0, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+	{0, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{0, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+}
+
+var qrECCBlocks = [4][41]int{
+	{0, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+	{0, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+	{0, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+	{0, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+}
+
+// QRCode is an encoded QR code symbol
+type QRCode struct {
+	Version int
+	Size    int // modules per side, excluding the quiet zone
+	Level   QRLevel
+
+	modules    []bool
+	isFunction []bool
+}
+
+// EncodeQR encodes text as a QR code at the given error correction level
+func EncodeQR(text string, level QRLevel) (*QRCode, error) {
+	if level < QRLevelLow || level > QRLevelHigh {
+		return nil, ErrQRInvalidLevel
+	}
+
+	data := []byte(text)
+	version := 0
+	for v := 1; v <= 40; v++ {
+		if qrDataBits(v, len(data)) <= qrDataCodewords(v, level)*8 {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrQRDataTooLong
+	}
+
+	// Byte mode segment, terminator and padding
+	var bits qrBitBuffer
+	bits.append(0x4, 4)
+	bits.append(len(data), qrCountBits(version))
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+	capacity := qrDataCodewords(version, level) * 8
+	terminator := capacity - len(bits)
+	if terminator > 4 {
+		terminator = 4
+	}
+	bits.append(0, terminator)
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+
+	codewords := make([]byte, len(bits)/8)
+	for i, bit := range bits {
+		if bit {
+			codewords[i>>3] |= 1 << (7 - uint(i&7))
+		}
+	}
+
+	size := version*4 + 17
+	qr := &QRCode{
+		Version:    version,
+		Size:       size,
+		Level:      level,
+		modules:    make([]bool, size*size),
+		isFunction: make([]bool, size*size),
+	}
+	qr.drawFunctionPatterns()
+	qr.drawCodewords(qr.addErrorCorrection(codewords))
+
+	bestMask, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		qr.applyMask(mask)
+		qr.drawFormatBits(mask)
+		if penalty := qr.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			bestMask, bestPenalty = mask, penalty
+		}
+		qr.applyMask(mask) // masking is its own inverse
+	}
+	qr.applyMask(bestMask)
+	qr.drawFormatBits(bestMask)
+
+	return qr, nil
+}
+
+// Dark reports whether the module at column x and row y is dark. Coordinates
+// outside the symbol are light.
+func (q *QRCode) Dark(x, y int) bool {
+	if x < 0 || y < 0 || x >= q.Size || y >= q.Size {
+		return false
+	}
+	return q.modules[y*q.Size+x]
+}
+
+func (q *QRCode) set(x, y int, dark bool) {
+	q.modules[y*q.Size+x] = dark
+}
+
+func (q *QRCode) setFunction(x, y int, dark bool) {
+	q.modules[y*q.Size+x] = dark
+	q.isFunction[y*q.Size+x] = true
+}
+
+// drawFunctionPatterns draws the timing, finder and alignment patterns and
+// reserves the format and version areas
+func (q *QRCode) drawFunctionPatterns() {
+	for i := 0; i < q.Size; i++ {
+		q.setFunction(6, i, i%2 == 0)
+		q.setFunction(i, 6, i%2 == 0)
+	}
+
+	q.drawFinderPattern(3, 3)
+	q.drawFinderPattern(q.Size-4, 3)
+	q.drawFinderPattern(3, q.Size-4)
+
+	positions := qrAlignmentPositions(q.Version)
+	last := len(positions) - 1
+	for i, x := range positions {
+		for j, y := range positions {
+			// Skip the corners taken by finder patterns
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			q.drawAlignmentPattern(x, y)
+		}
+	}
+
+	q.drawFormatBits(0)
+	q.drawVersion()
+}
+
+// drawFinderPattern draws a finder pattern and its separator centred on x, y
+func (q *QRCode) drawFinderPattern(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || yy < 0 || xx >= q.Size || yy >= q.Size {
+				continue
+			}
+			dist := qrMax(qrAbs(dx), qrAbs(dy))
+			q.setFunction(xx, yy, dist != 2 && dist != 4)
+		}
+	}
+}
+
+// drawAlignmentPattern draws an alignment pattern centred on x, y
+func (q *QRCode) drawAlignmentPattern(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			q.setFunction(x+dx, y+dy, qrMax(qrAbs(dx), qrAbs(dy)) != 1)
+		}
+	}
+}
+
+// drawFormatBits draws both copies of the level and mask, protected by a BCH code
+func (q *QRCode) drawFormatBits(mask int) {
+	data := qrFormatBits[q.Level]<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+
+	// Around the top-left finder
+	for i := 0; i <= 5; i++ {
+		q.setFunction(8, i, qrBit(bits, i))
+	}
+	q.setFunction(8, 7, qrBit(bits, 6))
+	q.setFunction(8, 8, qrBit(bits, 7))
+	q.setFunction(7, 8, qrBit(bits, 8))
+	for i := 9; i < 15; i++ {
+		q.setFunction(14-i, 8, qrBit(bits, i))
+	}
+
+	// Split between the other two finders
+	for i := 0; i < 8; i++ {
+		q.setFunction(q.Size-1-i, 8, qrBit(bits, i))
+	}
+	for i := 8; i < 15; i++ {
+		q.setFunction(8, q.Size-15+i, qrBit(bits, i))
+	}
+	q.setFunction(8, q.Size-8, true) // always dark
+}
+
+// drawVersion draws both copies of the version information from version 7 up
+func (q *QRCode) drawVersion() {
+	if q.Version < 7 {
+		return
+	}
+	rem := q.Version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	bits := q.Version<<12 | rem
+
+	for i := 0; i < 18; i++ {
+		a, b := q.Size-11+i%3, i/3
+		q.setFunction(a, b, qrBit(bits, i))
+		q.setFunction(b, a, qrBit(bits, i))
+	}
+}
+
+// addErrorCorrection splits data into blocks, appends Reed-Solomon codewords
+// to each and interleaves the result
+func (q *QRCode) addErrorCorrection(data []byte) []byte {
+	numBlocks := qrECCBlocks[q.Level][q.Version]
+	eccLen := qrECCCodewordsPerBlock[q.Level][q.Version]
+	rawCodewords := qrRawDataModules(q.Version) / 8
+	numShortBlocks := numBlocks - rawCodewords%numBlocks
+	shortBlockLen := rawCodewords / numBlocks
+
+	divisor := qrReedSolomonDivisor(eccLen)
+	blocks := make([][]byte, numBlocks)
+	for i, k := 0, 0; i < numBlocks; i++ {
+		dataLen := shortBlockLen - eccLen
+		if i >= numShortBlocks {
+			dataLen++
+		}
+		block := make([]byte, 0, shortBlockLen+1)
+		block = append(block, data[k:k+dataLen]...)
+		k += dataLen
+		ecc := qrReedSolomonRemainder(block, divisor)
+		if i < numShortBlocks {
+			block = append(block, 0) // placeholder skipped when interleaving
+		}
+		blocks[i] = append(block, ecc...)
+	}
+
+	result := make([]byte, 0, rawCodewords)
+	for i := 0; i < len(blocks[0]); i++ {
+		for j, block := range blocks {
+			if i != shortBlockLen-eccLen || j >= numShortBlocks {
+				result = append(result, block[i])
+			}
+		}
+	}
+	return result
+}
+
+// drawCodewords fills the data area in the zigzag order, two columns at a
+// time from the bottom right
+func (q *QRCode) drawCodewords(data []byte) {
+	i := 0
+	for right := q.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5 // skip the vertical timing pattern
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < q.Size; vert++ {
+			y := vert
+			if upward {
+				y = q.Size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if q.isFunction[y*q.Size+x] || i >= len(data)*8 {
+					continue
+				}
+				q.set(x, y, qrBit(int(data[i>>3]), 7-(i&7)))
+				i++
+			}
+		}
+	}
+}
+
+// applyMask inverts the data modules selected by a mask pattern
+func (q *QRCode) applyMask(mask int) {
+	for y := 0; y < q.Size; y++ {
+		for x := 0; x < q.Size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !q.isFunction[y*q.Size+x] {
+				q.modules[y*q.Size+x] = !q.modules[y*q.Size+x]
+			}
+		}
+	}
+}
+
+// penalty scores the symbol by the four rules used to choose a mask: long
+// runs, 2x2 blocks, finder-like patterns and an unbalanced dark ratio
+func (q *QRCode) penalty() int {
+	result := 0
+	dark := 0
+	for a := 0; a < q.Size; a++ {
+		for _, horizontal := range []bool{true, false} {
+			module := func(i int) bool {
+				if horizontal {
+					return q.Dark(i, a)
+				}
+				return q.Dark(a, i)
+			}
+
+			run := 1
+			for i := 1; i <= q.Size; i++ {
+				if i < q.Size && module(i) == module(i-1) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					result += 3 + run - 5
+				}
+				run = 1
+			}
+
+			for i := 0; i+11 <= q.Size; i++ {
+				if qrMatchesFinder(module, i) {
+					result += 40
+				}
+			}
+		}
+
+		for b := 0; b < q.Size; b++ {
+			if q.Dark(b, a) {
+				dark++
+			}
+			if a+1 < q.Size && b+1 < q.Size {
+				c := q.Dark(b, a)
+				if c == q.Dark(b+1, a) && c == q.Dark(b, a+1) && c == q.Dark(b+1, a+1) {
+					result += 3
+				}
+			}
+		}
+	}
+
+	total := q.Size * q.Size
+	result += qrAbs(dark*100/total-50) / 5 * 10
+	return result
+}
+
+// qrMatchesFinder reports whether the 11 modules from i form a 1:1:3:1:1
+// finder-like pattern with four light modules on either side
+func qrMatchesFinder(module func(int) bool, i int) bool {
+	const pattern = "10111010000"
+	forward, backward := true, true
+	for k := 0; k < 11; k++ {
+		dark := module(i + k)
+		if dark != (pattern[k] == '1') {
+			forward = false
+		}
+		if dark != (pattern[10-k] == '1') {
+			backward = false
+		}
+	}
+	return forward || backward
+}
+
+// qrAlignmentPositions returns the centre coordinates of alignment patterns
+func qrAlignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	numAlign := version/7 + 2
+	step := (version*8 + numAlign*3 + 5) / (numAlign*4 - 4) * 2
+	positions := make([]int, numAlign)
+	positions[0] = 6
+	for i, pos := numAlign-1, version*4+17-7; i >= 1; i, pos = i-1, pos-step {
+		positions[i] = pos
+	}
+	return positions
+}
+
+// qrRawDataModules returns the number of modules available for data and
+// error correction codewords, including remainder bits
+func qrRawDataModules(version int) int {
+	result := (16*version+128)*version + 64
+	if version >= 2 {
+		numAlign := version/7 + 2
+		result -= (25*numAlign-10)*numAlign - 55
+		if version >= 7 {
+			result -= 36
+		}
+	}
+	return result
+}
+
+// qrDataCodewords returns the number of data codewords a symbol holds
+func qrDataCodewords(version int, level QRLevel) int {
+	return qrRawDataModules(version)/8 - qrECCCodewordsPerBlock[level][version]*qrECCBlocks[level][version]
+}
+
+// qrCountBits returns the width of the byte mode character count
+func qrCountBits(version int) int {
+	if version <= 9 {
+		return 8
+	}
+	return 16
+}
+
+// qrDataBits returns the bits needed for a byte mode segment of n bytes
+func qrDataBits(version, n int) int {
+	return 4 + qrCountBits(version) + n*8
+}
+
+// qrReedSolomonDivisor returns the generator polynomial of the given degree,
+// highest term first with the leading 1 omitted
+func qrReedSolomonDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := 0; j < degree; j++ {
+			result[j] = qrMultiply(result[j], root)
+			if j+1 < degree {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = qrMultiply(root, 0x02)
+	}
+	return result
+}
+
+// qrReedSolomonRemainder returns the error correction codewords for data
+func qrReedSolomonRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, d := range divisor {
+			result[i] ^= qrMultiply(d, factor)
+		}
+	}
+	return result
+}
+
+// qrMultiply multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1
+func qrMultiply(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>uint(i))&1) * int(x)
+	}
+	return byte(z)
+}
+
+// qrBitBuffer is a sequence of bits, most significant first
+type qrBitBuffer []bool
+
+func (b *qrBitBuffer) append(value, length int) {
+	for i := length - 1; i >= 0; i-- {
+		*b = append(*b, (value>>uint(i))&1 != 0)
+	}
+}
+
+func qrBit(value, i int) bool {
+	return (value>>uint(i))&1 != 0
+}
+
+func qrAbs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func qrMax(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// QR code errors
+var (
+	ErrQRInvalidLevel = &QRCodeError{Message: "invalid QR code error correction level"}
+	ErrQRDataTooLong  = &QRCodeError{Message: "text is too long for a QR code"}
+)
+
+type QRCodeError struct {
+	Message string
+}
+
+func (e *QRCodeError) Error() string {
+	return e.Message
+}
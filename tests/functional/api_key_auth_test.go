@@ -0,0 +1,134 @@
+package functional
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/URLshorter/url-shortener/internal/middleware"
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/URLshorter/url-shortener/internal/services"
+	"github.com/URLshorter/url-shortener/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubAPIKeys validates a fixed set of keys and records their usage
+type stubAPIKeys struct {
+	keys map[string]*models.APIKey
+
+	mu       sync.Mutex
+	usage    []string
+	lastUsed map[int64]string
+}
+
+func (s *stubAPIKeys) ValidateAPIKey(key string) (*models.APIKey, error) {
+	apiKey, ok := s.keys[key]
+	if !ok {
+		return nil, services.ErrInvalidAPIKey
+	}
+	return apiKey, nil
+}
+
+func (s *stubAPIKeys) RecordAPIKeyUsage(keyID int64, endpoint, method string, statusCode, responseTimeMs, requestSize, responseSize int, ipAddress, userAgent string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.usage = append(s.usage, method+" "+endpoint+" "+http.StatusText(statusCode))
+	return nil
+}
+
+func (s *stubAPIKeys) UpdateAPIKeyUsage(keyID int64, ipAddress string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastUsed[keyID] = ipAddress
+	return nil
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	keys := &stubAPIKeys{
+		keys: map[string]*models.APIKey{
+			"us_reader": {ID: 1, UserID: 7, Permissions: models.APIKeyPermissions{models.ScopeURLsRead}, RateLimit: 100},
+			"us_admin":  {ID: 2, UserID: 7, Permissions: models.AdminAPIKeyPermissions},
+			"us_banned": {ID: 3, UserID: 8, Permissions: models.AdminAPIKeyPermissions},
+		},
+		lastUsed: make(map[int64]string),
+	}
+	store, cache := storage.NewMemoryStorage(), storage.NewMemoryCache()
+	now := time.Now()
+	require.NoError(t, store.CreateUser(&models.User{ID: 7, Name: "Ada", Email: "ada@example.com", Provider: "email", AccountType: "free", IsActive: true, CreatedAt: now, UpdatedAt: now}))
+	require.NoError(t, store.CreateUser(&models.User{ID: 8, Name: "Mallory", Email: "mallory@example.com", Provider: "email", AccountType: "free", IsActive: false, CreatedAt: now, UpdatedAt: now}))
+
+	usage := services.NewAPIKeyUsageRecorder(keys, services.APIKeyUsageConfig{})
+	jwtService := services.NewJWTService("secret", "test", time.Hour, time.Hour)
+	jwtAuth := middleware.NewAuthMiddleware(jwtService, services.NewUserService(store, cache, jwtService, nil, nil, nil), nil)
+	apiKeyAuth := middleware.NewAPIKeyAuth(jwtAuth, keys, usage)
+
+	router := gin.New()
+	respond := func(c *gin.Context) {
+		userID, _ := middleware.GetUserID(c)
+		c.JSON(http.StatusOK, gin.H{"user_id": userID})
+	}
+	router.GET("/my-urls", apiKeyAuth.RequireAuth(), middleware.RequireScope(models.ScopeURLsRead), respond)
+	router.PUT("/my-urls/:shortCode", apiKeyAuth.RequireAuth(), middleware.RequireScope(models.ScopeURLsWrite), respond)
+	router.POST("/shorten", apiKeyAuth.OptionalAuth(), middleware.RequireScope(models.ScopeURLsWrite), respond)
+
+	request := func(method, path, key string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request(http.MethodGet, "/my-urls", "us_reader")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"user_id": 7}`, w.Body.String())
+
+	// Keys only reach routes requiring a scope they were granted
+	w = request(http.MethodPut, "/my-urls/abc", "us_reader")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "insufficient_scope")
+	assert.Equal(t, http.StatusOK, request(http.MethodPut, "/my-urls/abc", "us_admin").Code)
+
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodGet, "/my-urls", "us_unknown").Code)
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodGet, "/my-urls", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodGet, "/my-urls", "not-a-jwt").Code)
+
+	// Keys of suspended accounts are refused like their tokens
+	w = request(http.MethodGet, "/my-urls", "us_banned")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "account_suspended")
+	assert.Equal(t, http.StatusForbidden, request(http.MethodPost, "/shorten", "us_banned").Code)
+
+	// Optional auth lets anonymous callers through but not invalid keys
+	w = request(http.MethodPost, "/shorten", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"user_id": 0}`, w.Body.String())
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodPost, "/shorten", "us_unknown").Code)
+	assert.Equal(t, http.StatusForbidden, request(http.MethodPost, "/shorten", "us_reader").Code)
+
+	require.NoError(t, usage.Shutdown(context.Background()))
+	assert.ElementsMatch(t, []string{
+		"GET /my-urls OK",
+		"PUT /my-urls/:shortCode Forbidden",
+		"PUT /my-urls/:shortCode OK",
+		"POST /shorten Forbidden",
+	}, keys.usage)
+	assert.Len(t, keys.lastUsed, 2)
+}
+
+func TestAPIKeyPermissions_Allows(t *testing.T) {
+	permissions := models.APIKeyPermissions{models.ScopeURLsRead, models.ScopeAnalyticsRead}
+	assert.True(t, permissions.Allows(models.ScopeURLsRead))
+	assert.False(t, permissions.Allows(models.ScopeURLsWrite))
+	assert.True(t, models.AdminAPIKeyPermissions.Allows(models.ScopeDomainsWrite))
+	assert.False(t, models.APIKeyPermissions(nil).Allows(models.ScopeURLsRead))
+}
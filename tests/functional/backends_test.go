@@ -0,0 +1,148 @@
+package functional
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/URLshorter/url-shortener/configs"
+	"github.com/URLshorter/url-shortener/internal/migrations"
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/URLshorter/url-shortener/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+// testBackend opens a fresh store and cache of one storage backend
+type testBackend struct {
+	name string
+	open func(t *testing.T) (storage.Store, storage.Cache)
+}
+
+// testBackends lists the backends. PostgreSQL is skipped when no database
+// is reachable.
+var testBackends = []testBackend{
+	{
+		name: "memory",
+		open: func(t *testing.T) (storage.Store, storage.Cache) {
+			return storage.NewMemoryStorage(), storage.NewMemoryCache()
+		},
+	},
+	{
+		name: "sqlite",
+		open: func(t *testing.T) (storage.Store, storage.Cache) {
+			store, err := storage.NewSQLiteStorage(&configs.Config{SQLitePath: filepath.Join(t.TempDir(), "shortener.db")})
+			require.NoError(t, err)
+			t.Cleanup(func() { store.Close() })
+			return store, storage.NewLRUCache(100)
+		},
+	},
+	{
+		name: "postgres",
+		open: func(t *testing.T) (storage.Store, storage.Cache) {
+			return newScratchPostgres(t), storage.NewMemoryCache()
+		},
+	},
+}
+
+// newTestPostgres connects to the database configured by the DB_* environment
+// variables, creating the schema, or skips the test if none is reachable
+func newTestPostgres(t *testing.T) *storage.PostgresStorage {
+	if testing.Short() {
+		t.Skip("skipping database test in short mode")
+	}
+
+	config, err := configs.LoadConfig()
+	require.NoError(t, err)
+	return openTestPostgres(t, config)
+}
+
+// newScratchPostgres creates a database of its own next to the configured
+// one, dropped when the test ends, so tests start empty and can't see each
+// other's rows or those of earlier runs. It skips the test if no database is
+// reachable or the user may not create databases.
+func newScratchPostgres(t *testing.T) *storage.PostgresStorage {
+	if testing.Short() {
+		t.Skip("skipping database test in short mode")
+	}
+
+	config, err := configs.LoadConfig()
+	require.NoError(t, err)
+
+	admin, err := storage.NewPostgresStorage(config)
+	if err != nil {
+		t.Skipf("database not available: %v", err)
+	}
+	name := fmt.Sprintf("shortener_test_%d", time.Now().UnixNano())
+	if _, err := admin.DB().Exec("CREATE DATABASE " + name); err != nil {
+		admin.Close()
+		t.Skipf("can't create a scratch database: %v", err)
+	}
+	t.Cleanup(func() {
+		defer admin.Close()
+		if _, err := admin.DB().Exec("DROP DATABASE IF EXISTS " + name + " WITH (FORCE)"); err != nil {
+			t.Logf("failed to drop scratch database %s: %v", name, err)
+		}
+	})
+
+	scratch := *config
+	scratch.DBName = name
+	return openTestPostgres(t, &scratch)
+}
+
+// openTestPostgres connects to the database of config and creates the
+// schema, or skips the test if it isn't reachable
+func openTestPostgres(t *testing.T, config *configs.Config) *storage.PostgresStorage {
+	db, err := storage.NewPostgresStorage(config)
+	if err != nil {
+		t.Skipf("database not available: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	runner, err := migrations.NewRunner(db.DB())
+	require.NoError(t, err)
+	_, err = runner.Up(context.Background(), 0)
+	require.NoError(t, err)
+	return db
+}
+
+// createOwners adds a user for each ID, since PostgreSQL only stores links
+// whose owner exists
+func createOwners(t *testing.T, store storage.AccountStore, ids ...int64) {
+	now := time.Now()
+	for _, id := range ids {
+		require.NoError(t, store.CreateUser(&models.User{
+			ID: id, Name: fmt.Sprintf("Owner %d", id), Email: fmt.Sprintf("owner%d@example.com", id), Provider: "email",
+			AccountType: "free", IsActive: true, CreatedAt: now, UpdatedAt: now,
+		}))
+	}
+}
+
+// newTestRedis connects to the Redis configured by the REDIS_* environment
+// variables or skips the test if none is reachable
+func newTestRedis(t *testing.T) *storage.RedisStorage {
+	if testing.Short() {
+		t.Skip("skipping Redis test in short mode")
+	}
+
+	config, err := configs.LoadConfig()
+	require.NoError(t, err)
+
+	redis, err := storage.NewRedisStorage(config)
+	if err != nil {
+		t.Skipf("Redis not available: %v", err)
+	}
+	t.Cleanup(func() { redis.Close() })
+	return redis
+}
+
+// forEachBackend runs test as a subtest against every backend in testBackends
+func forEachBackend(t *testing.T, test func(t *testing.T, store storage.Store, cache storage.Cache)) {
+	for _, backend := range testBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			store, cache := backend.open(t)
+			test(t, store, cache)
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package functional
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/URLshorter/url-shortener/configs"
+	"github.com/URLshorter/url-shortener/internal/handlers"
+	"github.com/URLshorter/url-shortener/internal/middleware"
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/URLshorter/url-shortener/internal/routes"
+	"github.com/URLshorter/url-shortener/internal/services"
+	"github.com/URLshorter/url-shortener/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const bulkImportCSV = `url,custom_code,expires_at,title,tags
+https://www.example.com/one,bulkone,,First link,Launch; Q3 ;launch
+https://www.example.com/two,,2099-01-01T00:00:00Z,,
+,,,,
+https://www.example.com/three,,tomorrow,,
+https://www.example.com/four,bulkone,,,
+not a url,,,,
+`
+
+func TestParseBulkImport(t *testing.T) {
+	rows, err := services.ParseBulkImport(models.BulkFormatCSV, strings.NewReader(bulkImportCSV), 100)
+	require.NoError(t, err)
+	require.Len(t, rows, 6)
+
+	assert.Equal(t, 2, rows[0].Line)
+	assert.Equal(t, models.BulkRowPending, rows[0].Status)
+	assert.Equal(t, models.Tags{"launch", "q3"}, rows[0].Tags)
+	assert.NotNil(t, rows[1].ExpiresAt)
+	assert.Equal(t, "url is required", rows[2].Error)
+	assert.Equal(t, models.BulkRowFailed, rows[3].Status)
+	assert.Equal(t, "custom code already used on line 2", rows[4].Error)
+	// URL validation happens when the row is imported
+	assert.Equal(t, models.BulkRowPending, rows[5].Status)
+
+	ndjson := "{\"url\": \"https://www.example.com\", \"tags\": [\"a\"]}\n\n{broken\n"
+	rows, err = services.ParseBulkImport(models.BulkFormatNDJSON, strings.NewReader(ndjson), 100)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, 3, rows[1].Line)
+	assert.Equal(t, models.BulkRowFailed, rows[1].Status)
+
+	_, err = services.ParseBulkImport(models.BulkFormatCSV, strings.NewReader("link\nhttps://www.example.com\n"), 100)
+	assert.Error(t, err)
+	_, err = services.ParseBulkImport(models.BulkFormatCSV, strings.NewReader(bulkImportCSV), 2)
+	assert.Error(t, err)
+}
+
+func TestBulkImportService(t *testing.T) {
+	forEachBackend(t, exerciseBulkImport)
+}
+
+// exerciseBulkImport imports a file, re-uploads it and exports the result
+func exerciseBulkImport(t *testing.T, store storage.Store, cache storage.Cache) {
+	config := &configs.Config{BaseURL: "http://localhost:8080", ServerHost: "localhost", BulkImportBatchSize: 2}
+	shortener := services.NewShortenerService(store, cache, config)
+	bulk := services.NewBulkImportService(store, shortener, config)
+	bulk.Start()
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		require.NoError(t, bulk.Shutdown(ctx))
+		require.NoError(t, shortener.Shutdown(ctx))
+	}()
+
+	owner := int64(7)
+	createOwners(t, store, owner)
+	job, created, err := bulk.Submit(owner, models.BulkFormatCSV, []byte(bulkImportCSV))
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, 6, job.TotalRows)
+
+	require.Eventually(t, func() bool {
+		job, err = bulk.GetJob(owner, job.ID)
+		return err == nil && job.IsFinished()
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.Equal(t, models.BulkImportCompleted, job.Status)
+	assert.Equal(t, 6, job.ProcessedRows)
+	assert.Equal(t, 2, job.CreatedRows)
+	assert.Equal(t, 4, job.FailedRows)
+
+	failed, err := bulk.ListRows(owner, job.ID, models.BulkRowFailed, 0, 2)
+	require.NoError(t, err)
+	require.Len(t, failed.Rows, 2)
+	require.NotNil(t, failed.NextAfter)
+	failed, err = bulk.ListRows(owner, job.ID, models.BulkRowFailed, *failed.NextAfter, 2)
+	require.NoError(t, err)
+	require.Len(t, failed.Rows, 2)
+	assert.Equal(t, 7, failed.Rows[1].Line)
+	assert.Equal(t, services.ErrInvalidURLScheme.Message, failed.Rows[1].Error)
+
+	// Re-uploading the same file returns the finished job
+	again, created, err := bulk.Submit(owner, models.BulkFormatCSV, []byte(bulkImportCSV))
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, job.ID, again.ID)
+
+	_, err = bulk.GetJob(owner+1, job.ID)
+	assert.Equal(t, storage.ErrImportJobNotFound, err)
+
+	var exported bytes.Buffer
+	require.NoError(t, bulk.Export(owner, models.BulkFormatCSV, &exported))
+	lines := strings.Split(strings.TrimSpace(exported.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[1], "https://www.example.com/one,bulkone,,First link,launch;q3,http://localhost:8080/bulkone,0,"))
+	assert.Contains(t, lines[2], "2099-01-01T00:00:00Z")
+
+	bulk.SetPlanLookup(func(int64) (string, error) { return "free", nil })
+	_, _, err = bulk.Submit(owner, models.BulkFormatCSV, []byte("url\nhttps://www.example.com/five\n"))
+	assert.Equal(t, services.ErrBulkImportNotInPlan, err)
+}
+
+func TestBulkImportRoutes_RequireAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store, cache := storage.NewMemoryStorage(), storage.NewMemoryCache()
+	config := &configs.Config{BaseURL: "http://localhost:8080", ServerHost: "localhost"}
+	shortener := services.NewShortenerService(store, cache, config)
+
+	jwtService := services.NewJWTService("secret", "test", time.Hour, time.Hour)
+	userService := services.NewUserService(store, cache, jwtService, nil, nil, nil)
+	authMiddleware := middleware.NewAuthMiddleware(jwtService, userService, nil)
+	handler := handlers.NewHandler(shortener, services.NewAnalyticsService(store), nil, nil, nil, nil, nil, nil, nil, nil)
+	handler.BulkImportHandlers = handlers.NewBulkImportHandler(services.NewBulkImportService(store, shortener, config))
+
+	router := gin.New()
+	routes.SetupRoutes(router, handler, authMiddleware, middleware.NewAPIKeyAuth(authMiddleware, nil, nil), middleware.NewRateLimiter(cache))
+
+	user := &models.User{ID: 7, Email: "owner@example.com", Provider: "email", AccountType: "free", IsActive: true}
+	require.NoError(t, store.CreateUser(user))
+	pair, err := jwtService.GenerateTokenPair(user, "")
+	require.NoError(t, err)
+	request := func(method, path, token string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader("url\nhttps://www.example.com\n"))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodPost, "/api/v1/imports?format=csv", ""))
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodGet, "/api/v1/imports/1", ""))
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodGet, "/api/v1/imports/1/rows", ""))
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodGet, "/api/v1/my-urls/export", ""))
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "/api/v1/my-urls/export", pair.AccessToken))
+}
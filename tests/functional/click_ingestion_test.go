@@ -0,0 +1,142 @@
+package functional
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/URLshorter/url-shortener/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClickStore struct {
+	mu          sync.Mutex
+	failBatch   bool
+	unavailable bool
+	saved       []*models.ClickEvent
+	ids         map[int64]bool
+	counts      map[string]int64
+}
+
+func newFakeClickStore() *fakeClickStore {
+	return &fakeClickStore{ids: map[int64]bool{}, counts: map[string]int64{}}
+}
+
+func (f *fakeClickStore) SaveClickEvents(events []*models.ClickEvent) ([]int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.unavailable {
+		return nil, errors.New("connection refused")
+	}
+	if f.failBatch && len(events) > 1 {
+		return nil, errors.New("batch failed")
+	}
+
+	var inserted []int64
+	for _, event := range events {
+		if event.ShortCode == "bad" {
+			return nil, errors.New("constraint violation")
+		}
+		if f.ids[event.ID] {
+			continue
+		}
+		f.ids[event.ID] = true
+		f.saved = append(f.saved, event)
+		inserted = append(inserted, event.ID)
+	}
+	return inserted, nil
+}
+
+func (f *fakeClickStore) IncrementClickCounts(counts map[string]int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for shortCode, n := range counts {
+		f.counts[shortCode] += n
+	}
+	return nil
+}
+
+func TestClickIngestionPipelineDrainsOnShutdown(t *testing.T) {
+	store := newFakeClickStore()
+	pipeline := services.NewClickIngestionPipeline(store, nil, services.ClickIngestionConfig{
+		QueueSize:     100,
+		Workers:       2,
+		BatchSize:     10,
+		FlushInterval: time.Hour,
+	})
+
+	for i := 0; i < 25; i++ {
+		shortCode := "abc"
+		if i%5 == 0 {
+			shortCode = "xyz"
+		}
+		require.True(t, pipeline.Enqueue(&models.ClickEvent{ID: int64(i), ShortCode: shortCode}))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, pipeline.Shutdown(ctx))
+
+	assert.Len(t, store.saved, 25)
+	assert.Equal(t, int64(20), store.counts["abc"])
+	assert.Equal(t, int64(5), store.counts["xyz"])
+
+	// Events arriving after shutdown are dropped, not lost silently
+	assert.False(t, pipeline.Enqueue(&models.ClickEvent{ShortCode: "abc"}))
+	stats := pipeline.Stats()
+	assert.Equal(t, uint64(25), stats.Persisted)
+	assert.Equal(t, uint64(1), stats.Dropped)
+}
+
+func TestClickIngestionPipelineRetriesFailedBatchIndividually(t *testing.T) {
+	store := newFakeClickStore()
+	store.failBatch = true
+	pipeline := services.NewClickIngestionPipeline(store, nil, services.ClickIngestionConfig{Workers: 1, BatchSize: 10})
+
+	pipeline.Enqueue(&models.ClickEvent{ID: 1, ShortCode: "abc"})
+	pipeline.Enqueue(&models.ClickEvent{ID: 2, ShortCode: "bad"})
+	pipeline.Enqueue(&models.ClickEvent{ID: 3, ShortCode: "abc"})
+	require.NoError(t, pipeline.Shutdown(context.Background()))
+
+	assert.Len(t, store.saved, 2)
+	assert.Equal(t, int64(2), store.counts["abc"])
+	assert.Equal(t, uint64(1), pipeline.Stats().Failed)
+}
+
+func TestClickEffectQueueKeepsSideEffectsOffTheFlushPath(t *testing.T) {
+	started := make(chan struct{}, 5)
+	release := make(chan struct{})
+	effects := services.NewClickEffectQueue(func(event *models.ClickEvent) {
+		started <- struct{}{}
+		<-release
+	}, services.ClickEffectConfig{QueueSize: 3, Workers: 1})
+
+	store := newFakeClickStore()
+	pipeline := services.NewClickIngestionPipeline(store, effects.Enqueue, services.ClickIngestionConfig{
+		Workers:       1,
+		BatchSize:     1,
+		FlushInterval: time.Hour,
+	})
+
+	// Clicks keep being persisted while the side effects of the first one hang
+	require.True(t, pipeline.Enqueue(&models.ClickEvent{ID: 0, ShortCode: "abc"}))
+	<-started
+	for i := 1; i < 5; i++ {
+		require.True(t, pipeline.Enqueue(&models.ClickEvent{ID: int64(i), ShortCode: "abc"}))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, pipeline.Shutdown(ctx))
+	assert.Equal(t, 5, store.savedCount())
+
+	// One click was being processed and three were queued; the last was dropped
+	close(release)
+	require.NoError(t, effects.Shutdown(ctx))
+	stats := effects.Stats()
+	assert.Equal(t, uint64(4), stats.Processed)
+	assert.Equal(t, uint64(1), stats.Dropped)
+}
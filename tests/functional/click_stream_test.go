@@ -0,0 +1,284 @@
+package functional
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/URLshorter/url-shortener/internal/services"
+	"github.com/URLshorter/url-shortener/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStreamEntry is a stream entry with its consumer group state
+type fakeStreamEntry struct {
+	storage.StreamMessage
+	delivered     bool
+	pending       bool
+	deliveries    int64
+	lastDelivered time.Time
+}
+
+// fakeClickStreamBroker is an in-memory Redis Stream with one consumer group
+type fakeClickStreamBroker struct {
+	mu      sync.Mutex
+	seq     int64
+	entries []*fakeStreamEntry
+	dead    []storage.StreamMessage
+	maxLens []int64 // caps requested when adding clicks
+}
+
+func (f *fakeClickStreamBroker) StreamAdd(stream string, maxLen int64, values map[string]interface{}) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Redis stores every value as a string
+	stringValues := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		if data, ok := value.([]byte); ok {
+			value = string(data)
+		}
+		stringValues[key] = fmt.Sprint(value)
+	}
+
+	f.seq++
+	msg := storage.StreamMessage{ID: fmt.Sprintf("%d-0", f.seq), Values: stringValues}
+	if stream == services.ClickDeadLetterStream {
+		f.dead = append(f.dead, msg)
+		return msg.ID, nil
+	}
+	f.entries = append(f.entries, &fakeStreamEntry{StreamMessage: msg})
+	f.maxLens = append(f.maxLens, maxLen)
+	return msg.ID, nil
+}
+
+func (f *fakeClickStreamBroker) StreamCreateGroup(stream, group string) error {
+	return nil
+}
+
+func (f *fakeClickStreamBroker) StreamReadGroup(stream, group, consumer string, count int64, block time.Duration) ([]storage.StreamMessage, error) {
+	f.mu.Lock()
+	var messages []storage.StreamMessage
+	for _, entry := range f.entries {
+		if int64(len(messages)) == count {
+			break
+		}
+		if !entry.delivered {
+			entry.delivered, entry.pending = true, true
+			entry.deliveries, entry.lastDelivered = 1, time.Now()
+			messages = append(messages, entry.StreamMessage)
+		}
+	}
+	f.mu.Unlock()
+
+	if len(messages) == 0 {
+		time.Sleep(block)
+	}
+	return messages, nil
+}
+
+func (f *fakeClickStreamBroker) StreamAck(stream, group string, ids ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, entry := range f.entries {
+		for _, id := range ids {
+			if entry.ID == id {
+				entry.pending = false
+			}
+		}
+	}
+	return nil
+}
+
+func (f *fakeClickStreamBroker) StreamPending(stream, group string, minIdle time.Duration, count int64) ([]storage.PendingStreamMessage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var pending []storage.PendingStreamMessage
+	for _, entry := range f.entries {
+		if idle := time.Since(entry.lastDelivered); entry.pending && idle >= minIdle && int64(len(pending)) < count {
+			pending = append(pending, storage.PendingStreamMessage{ID: entry.ID, Idle: idle, Deliveries: entry.deliveries})
+		}
+	}
+	return pending, nil
+}
+
+func (f *fakeClickStreamBroker) StreamClaim(stream, group, consumer string, minIdle time.Duration, ids ...string) ([]storage.StreamMessage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var messages []storage.StreamMessage
+	for _, entry := range f.entries {
+		for _, id := range ids {
+			if entry.ID == id && entry.pending && time.Since(entry.lastDelivered) >= minIdle {
+				entry.deliveries++
+				entry.lastDelivered = time.Now()
+				messages = append(messages, entry.StreamMessage)
+			}
+		}
+	}
+	return messages, nil
+}
+
+// StreamTrimAcknowledged keeps entries from the first one pending or not yet
+// delivered, as trimming to the oldest pending ID does in Redis
+func (f *fakeClickStreamBroker) StreamTrimAcknowledged(stream, group string) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for n < len(f.entries) && f.entries[n].delivered && !f.entries[n].pending {
+		n++
+	}
+	f.entries = f.entries[n:]
+	return int64(n), nil
+}
+
+// state returns the number of entries left in the stream, how many of them
+// are pending, and the number of dead-lettered entries
+func (f *fakeClickStreamBroker) state() (entries, pending, dead int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, entry := range f.entries {
+		if entry.pending {
+			pending++
+		}
+	}
+	return len(f.entries), pending, len(f.dead)
+}
+
+func (f *fakeClickStore) savedCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.saved)
+}
+
+func (f *fakeClickStore) setUnavailable(unavailable bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.unavailable = unavailable
+}
+
+// newTestClickStream starts a consumer with short intervals over a fake stream
+func newTestClickStream(t *testing.T, store *fakeClickStore) (*services.ClickStreamConsumer, *fakeClickStreamBroker) {
+	broker := &fakeClickStreamBroker{}
+	consumer := services.NewClickStreamConsumer(broker, store, nil, services.ClickStreamConfig{
+		Consumer:      "test",
+		BatchSize:     10,
+		Block:         5 * time.Millisecond,
+		ClaimIdle:     20 * time.Millisecond,
+		ClaimInterval: 10 * time.Millisecond,
+		MaxDeliveries: 2,
+		MaxBackoff:    40 * time.Millisecond,
+		TrimInterval:  10 * time.Millisecond,
+	})
+	consumer.Start()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		consumer.Shutdown(ctx)
+	})
+	return consumer, broker
+}
+
+func TestClickStreamConsumer_PersistsAndTrimsAcknowledged(t *testing.T) {
+	store := newFakeClickStore()
+	consumer, broker := newTestClickStream(t, store)
+
+	for i := 1; i <= 25; i++ {
+		require.NoError(t, consumer.Publish(&models.ClickEvent{ID: int64(i), ShortCode: "abc"}))
+	}
+
+	require.Eventually(t, func() bool { return store.savedCount() == 25 }, 5*time.Second, 5*time.Millisecond)
+	require.Eventually(t, func() bool {
+		entries, _, _ := broker.state()
+		return entries == 0
+	}, 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, uint64(25), consumer.Stats().Persisted)
+	store.mu.Lock()
+	assert.Equal(t, int64(25), store.counts["abc"])
+	store.mu.Unlock()
+
+	// The stream isn't capped on write, so unacknowledged clicks can't be trimmed away
+	for _, maxLen := range broker.maxLens {
+		assert.Zero(t, maxLen)
+	}
+}
+
+func TestClickStreamConsumer_WaitsOutStoreOutage(t *testing.T) {
+	store := newFakeClickStore()
+	store.setUnavailable(true)
+	consumer, broker := newTestClickStream(t, store)
+
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, consumer.Publish(&models.ClickEvent{ID: int64(i), ShortCode: "abc"}))
+	}
+
+	// Long enough for every entry to be redelivered well past MaxDeliveries
+	time.Sleep(300 * time.Millisecond)
+	entries, pending, dead := broker.state()
+	assert.Equal(t, 5, entries)
+	assert.Equal(t, 5, pending)
+	assert.Zero(t, dead)
+	assert.Zero(t, consumer.Stats().DeadLettered)
+
+	store.setUnavailable(false)
+	require.Eventually(t, func() bool { return store.savedCount() == 5 }, 5*time.Second, 5*time.Millisecond)
+	_, _, dead = broker.state()
+	assert.Zero(t, dead)
+}
+
+func TestClickStreamConsumer_DeadLettersEntriesTheStoreRejects(t *testing.T) {
+	store := newFakeClickStore()
+	consumer, broker := newTestClickStream(t, store)
+
+	require.NoError(t, consumer.Publish(&models.ClickEvent{ID: 1, ShortCode: "bad"}))
+
+	// The entry is only given up on once other clicks are being stored
+	id := int64(2)
+	require.Eventually(t, func() bool {
+		require.NoError(t, consumer.Publish(&models.ClickEvent{ID: id, ShortCode: "abc"}))
+		id++
+		_, _, dead := broker.state()
+		return dead == 1
+	}, 5*time.Second, 5*time.Millisecond)
+
+	assert.Equal(t, uint64(1), consumer.Stats().DeadLettered)
+	require.Eventually(t, func() bool {
+		_, pending, _ := broker.state()
+		return pending == 0
+	}, 5*time.Second, 5*time.Millisecond)
+}
+
+func TestRedisStorage_StreamTrimAcknowledged(t *testing.T) {
+	redis := newTestRedis(t)
+	stream := fmt.Sprintf("test:stream:%d", time.Now().UnixNano())
+	t.Cleanup(func() { redis.Delete(stream) })
+
+	require.NoError(t, redis.StreamCreateGroup(stream, "group"))
+	for i := 0; i < 3; i++ {
+		_, err := redis.StreamAdd(stream, 0, map[string]interface{}{"n": i})
+		require.NoError(t, err)
+	}
+
+	messages, err := redis.StreamReadGroup(stream, "group", "consumer", 2, time.Millisecond)
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	require.NoError(t, redis.StreamAck(stream, "group", messages[0].ID))
+
+	// Only the acknowledged entry goes; the pending and unread ones stay
+	_, err = redis.StreamTrimAcknowledged(stream, "group")
+	require.NoError(t, err)
+	length, err := redis.StreamLen(stream)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), length)
+
+	require.NoError(t, redis.StreamAck(stream, "group", messages[1].ID))
+	_, err = redis.StreamTrimAcknowledged(stream, "group")
+	require.NoError(t, err)
+	unread, err := redis.StreamReadGroup(stream, "group", "consumer", 10, time.Millisecond)
+	require.NoError(t, err)
+	require.Len(t, unread, 1)
+	assert.Equal(t, "2", unread[0].Values["n"])
+}
@@ -0,0 +1,70 @@
+package functional
+
+import (
+	"testing"
+
+	"github.com/URLshorter/url-shortener/configs"
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/URLshorter/url-shortener/internal/services"
+	"github.com/URLshorter/url-shortener/internal/storage"
+	"github.com/URLshorter/url-shortener/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDashboardURLs(t *testing.T) {
+	forEachBackend(t, exerciseDashboardURLs)
+}
+
+// exerciseDashboardURLs looks up, updates and deletes a link as its owner
+// and as another user
+func exerciseDashboardURLs(t *testing.T, store storage.Store, cache storage.Cache) {
+	utils.InitializeSnowflake(1)
+	service := services.NewShortenerService(store, cache, &configs.Config{BaseURL: "http://localhost:8080", ServerHost: "localhost"})
+
+	owner, other := int64(42), int64(43)
+	createOwners(t, store, owner, other)
+	created, err := service.ShortenURL(&models.ShortenRequest{URL: "https://www.example.com/original"}, "127.0.0.1", &owner)
+	require.NoError(t, err)
+	shortCode := created.ShortCode
+
+	url, err := service.GetURLByShortCode(owner, shortCode)
+	require.NoError(t, err)
+	assert.Equal(t, "https://www.example.com/original", url.OriginalURL)
+	assert.Nil(t, url.Title)
+	assert.Equal(t, owner, *url.CreatedBy)
+
+	_, err = service.GetURLByShortCode(other, shortCode)
+	assert.Equal(t, storage.ErrUnauthorized, err)
+	_, err = service.GetURLByShortCode(owner, "missing1")
+	assert.Equal(t, storage.ErrURLNotFound, err)
+
+	title := "Updated title"
+	description := "Updated description"
+	isPublic := false
+	updated, err := service.UpdateURL(owner, shortCode, &models.UpdateURLRequest{
+		Title:       &title,
+		Description: &description,
+		IsPublic:    &isPublic,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, title, *updated.Title)
+	assert.Equal(t, description, *updated.Description)
+	assert.False(t, updated.IsPublic)
+	assert.False(t, updated.UpdatedAt.Before(url.UpdatedAt))
+
+	// Changes are persisted, not just echoed back
+	stored, err := service.GetURLByShortCode(owner, shortCode)
+	require.NoError(t, err)
+	assert.Equal(t, title, *stored.Title)
+
+	_, err = service.UpdateURL(other, shortCode, &models.UpdateURLRequest{Title: &title})
+	assert.Equal(t, storage.ErrUnauthorized, err)
+
+	assert.Equal(t, storage.ErrUnauthorized, service.DeleteURL(other, shortCode))
+	require.NoError(t, service.DeleteURL(owner, shortCode))
+
+	_, err = service.GetURLByShortCode(owner, shortCode)
+	assert.Equal(t, storage.ErrURLNotFound, err)
+	assert.Equal(t, storage.ErrURLNotFound, service.DeleteURL(owner, shortCode))
+}
@@ -0,0 +1,97 @@
+package functional
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/URLshorter/url-shortener/configs"
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/URLshorter/url-shortener/internal/services"
+	"github.com/URLshorter/url-shortener/internal/storage"
+	"github.com/URLshorter/url-shortener/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// separateUpdateStore fails any update made outside a destination change, so
+// a destination change split across two writes fails halfway. With
+// failDestination set destination changes fail too, as a dropped database
+// connection would.
+type separateUpdateStore struct {
+	storage.Store
+	failDestination bool
+}
+
+func (s *separateUpdateStore) UpdateURL(string, int64, *storage.URLUpdate) error {
+	return errors.New("connection reset by peer")
+}
+
+func (s *separateUpdateStore) ChangeURLDestination(shortCode string, userID int64, newURL string, update *storage.URLUpdate) (int, error) {
+	if s.failDestination {
+		return 0, errors.New("connection reset by peer")
+	}
+	return s.Store.ChangeURLDestination(shortCode, userID, newURL, update)
+}
+
+func TestUpdateUserURL_ChangesDestinationAtomically(t *testing.T) {
+	forEachBackend(t, exerciseDestinationChange)
+}
+
+// exerciseDestinationChange changes a link's destination together with other
+// fields and checks they are saved all together or not at all
+func exerciseDestinationChange(t *testing.T, store storage.Store, cache storage.Cache) {
+	utils.InitializeSnowflake(1)
+	config := &configs.Config{BaseURL: "http://localhost:8080", ServerHost: "localhost"}
+	service := services.NewShortenerService(store, cache, config)
+	failing := &separateUpdateStore{Store: store}
+	failingService := services.NewShortenerService(failing, cache, config)
+
+	owner := int64(42)
+	createOwners(t, store, owner)
+	created, err := service.ShortenURL(&models.ShortenRequest{URL: "https://www.example.com/launch"}, "127.0.0.1", &owner)
+	require.NoError(t, err)
+	shortCode := created.ShortCode
+
+	title := "Relaunch"
+	destination := "https://www.example.com/relaunch"
+	updated, err := failingService.UpdateUserURL(owner, shortCode, &models.UpdateURLRequest{OriginalURL: &destination, Title: &title})
+	require.NoError(t, err)
+	assert.Equal(t, destination, updated.OriginalURL)
+	require.NotNil(t, updated.Title)
+	assert.Equal(t, title, *updated.Title)
+
+	history, err := service.GetUserURLDestinationHistory(owner, shortCode)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, 1, history[0].Version)
+	assert.Equal(t, "https://www.example.com/launch", history[0].OriginalURL)
+
+	// A failed destination change leaves the other fields untouched too
+	failing.failDestination = true
+	otherTitle := "Never saved"
+	otherDestination := "https://www.example.com/never"
+	_, err = failingService.UpdateUserURL(owner, shortCode, &models.UpdateURLRequest{OriginalURL: &otherDestination, Title: &otherTitle})
+	require.Error(t, err)
+
+	stored, err := service.GetURLByShortCode(owner, shortCode)
+	require.NoError(t, err)
+	assert.Equal(t, destination, stored.OriginalURL)
+	require.NotNil(t, stored.Title)
+	assert.Equal(t, title, *stored.Title)
+
+	// Other users' links are not found, and nothing is applied to them
+	_, err = store.ChangeURLDestination(shortCode, owner+1, otherDestination, &storage.URLUpdate{Title: &otherTitle})
+	assert.Equal(t, storage.ErrURLNotFound, err)
+	stored, err = service.GetURLByShortCode(owner, shortCode)
+	require.NoError(t, err)
+	assert.Equal(t, destination, stored.OriginalURL)
+	assert.Equal(t, title, *stored.Title)
+
+	// An unchanged destination still applies the update without a new version
+	version, err := store.ChangeURLDestination(shortCode, owner, destination, &storage.URLUpdate{Title: &otherTitle})
+	require.NoError(t, err)
+	assert.Equal(t, 2, version)
+	stored, err = service.GetURLByShortCode(owner, shortCode)
+	require.NoError(t, err)
+	assert.Equal(t, otherTitle, *stored.Title)
+}
@@ -0,0 +1,114 @@
+package functional
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/URLshorter/url-shortener/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadSigningKeys(t *testing.T) {
+	dir := t.TempDir()
+	writeKey := func(name string, key interface{}) string {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		require.NoError(t, err)
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+		return path
+	}
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	next := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	keys, err := services.LoadSigningKeys(fmt.Sprintf("ed=%s, rsa=%s@%s",
+		writeKey("ed.pem", edKey), writeKey("rsa.pem", rsaKey), next.Format(time.RFC3339)))
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.Equal(t, services.SigningAlgorithmEdDSA, keys[0].Algorithm)
+	assert.True(t, keys[0].ActiveFrom.IsZero())
+	assert.Equal(t, services.SigningAlgorithmRS256, keys[1].Algorithm)
+	assert.True(t, next.Equal(keys[1].ActiveFrom))
+
+	_, err = services.LoadSigningKeys("ed=" + filepath.Join(dir, "missing.pem"))
+	assert.Error(t, err)
+	_, err = services.LoadSigningKeys(writeKey("no-kid.pem", edKey))
+	assert.Error(t, err)
+}
+
+func TestJWTService_KeyRotation(t *testing.T) {
+	now := time.Now()
+	newKey := func(id string, activeFrom time.Time) *services.SigningKey {
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		key, err := services.NewSigningKey(id, privateKey, activeFrom)
+		require.NoError(t, err)
+		return key
+	}
+	retired := newKey("retired", now.Add(-100*time.Hour))
+	previous := newKey("previous", now.Add(-48*time.Hour))
+	current := newKey("current", now.Add(-time.Hour))
+	upcoming := newKey("upcoming", now.Add(24*time.Hour))
+	user := &models.User{ID: 7}
+
+	jwtService := services.NewJWTService("", "test", time.Hour, 24*time.Hour)
+	require.NoError(t, jwtService.SetSigningKeys([]*services.SigningKey{upcoming, current, retired, previous}))
+
+	// Tokens are signed with the newest active key
+	pair, err := jwtService.GenerateTokenPair(user, "")
+	require.NoError(t, err)
+	claims, err := jwtService.ValidateToken(pair.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, claims.UserID)
+
+	// The JWKS holds the signing key, the key it replaced within the
+	// overlap and the key scheduled next
+	var kids []string
+	for _, jwk := range jwtService.JWKS().Keys {
+		assert.Equal(t, "OKP", jwk.KeyType)
+		assert.NotEmpty(t, jwk.X)
+		kids = append(kids, jwk.KeyID)
+	}
+	assert.Equal(t, []string{"previous", "current", "upcoming"}, kids)
+
+	// Tokens signed before the rotation still verify within the overlap
+	signedWith := func(key *services.SigningKey) string {
+		signer := services.NewJWTService("", "test", time.Hour, 24*time.Hour)
+		require.NoError(t, signer.SetSigningKeys([]*services.SigningKey{key}))
+		pair, err := signer.GenerateTokenPair(user, "")
+		require.NoError(t, err)
+		return pair.AccessToken
+	}
+	_, err = jwtService.ValidateToken(signedWith(previous))
+	assert.NoError(t, err)
+	_, err = jwtService.ValidateToken(signedWith(retired))
+	assert.Equal(t, services.ErrInvalidToken, err)
+	_, err = jwtService.ValidateToken(signedWith(newKey("unknown", now.Add(-time.Hour))))
+	assert.Equal(t, services.ErrInvalidToken, err)
+
+	// HMAC tokens are only accepted while the secret is configured
+	hmacPair, err := services.NewJWTService("secret", "test", time.Hour, time.Hour).GenerateTokenPair(user, "")
+	require.NoError(t, err)
+	_, err = jwtService.ValidateToken(hmacPair.AccessToken)
+	assert.Equal(t, services.ErrInvalidToken, err)
+
+	migrating := services.NewJWTService("secret", "test", time.Hour, 24*time.Hour)
+	require.NoError(t, migrating.SetSigningKeys([]*services.SigningKey{current}))
+	_, err = migrating.ValidateToken(hmacPair.AccessToken)
+	assert.NoError(t, err)
+
+	assert.Error(t, services.NewJWTService("", "test", time.Hour, time.Hour).SetSigningKeys([]*services.SigningKey{upcoming}))
+}
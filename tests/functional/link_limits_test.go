@@ -0,0 +1,156 @@
+package functional
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/URLshorter/url-shortener/configs"
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/URLshorter/url-shortener/internal/services"
+	"github.com/URLshorter/url-shortener/internal/storage"
+	"github.com/URLshorter/url-shortener/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClickLimits(t *testing.T) {
+	forEachBackend(t, exerciseClickLimits)
+}
+
+// exerciseClickLimits exhausts a click-limited link with human visits while
+// bots come and go without using it up
+func exerciseClickLimits(t *testing.T, store storage.Store, cache storage.Cache) {
+	utils.InitializeSnowflake(1)
+	config := &configs.Config{BaseURL: "http://localhost:8080", ServerHost: "localhost", ClickFlushIntervalMs: 10}
+	service := services.NewShortenerService(store, cache, config)
+
+	owner := int64(42)
+	createOwners(t, store, owner)
+	_, err := service.ShortenURL(&models.ShortenRequest{URL: "https://www.example.com/drop", MaxClicks: int64Ptr(0)}, "127.0.0.1", &owner)
+	assert.Equal(t, services.ErrInvalidMaxClicks, err)
+
+	created, err := service.ShortenURL(&models.ShortenRequest{
+		URL:         "https://www.example.com/drop",
+		MaxClicks:   int64Ptr(3),
+		FallbackURL: "https://www.example.com/sold-out",
+	}, "127.0.0.1", &owner)
+	require.NoError(t, err)
+	mapping, err := service.GetOriginalURL(created.ShortCode)
+	require.NoError(t, err)
+	require.True(t, mapping.IsClickLimited())
+	assert.Equal(t, "https://www.example.com/sold-out", mapping.FallbackURL)
+
+	visit := func(service *services.ShortenerService, userAgent string) error {
+		if err := service.AdmitClick(mapping, userAgent); err != nil {
+			return err
+		}
+		require.NoError(t, service.RecordClick(mapping, "203.0.113.7", userAgent, ""))
+		return nil
+	}
+
+	require.NoError(t, visit(service, browserUserAgent))
+	require.NoError(t, visit(service, browserUserAgent))
+	for i := 0; i < 3; i++ {
+		require.NoError(t, visit(service, crawlerUserAgent))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, service.Shutdown(ctx))
+
+	// Once the cached counter is gone the cap resumes from the recorded human
+	// clicks, not the total including bots
+	restarted := services.NewShortenerService(store, storage.NewMemoryCache(), config)
+	require.NoError(t, visit(restarted, browserUserAgent))
+	assert.Equal(t, services.ErrClickLimitReached, restarted.AdmitClick(mapping, browserUserAgent))
+
+	// Bots don't get past an exhausted link either
+	assert.Equal(t, services.ErrClickLimitReached, restarted.AdmitClick(mapping, crawlerUserAgent))
+
+	// Raising the cap lets visitors through again
+	updated, err := restarted.UpdateUserURL(owner, created.ShortCode, &models.UpdateURLRequest{MaxClicks: int64Ptr(4)})
+	require.NoError(t, err)
+	require.NotNil(t, updated.MaxClicks)
+	mapping.MaxClicks = updated.MaxClicks
+	assert.NoError(t, restarted.AdmitClick(mapping, browserUserAgent))
+	assert.Equal(t, services.ErrClickLimitReached, restarted.AdmitClick(mapping, browserUserAgent))
+}
+
+func TestLinkSchedules(t *testing.T) {
+	forEachBackend(t, exerciseLinkSchedules)
+}
+
+// exerciseLinkSchedules checks that a link's start stays before its expiry
+// however the two are set
+func exerciseLinkSchedules(t *testing.T, store storage.Store, cache storage.Cache) {
+	utils.InitializeSnowflake(1)
+	service := services.NewShortenerService(store, cache, &configs.Config{BaseURL: "http://localhost:8080", ServerHost: "localhost"})
+
+	owner := int64(42)
+	createOwners(t, store, owner)
+	now := time.Now().Truncate(time.Second)
+	startsAt, expiresAt := now.Add(time.Hour), now.Add(2*time.Hour)
+
+	_, err := service.ShortenURL(&models.ShortenRequest{URL: "https://www.example.com/launch", StartsAt: &expiresAt, ExpiresAt: &startsAt}, "127.0.0.1", &owner)
+	assert.Equal(t, services.ErrInvalidSchedule, err)
+
+	created, err := service.ShortenURL(&models.ShortenRequest{URL: "https://www.example.com/launch", StartsAt: &startsAt, ExpiresAt: &expiresAt}, "127.0.0.1", &owner)
+	require.NoError(t, err)
+
+	// Scheduled links don't resolve before they start
+	_, err = service.GetOriginalURL(created.ShortCode)
+	assert.Equal(t, storage.ErrURLNotYetActive, err)
+
+	// Moving one end of the schedule is checked against the stored other end
+	early := now.Add(30 * time.Minute)
+	_, err = service.UpdateUserURL(owner, created.ShortCode, &models.UpdateURLRequest{ExpiresAt: &early})
+	assert.Equal(t, services.ErrInvalidSchedule, err)
+	late := now.Add(3 * time.Hour)
+	_, err = service.UpdateUserURL(owner, created.ShortCode, &models.UpdateURLRequest{StartsAt: &late})
+	assert.Equal(t, services.ErrInvalidSchedule, err)
+
+	stored, err := service.GetURLByShortCode(owner, created.ShortCode)
+	require.NoError(t, err)
+	require.NotNil(t, stored.ExpiresAt)
+	assert.True(t, stored.ExpiresAt.Equal(expiresAt))
+
+	// Both ends can move together
+	_, err = service.UpdateUserURL(owner, created.ShortCode, &models.UpdateURLRequest{StartsAt: &late, ExpiresAt: &late})
+	assert.Equal(t, services.ErrInvalidSchedule, err)
+	later := now.Add(4 * time.Hour)
+	updated, err := service.UpdateUserURL(owner, created.ShortCode, &models.UpdateURLRequest{StartsAt: &late, ExpiresAt: &later})
+	require.NoError(t, err)
+	require.NotNil(t, updated.StartsAt)
+	assert.True(t, updated.StartsAt.Equal(late))
+
+	// Starting now makes the link resolve
+	_, err = service.UpdateUserURL(owner, created.ShortCode, &models.UpdateURLRequest{StartsAt: &now})
+	require.NoError(t, err)
+	mapping, err := service.GetOriginalURL(created.ShortCode)
+	require.NoError(t, err)
+	assert.Equal(t, "https://www.example.com/launch", mapping.OriginalURL)
+
+	// An explicit null removes the start, a missing one leaves it alone
+	_, err = service.UpdateUserURL(owner, created.ShortCode, &models.UpdateURLRequest{StartsAt: &late})
+	require.NoError(t, err)
+	_, err = service.GetOriginalURL(created.ShortCode)
+	assert.Equal(t, storage.ErrURLNotYetActive, err)
+
+	var untouched, cleared models.UpdateURLRequest
+	require.NoError(t, json.Unmarshal([]byte(`{"title": "Launch"}`), &untouched))
+	assert.Nil(t, untouched.StartsAt)
+	require.NoError(t, json.Unmarshal([]byte(`{"starts_at": null}`), &cleared))
+	updated, err = service.UpdateUserURL(owner, created.ShortCode, &cleared)
+	require.NoError(t, err)
+	assert.Nil(t, updated.StartsAt)
+	require.NotNil(t, updated.ExpiresAt)
+	assert.True(t, updated.ExpiresAt.Equal(later))
+	_, err = service.GetOriginalURL(created.ShortCode)
+	assert.NoError(t, err)
+}
+
+func int64Ptr(n int64) *int64 {
+	return &n
+}
@@ -0,0 +1,157 @@
+package functional
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/URLshorter/url-shortener/configs"
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/URLshorter/url-shortener/internal/services"
+	"github.com/URLshorter/url-shortener/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const previewPage = `<!DOCTYPE html>
+<html><head>
+<meta charset="utf-8">
+<title>  Spring   Launch | Example </title>
+<meta name="description" content="Plain description">
+<meta property="og:title" content="Spring Launch">
+<meta property="og:image" content="/images/card.png">
+<meta property="og:site_name" content="Example">
+<meta name="twitter:description" content="Everything new this spring">
+<link rel="shortcut icon" href="/static/icon.ico">
+</head><body><title>not this one</title></body></html>`
+
+// newPreviewServer serves a page with preview metadata, a redirect loop, a
+// page too large to read in full and a non-HTML file
+func newPreviewServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, previewPage)
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/page", http.StatusFound)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<html><head><!-- %s --><title>Too far</title></head></html>", strings.Repeat("x", 4096))
+	})
+	mux.HandleFunc("/file.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		fmt.Fprint(w, "%PDF-1.4")
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestMetadataFetcher_Fetch(t *testing.T) {
+	server := newPreviewServer()
+	defer server.Close()
+
+	fetcher := services.NewMetadataFetcher(services.MetadataFetcherConfig{
+		MaxBodyBytes:         1024,
+		MaxRedirects:         2,
+		AllowPrivateNetworks: true,
+	})
+	ctx := context.Background()
+
+	metadata, err := fetcher.Fetch(ctx, server.URL+"/moved")
+	require.NoError(t, err)
+	assert.Equal(t, &models.LinkMetadata{
+		Title:       "Spring Launch",
+		Description: "Everything new this spring",
+		ImageURL:    server.URL + "/images/card.png",
+		FaviconURL:  server.URL + "/static/icon.ico",
+		SiteName:    "Example",
+	}, metadata)
+
+	// Only the first MaxBodyBytes are read
+	metadata, err = fetcher.Fetch(ctx, server.URL+"/large")
+	require.NoError(t, err)
+	assert.Empty(t, metadata.Title)
+	assert.Equal(t, server.URL+"/favicon.ico", metadata.FaviconURL)
+
+	_, err = fetcher.Fetch(ctx, server.URL+"/loop")
+	assert.True(t, errors.Is(err, services.ErrMetadataTooManyRedirects))
+	_, err = fetcher.Fetch(ctx, server.URL+"/file.pdf")
+	assert.Equal(t, services.ErrMetadataNotHTML, err)
+	_, err = fetcher.Fetch(ctx, "ftp://example.com/page")
+	assert.Equal(t, services.ErrMetadataUnsupportedScheme, err)
+
+	// The default fetcher refuses loopback and private addresses, including
+	// ones reached by a redirect
+	strict := services.NewMetadataFetcher(services.DefaultMetadataFetcherConfig())
+	for _, target := range []string{server.URL + "/page", "http://10.0.0.1/", "http://[::1]/", "http://169.254.169.254/latest/meta-data"} {
+		_, err = strict.Fetch(ctx, target)
+		assert.True(t, errors.Is(err, services.ErrMetadataBlockedAddress), target)
+	}
+}
+
+func TestLinkMetadata(t *testing.T) {
+	forEachBackend(t, exerciseLinkMetadata)
+}
+
+// exerciseLinkMetadata fetches previews for links and checks what is stored
+func exerciseLinkMetadata(t *testing.T, store storage.Store, cache storage.Cache) {
+	server := newPreviewServer()
+	defer server.Close()
+
+	service := services.NewShortenerService(store, cache, &configs.Config{BaseURL: "http://localhost:8080", ServerHost: "localhost"})
+	defer service.Shutdown(context.Background())
+
+	owner := int64(21)
+	createOwners(t, store, owner)
+	plain, err := service.ShortenURL(&models.ShortenRequest{URL: server.URL + "/page"}, "", &owner)
+	require.NoError(t, err)
+	titled, err := service.ShortenURL(&models.ShortenRequest{URL: server.URL + "/page"}, "", &owner)
+	require.NoError(t, err)
+	title := "My own title"
+	_, err = service.UpdateUserURL(owner, titled.ShortCode, &models.UpdateURLRequest{Title: &title})
+	require.NoError(t, err)
+
+	fetcher := services.NewMetadataFetcher(services.MetadataFetcherConfig{AllowPrivateNetworks: true})
+	metadata := services.NewLinkMetadataService(store, fetcher, services.LinkMetadataConfig{})
+	assert.True(t, metadata.Enqueue(plain.ShortCode, plain.OriginalURL, 1))
+	assert.True(t, metadata.Enqueue(titled.ShortCode, titled.OriginalURL, 1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, metadata.Shutdown(ctx))
+	assert.False(t, metadata.Enqueue(plain.ShortCode, plain.OriginalURL, 1))
+
+	url, err := store.GetUserURL(plain.ShortCode)
+	require.NoError(t, err)
+	require.NotNil(t, url.Title)
+	assert.Equal(t, "Spring Launch", *url.Title)
+	assert.Equal(t, "Everything new this spring", *url.Description)
+	assert.Equal(t, server.URL+"/images/card.png", *url.ImageURL)
+	assert.Equal(t, server.URL+"/static/icon.ico", *url.FaviconURL)
+	assert.Equal(t, "Example", *url.SiteName)
+	assert.NotNil(t, url.MetadataFetchedAt)
+
+	// Titles set by the owner are kept
+	url, err = store.GetUserURL(titled.ShortCode)
+	require.NoError(t, err)
+	assert.Equal(t, title, *url.Title)
+	assert.Equal(t, "Example", *url.SiteName)
+
+	// Metadata for a destination that has since been replaced is discarded
+	newURL := server.URL + "/file.pdf"
+	_, err = service.UpdateUserURL(owner, plain.ShortCode, &models.UpdateURLRequest{OriginalURL: &newURL})
+	require.NoError(t, err)
+	require.NoError(t, store.SaveLinkMetadata(plain.ShortCode, 1, &models.LinkMetadata{SiteName: "Stale"}))
+	url, err = store.GetUserURL(plain.ShortCode)
+	require.NoError(t, err)
+	assert.Equal(t, "Example", *url.SiteName)
+}
@@ -0,0 +1,114 @@
+package functional
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/URLshorter/url-shortener/configs"
+	"github.com/URLshorter/url-shortener/internal/handlers"
+	"github.com/URLshorter/url-shortener/internal/middleware"
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/URLshorter/url-shortener/internal/services"
+	"github.com/URLshorter/url-shortener/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinkPasswordService(t *testing.T) {
+	linkPasswords := services.NewLinkPasswordService(nil, "test-secret")
+
+	_, err := linkPasswords.HashPassword("abc")
+	assert.Equal(t, services.ErrInvalidLinkPassword, err)
+
+	hash, err := linkPasswords.HashPassword("prerelease")
+	require.NoError(t, err)
+	mapping := &models.URLMapping{ShortCode: "launch", PasswordHash: hash}
+
+	_, _, err = linkPasswords.Unlock(mapping, "wrong", "203.0.113.7")
+	assert.Equal(t, services.ErrIncorrectLinkPassword, err)
+
+	token, _, err := linkPasswords.Unlock(mapping, "prerelease", "203.0.113.7")
+	require.NoError(t, err)
+	assert.True(t, linkPasswords.HasAccess(mapping, token))
+	assert.False(t, linkPasswords.HasAccess(mapping, token+"0"))
+	assert.False(t, linkPasswords.HasAccess(&models.URLMapping{ShortCode: "other", PasswordHash: hash}, token))
+
+	// Changing the password invalidates previously issued tokens
+	newHash, err := linkPasswords.HashPassword("launch-day")
+	require.NoError(t, err)
+	assert.False(t, linkPasswords.HasAccess(&models.URLMapping{ShortCode: "launch", PasswordHash: newHash}, token))
+
+	// Visitors are locked out even without a shared cache to count failures in
+	for i := 1; i < 5; i++ {
+		_, _, err = linkPasswords.Unlock(mapping, "wrong", "203.0.113.8")
+		assert.Equal(t, services.ErrIncorrectLinkPassword, err)
+	}
+	_, _, err = linkPasswords.Unlock(mapping, "wrong", "203.0.113.8")
+	assert.Equal(t, services.ErrLinkPasswordLocked, err)
+	_, _, err = linkPasswords.Unlock(mapping, "prerelease", "203.0.113.8")
+	assert.Equal(t, services.ErrLinkPasswordLocked, err)
+	_, _, err = linkPasswords.Unlock(mapping, "prerelease", "203.0.113.7")
+	assert.NoError(t, err)
+
+	// Without a secret, cookies could be forged, so none are issued or accepted
+	unconfigured := services.NewLinkPasswordService(nil, "")
+	_, _, err = unconfigured.Unlock(mapping, "prerelease", "203.0.113.7")
+	assert.Equal(t, services.ErrLinkPasswordUnavailable, err)
+	assert.False(t, unconfigured.HasAccess(mapping, token))
+	_, err = unconfigured.HashPassword("prerelease")
+	assert.Equal(t, services.ErrLinkPasswordUnavailable, err)
+}
+
+func TestUnlockURL_LockoutIgnoresForgedForwardedFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewMemoryStorage()
+	shortener := services.NewShortenerService(store, storage.NewMemoryCache(), &configs.Config{
+		BaseURL: "http://localhost:8080", ServerHost: "localhost", LinkPasswordSecret: "test-secret",
+	})
+	created, err := shortener.ShortenURL(&models.ShortenRequest{URL: "https://www.example.com/launch", Password: "prerelease"}, "127.0.0.1", nil)
+	require.NoError(t, err)
+
+	handler := handlers.NewHandler(shortener, services.NewAnalyticsService(store), nil, nil, nil, nil, nil, nil, nil, nil)
+	router := gin.New()
+	require.NoError(t, middleware.TrustProxies(router, ""))
+	router.POST("/:shortCode", handler.UnlockURL)
+
+	unlock := func(password, forwardedFor string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/"+created.ShortCode, strings.NewReader(url.Values{"password": {password}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		req.RemoteAddr = "198.51.100.1:4000"
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Rotating the forwarded address doesn't earn more guesses
+	for i := 1; i < 5; i++ {
+		assert.Equal(t, http.StatusUnauthorized, unlock("wrong", fmt.Sprintf("1.2.3.%d", i)))
+	}
+	assert.Equal(t, http.StatusTooManyRequests, unlock("wrong", "1.2.3.9"))
+	assert.Equal(t, http.StatusTooManyRequests, unlock("prerelease", "1.2.4.1"))
+}
+
+func TestURLMapping_PasswordHashStaysOutOfJSON(t *testing.T) {
+	mapping := &models.URLMapping{ShortCode: "launch", OriginalURL: "https://www.example.com", PasswordHash: "$2a$12$hash"}
+
+	data, err := json.Marshal(mapping)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "$2a$12$hash")
+
+	// Caches keep the hash so redirects can still challenge visitors
+	cache := storage.NewMemoryCache()
+	require.NoError(t, cache.SetURLMapping("launch", mapping, time.Minute))
+	cached, err := cache.GetURLMapping("launch")
+	require.NoError(t, err)
+	assert.Equal(t, mapping, cached)
+}
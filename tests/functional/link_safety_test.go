@@ -0,0 +1,160 @@
+package functional
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/URLshorter/url-shortener/configs"
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/URLshorter/url-shortener/internal/services"
+	"github.com/URLshorter/url-shortener/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubChecker is a reputation feed that flags the URLs it is given
+type stubChecker struct {
+	mu        sync.Mutex
+	malicious map[string]bool
+}
+
+func (c *stubChecker) flag(rawURL string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.malicious[rawURL] = true
+}
+
+func (c *stubChecker) CheckURL(ctx context.Context, rawURL string) (*models.SafetyVerdict, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.malicious[rawURL] {
+		return &models.SafetyVerdict{Malicious: true, Source: "stub", Reason: "known phishing page"}, nil
+	}
+	return &models.SafetyVerdict{}, nil
+}
+
+func TestSafeBrowsingChecker(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("key") != "test-key" {
+			http.Error(w, `{"error": {"code": 403}}`, http.StatusForbidden)
+			return
+		}
+
+		var lookup struct {
+			ThreatInfo struct {
+				ThreatEntries []struct {
+					URL string `json:"url"`
+				} `json:"threatEntries"`
+			} `json:"threatInfo"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&lookup))
+		if lookup.ThreatInfo.ThreatEntries[0].URL == "http://malware.testing.google.test/testing/malware/" {
+			w.Write([]byte(`{"matches": [{"threatType": "MALWARE", "platformType": "ANY_PLATFORM"}]}`))
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	checker := services.NewSafeBrowsingChecker("test-key", server.URL)
+	verdict, err := checker.CheckURL(context.Background(), "http://malware.testing.google.test/testing/malware/")
+	require.NoError(t, err)
+	assert.Equal(t, &models.SafetyVerdict{Malicious: true, Source: "safe_browsing", Reason: "flagged as malware by Google Safe Browsing"}, verdict)
+
+	verdict, err = checker.CheckURL(context.Background(), "https://www.example.com/")
+	require.NoError(t, err)
+	assert.False(t, verdict.Malicious)
+
+	// Feed errors are reported to the caller, which lets the URL through
+	_, err = services.NewSafeBrowsingChecker("wrong-key", server.URL).CheckURL(context.Background(), "https://www.example.com/")
+	assert.Error(t, err)
+}
+
+func TestLinkSafety(t *testing.T) {
+	forEachBackend(t, exerciseLinkSafety)
+}
+
+// exerciseLinkSafety blocks destinations, then quarantines and releases a link
+// whose destination a reputation feed starts flagging
+func exerciseLinkSafety(t *testing.T, store storage.Store, cache storage.Cache) {
+	service := services.NewShortenerService(store, cache, &configs.Config{BaseURL: "http://localhost:8080", ServerHost: "localhost"})
+	defer service.Shutdown(context.Background())
+
+	feed := &stubChecker{malicious: make(map[string]bool)}
+	safety := services.NewLinkSafetyService(store, cache, services.LinkSafetyConfig{RecheckInterval: time.Hour})
+	safety.AddChecker(feed)
+	service.SetLinkSafetyService(safety)
+
+	domain := &models.BlocklistEntry{Kind: "domain", Pattern: "*.Evil.example.", Reason: "phishing"}
+	require.NoError(t, safety.AddBlocklistEntry(domain))
+	assert.Equal(t, "evil.example", domain.Pattern)
+	require.NoError(t, safety.AddBlocklistEntry(&models.BlocklistEntry{Kind: "url", Pattern: "https://files.example.com/*.exe"}))
+	assert.Equal(t, storage.ErrBlocklistEntryExists, safety.AddBlocklistEntry(&models.BlocklistEntry{Kind: "domain", Pattern: "https://evil.example/login"}))
+	assert.Equal(t, services.ErrInvalidBlocklistEntry, safety.AddBlocklistEntry(&models.BlocklistEntry{Kind: "ip", Pattern: "10.0.0.1"}))
+	assert.Equal(t, services.ErrInvalidBlocklistEntry, safety.AddBlocklistEntry(&models.BlocklistEntry{Kind: "url", Pattern: "**"}))
+
+	owner := int64(41)
+	createOwners(t, store, owner)
+	for _, request := range []*models.ShortenRequest{
+		{URL: "https://login.evil.example/account"},
+		{URL: "https://files.example.com/tools/setup.EXE"},
+		{URL: "https://www.example.com/", FallbackURL: "https://evil.example/"},
+	} {
+		_, err := service.ShortenURL(request, "", &owner)
+		assert.Equal(t, services.ErrUnsafeDestination, err, request.URL)
+	}
+	for _, destination := range []string{"https://notevil.example/", "https://files.example.com/readme.txt"} {
+		_, err := service.ShortenURL(&models.ShortenRequest{URL: destination}, "", &owner)
+		assert.NoError(t, err, destination)
+	}
+
+	link, err := service.ShortenURL(&models.ShortenRequest{URL: "https://www.example.com/offer"}, "", &owner)
+	require.NoError(t, err)
+	blocked := "https://sub.evil.example/"
+	_, err = service.UpdateUserURL(owner, link.ShortCode, &models.UpdateURLRequest{OriginalURL: &blocked})
+	assert.Equal(t, services.ErrUnsafeDestination, err)
+
+	// The feed starts flagging the destination; the next pass quarantines the link
+	feed.flag("https://www.example.com/offer")
+	_, err = service.GetOriginalURL(link.ShortCode) // cached before the quarantine
+	require.NoError(t, err)
+
+	result, err := safety.RecheckLinks(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.Checked)
+	assert.Equal(t, []string{link.ShortCode}, result.Quarantined)
+
+	mapping, err := service.GetOriginalURL(link.ShortCode)
+	require.NoError(t, err)
+	assert.True(t, mapping.IsQuarantined())
+	assert.Equal(t, "known phishing page", mapping.QuarantineReason)
+	url, err := store.GetUserURL(link.ShortCode)
+	require.NoError(t, err)
+	assert.NotNil(t, url.QuarantinedAt)
+
+	// Links screened within the re-check interval are skipped
+	result, err = safety.RecheckLinks(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, result.Checked)
+
+	require.NoError(t, safety.ReleaseURL(link.ShortCode))
+	mapping, err = service.GetOriginalURL(link.ShortCode)
+	require.NoError(t, err)
+	assert.False(t, mapping.IsQuarantined())
+	assert.Equal(t, storage.ErrURLNotFound, safety.ReleaseURL("missing"))
+
+	require.NoError(t, safety.RemoveBlocklistEntry(domain.ID))
+	assert.Equal(t, storage.ErrBlocklistEntryNotFound, safety.RemoveBlocklistEntry(domain.ID))
+	_, err = service.ShortenURL(&models.ShortenRequest{URL: "https://login.evil.example/account"}, "", &owner)
+	assert.NoError(t, err)
+
+	entries, err := safety.ListBlocklist()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, models.BlocklistKindURL, entries[0].Kind)
+}
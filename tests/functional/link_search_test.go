@@ -0,0 +1,92 @@
+package functional
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/URLshorter/url-shortener/configs"
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/URLshorter/url-shortener/internal/services"
+	"github.com/URLshorter/url-shortener/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchUserURLs(t *testing.T) {
+	forEachBackend(t, exerciseLinkSearch)
+}
+
+// exerciseLinkSearch tags a few links and searches them against a storage backend
+func exerciseLinkSearch(t *testing.T, store storage.Store, cache storage.Cache) {
+	service := services.NewShortenerService(store, cache, &configs.Config{BaseURL: "http://localhost:8080", ServerHost: "localhost"})
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		require.NoError(t, service.Shutdown(ctx))
+	}()
+
+	owner, other := int64(11), int64(12)
+	createOwners(t, store, owner, other)
+	past := time.Now().Add(-time.Hour)
+	shorten := func(userID int64, url string, tags []string, expiresAt *time.Time) string {
+		created, err := service.ShortenURL(&models.ShortenRequest{URL: url, Tags: tags, ExpiresAt: expiresAt}, "", &userID)
+		require.NoError(t, err)
+		return created.ShortCode
+	}
+	docs := shorten(owner, "https://docs.example.com/guide", []string{"Docs", "launch"}, nil)
+	blog := shorten(owner, "https://example.com/blog/spring-launch", []string{"launch"}, nil)
+	old := shorten(owner, "https://www.other.org/archive", nil, &past)
+	shorten(other, "https://docs.example.com/guide", []string{"launch"}, nil)
+
+	title := "Release notes"
+	updated, err := service.UpdateUserURL(owner, old, &models.UpdateURLRequest{Title: &title, Tags: &[]string{"Archive"}})
+	require.NoError(t, err)
+	assert.Equal(t, models.Tags{"archive"}, updated.Tags)
+
+	codes := func(req *models.URLSearchRequest) []string {
+		results, err := service.SearchUserURLs(owner, req)
+		require.NoError(t, err)
+		var shortCodes []string
+		for _, url := range results.URLs {
+			shortCodes = append(shortCodes, url.ShortCode)
+		}
+		return shortCodes
+	}
+
+	assert.Equal(t, []string{old, blog, docs}, codes(&models.URLSearchRequest{}))
+	assert.Equal(t, []string{blog, docs}, codes(&models.URLSearchRequest{Tags: []string{"LAUNCH"}}))
+	assert.Equal(t, []string{docs}, codes(&models.URLSearchRequest{Tags: []string{"launch", "docs"}}))
+	assert.Equal(t, []string{blog, docs}, codes(&models.URLSearchRequest{Domain: "example.com"}))
+	assert.Equal(t, []string{docs}, codes(&models.URLSearchRequest{Domain: "https://docs.example.com/"}))
+	assert.Equal(t, []string{old}, codes(&models.URLSearchRequest{Query: "release"}))
+	assert.Equal(t, []string{blog}, codes(&models.URLSearchRequest{Query: "spring"}))
+	assert.Equal(t, []string{old}, codes(&models.URLSearchRequest{State: models.URLStateExpired}))
+	assert.Equal(t, []string{blog, docs}, codes(&models.URLSearchRequest{State: models.URLStateActive}))
+	assert.Empty(t, codes(&models.URLSearchRequest{CreatedTo: time.Now().AddDate(0, 0, -2).Format("2006-01-02")}))
+
+	// Keyset pages follow each other without gaps or repeats
+	first, err := service.SearchUserURLs(owner, &models.URLSearchRequest{Sort: models.URLSortCreatedAt, Order: "asc", Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, first.URLs, 2)
+	require.NotEmpty(t, first.NextCursor)
+	assert.Equal(t, "http://localhost:8080/"+docs, first.URLs[0].ShortURL)
+
+	second, err := service.SearchUserURLs(owner, &models.URLSearchRequest{Sort: models.URLSortCreatedAt, Order: "asc", Limit: 2, Cursor: first.NextCursor})
+	require.NoError(t, err)
+	require.Len(t, second.URLs, 1)
+	assert.Equal(t, old, second.URLs[0].ShortCode)
+	assert.Empty(t, second.NextCursor)
+
+	_, err = service.SearchUserURLs(owner, &models.URLSearchRequest{Sort: models.URLSortTitle, Cursor: first.NextCursor})
+	assert.Equal(t, services.ErrInvalidSearchCursor, err)
+	_, err = service.SearchUserURLs(owner, &models.URLSearchRequest{Domain: "exa mple.com"})
+	assert.Equal(t, services.ErrInvalidSearchDomain, err)
+
+	// LIKE wildcards in a domain reaching the store match only themselves
+	for _, domain := range []string{"ex_mple.com", "%.com", "%"} {
+		results, err := store.SearchUserURLs(owner, &storage.URLSearch{Domain: domain, Limit: 10})
+		require.NoError(t, err)
+		assert.Empty(t, results, domain)
+	}
+}
@@ -0,0 +1,136 @@
+package functional
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"testing/fstest"
+
+	"github.com/URLshorter/url-shortener/internal/migrations"
+	"github.com/URLshorter/url-shortener/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_add_column.up.sql":     {Data: []byte("ALTER TABLE t ADD COLUMN b INT;")},
+		"0001_create_table.up.sql":   {Data: []byte("CREATE TABLE t (a INT);")},
+		"0001_create_table.down.sql": {Data: []byte("DROP TABLE t;")},
+		"README.md":                  {Data: []byte("ignored")},
+	}
+
+	loaded, err := migrations.Load(fsys)
+	require.NoError(t, err)
+	require.Len(t, loaded, 2)
+
+	assert.Equal(t, int64(1), loaded[0].Version)
+	assert.Equal(t, "create_table", loaded[0].Name)
+	assert.Equal(t, "DROP TABLE t;", loaded[0].Down)
+	assert.Equal(t, int64(2), loaded[1].Version)
+	assert.Empty(t, loaded[1].Down, "a missing down script makes the migration irreversible")
+
+	// The checksum covers the up script, so editing it is detectable
+	fsys["0002_add_column.up.sql"] = &fstest.MapFile{Data: []byte("ALTER TABLE t ADD COLUMN c INT;")}
+	edited, err := migrations.Load(fsys)
+	require.NoError(t, err)
+	assert.Equal(t, loaded[0].Checksum, edited[0].Checksum)
+	assert.NotEqual(t, loaded[1].Checksum, edited[1].Checksum)
+}
+
+func TestLoadMigrations_Invalid(t *testing.T) {
+	_, err := migrations.Load(fstest.MapFS{"create_table.up.sql": {Data: []byte("SELECT 1;")}})
+	assert.Error(t, err, "file names need a version")
+
+	_, err = migrations.Load(fstest.MapFS{"0001_create_table.down.sql": {Data: []byte("SELECT 1;")}})
+	assert.Error(t, err, "every version needs an up script")
+
+	_, err = migrations.Load(fstest.MapFS{
+		"0001_one.up.sql": {Data: []byte("SELECT 1;")},
+		"0001_two.up.sql": {Data: []byte("SELECT 2;")},
+	})
+	assert.Error(t, err, "versions must be unique")
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	all, err := migrations.All()
+	require.NoError(t, err)
+	require.NotEmpty(t, all)
+
+	for i, m := range all {
+		assert.Equal(t, int64(i+1), m.Version, "versions are sequential")
+		assert.NotEmpty(t, m.Down, "%04d_%s has no down script", m.Version, m.Name)
+	}
+}
+
+func TestMigrationRunner(t *testing.T) {
+	db := newTestPostgres(t)
+
+	id, err := utils.GenerateID()
+	require.NoError(t, err)
+	table := fmt.Sprintf("migration_test_%d", id)
+
+	// Versions far beyond the embedded set keep this runner's bookkeeping
+	// apart from the real schema sharing the schema_migrations table
+	base := int64(900000000 + id%1000000)
+	fsys := fstest.MapFS{
+		fmt.Sprintf("%d_create.up.sql", base):     {Data: []byte("CREATE TABLE " + table + " (a INT);")},
+		fmt.Sprintf("%d_create.down.sql", base):   {Data: []byte("DROP TABLE " + table + ";")},
+		fmt.Sprintf("%d_column.up.sql", base+1):   {Data: []byte("ALTER TABLE " + table + " ADD COLUMN b INT;")},
+		fmt.Sprintf("%d_column.down.sql", base+1): {Data: []byte("ALTER TABLE " + table + " DROP COLUMN b;")},
+	}
+	loaded, err := migrations.Load(fsys)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	_, err = db.Exec(`DELETE FROM schema_migrations WHERE version >= $1`, base)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM schema_migrations WHERE version >= $1`, base)
+		db.Exec(`DROP TABLE IF EXISTS ` + table)
+	})
+
+	// Only the test migrations are known, so the embedded ones look unknown;
+	// the runner refuses to touch a database it does not fully recognise
+	runner := migrations.NewRunnerWithMigrations(db.DB(), loaded)
+	_, err = runner.Up(ctx, 0)
+	assert.ErrorIs(t, err, migrations.ErrUnknownVersion)
+
+	all, err := migrations.All()
+	require.NoError(t, err)
+	withEmbedded := func(extra []migrations.Migration) []migrations.Migration {
+		return append(append([]migrations.Migration(nil), all...), extra...)
+	}
+	runner = migrations.NewRunnerWithMigrations(db.DB(), withEmbedded(loaded))
+
+	applied, err := runner.Up(ctx, base)
+	require.NoError(t, err)
+	require.Len(t, applied, 1, "up stops at the target version")
+
+	applied, err = runner.Up(ctx, 0)
+	require.NoError(t, err)
+	require.Len(t, applied, 1)
+	_, err = db.Exec(`INSERT INTO ` + table + ` (a, b) VALUES (1, 2)`)
+	require.NoError(t, err)
+
+	// Editing an applied migration is detected and blocks further runs
+	edited := append([]migrations.Migration(nil), loaded...)
+	edited[1].Checksum = "edited"
+	drifted := migrations.NewRunnerWithMigrations(db.DB(), withEmbedded(edited))
+	assert.ErrorIs(t, drifted.Verify(ctx), migrations.ErrChecksumMismatch)
+	_, err = drifted.Up(ctx, 0)
+	assert.ErrorIs(t, err, migrations.ErrChecksumMismatch)
+
+	statuses, err := drifted.Status(ctx)
+	require.NoError(t, err)
+	assert.True(t, statuses[len(statuses)-1].Drifted())
+
+	rolledBack, err := runner.Down(ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, rolledBack, 2)
+	assert.Equal(t, base+1, rolledBack[0].Version, "down rolls back newest first")
+
+	var exists bool
+	require.NoError(t, db.QueryRow(`SELECT to_regclass($1) IS NOT NULL`, table).Scan(&exists))
+	assert.False(t, exists)
+}
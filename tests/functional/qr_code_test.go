@@ -0,0 +1,126 @@
+package functional
+
+import (
+	"bytes"
+	"context"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/URLshorter/url-shortener/configs"
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/URLshorter/url-shortener/internal/services"
+	"github.com/URLshorter/url-shortener/internal/storage"
+	"github.com/URLshorter/url-shortener/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeQR(t *testing.T) {
+	code, err := utils.EncodeQR("https://x.co/ab", utils.QRLevelLow)
+	require.NoError(t, err)
+	assert.Equal(t, 1, code.Version)
+	assert.Equal(t, 21, code.Size)
+
+	// Finder pattern corners and the always-dark module
+	assert.True(t, code.Dark(0, 0))
+	assert.True(t, code.Dark(20, 0))
+	assert.True(t, code.Dark(0, 20))
+	assert.False(t, code.Dark(7, 7))
+	assert.True(t, code.Dark(8, 13))
+
+	// Higher levels need a larger symbol for the same text
+	high, err := utils.EncodeQR("https://x.co/ab", utils.QRLevelHigh)
+	require.NoError(t, err)
+	assert.Greater(t, high.Version, code.Version)
+
+	_, err = utils.EncodeQR(strings.Repeat("x", 3000), utils.QRLevelLow)
+	assert.Equal(t, utils.ErrQRDataTooLong, err)
+}
+
+func TestQRCodeService_Render(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	config := &configs.Config{BaseURL: "http://localhost:8080", ServerHost: "localhost"}
+	shortener := services.NewShortenerService(store, storage.NewMemoryCache(), config)
+	qr := services.NewQRCodeService(shortener, config)
+
+	owner := int64(5)
+	created, err := shortener.ShortenURL(&models.ShortenRequest{URL: "https://www.example.com/poster"}, "", &owner)
+	require.NoError(t, err)
+
+	data, contentType, err := qr.Render(owner, created.ShortCode, &models.QRCodeRequest{Size: 300, Foreground: "#123456"})
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", contentType)
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, 300, img.Bounds().Dx())
+	assert.Equal(t, color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}, color.NRGBAModel.Convert(img.At(0, 0)))
+
+	// The top-left finder pattern starts where the quiet zone ends
+	corner := 0
+	for corner < 300 && color.NRGBAModel.Convert(img.At(corner, corner)) != (color.NRGBA{R: 0x12, G: 0x34, B: 0x56, A: 0xff}) {
+		corner++
+	}
+	assert.Greater(t, corner, 0)
+	assert.Less(t, corner, 60)
+
+	margin := 0
+	data, contentType, err = qr.Render(owner, created.ShortCode, &models.QRCodeRequest{Format: "svg", Margin: &margin, Level: "h", Background: "fe0"})
+	require.NoError(t, err)
+	assert.Equal(t, "image/svg+xml", contentType)
+	assert.Contains(t, string(data), `fill="#ffee00"`)
+	assert.Contains(t, string(data), `d="M0 0h7v1h-7z`)
+
+	cases := map[*models.QRCodeRequest]error{
+		{Format: "gif"}:                          services.ErrInvalidQRFormat,
+		{Size: 10}:                               services.ErrInvalidQRSize,
+		{Level: "X"}:                             services.ErrInvalidQRLevel,
+		{Foreground: "#zzzzzz"}:                  services.ErrInvalidQRColor,
+		{Foreground: "#ffffff", Background: "0"}: services.ErrInvalidQRColor,
+		{Foreground: "#ffffff"}:                  services.ErrQRLowContrast,
+		{LogoID: 3, Level: "L"}:                  services.ErrQRLogoNeedsHighLevel,
+		{LogoID: 3}:                              services.ErrQRLogoUnavailable,
+		{Domain: "go.example.com"}:               services.ErrQRDomainNotVerified,
+	}
+	for req, expected := range cases {
+		_, _, err := qr.Render(owner, created.ShortCode, req)
+		assert.Equal(t, expected, err)
+	}
+
+	_, _, err = qr.Render(owner+1, created.ShortCode, &models.QRCodeRequest{})
+	assert.Equal(t, storage.ErrUnauthorized, err)
+}
+
+func TestQRCodeScans(t *testing.T) {
+	forEachBackend(t, exerciseQRCodeScans)
+}
+
+// exerciseQRCodeScans checks scans are counted as clicks and reported separately
+func exerciseQRCodeScans(t *testing.T, store storage.Store, cache storage.Cache) {
+	service := services.NewShortenerService(store, cache, &configs.Config{
+		BaseURL:              "http://localhost:8080",
+		ServerHost:           "localhost",
+		ClickFlushIntervalMs: 10,
+	})
+
+	owner := int64(6)
+	createOwners(t, store, owner)
+	created, err := service.ShortenURL(&models.ShortenRequest{URL: "https://www.example.com/menu"}, "", &owner)
+	require.NoError(t, err)
+	mapping, err := service.GetOriginalURL(created.ShortCode)
+	require.NoError(t, err)
+
+	require.NoError(t, service.RecordClick(mapping, "203.0.113.7", browserUserAgent, ""))
+	require.NoError(t, service.RecordScan(mapping, "203.0.113.8", browserUserAgent, ""))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, service.Shutdown(ctx))
+
+	analytics, err := services.NewAnalyticsService(store).GetAnalytics(created.ShortCode, 30, false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), analytics.TotalClicks)
+	assert.Equal(t, int64(1), analytics.TotalScans)
+}
@@ -0,0 +1,120 @@
+package functional
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/URLshorter/url-shortener/internal/middleware"
+	"github.com/URLshorter/url-shortener/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rateLimitedRouter serves /ping behind limiter, setting whatever auth
+// context the test asks for first
+func rateLimitedRouter(limiter *middleware.RateLimiter, config middleware.RateLimitConfig, auth map[string]interface{}) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/ping", func(c *gin.Context) {
+		for key, value := range auth {
+			c.Set(key, value)
+		}
+	}, limiter.Limit(config), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+func ping(router *gin.Engine) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+	req.RemoteAddr = "203.0.113.7:4000"
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimiter_PerIP(t *testing.T) {
+	limiter := middleware.NewRateLimiter(storage.NewMemoryCache())
+	config := middleware.RateLimitConfig{Name: "test", RequestsPerSecond: 0.01, Burst: 2}
+	router := rateLimitedRouter(limiter, config, nil)
+
+	w := ping(router)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Remaining"))
+	assert.NotEmpty(t, w.Header().Get("X-RateLimit-Reset"))
+
+	assert.Equal(t, http.StatusOK, ping(router).Code)
+	w = ping(router)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "100", w.Header().Get("Retry-After"))
+
+	// Another policy counts the same IP in its own bucket
+	other := rateLimitedRouter(limiter, middleware.RateLimitConfig{Name: "other", RequestsPerSecond: 0.01, Burst: 2}, nil)
+	assert.Equal(t, http.StatusOK, ping(other).Code)
+}
+
+func TestRateLimiter_IgnoresForgedForwardedFor(t *testing.T) {
+	limiter := middleware.NewRateLimiter(storage.NewMemoryCache())
+	config := middleware.RateLimitConfig{Name: "test", RequestsPerSecond: 0.01, Burst: 1}
+	router := rateLimitedRouter(limiter, config, nil)
+	require.NoError(t, middleware.TrustProxies(router, " 10.0.0.2 , 10.1.0.0/16"))
+
+	pingFrom := func(remoteAddr, forwardedFor string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// A client connecting directly can't get a fresh bucket by forging the header
+	assert.Equal(t, http.StatusOK, pingFrom("203.0.113.7:4000", "1.2.3.4"))
+	assert.Equal(t, http.StatusTooManyRequests, pingFrom("203.0.113.7:4000", "1.2.3.5"))
+
+	// Behind a trusted proxy each forwarded client has its own bucket
+	assert.Equal(t, http.StatusOK, pingFrom("10.0.0.2:4000", "198.51.100.1"))
+	assert.Equal(t, http.StatusOK, pingFrom("10.1.3.4:4000", "198.51.100.2"))
+	assert.Equal(t, http.StatusTooManyRequests, pingFrom("10.0.0.2:4000", "198.51.100.2"))
+
+	// Without trusted proxies the header is ignored altogether
+	require.NoError(t, middleware.TrustProxies(router, ""))
+	assert.Equal(t, http.StatusOK, pingFrom("10.0.0.2:4000", "198.51.100.3"))
+	assert.Equal(t, http.StatusTooManyRequests, pingFrom("10.0.0.2:4000", "198.51.100.4"))
+
+	assert.Error(t, middleware.TrustProxies(router, "not-an-address"))
+}
+
+func TestRateLimiter_Quotas(t *testing.T) {
+	limiter := middleware.NewRateLimiter(storage.NewMemoryCache())
+	limiter.SetPlanLookup(func(userID int64) (string, error) {
+		if userID == 2 {
+			return "premium", nil
+		}
+		return "free", nil
+	})
+
+	// Signed-in users get their plan's hourly quota instead of the IP limit
+	w := ping(rateLimitedRouter(limiter, middleware.DefaultRateLimit, map[string]interface{}{"user_id": int64(1)}))
+	assert.Equal(t, "1000", w.Header().Get("X-RateLimit-Limit"))
+	w = ping(rateLimitedRouter(limiter, middleware.DefaultRateLimit, map[string]interface{}{"user_id": int64(2)}))
+	assert.Equal(t, "5000", w.Header().Get("X-RateLimit-Limit"))
+
+	// An API key's own limit applies, but never above its owner's plan
+	w = ping(rateLimitedRouter(limiter, middleware.DefaultRateLimit, map[string]interface{}{
+		"user_id": int64(2), "api_key_id": int64(9), "api_key_rate_limit": 100,
+	}))
+	assert.Equal(t, "100", w.Header().Get("X-RateLimit-Limit"))
+	w = ping(rateLimitedRouter(limiter, middleware.DefaultRateLimit, map[string]interface{}{
+		"user_id": int64(1), "api_key_id": int64(10), "api_key_rate_limit": 100000,
+	}))
+	assert.Equal(t, "1000", w.Header().Get("X-RateLimit-Limit"))
+
+	// Policies without quotas keep limiting signed-in users per IP
+	w = ping(rateLimitedRouter(limiter, middleware.AuthRateLimit, map[string]interface{}{"user_id": int64(1)}))
+	assert.Equal(t, "10", w.Header().Get("X-RateLimit-Limit"))
+}
@@ -0,0 +1,71 @@
+package functional
+
+import (
+	"testing"
+	"time"
+
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/URLshorter/url-shortener/internal/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAcceptLanguage(t *testing.T) {
+	langs := services.ParseAcceptLanguage("fr-CH, fr;q=0.9, en;q=0.8, de;q=0, *;q=0.5")
+	assert.Equal(t, []string{"fr-ch", "fr", "en"}, langs)
+	assert.Empty(t, services.ParseAcceptLanguage(""))
+}
+
+func TestRedirectRuleEngineResolve(t *testing.T) {
+	engine := services.NewRedirectRuleEngine(nil, services.NewUserAgentService())
+	rules := models.RedirectRules{
+		{Languages: []string{"de"}, Destination: "https://example.de"},
+		{Weekdays: []int{6, 0}, StartTime: "22:00", EndTime: "06:00", Destination: "https://example.com/night"},
+	}
+
+	// Saturday 23:30 UTC
+	saturdayNight := time.Date(2024, 6, 1, 23, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		ctx      *services.RedirectContext
+		expected string
+	}{
+		{"language prefix match", &services.RedirectContext{AcceptLanguage: "de-AT,en;q=0.5", Time: saturdayNight}, "https://example.de"},
+		{"schedule wraps midnight", &services.RedirectContext{AcceptLanguage: "en-US", Time: saturdayNight}, "https://example.com/night"},
+		{"falls back", &services.RedirectContext{AcceptLanguage: "en-US", Time: saturdayNight.Add(-12 * time.Hour)}, "https://example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, engine.Resolve(rules, "https://example.com", tt.ctx))
+		})
+	}
+}
+
+// countryLookup places every address in one country
+type countryLookup string
+
+func (c countryLookup) LookupIP(string) (*services.GeoIPResult, error) {
+	return &services.GeoIPResult{CountryCode: string(c)}, nil
+}
+
+func TestRedirectRuleEngineCountries(t *testing.T) {
+	rules := models.RedirectRules{{Countries: []string{"de", "AT"}, Destination: "https://example.de"}}
+	ctx := &services.RedirectContext{ClientIP: "203.0.113.7", Time: time.Now()}
+
+	engine := services.NewRedirectRuleEngine(countryLookup("DE"), services.NewUserAgentService())
+	assert.Equal(t, "https://example.de", engine.Resolve(rules, "https://example.com", ctx))
+	engine = services.NewRedirectRuleEngine(countryLookup("FR"), services.NewUserAgentService())
+	assert.Equal(t, "https://example.com", engine.Resolve(rules, "https://example.com", ctx))
+
+	// Without a database loaded no country matches, and nothing is looked up
+	// remotely
+	engine = services.NewRedirectRuleEngine(services.NewGeoIPService(), services.NewUserAgentService())
+	assert.Equal(t, "https://example.com", engine.Resolve(rules, "https://example.com", ctx))
+}
+
+func TestValidateRedirectRules(t *testing.T) {
+	assert.NoError(t, services.ValidateRedirectRules(models.RedirectRules{{Destination: "https://example.com", Timezone: "Europe/Berlin"}}))
+	assert.Equal(t, services.ErrInvalidRedirectRule, services.ValidateRedirectRules(models.RedirectRules{{Destination: "https://example.com", Weekdays: []int{7}}}))
+	assert.Equal(t, services.ErrInvalidRedirectRule, services.ValidateRedirectRules(models.RedirectRules{{Destination: "https://example.com", StartTime: "25:00"}}))
+}
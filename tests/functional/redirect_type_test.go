@@ -0,0 +1,42 @@
+package functional
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsValidRedirectType(t *testing.T) {
+	tests := []struct {
+		redirectType string
+		valid        bool
+	}{
+		{"301", true},
+		{"302", true},
+		{"307", true},
+		{"308", true},
+		{"meta_refresh", true},
+		{"", false},
+		{"303", false},
+		{"js", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.redirectType, func(t *testing.T) {
+			assert.Equal(t, tt.valid, models.IsValidRedirectType(tt.redirectType))
+		})
+	}
+}
+
+func TestRedirectStatusCode(t *testing.T) {
+	assert.Equal(t, http.StatusMovedPermanently, models.RedirectStatusCode(models.RedirectTypePermanent))
+	assert.Equal(t, http.StatusFound, models.RedirectStatusCode(models.RedirectTypeFound))
+	assert.Equal(t, http.StatusTemporaryRedirect, models.RedirectStatusCode(models.RedirectTypeTemporary))
+	assert.Equal(t, http.StatusPermanentRedirect, models.RedirectStatusCode(models.RedirectTypePermanentRedirect))
+	assert.Equal(t, http.StatusOK, models.RedirectStatusCode(models.RedirectTypeMetaRefresh))
+
+	// Links created before redirect types existed fall back to the default
+	assert.Equal(t, http.StatusFound, models.RedirectStatusCode(""))
+}
@@ -0,0 +1,93 @@
+package functional
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/URLshorter/url-shortener/configs"
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/URLshorter/url-shortener/internal/services"
+	"github.com/URLshorter/url-shortener/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const slackbotUserAgent = "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)"
+
+func TestNormalizeSocialPreview(t *testing.T) {
+	preview := &models.SocialPreview{OGTitle: "  Spring launch ", OGImage: "https://cdn.example.com/card.png"}
+	require.NoError(t, services.NormalizeSocialPreview(preview))
+	assert.Equal(t, "Spring launch", preview.OGTitle)
+	assert.Equal(t, models.TwitterCardSummaryLargeImage, preview.TwitterCard)
+
+	preview = &models.SocialPreview{OGDescription: "Text only"}
+	require.NoError(t, services.NormalizeSocialPreview(preview))
+	assert.Equal(t, models.TwitterCardSummary, preview.TwitterCard)
+
+	empty := &models.SocialPreview{}
+	require.NoError(t, services.NormalizeSocialPreview(empty))
+	assert.True(t, empty.IsEmpty())
+
+	cases := map[*models.SocialPreview]error{
+		{OGTitle: strings.Repeat("x", 201)}:        services.ErrSocialPreviewTooLong,
+		{OGImage: "javascript:alert(1)"}:           services.ErrInvalidSocialPreviewImage,
+		{OGImage: "/relative.png"}:                 services.ErrInvalidSocialPreviewImage,
+		{OGTitle: "Launch", TwitterCard: "player"}: services.ErrInvalidTwitterCard,
+	}
+	for preview, expected := range cases {
+		assert.Equal(t, expected, services.NormalizeSocialPreview(preview))
+	}
+}
+
+func TestSocialPreview(t *testing.T) {
+	forEachBackend(t, exerciseSocialPreview)
+}
+
+// exerciseSocialPreview stores a custom preview and checks who is served its card
+func exerciseSocialPreview(t *testing.T, store storage.Store, cache storage.Cache) {
+	service := services.NewShortenerService(store, cache, &configs.Config{BaseURL: "http://localhost:8080", ServerHost: "localhost"})
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		require.NoError(t, service.Shutdown(ctx))
+	}()
+
+	owner := int64(31)
+	createOwners(t, store, owner)
+	created, err := service.ShortenURL(&models.ShortenRequest{
+		URL:           "https://www.example.com/spring",
+		SocialPreview: &models.SocialPreview{OGTitle: "Spring launch", OGImage: "https://cdn.example.com/card.png"},
+	}, "", &owner)
+	require.NoError(t, err)
+	assert.Equal(t, models.TwitterCardSummaryLargeImage, created.SocialPreview.TwitterCard)
+
+	mapping, err := store.GetURLMappingByShortCode(created.ShortCode)
+	require.NoError(t, err)
+	require.NotNil(t, mapping.SocialPreview)
+
+	assert.Nil(t, service.PreviewCard(mapping, browserUserAgent))
+	for _, userAgent := range []string{slackbotUserAgent, "LinkedInBot/1.0 (compatible; Mozilla/5.0; Apache-HttpClient +http://www.linkedin.com)"} {
+		card := service.PreviewCard(mapping, userAgent)
+		require.NotNil(t, card, userAgent)
+		assert.Equal(t, &services.PreviewCard{
+			URL:         "http://localhost:8080/" + created.ShortCode,
+			Title:       "Spring launch",
+			Image:       "https://cdn.example.com/card.png",
+			TwitterCard: models.TwitterCardSummaryLargeImage,
+		}, card)
+	}
+
+	updated, err := service.UpdateUserURL(owner, created.ShortCode, &models.UpdateURLRequest{SocialPreview: &models.SocialPreview{OGDescription: "Now with text"}})
+	require.NoError(t, err)
+	assert.Equal(t, &models.SocialPreview{OGDescription: "Now with text", TwitterCard: models.TwitterCardSummary}, updated.SocialPreview)
+
+	// An empty preview removes it, and bots are redirected like everyone else
+	updated, err = service.UpdateUserURL(owner, created.ShortCode, &models.UpdateURLRequest{SocialPreview: &models.SocialPreview{}})
+	require.NoError(t, err)
+	assert.Nil(t, updated.SocialPreview)
+	mapping, err = store.GetURLMappingByShortCode(created.ShortCode)
+	require.NoError(t, err)
+	assert.Nil(t, service.PreviewCard(mapping, slackbotUserAgent))
+}
@@ -0,0 +1,187 @@
+package functional
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/URLshorter/url-shortener/configs"
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/URLshorter/url-shortener/internal/services"
+	"github.com/URLshorter/url-shortener/internal/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	browserUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36"
+	crawlerUserAgent = "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+)
+
+func TestShortenerService(t *testing.T) {
+	forEachBackend(t, exerciseLinkStore)
+}
+
+// exerciseLinkStore runs a link through its lifecycle against a storage backend
+func exerciseLinkStore(t *testing.T, store storage.Store, cache storage.Cache) {
+	service := services.NewShortenerService(store, cache, &configs.Config{
+		BaseURL:              "http://localhost:8080",
+		ServerHost:           "localhost",
+		ClickFlushIntervalMs: 10,
+	})
+	analytics := services.NewAnalyticsService(store)
+
+	owner := int64(42)
+	createOwners(t, store, owner)
+	created, err := service.ShortenURL(&models.ShortenRequest{URL: "https://www.example.com/launch"}, "127.0.0.1", &owner)
+	require.NoError(t, err)
+
+	mapping, err := service.GetOriginalURL(created.ShortCode)
+	require.NoError(t, err)
+	assert.Equal(t, "https://www.example.com/launch", mapping.OriginalURL)
+
+	require.NoError(t, service.RecordClick(mapping, "203.0.113.7", browserUserAgent, ""))
+	require.NoError(t, service.RecordClick(mapping, "203.0.113.8", crawlerUserAgent, ""))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, service.Shutdown(ctx))
+
+	// Bots are persisted but only counted when asked for
+	human, err := analytics.GetAnalytics(created.ShortCode, 30, false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), human.TotalClicks)
+	require.Len(t, human.DailyClicks, 1)
+	assert.Equal(t, time.Now().Format("2006-01-02"), human.DailyClicks[0].Date)
+
+	all, err := analytics.GetAnalytics(created.ShortCode, 30, true)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), all.TotalClicks)
+
+	stats, err := analytics.GetUserDashboardStats(owner, false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stats.TotalURLs)
+	assert.Equal(t, int64(1), stats.TodayClicks)
+
+	trends, err := analytics.GetClickTrends(created.ShortCode, "day", true)
+	require.NoError(t, err)
+	require.Len(t, trends, 1)
+	assert.Equal(t, int64(2), trends[0].Clicks)
+
+	title := "Launch"
+	updated, err := service.UpdateUserURL(owner, created.ShortCode, &models.UpdateURLRequest{Title: &title})
+	require.NoError(t, err)
+	require.NotNil(t, updated.Title)
+	assert.Equal(t, title, *updated.Title)
+
+	destination := "https://www.example.com/relaunch"
+	_, err = service.UpdateUserURL(owner, created.ShortCode, &models.UpdateURLRequest{OriginalURL: &destination})
+	require.NoError(t, err)
+	history, err := service.GetUserURLDestinationHistory(owner, created.ShortCode)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, "https://www.example.com/launch", history[0].OriginalURL)
+
+	urls, total, err := service.GetUserURLs(owner, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	require.Len(t, urls, 1)
+	assert.Equal(t, "http://localhost:8080/"+created.ShortCode, urls[0].ShortURL)
+
+	assert.Equal(t, storage.ErrUnauthorized, service.DeleteURL(owner+1, created.ShortCode))
+
+	// Operators can move and disable links regardless of owner; both drop
+	// the cached mapping so redirects see the change at once
+	newOwner := owner + 1
+	require.NoError(t, service.TransferURL(created.ShortCode, newOwner))
+	require.NoError(t, service.SetURLActive(created.ShortCode, false))
+	_, err = service.GetOriginalURL(created.ShortCode)
+	assert.Equal(t, storage.ErrURLNotFound, err)
+
+	disabled, err := service.LookupURL(created.ShortCode)
+	require.NoError(t, err)
+	assert.False(t, disabled.IsActive)
+	assert.Equal(t, "127.0.0.1", disabled.CreatedByIP)
+	require.NotNil(t, disabled.UserID)
+	assert.Equal(t, newOwner, *disabled.UserID)
+
+	require.NoError(t, service.SetURLActive(created.ShortCode, true))
+	assert.Equal(t, storage.ErrUnauthorized, service.DeleteURL(owner, created.ShortCode))
+	require.NoError(t, service.DeleteURL(newOwner, created.ShortCode))
+
+	_, err = service.GetOriginalURL(created.ShortCode)
+	assert.Equal(t, storage.ErrURLNotFound, err)
+	assert.Equal(t, storage.ErrURLNotFound, service.TransferURL("missing", owner))
+}
+
+func TestSQLiteStorage_Accounts(t *testing.T) {
+	store, err := storage.NewSQLiteStorage(&configs.Config{SQLitePath: filepath.Join(t.TempDir(), "accounts.db")})
+	require.NoError(t, err)
+	defer store.Close()
+
+	now := time.Now()
+	require.NoError(t, store.CreateUser(&models.User{
+		ID: 7, Name: "Ada", Email: "ada@example.com", Provider: "email",
+		AccountType: "free", IsActive: true, CreatedAt: now, UpdatedAt: now,
+	}))
+	require.NoError(t, store.UpdateUserEmailVerified(7, true))
+
+	user, err := store.GetUserByEmail("ada@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), user.ID)
+	assert.True(t, user.EmailVerified)
+	assert.Nil(t, user.LastLoginAt)
+
+	_, err = store.GetUserByEmail("missing@example.com")
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	session := &models.UserSession{ID: uuid.New(), UserID: 7, ExpiresAt: now.Add(time.Hour), CreatedAt: now}
+	require.NoError(t, store.CreateSession(session))
+	stored, err := store.GetSessionByID(session.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, session.ID, stored.ID)
+	assert.WithinDuration(t, session.ExpiresAt, stored.ExpiresAt, time.Millisecond)
+}
+
+func TestLRUCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	cache := storage.NewLRUCache(2)
+	require.NoError(t, cache.Set("a", 1, 0))
+	require.NoError(t, cache.Set("b", 2, 0))
+
+	// Reading a makes b the least recently used key
+	_, err := cache.Get("a")
+	require.NoError(t, err)
+	require.NoError(t, cache.Set("c", 3, 0))
+
+	assert.Equal(t, 2, cache.Len())
+	_, err = cache.Get("b")
+	assert.Equal(t, storage.ErrCacheKeyNotFound, err)
+	value, err := cache.Get("a")
+	require.NoError(t, err)
+	assert.Equal(t, "1", value)
+}
+
+func TestMemoryCache_Expiry(t *testing.T) {
+	cache := storage.NewMemoryCache()
+
+	count, err := cache.IncrementWithTTL("attempts", 20*time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	count, err = cache.IncrementWithTTL("attempts", 20*time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	time.Sleep(30 * time.Millisecond)
+	_, err = cache.Get("attempts")
+	assert.Equal(t, storage.ErrCacheKeyNotFound, err)
+
+	expired := time.Now().Add(-time.Minute)
+	require.NoError(t, cache.SetURLMapping("old", &models.URLMapping{ShortCode: "old", ExpiresAt: &expired}, time.Hour))
+	_, err = cache.GetURLMapping("old")
+	assert.Equal(t, storage.ErrURLExpired, err)
+	_, err = cache.GetURLMapping("old")
+	assert.Equal(t, storage.ErrCacheKeyNotFound, err)
+}
@@ -0,0 +1,134 @@
+package functional
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/URLshorter/url-shortener/internal/middleware"
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/URLshorter/url-shortener/internal/services"
+	"github.com/URLshorter/url-shortener/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestJWTService_Revocation(t *testing.T) {
+	jwtService := services.NewJWTService("secret", "test", time.Hour, 24*time.Hour)
+	jwtService.SetRevocationCache(storage.NewMemoryCache())
+	user := &models.User{ID: 7, Email: "user@example.com", AccountType: "free"}
+
+	claimsOf := func(token string) *services.Claims {
+		claims, err := jwtService.ValidateToken(token)
+		require.NoError(t, err)
+		return claims
+	}
+
+	laptop, err := jwtService.GenerateTokenPair(user, "laptop")
+	require.NoError(t, err)
+	phone, err := jwtService.GenerateTokenPair(user, "phone")
+	require.NoError(t, err)
+
+	// Denylisting a token leaves the user's other tokens alone
+	access := claimsOf(laptop.AccessToken)
+	require.NoError(t, jwtService.CheckRevoked(access))
+	require.NoError(t, jwtService.InvalidateToken(access.ID, access.ExpiresAt.Time))
+	assert.Equal(t, services.ErrRevokedToken, jwtService.CheckRevoked(access))
+	assert.NoError(t, jwtService.CheckRevoked(claimsOf(phone.AccessToken)))
+
+	// Refresh tokens rotate within their session and can only be used once
+	rotated, err := jwtService.RefreshToken(phone.RefreshToken, user)
+	require.NoError(t, err)
+	assert.Equal(t, "phone", claimsOf(rotated.AccessToken).SessionID)
+	assert.NotEqual(t, phone.RefreshToken, rotated.RefreshToken)
+
+	_, err = jwtService.RefreshToken(phone.RefreshToken, user)
+	assert.Equal(t, services.ErrRefreshTokenReused, err)
+
+	// Reuse revokes the whole session, including the tokens it rotated to
+	assert.Equal(t, services.ErrRevokedToken, jwtService.CheckRevoked(claimsOf(rotated.AccessToken)))
+	_, err = jwtService.RefreshToken(rotated.RefreshToken, user)
+	assert.Equal(t, services.ErrRevokedToken, err)
+	assert.NoError(t, jwtService.CheckRevoked(claimsOf(laptop.RefreshToken)))
+
+	// Logging out of all devices revokes everything issued before it
+	require.NoError(t, jwtService.RevokeAllTokens(user.ID))
+	assert.Equal(t, services.ErrRevokedToken, jwtService.CheckRevoked(claimsOf(laptop.RefreshToken)))
+
+	fresh, err := jwtService.GenerateTokenPair(user, "")
+	require.NoError(t, err)
+	assert.NotEmpty(t, claimsOf(fresh.AccessToken).SessionID)
+	assert.NoError(t, jwtService.CheckRevoked(claimsOf(fresh.AccessToken)))
+	assert.NoError(t, jwtService.CheckRevoked(claimsOf(fresh.RefreshToken)))
+}
+
+func TestRequireAuth_RejectsRevokedTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	jwtService := services.NewJWTService("secret", "test", time.Hour, 24*time.Hour)
+	jwtService.SetRevocationCache(storage.NewMemoryCache())
+
+	pair, err := jwtService.GenerateTokenPair(&models.User{ID: 7}, "")
+	require.NoError(t, err)
+	require.NoError(t, jwtService.RevokeAllTokens(7))
+
+	router := gin.New()
+	router.GET("/profile", middleware.NewAuthMiddleware(jwtService, nil, nil).RequireAuth(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/profile", nil)
+	req.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "token_revoked")
+}
+
+func TestUserService_RevokesTokensOnPasswordChange(t *testing.T) {
+	forEachBackend(t, exercisePasswordChangeRevocation)
+}
+
+// exercisePasswordChangeRevocation checks that every way of replacing a
+// password, and deactivating the account, logs the user out everywhere
+func exercisePasswordChangeRevocation(t *testing.T, store storage.Store, cache storage.Cache) {
+	jwtService := services.NewJWTService("secret", "test", time.Hour, 24*time.Hour)
+	jwtService.SetRevocationCache(cache)
+	userService := services.NewUserService(store, cache, jwtService, nil, nil, &services.Config{
+		BCryptCost:               bcrypt.MinCost,
+		PasswordResetExpiryHours: 2,
+	})
+	user := createTwoFactorUser(t, store)
+
+	// issued returns the claims of a token that is valid until the next change
+	issued := func() *services.Claims {
+		pair, err := jwtService.GenerateTokenPair(user, "")
+		require.NoError(t, err)
+		claims, err := jwtService.ValidateToken(pair.AccessToken)
+		require.NoError(t, err)
+		require.NoError(t, jwtService.CheckRevoked(claims))
+		return claims
+	}
+
+	claims := issued()
+	assert.Equal(t, services.ErrInvalidCredentials, userService.ChangePassword(user.ID, "wrong", "battery staple"))
+	assert.NoError(t, jwtService.CheckRevoked(claims))
+	require.NoError(t, userService.ChangePassword(user.ID, "correct horse", "battery staple"))
+	assert.Equal(t, services.ErrRevokedToken, jwtService.CheckRevoked(claims))
+
+	claims = issued()
+	require.NoError(t, userService.SetPassword(user.ID, "admin reset"))
+	assert.Equal(t, services.ErrRevokedToken, jwtService.CheckRevoked(claims))
+
+	claims = issued()
+	token, err := userService.CreatePasswordResetToken(user.Email)
+	require.NoError(t, err)
+	require.NoError(t, userService.ResetPassword(token, "forgot it"))
+	assert.Equal(t, services.ErrRevokedToken, jwtService.CheckRevoked(claims))
+
+	claims = issued()
+	require.NoError(t, userService.DeactivateUser(user.ID))
+	assert.Equal(t, services.ErrRevokedToken, jwtService.CheckRevoked(claims))
+}
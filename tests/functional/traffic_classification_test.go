@@ -0,0 +1,32 @@
+package functional
+
+import (
+	"testing"
+
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/URLshorter/url-shortener/internal/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyTraffic(t *testing.T) {
+	userAgents := services.NewUserAgentService()
+
+	tests := []struct {
+		name      string
+		userAgent string
+		expected  string
+	}{
+		{"desktop chrome", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36", models.TrafficClassHuman},
+		{"slack unfurl", "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)", models.TrafficClassPreview},
+		{"imessage preview", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_11_1) AppleWebKit/601.2.4 (KHTML, like Gecko) Version/9.0.1 Safari/601.2.4 facebookexternalhit/1.1 Facebot Twitterbot/1.0", models.TrafficClassPreview},
+		{"url scanner", "Mozilla/5.0 (compatible; urlscan.io)", models.TrafficClassScanner},
+		{"http client", "curl/8.4.0", models.TrafficClassBot},
+		{"empty", "", models.TrafficClassBot},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, userAgents.ClassifyTraffic(tt.userAgent))
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package functional
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/URLshorter/url-shortener/configs"
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/URLshorter/url-shortener/internal/services"
+	"github.com/URLshorter/url-shortener/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestTOTPCode_RFC6238(t *testing.T) {
+	// The SHA-1 test vectors of RFC 6238, truncated to six digits
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" // "12345678901234567890"
+	for at, want := range map[int64]string{59: "287082", 1111111109: "081804", 2000000000: "279037"} {
+		code, err := services.TOTPCode(secret, time.Unix(at, 0))
+		require.NoError(t, err)
+		assert.Equal(t, want, code)
+	}
+}
+
+func TestTwoFactorService(t *testing.T) {
+	forEachBackend(t, exerciseTwoFactor)
+}
+
+func exerciseTwoFactor(t *testing.T, store storage.Store, cache storage.Cache) {
+	user := createTwoFactorUser(t, store)
+	twoFactor := services.NewTwoFactorService(store, cache, "Shortener")
+
+	enrollment, err := twoFactor.BeginEnrollment(user)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(enrollment.URI, "otpauth://totp/Shortener:ada@example.com?"))
+	assert.Contains(t, enrollment.URI, "secret="+enrollment.Secret)
+
+	_, err = twoFactor.ConfirmEnrollment(user.ID, "000000")
+	assert.Equal(t, services.ErrInvalidTwoFactorCode, err)
+
+	now := time.Now()
+	code, err := services.TOTPCode(enrollment.Secret, now)
+	require.NoError(t, err)
+	recoveryCodes, err := twoFactor.ConfirmEnrollment(user.ID, code)
+	require.NoError(t, err)
+	require.Len(t, recoveryCodes, 10)
+
+	_, err = twoFactor.BeginEnrollment(user)
+	assert.Equal(t, services.ErrTwoFactorAlreadyEnabled, err)
+
+	// A code is accepted once; the next period's code still works
+	assert.Equal(t, services.ErrInvalidTwoFactorCode, twoFactor.Verify(user.ID, code))
+	next, err := services.TOTPCode(enrollment.Secret, now.Add(30*time.Second))
+	require.NoError(t, err)
+	assert.NoError(t, twoFactor.Verify(user.ID, next))
+
+	// Recovery codes are single use and forgiving about formatting
+	assert.NoError(t, twoFactor.Verify(user.ID, strings.ToUpper(strings.ReplaceAll(recoveryCodes[0], "-", ""))))
+	assert.Equal(t, services.ErrInvalidTwoFactorCode, twoFactor.Verify(user.ID, recoveryCodes[0]))
+
+	status, err := twoFactor.Status(user.ID)
+	require.NoError(t, err)
+	assert.True(t, status.Enabled)
+	assert.Equal(t, 9, status.RecoveryCodesRemaining)
+
+	// Required users can't turn two-factor off
+	require.NoError(t, twoFactor.SetRequired(user.ID, true))
+	assert.Equal(t, services.ErrTwoFactorRequired, twoFactor.Disable(user.ID, recoveryCodes[1]))
+	require.NoError(t, twoFactor.SetRequired(user.ID, false))
+	require.NoError(t, twoFactor.Disable(user.ID, recoveryCodes[1]))
+
+	status, err = twoFactor.Status(user.ID)
+	require.NoError(t, err)
+	assert.False(t, status.Enabled)
+	assert.Equal(t, services.ErrTwoFactorNotEnabled, twoFactor.Verify(user.ID, recoveryCodes[2]))
+}
+
+func TestAuthService_TwoFactorLogin(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	cache := storage.NewMemoryCache()
+	user := createTwoFactorUser(t, store)
+
+	jwtService := services.NewJWTService("secret", "test", time.Hour, 24*time.Hour)
+	userService := services.NewUserService(store, cache, jwtService, nil, nil, nil)
+	authService := services.NewAuthService(userService, jwtService, nil, nil, store, cache, &configs.Config{})
+	twoFactor := services.NewTwoFactorService(store, cache, "Shortener")
+	authService.SetTwoFactorService(twoFactor)
+
+	login := &models.LoginRequest{Email: user.Email, Password: "correct horse"}
+	response, err := authService.Login(login, "", "")
+	require.NoError(t, err)
+	assert.NotEmpty(t, response.AccessToken)
+	assert.Nil(t, response.TwoFactor)
+
+	// Team owners must enrol before their login completes, and can't
+	// refresh their way around it
+	isOwner := true
+	twoFactor.SetTeamOwnerLookup(func(userID int64) (bool, error) { return isOwner, nil })
+	_, err = authService.RefreshTokens(&models.RefreshTokenRequest{RefreshToken: response.RefreshToken}, "", "")
+	assert.Equal(t, services.ErrTwoFactorSetupRequired, err)
+
+	response, err = authService.Login(login, "", "")
+	require.NoError(t, err)
+	require.NotNil(t, response.TwoFactor)
+	assert.True(t, response.TwoFactor.SetupRequired)
+	assert.Empty(t, response.AccessToken)
+
+	enrollment, err := authService.BeginTwoFactorLoginSetup(&models.TwoFactorSetupRequest{Token: response.TwoFactor.Token})
+	require.NoError(t, err)
+	code, err := services.TOTPCode(enrollment.Secret, time.Now())
+	require.NoError(t, err)
+	response, err = authService.CompleteTwoFactorLogin(&models.TwoFactorLoginRequest{Token: response.TwoFactor.Token, Code: code}, "", "")
+	require.NoError(t, err)
+	assert.NotEmpty(t, response.AccessToken)
+	assert.Len(t, response.RecoveryCodes, 10)
+	recoveryCode := response.RecoveryCodes[0]
+
+	lockoutCode := response.RecoveryCodes[1]
+
+	// Enrolled users are challenged for a code at every login, and aren't
+	// logged in until they answer it
+	isOwner = false
+	before, err := store.GetUserByID(user.ID)
+	require.NoError(t, err)
+	response, err = authService.Login(login, "", "")
+	require.NoError(t, err)
+	require.NotNil(t, response.TwoFactor)
+	assert.False(t, response.TwoFactor.SetupRequired)
+	body, err := json.Marshal(response)
+	require.NoError(t, err)
+	assert.NotContains(t, string(body), "access_token")
+	assert.NotContains(t, string(body), "refresh_token")
+	challenged, err := store.GetUserByID(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, before.LastLoginAt, challenged.LastLoginAt)
+	challenge := &models.TwoFactorLoginRequest{Token: response.TwoFactor.Token, Code: "000000"}
+
+	_, err = authService.CompleteTwoFactorLogin(challenge, "", "")
+	assert.Equal(t, services.ErrInvalidTwoFactorCode, err)
+	challenge.Code = recoveryCode
+	response, err = authService.CompleteTwoFactorLogin(challenge, "203.0.113.7", "test")
+	require.NoError(t, err)
+	assert.NotEmpty(t, response.AccessToken)
+	assert.Empty(t, response.RecoveryCodes)
+
+	// The session starts with the second factor
+	claims, err := jwtService.ValidateToken(response.AccessToken)
+	require.NoError(t, err)
+	session, err := store.GetSessionByID(claims.SessionID)
+	require.NoError(t, err)
+	require.NotNil(t, session.IPAddress)
+	assert.Equal(t, "203.0.113.7", *session.IPAddress)
+
+	// A completed challenge can't be used again
+	_, err = authService.CompleteTwoFactorLogin(challenge, "", "")
+	assert.Equal(t, services.ErrTwoFactorChallengeInvalid, err)
+
+	// Starting fresh challenges doesn't give unlimited guesses
+	for failures := 0; failures < 10; {
+		response, err = authService.Login(login, "", "")
+		require.NoError(t, err)
+		challenge = &models.TwoFactorLoginRequest{Token: response.TwoFactor.Token, Code: "000000"}
+		for attempt := 0; attempt < 5 && failures < 10; attempt++ {
+			_, err = authService.CompleteTwoFactorLogin(challenge, "", "")
+			assert.Equal(t, services.ErrInvalidTwoFactorCode, err)
+			failures++
+		}
+	}
+	response, err = authService.Login(login, "", "")
+	require.NoError(t, err)
+	challenge = &models.TwoFactorLoginRequest{Token: response.TwoFactor.Token, Code: lockoutCode}
+	_, err = authService.CompleteTwoFactorLogin(challenge, "", "")
+	assert.Equal(t, services.ErrTwoFactorLocked, err)
+}
+
+// createTwoFactorUser stores a user whose password is "correct horse"
+func createTwoFactorUser(t *testing.T, store storage.AccountStore) *models.User {
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	require.NoError(t, err)
+	passwordHash := string(hash)
+
+	now := time.Now()
+	user := &models.User{
+		ID: 7, Name: "Ada", Email: "ada@example.com", PasswordHash: &passwordHash, Provider: "email",
+		AccountType: "free", IsActive: true, CreatedAt: now, UpdatedAt: now,
+	}
+	require.NoError(t, store.CreateUser(user))
+	return user
+}
@@ -0,0 +1,70 @@
+package functional
+
+import (
+	"testing"
+	"time"
+
+	"github.com/URLshorter/url-shortener/configs"
+	"github.com/URLshorter/url-shortener/internal/models"
+	"github.com/URLshorter/url-shortener/internal/services"
+	"github.com/URLshorter/url-shortener/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContactVerification(t *testing.T) {
+	forEachBackend(t, exerciseContactVerification)
+}
+
+// exerciseContactVerification runs the email and phone verification flows on
+// every backend, not only Postgres
+func exerciseContactVerification(t *testing.T, store storage.Store, cache storage.Cache) {
+	phone := "+94771234567"
+	now := time.Now()
+	user := &models.User{
+		ID: 11, Name: "Grace", Email: "grace@example.com", Phone: &phone, Provider: "email",
+		AccountType: "free", IsActive: true, CreatedAt: now, UpdatedAt: now,
+	}
+	require.NoError(t, store.CreateUser(user))
+	config := &configs.Config{Environment: "development"}
+
+	emails := services.NewEmailService(store, config)
+	first, err := emails.SendVerificationEmail(user.ID, user.Email)
+	require.NoError(t, err)
+	_, err = emails.ResendVerificationEmail(user.ID)
+	assert.EqualError(t, err, "please wait before requesting another verification email")
+
+	// Only the newest token survives a fresh one being issued
+	require.NoError(t, emails.InvalidateEmailVerifications(user.ID))
+	latest, err := emails.SendVerificationEmail(user.ID, user.Email)
+	require.NoError(t, err)
+	assert.EqualError(t, emails.VerifyEmail(first.Token), "email already verified")
+	assert.EqualError(t, emails.VerifyEmail("not-a-token"), "invalid verification token")
+	require.NoError(t, emails.VerifyEmail(latest.Token))
+
+	stored, err := store.GetUserByID(user.ID)
+	require.NoError(t, err)
+	assert.True(t, stored.EmailVerified)
+	_, err = emails.ResendVerificationEmail(user.ID)
+	assert.EqualError(t, err, "email already verified")
+
+	sms := services.NewSMSService(store, config)
+	_, err = sms.ResendOTP(user.ID)
+	require.NoError(t, err)
+	_, err = sms.ResendOTP(user.ID)
+	assert.EqualError(t, err, "please wait before requesting another OTP")
+
+	pending, err := store.GetPhoneVerificationByUserID(user.ID)
+	require.NoError(t, err)
+	assert.EqualError(t, sms.VerifyOTP(user.ID, "not-the-code"), "invalid OTP")
+	attempted, err := store.GetPhoneVerificationByUserID(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, attempted.Attempts)
+
+	require.NoError(t, sms.VerifyOTP(user.ID, pending.OTPCode))
+	assert.EqualError(t, sms.VerifyOTP(user.ID, pending.OTPCode), "phone number already verified")
+
+	stored, err = store.GetUserByID(user.ID)
+	require.NoError(t, err)
+	assert.True(t, stored.PhoneVerified)
+}